@@ -4,25 +4,51 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/url"
-	"strconv"
 	"time"
 
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
 	"github.com/jsirianni/dzsa-sync/internal/metrics"
 	"github.com/jsirianni/dzsa-sync/model"
 )
 
-const (
-	baseURL = "https://dayzsalauncher.com/api/v1/query"
-)
+// Host is the DZSA launcher API host.
+const Host = "dayzsalauncher.com"
+
+// APIVersion is the DZSA API version the client queries. Responses are decoded with the model's decoder
+// for this version.
+const APIVersion = model.APIVersion1
+
+var baseURL = fmt.Sprintf("https://%s/api/v%d/query", Host, APIVersion)
 
 // DefaultHTTPTimeout is the default timeout for HTTP requests to the DZSA launcher.
 const DefaultHTTPTimeout = 60 * time.Second
 
+// StatusError is returned when DZSA answers with a status other than 200.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+// Retryable reports whether a Query error is likely transient: a network error, a timeout, 429, or 5xx.
+// Errors from DZSA about the server itself, such as an unreachable query port, are not.
+func Retryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
 // Client interacts with the DZSA API.
 type Client interface {
 	Query(ctx context.Context, ip string, port int) (*model.QueryResponse, error)
@@ -32,6 +58,8 @@ type Client interface {
 type Options struct {
 	HTTPClient *http.Client
 	Recorder   metrics.HTTPRecorder
+	// BaseURL replaces the DZSA query endpoint, e.g. a staging or mock server. Empty uses the live API.
+	BaseURL string
 }
 
 // New creates a new DZSA client.
@@ -40,8 +68,12 @@ func New(opts Options) Client {
 	if hc == nil {
 		hc = &http.Client{Timeout: DefaultHTTPTimeout}
 	}
+	base := opts.BaseURL
+	if base == "" {
+		base = baseURL
+	}
 	return &defaultClient{
-		baseURL:  baseURL,
+		baseURL:  base,
 		client:   hc,
 		recorder: opts.Recorder,
 	}
@@ -61,12 +93,12 @@ func (c *defaultClient) Query(ctx context.Context, ip string, port int) (*model.
 	host := "dzsa"
 	var statusCode int
 
-	endpoint, err := buildEndpoint(c.baseURL, ip, port)
+	endpoint, err := buildEndpoint(c.baseURL, model.Endpoint{IP: ip, Port: port})
 	if err != nil {
 		if c.recorder != nil {
 			c.recorder.RecordRequest(ctx, host, 0, metrics.ClassifyError(err, 0), time.Since(start))
 		}
-		return nil, fmt.Errorf("build endpoint: %w", err)
+		return nil, errkind.Errorf(errkind.Validation, "build endpoint: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
@@ -74,7 +106,7 @@ func (c *defaultClient) Query(ctx context.Context, ip string, port int) (*model.
 		if c.recorder != nil {
 			c.recorder.RecordRequest(ctx, host, 0, metrics.ClassifyError(err, 0), time.Since(start))
 		}
-		return nil, fmt.Errorf("create request: %w", err)
+		return nil, errkind.Errorf(errkind.Internal, "create request: %w", err)
 	}
 	req.Header.Set("User-Agent", "dzsa-sync/1.0")
 	req.Header.Set("Accept", "application/json")
@@ -84,7 +116,7 @@ func (c *defaultClient) Query(ctx context.Context, ip string, port int) (*model.
 		if c.recorder != nil {
 			c.recorder.RecordRequest(ctx, host, 0, metrics.ClassifyError(err, 0), time.Since(start))
 		}
-		return nil, fmt.Errorf("do request: %w", err)
+		return nil, errkind.Errorf(errkind.Network, "do request: %w", err)
 	}
 	defer resp.Body.Close()
 	statusCode = resp.StatusCode
@@ -93,7 +125,7 @@ func (c *defaultClient) Query(ctx context.Context, ip string, port int) (*model.
 		if c.recorder != nil {
 			c.recorder.RecordRequest(ctx, host, statusCode, metrics.ClassifyError(nil, statusCode), time.Since(start))
 		}
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, errkind.Wrap(errkind.Upstream, &StatusError{StatusCode: resp.StatusCode})
 	}
 
 	b, err := io.ReadAll(resp.Body)
@@ -101,7 +133,7 @@ func (c *defaultClient) Query(ctx context.Context, ip string, port int) (*model.
 		if c.recorder != nil {
 			c.recorder.RecordRequest(ctx, host, statusCode, metrics.ErrorDecode, time.Since(start))
 		}
-		return nil, fmt.Errorf("read response: %w", err)
+		return nil, errkind.Errorf(errkind.Network, "read response: %w", err)
 	}
 
 	rawReq := make(map[string]any)
@@ -109,21 +141,28 @@ func (c *defaultClient) Query(ctx context.Context, ip string, port int) (*model.
 		if c.recorder != nil {
 			c.recorder.RecordRequest(ctx, host, statusCode, metrics.ErrorDecode, time.Since(start))
 		}
-		return nil, fmt.Errorf("decode response: %w", err)
+		return nil, errkind.Errorf(errkind.Upstream, "decode response: %w", err)
 	}
 	if _, ok := rawReq["error"]; ok {
 		if c.recorder != nil {
 			c.recorder.RecordRequest(ctx, host, statusCode, metrics.ErrorStatus4xx, time.Since(start))
 		}
-		return nil, fmt.Errorf("api error: %v", rawReq["error"])
+		return nil, errkind.Errorf(errkind.Upstream, "api error: %v", rawReq["error"])
 	}
 
-	queryResponse := &model.QueryResponse{}
-	if err := json.Unmarshal(b, queryResponse); err != nil {
+	queryResponse, err := model.DecodeQueryResponse(APIVersion, b)
+	if err != nil {
 		if c.recorder != nil {
 			c.recorder.RecordRequest(ctx, host, statusCode, metrics.ErrorDecode, time.Since(start))
 		}
-		return nil, fmt.Errorf("unmarshal response: %w", err)
+		return nil, errkind.Errorf(errkind.Upstream, "unmarshal response: %w", err)
+	}
+	queryResponse.Result.Mods = model.NormalizeMods(queryResponse.Result.Mods)
+	if err := queryResponse.Result.Validate(); err != nil {
+		if c.recorder != nil {
+			c.recorder.RecordRequest(ctx, host, statusCode, metrics.ErrorInvalid, time.Since(start))
+		}
+		return nil, errkind.Errorf(errkind.Upstream, "invalid result: %w", err)
 	}
 
 	if c.recorder != nil {
@@ -132,15 +171,17 @@ func (c *defaultClient) Query(ctx context.Context, ip string, port int) (*model.
 	return queryResponse, nil
 }
 
-func buildEndpoint(base, ip string, port int) (string, error) {
+func buildEndpoint(base string, e model.Endpoint) (string, error) {
+	if err := e.Validate(); err != nil {
+		return "", err
+	}
 	u, err := url.Parse(base)
 	if err != nil {
 		return "", fmt.Errorf("parse base: %w", err)
 	}
-	ipPort := net.JoinHostPort(ip, strconv.Itoa(port))
-	path, err := url.JoinPath(u.Path, ipPort)
+	path, err := url.JoinPath(u.Path, e.String())
 	if err != nil {
-		return "", fmt.Errorf("join path %s: %w", ipPort, err)
+		return "", fmt.Errorf("join path %s: %w", e, err)
 	}
 	u.Path = path
 	return u.String(), nil
@@ -1,7 +1,16 @@
 package client
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+	"github.com/jsirianni/dzsa-sync/model"
 )
 
 func Test_buildEndpoint(t *testing.T) {
@@ -11,6 +20,7 @@ func Test_buildEndpoint(t *testing.T) {
 		ip      string
 		port    int
 		want    string
+		wantErr bool
 	}{
 		{
 			name:    "valid",
@@ -19,12 +29,33 @@ func Test_buildEndpoint(t *testing.T) {
 			port:    2424,
 			want:    "https://dayzsalauncher.com/api/v1/query/50.108.13.235:2424",
 		},
+		{
+			name:    "ipv6",
+			baseURL: "https://dayzsalauncher.com/api/v1/query",
+			ip:      "2001:db8::1",
+			port:    2424,
+			want:    "https://dayzsalauncher.com/api/v1/query/%5B2001:db8::1%5D:2424",
+		},
+		{
+			name:    "hostname",
+			baseURL: "https://dayzsalauncher.com/api/v1/query",
+			ip:      "dayz.example.com",
+			port:    2424,
+			wantErr: true,
+		},
+		{
+			name:    "port out of range",
+			baseURL: "https://dayzsalauncher.com/api/v1/query",
+			ip:      "50.108.13.235",
+			port:    0,
+			wantErr: true,
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			got, err := buildEndpoint(tc.baseURL, tc.ip, tc.port)
-			if err != nil {
-				t.Fatalf("unexpected error: %v", err)
+			got, err := buildEndpoint(tc.baseURL, model.Endpoint{IP: tc.ip, Port: tc.port})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tc.wantErr)
 			}
 			if got != tc.want {
 				t.Errorf("got: %s, want: %s", got, tc.want)
@@ -32,3 +63,72 @@ func Test_buildEndpoint(t *testing.T) {
 		})
 	}
 }
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&StatusError{StatusCode: 503}, true},
+		{&StatusError{StatusCode: 429}, true},
+		{&StatusError{StatusCode: 404}, false},
+		{fmt.Errorf("do request: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), true},
+		{fmt.Errorf("do request: %w", context.DeadlineExceeded), true},
+		{errors.New("api error: Timed out querying server"), false},
+	}
+	for _, tc := range tests {
+		if got := Retryable(tc.err); got != tc.want {
+			t.Errorf("Retryable(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestQuerySchemaDrift(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"status":0,"result":{"name":"main","players":"12","maxPlayers":60,"endpoint":{"ip":"203.0.113.10","port":2424},"queue":3,
+			"mods":[{"name":"VPP","steamWorkshopId":1828439124},{"name":"CF ","steamWorkshopId":1559212036}]}}`))
+	}))
+	defer srv.Close()
+	c := &defaultClient{baseURL: srv.URL, client: srv.Client()}
+
+	resp, err := c.Query(context.Background(), "203.0.113.10", 2424)
+	if err != nil {
+		t.Fatalf("Query() error = %v, want the sync to succeed despite drift", err)
+	}
+	if resp.Result.Players != 12 || resp.Drift == nil || len(resp.Drift.Unknown) != 1 {
+		t.Errorf("Query() = %+v, drift %v", resp.Result, resp.Drift)
+	}
+	if mods := resp.Result.Mods; len(mods) != 2 || mods[0].Name != "CF" || mods[1].Name != "VPP" {
+		t.Errorf("Query() mods = %v, want them normalized", mods)
+	}
+}
+
+func TestQueryInvalidResult(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"status":0,"result":{"name":"main","players":80,"maxPlayers":60,"endpoint":{"ip":"203.0.113.10","port":2424}}}`))
+	}))
+	defer srv.Close()
+	c := &defaultClient{baseURL: srv.URL, client: srv.Client()}
+
+	_, err := c.Query(context.Background(), "203.0.113.10", 2424)
+	if err == nil || errkind.Of(err) != errkind.Upstream {
+		t.Errorf("Query() error = %v, want an upstream_api error", err)
+	}
+}
+
+func TestNew_BaseURL(t *testing.T) {
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		_, _ = w.Write([]byte(`{"status":0,"result":{"name":"main","players":1,"maxPlayers":60,"endpoint":{"ip":"203.0.113.10","port":2424}}}`))
+	}))
+	defer srv.Close()
+	c := New(Options{HTTPClient: srv.Client(), BaseURL: srv.URL + "/api/v1/query"})
+
+	if _, err := c.Query(context.Background(), "203.0.113.10", 2424); err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if path != "/api/v1/query/203.0.113.10:2424" {
+		t.Errorf("Query() sent to %q, want the staging endpoint", path)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/backup"
+	"github.com/spf13/cobra"
+)
+
+// backupTimeout bounds backup and restore requests, which move a whole history database.
+const backupTimeout = 5 * time.Minute
+
+func newBackupCmd() *cobra.Command {
+	var addr, out string
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Write an archive of the running daemon's state",
+		Long: "Write a gzipped tar archive of the running daemon's server store, external IP, and SQLite history " +
+			"(when history.sqlite is enabled). Restore it with dzsa-sync restore. Requires the admin token when " +
+			"api.admin is set; see " + envAPIToken + ".",
+		Example: "  dzsa-sync backup\n  dzsa-sync backup --addr http://127.0.0.1:8889 --out /var/backups/dzsa-sync.tar.gz",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			resp, err := apiSend(cmd, http.MethodPost, addr, "/api/v1/backup", nil, backupTimeout)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			if out == "" {
+				out = attachmentName(resp.Header.Get("Content-Disposition"))
+			}
+			f, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600) // #nosec G304 -- path from the user
+			if err != nil {
+				return fmt.Errorf("create backup: %w", err)
+			}
+			if _, err := io.Copy(f, resp.Body); err != nil {
+				f.Close()
+				return fmt.Errorf("write backup: %w", err)
+			}
+			if err := f.Close(); err != nil {
+				return fmt.Errorf("write backup: %w", err)
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "wrote %s\n", out)
+			return nil
+		},
+	}
+	addAddrFlag(cmd, &addr)
+	cmd.Flags().StringVar(&out, "out", "", "Output file (default the name the daemon suggests, in the current directory)")
+	return cmd
+}
+
+// attachmentName returns the base name of the file in a Content-Disposition header, or a timestamped name.
+func attachmentName(header string) string {
+	if _, params, err := mime.ParseMediaType(header); err == nil {
+		if name := filepath.Base(params["filename"]); name != "." && name != "/" && name != "" {
+			return name
+		}
+	}
+	return "dzsa-sync-" + time.Now().UTC().Format("20060102T150405Z") + ".tar.gz"
+}
+
+func newRestoreCmd() *cobra.Command {
+	var addr string
+	cmd := &cobra.Command{
+		Use:   "restore <archive>",
+		Short: "Restore an archive written by dzsa-sync backup into the running daemon",
+		Long: "Upload an archive written by dzsa-sync backup to the running daemon. The archive is checked first: " +
+			"a backup from a newer, incompatible build is rejected without changing anything. The server store is " +
+			"replaced, and history records older than the daemon's oldest record are imported. The external IP is " +
+			"not applied; a difference is reported as a warning.",
+		Example: "  dzsa-sync restore dzsa-sync-20260101T000000Z.tar.gz",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := os.Open(args[0]) // #nosec G304 -- path from the user
+			if err != nil {
+				return fmt.Errorf("open backup: %w", err)
+			}
+			defer f.Close()
+			resp, err := apiSend(cmd, http.MethodPost, addr, "/api/v1/restore", f, backupTimeout)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			var res backup.Result
+			if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+				return fmt.Errorf("decode response: %w", err)
+			}
+			w := cmd.OutOrStdout()
+			fmt.Fprintf(w, "restored backup taken %s by dzsa-sync %s", res.Manifest.CreatedAt.Format(time.RFC3339), res.Manifest.Version)
+			if res.Manifest.InstanceName != "" {
+				fmt.Fprintf(w, " on %s", res.Manifest.InstanceName)
+			}
+			fmt.Fprintln(w)
+			fmt.Fprintf(w, "servers: %d\nhistory records imported: %d\n", res.Servers, res.HistoryRecords)
+			for _, warning := range res.Warnings {
+				fmt.Fprintf(cmd.ErrOrStderr(), "warning: %s\n", warning)
+			}
+			return nil
+		},
+	}
+	addAddrFlag(cmd, &addr)
+	return cmd
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/client"
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/a2s"
+	"github.com/jsirianni/dzsa-sync/internal/ifconfig"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// checkReport is the check command's output.
+type checkReport struct {
+	ExternalIP string        `json:"external_ip"`
+	Servers    []checkResult `json:"servers"`
+}
+
+// checkResult is one server's probe results. Each leg covers one hop between the game server and
+// the launcher: local (the server answers Steam queries), external (the query port is reachable
+// through the public IP), and dzsa (the launcher can query the server).
+type checkResult struct {
+	Name     string   `json:"name"`
+	Port     int      `json:"port"`
+	Local    checkLeg `json:"local"`
+	External checkLeg `json:"external"`
+	DZSA     checkLeg `json:"dzsa"`
+	// Broken is true when the server cannot be listed; Diagnosis says which leg to fix.
+	Broken    bool   `json:"broken"`
+	Diagnosis string `json:"diagnosis,omitempty"`
+}
+
+// checkLeg is the outcome of one probe.
+type checkLeg struct {
+	Addr  string `json:"addr"`
+	OK    bool   `json:"ok"`
+	Info  string `json:"info,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// prober runs the reachability probes. The functions are replaced in tests.
+type prober struct {
+	info  func(ctx context.Context, addr string) (*a2s.Info, error)
+	query func(ctx context.Context, ip string, port int) (string, error)
+}
+
+func newProber(timeout time.Duration) *prober {
+	a2sClient := &a2s.Client{Timeout: timeout}
+	dzsa := client.New(client.Options{})
+	return &prober{
+		info: a2sClient.Info,
+		query: func(ctx context.Context, ip string, port int) (string, error) {
+			ctx, cancel := context.WithTimeout(ctx, client.DefaultHTTPTimeout)
+			defer cancel()
+			resp, err := dzsa.Query(ctx, ip, port)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%s, %d/%d players", resp.Result.Name, resp.Result.Players, resp.Result.MaxPlayers), nil
+		},
+	}
+}
+
+func newCheckCmd(configPath *string) *cobra.Command {
+	var (
+		output  string
+		timeout time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:   "check [name|port ...]",
+		Short: "Check that each server is reachable by DZSA and report which leg is broken",
+		Long: "Probe every configured server (or the named ones) along the path the launcher uses: an A2S query to the " +
+			"local query port (a2s.host, default 127.0.0.1), an A2S query through the external IP, and a DZSA query. " +
+			"The result says which leg is broken. Routers without NAT hairpinning fail the external probe from inside " +
+			"the network even when the port is forwarded; the DZSA query, which comes from outside, decides in that case.",
+		Example: "  dzsa-sync check --config /etc/dzsa-sync/config.yaml\n  dzsa-sync check main 2324 -o json",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(*configPath)
+			if err != nil {
+				return err
+			}
+			// Monitor-only servers are not ours to fix.
+			own := slices.DeleteFunc(slices.Clone(cfg.Servers), func(s config.Server) bool { return s.MonitorOnly })
+			servers, err := selectServers(own, args)
+			if err != nil {
+				return err
+			}
+			localHost := "127.0.0.1"
+			if cfg.A2S != nil && cfg.A2S.Host != "" {
+				localHost = cfg.A2S.Host
+			}
+
+			ipCtx, cancel := context.WithTimeout(cmd.Context(), connectivityTimeout)
+			defer cancel()
+			ifc := ifconfig.New(zap.NewNop(), nil, nil)
+			ip, err := resolveExternalIP(ipCtx, cfg.DetectIP, cfg.ExternalIP, false, ifc.Get)
+			if err != nil {
+				return err
+			}
+
+			report := newProber(timeout).run(cmd.Context(), localHost, ip.IP, servers)
+			if err := writeOutput(cmd.OutOrStdout(), output, report, func(w io.Writer) error {
+				return printCheck(w, report)
+			}); err != nil {
+				return err
+			}
+			broken := 0
+			for _, r := range report.Servers {
+				if r.Broken {
+					broken++
+				}
+			}
+			if broken > 0 {
+				return fmt.Errorf("%d of %d servers cannot be listed", broken, len(report.Servers))
+			}
+			return nil
+		},
+	}
+	addOutputFlag(cmd, &output)
+	cmd.Flags().DurationVar(&timeout, "timeout", a2s.DefaultTimeout, "Timeout for each A2S query")
+	return cmd
+}
+
+// selectServers returns the servers matching targets by name or port, or all servers when targets is empty.
+func selectServers(servers []config.Server, targets []string) ([]config.Server, error) {
+	if len(targets) == 0 {
+		if len(servers) == 0 {
+			return nil, fmt.Errorf("no servers configured")
+		}
+		return servers, nil
+	}
+	var out []config.Server
+	for _, t := range targets {
+		i := slices.IndexFunc(servers, func(s config.Server) bool {
+			return s.Name == t || strconv.Itoa(s.Port) == t
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("no configured server matches %q", t)
+		}
+		out = append(out, servers[i])
+	}
+	return out, nil
+}
+
+// run probes each server's three legs and diagnoses the result.
+func (p *prober) run(ctx context.Context, localHost, externalIP string, servers []config.Server) *checkReport {
+	report := &checkReport{ExternalIP: externalIP}
+	for _, s := range servers {
+		r := checkResult{Name: s.Name, Port: s.Port}
+		r.Local = p.probeA2S(ctx, net.JoinHostPort(localHost, strconv.Itoa(s.Port)))
+		// Players and DZSA reach the server at its advertised endpoint, e.g. a relay in front of it.
+		ip, port := s.Advertised(externalIP)
+		r.External = p.probeA2S(ctx, net.JoinHostPort(ip, strconv.Itoa(port)))
+		r.DZSA = checkLeg{Addr: net.JoinHostPort(ip, strconv.Itoa(port))}
+		if info, err := p.query(ctx, ip, port); err != nil {
+			r.DZSA.Error = err.Error()
+		} else {
+			r.DZSA.OK, r.DZSA.Info = true, info
+		}
+		r.Broken, r.Diagnosis = diagnose(r)
+		if !r.Local.OK && !r.External.OK && s.QueryPort == "" {
+			if queryPort, ok := p.gamePort(ctx, localHost, s.Port); ok {
+				r.Diagnosis = fmt.Sprintf("%d is the server's game port, and its query port %d answers: replace port with game_port: %d and query_port: auto", s.Port, queryPort, s.Port)
+			}
+		}
+		report.Servers = append(report.Servers, r)
+	}
+	return report
+}
+
+// gamePort reports whether port is the game port of a server on host, and if so its query port.
+func (p *prober) gamePort(ctx context.Context, host string, port int) (int, bool) {
+	queryPort := config.DeriveQueryPort(port)
+	if queryPort > 65535 {
+		return 0, false
+	}
+	info, err := p.info(ctx, net.JoinHostPort(host, strconv.Itoa(queryPort)))
+	if err != nil || info.GamePort != port {
+		return 0, false
+	}
+	return queryPort, true
+}
+
+func (p *prober) probeA2S(ctx context.Context, addr string) checkLeg {
+	leg := checkLeg{Addr: addr}
+	info, err := p.info(ctx, addr)
+	if err != nil {
+		leg.Error = err.Error()
+		return leg
+	}
+	leg.OK = true
+	leg.Info = fmt.Sprintf("%s, %d/%d players", info.Name, info.Players, info.MaxPlayers)
+	return leg
+}
+
+// diagnose reports whether the server can be listed and, if something failed, the most likely cause.
+func diagnose(r checkResult) (broken bool, diagnosis string) {
+	switch {
+	case r.Local.OK && r.External.OK && r.DZSA.OK:
+		return false, ""
+	case r.DZSA.OK && !r.External.OK:
+		return false, "DZSA reaches the server; the external probe likely failed because the router does not support NAT hairpinning"
+	case r.DZSA.OK:
+		return false, fmt.Sprintf("DZSA reaches the server but %s does not answer; set a2s.host to the address the server listens on", r.Local.Addr)
+	case !r.Local.OK && !r.External.OK:
+		return true, fmt.Sprintf("the server does not answer Steam queries on %s: check it is running and that %d is its steamQueryPort", r.Local.Addr, r.Port)
+	case !r.External.OK:
+		return true, fmt.Sprintf("the query port is not reachable through %s: forward UDP %d to this host and allow it through the firewall", r.External.Addr, r.Port)
+	default:
+		return true, "the query port answers through the external IP but DZSA cannot query it: check the DZSA error and that no firewall rule limits which sources may query the port"
+	}
+}
+
+func printCheck(out io.Writer, report *checkReport) error {
+	fmt.Fprintf(out, "External IP: %s\n\n", report.ExternalIP)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPORT\tLOCAL\tEXTERNAL\tDZSA")
+	for _, r := range report.Servers {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", r.Name, r.Port, legStatus(r.Local), legStatus(r.External), legStatus(r.DZSA))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for _, r := range report.Servers {
+		if r.Diagnosis == "" {
+			continue
+		}
+		fmt.Fprintf(out, "\n%s (%d): %s\n", r.Name, r.Port, r.Diagnosis)
+		for _, leg := range []struct {
+			name string
+			leg  checkLeg
+		}{{"local", r.Local}, {"external", r.External}, {"dzsa", r.DZSA}} {
+			if leg.leg.Error != "" {
+				fmt.Fprintf(out, "  %s %s: %s\n", leg.name, leg.leg.Addr, leg.leg.Error)
+			}
+		}
+	}
+	return nil
+}
+
+func legStatus(l checkLeg) string {
+	if l.OK {
+		return "ok"
+	}
+	return "FAILED"
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/client"
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/ifconfig"
+	"go.uber.org/zap"
+)
+
+const connectivityTimeout = 15 * time.Second
+
+// connectivity tests reachability of the IP detection service and DZSA. The functions are
+// replaced in tests.
+type connectivity struct {
+	detectIP func(ctx context.Context) (string, error)
+	query    func(ctx context.Context, ip string, port int) error
+}
+
+func newConnectivity() *connectivity {
+	ifc := ifconfig.New(zap.NewNop(), nil, nil)
+	dzsa := client.New(client.Options{})
+	return &connectivity{
+		detectIP: func(ctx context.Context) (string, error) {
+			r, err := ifc.Get(ctx)
+			if err != nil {
+				return "", err
+			}
+			return r.IP, nil
+		},
+		query: func(ctx context.Context, ip string, port int) error {
+			_, err := dzsa.Query(ctx, ip, port)
+			return err
+		},
+	}
+}
+
+// check writes one line per test to w. When detect is true the external IP is detected first;
+// otherwise ip is used for the DZSA queries. Failures are reported, not returned: a server that
+// is not running is expected to fail its DZSA query.
+func (c *connectivity) check(ctx context.Context, w io.Writer, detect bool, ip string, servers []config.Server) {
+	ctx, cancel := context.WithTimeout(ctx, connectivityTimeout)
+	defer cancel()
+
+	if detect {
+		detected, err := c.detectIP(ctx)
+		if err != nil {
+			fmt.Fprintf(w, "  ifconfig.net: FAILED (%v)\n", err)
+			return
+		}
+		fmt.Fprintf(w, "  ifconfig.net: ok, external IP %s\n", detected)
+		ip = detected
+	}
+	for _, srv := range servers {
+		addr := ip
+		if srv.MonitorOnly {
+			addr = srv.IP
+		}
+		if err := c.query(ctx, addr, srv.Port); err != nil {
+			fmt.Fprintf(w, "  DZSA %s (%d): FAILED (%v)\n", srv.Name, srv.Port, err)
+			continue
+		}
+		fmt.Fprintf(w, "  DZSA %s (%d): ok\n", srv.Name, srv.Port)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/api"
+	"github.com/jsirianni/dzsa-sync/internal/controller"
+	"github.com/jsirianni/dzsa-sync/internal/daemon"
+	"github.com/jsirianni/dzsa-sync/internal/httpclient"
+	"github.com/jsirianni/dzsa-sync/internal/metrics"
+	"github.com/jsirianni/dzsa-sync/internal/redact"
+	"go.uber.org/zap"
+)
+
+// runController runs the instance in controller mode until SIGINT or SIGTERM: it polls the configured
+// agents and serves their combined state, instead of syncing servers itself.
+func runController(cfg *config.Config, logger *zap.Logger, redactor *redact.Redactor) error {
+	signalCtx, signalCancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer signalCancel()
+
+	metricsProvider, err := metrics.NewProvider(cfg.InstanceName, daemon.OTLPOptions(cfg.Metrics))
+	if err != nil {
+		logger.Fatal("metrics provider", zap.Error(err))
+	}
+	defer func() {
+		_ = metricsProvider.Shutdown(context.Background())
+	}()
+	agentRecorder, err := metrics.NewAgentRecorder()
+	if err != nil {
+		logger.Fatal("agent recorder", zap.Error(err))
+	}
+	dnsRecorder, err := metrics.NewDNSRecorder()
+	if err != nil {
+		logger.Fatal("dns recorder", zap.Error(err))
+	}
+	httpOpts, err := daemon.HTTPOptions(cfg.HTTP, dnsRecorder)
+	if err != nil {
+		logger.Fatal("http client", zap.Error(err))
+	}
+
+	ctrl := controller.New(controller.Options{
+		Agents:     cfg.Controller.Agents,
+		Interval:   cfg.Controller.Interval,
+		HTTPClient: httpclient.New(httpOpts),
+		Recorder:   agentRecorder,
+		Logger:     logger,
+	})
+
+	apiOpts := api.ControllerOptions{
+		Addr:           daemon.APIAddr(cfg.API),
+		MetricsHandler: metricsProvider.Handler(daemon.MetricsHandlerOptions(cfg.Metrics)),
+		Fleet:          ctrl,
+		InstanceName:   cfg.InstanceName,
+		Logger:         logger,
+	}
+	if cfg.API != nil {
+		if apiOpts.TrustedProxies, err = api.ParseTrustedProxies(cfg.API.TrustedProxies); err != nil {
+			logger.Fatal("API server", zap.Error(err))
+		}
+		apiOpts.UI = cfg.API.UI
+	}
+	if redactor != nil {
+		apiOpts.Redact = redactor.String
+	}
+	var adminServer *http.Server
+	if cfg.API != nil && cfg.API.Admin != nil {
+		adminOpts := apiOpts
+		adminOpts.Addr = net.JoinHostPort(cfg.API.Admin.Host, strconv.Itoa(cfg.API.Admin.Port))
+		adminOpts.AdminToken = cfg.API.Admin.Token
+		adminServer = api.NewControllerServer(adminOpts)
+		apiOpts.ReadOnly = true
+	}
+	apiServer := api.NewControllerServer(apiOpts)
+	serve := func(name string, srv *http.Server) {
+		logger.Info(name+" listening", zap.String("addr", srv.Addr))
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error(name, zap.Error(err))
+			signalCancel()
+		}
+	}
+	go serve("API server", apiServer)
+	if adminServer != nil {
+		go serve("admin API server", adminServer)
+	}
+	defer func() {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer shutdownCancel()
+		_ = apiServer.Shutdown(shutdownCtx)
+		if adminServer != nil {
+			_ = adminServer.Shutdown(shutdownCtx)
+		}
+	}()
+
+	if err := sdNotify("READY=1"); err != nil {
+		logger.Warn("systemd notify", zap.Error(err))
+	}
+	logger.Info("controller started", zap.Int("agents", len(cfg.Controller.Agents)))
+	ctrl.Run(signalCtx)
+	logger.Info("shutdown complete")
+	return nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultDiagLogLines = 1000
+
+func newDiagCmd(configPath *string) *cobra.Command {
+	var (
+		addr       string
+		out        string
+		logLines   int
+		skipChecks bool
+	)
+	cmd := &cobra.Command{
+		Use:   "diag",
+		Short: "Collect a diagnostics bundle for bug reports",
+		Long: "Collect version info, the config with secrets redacted, recent log lines, a metrics and status " +
+			"snapshot from the running daemon, and connectivity test results into a .tar.gz to attach to a bug report. " +
+			"Anything that cannot be collected is listed in errors.txt inside the bundle.",
+		Example: "  sudo dzsa-sync diag --config /etc/dzsa-sync/config.yaml",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			d := &diag{cmd: cmd, addr: addr, logLines: logLines}
+			if !skipChecks {
+				d.checker = newConnectivity()
+			}
+			bundle := d.collect(*configPath)
+
+			if out == "" {
+				out = fmt.Sprintf("dzsa-sync-diag-%s.tar.gz", time.Now().UTC().Format("20060102-150405"))
+			}
+			f, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600) // #nosec G304 -- path is user-supplied
+			if err != nil {
+				return fmt.Errorf("create bundle: %w", err)
+			}
+			if err := bundle.write(f); err != nil {
+				f.Close()
+				return fmt.Errorf("write bundle: %w", err)
+			}
+			if err := f.Close(); err != nil {
+				return fmt.Errorf("write bundle: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "wrote %s (%d files", out, len(bundle.files))
+			if len(bundle.errs) > 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), ", %d items could not be collected, see errors.txt", len(bundle.errs))
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), ")")
+			return nil
+		},
+	}
+	addAddrFlag(cmd, &addr)
+	cmd.Flags().StringVar(&out, "out", "", "Bundle path (default dzsa-sync-diag-<timestamp>.tar.gz)")
+	cmd.Flags().IntVar(&logLines, "log-lines", defaultDiagLogLines, "Number of recent log lines to include")
+	cmd.Flags().BoolVar(&skipChecks, "skip-checks", false, "Do not test connectivity to DZSA and the IP detection service")
+	return cmd
+}
+
+// diag collects the diagnostics bundle. checker is nil when connectivity checks are skipped.
+type diag struct {
+	cmd      *cobra.Command
+	addr     string
+	logLines int
+	checker  *connectivity
+}
+
+// diagBundle is the set of files written to the tarball, in order, plus the errors met collecting them.
+type diagBundle struct {
+	files []diagFile
+	errs  []string
+}
+
+type diagFile struct {
+	name string
+	data []byte
+}
+
+func (b *diagBundle) add(name string, data []byte) {
+	b.files = append(b.files, diagFile{name: name, data: data})
+}
+
+func (b *diagBundle) fail(what string, err error) {
+	b.errs = append(b.errs, fmt.Sprintf("%s: %v", what, err))
+}
+
+// collect gathers every part of the bundle. It never fails: whatever cannot be collected is recorded in errors.txt.
+func (d *diag) collect(configPath string) *diagBundle {
+	b := &diagBundle{}
+
+	var version bytes.Buffer
+	printVersion(&version)
+	b.add("version.txt", version.Bytes())
+
+	cfg := d.collectConfig(b, configPath)
+
+	switch {
+	case cfg == nil || cfg.LogPath == "":
+	case cfg.LogPath == config.LogStdout || cfg.LogPath == config.LogStderr:
+		b.fail("log", fmt.Errorf("log_path is %s; attach the service manager's logs separately", cfg.LogPath))
+	default:
+		if lines, _, err := tailLines(cfg.LogPath, d.logLines); err != nil {
+			b.fail("log", err)
+		} else {
+			b.add("log.txt", lines)
+		}
+	}
+
+	for _, f := range []struct{ name, path string }{
+		{"status.json", "/api/v1/status"},
+		{"metrics.txt", "/metrics"},
+	} {
+		if data, err := d.apiRaw(f.path); err != nil {
+			b.fail(f.name, err)
+		} else {
+			b.add(f.name, data)
+		}
+	}
+
+	switch {
+	case d.checker == nil:
+		b.add("connectivity.txt", []byte("skipped (--skip-checks)\n"))
+	case cfg == nil:
+		b.fail("connectivity", fmt.Errorf("skipped: no config"))
+	default:
+		var conn bytes.Buffer
+		d.checker.check(d.cmd.Context(), &conn, cfg.DetectIP, cfg.ExternalIP, cfg.Servers)
+		b.add("connectivity.txt", conn.Bytes())
+	}
+
+	if len(b.errs) > 0 {
+		var errs bytes.Buffer
+		for _, e := range b.errs {
+			fmt.Fprintln(&errs, e)
+		}
+		b.add("errors.txt", errs.Bytes())
+	}
+	return b
+}
+
+// collectConfig adds the redacted config and its validation result, and returns the parsed
+// config (even when it is invalid) or nil when it cannot be read.
+func (d *diag) collectConfig(b *diagBundle, path string) *config.Config {
+	if path == "" {
+		b.fail("config", fmt.Errorf("--config not set"))
+		return nil
+	}
+	raw, err := os.ReadFile(path) // #nosec G304 -- path is user-supplied
+	if err != nil {
+		b.fail("config", err)
+		return nil
+	}
+	redacted, err := config.Redact(raw)
+	if err != nil {
+		// Never include an unredacted config.
+		b.fail("config", err)
+		return nil
+	}
+	b.add("config.yaml", redacted)
+
+	var cfg config.Config
+	if err := yaml.Unmarshal(raw, &cfg); err != nil {
+		b.fail("config", err)
+		return nil
+	}
+	validation := "valid\n"
+	if err := cfg.Validate(); err != nil {
+		validation = fmt.Sprintf("invalid: %v\n", err)
+	}
+	b.add("config-validation.txt", []byte(validation))
+	return &cfg
+}
+
+// apiRaw returns the body of GET path on the running daemon.
+func (d *diag) apiRaw(path string) ([]byte, error) {
+	resp, err := apiDo(d.cmd, http.MethodGet, d.addr, path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// write encodes the bundle as a gzipped tarball under a dzsa-sync-diag/ directory.
+func (b *diagBundle) write(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, f := range b.files {
+		hdr := &tar.Header{
+			Name:    "dzsa-sync-diag/" + f.name,
+			Mode:    0o600,
+			Size:    int64(len(f.data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
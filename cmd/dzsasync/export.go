@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/api"
+	"github.com/jsirianni/dzsa-sync/internal/buildinfo"
+	"github.com/jsirianni/dzsa-sync/internal/history"
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+	"github.com/spf13/cobra"
+)
+
+// Export formats and CSV tables.
+const (
+	exportJSON    = "json"
+	exportCSV     = "csv"
+	exportServers = "servers"
+	exportHistory = "history"
+)
+
+// exportSnapshot is the JSON export: everything the daemon's API exposes at one point in time.
+type exportSnapshot struct {
+	ExportedAt time.Time             `json:"exported_at"`
+	Daemon     buildinfo.Info        `json:"daemon"`
+	Status     api.StatusResponse    `json:"status"`
+	Servers    []servers.ServerEntry `json:"servers"`
+	// History is omitted when the daemon has no history store enabled.
+	History []history.Record `json:"history,omitempty"`
+}
+
+func newExportCmd() *cobra.Command {
+	var (
+		addr   string
+		format string
+		table  string
+		out    string
+		since  time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Dump the running daemon's state to JSON or CSV",
+		Long: "Dump the running daemon's current state for backups or analysis. JSON includes build info, external IP, " +
+			"every server's latest result and sync state, and sync history (when history is enabled) for --since. " +
+			"CSV writes one table: servers (default) or history.",
+		Example: "  dzsa-sync export --out state.json\n  dzsa-sync export --format csv --table history --since 168h --out week.csv",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if format != exportJSON && format != exportCSV {
+				return fmt.Errorf("invalid format %q: must be %s or %s", format, exportJSON, exportCSV)
+			}
+			if table != exportServers && table != exportHistory {
+				return fmt.Errorf("invalid table %q: must be %s or %s", table, exportServers, exportHistory)
+			}
+			snap, err := fetchExport(cmd, addr, since, format == exportJSON || table == exportHistory)
+			if err != nil {
+				return err
+			}
+
+			var buf bytes.Buffer
+			switch {
+			case format == exportJSON:
+				enc := json.NewEncoder(&buf)
+				enc.SetIndent("", "  ")
+				err = enc.Encode(snap)
+			case table == exportHistory:
+				err = writeHistoryCSV(&buf, snap.History)
+			default:
+				err = writeServersCSV(&buf, snap.Status.Servers)
+			}
+			if err != nil {
+				return fmt.Errorf("encode export: %w", err)
+			}
+			if out == "" || out == "-" {
+				_, err := cmd.OutOrStdout().Write(buf.Bytes())
+				return err
+			}
+			if err := os.WriteFile(out, buf.Bytes(), 0o600); err != nil {
+				return fmt.Errorf("write export: %w", err)
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "wrote %s\n", out)
+			return nil
+		},
+	}
+	addAddrFlag(cmd, &addr)
+	cmd.Flags().StringVar(&format, "format", exportJSON, "Export format: json or csv")
+	cmd.Flags().StringVar(&table, "table", exportServers, "CSV table: servers or history")
+	cmd.Flags().StringVar(&out, "out", "", "Output file (default stdout)")
+	cmd.Flags().DurationVar(&since, "since", 24*time.Hour, "History window ending now")
+	return cmd
+}
+
+// fetchExport collects the daemon state. History is only requested when withHistory is true;
+// a daemon without history enabled yields no records rather than an error.
+func fetchExport(cmd *cobra.Command, addr string, since time.Duration, withHistory bool) (*exportSnapshot, error) {
+	snap := &exportSnapshot{ExportedAt: time.Now().UTC()}
+	if err := apiGet(cmd, addr, "/api/v1/version", &snap.Daemon); err != nil {
+		return nil, err
+	}
+	if err := apiGet(cmd, addr, "/api/v1/status", &snap.Status); err != nil {
+		return nil, err
+	}
+	var list struct {
+		Servers []servers.ServerEntry `json:"servers"`
+	}
+	if err := apiGet(cmd, addr, "/api/v1/servers", &list); err != nil {
+		return nil, err
+	}
+	snap.Servers = list.Servers
+	if !withHistory {
+		return snap, nil
+	}
+
+	q := url.Values{}
+	q.Set("from", snap.ExportedAt.Add(-since).Format(time.RFC3339))
+	q.Set("to", snap.ExportedAt.Format(time.RFC3339))
+	q.Set("limit", strconv.Itoa(history.DefaultQueryLimit))
+	var h struct {
+		History []history.Record `json:"history"`
+	}
+	err := apiGet(cmd, addr, "/api/v1/history?"+q.Encode(), &h)
+	var statusErr *apiStatusError
+	switch {
+	case errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound:
+		// History is not enabled on the daemon.
+	case err != nil:
+		return nil, err
+	default:
+		snap.History = h.History
+		if len(h.History) == history.DefaultQueryLimit {
+			fmt.Fprintf(cmd.ErrOrStderr(), "warning: history truncated to %d records; use a shorter --since\n", history.DefaultQueryLimit)
+		}
+	}
+	return snap, nil
+}
+
+func writeServersCSV(w io.Writer, list []api.ServerStatus) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"name", "port", "players", "max_players", "map", "last_attempt", "last_success", "last_error", "consecutive_failures"})
+	for _, s := range list {
+		var lastAttempt, lastSuccess, lastError, failures string
+		if s.Sync != nil {
+			lastAttempt = formatCSVTime(s.Sync.LastAttempt)
+			lastSuccess = formatCSVTime(s.Sync.LastSuccess)
+			lastError = s.Sync.LastError
+			failures = strconv.Itoa(s.Sync.ConsecutiveFailures)
+		}
+		_ = cw.Write([]string{s.Name, strconv.Itoa(s.Port), strconv.Itoa(s.Players), strconv.Itoa(s.MaxPlayers), s.Map,
+			lastAttempt, lastSuccess, lastError, failures})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeHistoryCSV(w io.Writer, records []history.Record) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"time", "server", "port", "online", "players", "max_players", "version", "map", "mods_hash", "error",
+		"samples", "failed", "peak_players"})
+	for _, r := range records {
+		_ = cw.Write([]string{formatCSVTime(r.Time), r.Server, strconv.Itoa(r.Port), strconv.FormatBool(r.Online),
+			strconv.Itoa(r.Players), strconv.Itoa(r.MaxPlayers), r.Version, r.Map, r.ModsHash, r.Error,
+			strconv.Itoa(r.Samples), strconv.Itoa(r.Failed), strconv.Itoa(r.PeakPlayers)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatCSVTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+// Environment variables read by flag-only mode. Flags take precedence. Except for envServers and envLog, they
+// also override the values of a config file (see config.ApplyEnv).
+const (
+	envServers    = "DZSA_SYNC_SERVERS"
+	envDetectIP   = config.EnvDetectIP
+	envExternalIP = config.EnvExternalIP
+	envLog        = "DZSA_SYNC_LOG"
+	envLogLevel   = config.EnvLogLevel
+	envLogFormat  = config.EnvLogFormat
+	envAPIPort    = config.EnvAPIPort
+	envInstance   = config.EnvInstanceName
+)
+
+// daemonFlags describe a simple setup without a config file: servers, IP source, log
+// destination, and API port. They are ignored when --config is set.
+type daemonFlags struct {
+	servers    []string
+	detectIP   bool
+	externalIP string
+	log        string
+	logLevel   string
+	logFormat  string
+	apiPort    int
+	instance   string
+}
+
+func addDaemonFlags(cmd *cobra.Command, f *daemonFlags) {
+	cmd.Flags().StringArrayVar(&f.servers, "server", nil, "Server to sync as name:port (repeatable); env "+envServers+" (comma-separated)")
+	cmd.Flags().BoolVar(&f.detectIP, "detect-ip", false, "Detect the external IP via ifconfig.net; env "+envDetectIP)
+	cmd.Flags().StringVar(&f.externalIP, "external-ip", "", "Static external IP; env "+envExternalIP)
+	cmd.Flags().StringVar(&f.log, "log", config.LogStdout, "Log file path, stdout, or stderr; env "+envLog)
+	cmd.Flags().StringVar(&f.logLevel, "log-level", "", "Minimum log level: debug, info, warn, or error (default debug); env "+envLogLevel)
+	cmd.Flags().StringVar(&f.logFormat, "log-format", "", "Log format: json or console (default json); env "+envLogFormat)
+	cmd.Flags().IntVar(&f.apiPort, "api-port", daemon.DefaultAPIPort, "API server port; env "+envAPIPort)
+	cmd.Flags().StringVar(&f.instance, "instance-name", "", "Instance name for logs, metrics, and API responses; env "+envInstance)
+}
+
+// daemonConfig returns the config from configPath when set, with its environment overrides, and otherwise
+// builds one from the flags and environment. When both are given, the config file wins and the ignored flags
+// are reported on warn.
+func daemonConfig(cmd *cobra.Command, configPath string, f *daemonFlags, warn io.Writer) (*config.Config, error) {
+	if configPath != "" {
+		var ignored []string
+		for _, name := range []string{"server", "detect-ip", "external-ip", "log", "log-level", "log-format", "api-port", "instance-name"} {
+			if cmd.Flags().Changed(name) {
+				ignored = append(ignored, "--"+name)
+			}
+		}
+		if len(ignored) > 0 {
+			fmt.Fprintf(warn, "warning: --config is set, ignoring %s\n", strings.Join(ignored, ", "))
+		}
+		return loadConfig(configPath)
+	}
+	cfg, err := f.config(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Servers) == 0 {
+		return nil, fmt.Errorf("missing required flag: --config (or --server for flag-only mode)")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	return cfg, nil
+}
+
+// config builds a config from flags, falling back to the environment for flags that were not set.
+func (f *daemonFlags) config(cmd *cobra.Command) (*config.Config, error) {
+	flags := cmd.Flags()
+	servers := f.servers
+	if !flags.Changed("server") {
+		if v := os.Getenv(envServers); v != "" {
+			servers = strings.Split(v, ",")
+		}
+	}
+	detectIP := f.detectIP
+	if v := os.Getenv(envDetectIP); v != "" && !flags.Changed("detect-ip") {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", envDetectIP, err)
+		}
+		detectIP = b
+	}
+	externalIP := envOr(flags.Changed("external-ip"), f.externalIP, envExternalIP)
+	logPath := envOr(flags.Changed("log"), f.log, envLog)
+	apiPort := f.apiPort
+	if v := os.Getenv(envAPIPort); v != "" && !flags.Changed("api-port") {
+		p, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", envAPIPort, err)
+		}
+		apiPort = p
+	}
+
+	cfg := &config.Config{
+		InstanceName: envOr(flags.Changed("instance-name"), f.instance, envInstance),
+		DetectIP:     detectIP,
+		ExternalIP:   externalIP,
+		LogPath:      logPath,
+		LogLevel:     envOr(flags.Changed("log-level"), f.logLevel, envLogLevel),
+		LogFormat:    envOr(flags.Changed("log-format"), f.logFormat, envLogFormat),
+		API:          &config.APIConfig{Port: apiPort},
+	}
+	for _, s := range servers {
+		srv, err := parseServerFlag(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		cfg.Servers = append(cfg.Servers, srv)
+	}
+	return cfg, nil
+}
+
+// envOr returns flagValue when the flag was set, otherwise the environment variable when it is non-empty.
+func envOr(changed bool, flagValue, env string) string {
+	if changed {
+		return flagValue
+	}
+	if v := os.Getenv(env); v != "" {
+		return v
+	}
+	return flagValue
+}
+
+// parseServerFlag parses name:port. The name is optional; a bare port is named server-<port>.
+func parseServerFlag(s string) (config.Server, error) {
+	name, portStr, ok := strings.Cut(s, ":")
+	if !ok {
+		name, portStr = "", s
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return config.Server{}, fmt.Errorf("invalid server %q: want name:port", s)
+	}
+	if name == "" {
+		name = config.LegacyServerName(port)
+	}
+	return config.Server{Name: name, Port: port}, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// healthcheckRetryInterval is the delay between probes while --wait has not elapsed.
+const healthcheckRetryInterval = time.Second
+
+func newHealthcheckCmd() *cobra.Command {
+	var (
+		addr string
+		wait time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:   "healthcheck",
+		Short: "Exit 0 when the local daemon is ready, 1 otherwise",
+		Long: "Probe the running daemon's /readyz endpoint and exit 0 when it is ready or 1 when it is not, " +
+			"for Docker HEALTHCHECK or systemd ExecStartPost without curl. --wait keeps probing until the daemon is ready or the time is up.",
+		Example: "  HEALTHCHECK CMD [\"dzsa-sync\", \"healthcheck\"]\n" +
+			"  ExecStartPost=/usr/bin/dzsa-sync healthcheck --wait 30s --addr unix:///run/dzsa-sync/api.sock",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx, cancel := context.WithTimeout(cmd.Context(), wait)
+			defer cancel()
+			for {
+				err := probeReady(cmd, addr)
+				if err == nil {
+					fmt.Fprintln(cmd.OutOrStdout(), "ready")
+					return nil
+				}
+				select {
+				case <-ctx.Done():
+					return fmt.Errorf("not ready: %w", err)
+				case <-time.After(healthcheckRetryInterval):
+				}
+			}
+		},
+	}
+	addAddrFlag(cmd, &addr)
+	cmd.Flags().DurationVar(&wait, "wait", 0, "Keep probing until ready for up to this long (0 probes once)")
+	return cmd
+}
+
+// probeReady returns nil when GET /readyz succeeds.
+func probeReady(cmd *cobra.Command, addr string) error {
+	resp, err := apiDo(cmd, http.MethodGet, addr, "/readyz")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
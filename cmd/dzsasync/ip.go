@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/ifconfig"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// IP sources reported by the ip command.
+const (
+	ipSourceConfig   = "external_ip"
+	ipSourceIfconfig = "ifconfig.net"
+)
+
+// ipResult is the ip command's output. Providers is only set in verbose mode.
+type ipResult struct {
+	IP        string       `json:"ip"`
+	Source    string       `json:"source"`
+	Providers []ipProvider `json:"providers,omitempty"`
+}
+
+// ipProvider is one IP source's answer.
+type ipProvider struct {
+	Name  string `json:"name"`
+	IP    string `json:"ip,omitempty"`
+	Error string `json:"error,omitempty"`
+	// Details is the full ifconfig.net response.
+	Details *ifconfig.Response `json:"details,omitempty"`
+}
+
+func newIPCmd(configPath *string) *cobra.Command {
+	var (
+		output  string
+		verbose bool
+		timeout time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:   "ip",
+		Short: "Detect the external IP once and print it",
+		Long: "Resolve the external IP the way the daemon does and print it. With --config, a static external_ip " +
+			"(detect_ip: false) is used as-is; otherwise the IP is detected via ifconfig.net. " +
+			"--verbose queries every source and prints each answer, which helps explain why a wrong IP is registered.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			detect, static := true, ""
+			if *configPath != "" {
+				cfg, err := loadConfig(*configPath)
+				if err != nil {
+					return err
+				}
+				detect, static = cfg.DetectIP, cfg.ExternalIP
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			defer cancel()
+			ifc := ifconfig.New(zap.NewNop(), nil, nil)
+			res, err := resolveExternalIP(ctx, detect, static, verbose, ifc.Get)
+			if err != nil {
+				return err
+			}
+			return writeOutput(cmd.OutOrStdout(), output, res, func(w io.Writer) error {
+				return printIP(w, res)
+			})
+		},
+	}
+	addOutputFlag(cmd, &output)
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Query every IP source and print each answer")
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Second, "Timeout for IP detection")
+	return cmd
+}
+
+// resolveExternalIP returns the IP the daemon would register: static when detect is false,
+// otherwise the detected IP. In verbose mode every source is consulted and reported, even the
+// ones that do not decide the result.
+func resolveExternalIP(ctx context.Context, detect bool, static string, verbose bool, detectFn func(context.Context) (*ifconfig.Response, error)) (*ipResult, error) {
+	res := &ipResult{}
+	if static != "" {
+		if verbose {
+			res.Providers = append(res.Providers, ipProvider{Name: ipSourceConfig, IP: static})
+		}
+		if !detect {
+			res.IP, res.Source = static, ipSourceConfig
+		}
+	}
+	if !detect && !verbose {
+		return res, nil
+	}
+
+	r, err := detectFn(ctx)
+	p := ipProvider{Name: ipSourceIfconfig}
+	if err != nil {
+		p.Error = err.Error()
+	} else {
+		p.IP, p.Details = r.IP, r
+	}
+	if verbose {
+		res.Providers = append(res.Providers, p)
+	}
+	if detect {
+		if err != nil {
+			return nil, fmt.Errorf("detect IP via %s: %w", ipSourceIfconfig, err)
+		}
+		res.IP, res.Source = r.IP, ipSourceIfconfig
+	}
+	return res, nil
+}
+
+func printIP(out io.Writer, res *ipResult) error {
+	fmt.Fprintf(out, "IP:     %s\nSource: %s\n", res.IP, res.Source)
+	if len(res.Providers) == 0 {
+		return nil
+	}
+	fmt.Fprintln(out)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SOURCE\tIP\tDETAILS")
+	for _, p := range res.Providers {
+		ip, details := p.IP, ""
+		switch {
+		case p.Error != "":
+			ip, details = "-", "error: "+p.Error
+		case p.Details != nil:
+			details = fmt.Sprintf("%s %s, %s", p.Details.Asn, p.Details.AsnOrg, p.Details.Country)
+		case p.Name == ipSourceConfig:
+			details = "static, from config"
+		}
+		if p.IP != "" && p.IP != res.IP && res.IP != "" {
+			details += " (differs from result)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", p.Name, ip, details)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultLogsLines = 50
+	// logTailBytes bounds how much of the log file is read to find the last lines.
+	logTailBytes = 4 << 20
+	// logFollowInterval is how often a followed log file is polled for new lines and rotation.
+	logFollowInterval = 500 * time.Millisecond
+)
+
+// Log entry keys written by the daemon's logger (see setupLogger).
+const (
+	logKeyTime    = "timestamp"
+	logKeyLevel   = "level"
+	logKeyMessage = "message"
+)
+
+// logLevels orders zap's level names for --level filtering.
+var logLevels = []string{"debug", "info", "warn", "error", "dpanic", "panic", "fatal"}
+
+// ANSI colors used for levels when color is enabled.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiGray   = "\x1b[90m"
+	ansiCyan   = "\x1b[36m"
+	ansiYellow = "\x1b[33m"
+	ansiRed    = "\x1b[31m"
+)
+
+func newLogsCmd(configPath *string) *cobra.Command {
+	var (
+		file   string
+		lines  int
+		follow bool
+		f      logFilter
+		color  string
+	)
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Print the daemon's JSON log as readable, colored lines",
+		Long: "Print the last lines of the log file (log_path from --config, or --file) as readable text, " +
+			"optionally filtered by server and minimum level. --follow keeps printing new lines and survives log rotation.",
+		Example: "  dzsa-sync logs -c /etc/dzsa-sync/config.yaml -f --server main --level warn",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if file == "" {
+				cfg, err := loadConfig(*configPath)
+				if err != nil {
+					return fmt.Errorf("%w (or set --file)", err)
+				}
+				file = cfg.LogPath
+				if cfg.LogFormat == config.LogFormatConsole {
+					return fmt.Errorf("log_format is console; only JSON logs can be read")
+				}
+			}
+			if file == config.LogStdout || file == config.LogStderr {
+				return fmt.Errorf("log_path is %s; read the logs from the service manager (journalctl, docker logs) instead", file)
+			}
+			if f.level != "" && !slices.Contains(logLevels, f.level) {
+				return fmt.Errorf("invalid level %q: must be one of %s", f.level, strings.Join(logLevels, ", "))
+			}
+			out := cmd.OutOrStdout()
+			p := &logPrinter{out: out, filter: f}
+			switch color {
+			case "always":
+				p.color = true
+			case "never":
+			case "auto":
+				p.color = isTerminal(out) && os.Getenv("NO_COLOR") == ""
+			default:
+				return fmt.Errorf("invalid color %q: must be auto, always, or never", color)
+			}
+
+			offset, err := p.tail(file, lines)
+			if err != nil {
+				return err
+			}
+			if !follow {
+				return nil
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return followLog(ctx, file, offset, p.print)
+		},
+	}
+	cmd.Flags().StringVar(&file, "file", "", "Log file to read instead of log_path from --config")
+	cmd.Flags().IntVarP(&lines, "lines", "n", defaultLogsLines, "Number of recent lines to print")
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "Keep printing new lines as they are written")
+	cmd.Flags().StringVar(&f.server, "server", "", "Only show lines for this server name or port")
+	cmd.Flags().StringVar(&f.level, "level", "", "Only show lines at or above this level (debug, info, warn, error)")
+	cmd.Flags().StringVar(&color, "color", "auto", "Colorize output: auto, always, or never")
+	return cmd
+}
+
+// logFilter selects log lines. Empty fields match everything.
+type logFilter struct {
+	server string
+	level  string
+}
+
+// match reports whether the decoded entry passes the filter.
+func (f logFilter) match(entry map[string]any) bool {
+	if f.level != "" {
+		level, _ := entry[logKeyLevel].(string)
+		if slices.Index(logLevels, level) < slices.Index(logLevels, f.level) {
+			return false
+		}
+	}
+	if f.server != "" {
+		server, _ := entry["server"].(string)
+		port, _ := entry["port"].(float64)
+		if server != f.server && strconv.Itoa(int(port)) != f.server {
+			return false
+		}
+	}
+	return true
+}
+
+// logPrinter renders JSON log lines as text.
+type logPrinter struct {
+	out    io.Writer
+	filter logFilter
+	color  bool
+}
+
+// tail prints the last n lines of path and returns the file size, where following continues.
+func (p *logPrinter) tail(path string, n int) (int64, error) {
+	data, size, err := tailLines(path, n)
+	if err != nil {
+		return 0, err
+	}
+	for line := range bytes.Lines(data) {
+		p.print(line)
+	}
+	return size, nil
+}
+
+// print renders one log line. Lines that are not JSON objects are printed as-is and are only
+// filtered out when a filter is set.
+func (p *logPrinter) print(line []byte) {
+	line = bytes.TrimRight(line, "\r\n")
+	if len(line) == 0 {
+		return
+	}
+	var entry map[string]any
+	if err := json.Unmarshal(line, &entry); err != nil {
+		if p.filter == (logFilter{}) {
+			fmt.Fprintf(p.out, "%s\n", line)
+		}
+		return
+	}
+	if !p.filter.match(entry) {
+		return
+	}
+	fmt.Fprintln(p.out, p.format(entry))
+}
+
+// format renders an entry as "<time> <LEVEL> <message> key=value ...", with server and port first
+// and the remaining fields sorted by key.
+func (p *logPrinter) format(entry map[string]any) string {
+	ts, _ := entry[logKeyTime].(string)
+	level, _ := entry[logKeyLevel].(string)
+	msg, _ := entry[logKeyMessage].(string)
+
+	var b strings.Builder
+	b.WriteString(p.paint(ansiGray, ts))
+	b.WriteByte(' ')
+	b.WriteString(p.paint(levelColor(level), fmt.Sprintf("%-5s", strings.ToUpper(level))))
+	b.WriteByte(' ')
+	b.WriteString(msg)
+
+	keys := make([]string, 0, len(entry))
+	for k := range entry {
+		switch k {
+		case logKeyTime, logKeyLevel, logKeyMessage, "server", "port":
+		default:
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	for _, k := range append([]string{"server", "port"}, keys...) {
+		v, ok := entry[k]
+		if !ok {
+			continue
+		}
+		b.WriteByte(' ')
+		b.WriteString(p.paint(ansiGray, k+"="))
+		b.WriteString(formatLogValue(v))
+	}
+	return b.String()
+}
+
+func (p *logPrinter) paint(color, s string) string {
+	if !p.color || color == "" {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+func levelColor(level string) string {
+	switch level {
+	case "debug":
+		return ansiGray
+	case "info":
+		return ansiCyan
+	case "warn":
+		return ansiYellow
+	case "":
+		return ""
+	default:
+		return ansiRed
+	}
+}
+
+// formatLogValue renders a field value, quoting strings that contain spaces or are empty.
+func formatLogValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		if v == "" || strings.ContainsAny(v, " \t\"=") {
+			return strconv.Quote(v)
+		}
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+}
+
+// isTerminal reports whether w is a character device such as a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// tailLines returns the last n lines of the file at path, reading at most logTailBytes, and the
+// file's size.
+func tailLines(path string, n int) ([]byte, int64, error) {
+	f, err := os.Open(path) // #nosec G304 -- path is from the config or a flag
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	size := info.Size()
+	offset := max(size-logTailBytes, 0)
+	data, err := io.ReadAll(io.NewSectionReader(f, offset, size-offset))
+	if err != nil {
+		return nil, 0, err
+	}
+	if offset > 0 {
+		// Drop the partial first line.
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return bytes.Join(lines, nil), size, nil
+}
+
+// followLog calls fn with each complete line appended to path after offset until ctx is done.
+// When the file is rotated (replaced or truncated), it continues from the start of the new file.
+func followLog(ctx context.Context, path string, offset int64, fn func([]byte)) error {
+	f, err := os.Open(path) // #nosec G304 -- path is from the config or a flag
+	if err != nil {
+		return err
+	}
+	defer func() { f.Close() }()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(f)
+	var partial []byte
+
+	// drain passes every complete line up to EOF to fn.
+	drain := func() error {
+		for {
+			line, err := r.ReadBytes('\n')
+			partial = append(partial, line...)
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return err
+			}
+			fn(partial)
+			offset += int64(len(partial))
+			partial = partial[:0]
+		}
+	}
+
+	ticker := time.NewTicker(logFollowInterval)
+	defer ticker.Stop()
+	for {
+		if err := drain(); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		current, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		latest, err := os.Stat(path)
+		if err != nil {
+			// The file is briefly missing while it is rotated; try again on the next tick.
+			continue
+		}
+		if os.SameFile(current, latest) && latest.Size() >= offset+int64(len(partial)) {
+			continue
+		}
+		// Rotated or truncated: finish the old file, including a last partial line, and reopen.
+		if err := drain(); err != nil {
+			return err
+		}
+		if len(partial) > 0 {
+			fn(partial)
+			partial = partial[:0]
+		}
+		next, err := os.Open(path) // #nosec G304 -- path is from the config or a flag
+		if err != nil {
+			continue
+		}
+		f.Close()
+		f, offset = next, 0
+		r.Reset(f)
+	}
+}
@@ -0,0 +1,951 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/a2s"
+	"github.com/jsirianni/dzsa-sync/internal/api"
+	"github.com/jsirianni/dzsa-sync/internal/daemon"
+	"github.com/jsirianni/dzsa-sync/internal/ifconfig"
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+	"github.com/jsirianni/dzsa-sync/model"
+	"github.com/spf13/cobra"
+)
+
+func TestNormalizeArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"-config", "/etc/dzsa-sync/config.yaml"}, []string{"--config", "/etc/dzsa-sync/config.yaml"}},
+		{[]string{"-config=/etc/dzsa-sync/config.yaml"}, []string{"--config=/etc/dzsa-sync/config.yaml"}},
+		{[]string{"run", "--config", "x.yaml"}, []string{"run", "--config", "x.yaml"}},
+		{[]string{"validate", "-c", "x.yaml"}, []string{"validate", "-c", "x.yaml"}},
+	}
+	for _, tt := range tests {
+		if got := normalizeArgs(tt.args); !slices.Equal(got, tt.want) {
+			t.Errorf("normalizeArgs(%v) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestPrintResult(t *testing.T) {
+	var buf bytes.Buffer
+	r := &model.Result{
+		Name:       "main",
+		Endpoint:   model.Endpoint{IP: "203.0.113.10", Port: 2424},
+		Players:    12,
+		MaxPlayers: 60,
+		Mods:       []model.Mods{{Name: "CF", SteamWorkshopID: 1559212036}},
+	}
+	if err := printResult(&buf, r); err != nil {
+		t.Fatalf("printResult() error = %v", err)
+	}
+	for _, want := range []string{"203.0.113.10:2424", "12/60", "1559212036", "CF"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("printResult() output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestResolveIP(t *testing.T) {
+	tests := []struct {
+		host    string
+		ipv6    bool
+		want    string
+		wantErr bool
+	}{
+		{host: "203.0.113.10", want: "203.0.113.10"},
+		{host: "2001:db8::10", want: "2001:db8::10"},
+		{host: "2001:db8::10", ipv6: true, want: "2001:db8::10"},
+		{host: "203.0.113.10", ipv6: true, wantErr: true},
+	}
+	for _, tc := range tests {
+		got, err := resolveIP(context.Background(), tc.host, tc.ipv6)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("resolveIP(%q, %v) = %q, %v", tc.host, tc.ipv6, got, err)
+		}
+	}
+}
+
+func TestPrintStatus(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	status := &api.StatusResponse{
+		ExternalIP:    "203.0.113.10",
+		SyncTarget:    "dry_run",
+		StartedAt:     now.Add(-2 * time.Hour),
+		UptimeSeconds: 7200,
+		Servers: []api.ServerStatus{
+			{Name: "main", Port: 2424, Players: 12, MaxPlayers: 60, Sync: &servers.SyncState{LastAttempt: now, LastSuccess: now.Add(-5 * time.Minute)}, NextSyncAt: now.Add(10 * time.Minute)},
+			{Name: "modded", Port: 2324, Sync: &servers.SyncState{LastAttempt: now, LastError: "unexpected status code: 404", ConsecutiveFailures: 3}},
+			{Name: "new", Port: 2524},
+			{Name: "community", Port: 2624, MonitorOnly: true},
+		},
+	}
+	var buf bytes.Buffer
+	if err := printStatus(&buf, status, now); err != nil {
+		t.Fatalf("printStatus() error = %v", err)
+	}
+	for _, want := range []string{"203.0.113.10", "Uptime:      2h0m0s", "12/60", "5m0s ago", "in 10m0s", "community (monitor)", "dry run, syncs are not sent to DZSA", "error (3): unexpected status code: 404", "never", "pending"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("printStatus() output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestReportSync(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	before, after := start.Add(-time.Minute), start.Add(5*time.Second)
+	status := func(mainAttempt time.Time, moddedErr string) *api.StatusResponse {
+		return &api.StatusResponse{Servers: []api.ServerStatus{
+			{Name: "main", Port: 2424, Sync: &servers.SyncState{LastAttempt: mainAttempt}},
+			{Name: "modded", Port: 2324, Sync: &servers.SyncState{LastAttempt: after, LastError: moddedErr}},
+			{Name: "wipe", Port: 2524, Maintenance: &servers.Maintenance{Until: after}},
+		}}
+	}
+
+	var buf bytes.Buffer
+	if done, _ := reportSync(&buf, status(before, ""), 0, start); done {
+		t.Error("reportSync() done before every server attempted a sync")
+	}
+	if done, err := reportSync(&buf, status(before, ""), 2324, start); !done || err != nil {
+		t.Errorf("reportSync(2324) = %v, %v, want done without error", done, err)
+	}
+	buf.Reset()
+	done, err := reportSync(&buf, status(after, "status 404"), 0, start)
+	if !done || err == nil {
+		t.Errorf("reportSync() = %v, %v, want done with error", done, err)
+	}
+	for _, want := range []string{"main (2424): synced", "modded (2324): failed: status 404", "wipe (2524): skipped"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("reportSync() output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestWriteOutput(t *testing.T) {
+	v := api.StatusResponse{ExternalIP: "203.0.113.10", Servers: []api.ServerStatus{{Name: "main", Port: 2424}}}
+	table := func(w io.Writer) error {
+		_, err := io.WriteString(w, "table")
+		return err
+	}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{outputTable, "table"},
+		{outputJSON, `"external_ip": "203.0.113.10"`},
+		{outputYAML, "external_ip: 203.0.113.10"},
+		{outputYAML, "max_players: 0"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := writeOutput(&buf, tt.format, v, table); err != nil {
+			t.Fatalf("writeOutput(%s) error = %v", tt.format, err)
+		}
+		if !strings.Contains(buf.String(), tt.want) {
+			t.Errorf("writeOutput(%s) = %q, want it to contain %q", tt.format, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestMigrateConfigCmd(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	legacy := "detect_ip: true\nlog_path: /tmp/dzsa-sync.log\nports: [2424]\n"
+	if err := os.WriteFile(path, []byte(legacy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	root := newRootCmd()
+	root.SetOut(&out)
+	root.SetArgs([]string{"migrate-config", "--config", path})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("migrate-config: %v", err)
+	}
+	if !strings.Contains(out.String(), "server-2424") {
+		t.Errorf("output does not report the change:\n%s", out.String())
+	}
+	if b, err := os.ReadFile(path + ".bak"); err != nil || string(b) != legacy {
+		t.Errorf("backup = %q, %v; want original config", b, err)
+	}
+	cfg, err := config.NewFromFile(path)
+	if err != nil {
+		t.Fatalf("migrated config: %v", err)
+	}
+	if len(cfg.Servers) != 1 || cfg.Servers[0].Port != 2424 {
+		t.Errorf("servers = %+v", cfg.Servers)
+	}
+}
+
+func TestValidateCmd(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	run := func(path string) (string, string, error) {
+		var out, errOut bytes.Buffer
+		root := newRootCmd()
+		root.SetOut(&out)
+		root.SetErr(&errOut)
+		root.SetArgs([]string{"validate", "--config", path})
+		err := root.Execute()
+		return out.String(), errOut.String(), err
+	}
+
+	valid := write("valid.yaml", "detect_ip: true\nlog_path: /tmp/dzsa-sync.log\nservers:\n  - name: main\n    port: 2424\n")
+	if out, _, err := run(valid); err != nil || !strings.Contains(out, "valid (1 servers)") {
+		t.Errorf("validate valid config: out = %q, err = %v", out, err)
+	}
+
+	// Every problem is listed, not only the first.
+	invalid := write("invalid.yaml", "detect_ip: true\nlog_level: loud\nservers:\n  - name: main\n    port: 2424\nretry:\n  attempts: -1\n")
+	_, errOut, err := run(invalid)
+	if err == nil {
+		t.Fatal("validate invalid config: error = nil")
+	}
+	for _, want := range []string{"3 problems", "log_path is required", "log_level must be one of", "retry.attempts"} {
+		if !strings.Contains(errOut, want) {
+			t.Errorf("output is missing %q:\n%s", want, errOut)
+		}
+	}
+}
+
+func TestSetup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dzsa-sync", "config.yaml")
+	input := strings.Join([]string{
+		"",       // config file: default (the --config path)
+		"main",   // server 1 name
+		"70000",  // invalid port, asked again
+		"2424",   // server 1 port
+		"modded", // server 2 name
+		"2424",   // duplicate port, asked again
+		"2324",   // server 2 port
+		"",       // done with servers
+		"maybe",  // invalid answer, asked again
+		"n",      // detect IP
+		"203.0.113.10",
+		"/tmp/dzsa-sync.log",
+		"127.0.0.1", // API host
+		"",          // API port: default
+	}, "\n") + "\n"
+
+	var queried []int
+	var out bytes.Buffer
+	s := &setup{
+		in:  bufio.NewReader(strings.NewReader(input)),
+		out: &out,
+		checker: &connectivity{
+			detectIP: func(context.Context) (string, error) { return "", errors.New("detectIP called with detect_ip false") },
+			query: func(_ context.Context, ip string, port int) error {
+				if ip != "203.0.113.10" {
+					t.Errorf("query ip = %s", ip)
+				}
+				queried = append(queried, port)
+				if port == 2324 {
+					return errors.New("server not found")
+				}
+				return nil
+			},
+		},
+	}
+	if err := s.run(context.Background(), path); err != nil {
+		t.Fatalf("setup: %v\n%s", err, out.String())
+	}
+
+	cfg, err := config.NewFromFile(path)
+	if err != nil {
+		t.Fatalf("written config: %v", err)
+	}
+	wantServers := []config.Server{{Name: "main", Port: 2424}, {Name: "modded", Port: 2324}}
+	if !slices.Equal(cfg.Servers, wantServers) {
+		t.Errorf("servers = %+v, want %+v", cfg.Servers, wantServers)
+	}
+	if cfg.DetectIP || cfg.ExternalIP != "203.0.113.10" || cfg.LogPath != "/tmp/dzsa-sync.log" {
+		t.Errorf("config = %+v", cfg)
+	}
+	if cfg.API == nil || cfg.API.Host != "127.0.0.1" || cfg.API.Port != 8888 {
+		t.Errorf("api = %+v", cfg.API)
+	}
+	if !slices.Equal(queried, []int{2424, 2324}) {
+		t.Errorf("queried ports = %v", queried)
+	}
+	if !strings.Contains(out.String(), "DZSA modded (2324): FAILED") {
+		t.Errorf("output does not report the failed check:\n%s", out.String())
+	}
+}
+
+func TestSetupAborted(t *testing.T) {
+	s := &setup{
+		in:  bufio.NewReader(strings.NewReader("\nmain\n")),
+		out: io.Discard,
+	}
+	if err := s.run(context.Background(), filepath.Join(t.TempDir(), "config.yaml")); err == nil {
+		t.Fatal("setup succeeded on truncated input")
+	}
+}
+
+func TestDiag(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "dzsa-sync.log")
+	var log strings.Builder
+	for i := range 5 {
+		fmt.Fprintf(&log, "{\"msg\":\"line %d\"}\n", i)
+	}
+	if err := os.WriteFile(logPath, []byte(log.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, "config.yaml")
+	cfg := "detect_ip: true\nlog_path: " + logPath + "\nservers:\n  - name: main\n    port: 2424\nhooks:\n  - name: restart\n    token: s3cret\n    action: sync\n"
+	if err := os.WriteFile(configPath, []byte(cfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/status":
+			fmt.Fprint(w, `{"version":"dev","servers":[]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	bundlePath := filepath.Join(dir, "diag.tar.gz")
+	root := newRootCmd()
+	root.SetOut(io.Discard)
+	root.SetArgs([]string{"diag", "--config", configPath, "--addr", srv.URL, "--out", bundlePath, "--log-lines", "2", "--skip-checks"})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("diag: %v", err)
+	}
+
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[strings.TrimPrefix(hdr.Name, "dzsa-sync-diag/")] = string(b)
+	}
+
+	for _, name := range []string{"version.txt", "config.yaml", "config-validation.txt", "log.txt", "status.json", "connectivity.txt", "errors.txt"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("bundle is missing %s", name)
+		}
+	}
+	if strings.Contains(files["config.yaml"], "s3cret") {
+		t.Errorf("config.yaml is not redacted:\n%s", files["config.yaml"])
+	}
+	if want := "{\"msg\":\"line 3\"}\n{\"msg\":\"line 4\"}\n"; files["log.txt"] != want {
+		t.Errorf("log.txt = %q, want %q", files["log.txt"], want)
+	}
+	if !strings.Contains(files["errors.txt"], "metrics.txt") {
+		t.Errorf("errors.txt does not report the failed metrics fetch:\n%s", files["errors.txt"])
+	}
+}
+
+func TestResolveExternalIP(t *testing.T) {
+	detected := func(context.Context) (*ifconfig.Response, error) {
+		return &ifconfig.Response{IP: "198.51.100.7", Asn: "AS64500", AsnOrg: "Example", Country: "Nowhere"}, nil
+	}
+	failed := func(context.Context) (*ifconfig.Response, error) { return nil, errors.New("timeout") }
+
+	tests := []struct {
+		name          string
+		detect        bool
+		static        string
+		verbose       bool
+		detectFn      func(context.Context) (*ifconfig.Response, error)
+		wantIP        string
+		wantSource    string
+		wantProviders int
+		wantErr       bool
+	}{
+		{name: "detected", detect: true, detectFn: detected, wantIP: "198.51.100.7", wantSource: ipSourceIfconfig},
+		{name: "static skips detection", static: "203.0.113.10", detectFn: failed, wantIP: "203.0.113.10", wantSource: ipSourceConfig},
+		{name: "static verbose reports detection error", static: "203.0.113.10", verbose: true, detectFn: failed, wantIP: "203.0.113.10", wantSource: ipSourceConfig, wantProviders: 2},
+		{name: "detected verbose", detect: true, verbose: true, detectFn: detected, wantIP: "198.51.100.7", wantSource: ipSourceIfconfig, wantProviders: 1},
+		{name: "detection fails", detect: true, detectFn: failed, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := resolveExternalIP(context.Background(), tt.detect, tt.static, tt.verbose, tt.detectFn)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveExternalIP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if res.IP != tt.wantIP || res.Source != tt.wantSource || len(res.Providers) != tt.wantProviders {
+				t.Errorf("resolveExternalIP() = %+v", res)
+			}
+		})
+	}
+
+	res, _ := resolveExternalIP(context.Background(), false, "203.0.113.10", true, detected)
+	var out bytes.Buffer
+	if err := printIP(&out, res); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "198.51.100.7  AS64500 Example, Nowhere (differs from result)") {
+		t.Errorf("printIP() output:\n%s", out.String())
+	}
+}
+
+func TestLogPrinter(t *testing.T) {
+	lines := strings.Join([]string{
+		`{"level":"info","timestamp":"2026-01-02T03:04:05.000Z","message":"synced","server":"main","port":2424,"players":12}`,
+		`{"level":"error","timestamp":"2026-01-02T03:04:06.000Z","message":"query failed","server":"modded","port":2324,"error":"context deadline exceeded"}`,
+		`{"level":"debug","timestamp":"2026-01-02T03:04:07.000Z","message":"tick","port":2424}`,
+		`not json`,
+	}, "\n") + "\n"
+
+	tests := []struct {
+		name   string
+		filter logFilter
+		want   string
+	}{
+		{
+			name: "all",
+			want: "2026-01-02T03:04:05.000Z INFO  synced server=main port=2424 players=12\n" +
+				"2026-01-02T03:04:06.000Z ERROR query failed server=modded port=2324 error=\"context deadline exceeded\"\n" +
+				"2026-01-02T03:04:07.000Z DEBUG tick port=2424\n" +
+				"not json\n",
+		},
+		{
+			name:   "by port",
+			filter: logFilter{server: "2424"},
+			want: "2026-01-02T03:04:05.000Z INFO  synced server=main port=2424 players=12\n" +
+				"2026-01-02T03:04:07.000Z DEBUG tick port=2424\n",
+		},
+		{
+			name:   "by name and level",
+			filter: logFilter{server: "modded", level: "warn"},
+			want:   "2026-01-02T03:04:06.000Z ERROR query failed server=modded port=2324 error=\"context deadline exceeded\"\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			p := &logPrinter{out: &out, filter: tt.filter}
+			for line := range strings.Lines(lines) {
+				p.print([]byte(line))
+			}
+			if out.String() != tt.want {
+				t.Errorf("output:\n%s\nwant:\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestFollowLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dzsa-sync.log")
+	if err := os.WriteFile(path, []byte("old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	got := make(chan string, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- followLog(ctx, path, 4, func(line []byte) { got <- string(line) })
+	}()
+	next := func() string {
+		select {
+		case line := <-got:
+			return line
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a line")
+			return ""
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(f, "first\n")
+	f.Close()
+	if line := next(); line != "first\n" {
+		t.Errorf("line = %q, want first", line)
+	}
+
+	// Rotate: move the file away and start a new one.
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("rotated\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if line := next(); line != "rotated\n" {
+		t.Errorf("line = %q, want rotated", line)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("followLog() error = %v", err)
+	}
+}
+
+func TestDaemonConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		want    *config.Config
+		wantErr bool
+	}{
+		{
+			name: "flags",
+			args: []string{"--server", "main:2424", "--server", "2324", "--detect-ip"},
+			want: &config.Config{
+				DetectIP: true,
+				LogPath:  config.LogStdout,
+				Servers:  []config.Server{{Name: "main", Port: 2424}, {Name: "server-2324", Port: 2324}},
+				API:      &config.APIConfig{Port: 8888},
+			},
+		},
+		{
+			name: "environment",
+			env: map[string]string{
+				envServers:    "main:2424, modded:2324",
+				envExternalIP: "203.0.113.10",
+				envLog:        "/tmp/dzsa-sync.log",
+				envLogLevel:   "info",
+				envLogFormat:  "console",
+				envAPIPort:    "9000",
+				envInstance:   "eu-1",
+			},
+			want: &config.Config{
+				InstanceName: "eu-1",
+				ExternalIP:   "203.0.113.10",
+				LogPath:      "/tmp/dzsa-sync.log",
+				LogLevel:     "info",
+				LogFormat:    "console",
+				Servers:      []config.Server{{Name: "main", Port: 2424}, {Name: "modded", Port: 2324}},
+				API:          &config.APIConfig{Port: 9000},
+			},
+		},
+		{
+			name: "flags override environment",
+			args: []string{"--server", "main:2424", "--detect-ip=false", "--external-ip", "198.51.100.7"},
+			env:  map[string]string{envServers: "other:2302", envDetectIP: "true"},
+			want: &config.Config{
+				ExternalIP: "198.51.100.7",
+				LogPath:    config.LogStdout,
+				Servers:    []config.Server{{Name: "main", Port: 2424}},
+				API:        &config.APIConfig{Port: 8888},
+			},
+		},
+		{name: "no servers", args: []string{"--detect-ip"}, wantErr: true},
+		{name: "invalid server", args: []string{"--server", "main:abc", "--detect-ip"}, wantErr: true},
+		{name: "no IP source", args: []string{"--server", "main:2424"}, wantErr: true},
+		{name: "invalid log level", args: []string{"--server", "main:2424", "--detect-ip", "--log-level", "trace"}, wantErr: true},
+		{name: "invalid env bool", args: []string{"--server", "main:2424"}, env: map[string]string{envDetectIP: "maybe"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{envServers, envDetectIP, envExternalIP, envLog, envLogLevel, envLogFormat, envAPIPort, envInstance} {
+				t.Setenv(k, tt.env[k])
+			}
+			var flags daemonFlags
+			cmd := &cobra.Command{}
+			addDaemonFlags(cmd, &flags)
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			got, err := daemonConfig(cmd, "", &flags, io.Discard)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("daemonConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.InstanceName != tt.want.InstanceName || got.DetectIP != tt.want.DetectIP || got.ExternalIP != tt.want.ExternalIP || got.LogPath != tt.want.LogPath ||
+				got.LogLevel != tt.want.LogLevel || got.LogFormat != tt.want.LogFormat ||
+				!slices.Equal(got.Servers, tt.want.Servers) || !reflect.DeepEqual(got.API, tt.want.API) {
+				t.Errorf("daemonConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDaemonConfigFileWins(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("detect_ip: true\nlog_path: stdout\nservers:\n  - name: main\n    port: 2424\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var flags daemonFlags
+	cmd := &cobra.Command{}
+	addDaemonFlags(cmd, &flags)
+	if err := cmd.ParseFlags([]string{"--server", "other:2302"}); err != nil {
+		t.Fatal(err)
+	}
+	var warn bytes.Buffer
+	cfg, err := daemonConfig(cmd, path, &flags, &warn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Servers) != 1 || cfg.Servers[0].Name != "main" {
+		t.Errorf("servers = %+v, want the config file's", cfg.Servers)
+	}
+	if !strings.Contains(warn.String(), "ignoring --server") {
+		t.Errorf("warning = %q", warn.String())
+	}
+}
+
+func TestHealthcheck(t *testing.T) {
+	var ready atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/readyz" || !ready.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"ready":true}`)
+	}))
+	defer srv.Close()
+
+	run := func() error {
+		root := newRootCmd()
+		root.SetOut(io.Discard)
+		root.SetErr(io.Discard)
+		root.SetArgs([]string{"healthcheck", "--addr", srv.URL})
+		return root.Execute()
+	}
+	if err := run(); err == nil {
+		t.Error("healthcheck succeeded while not ready")
+	}
+	ready.Store(true)
+	if err := run(); err != nil {
+		t.Errorf("healthcheck: %v", err)
+	}
+}
+
+func TestMods(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/status":
+			fmt.Fprint(w, `{"external_ip":"203.0.113.10","servers":[{"name":"main","port":2424},{"name":"modded","port":2324}]}`)
+		case "/api/v1/servers/2324":
+			fmt.Fprint(w, `{"name":"Modded","mods":[{"name":"CF","steamWorkshopId":1559212036},{"name":"Code Lock","steamWorkshopId":1646187754}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		root := newRootCmd()
+		root.SetOut(&out)
+		root.SetErr(io.Discard)
+		root.SetArgs(append([]string{"mods", "--addr", srv.URL}, args...))
+		err := root.Execute()
+		return out.String(), err
+	}
+
+	for _, target := range []string{"modded", "2324"} {
+		out, err := run(target)
+		if err != nil {
+			t.Fatalf("mods %s: %v", target, err)
+		}
+		want := "modded (port 2324): 2 mods\n\nWORKSHOP ID  NAME\n1559212036   CF\n1646187754   Code Lock\n"
+		if out != want {
+			t.Errorf("mods %s output:\n%s\nwant:\n%s", target, out, want)
+		}
+	}
+
+	if _, err := run("main"); err == nil || !strings.Contains(err.Error(), "has not synced yet") {
+		t.Errorf("mods main error = %v, want not synced", err)
+	}
+	if _, err := run("unknown"); err == nil {
+		t.Error("mods unknown succeeded")
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{0: "-", 512: "512 B", 1536: "1.5 KiB", 3 << 30: "3.0 GiB"}
+	for n, want := range tests {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestPrintWatch(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local)
+	status := &api.StatusResponse{
+		ExternalIP: "203.0.113.10",
+		Servers: []api.ServerStatus{
+			{Name: "main", Port: 2424, Players: 12, MaxPlayers: 60, Map: "chernarusplus", Sync: &servers.SyncState{LastSuccess: now.Add(-2 * time.Minute)}},
+			{Name: "modded", Port: 2324},
+		},
+	}
+	var out bytes.Buffer
+	if err := printWatch(&out, "http://localhost:8888", 5*time.Second, status, errors.New("connection refused"), now); err != nil {
+		t.Fatal(err)
+	}
+	want := "Every 5s: http://localhost:8888    2026-01-02 03:04:05\n" +
+		"External IP: 203.0.113.10    Players: 12/60\n\n" +
+		"NAME    PORT  PLAYERS  MAP            LAST SYNC  STATUS\n" +
+		"main    2424  12/60    chernarusplus  2m0s ago   ok\n" +
+		"modded  2324  0/0      -              never      pending\n" +
+		"\nrefresh failed: connection refused\n"
+	if out.String() != want {
+		t.Errorf("printWatch() output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestExport(t *testing.T) {
+	var historyEnabled atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/version":
+			fmt.Fprint(w, `{"version":"v1.2.3"}`)
+		case "/api/v1/status":
+			fmt.Fprint(w, `{"version":"v1.2.3","external_ip":"203.0.113.10","servers":[`+
+				`{"name":"main","port":2424,"players":12,"max_players":60,"map":"chernarusplus","sync":{"last_success":"2026-01-02T03:04:05Z","consecutive_failures":0}},`+
+				`{"name":"modded","port":2324}]}`)
+		case "/api/v1/servers":
+			fmt.Fprint(w, `{"servers":[{"port":2424,"result":{"name":"Main"}}]}`)
+		case "/api/v1/history":
+			if !historyEnabled.Load() {
+				http.NotFound(w, r)
+				return
+			}
+			if r.URL.Query().Get("from") == "" {
+				http.Error(w, "missing from", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"history":[{"time":"2026-01-02T03:04:05Z","server":"main","port":2424,"online":true,"players":12,"max_players":60,"map":"chernarusplus"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		root := newRootCmd()
+		root.SetOut(&out)
+		root.SetErr(io.Discard)
+		root.SetArgs(append([]string{"export", "--addr", srv.URL}, args...))
+		err := root.Execute()
+		return out.String(), err
+	}
+
+	out, err := run()
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	for _, want := range []string{`"external_ip": "203.0.113.10"`, `"version": "v1.2.3"`, `"name": "Main"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("export json missing %s:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `"history"`) {
+		t.Errorf("export json includes history while disabled:\n%s", out)
+	}
+
+	out, err = run("--format", "csv")
+	if err != nil {
+		t.Fatalf("export csv: %v", err)
+	}
+	want := "name,port,players,max_players,map,last_attempt,last_success,last_error,consecutive_failures\n" +
+		"main,2424,12,60,chernarusplus,,2026-01-02T03:04:05Z,,0\n" +
+		"modded,2324,0,0,,,,,\n"
+	if out != want {
+		t.Errorf("export csv servers:\n%s\nwant:\n%s", out, want)
+	}
+
+	historyEnabled.Store(true)
+	out, err = run("--format", "csv", "--table", "history")
+	if err != nil {
+		t.Fatalf("export csv history: %v", err)
+	}
+	want = "time,server,port,online,players,max_players,version,map,mods_hash,error,samples,failed,peak_players\n" +
+		"2026-01-02T03:04:05Z,main,2424,true,12,60,,chernarusplus,,,0,0,0\n"
+	if out != want {
+		t.Errorf("export csv history:\n%s\nwant:\n%s", out, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	if _, err := run("--out", path); err != nil {
+		t.Fatalf("export --out: %v", err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(b), `"history"`) {
+		t.Errorf("export file = %s, %v; want history included", b, err)
+	}
+
+	if _, err := run("--format", "xml"); err == nil {
+		t.Error("export --format xml succeeded")
+	}
+}
+
+func TestProberRun(t *testing.T) {
+	// up lists the addresses that answer A2S; DZSA sees ports in listed.
+	up := map[string]bool{"127.0.0.1:2424": true, "203.0.113.10:2424": true, "127.0.0.1:2324": true, "127.0.0.1:2524": true}
+	listed := map[int]bool{2424: true, 2524: true}
+	p := &prober{
+		info: func(_ context.Context, addr string) (*a2s.Info, error) {
+			if addr == "127.0.0.1:27016" {
+				// The query port of the server whose game port is configured as "game".
+				return &a2s.Info{Name: "DayZ", MaxPlayers: 60, GamePort: 2302}, nil
+			}
+			if !up[addr] {
+				return nil, errors.New("i/o timeout")
+			}
+			return &a2s.Info{Name: "DayZ", Players: 3, MaxPlayers: 60}, nil
+		},
+		query: func(_ context.Context, _ string, port int) (string, error) {
+			if !listed[port] {
+				return "", errors.New("server not found")
+			}
+			return "DayZ", nil
+		},
+	}
+	servers := []config.Server{{Name: "main", Port: 2424}, {Name: "modded", Port: 2324}, {Name: "test", Port: 2524}, {Name: "off", Port: 2624}, {Name: "game", Port: 2302}}
+	report := p.run(context.Background(), "127.0.0.1", "203.0.113.10", servers)
+
+	var out bytes.Buffer
+	if err := printCheck(&out, report); err != nil {
+		t.Fatal(err)
+	}
+	want := "External IP: 203.0.113.10\n\n" +
+		"NAME    PORT  LOCAL   EXTERNAL  DZSA\n" +
+		"main    2424  ok      ok        ok\n" +
+		"modded  2324  ok      FAILED    FAILED\n" +
+		"test    2524  ok      FAILED    ok\n" +
+		"off     2624  FAILED  FAILED    FAILED\n" +
+		"game    2302  FAILED  FAILED    FAILED\n" +
+		"\nmodded (2324): the query port is not reachable through 203.0.113.10:2324: forward UDP 2324 to this host and allow it through the firewall\n" +
+		"  external 203.0.113.10:2324: i/o timeout\n" +
+		"  dzsa 203.0.113.10:2324: server not found\n" +
+		"\ntest (2524): DZSA reaches the server; the external probe likely failed because the router does not support NAT hairpinning\n" +
+		"  external 203.0.113.10:2524: i/o timeout\n" +
+		"\noff (2624): the server does not answer Steam queries on 127.0.0.1:2624: check it is running and that 2624 is its steamQueryPort\n" +
+		"  local 127.0.0.1:2624: i/o timeout\n" +
+		"  external 203.0.113.10:2624: i/o timeout\n" +
+		"  dzsa 203.0.113.10:2624: server not found\n" +
+		"\ngame (2302): 2302 is the server's game port, and its query port 27016 answers: replace port with game_port: 2302 and query_port: auto\n" +
+		"  local 127.0.0.1:2302: i/o timeout\n" +
+		"  external 203.0.113.10:2302: i/o timeout\n" +
+		"  dzsa 203.0.113.10:2302: server not found\n"
+	if out.String() != want {
+		t.Errorf("printCheck() output:\n%s\nwant:\n%s", out.String(), want)
+	}
+	for i, broken := range []bool{false, true, false, true, true} {
+		if report.Servers[i].Broken != broken {
+			t.Errorf("%s broken = %v, want %v", report.Servers[i].Name, report.Servers[i].Broken, broken)
+		}
+	}
+}
+
+func TestSelectServers(t *testing.T) {
+	servers := []config.Server{{Name: "main", Port: 2424}, {Name: "modded", Port: 2324}}
+	got, err := selectServers(servers, []string{"2324", "main"})
+	if err != nil || len(got) != 2 || got[0].Name != "modded" || got[1].Name != "main" {
+		t.Errorf("selectServers() = %v, %v", got, err)
+	}
+	if _, err := selectServers(servers, []string{"other"}); err == nil {
+		t.Error("selectServers() matched an unknown server")
+	}
+}
+
+func TestSDNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := sdNotify("READY=1"); err != nil {
+		t.Errorf("sdNotify() without systemd = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", path)
+	if err := sdNotify("MAINPID=42\nREADY=1"); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "MAINPID=42\nREADY=1" {
+		t.Errorf("systemd received %q, %v", buf[:n], err)
+	}
+}
+
+func TestInheritFromParent(t *testing.T) {
+	t.Setenv(envRestartFDs, "")
+	in, err := inheritFromParent()
+	if err != nil || in != nil {
+		t.Errorf("inheritFromParent() = %v, %v, want nil for a normal start", in, err)
+	}
+	if _, ok := in.listener(daemon.ListenerAPI); ok {
+		t.Error("nil inherited returned a listener")
+	}
+}
+
+func TestReadMockResponses(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "responses.yaml")
+	if err := os.WriteFile(path, []byte("servers:\n  \"203.0.113.10:2424\":\n    name: main\n    maxPlayers: 60\ndefault:\n  name: other\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := readMockResponses(path)
+	if err != nil {
+		t.Fatalf("readMockResponses() error = %v", err)
+	}
+	if got := r.Servers["203.0.113.10:2424"]; got.Name != "main" || got.MaxPlayers != 60 || r.Default == nil || r.Default.Name != "other" {
+		t.Errorf("readMockResponses() = %+v", r)
+	}
+
+	if err := os.WriteFile(path, []byte("servers:\n  main:\n    name: main\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readMockResponses(path); err == nil {
+		t.Error("readMockResponses() accepted an endpoint that is not ip:port")
+	}
+}
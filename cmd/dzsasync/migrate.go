@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+func newMigrateConfigCmd(configPath *string) *cobra.Command {
+	var (
+		out    string
+		dryRun bool
+	)
+	cmd := &cobra.Command{
+		Use:   "migrate-config",
+		Short: "Convert a config from an older release to the current schema",
+		Long: "Convert a config from an older release to the current schema (e.g. the legacy ports list to servers).\n" +
+			"By default the file is rewritten in place and the original is kept with a .bak suffix.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if *configPath == "" {
+				return fmt.Errorf("missing required flag: --config")
+			}
+			info, err := os.Stat(*configPath)
+			if err != nil {
+				return fmt.Errorf("stat config: %w", err)
+			}
+			b, err := os.ReadFile(*configPath) // #nosec G304 -- path is user-supplied
+			if err != nil {
+				return fmt.Errorf("read config: %w", err)
+			}
+			migrated, changes, err := config.Migrate(b)
+			if err != nil {
+				return fmt.Errorf("migrate %s: %w", *configPath, err)
+			}
+
+			w := cmd.OutOrStdout()
+			if len(changes) == 0 {
+				fmt.Fprintf(w, "%s: already up to date\n", *configPath)
+				return nil
+			}
+			if dryRun {
+				printMigration(w, *configPath, changes)
+				_, err := w.Write(migrated)
+				return err
+			}
+
+			dest := out
+			if dest == "" {
+				dest = *configPath
+				backup := *configPath + ".bak"
+				if err := os.WriteFile(backup, b, info.Mode().Perm()); err != nil {
+					return fmt.Errorf("write backup: %w", err)
+				}
+				fmt.Fprintf(w, "backed up %s to %s\n", *configPath, backup)
+			}
+			if err := os.WriteFile(dest, migrated, info.Mode().Perm()); err != nil {
+				return fmt.Errorf("write %s: %w", dest, err)
+			}
+			printMigration(w, dest, changes)
+
+			var cfg config.Config
+			if err := yaml.Unmarshal(migrated, &cfg); err == nil {
+				if err := cfg.Validate(); err != nil {
+					fmt.Fprintf(w, "warning: migrated config does not validate: %v\n", err)
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&out, "out", "", "Write the migrated config to this path instead of replacing --config")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the changes and migrated config without writing anything")
+	return cmd
+}
+
+// printMigration reports each change made to the config written to path.
+func printMigration(w io.Writer, path string, changes []string) {
+	fmt.Fprintf(w, "%s: %d change(s)\n", path, len(changes))
+	for _, c := range changes {
+		fmt.Fprintf(w, "  - %s\n", c)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/mockserver"
+	"github.com/jsirianni/dzsa-sync/model"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// mockResponses is the format of the mockserver --responses file: results by endpoint, and the result for
+// any other endpoint. Results use the DZSA field names.
+type mockResponses struct {
+	Servers map[string]model.Result `json:"servers"`
+	Default *model.Result           `json:"default"`
+}
+
+// defaultMockResult answers every endpoint when mockserver runs without --responses.
+var defaultMockResult = model.Result{
+	Name:       "dzsa-sync mock server",
+	Map:        "chernarusplus",
+	Players:    0,
+	MaxPlayers: 60,
+	Version:    "1.26.159040",
+	GamePort:   2302,
+}
+
+func newMockServerCmd() *cobra.Command {
+	var (
+		addr, responses string
+		latency, jitter time.Duration
+		faults          []string
+	)
+	cmd := &cobra.Command{
+		Use:   "mockserver",
+		Short: "Serve a fake DZSA query API for local development and tests",
+		Long: "Serve the DZSA query API (GET /api/v1/query/<ip>:<port>) with configurable responses, latency, and faults, " +
+			"so the daemon can run offline: point staging.url at http://<addr>/api/v1/query. Without --responses, every " +
+			"endpoint gets a generic result. Results can be changed while it runs with PUT and DELETE /mock/servers/<ip>:<port>, " +
+			"and GET /mock/queries counts the queries received.\n\n" +
+			"--fault kind=rate[:status] fails a share of queries: status (HTTP status, default 503), error (a DZSA error body), " +
+			"timeout (no answer), or malformed (a body that is not JSON).",
+		Example: "  dzsa-sync mockserver --addr 127.0.0.1:8080\n" +
+			"  dzsa-sync mockserver --responses servers.yaml --latency 300ms --jitter 200ms --fault status=0.1:429 --fault timeout=0.02",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			opts := mockserver.Options{Default: &defaultMockResult, Latency: latency, Jitter: jitter}
+			if responses != "" {
+				r, err := readMockResponses(responses)
+				if err != nil {
+					return err
+				}
+				opts.Servers, opts.Default = r.Servers, r.Default
+			}
+			for _, f := range faults {
+				fault, err := mockserver.ParseFault(f)
+				if err != nil {
+					return err
+				}
+				opts.Faults = append(opts.Faults, fault)
+			}
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
+			ln, err := net.Listen("tcp", addr)
+			if err != nil {
+				return fmt.Errorf("listen: %w", err)
+			}
+			mock := mockserver.New(opts)
+			srv := &http.Server{Handler: mock, ReadHeaderTimeout: 10 * time.Second}
+			fmt.Fprintf(cmd.OutOrStdout(), "mock DZSA query API on http://%s%s\n", ln.Addr(), mockserver.QueryPath)
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			errc := make(chan error, 1)
+			go func() { errc <- srv.Serve(ln) }()
+			select {
+			case err := <-errc:
+				return err
+			case <-ctx.Done():
+			}
+			mock.Close()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:8080", "Listen address")
+	cmd.Flags().StringVar(&responses, "responses", "", "YAML or JSON file with servers (results by ip:port) and default (result for any other endpoint)")
+	cmd.Flags().DurationVar(&latency, "latency", 0, "Delay before every response")
+	cmd.Flags().DurationVar(&jitter, "jitter", 0, "Random extra delay of up to this long")
+	cmd.Flags().StringArrayVar(&faults, "fault", nil, "Fail a share of queries: kind=rate[:status], e.g. status=0.1:429 (repeatable)")
+	return cmd
+}
+
+// readMockResponses reads a mockserver --responses file. YAML is converted to JSON first, so results use
+// the same field names in both formats.
+func readMockResponses(path string) (*mockResponses, error) {
+	b, err := os.ReadFile(path) // #nosec G304 -- path is user-supplied
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	b, err = json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	var r mockResponses
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for endpoint := range r.Servers {
+		if _, err := model.ParseEndpoint(endpoint); err != nil {
+			return nil, fmt.Errorf("%s: servers: %w", path, err)
+		}
+	}
+	return &r, nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/client"
+	"github.com/jsirianni/dzsa-sync/internal/api"
+	"github.com/jsirianni/dzsa-sync/internal/steam"
+	"github.com/jsirianni/dzsa-sync/model"
+	"github.com/spf13/cobra"
+)
+
+// modsResult is the mods command's output.
+type modsResult struct {
+	Server string     `json:"server,omitempty"`
+	Port   int        `json:"port"`
+	Mods   []modEntry `json:"mods"`
+}
+
+// modEntry is one mod. The workshop fields are set with --steam.
+type modEntry struct {
+	WorkshopID int    `json:"workshop_id"`
+	Name       string `json:"name"`
+	URL        string `json:"url"`
+	// Title is the current workshop title.
+	Title string `json:"title,omitempty"`
+	// Size is the mod size in bytes.
+	Size    int64      `json:"size,omitempty"`
+	Updated *time.Time `json:"updated,omitempty"`
+	// Status is "ok" or the workshop problem (deleted, private, banned, renamed).
+	Status string `json:"status,omitempty"`
+}
+
+const workshopItemURL = "https://steamcommunity.com/sharedfiles/filedetails/?id="
+
+func newModsCmd() *cobra.Command {
+	var (
+		addr      string
+		output    string
+		live      bool
+		withSteam bool
+		timeout   time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:   "mods <name|port|ip:port>",
+		Short: "Print a server's mod list with workshop IDs",
+		Long: "Print the mod list DZSA reports for a server managed by the running daemon (by name or port), " +
+			"or for any server given as ip:port. --live queries DZSA now instead of using the daemon's last sync; " +
+			"--steam adds each mod's workshop title, size, last update, and status.",
+		Example: "  dzsa-sync mods main --steam\n  dzsa-sync mods 203.0.113.10:2424 -o json | jq -r '.mods[].workshop_id'",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			defer cancel()
+			cmd.SetContext(ctx)
+
+			res, mods, err := loadMods(cmd, addr, args[0], live)
+			if err != nil {
+				return err
+			}
+			res.Mods = make([]modEntry, 0, len(mods))
+			for _, m := range mods {
+				res.Mods = append(res.Mods, modEntry{
+					WorkshopID: m.SteamWorkshopID,
+					Name:       m.Name,
+					URL:        workshopItemURL + strconv.Itoa(m.SteamWorkshopID),
+				})
+			}
+			if withSteam {
+				if err := addWorkshopDetails(ctx, steam.New(nil, nil), mods, res.Mods); err != nil {
+					return err
+				}
+			}
+			return writeOutput(cmd.OutOrStdout(), output, res, func(w io.Writer) error {
+				return printMods(w, res, withSteam)
+			})
+		},
+	}
+	addAddrFlag(cmd, &addr)
+	addOutputFlag(cmd, &output)
+	cmd.Flags().BoolVar(&live, "live", false, "Query DZSA now instead of using the daemon's last sync result")
+	cmd.Flags().BoolVar(&withSteam, "steam", false, "Add workshop title, size, last update, and status from the Steam API")
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Second, "Timeout for all requests")
+	return cmd
+}
+
+// loadMods returns the mod list for target: an ip:port is always queried live; a configured
+// server name or port is read from the daemon, or queried live at the daemon's external IP.
+func loadMods(cmd *cobra.Command, addr, target string, live bool) (*modsResult, []model.Mods, error) {
+	if strings.Contains(target, ":") {
+		e, err := model.ParseEndpoint(target)
+		if err != nil {
+			return nil, nil, err
+		}
+		if e.IP, err = resolveIP(cmd.Context(), e.IP, false); err != nil {
+			return nil, nil, err
+		}
+		resp, err := client.New(client.Options{}).Query(cmd.Context(), e.IP, e.Port)
+		if err != nil {
+			return nil, nil, fmt.Errorf("query %s: %w", e, err)
+		}
+		return &modsResult{Server: resp.Result.Name, Port: e.Port}, resp.Result.Mods, nil
+	}
+
+	var status api.StatusResponse
+	if err := apiGet(cmd, addr, "/api/v1/status", &status); err != nil {
+		return nil, nil, err
+	}
+	srv, ok := findServer(status.Servers, target)
+	if !ok {
+		return nil, nil, fmt.Errorf("no server named %q or with port %s", target, target)
+	}
+	res := &modsResult{Server: srv.Name, Port: srv.Port}
+	if live {
+		e := model.Endpoint{IP: srv.AdvertiseIP, Port: srv.Port}
+		if srv.AdvertisePort != 0 {
+			e.Port = srv.AdvertisePort
+		}
+		if e.IP == "" {
+			if srv.Host != "" {
+				return nil, nil, fmt.Errorf("%s runs on host %s; pass its ip:port instead", srv.Name, srv.Host)
+			}
+			if status.ExternalIP == "" {
+				return nil, nil, fmt.Errorf("daemon has not detected its external IP yet")
+			}
+			e.IP = status.ExternalIP
+		}
+		if net.ParseIP(e.IP) == nil {
+			return nil, nil, fmt.Errorf("daemon redacts the server's IP (%s); pass ip:port instead", e.IP)
+		}
+		resp, err := client.New(client.Options{}).Query(cmd.Context(), e.IP, e.Port)
+		if err != nil {
+			return nil, nil, fmt.Errorf("query %s: %w", e, err)
+		}
+		return res, resp.Result.Mods, nil
+	}
+	var result model.Result
+	if err := apiGet(cmd, addr, "/api/v1/servers/"+strconv.Itoa(srv.Port), &result); err != nil {
+		var statusErr *apiStatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+			return nil, nil, fmt.Errorf("%s has not synced yet; use --live to query DZSA now", srv.Name)
+		}
+		return nil, nil, err
+	}
+	return res, result.Mods, nil
+}
+
+// findServer matches target against server names, then ports.
+func findServer(list []api.ServerStatus, target string) (api.ServerStatus, bool) {
+	for _, s := range list {
+		if s.Name == target {
+			return s, true
+		}
+	}
+	if port, err := strconv.Atoi(target); err == nil {
+		for _, s := range list {
+			if s.Port == port {
+				return s, true
+			}
+		}
+	}
+	return api.ServerStatus{}, false
+}
+
+// addWorkshopDetails fills the workshop fields of entries, which correspond to mods by index.
+func addWorkshopDetails(ctx context.Context, c *steam.Client, mods []model.Mods, entries []modEntry) error {
+	ids := make([]int, len(mods))
+	for i, m := range mods {
+		ids[i] = m.SteamWorkshopID
+	}
+	details, err := c.PublishedFileDetails(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("workshop details: %w", err)
+	}
+	byID := make(map[int]steam.FileDetails, len(details))
+	for _, d := range details {
+		byID[d.ID] = d
+	}
+	problems := make(map[int]string)
+	for _, p := range steam.ValidateMods(mods, byID) {
+		problems[p.WorkshopID] = p.Problem
+	}
+	for i := range entries {
+		e := &entries[i]
+		d := byID[e.WorkshopID]
+		e.Title, e.Size = d.Title, d.FileSize
+		if !d.Updated.IsZero() {
+			updated := d.Updated
+			e.Updated = &updated
+		}
+		e.Status = "ok"
+		if p, ok := problems[e.WorkshopID]; ok {
+			e.Status = p
+		}
+	}
+	return nil
+}
+
+func printMods(out io.Writer, res *modsResult, withSteam bool) error {
+	fmt.Fprintf(out, "%s (port %d): %d mods\n\n", res.Server, res.Port, len(res.Mods))
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if withSteam {
+		fmt.Fprintln(w, "WORKSHOP ID\tNAME\tSIZE\tUPDATED\tSTATUS")
+	} else {
+		fmt.Fprintln(w, "WORKSHOP ID\tNAME")
+	}
+	for _, m := range res.Mods {
+		if !withSteam {
+			fmt.Fprintf(w, "%d\t%s\n", m.WorkshopID, m.Name)
+			continue
+		}
+		updated := "-"
+		if m.Updated != nil {
+			updated = m.Updated.Format(time.DateOnly)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", m.WorkshopID, m.Name, formatBytes(m.Size), updated, m.Status)
+	}
+	return w.Flush()
+}
+
+// formatBytes renders n in the largest binary unit that keeps it at or above 1.
+func formatBytes(n int64) string {
+	if n <= 0 {
+		return "-"
+	}
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats for read-style commands.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// addOutputFlag registers --output (-o) on a read-style command and rejects unknown formats before it runs.
+func addOutputFlag(cmd *cobra.Command, output *string) {
+	cmd.Flags().StringVarP(output, "output", "o", outputTable, "Output format: table, json, or yaml")
+	cmd.PreRunE = func(_ *cobra.Command, _ []string) error {
+		switch *output {
+		case outputTable, outputJSON, outputYAML:
+			return nil
+		default:
+			return fmt.Errorf("invalid output %q: must be %s, %s, or %s", *output, outputTable, outputJSON, outputYAML)
+		}
+	}
+}
+
+// writeOutput writes v as JSON or YAML, or calls table for the human-readable format. YAML keys
+// match the JSON field names so both formats can be used with the same field paths.
+func writeOutput(out io.Writer, format string, v any, table func(io.Writer) error) error {
+	switch format {
+	case outputJSON:
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case outputYAML:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("encode: %w", err)
+		}
+		var generic any
+		if err := json.Unmarshal(b, &generic); err != nil {
+			return fmt.Errorf("encode: %w", err)
+		}
+		enc := yaml.NewEncoder(out)
+		enc.SetIndent(2)
+		if err := enc.Encode(generic); err != nil {
+			return fmt.Errorf("encode yaml: %w", err)
+		}
+		return enc.Close()
+	default:
+		return table(out)
+	}
+}
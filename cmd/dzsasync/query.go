@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/client"
+	"github.com/jsirianni/dzsa-sync/model"
+	"github.com/spf13/cobra"
+)
+
+func newQueryCmd() *cobra.Command {
+	var (
+		output  string
+		timeout time.Duration
+		ipv6    bool
+	)
+	cmd := &cobra.Command{
+		Use:   "query <ip:port>",
+		Short: "Query DZSA once for a server and print the result",
+		Long: "Query the DZSA launcher API once for any server and print what DZSA reports about it. " +
+			"The port is the server's query port. A hostname is resolved to its first IPv4 address, or with --ipv6 " +
+			"its first IPv6 address, as for a server with address_family: ipv6.",
+		Example: "  dzsa-sync query 203.0.113.10:2424\n  dzsa-sync query dayz.example.com:2424 --output json | jq .players\n" +
+			"  dzsa-sync query dayz.example.com:2424 --ipv6",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			defer cancel()
+
+			e, err := model.ParseEndpoint(args[0])
+			if err != nil {
+				return err
+			}
+			if e.IP, err = resolveIP(ctx, e.IP, ipv6); err != nil {
+				return err
+			}
+			resp, err := client.New(client.Options{}).Query(ctx, e.IP, e.Port)
+			if err != nil {
+				return fmt.Errorf("query %s: %w", e, err)
+			}
+			return writeOutput(cmd.OutOrStdout(), output, resp.Result, func(w io.Writer) error {
+				return printResult(w, &resp.Result)
+			})
+		},
+	}
+	addOutputFlag(cmd, &output)
+	cmd.Flags().DurationVar(&timeout, "timeout", client.DefaultHTTPTimeout, "Timeout for the query")
+	cmd.Flags().BoolVarP(&ipv6, "ipv6", "6", false, "Resolve a hostname to its IPv6 address")
+	return cmd
+}
+
+// resolveIP returns host when it is an IP address, or its first IPv4 address otherwise. With ipv6, it returns
+// the first IPv6 address instead, and rejects an IPv4 address.
+func resolveIP(ctx context.Context, host string, ipv6 bool) (string, error) {
+	network, family := "ip4", "IPv4"
+	if ipv6 {
+		network, family = "ip6", "IPv6"
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if ipv6 && ip.To4() != nil {
+			return "", fmt.Errorf("%s is not an IPv6 address", host)
+		}
+		return host, nil
+	}
+	addrs, err := net.DefaultResolver.LookupIP(ctx, network, host)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", host, err)
+	}
+	if len(addrs) == 0 {
+		return "", fmt.Errorf("resolve %s: no %s address", host, family)
+	}
+	return addrs[0].String(), nil
+}
+
+// printResult writes a human-readable summary of a DZSA result followed by its mod list.
+func printResult(out io.Writer, r *model.Result) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	rows := []struct {
+		key, value string
+	}{
+		{"Name", r.Name},
+		{"Endpoint", r.Endpoint.String()},
+		{"Game port", strconv.Itoa(r.GamePort)},
+		{"Map", r.Map},
+		{"Version", r.Version},
+		{"Players", fmt.Sprintf("%d/%d", r.Players, r.MaxPlayers)},
+		{"Time", fmt.Sprintf("%s (x%d)", r.Time, r.TimeAcceleration)},
+		{"Password", strconv.FormatBool(r.Password)},
+		{"First person only", strconv.FormatBool(r.FirstPersonOnly)},
+		{"BattlEye", strconv.FormatBool(r.BattlEye)},
+		{"Mods", strconv.Itoa(len(r.Mods))},
+	}
+	for _, row := range rows {
+		fmt.Fprintf(w, "%s:\t%s\n", row.key, row.value)
+	}
+	if len(r.Mods) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "WORKSHOP ID\tMOD")
+		for _, m := range r.Mods {
+			fmt.Fprintf(w, "%d\t%s\n", m.SteamWorkshopID, m.Name)
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/daemon"
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+)
+
+// A graceful restart (SIGUSR2, or systemctl reload) starts a new copy of the binary that inherits the API
+// listeners and the store state, so an upgrade never closes the API port or forgets recent syncs:
+//
+//  1. The old process pauses syncs, waits for those in flight, and snapshots the store.
+//  2. It starts the new binary with the listeners, a pipe carrying the snapshot, and a ready pipe as
+//     extra files, named in envRestartFDs.
+//  3. The new process restores the snapshot, serves on the inherited listeners, starts its workers,
+//     tells systemd its PID, and writes to the ready pipe.
+//  4. The old process drains its API connections and exits. If the new process fails first, the old
+//     one resumes syncing and keeps running.
+const (
+	envRestartFDs = "DZSA_SYNC_RESTART_FDS"
+
+	restartReadyTimeout = time.Minute
+
+	// Names of the inherited files other than the listeners, which keep their daemon names.
+	fdState = "state"
+	fdReady = "ready"
+)
+
+// inherited is what a process started by a graceful restart receives from its predecessor.
+type inherited struct {
+	listeners map[string]net.Listener
+	state     *servers.Snapshot
+	ready     *os.File
+}
+
+// inheritFromParent returns the files passed by a graceful restart, or nil when the process was started normally.
+func inheritFromParent() (*inherited, error) {
+	names := os.Getenv(envRestartFDs)
+	if names == "" {
+		return nil, nil
+	}
+	_ = os.Unsetenv(envRestartFDs)
+	in := &inherited{listeners: make(map[string]net.Listener)}
+	for i, name := range strings.Split(names, ",") {
+		f := os.NewFile(uintptr(3+i), name) // #nosec G115 -- small index
+		if f == nil {
+			return nil, fmt.Errorf("inherited file %s is not open", name)
+		}
+		switch name {
+		case fdState:
+			var snap servers.Snapshot
+			err := json.NewDecoder(f).Decode(&snap)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("read inherited state: %w", err)
+			}
+			in.state = &snap
+		case fdReady:
+			in.ready = f
+		default:
+			ln, err := net.FileListener(f)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("inherited listener %s: %w", name, err)
+			}
+			if ul, ok := ln.(*net.UnixListener); ok {
+				// Like a socket the daemon listens on, the socket file is removed on shutdown.
+				ul.SetUnlinkOnClose(true)
+			}
+			in.listeners[name] = ln
+		}
+	}
+	return in, nil
+}
+
+// listener returns the inherited listener called name. It is safe to call on a nil inherited.
+func (in *inherited) listener(name string) (net.Listener, bool) {
+	if in == nil {
+		return nil, false
+	}
+	ln, ok := in.listeners[name]
+	return ln, ok
+}
+
+// signalReady tells the previous process that this one serves and syncs, so it can exit.
+func (in *inherited) signalReady() error {
+	if in.ready == nil {
+		return nil
+	}
+	defer in.ready.Close()
+	if _, err := in.ready.Write([]byte{1}); err != nil {
+		return fmt.Errorf("signal ready: %w", err)
+	}
+	return nil
+}
+
+// handoff starts a new copy of the running binary with the same arguments, passes it listeners and snap,
+// and returns its PID once it reports ready. The new process is killed if it is not ready within timeout.
+func handoff(listeners []daemon.Listener, snap servers.Snapshot, timeout time.Duration) (int, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("find executable: %w", err)
+	}
+
+	var (
+		names []string
+		files []*os.File
+	)
+	// The new process has its own copies once started; the write end of the ready pipe in particular
+	// must be closed here so the read below sees EOF when the new process exits.
+	closeFiles := func() {
+		for _, f := range files {
+			f.Close()
+		}
+		files = nil
+	}
+	defer closeFiles()
+	for _, l := range listeners {
+		filer, ok := l.Listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			return 0, fmt.Errorf("listener %s cannot be passed to another process", l.Name)
+		}
+		f, err := filer.File()
+		if err != nil {
+			return 0, fmt.Errorf("listener %s: %w", l.Name, err)
+		}
+		names = append(names, l.Name)
+		files = append(files, f)
+	}
+	stateR, stateW, err := os.Pipe()
+	if err != nil {
+		return 0, fmt.Errorf("state pipe: %w", err)
+	}
+	defer stateW.Close()
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		stateR.Close()
+		return 0, fmt.Errorf("ready pipe: %w", err)
+	}
+	defer readyR.Close()
+	names = append(names, fdState, fdReady)
+	files = append(files, stateR, readyW)
+
+	cmd := exec.Command(exe, os.Args[1:]...) // #nosec G204 -- re-executes this binary with its own arguments
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), envRestartFDs+"="+strings.Join(names, ","))
+	cmd.ExtraFiles = files
+	err = cmd.Start()
+	closeFiles()
+	if err != nil {
+		return 0, fmt.Errorf("start %s: %w", exe, err)
+	}
+
+	go func() {
+		// A write error means the new process exited, which the ready pipe reports.
+		_ = json.NewEncoder(stateW).Encode(snap)
+		stateW.Close()
+	}()
+
+	// The read fails with EOF when the new process exits, since that closes its end of the ready pipe.
+	_ = readyR.SetReadDeadline(time.Now().Add(timeout))
+	if _, err := readyR.Read(make([]byte, 1)); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		if errors.Is(err, io.EOF) {
+			return 0, errors.New("new process exited before it was ready")
+		}
+		return 0, fmt.Errorf("wait for new process: %w", err)
+	}
+	pid := cmd.Process.Pid
+	_ = cmd.Process.Release()
+	return pid, nil
+}
+
+// sdNotify sends state to systemd when it started the process as Type=notify. It is a no-op otherwise.
+func sdNotify(state string) error {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return nil
+	}
+	if path[0] == '@' {
+		// Abstract socket namespace.
+		path = "\x00" + path[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("notify systemd: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("notify systemd: %w", err)
+	}
+	return nil
+}
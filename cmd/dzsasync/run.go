@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/buildinfo"
+	"github.com/jsirianni/dzsa-sync/internal/daemon"
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	defaultLogMaxSize    = 100
+	defaultLogMaxBackups = 3
+	defaultLogMaxAge     = 28
+)
+
+func newRunCmd(configPath *string) *cobra.Command {
+	var flags daemonFlags
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Run the sync daemon",
+		Long: "Run the sync daemon: register every configured server with DZSA, serve the API and metrics, and keep servers synced until interrupted.\n\n" +
+			"Without --config, a simple setup can be given entirely with flags or environment variables, e.g. " +
+			"dzsa-sync run --server main:2424 --detect-ip --log stdout. When --config is set, the file is authoritative and these flags are ignored.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cfg, err := daemonConfig(cmd, *configPath, &flags, cmd.ErrOrStderr())
+			if err != nil {
+				return err
+			}
+			return runDaemon(cfg, *configPath)
+		},
+	}
+	addDaemonFlags(cmd, &flags)
+	return cmd
+}
+
+// runDaemon runs the daemon until SIGINT or SIGTERM. configPath is the file cfg was loaded from, or empty
+// when it was built from flags. Errors before the logger is ready are returned; later startup failures are
+// logged and returned.
+func runDaemon(cfg *config.Config, configPath string) error {
+	logger, err := setupLogger(cfg)
+	if err != nil {
+		return fmt.Errorf("logger: %w", err)
+	}
+	defer logger.Sync()
+	redactor, err := daemon.NewRedactor(cfg.Privacy)
+	if err != nil {
+		return fmt.Errorf("privacy: %w", err)
+	}
+	logger = logger.WithOptions(zap.WrapCore(redactor.Core))
+	if cfg.InstanceName != "" {
+		logger = logger.With(zap.String("instance_name", cfg.InstanceName))
+	}
+
+	build := buildinfo.Get()
+	logger.Info("starting dzsa-sync",
+		zap.String("version", build.Version),
+		zap.String("commit", build.Commit),
+		zap.String("build_date", build.Date),
+		zap.String("go_version", build.GoVersion))
+	if cfg.ControllerEnabled() {
+		return runController(cfg, logger, redactor)
+	}
+
+	inherit, err := inheritFromParent()
+	if err != nil {
+		logger.Fatal("graceful restart", zap.Error(err))
+	}
+
+	signalCtx, signalCancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer signalCancel()
+	restart := make(chan os.Signal, 1)
+	signal.Notify(restart, syscall.SIGUSR2)
+	defer signal.Stop(restart)
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+
+	opts := daemon.Options{
+		Logger:     logger,
+		Redactor:   redactor,
+		ConfigPath: configPath,
+		Listener:   inherit.listener,
+		Reload:     reload,
+		Restart:    restart,
+		Handoff: func(listeners []daemon.Listener, snap servers.Snapshot) (int, error) {
+			return handoff(listeners, snap, restartReadyTimeout)
+		},
+		Ready: func() {
+			if inherit != nil {
+				if err := sdNotify(fmt.Sprintf("MAINPID=%d\nREADY=1", os.Getpid())); err != nil {
+					logger.Warn("systemd notify", zap.Error(err))
+				}
+				if err := inherit.signalReady(); err != nil {
+					logger.Error("graceful restart", zap.Error(err))
+				}
+				logger.Info("graceful restart complete, took over from the previous process")
+			} else if err := sdNotify("READY=1"); err != nil {
+				logger.Warn("systemd notify", zap.Error(err))
+			}
+		},
+	}
+	if inherit != nil {
+		opts.State = inherit.state
+	}
+	if err := daemon.Run(signalCtx, cfg, opts); err != nil {
+		logger.Error("daemon", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func setupLogger(cfg *config.Config) (*zap.Logger, error) {
+	logPath := cfg.LogPath
+	var writer zapcore.WriteSyncer
+	switch logPath {
+	case config.LogStdout:
+		writer = zapcore.Lock(os.Stdout)
+	case config.LogStderr:
+		writer = zapcore.Lock(os.Stderr)
+	default:
+		// lumberjack opens an existing file for appending but creates a missing one without O_APPEND,
+		// which would let the two processes of a graceful restart overwrite each other's lines.
+		if f, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600); err == nil { // #nosec G304 -- operator-configured log path
+			f.Close()
+		}
+		writer = zapcore.AddSync(&lumberjack.Logger{
+			Filename:   logPath,
+			MaxSize:    defaultLogMaxSize,
+			MaxBackups: defaultLogMaxBackups,
+			MaxAge:     defaultLogMaxAge,
+			Compress:   true,
+		})
+	}
+
+	core := zapcore.NewCore(
+		daemon.LogEncoder(cfg.LogFormat),
+		writer,
+		daemon.LogLevel(cfg.LogLevel),
+	)
+	return zap.New(core), nil
+}
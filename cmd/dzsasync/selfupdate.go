@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/jsirianni/dzsa-sync/internal/buildinfo"
+	"github.com/jsirianni/dzsa-sync/internal/selfupdate"
+	"github.com/spf13/cobra"
+)
+
+func newSelfUpdateCmd() *cobra.Command {
+	var check, force bool
+	cmd := &cobra.Command{
+		Use:   "self-update",
+		Short: "Update this binary to the latest GitHub release",
+		Long: "Check GitHub for the latest release and replace this binary with it. The download is verified " +
+			"against the release checksums and their signature and replaced atomically; only release builds, which carry " +
+			"the release key, can update themselves. Restart the daemon afterwards, or send it SIGUSR2 (systemctl reload dzsa-sync) to switch without downtime. Installs from the .deb or .rpm package " +
+			"should be updated with the package manager instead.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			out := cmd.OutOrStdout()
+			u, err := selfupdate.New(selfupdate.Options{})
+			if err != nil {
+				return err
+			}
+			release, err := u.Latest(cmd.Context())
+			if err != nil {
+				return err
+			}
+			current := buildinfo.Get().Version
+			newer := selfupdate.Newer(release.Tag, current)
+			if !newer {
+				fmt.Fprintf(out, "dzsa-sync %s is up to date (latest %s)\n", current, release.Tag)
+				if !force {
+					return nil
+				}
+			} else {
+				fmt.Fprintf(out, "update available: %s -> %s %s\n", current, release.Tag, release.URL)
+			}
+			if check {
+				return nil
+			}
+
+			exe, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("locate executable: %w", err)
+			}
+			if exe, err = filepath.EvalSymlinks(exe); err != nil {
+				return fmt.Errorf("locate executable: %w", err)
+			}
+			if strings.HasPrefix(exe, "/usr/bin/") && !force {
+				return fmt.Errorf("%s looks package-managed; update the package instead or pass --force", exe)
+			}
+			if err := u.Install(cmd.Context(), release, runtime.GOOS, runtime.GOARCH, exe); err != nil {
+				return fmt.Errorf("install %s: %w", release.Tag, err)
+			}
+			fmt.Fprintf(out, "updated %s to %s; restart or reload dzsa-sync to use it\n", exe, release.Tag)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&check, "check", false, "Only report whether an update is available")
+	cmd.Flags().BoolVar(&force, "force", false, "Reinstall the latest release even when up to date, and replace package-managed binaries")
+	return cmd
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultSetupPath    = "/etc/dzsa-sync/config.yaml"
+	defaultSetupLog     = "/var/log/dzsa-sync/dzsa-sync.log"
+	defaultSetupAPIPort = 8888
+)
+
+func newSetupCmd(configPath *string) *cobra.Command {
+	var skipChecks bool
+	cmd := &cobra.Command{
+		Use:   "setup",
+		Short: "Interactively create a config file",
+		Long: "Ask for servers, IP detection, log location, and API settings, check connectivity to DZSA and " +
+			"the IP detection service, and write a working config. The file is written to --config, or " +
+			defaultSetupPath + " when --config is not set.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			s := &setup{
+				in:  bufio.NewReader(cmd.InOrStdin()),
+				out: cmd.OutOrStdout(),
+			}
+			if !skipChecks {
+				s.checker = newConnectivity()
+			}
+			return s.run(cmd.Context(), *configPath)
+		},
+	}
+	cmd.Flags().BoolVar(&skipChecks, "skip-checks", false, "Do not test connectivity to DZSA and the IP detection service")
+	return cmd
+}
+
+// setupConfig is the subset of config.Config the wizard writes. Empty sections are omitted so the
+// file only contains what was asked for.
+type setupConfig struct {
+	DetectIP   bool              `yaml:"detect_ip"`
+	ExternalIP string            `yaml:"external_ip,omitempty"`
+	Servers    []config.Server   `yaml:"servers"`
+	LogPath    string            `yaml:"log_path"`
+	API        *config.APIConfig `yaml:"api,omitempty"`
+}
+
+// setup holds the wizard's input and output. checker is nil when connectivity checks are skipped.
+type setup struct {
+	in      *bufio.Reader
+	out     io.Writer
+	checker *connectivity
+}
+
+func (s *setup) run(ctx context.Context, path string) error {
+	fmt.Fprintln(s.out, "dzsa-sync setup: press enter to accept the default shown in brackets.")
+
+	path, err := s.ask("Config file", orDefault(path, defaultSetupPath))
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		ok, err := s.confirm(path+" exists. Overwrite?", false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("not overwriting %s", path)
+		}
+	}
+
+	var cfg setupConfig
+	if cfg.Servers, err = s.askServers(); err != nil {
+		return err
+	}
+
+	if cfg.DetectIP, err = s.confirm("Detect the external IP automatically (ifconfig.net)?", true); err != nil {
+		return err
+	}
+	if !cfg.DetectIP {
+		if cfg.ExternalIP, err = s.askValid("External IP", "", validateIP); err != nil {
+			return err
+		}
+	}
+
+	if cfg.LogPath, err = s.ask("Log file", defaultSetupLog); err != nil {
+		return err
+	}
+
+	api := config.APIConfig{}
+	if api.Host, err = s.ask("API listen host (blank for all interfaces)", ""); err != nil {
+		return err
+	}
+	portStr, err := s.askValid("API port", strconv.Itoa(defaultSetupAPIPort), func(v string) error {
+		_, err := parsePort(v)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	api.Port, _ = parsePort(portStr)
+	if api.Host != "" || api.Port != defaultSetupAPIPort {
+		cfg.API = &api
+	}
+
+	if s.checker != nil {
+		fmt.Fprintln(s.out, "\nChecking connectivity...")
+		s.checker.check(ctx, s.out, cfg.DetectIP, cfg.ExternalIP, cfg.Servers)
+	}
+
+	b, err := yaml.Marshal(&cfg)
+	if err != nil {
+		return fmt.Errorf("encode config: %w", err)
+	}
+	var parsed config.Config
+	if err := yaml.Unmarshal(b, &parsed); err != nil {
+		return fmt.Errorf("decode config: %w", err)
+	}
+	if err := parsed.Validate(); err != nil {
+		return fmt.Errorf("generated config is invalid: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("create config directory: %w", err)
+	}
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	fmt.Fprintf(s.out, "\nWrote %s. Start the daemon with:\n  dzsa-sync run --config %s\n", path, path)
+	return nil
+}
+
+// askServers prompts for servers until a blank name is entered. At least one server is required.
+func (s *setup) askServers() ([]config.Server, error) {
+	fmt.Fprintln(s.out, "\nServers: enter each server's name and query port. Leave the name blank when done.")
+	var list []config.Server
+	seen := make(map[int]bool)
+	for {
+		def := ""
+		if len(list) == 0 {
+			def = "main"
+		}
+		name, err := s.ask(fmt.Sprintf("Server %d name", len(list)+1), def)
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			if len(list) == 0 {
+				fmt.Fprintln(s.out, "  at least one server is required")
+				continue
+			}
+			return list, nil
+		}
+		portStr, err := s.askValid("  query port", "", func(v string) error {
+			p, err := parsePort(v)
+			if err != nil {
+				return err
+			}
+			if seen[p] {
+				return fmt.Errorf("port %d is already configured", p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		port, _ := parsePort(portStr)
+		seen[port] = true
+		list = append(list, config.Server{Name: name, Port: port})
+	}
+}
+
+// ask prints prompt and returns the trimmed answer, or def when the answer is blank.
+func (s *setup) ask(prompt, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(s.out, "%s [%s]: ", prompt, def)
+	} else {
+		fmt.Fprintf(s.out, "%s: ", prompt)
+	}
+	line, err := s.in.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		if errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("setup aborted: unexpected end of input")
+		}
+		return "", fmt.Errorf("read input: %w", err)
+	}
+	if v := strings.TrimSpace(line); v != "" {
+		return v, nil
+	}
+	return def, nil
+}
+
+// askValid repeats the prompt until validate accepts the answer.
+func (s *setup) askValid(prompt, def string, validate func(string) error) (string, error) {
+	for {
+		v, err := s.ask(prompt, def)
+		if err != nil {
+			return "", err
+		}
+		if err := validate(v); err != nil {
+			fmt.Fprintf(s.out, "  %v\n", err)
+			continue
+		}
+		return v, nil
+	}
+}
+
+// confirm asks a yes/no question.
+func (s *setup) confirm(prompt string, def bool) (bool, error) {
+	d := "y/N"
+	if def {
+		d = "Y/n"
+	}
+	for {
+		v, err := s.ask(prompt, d)
+		if err != nil {
+			return false, err
+		}
+		if v == d {
+			return def, nil
+		}
+		switch strings.ToLower(v) {
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		fmt.Fprintln(s.out, "  answer y or n")
+	}
+}
+
+func parsePort(v string) (int, error) {
+	p, err := strconv.Atoi(v)
+	if err != nil || p < 1 || p > 65535 {
+		return 0, fmt.Errorf("port must be a number between 1 and 65535")
+	}
+	return p, nil
+}
+
+func validateIP(v string) error {
+	if net.ParseIP(v) == nil {
+		return fmt.Errorf("%q is not an IP address", v)
+	}
+	return nil
+}
+
+func orDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
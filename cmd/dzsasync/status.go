@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/api"
+	"github.com/spf13/cobra"
+)
+
+func newStatusCmd() *cobra.Command {
+	var addr, output string
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show a summary of the servers synced by a running daemon",
+		Long: "Call the running daemon's API and print each server's players, last successful sync, next sync, and latest error. " +
+			"--addr accepts http://host:port or unix:///path/to/socket (see api.socket).",
+		Example: "  dzsa-sync status\n  dzsa-sync status --addr unix:///run/dzsa-sync/api.sock",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			var status api.StatusResponse
+			if err := apiGet(cmd, addr, "/api/v1/status", &status); err != nil {
+				return err
+			}
+			return writeOutput(cmd.OutOrStdout(), output, status, func(w io.Writer) error {
+				return printStatus(w, &status, time.Now())
+			})
+		},
+	}
+	addAddrFlag(cmd, &addr)
+	addOutputFlag(cmd, &output)
+	return cmd
+}
+
+// printStatus writes the external IP followed by one row per server.
+func printStatus(out io.Writer, status *api.StatusResponse, now time.Time) error {
+	ip := status.ExternalIP
+	if ip == "" {
+		ip = "(not detected)"
+	}
+	if status.InstanceName != "" {
+		fmt.Fprintf(out, "Instance:    %s\n", status.InstanceName)
+	}
+	fmt.Fprintf(out, "Version:     %s\nExternal IP: %s\n", status.Version, ip)
+	if !status.StartedAt.IsZero() {
+		fmt.Fprintf(out, "Uptime:      %s\n", time.Duration(status.UptimeSeconds)*time.Second)
+	}
+	switch status.SyncTarget {
+	case "":
+	case "dry_run":
+		fmt.Fprintln(out, "Staging:     dry run, syncs are not sent to DZSA")
+	default:
+		fmt.Fprintf(out, "Staging:     syncs go to %s, not the live DZSA listing\n", status.SyncTarget)
+	}
+	if status.Role != "" {
+		role := status.Role
+		if status.Role == api.RoleFollower {
+			role += " (syncs paused; leader " + orUnknown(status.Leader) + ")"
+		}
+		fmt.Fprintf(out, "Role:        %s\n", role)
+	}
+	fmt.Fprintln(out)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPORT\tPLAYERS\tLAST SYNC\tNEXT SYNC\tSTATUS")
+	for _, s := range status.Servers {
+		lastSync, state := syncSummary(s, now)
+		name := s.Name
+		if s.MonitorOnly {
+			name += " (monitor)"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d/%d\t%s\t%s\t%s\n", name, s.Port, s.Players, s.MaxPlayers, lastSync, nextSync(s, now), state)
+	}
+	return w.Flush()
+}
+
+// syncSummary returns how long ago the server last synced successfully and its current state.
+func syncSummary(s api.ServerStatus, now time.Time) (lastSync, state string) {
+	lastSync, state = "never", "pending"
+	if s.Sync != nil {
+		if !s.Sync.LastSuccess.IsZero() {
+			lastSync = formatAgo(now.Sub(s.Sync.LastSuccess)) + " ago"
+		}
+		state = "ok"
+		if s.Sync.LastError != "" {
+			state = fmt.Sprintf("error (%d): %s", s.Sync.ConsecutiveFailures, truncate(s.Sync.LastError, 60))
+		}
+	}
+	if s.Maintenance != nil {
+		state = "maintenance until " + s.Maintenance.Until.Local().Format(time.Kitchen)
+	}
+	return lastSync, state
+}
+
+// nextSync returns how long until the server's next sync.
+func nextSync(s api.ServerStatus, now time.Time) string {
+	switch {
+	case s.NextSyncAt.IsZero():
+		return "-"
+	case !s.NextSyncAt.After(now):
+		return "due"
+	default:
+		return "in " + formatAgo(s.NextSyncAt.Sub(now))
+	}
+}
+
+// formatAgo rounds d to a short human-readable duration.
+func formatAgo(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return d.Round(time.Second).String()
+	case d < time.Hour:
+		return d.Round(time.Minute).String()
+	default:
+		return d.Round(time.Hour).String()
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-1] + "…"
+}
+
+// apiGet decodes the JSON response of GET addr+path into out.
+func apiGet(cmd *cobra.Command, addr, path string, out any) error {
+	resp, err := apiDo(cmd, http.MethodGet, addr, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// apiStatusError is returned by apiDo when the daemon answers with a non-2xx status.
+type apiStatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	// Message is the start of the response body, the daemon's error message.
+	Message string
+}
+
+func (e *apiStatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s %s: unexpected status code: %d", e.Method, e.URL, e.StatusCode)
+	}
+	return fmt.Sprintf("%s %s: unexpected status code: %d: %s", e.Method, e.URL, e.StatusCode, e.Message)
+}
+
+// envAPIToken is sent as a bearer token by the commands that call the API, for the admin listener (api.admin.token).
+const envAPIToken = "DZSA_SYNC_API_TOKEN"
+
+// apiDo sends a request to the daemon API and returns the response when the status is 2xx.
+// addr is an http(s) base URL or unix:///path/to/socket.
+func apiDo(cmd *cobra.Command, method, addr, path string) (*http.Response, error) {
+	return apiSend(cmd, method, addr, path, nil, 10*time.Second)
+}
+
+// apiSend is apiDo with a request body and a timeout for the whole exchange.
+func apiSend(cmd *cobra.Command, method, addr, path string, body io.Reader, timeout time.Duration) (*http.Response, error) {
+	httpClient := &http.Client{Timeout: timeout}
+	base := strings.TrimRight(addr, "/")
+	if socket, ok := strings.CutPrefix(addr, "unix://"); ok {
+		dialer := &net.Dialer{}
+		httpClient.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		}
+		// Host is ignored when dialing the socket but must be a valid URL.
+		base = "http://dzsa-sync"
+	}
+	url := base + path
+	req, err := http.NewRequestWithContext(cmd.Context(), method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	if token := os.Getenv(envAPIToken); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, addr+path, err)
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &apiStatusError{Method: method, URL: addr + path, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	return resp, nil
+}
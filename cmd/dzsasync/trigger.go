@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/api"
+	"github.com/spf13/cobra"
+)
+
+// triggerPollInterval is how often --wait polls the daemon status.
+const triggerPollInterval = time.Second
+
+func newTriggerCmd() *cobra.Command {
+	var (
+		addr    string
+		wait    bool
+		timeout time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:   "trigger [port]",
+		Short: "Trigger an immediate sync on a running daemon (all servers, or one port)",
+		Long: "Ask a running daemon to sync now instead of waiting for the next interval, e.g. from a restart script " +
+			"right after the game server is back up. With --wait, block until the sync finishes and exit non-zero if it failed.",
+		Example: "  dzsa-sync trigger\n  dzsa-sync trigger 2424 --wait --timeout 2m",
+		Args:    cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			port := 0
+			path := "/api/v1/sync"
+			if len(args) == 1 {
+				p, err := strconv.Atoi(args[0])
+				if err != nil || p < 1 || p > 65535 {
+					return fmt.Errorf("invalid port %q", args[0])
+				}
+				port = p
+				path += "/" + strconv.Itoa(port)
+			}
+
+			// Second precision is enough: a sync takes at least the request round trip.
+			start := time.Now().UTC().Truncate(time.Second)
+			resp, err := apiDo(cmd, http.MethodPost, addr, path)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			if !wait {
+				fmt.Fprintln(cmd.OutOrStdout(), "sync triggered")
+				return nil
+			}
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			defer cancel()
+			cmd.SetContext(ctx)
+			return waitForSync(cmd, addr, port, start)
+		},
+	}
+	addAddrFlag(cmd, &addr)
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for the triggered sync to finish and report the result")
+	cmd.Flags().DurationVar(&timeout, "timeout", time.Minute, "Maximum time to wait with --wait")
+	return cmd
+}
+
+// waitForSync polls the daemon status until every targeted server (port, or all when port is 0) has
+// attempted a sync since start, then prints the outcome. It returns an error if any sync failed.
+func waitForSync(cmd *cobra.Command, addr string, port int, start time.Time) error {
+	ticker := time.NewTicker(triggerPollInterval)
+	defer ticker.Stop()
+	for {
+		var status api.StatusResponse
+		if err := apiGet(cmd, addr, "/api/v1/status", &status); err != nil {
+			return err
+		}
+		if done, err := reportSync(cmd.OutOrStdout(), &status, port, start); done {
+			return err
+		}
+		select {
+		case <-ticker.C:
+		case <-cmd.Context().Done():
+			return fmt.Errorf("timed out waiting for sync: %w", cmd.Context().Err())
+		}
+	}
+}
+
+// reportSync returns done once every targeted server has a sync attempt at or after start, after
+// printing one line per server. Servers in a maintenance window are skipped by the daemon, so they
+// are reported and not waited on.
+func reportSync(out io.Writer, status *api.StatusResponse, port int, start time.Time) (bool, error) {
+	var targets []api.ServerStatus
+	for _, s := range status.Servers {
+		if port == 0 || s.Port == port {
+			targets = append(targets, s)
+		}
+	}
+	for _, s := range targets {
+		if s.Maintenance == nil && (s.Sync == nil || s.Sync.LastAttempt.Before(start)) {
+			return false, nil
+		}
+	}
+
+	var failed []string
+	for _, s := range targets {
+		switch {
+		case s.Maintenance != nil:
+			fmt.Fprintf(out, "%s (%d): skipped, in maintenance\n", s.Name, s.Port)
+		case s.Sync.LastError != "":
+			fmt.Fprintf(out, "%s (%d): failed: %s\n", s.Name, s.Port, s.Sync.LastError)
+			failed = append(failed, s.Name)
+		default:
+			fmt.Fprintf(out, "%s (%d): synced, %d/%d players\n", s.Name, s.Port, s.Players, s.MaxPlayers)
+		}
+	}
+	if len(failed) > 0 {
+		return true, errors.New("sync failed for " + strconv.Itoa(len(failed)) + " server(s)")
+	}
+	return true, nil
+}
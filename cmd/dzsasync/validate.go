@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func newValidateCmd(configPath *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the configuration file and exit",
+		Long: "Validate the configuration file and exit: 0 when it is valid, 1 with every problem listed otherwise, " +
+			"e.g. in CI or before a deploy. Environment overrides are applied as for run.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cfg, err := loadConfig(*configPath)
+			if err != nil {
+				problems := configProblems(err)
+				if len(problems) < 2 {
+					return err
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "%s: %d problems:\n", *configPath, len(problems))
+				for _, p := range problems {
+					fmt.Fprintf(cmd.ErrOrStderr(), "  - %v\n", p)
+				}
+				return fmt.Errorf("%s: invalid config", *configPath)
+			}
+			if cfg.ControllerEnabled() {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s: valid (controller, %d agents)\n", *configPath, len(cfg.Controller.Agents))
+				return nil
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s: valid (%d servers)\n", *configPath, len(cfg.AllServers()))
+			return nil
+		},
+	}
+}
+
+// configProblems returns the problems config.Validate joined into err, or err alone.
+func configProblems(err error) []error {
+	var joined interface{ Unwrap() []error }
+	if errors.As(err, &joined) {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jsirianni/dzsa-sync/internal/buildinfo"
+	"github.com/spf13/cobra"
+)
+
+func newVersionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Print the version, commit, build date, and Go runtime",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			printVersion(cmd.OutOrStdout())
+		},
+	}
+}
+
+func printVersion(w io.Writer) {
+	i := buildinfo.Get()
+	fmt.Fprintf(w, "dzsa-sync %s\ncommit:   %s\nbuilt:    %s\ngo:       %s\nplatform: %s\n",
+		i.Version, orUnknown(i.Commit), orUnknown(i.Date), i.GoVersion, i.Platform)
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
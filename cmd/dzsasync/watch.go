@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/api"
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultWatchInterval = 5 * time.Second
+	// ansiClear moves the cursor home and clears the screen.
+	ansiClear = "\x1b[H\x1b[2J"
+)
+
+func newWatchCmd() *cobra.Command {
+	var (
+		addr     string
+		interval time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Live view of all servers, refreshed every few seconds",
+		Long: "Redraw a table of every server (players, map, last sync, status) from the running daemon's API " +
+			"until interrupted. When the daemon cannot be reached, the last table stays on screen with the error.",
+		Example: "  dzsa-sync watch --interval 2s",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if interval < time.Second {
+				return fmt.Errorf("invalid interval %s: must be at least 1s", interval)
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			cmd.SetContext(ctx)
+
+			out := cmd.OutOrStdout()
+			redraw := isTerminal(out)
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			var last *api.StatusResponse
+			for {
+				var status api.StatusResponse
+				err := apiGet(cmd, addr, "/api/v1/status", &status)
+				if err == nil {
+					last = &status
+				}
+				if ctx.Err() != nil {
+					return nil
+				}
+				if redraw {
+					fmt.Fprint(out, ansiClear)
+				}
+				if err := printWatch(out, addr, interval, last, err, time.Now()); err != nil {
+					return err
+				}
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-ticker.C:
+				}
+			}
+		},
+	}
+	addAddrFlag(cmd, &addr)
+	cmd.Flags().DurationVarP(&interval, "interval", "n", defaultWatchInterval, "Time between refreshes")
+	return cmd
+}
+
+// printWatch draws one frame: a header, the latest status (if any was fetched), and fetchErr when the last refresh failed.
+func printWatch(out io.Writer, addr string, interval time.Duration, status *api.StatusResponse, fetchErr error, now time.Time) error {
+	header := fmt.Sprintf("Every %s: %s", interval, addr)
+	if status != nil && status.InstanceName != "" {
+		header += " (" + status.InstanceName + ")"
+	}
+	fmt.Fprintf(out, "%s    %s\n", header, now.Local().Format(time.DateTime))
+	if status != nil {
+		ip := status.ExternalIP
+		if ip == "" {
+			ip = "(not detected)"
+		}
+		total, capacity := 0, 0
+		for _, s := range status.Servers {
+			total += s.Players
+			capacity += s.MaxPlayers
+		}
+		fmt.Fprintf(out, "External IP: %s    Players: %d/%d\n\n", ip, total, capacity)
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tPORT\tPLAYERS\tMAP\tLAST SYNC\tSTATUS")
+		for _, s := range status.Servers {
+			lastSync, state := syncSummary(s, now)
+			m := s.Map
+			if m == "" {
+				m = "-"
+			}
+			fmt.Fprintf(w, "%s\t%d\t%d/%d\t%s\t%s\t%s\n", s.Name, s.Port, s.Players, s.MaxPlayers, m, lastSync, state)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	if fetchErr != nil {
+		fmt.Fprintf(out, "\nrefresh failed: %v\n", fetchErr)
+	}
+	return nil
+}
@@ -1,9 +1,13 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"testing"
+	"time"
 )
 
 func TestConfig_Validate(t *testing.T) {
@@ -44,32 +48,967 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid log_level and log_format",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				LogLevel:  "warn",
+				LogFormat: LogFormatConsole,
+				DetectIP:  true,
+				Servers:   []Server{{Name: "main", Port: 2424}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid log_level",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				LogLevel: "trace",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid log_format",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				LogFormat: "text",
+				DetectIP:  true,
+				Servers:   []Server{{Name: "main", Port: 2424}},
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid detect_ip false without external_ip",
 			c: Config{
 				LogPath:    "/var/log/dzsa-sync/dzsa-sync.log",
-				DetectIP:   false,
-				ExternalIP: "",
+				DetectIP:   false,
+				ExternalIP: "",
+				Servers:    []Server{{Name: "main", Port: 2424}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid empty servers",
+			c: Config{
+				LogPath:    "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:   true,
+				ExternalIP: "",
+				Servers:    nil,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid empty servers slice",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid empty servers with docker discovery",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:  true,
+				Discovery: &DiscoveryConfig{Docker: &DockerDiscoveryConfig{Enabled: true}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid empty servers with docker discovery disabled",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:  true,
+				Discovery: &DiscoveryConfig{Docker: &DockerDiscoveryConfig{Enabled: false}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid hooks",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Hooks: []Hook{
+					{Name: "restart", Token: "secret", Action: HookActionMaintenance, Port: 2424},
+					{Name: "started", Token: "secret", Action: HookActionSync},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid hook action",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Hooks:    []Hook{{Name: "restart", Token: "secret", Action: "reboot"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid hook without token",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Hooks:    []Hook{{Name: "restart", Action: HookActionSync}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid remote_write without url",
+			c: Config{
+				LogPath:     "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:    true,
+				Servers:     []Server{{Name: "main", Port: 2424}},
+				RemoteWrite: &RemoteWriteConfig{Enabled: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid ha without lease file",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				HA:       &HAConfig{Enabled: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid negative http limit",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				HTTP:     &HTTPConfig{MaxIdleConnsPerHost: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid negative shutdown timeout",
+			c: Config{
+				LogPath:         "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:        true,
+				Servers:         []Server{{Name: "main", Port: 2424}},
+				ShutdownTimeout: -time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid sync intervals",
+			c: Config{
+				LogPath:      "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:     true,
+				Servers:      []Server{{Name: "main", Port: 2424, SyncInterval: 15 * time.Minute}},
+				SyncInterval: 2 * time.Hour,
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid short sync interval",
+			c: Config{
+				LogPath:      "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:     true,
+				Servers:      []Server{{Name: "main", Port: 2424}},
+				SyncInterval: time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid short server sync interval",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424, SyncInterval: -time.Minute}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid exec hook",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:  true,
+				Servers:   []Server{{Name: "main", Port: 2424}},
+				ExecHooks: []ExecHook{{Name: "page", Events: []string{ExecEventServerOffline, ExecEventIPChange}, Command: "/usr/local/bin/page"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid exec hook event",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:  true,
+				Servers:   []Server{{Name: "main", Port: 2424}},
+				ExecHooks: []ExecHook{{Name: "page", Events: []string{"on-server-offline"}, Command: "/usr/local/bin/page"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid exec hook without command",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:  true,
+				Servers:   []Server{{Name: "main", Port: 2424}},
+				ExecHooks: []ExecHook{{Name: "page", Events: []string{ExecEventSyncFailure}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid webhook",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Webhooks: []Webhook{{Name: "status", Events: []string{ExecEventServerChange}, URL: "https://status.example.com/hook", Method: "PUT", Body: `{"server": {{json .Server}}}`}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid webhook body template",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Webhooks: []Webhook{{Name: "status", Events: []string{ExecEventSyncFailure}, URL: "https://status.example.com/hook", Body: `{{.Server`}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid webhook method",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Webhooks: []Webhook{{Name: "status", Events: []string{ExecEventSyncFailure}, URL: "https://status.example.com/hook", Method: "GET"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid advertised endpoint",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424, AdvertiseIP: "198.51.100.20", AdvertisePort: 40424}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid advertise ip",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424, AdvertiseIP: "relay.example.com"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid advertise port on monitor only server",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "fav", Port: 2424, MonitorOnly: true, IP: "198.51.100.9", AdvertisePort: 40424}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid ipv6 server with detect_ipv6",
+			c: Config{
+				LogPath:    "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:   true,
+				DetectIPv6: true,
+				Servers:    []Server{{Name: "main", Port: 2424}, {Name: "v6", Port: 2524, AddressFamily: AddressFamilyIPv6}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid ipv6 server with external_ipv6",
+			c: Config{
+				LogPath:      "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:     true,
+				ExternalIPv6: "2001:db8::10",
+				Servers:      []Server{{Name: "v6", Port: 2424, AddressFamily: AddressFamilyIPv6}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid ipv6 server without an ipv6 source",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "v6", Port: 2424, AddressFamily: AddressFamilyIPv6}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid external_ipv6 with an ipv4 address",
+			c: Config{
+				LogPath:      "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:     true,
+				ExternalIPv6: "203.0.113.10",
+				Servers:      []Server{{Name: "main", Port: 2424}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid address_family",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424, AddressFamily: "inet6"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid ipv6 server on a host",
+			c: Config{
+				LogPath:    "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:   true,
+				DetectIPv6: true,
+				Servers:    []Server{{Name: "main", Port: 2424}},
+				Hosts:      []Host{{Name: "box-2", ExternalIP: "203.0.113.20", Servers: []Server{{Name: "v6", Port: 2524, AddressFamily: AddressFamilyIPv6}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid metrics otlp",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Metrics:  &MetricsConfig{OTLP: &OTLPConfig{Enabled: true, Protocol: OTLPProtocolGRPC, Endpoint: "http://otel-collector:4317"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid metrics otlp without endpoint",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Metrics:  &MetricsConfig{OTLP: &OTLPConfig{Enabled: true}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid metrics otlp endpoint without scheme",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Metrics:  &MetricsConfig{OTLP: &OTLPConfig{Enabled: true, Endpoint: "otel-collector:4318"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid metrics otlp protocol",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Metrics:  &MetricsConfig{OTLP: &OTLPConfig{Enabled: true, Protocol: "http/json", Endpoint: "http://otel-collector:4318"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid created timestamps without openmetrics",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Metrics:  &MetricsConfig{CreatedTimestamps: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid DNS servers",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				HTTP:     &HTTPConfig{DNSServers: []string{"1.1.1.1", "[2606:4700::1111]:53", "9.9.9.9:5353"}, DNSOverHTTPS: "https://1.1.1.1/dns-query"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid DNS server hostname",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				HTTP:     &HTTPConfig{DNSServers: []string{"dns.example.com"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid DNS over HTTPS without https",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				HTTP:     &HTTPConfig{DNSOverHTTPS: "http://1.1.1.1/dns-query"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid DNS servers without the DNS cache",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				HTTP:     &HTTPConfig{DisableDNSCache: true, DNSServers: []string{"1.1.1.1"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid negative retry budget",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Retry:    &RetryConfig{Attempts: 3, Budget: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid non-positive retry failure schedule",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Retry:    &RetryConfig{FailureSchedule: []time.Duration{time.Minute, 0}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid cloudflare dns",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				DNS:      &DNSConfig{Cloudflare: &CloudflareDNSConfig{ZoneID: "zone1", Record: "play.example.com", APIToken: "secret", TTL: 300}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid cloudflare dns without a token",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				DNS:      &DNSConfig{Cloudflare: &CloudflareDNSConfig{ZoneID: "zone1", Record: "play.example.com"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid cloudflare dns ttl",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				DNS:      &DNSConfig{Cloudflare: &CloudflareDNSConfig{ZoneID: "zone1", Record: "play.example.com", APIToken: "secret", TTL: 30}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid ip flap damping",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				IPFlap:   &IPFlapConfig{Window: 2 * time.Hour, Changes: 4, HoldDown: time.Hour},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid ip flap changes",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				IPFlap:   &IPFlapConfig{Changes: 1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid dzsa pin",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				HTTP:     &HTTPConfig{DZSAPins: []string{"sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid dzsa pin",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				HTTP:     &HTTPConfig{DZSAPins: []string{"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid privacy truncate",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Privacy:  &PrivacyConfig{RedactIPs: RedactTruncate, RedactServerIP: true},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid privacy mode",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Privacy:  &PrivacyConfig{RedactIPs: "mask"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid feed template without path",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Feed:     &FeedConfig{Template: "/etc/dzsa-sync/feed.tmpl"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid remote discovery without servers",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:  true,
+				Discovery: &DiscoveryConfig{Remote: &RemoteDiscoveryConfig{Enabled: true, URL: "https://control.example.com/servers.json"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid remote discovery without url",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:  true,
+				Discovery: &DiscoveryConfig{Remote: &RemoteDiscoveryConfig{Enabled: true}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid serverdz discovery without paths or processes",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:  true,
+				Discovery: &DiscoveryConfig{ServerDZ: &ServerDZDiscoveryConfig{Enabled: true}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid postgres history without dsn",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				History:  &HistoryConfig{Postgres: &PostgresHistoryConfig{Enabled: true}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid history retention",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				History: &HistoryConfig{
+					Postgres: &PostgresHistoryConfig{Enabled: true, DSN: "postgres://localhost/dzsa", Retention: 720 * time.Hour},
+					SQLite:   &SQLiteHistoryConfig{Enabled: true, Retention: 72 * time.Hour, HourlyRetention: 4320 * time.Hour},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid negative hourly retention",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				History:  &HistoryConfig{Postgres: &PostgresHistoryConfig{Enabled: true, DSN: "postgres://localhost/dzsa", HourlyRetention: -time.Hour}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid trusted proxies",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				API:      &APIConfig{TrustedProxies: []string{"127.0.0.1", "10.0.0.0/8", "::1"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid trusted proxy",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				API:      &APIConfig{TrustedProxies: []string{"proxy.local"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid hosts",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Hosts: []Host{
+					{Name: "box-2", ExternalIP: "203.0.113.20", Servers: []Server{{Name: "modded", Port: 2524}}},
+					{Name: "box-3", Hostname: "box-3.example.com", Servers: []Server{{Name: "test", Port: 2624}}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid hosts without an instance IP",
+			c: Config{
+				LogPath: "/var/log/dzsa-sync/dzsa-sync.log",
+				Hosts:   []Host{{Name: "box-2", ExternalIP: "203.0.113.20", Servers: []Server{{Name: "modded", Port: 2524}}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid host without an IP",
+			c: Config{
+				LogPath: "/var/log/dzsa-sync/dzsa-sync.log",
+				Hosts:   []Host{{Name: "box-2", Servers: []Server{{Name: "modded", Port: 2524}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid host with both IP sources",
+			c: Config{
+				LogPath: "/var/log/dzsa-sync/dzsa-sync.log",
+				Hosts:   []Host{{Name: "box-2", ExternalIP: "203.0.113.20", Hostname: "box-2.example.com", Servers: []Server{{Name: "modded", Port: 2524}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid host external IP",
+			c: Config{
+				LogPath: "/var/log/dzsa-sync/dzsa-sync.log",
+				Hosts:   []Host{{Name: "box-2", ExternalIP: "box-2", Servers: []Server{{Name: "modded", Port: 2524}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid port shared across hosts",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Hosts:    []Host{{Name: "box-2", ExternalIP: "203.0.113.20", Servers: []Server{{Name: "main", Port: 2424}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid top-level servers without an instance IP",
+			c: Config{
+				LogPath: "/var/log/dzsa-sync/dzsa-sync.log",
+				Servers: []Server{{Name: "main", Port: 2424}},
+				Hosts:   []Host{{Name: "box-2", ExternalIP: "203.0.113.20", Servers: []Server{{Name: "modded", Port: 2524}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid admin API",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				API:      &APIConfig{Port: 8888, Admin: &AdminAPIConfig{Host: "127.0.0.1", Port: 8889, Token: "secret"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid API listeners",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				API: &APIConfig{Listeners: []ListenerConfig{
+					{Port: 9090, Routes: RoutesMetrics},
+					{Socket: "/run/dzsa-sync/admin.sock", Routes: RoutesAdmin},
+					{Host: "127.0.0.1", Port: 8890},
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid API listener with port and socket",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				API:      &APIConfig{Listeners: []ListenerConfig{{Port: 9090, Socket: "/run/dzsa-sync/api.sock"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid API listener routes",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				API:      &APIConfig{Listeners: []ListenerConfig{{Port: 9090, Routes: "public"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid admin API without token",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				API:      &APIConfig{Admin: &AdminAPIConfig{Port: 8889}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid admin API on the API port",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				API:      &APIConfig{Port: 8889, Admin: &AdminAPIConfig{Port: 8889, Token: "secret"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid rules",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:  true,
+				Servers:   []Server{{Name: "main", Port: 2424}},
+				Notifiers: []Notifier{{Name: "ops", Type: "discord", URL: "https://discord.com/api/webhooks/1/abc"}},
+				Rules: []Rule{
+					{Name: "empty", When: "players == 0", For: 2 * time.Hour, Servers: []string{"main"}, Notify: []string{"ops"}},
+					{Name: "update", When: "version changed", Notify: []string{"ops"}},
+					{Name: "prime-time", When: "online == false", During: "18:00-23:00", Days: []string{"fri", "sat"}, Timezone: "UTC", Notify: []string{"ops"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid rule condition",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:  true,
+				Servers:   []Server{{Name: "main", Port: 2424}},
+				Notifiers: []Notifier{{Name: "ops", Type: "slack", URL: "https://hooks.slack.com/services/x"}},
+				Rules:     []Rule{{Name: "empty", When: "map > 3", Notify: []string{"ops"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid rule with unknown notifier",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Rules:    []Rule{{Name: "empty", When: "players == 0", Notify: []string{"ops"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid rule with for on a change",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:  true,
+				Servers:   []Server{{Name: "main", Port: 2424}},
+				Notifiers: []Notifier{{Name: "ops", Type: "webhook", URL: "https://example.com/hook"}},
+				Rules:     []Rule{{Name: "update", When: "version changed", For: time.Minute, Notify: []string{"ops"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid rule on last week's players",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:  true,
+				Servers:   []Server{{Name: "main", Port: 2424}},
+				History:   &HistoryConfig{SQLite: &SQLiteHistoryConfig{Enabled: true}},
+				Notifiers: []Notifier{{Name: "ops", Type: "discord", URL: "https://discord.com/api/webhooks/1/abc"}},
+				Rules:     []Rule{{Name: "drop", When: "last_week_change <= -50 and last_week_players >= 10", Notify: []string{"ops"}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid rule on last week's players without history",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:  true,
+				Servers:   []Server{{Name: "main", Port: 2424}},
+				Notifiers: []Notifier{{Name: "ops", Type: "discord", URL: "https://discord.com/api/webhooks/1/abc"}},
+				Rules:     []Rule{{Name: "drop", When: "last_week_change <= -50", Notify: []string{"ops"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid notifier type",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:  true,
+				Servers:   []Server{{Name: "main", Port: 2424}},
+				Notifiers: []Notifier{{Name: "ops", Type: "pager", URL: "https://example.com/hook"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid email notifier and reports",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:  true,
+				Servers:   []Server{{Name: "main", Port: 2424}},
+				History:   &HistoryConfig{SQLite: &SQLiteHistoryConfig{Enabled: true}},
+				Notifiers: []Notifier{{Name: "mail", Type: "email", SMTP: &SMTPConfig{Host: "smtp.example.com", From: "dzsa@example.com", To: []string{"ops@example.com"}}}},
+				Reports: []Report{
+					{Name: "daily", Schedule: "daily", At: "08:00", Timezone: "UTC", Notify: []string{"mail"}},
+					{Name: "weekly", Schedule: "weekly", Day: "mon", Servers: []string{"main"}, Notify: []string{"mail"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid email notifier without smtp",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:  true,
+				Servers:   []Server{{Name: "main", Port: 2424}},
+				Notifiers: []Notifier{{Name: "mail", Type: "email"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid email notifier recipient",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:  true,
+				Servers:   []Server{{Name: "main", Port: 2424}},
+				Notifiers: []Notifier{{Name: "mail", Type: "email", SMTP: &SMTPConfig{Host: "smtp.example.com", From: "dzsa@example.com", To: []string{"not an address"}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid smtp on a webhook notifier",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:  true,
+				Servers:   []Server{{Name: "main", Port: 2424}},
+				Notifiers: []Notifier{{Name: "ops", Type: "webhook", URL: "https://example.com/hook", SMTP: &SMTPConfig{Host: "smtp.example.com"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid report without history",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:  true,
+				Servers:   []Server{{Name: "main", Port: 2424}},
+				Notifiers: []Notifier{{Name: "mail", Type: "email", SMTP: &SMTPConfig{Host: "smtp.example.com", From: "dzsa@example.com", To: []string{"ops@example.com"}}}},
+				Reports:   []Report{{Name: "daily", Schedule: "daily", Notify: []string{"mail"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid report schedule",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:  true,
+				Servers:   []Server{{Name: "main", Port: 2424}},
+				History:   &HistoryConfig{SQLite: &SQLiteHistoryConfig{Enabled: true}},
+				Notifiers: []Notifier{{Name: "mail", Type: "email", SMTP: &SMTPConfig{Host: "smtp.example.com", From: "dzsa@example.com", To: []string{"ops@example.com"}}}},
+				Reports:   []Report{{Name: "daily", Schedule: "daily", Day: "mon", Notify: []string{"mail"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid report with unknown notifier",
+			c: Config{
+				LogPath:   "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:  true,
+				Servers:   []Server{{Name: "main", Port: 2424}},
+				History:   &HistoryConfig{SQLite: &SQLiteHistoryConfig{Enabled: true}},
+				Notifiers: []Notifier{{Name: "mail", Type: "email", SMTP: &SMTPConfig{Host: "smtp.example.com", From: "dzsa@example.com", To: []string{"ops@example.com"}}}},
+				Reports:   []Report{{Name: "daily", Schedule: "daily", Notify: []string{"ops"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid report with embedded notifier and history",
+			c: Config{
+				LogPath:           "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:          true,
+				Servers:           []Server{{Name: "main", Port: 2424}},
+				Reports:           []Report{{Name: "daily", Schedule: "daily", Notify: []string{"ops"}}},
+				EmbeddedNotifiers: []string{"ops"},
+				EmbeddedHistory:   true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid controller",
+			c: Config{
+				LogPath: "/var/log/dzsa-sync/dzsa-sync.log",
+				Controller: &ControllerConfig{Enabled: true, Agents: []Agent{
+					{Name: "box-1", URL: "http://box-1:8888"},
+					{Name: "box-2", URL: "https://box-2.example.com/dzsa/"},
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid controller with servers",
+			c: Config{
+				LogPath:    "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:   true,
 				Servers:    []Server{{Name: "main", Port: 2424}},
+				Controller: &ControllerConfig{Enabled: true, Agents: []Agent{{Name: "box-1", URL: "http://box-1:8888"}}},
 			},
 			wantErr: true,
 		},
 		{
-			name: "invalid empty servers",
+			name: "invalid controller without agents",
 			c: Config{
 				LogPath:    "/var/log/dzsa-sync/dzsa-sync.log",
-				DetectIP:   true,
-				ExternalIP: "",
-				Servers:    nil,
+				Controller: &ControllerConfig{Enabled: true},
 			},
 			wantErr: true,
 		},
 		{
-			name: "invalid empty servers slice",
+			name: "invalid controller agent URL",
 			c: Config{
-				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
-				DetectIP: true,
-				Servers:  []Server{},
+				LogPath:    "/var/log/dzsa-sync/dzsa-sync.log",
+				Controller: &ControllerConfig{Enabled: true, Agents: []Agent{{Name: "box-1", URL: "box-1:8888"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid duplicate agent",
+			c: Config{
+				LogPath: "/var/log/dzsa-sync/dzsa-sync.log",
+				Controller: &ControllerConfig{Enabled: true, Agents: []Agent{
+					{Name: "box-1", URL: "http://box-1:8888"},
+					{Name: "box-1", URL: "http://box-2:8888"},
+				}},
 			},
 			wantErr: true,
 		},
@@ -104,6 +1043,200 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid query_port auto",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", GamePort: 2302, QueryPort: QueryPortAuto}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid query_port auto without game_port",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", QueryPort: QueryPortAuto}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid query_port auto with port",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424, GamePort: 2302, QueryPort: QueryPortAuto}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid monitor_only without external_ip",
+			c: Config{
+				LogPath: "/var/log/dzsa-sync/dzsa-sync.log",
+				Servers: []Server{{Name: "community", Port: 2424, MonitorOnly: true, IP: "198.51.100.7"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid monitor_only without ip",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "community", Port: 2424, MonitorOnly: true}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid ip without monitor_only",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424, IP: "198.51.100.7"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid own server without external_ip beside monitor_only",
+			c: Config{
+				LogPath: "/var/log/dzsa-sync/dzsa-sync.log",
+				Servers: []Server{
+					{Name: "main", Port: 2324},
+					{Name: "community", Port: 2424, MonitorOnly: true, IP: "198.51.100.7"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid monitor_only under hosts",
+			c: Config{
+				LogPath: "/var/log/dzsa-sync/dzsa-sync.log",
+				Hosts: []Host{{Name: "box-2", ExternalIP: "203.0.113.20", Servers: []Server{
+					{Name: "community", Port: 2424, MonitorOnly: true, IP: "198.51.100.7"},
+				}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid staging url",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Staging:  &StagingConfig{URL: "http://localhost:9000/api/v1/query"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid staging dry_run",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Staging:  &StagingConfig{DryRun: true},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid staging url and dry_run",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Staging:  &StagingConfig{URL: "http://localhost:9000/api/v1/query", DryRun: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid staging empty",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Staging:  &StagingConfig{},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid staging url scheme",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424}},
+				Staging:  &StagingConfig{URL: "localhost:9000"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid query_port value",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", Port: 2424, QueryPort: "2424"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid query_port auto game_port out of range",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers:  []Server{{Name: "main", GamePort: 50000, QueryPort: QueryPortAuto}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid query_port auto duplicate derived port",
+			c: Config{
+				LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP: true,
+				Servers: []Server{
+					{Name: "main", Port: 27016},
+					{Name: "modded", GamePort: 2302, QueryPort: QueryPortAuto},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid server_logs",
+			c: Config{
+				LogPath:    "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:   true,
+				Servers:    []Server{{Name: "main", Port: 2424}, {Name: "test", Port: 2524}},
+				ServerLogs: &ServerLogsConfig{Path: "/var/log/dzsa-sync/servers/{{ .Name }}.log"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid server_logs shared file",
+			c: Config{
+				LogPath:    "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:   true,
+				Servers:    []Server{{Name: "main", Port: 2424}, {Name: "test", Port: 2524}},
+				ServerLogs: &ServerLogsConfig{Path: "/var/log/dzsa-sync/servers/{{ .Host }}.log"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid server_logs template",
+			c: Config{
+				LogPath:    "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:   true,
+				Servers:    []Server{{Name: "main", Port: 2424}},
+				ServerLogs: &ServerLogsConfig{Path: "/var/log/dzsa-sync/{{ .Owner }}.log"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid server_logs empty path",
+			c: Config{
+				LogPath:    "/var/log/dzsa-sync/dzsa-sync.log",
+				DetectIP:   true,
+				Servers:    []Server{{Name: "main", Port: 2424}},
+				ServerLogs: &ServerLogsConfig{},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -115,6 +1248,57 @@ func TestConfig_Validate(t *testing.T) {
 	}
 }
 
+func TestConfig_Validate_AllProblems(t *testing.T) {
+	c := Config{
+		DetectIP: true,
+		Servers:  []Server{{Name: "main", Port: 2424}},
+		Retry:    &RetryConfig{Attempts: -1},
+		IPFlap:   &IPFlapConfig{Changes: 1},
+	}
+	err := c.Validate()
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) || len(joined.Unwrap()) != 3 {
+		t.Fatalf("Validate() = %v, want log_path, retry, and ip_flap problems", err)
+	}
+}
+
+func TestConfig_Validate_QueryPortAuto(t *testing.T) {
+	c := Config{
+		LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+		DetectIP: true,
+		Servers:  []Server{{Name: "main", GamePort: 2302, QueryPort: QueryPortAuto}},
+		Hosts:    []Host{{Name: "box-2", ExternalIP: "203.0.113.20", Servers: []Server{{Name: "modded", GamePort: 2402, QueryPort: QueryPortAuto}}}},
+	}
+	for range 2 {
+		if err := c.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+	}
+	if got := c.Servers[0].Port; got != 27016 {
+		t.Errorf("derived port = %d, want 27016", got)
+	}
+	if got := c.Hosts[0].Servers[0].Port; got != 27116 {
+		t.Errorf("derived host server port = %d, want 27116", got)
+	}
+	if got := QueryPortCandidates(2402); !reflect.DeepEqual(got, []int{27116, 27016}) {
+		t.Errorf("QueryPortCandidates(2402) = %v", got)
+	}
+}
+
+func TestConfig_AllServers(t *testing.T) {
+	c := Config{
+		Servers: []Server{{Name: "main", Port: 2424}},
+		Hosts:   []Host{{Name: "box-2", ExternalIP: "203.0.113.20", Servers: []Server{{Name: "modded", Port: 2524}}}},
+	}
+	want := []Server{{Name: "main", Port: 2424}, {Name: "modded", Port: 2524, Host: "box-2"}}
+	if got := c.AllServers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("AllServers() = %+v, want %+v", got, want)
+	}
+	if c.Hosts[0].Servers[0].Host != "" {
+		t.Error("AllServers() modified the config")
+	}
+}
+
 func TestNewFromFile(t *testing.T) {
 	dir := t.TempDir()
 
@@ -204,3 +1388,40 @@ func TestNewFromFile_Validation(t *testing.T) {
 		}
 	})
 }
+
+func TestParse_FailureSchedule(t *testing.T) {
+	base := "log_path: /var/log/dzsa-sync/dzsa-sync.log\ndetect_ip: true\nservers:\n  - name: main\n    port: 2424\n"
+	c, err := Parse([]byte(base + "retry:\n  failure_schedule: [30s, 2m]\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if want := []time.Duration{30 * time.Second, 2 * time.Minute}; !slices.Equal(c.Retry.FailureSchedule, want) {
+		t.Errorf("failure_schedule = %v, want %v", c.Retry.FailureSchedule, want)
+	}
+	// An empty list turns the schedule off, so it must not read as unset.
+	c, err = Parse([]byte(base + "retry:\n  failure_schedule: []\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if c.Retry.FailureSchedule == nil {
+		t.Error("failure_schedule: [] parsed as unset")
+	}
+}
+
+func TestServerLogPath(t *testing.T) {
+	tests := []struct {
+		srv  Server
+		want string
+	}{
+		{Server{Name: "main", Port: 2424}, "/var/log/dzsa/main-2424.log"},
+		{Server{Name: "../../etc/passwd", Port: 2424}, "/var/log/dzsa/.._.._etc_passwd-2424.log"},
+		{Server{Name: "..", Port: 2424}, "/var/log/dzsa/__-2424.log"},
+		{Server{Name: "Chernarus PvE #1", Port: 2524}, "/var/log/dzsa/Chernarus_PvE__1-2524.log"},
+	}
+	for _, tt := range tests {
+		got, err := ServerLogPath("/var/log/dzsa/{{ .Name }}-{{ .Port }}.log", tt.srv)
+		if err != nil || got != tt.want {
+			t.Errorf("ServerLogPath(%q) = %q, %v, want %q", tt.srv.Name, got, err, tt.want)
+		}
+	}
+}
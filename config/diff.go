@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Change is a setting that differs between two configs.
+type Change struct {
+	// Path is the setting's YAML path, e.g. "api.port" or "servers[1].name".
+	Path string `json:"path"`
+	// Old and New are the setting's values, nil where it is not set. Secrets are Redacted.
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// Diff returns the settings that differ from c to other, sorted by path. Both configs are compared as they
+// are marshaled, so formatting and comments do not count; lists are compared by index. A changed secret is
+// reported with both values Redacted.
+func Diff(c, other *Config) ([]Change, error) {
+	oldRaw, oldShown, err := flattenConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	newRaw, newShown, err := flattenConfig(other)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for path, v := range oldRaw {
+		if nv, ok := newRaw[path]; !ok || nv != v {
+			changes = append(changes, Change{Path: path, Old: oldShown[path], New: newShown[path]})
+		}
+	}
+	for path := range newRaw {
+		if _, ok := oldRaw[path]; !ok {
+			changes = append(changes, Change{Path: path, New: newShown[path]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// flattenConfig returns the scalar settings of c by YAML path, as marshaled and as redacted.
+func flattenConfig(c *Config) (raw, shown map[string]any, err error) {
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshal config: %w", err)
+	}
+	redacted, err := Redact(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	raw, shown = make(map[string]any), make(map[string]any)
+	for _, f := range []struct {
+		b   []byte
+		out map[string]any
+	}{{b, raw}, {redacted, shown}} {
+		var doc any
+		if err := yaml.Unmarshal(f.b, &doc); err != nil {
+			return nil, nil, fmt.Errorf("parse config: %w", err)
+		}
+		flatten("", doc, f.out)
+	}
+	return raw, shown, nil
+}
+
+// flatten adds the scalars under v to out by path. Zero values are left out, as are empty maps and lists, so
+// an omitted setting and one set to its zero value compare equal.
+func flatten(path string, v any, out map[string]any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			flatten(p, child, out)
+		}
+	case []any:
+		for i, child := range v {
+			flatten(path+"["+strconv.Itoa(i)+"]", child, out)
+		}
+	default:
+		// Durations marshal as strings, so a zero one is "0s".
+		if v != nil && !reflect.ValueOf(v).IsZero() && v != "0s" {
+			out[path] = v
+		}
+	}
+}
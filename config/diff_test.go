@@ -0,0 +1,47 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	applied := &Config{
+		LogPath:    "stdout",
+		ExternalIP: "203.0.113.10",
+		Servers:    []Server{{Name: "main", Port: 2424}},
+		Hooks:      []Hook{{Name: "restart", Token: "old-token", Action: "sync"}},
+		Notifiers:  []Notifier{{Name: "discord", Type: "discord", URL: "https://discord.com/api/webhooks/1/old-secret"}},
+		Webhooks:   []Webhook{{Name: "ci", Events: []string{"post_sync_failure"}, URL: "https://ci.example.com/hook?token=old"}},
+	}
+	onDisk := &Config{
+		LogPath:    "stdout",
+		ExternalIP: "203.0.113.10",
+		Servers:    []Server{{Name: "main", Port: 2425}, {Name: "test", Port: 2524}},
+		Hooks:      []Hook{{Name: "restart", Token: "new-token", Action: "sync"}},
+		Notifiers:  []Notifier{{Name: "discord", Type: "discord", URL: "https://discord.com/api/webhooks/1/new-secret"}},
+		Webhooks:   []Webhook{{Name: "ci", Events: []string{"post_sync_failure"}, URL: "https://ci.example.com/hook?token=new"}},
+		API:        &APIConfig{Port: 9000},
+	}
+	got, err := Diff(applied, onDisk)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	want := []Change{
+		{Path: "api.port", New: 9000},
+		{Path: "hooks[0].token", Old: Redacted, New: Redacted},
+		{Path: "notifiers[0].url", Old: "https://discord.com/" + Redacted, New: "https://discord.com/" + Redacted},
+		{Path: "servers[0].port", Old: 2424, New: 2425},
+		{Path: "servers[1].name", New: "test"},
+		{Path: "servers[1].port", New: 2524},
+		{Path: "webhooks[0].url", Old: "https://ci.example.com/" + Redacted, New: "https://ci.example.com/" + Redacted},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %+v, want %+v", got, want)
+	}
+
+	got, err = Diff(applied, applied)
+	if err != nil || len(got) != 0 {
+		t.Errorf("Diff() of a config with itself = %+v, %v", got, err)
+	}
+}
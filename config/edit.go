@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AppendServer adds srv to the servers list of a config file's contents and returns the rewritten YAML.
+// Comments and key order are preserved, and only the fields srv sets are written.
+func AppendServer(b []byte, srv Server) ([]byte, error) {
+	doc, root, err := parseDocument(b)
+	if err != nil {
+		return nil, err
+	}
+	var entry yaml.Node
+	if err := entry.Encode(srv); err != nil {
+		return nil, fmt.Errorf("encode server: %w", err)
+	}
+	// Keep the entry as short as a hand-written one: name and port, and whatever else is set.
+	content := entry.Content[:0]
+	for i := 0; i+1 < len(entry.Content); i += 2 {
+		key, value := entry.Content[i], entry.Content[i+1]
+		if key.Value != "name" && key.Value != "port" && isZeroScalar(value) {
+			continue
+		}
+		content = append(content, key, value)
+	}
+	entry.Content = content
+
+	if i := mappingIndex(root, "servers"); i >= 0 && root.Content[i+1].Kind == yaml.SequenceNode {
+		servers := root.Content[i+1]
+		servers.Content = append(servers.Content, &entry)
+		servers.Style = 0
+	} else if i >= 0 {
+		// An empty servers key (servers: or servers: []) becomes the list.
+		root.Content[i+1] = &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{&entry}}
+	} else {
+		root.Content = append(root.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: "servers"},
+			&yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{&entry}},
+		)
+	}
+	return encodeDocument(doc)
+}
+
+// DeleteServer removes the server with query port port from the servers list, or a hosts entry's servers, of a
+// config file's contents, and returns the rewritten YAML. It returns false when no server has the port.
+func DeleteServer(b []byte, port int) ([]byte, bool, error) {
+	doc, root, err := parseDocument(b)
+	if err != nil {
+		return nil, false, err
+	}
+	lists := []*yaml.Node{}
+	if i := mappingIndex(root, "servers"); i >= 0 {
+		lists = append(lists, root.Content[i+1])
+	}
+	if i := mappingIndex(root, "hosts"); i >= 0 && root.Content[i+1].Kind == yaml.SequenceNode {
+		for _, h := range root.Content[i+1].Content {
+			if j := mappingIndex(h, "servers"); h.Kind == yaml.MappingNode && j >= 0 {
+				lists = append(lists, h.Content[j+1])
+			}
+		}
+	}
+	for _, list := range lists {
+		if list.Kind != yaml.SequenceNode {
+			continue
+		}
+		for i, item := range list.Content {
+			var s Server
+			if err := item.Decode(&s); err != nil {
+				return nil, false, fmt.Errorf("line %d: decode server: %w", item.Line, err)
+			}
+			if s.Port == 0 && s.QueryPort == QueryPortAuto {
+				s.Port = DeriveQueryPort(s.GamePort)
+			}
+			if s.Port != port {
+				continue
+			}
+			list.Content = append(list.Content[:i], list.Content[i+1:]...)
+			out, err := encodeDocument(doc)
+			return out, err == nil, err
+		}
+	}
+	return b, false, nil
+}
+
+// parseDocument parses a config file's contents, which must be a mapping, into a document node and its root.
+func parseDocument(b []byte) (*yaml.Node, *yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, nil, fmt.Errorf("parse yaml: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("line %d: config must be a mapping", root.Line)
+	}
+	return &doc, root, nil
+}
+
+// isZeroScalar reports whether n is a scalar with a zero value, as encoded for an unset field.
+func isZeroScalar(n *yaml.Node) bool {
+	if n.Kind != yaml.ScalarNode {
+		return false
+	}
+	switch n.Value {
+	case "", "false", "0s":
+		return true
+	}
+	i, err := strconv.Atoi(n.Value)
+	return err == nil && i == 0 && n.Tag == "!!int"
+}
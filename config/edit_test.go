@@ -0,0 +1,69 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestAppendServer(t *testing.T) {
+	in := "detect_ip: true\n# game servers\nservers:\n  - name: main\n    port: 2424\nlog_path: /var/log/dzsa-sync/dzsa-sync.log\n"
+	out, err := AppendServer([]byte(in), Server{Name: "modded", Port: 2324, SyncInterval: 15 * time.Minute})
+	if err != nil {
+		t.Fatalf("AppendServer() error = %v", err)
+	}
+	want := "detect_ip: true\n# game servers\nservers:\n  - name: main\n    port: 2424\n  - name: modded\n    port: 2324\n    sync_interval: 15m0s\nlog_path: /var/log/dzsa-sync/dzsa-sync.log\n"
+	if string(out) != want {
+		t.Errorf("AppendServer() =\n%s\nwant\n%s", out, want)
+	}
+
+	for _, in := range []string{"detect_ip: true\n", "servers: []\n", "servers:\n"} {
+		out, err := AppendServer([]byte(in), Server{Name: "main", Port: 2424})
+		if err != nil {
+			t.Fatalf("AppendServer(%q) error = %v", in, err)
+		}
+		var cfg Config
+		if err := yaml.Unmarshal(out, &cfg); err != nil {
+			t.Fatal(err)
+		}
+		if len(cfg.Servers) != 1 || cfg.Servers[0] != (Server{Name: "main", Port: 2424}) {
+			t.Errorf("AppendServer(%q) servers = %+v", in, cfg.Servers)
+		}
+	}
+}
+
+func TestDeleteServer(t *testing.T) {
+	in := "servers:\n  - name: main # the vanilla server\n    port: 2424\n  - name: auto\n    game_port: 2402\n    query_port: auto\n" +
+		"hosts:\n  - name: box-2\n    external_ip: 203.0.113.20\n    servers:\n      - name: remote\n        port: 2524\n"
+	for _, tt := range []struct {
+		port      int
+		wantNames []string
+	}{
+		{port: 2424, wantNames: []string{"auto", "remote"}},
+		{port: 27116, wantNames: []string{"main", "remote"}},
+		{port: 2524, wantNames: []string{"main", "auto"}},
+	} {
+		out, ok, err := DeleteServer([]byte(in), tt.port)
+		if err != nil || !ok {
+			t.Fatalf("DeleteServer(%d) = %v, %v", tt.port, ok, err)
+		}
+		var cfg Config
+		if err := yaml.Unmarshal(out, &cfg); err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, s := range cfg.AllServers() {
+			names = append(names, s.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(tt.wantNames, ",") {
+			t.Errorf("DeleteServer(%d) servers = %v, want %v", tt.port, names, tt.wantNames)
+		}
+	}
+
+	out, ok, err := DeleteServer([]byte(in), 9999)
+	if err != nil || ok || string(out) != in {
+		t.Errorf("DeleteServer() of an unknown port = %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Environment variables that override values of the config file, so secrets and host-specific values do not
+// have to live in it. A variable that is unset or empty leaves the file's value.
+const (
+	EnvInstanceName = "DZSA_SYNC_INSTANCE_NAME"
+	EnvDetectIP     = "DZSA_SYNC_DETECT_IP"
+	EnvExternalIP   = "DZSA_SYNC_EXTERNAL_IP"
+	EnvDetectIPv6   = "DZSA_SYNC_DETECT_IPV6"
+	EnvExternalIPv6 = "DZSA_SYNC_EXTERNAL_IPV6"
+	EnvLogPath      = "DZSA_SYNC_LOG_PATH"
+	EnvLogLevel     = "DZSA_SYNC_LOG_LEVEL"
+	EnvLogFormat    = "DZSA_SYNC_LOG_FORMAT"
+	EnvAPIHost      = "DZSA_SYNC_API_HOST"
+	EnvAPIPort      = "DZSA_SYNC_API_PORT"
+	// EnvAdminToken overrides api.admin.token when the file has an api.admin section.
+	EnvAdminToken = "DZSA_SYNC_ADMIN_TOKEN"
+	// EnvPostgresDSN overrides history.postgres.dsn when the file has a history.postgres section.
+	EnvPostgresDSN = "DZSA_SYNC_POSTGRES_DSN"
+	// EnvCloudflareToken overrides dns.cloudflare.api_token when the file has a dns.cloudflare section.
+	EnvCloudflareToken = "DZSA_SYNC_CLOUDFLARE_API_TOKEN"
+)
+
+// ApplyEnv overrides config values with the environment variables above, read with getenv (e.g. os.Getenv).
+// Variables of sections the config does not have are ignored, except for the api section, which is added.
+func (c *Config) ApplyEnv(getenv func(string) string) error {
+	setString := func(dst *string, env string) {
+		if v := getenv(env); v != "" {
+			*dst = v
+		}
+	}
+	setString(&c.InstanceName, EnvInstanceName)
+	setString(&c.ExternalIP, EnvExternalIP)
+	setString(&c.ExternalIPv6, EnvExternalIPv6)
+	setString(&c.LogPath, EnvLogPath)
+	setString(&c.LogLevel, EnvLogLevel)
+	setString(&c.LogFormat, EnvLogFormat)
+	setBool := func(dst *bool, env string) error {
+		if v := getenv(env); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", env, err)
+			}
+			*dst = b
+		}
+		return nil
+	}
+	if err := setBool(&c.DetectIP, EnvDetectIP); err != nil {
+		return err
+	}
+	if err := setBool(&c.DetectIPv6, EnvDetectIPv6); err != nil {
+		return err
+	}
+
+	host, port := getenv(EnvAPIHost), getenv(EnvAPIPort)
+	if (host != "" || port != "") && c.API == nil {
+		c.API = &APIConfig{}
+	}
+	if host != "" {
+		c.API.Host = host
+	}
+	if port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvAPIPort, err)
+		}
+		c.API.Port = p
+	}
+	if c.API != nil && c.API.Admin != nil {
+		setString(&c.API.Admin.Token, EnvAdminToken)
+	}
+	if c.History != nil && c.History.Postgres != nil {
+		setString(&c.History.Postgres.DSN, EnvPostgresDSN)
+	}
+	if c.DNS != nil && c.DNS.Cloudflare != nil {
+		setString(&c.DNS.Cloudflare.APIToken, EnvCloudflareToken)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfig_ApplyEnv(t *testing.T) {
+	env := map[string]string{
+		EnvExternalIP:      "198.51.100.7",
+		EnvDetectIP:        "false",
+		EnvDetectIPv6:      "true",
+		EnvLogPath:         LogStdout,
+		EnvAPIPort:         "9000",
+		EnvAdminToken:      "secret",
+		EnvPostgresDSN:     "postgres://dzsa@db/dzsa",
+		EnvCloudflareToken: "cf-secret",
+	}
+	c := &Config{
+		DetectIP: true,
+		LogPath:  "/var/log/dzsa-sync/dzsa-sync.log",
+		API:      &APIConfig{Host: "127.0.0.1", Admin: &AdminAPIConfig{Port: 8889}},
+		DNS:      &DNSConfig{Cloudflare: &CloudflareDNSConfig{APIToken: "from-file"}},
+	}
+	if err := c.ApplyEnv(func(k string) string { return env[k] }); err != nil {
+		t.Fatalf("ApplyEnv() error = %v", err)
+	}
+	if c.ExternalIP != "198.51.100.7" || c.DetectIP || !c.DetectIPv6 || c.LogPath != LogStdout {
+		t.Errorf("top-level values = %+v", c)
+	}
+	if c.API.Host != "127.0.0.1" || c.API.Port != 9000 || c.API.Admin.Token != "secret" {
+		t.Errorf("api = %+v, admin = %+v", c.API, c.API.Admin)
+	}
+	if c.DNS.Cloudflare.APIToken != "cf-secret" {
+		t.Errorf("dns.cloudflare.api_token = %q", c.DNS.Cloudflare.APIToken)
+	}
+	if c.History != nil {
+		t.Errorf("history = %+v, want no section added", c.History)
+	}
+
+	if err := (&Config{}).ApplyEnv(func(k string) string { return map[string]string{EnvAPIPort: "abc"}[k] }); err == nil {
+		t.Error("ApplyEnv() with an invalid port: error = nil")
+	}
+}
+
+func TestNewFromFile_Env(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("log_path: /var/log/dzsa-sync/dzsa-sync.log\ndetect_ip: false\nservers:\n  - name: main\n    port: 2424\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// The file has no IP source; the environment provides it.
+	t.Setenv(EnvExternalIP, "203.0.113.10")
+	c, err := NewFromFile(path)
+	if err != nil {
+		t.Fatalf("NewFromFile() error = %v", err)
+	}
+	if c.ExternalIP != "203.0.113.10" {
+		t.Errorf("external_ip = %q", c.ExternalIP)
+	}
+}
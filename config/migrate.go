@@ -0,0 +1,130 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LegacyServerName returns the name given to a server migrated from the legacy ports list.
+func LegacyServerName(port int) string {
+	return "server-" + strconv.Itoa(port)
+}
+
+// Migrate converts a config written for an older release to the current schema. It returns the
+// rewritten YAML and a human-readable description of each change; no changes means the input is
+// already current and is returned unmodified. Comments and key order are preserved.
+//
+// Migrations:
+//   - ports: [2424, ...] becomes servers: [{name: server-2424, port: 2424}, ...]. Ports already
+//     present in servers are dropped from the legacy list.
+func Migrate(b []byte) ([]byte, []string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, nil, fmt.Errorf("parse yaml: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return b, nil, nil
+	}
+	root := doc.Content[0]
+
+	changes, err := migratePorts(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(changes) == 0 {
+		return b, nil, nil
+	}
+
+	out, err := encodeDocument(&doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, changes, nil
+}
+
+// encodeDocument writes doc back as YAML with two-space indentation, the way the examples are written.
+func encodeDocument(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("encode yaml: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("encode yaml: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// migratePorts replaces the legacy ports key with servers entries.
+func migratePorts(root *yaml.Node) ([]string, error) {
+	portsIdx := mappingIndex(root, "ports")
+	if portsIdx < 0 {
+		return nil, nil
+	}
+	portsKey, portsVal := root.Content[portsIdx], root.Content[portsIdx+1]
+	if portsVal.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("line %d: ports must be a list of port numbers", portsVal.Line)
+	}
+
+	var servers *yaml.Node
+	existing := make(map[int]bool)
+	if i := mappingIndex(root, "servers"); i >= 0 {
+		servers = root.Content[i+1]
+		var current []Server
+		if err := servers.Decode(&current); err != nil {
+			return nil, fmt.Errorf("line %d: decode servers: %w", servers.Line, err)
+		}
+		for _, s := range current {
+			existing[s.Port] = true
+		}
+	}
+
+	var changes []string
+	var added []*yaml.Node
+	for _, item := range portsVal.Content {
+		port, err := strconv.Atoi(item.Value)
+		if item.Kind != yaml.ScalarNode || err != nil {
+			return nil, fmt.Errorf("line %d: invalid port %q in ports", item.Line, item.Value)
+		}
+		if existing[port] {
+			changes = append(changes, fmt.Sprintf("ports: dropped %d, already in servers", port))
+			continue
+		}
+		existing[port] = true
+		name := LegacyServerName(port)
+		entry := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: "name"},
+			{Kind: yaml.ScalarNode, Value: name},
+			{Kind: yaml.ScalarNode, Value: "port"},
+			{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(port)},
+		}}
+		added = append(added, entry)
+		changes = append(changes, fmt.Sprintf("ports: %d -> servers: {name: %s, port: %d}", port, name, port))
+	}
+
+	if servers == nil {
+		// Reuse the ports key position and comments for servers.
+		portsKey.Value = "servers"
+		portsVal.Content = added
+		portsVal.Style = 0
+	} else {
+		servers.Content = append(servers.Content, added...)
+		root.Content = append(root.Content[:portsIdx], root.Content[portsIdx+2:]...)
+	}
+	changes = append(changes, "ports: removed (replaced by servers)")
+	return changes, nil
+}
+
+// mappingIndex returns the index of key in a mapping node's content, or -1.
+func mappingIndex(m *yaml.Node, key string) int {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
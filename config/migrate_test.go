@@ -0,0 +1,78 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name        string
+		in          string
+		wantServers []Server
+		wantChanges int
+		wantErr     bool
+	}{
+		{
+			name:        "legacy ports",
+			in:          "detect_ip: true\n# game servers\nports: [2424, 2324]\nlog_path: /var/log/dzsa-sync/dzsa-sync.log\n",
+			wantServers: []Server{{Name: "server-2424", Port: 2424}, {Name: "server-2324", Port: 2324}},
+			wantChanges: 3,
+		},
+		{
+			name:        "ports merged into servers",
+			in:          "servers:\n  - name: main\n    port: 2424\nports:\n  - 2424\n  - 2324\n",
+			wantServers: []Server{{Name: "main", Port: 2424}, {Name: "server-2324", Port: 2324}},
+			wantChanges: 3,
+		},
+		{
+			name:        "already current",
+			in:          "servers:\n  - name: main\n    port: 2424\n",
+			wantServers: []Server{{Name: "main", Port: 2424}},
+		},
+		{
+			name:    "invalid port",
+			in:      "ports: [main]\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, changes, err := Migrate([]byte(tt.in))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Migrate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(changes) != tt.wantChanges {
+				t.Errorf("Migrate() changes = %q, want %d", changes, tt.wantChanges)
+			}
+			if tt.wantChanges == 0 && string(out) != tt.in {
+				t.Errorf("Migrate() modified a current config:\n%s", out)
+			}
+			if strings.Contains(string(out), "ports:") {
+				t.Errorf("Migrate() output still has ports:\n%s", out)
+			}
+			var cfg Config
+			if err := yaml.Unmarshal(out, &cfg); err != nil {
+				t.Fatalf("unmarshal migrated config: %v", err)
+			}
+			if len(cfg.Servers) != len(tt.wantServers) {
+				t.Fatalf("servers = %+v, want %+v", cfg.Servers, tt.wantServers)
+			}
+			for i := range tt.wantServers {
+				if cfg.Servers[i] != tt.wantServers[i] {
+					t.Errorf("servers[%d] = %+v, want %+v", i, cfg.Servers[i], tt.wantServers[i])
+				}
+			}
+		})
+	}
+
+	out, _, _ := Migrate([]byte(tests[0].in))
+	if !strings.Contains(string(out), "# game servers") {
+		t.Errorf("Migrate() dropped comments:\n%s", out)
+	}
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted replaces secret values in Redact's output.
+const Redacted = "REDACTED"
+
+// secretKeys are config keys whose values are replaced by Redact. Every value under headers is
+// redacted because headers usually carry credentials (Authorization, API keys). The privacy hash key is a
+// secret too: with it, hashed IPv4 addresses can be reversed by hashing every address.
+var secretKeys = map[string]bool{
+	"token":     true,
+	"api_token": true,
+	"password":  true,
+	"dsn":       true,
+	"headers":   true,
+	"hash_key":  true,
+}
+
+// secretURLKeys are config keys under which url values carry secrets, e.g. the token in the path of a Discord
+// or Slack webhook URL, or in the query of a webhook. Redact keeps only their scheme and host.
+var secretURLKeys = map[string]bool{
+	"notifiers": true,
+	"webhooks":  true,
+}
+
+// Redact returns the config YAML with secrets (tokens, passwords, database DSNs, HTTP header values, and
+// the privacy hash key) replaced, so it can be shared in bug reports. Comments and key order are preserved.
+func Redact(b []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("parse yaml: %w", err)
+	}
+	redactNode(&doc, false, false)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("encode yaml: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("encode yaml: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// redactNode walks n, replacing every scalar value when secret is true or under a secret key, and the path,
+// query, and user info of url values when secretURL is true.
+func redactNode(n *yaml.Node, secret, secretURL bool) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if secret && n.Value != "" {
+			n.Value = Redacted
+			n.Tag = "!!str"
+			n.Style = 0
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i].Value, n.Content[i+1]
+			if secretURL && key == "url" && value.Kind == yaml.ScalarNode && !secret {
+				value.Value = redactURL(value.Value)
+				continue
+			}
+			redactNode(value, secret || secretKeys[key], secretURL || secretURLKeys[key])
+		}
+	default:
+		for _, c := range n.Content {
+			redactNode(c, secret, secretURL)
+		}
+	}
+}
+
+// redactURL returns the scheme and host of s, followed by Redacted when s has more. A value that does not
+// parse as an absolute URL is Redacted entirely.
+func redactURL(s string) string {
+	if s == "" {
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return Redacted
+	}
+	base := u.Scheme + "://" + u.Host
+	if u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" || u.Opaque != "" {
+		return base + "/" + Redacted
+	}
+	return base
+}
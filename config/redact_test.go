@@ -0,0 +1,80 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	in := `detect_ip: true
+servers:
+  - name: main
+    port: 2424
+history:
+  postgres:
+    enabled: true
+    dsn: postgres://dzsa:hunter2@db:5432/dzsa
+hooks:
+  - name: restart
+    token: s3cret-token # rotated monthly
+    action: sync
+remote_write:
+  url: https://prometheus.example.com/api/v1/write
+  username: "12345"
+  password: glc_abcdef
+  headers:
+    X-Scope-OrgID: tenant-1
+dns:
+  cloudflare:
+    zone_id: 023e105f4ecef8ad9ca31a8372d0c353
+    record: play.example.com
+    api_token: cf-token-123
+notifiers:
+  - name: discord
+    type: discord
+    url: https://discord.com/api/webhooks/123456/discord-secret
+  - name: slack
+    type: slack
+    url: https://hooks.slack.com/services/T000/B000/slack-secret?team=slack-team
+webhooks:
+  - name: ci
+    events: [post_sync_failure]
+    url: https://ci.example.com/hooks/trigger?token=ci-secret
+privacy:
+  redact_ips: hash
+  hash_key: 4f9c0a7e5d1b2c3a
+`
+	out, err := Redact([]byte(in))
+	if err != nil {
+		t.Fatalf("Redact() error = %v", err)
+	}
+	got := string(out)
+	for _, secret := range []string{"hunter2", "s3cret-token", "glc_abcdef", "tenant-1", "cf-token-123", "4f9c0a7e5d1b2c3a", "discord-secret", "123456", "slack-secret", "slack-team", "ci-secret", "/hooks/trigger"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("Redact() output contains %q:\n%s", secret, got)
+		}
+	}
+	for _, keep := range []string{"name: main", "port: 2424", "username: \"12345\"", "https://prometheus.example.com", "# rotated monthly", "record: play.example.com", "redact_ips: hash", "url: https://discord.com/REDACTED", "url: https://hooks.slack.com/REDACTED", "url: https://ci.example.com/REDACTED", "type: discord"} {
+		if !strings.Contains(got, keep) {
+			t.Errorf("Redact() output is missing %q:\n%s", keep, got)
+		}
+	}
+
+	for in, want := range map[string]string{
+		"":                             "",
+		"https://example.com":          "https://example.com",
+		"https://example.com/":         "https://example.com",
+		"https://user:pw@example.com":  "https://example.com/" + Redacted,
+		"https://example.com?token=x":  "https://example.com/" + Redacted,
+		"http://example.com:8080/hook": "http://example.com:8080/" + Redacted,
+		"not a url":                    Redacted,
+	} {
+		if got := redactURL(in); got != want {
+			t.Errorf("redactURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	if _, err := Redact([]byte("servers: [")); err == nil {
+		t.Error("Redact() accepted invalid YAML")
+	}
+}
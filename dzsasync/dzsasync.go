@@ -0,0 +1,115 @@
+// Package dzsasync runs the dzsa-sync daemon inside another Go program: the same workers, store, history,
+// checks, notifications, and API as dzsa-sync run, configured with a config.Config, with the logger, history
+// stores, notifiers, and clients passed in Options instead of built from the config.
+//
+//	cfg, err := config.NewFromFile("/etc/dzsa-sync/config.yaml")
+//	if err != nil {
+//		return err
+//	}
+//	return dzsasync.Run(ctx, cfg, dzsasync.Options{Logger: logger})
+//
+// The daemon registers its metrics with the global OpenTelemetry meter provider, so Run can be called once
+// per process. Graceful restarts and systemd notification are left to the embedding program.
+package dzsasync
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/jsirianni/dzsa-sync/client"
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/daemon"
+	"github.com/jsirianni/dzsa-sync/internal/history"
+	"github.com/jsirianni/dzsa-sync/internal/notify"
+	"go.uber.org/zap"
+)
+
+type (
+	// HistorySink receives a record for every sync.
+	HistorySink = history.Sink
+	// HistoryReader serves the history API, rules, and reports.
+	HistoryReader = history.Reader
+	// HistoryRecord is the result of one sync, or an hourly aggregate of several.
+	HistoryRecord = history.Record
+	// HistoryQuery selects the records a HistoryReader returns.
+	HistoryQuery = history.Query
+	// Notifier delivers rule events and scheduled reports.
+	Notifier = notify.Notifier
+	// NotifyEvent is a rule firing or resolving.
+	NotifyEvent = notify.Event
+	// NotifyReport is a scheduled report.
+	NotifyReport = notify.Report
+)
+
+// Options configures Run. Every field is optional.
+type Options struct {
+	// Logger receives the daemon's logs, redacted per the privacy config section. Nil discards them; log_path
+	// is not used.
+	Logger *zap.Logger
+	// ConfigPath is the file cfg was loaded from, compared against the running config by
+	// GET /api/v1/config/diff. Empty leaves the endpoint out.
+	ConfigPath string
+	// HTTPClient sends every outbound request: DZSA, IP detection, Steam, notifiers, and remote_write.
+	// Nil builds one from the http config section.
+	HTTPClient *http.Client
+	// Client queries DZSA, e.g. a fake in tests. Nil builds one from HTTPClient and the staging config section.
+	Client client.Client
+	// History receives a record for every sync, in addition to the stores of the history config section.
+	// Run does not close it.
+	History HistorySink
+	// HistoryReader serves the history API, rules, and reports instead of the stores of the history config
+	// section, and satisfies rules and reports that need history.
+	HistoryReader HistoryReader
+	// Notifiers can be named by rules and reports like notifiers entries. They take precedence over
+	// notifiers entries of the same name.
+	Notifiers map[string]Notifier
+	// DisableAPI leaves out the API and metrics listeners, e.g. when the embedding program serves its own.
+	DisableAPI bool
+	// Ready is called once the daemon serves and syncs.
+	Ready func()
+}
+
+// Run validates cfg with the notifiers and history of opts, then runs the daemon until ctx is cancelled and
+// the syncs in flight finish within shutdown_timeout. It returns the validation or startup error, if any.
+// cfg is not modified. Controller mode is not supported.
+func Run(ctx context.Context, cfg *config.Config, opts Options) error {
+	c := *cfg
+	if c.LogPath == "" {
+		// Logs go to opts.Logger.
+		c.LogPath = config.LogStderr
+	}
+	c.EmbeddedNotifiers = slices.Sorted(maps.Keys(opts.Notifiers))
+	c.EmbeddedHistory = opts.HistoryReader != nil
+	if err := c.Validate(); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	if c.ControllerEnabled() {
+		return fmt.Errorf("config: controller mode cannot be embedded")
+	}
+
+	redactor, err := daemon.NewRedactor(c.Privacy)
+	if err != nil {
+		return fmt.Errorf("privacy: %w", err)
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	logger = logger.WithOptions(zap.WrapCore(redactor.Core))
+
+	return daemon.Run(ctx, &c, daemon.Options{
+		Logger:        logger,
+		Redactor:      redactor,
+		ConfigPath:    opts.ConfigPath,
+		HTTPClient:    opts.HTTPClient,
+		Client:        opts.Client,
+		History:       opts.History,
+		HistoryReader: opts.HistoryReader,
+		Notifiers:     opts.Notifiers,
+		DisableAPI:    opts.DisableAPI,
+		Ready:         opts.Ready,
+	})
+}
@@ -0,0 +1,62 @@
+package dzsasync
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/model"
+)
+
+type fakeClient struct{}
+
+func (fakeClient) Query(context.Context, string, int) (*model.QueryResponse, error) {
+	return &model.QueryResponse{}, nil
+}
+
+type fakeNotifier struct{}
+
+func (fakeNotifier) Notify(context.Context, NotifyEvent) error      { return nil }
+func (fakeNotifier) SendReport(context.Context, NotifyReport) error { return nil }
+
+type fakeHistory struct{}
+
+func (fakeHistory) Query(context.Context, HistoryQuery) ([]HistoryRecord, error) { return nil, nil }
+
+func TestRun(t *testing.T) {
+	cfg := &config.Config{
+		ExternalIP: "203.0.113.10",
+		Servers:    []config.Server{{Name: "main", Port: 2424}},
+		Reports:    []config.Report{{Name: "daily", Schedule: "daily", Notify: []string{"ops"}}},
+	}
+
+	// The report needs a notifier and history, which only opts provides.
+	err := Run(context.Background(), cfg, Options{Client: fakeClient{}, DisableAPI: true})
+	if err == nil || !strings.Contains(err.Error(), "config:") {
+		t.Fatalf("Run without notifiers = %v, want a config error", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ready := false
+	err = Run(ctx, cfg, Options{
+		Client:        fakeClient{},
+		HistoryReader: fakeHistory{},
+		Notifiers:     map[string]Notifier{"ops": fakeNotifier{}},
+		DisableAPI:    true,
+		Ready: func() {
+			ready = true
+			cancel()
+		},
+	})
+	if err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if !ready {
+		t.Error("Ready was not called")
+	}
+	if cfg.LogPath != "" || cfg.EmbeddedNotifiers != nil {
+		t.Errorf("cfg was modified: %+v", cfg)
+	}
+}
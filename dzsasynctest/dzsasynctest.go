@@ -0,0 +1,261 @@
+// Package dzsasynctest runs the dzsa-sync sync loop in-process for tests: a worker per server, the in-memory
+// server store, and the API, against a fake DZSA (package mockserver) and a fake IP provider. Nothing leaves
+// the machine.
+//
+// The loop uses the real clock with a one hour sync interval, so scheduled syncs do not run during a test.
+// Advance and Sync stand in for the passing of time: they run the next sync now and wait for it.
+package dzsasynctest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/client"
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/api"
+	"github.com/jsirianni/dzsa-sync/internal/ifconfig"
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+	"github.com/jsirianni/dzsa-sync/internal/worker"
+	"github.com/jsirianni/dzsa-sync/mockserver"
+	"github.com/jsirianni/dzsa-sync/model"
+	"go.uber.org/zap"
+)
+
+// DefaultExternalIP is the IP the fake provider reports when Options.ExternalIP is empty.
+const DefaultExternalIP = "203.0.113.10"
+
+// DefaultTimeout bounds the wait for a sync when Options.Timeout is zero.
+const DefaultTimeout = 5 * time.Second
+
+// Options configures a Harness.
+type Options struct {
+	// Servers are synced as if listed under servers in the config. Ports must be unique.
+	Servers []config.Server
+	// ExternalIP is the IP the fake provider reports at start. Empty uses DefaultExternalIP.
+	ExternalIP string
+	// DZSA configures the fake DZSA. With no Servers and no Default, every endpoint gets a generic result.
+	DZSA mockserver.Options
+	// Logger receives the daemon's logs. Nil discards them.
+	Logger *zap.Logger
+	// Timeout bounds the wait for a sync. Zero uses DefaultTimeout.
+	Timeout time.Duration
+}
+
+// Harness is a running sync loop. Create it with New; it stops when the test ends.
+type Harness struct {
+	// DZSA is the fake DZSA. Change its results and faults to steer the next syncs.
+	DZSA *mockserver.Server
+	// APIURL is the base URL of the API, e.g. for GET APIURL+"/api/v1/status".
+	APIURL string
+
+	t       testing.TB
+	timeout time.Duration
+	store   *servers.Store
+	manager *worker.Manager
+	ifc     *ifconfig.Client
+
+	ipMu sync.Mutex
+	ip   string
+}
+
+// New starts the sync loop for opts.Servers and waits for every server's first sync.
+func New(t testing.TB, opts Options) *Harness {
+	t.Helper()
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+	if opts.ExternalIP == "" {
+		opts.ExternalIP = DefaultExternalIP
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.DZSA.Servers == nil && opts.DZSA.Default == nil {
+		opts.DZSA.Default = &model.Result{Name: "dzsasynctest", Map: "chernarusplus", MaxPlayers: 60}
+	}
+	if err := opts.DZSA.Validate(); err != nil {
+		t.Fatalf("dzsasynctest: %v", err)
+	}
+	h := &Harness{t: t, timeout: opts.Timeout, ip: opts.ExternalIP, store: servers.New(nil)}
+
+	h.DZSA = mockserver.New(opts.DZSA)
+	dzsaServer := httptest.NewServer(h.DZSA)
+	ipServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(ifconfig.Response{IP: h.externalIP()})
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h.ifc = ifconfig.New(opts.Logger, ipServer.Client(), nil)
+	h.ifc.BaseURL = ipServer.URL
+	h.ifc.Check(ctx, nil)
+	h.manager = worker.NewManager(ctx, worker.Options{
+		Logger:   opts.Logger,
+		Client:   client.New(client.Options{HTTPClient: dzsaServer.Client(), BaseURL: dzsaServer.URL + mockserver.QueryPath}),
+		IFConfig: h.ifc,
+		Store:    h.store,
+		// Below one second, so syncs start without the random delay.
+		JitterMax: time.Nanosecond,
+	})
+	apiServer := httptest.NewServer(api.NewServer(api.Options{
+		MetricsHandler: http.NotFoundHandler(),
+		Store:          h.store,
+		Syncer:         h.manager,
+		Address:        h.ifc.GetAddress,
+		Logger:         opts.Logger,
+	}).Handler)
+	h.APIURL = apiServer.URL
+	t.Cleanup(func() {
+		cancel()
+		h.manager.Wait()
+		h.DZSA.Close()
+		apiServer.Close()
+		dzsaServer.Close()
+		ipServer.Close()
+	})
+
+	before := h.attempts()
+	h.manager.Reconcile(worker.SourceConfig, opts.Servers)
+	h.wait(before, ports(opts.Servers))
+	return h
+}
+
+// Advance runs every server's next sync now, as if the sync interval had passed, and waits for them.
+func (h *Harness) Advance() {
+	h.t.Helper()
+	before := h.attempts()
+	h.manager.TriggerAll()
+	h.wait(before, ports(h.manager.Servers()))
+}
+
+// Sync runs the next sync of the server on port now and waits for it. It returns the sync's error.
+func (h *Harness) Sync(port int) error {
+	h.t.Helper()
+	before := h.attempts()
+	if !h.manager.Trigger(port) {
+		h.t.Fatalf("dzsasynctest: no server on port %d", port)
+	}
+	h.wait(before, []int{port})
+	return h.Err(port)
+}
+
+// SetExternalIP changes the IP the fake provider reports and runs the daemon's next IP check, which resyncs
+// every server when the IP changed. It waits for those syncs.
+func (h *Harness) SetExternalIP(ip string) {
+	h.t.Helper()
+	h.ipMu.Lock()
+	h.ip = ip
+	h.ipMu.Unlock()
+	before := h.attempts()
+	changed := false
+	h.ifc.Check(context.Background(), func(_, _ string) {
+		changed = true
+		h.manager.TriggerAll()
+	})
+	if changed {
+		h.wait(before, ports(h.manager.Servers()))
+	}
+}
+
+// ExternalIP returns the IP the daemon currently registers servers with.
+func (h *Harness) ExternalIP() string {
+	return h.ifc.GetAddress()
+}
+
+// Result returns the stored result for the server on port, and false until it synced successfully.
+func (h *Harness) Result(port int) (model.Result, bool) {
+	r, ok := h.store.Get(port)
+	if !ok {
+		return model.Result{}, false
+	}
+	return *r, true
+}
+
+// Err returns the error of the last sync of the server on port, or nil if it succeeded.
+func (h *Harness) Err(port int) error {
+	st, ok := h.store.GetSyncState(port)
+	if !ok || st.LastError == "" {
+		return nil
+	}
+	return fmt.Errorf("%s", st.LastError)
+}
+
+// Get decodes the JSON response of GET path on the API into out, failing the test on error.
+func (h *Harness) Get(path string, out any) {
+	h.t.Helper()
+	resp, err := http.Get(h.APIURL + path) // #nosec G107 -- test server URL
+	if err != nil {
+		h.t.Fatalf("dzsasynctest: GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		h.t.Fatalf("dzsasynctest: GET %s: status %d", path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		h.t.Fatalf("dzsasynctest: GET %s: decode: %v", path, err)
+	}
+}
+
+func (h *Harness) externalIP() string {
+	h.ipMu.Lock()
+	defer h.ipMu.Unlock()
+	return h.ip
+}
+
+// mark is a server's last sync attempt and next scheduled sync.
+type mark struct {
+	attempt, next time.Time
+}
+
+// mark returns the mark of the server on port.
+func (h *Harness) mark(port int) mark {
+	var m mark
+	if st, ok := h.store.GetSyncState(port); ok {
+		m.attempt = st.LastAttempt
+	}
+	m.next, _ = h.store.NextSync(port, time.Now())
+	return m
+}
+
+// attempts returns the mark of every managed server.
+func (h *Harness) attempts() map[int]mark {
+	out := make(map[int]mark)
+	for _, srv := range h.manager.Servers() {
+		out[srv.Port] = h.mark(srv.Port)
+	}
+	return out
+}
+
+// wait waits until every port finished a sync attempt after its mark in before, and fails the test after the
+// timeout. An attempt is finished once the worker scheduled the next sync after it; by then its result is
+// stored.
+func (h *Harness) wait(before map[int]mark, ports []int) {
+	h.t.Helper()
+	deadline := time.Now().Add(h.timeout)
+	for _, port := range ports {
+		for {
+			m := h.mark(port)
+			if m.attempt.After(before[port].attempt) && m.next.After(m.attempt) && !m.next.Equal(before[port].next) {
+				break
+			}
+			if time.Now().After(deadline) {
+				h.t.Fatalf("dzsasynctest: no sync of port %d within %v", port, h.timeout)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+}
+
+func ports(srvs []config.Server) []int {
+	out := make([]int, len(srvs))
+	for i, s := range srvs {
+		out[i] = s.Port
+	}
+	return out
+}
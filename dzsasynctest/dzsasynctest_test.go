@@ -0,0 +1,82 @@
+package dzsasynctest
+
+import (
+	"testing"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/mockserver"
+	"github.com/jsirianni/dzsa-sync/model"
+)
+
+func TestHarness(t *testing.T) {
+	h := New(t, Options{
+		Servers: []config.Server{{Name: "alpha", Port: 2324}, {Name: "bravo", Port: 2424}},
+		DZSA: mockserver.Options{
+			Servers: map[string]model.Result{"203.0.113.10:2324": {Name: "Alpha", Players: 12, MaxPlayers: 60}},
+		},
+	})
+
+	// Initial sync: alpha is listed, bravo is unknown to DZSA.
+	r, ok := h.Result(2324)
+	if !ok || r.Name != "Alpha" || r.Players != 12 {
+		t.Fatalf("Result(2324) = %+v, %v", r, ok)
+	}
+	if err := h.Err(2324); err != nil {
+		t.Fatalf("Err(2324) = %v", err)
+	}
+	if err := h.Err(2424); err == nil {
+		t.Fatal("Err(2424) = nil, want the DZSA error")
+	}
+
+	// Scheduled syncs pick up changed results.
+	h.DZSA.Set("203.0.113.10:2324", model.Result{Name: "Alpha", Players: 30, MaxPlayers: 60})
+	h.Advance()
+	if r, _ := h.Result(2324); r.Players != 30 {
+		t.Fatalf("after Advance players = %d, want 30", r.Players)
+	}
+
+	// An IP change resyncs every server with the new IP.
+	h.DZSA.Set("198.51.100.7:2324", model.Result{Name: "Alpha", Players: 5, MaxPlayers: 60})
+	h.SetExternalIP("198.51.100.7")
+	if ip := h.ExternalIP(); ip != "198.51.100.7" {
+		t.Fatalf("ExternalIP() = %q", ip)
+	}
+	queries := h.DZSA.Queries()
+	if queries["198.51.100.7:2324"] != 1 || queries["198.51.100.7:2424"] != 1 {
+		t.Fatalf("queries = %v, want one per server at the new IP", queries)
+	}
+	if r, _ := h.Result(2324); r.Players != 5 {
+		t.Fatalf("after IP change players = %d, want 5", r.Players)
+	}
+
+	// Faults surface as sync errors, and the last good result is kept.
+	h.DZSA.SetFaults([]mockserver.Fault{{Kind: mockserver.FaultStatus, Rate: 1}})
+	if err := h.Sync(2324); err == nil {
+		t.Fatal("Sync(2324) = nil, want an error")
+	}
+	if r, ok := h.Result(2324); !ok || r.Players != 5 {
+		t.Fatalf("after failed sync Result = %+v, %v", r, ok)
+	}
+	h.DZSA.SetFaults(nil)
+	if err := h.Sync(2324); err != nil {
+		t.Fatalf("Sync(2324) = %v", err)
+	}
+
+	// The API reports the same state.
+	var status struct {
+		ExternalIP string `json:"external_ip"`
+		Servers    []struct {
+			Port    int `json:"port"`
+			Players int `json:"players"`
+		} `json:"servers"`
+	}
+	h.Get("/api/v1/status", &status)
+	if status.ExternalIP != "198.51.100.7" || len(status.Servers) != 2 {
+		t.Fatalf("status = %+v", status)
+	}
+	for _, s := range status.Servers {
+		if s.Port == 2324 && s.Players != 5 {
+			t.Fatalf("status players = %d, want 5", s.Players)
+		}
+	}
+}
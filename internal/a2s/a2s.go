@@ -0,0 +1,340 @@
+// Package a2s implements the Steam server query protocol (A2S) used to query DayZ servers directly.
+package a2s
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// DefaultTimeout is the default time allowed for a single query, including the challenge round trip.
+const DefaultTimeout = 5 * time.Second
+
+const (
+	headerSingle = -1
+	headerSplit  = -2
+
+	typeChallenge     = 0x41
+	typeInfoRequest   = 0x54
+	typeInfoResponse  = 0x49
+	typeRulesRequest  = 0x56
+	typeRulesResponse = 0x45
+
+	// edfPort is the extra data flag set when an A2S_INFO response includes the game port.
+	edfPort = 0x80
+
+	maxPacketSize = 1400
+)
+
+// ErrCompressed is returned when a server replies with a bzip2 compressed split response, which is not supported.
+var ErrCompressed = errors.New("compressed responses are not supported")
+
+// infoPayload is the fixed A2S_INFO request string.
+var infoPayload = []byte("Source Engine Query\x00")
+
+// Info is the subset of an A2S_INFO response used by dzsa-sync.
+type Info struct {
+	Name       string `json:"name"`
+	Map        string `json:"map"`
+	Players    int    `json:"players"`
+	MaxPlayers int    `json:"max_players"`
+	Version    string `json:"version"`
+	// GamePort is the server's game port, when the server reports it.
+	GamePort int `json:"game_port,omitempty"`
+}
+
+// Client performs A2S queries over UDP.
+type Client struct {
+	// Timeout bounds each query. Zero uses DefaultTimeout.
+	Timeout time.Duration
+}
+
+// Rules sends A2S_RULES to addr (host:port) and returns the raw rule key/value pairs.
+// DayZ encodes its mod list in binary rules; see DayZMods.
+func (c *Client) Rules(ctx context.Context, addr string) (map[string]string, error) {
+	// A2S_RULES starts with the -1 challenge to request one.
+	payload, err := c.challengeQuery(ctx, addr, []byte{typeRulesRequest}, []byte{0xFF, 0xFF, 0xFF, 0xFF}, typeRulesResponse)
+	if err != nil {
+		return nil, err
+	}
+	return parseRules(payload)
+}
+
+// Info sends A2S_INFO to addr (host:port) and returns the server's name, map, and player counts.
+// It is the cheapest way to check that a server answers Steam queries.
+func (c *Client) Info(ctx context.Context, addr string) (*Info, error) {
+	// A2S_INFO is sent without a challenge; servers that require one reply with it first.
+	payload, err := c.challengeQuery(ctx, addr, append([]byte{typeInfoRequest}, infoPayload...), nil, typeInfoResponse)
+	if err != nil {
+		return nil, err
+	}
+	return parseInfo(payload)
+}
+
+// Ping sends A2S_INFO to addr (host:port) and returns the time until the first reply, which is a single
+// round trip whether the server answers with its info or with a challenge.
+func (c *Client) Ping(ctx context.Context, addr string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return 0, fmt.Errorf("dial %s: %w", addr, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	req := append([]byte{0xFF, 0xFF, 0xFF, 0xFF, typeInfoRequest}, infoPayload...)
+	start := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return 0, fmt.Errorf("write: %w", err)
+	}
+	packet, err := readResponse(conn)
+	rtt := time.Since(start)
+	if err != nil {
+		return 0, err
+	}
+	if len(packet) < 1 || (packet[0] != typeChallenge && packet[0] != typeInfoResponse) {
+		return 0, fmt.Errorf("unexpected response to A2S_INFO")
+	}
+	return rtt, nil
+}
+
+func (c *Client) timeout() time.Duration {
+	if c.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return c.Timeout
+}
+
+// challengeQuery sends request (type byte and payload) followed by challenge, answers a challenge if one
+// is returned, and returns the response payload (after the type byte) when its type is respType.
+func (c *Client) challengeQuery(ctx context.Context, addr string, request, challenge []byte, respType byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", addr, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	// A server may answer with a fresh challenge more than once; bound the retries.
+	for range 3 {
+		req := append(append([]byte{0xFF, 0xFF, 0xFF, 0xFF}, request...), challenge...)
+		if _, err := conn.Write(req); err != nil {
+			return nil, fmt.Errorf("write: %w", err)
+		}
+		packet, err := readResponse(conn)
+		if err != nil {
+			return nil, err
+		}
+		if len(packet) < 1 {
+			return nil, fmt.Errorf("empty response")
+		}
+		switch packet[0] {
+		case typeChallenge:
+			if len(packet) < 5 {
+				return nil, fmt.Errorf("short challenge response")
+			}
+			challenge = packet[1:5]
+		case respType:
+			return packet[1:], nil
+		default:
+			return nil, fmt.Errorf("unexpected response type 0x%02x", packet[0])
+		}
+	}
+	return nil, fmt.Errorf("too many challenges")
+}
+
+// readResponse reads one logical response, reassembling split packets, and returns it without the
+// 4 byte single-packet header.
+func readResponse(conn net.Conn) ([]byte, error) {
+	buf := make([]byte, 65535)
+	var (
+		parts    map[int][]byte
+		total    int
+		expectID int32
+	)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, fmt.Errorf("read: %w", err)
+		}
+		packet := buf[:n]
+		if len(packet) < 4 {
+			return nil, fmt.Errorf("short packet")
+		}
+		header := int32(binary.LittleEndian.Uint32(packet[:4])) // #nosec G115 -- protocol header is a signed int32
+		switch header {
+		case headerSingle:
+			return append([]byte(nil), packet[4:]...), nil
+		case headerSplit:
+			// Source engine split header: ID (4), total (1), number (1), max size (2).
+			if len(packet) < 12 {
+				return nil, fmt.Errorf("short split packet")
+			}
+			id := int32(binary.LittleEndian.Uint32(packet[4:8])) // #nosec G115 -- protocol ID is a signed int32
+			if id < 0 {
+				return nil, ErrCompressed
+			}
+			if parts == nil {
+				parts = make(map[int][]byte)
+				total = int(packet[8])
+				expectID = id
+			}
+			if id != expectID {
+				continue
+			}
+			parts[int(packet[9])] = append([]byte(nil), packet[12:]...)
+			if len(parts) < total {
+				continue
+			}
+			var joined bytes.Buffer
+			for i := range total {
+				joined.Write(parts[i])
+			}
+			b := joined.Bytes()
+			if len(b) < 4 || int32(binary.LittleEndian.Uint32(b[:4])) != headerSingle { // #nosec G115 -- protocol header is a signed int32
+				return nil, fmt.Errorf("invalid split payload header")
+			}
+			return b[4:], nil
+		default:
+			return nil, fmt.Errorf("unknown packet header %d", header)
+		}
+	}
+}
+
+// parseRules parses an A2S_RULES payload: rule count (uint16) followed by null-terminated key/value pairs.
+func parseRules(b []byte) (map[string]string, error) {
+	r := &reader{b: b}
+	count, err := r.uint16()
+	if err != nil {
+		return nil, fmt.Errorf("rule count: %w", err)
+	}
+	rules := make(map[string]string, count)
+	for i := 0; i < int(count); i++ {
+		k, err := r.cstring()
+		if err != nil {
+			return nil, fmt.Errorf("rule %d key: %w", i, err)
+		}
+		v, err := r.cstring()
+		if err != nil {
+			return nil, fmt.Errorf("rule %d value: %w", i, err)
+		}
+		rules[k] = v
+	}
+	return rules, nil
+}
+
+// parseInfo parses an A2S_INFO payload. Fields after the version are only read for the optional game port.
+func parseInfo(b []byte) (*Info, error) {
+	r := &reader{b: b}
+	if _, err := r.byte(); err != nil {
+		return nil, fmt.Errorf("protocol: %w", err)
+	}
+	info := &Info{}
+	var err error
+	if info.Name, err = r.cstring(); err != nil {
+		return nil, fmt.Errorf("name: %w", err)
+	}
+	if info.Map, err = r.cstring(); err != nil {
+		return nil, fmt.Errorf("map: %w", err)
+	}
+	for _, field := range []string{"folder", "game"} {
+		if _, err := r.cstring(); err != nil {
+			return nil, fmt.Errorf("%s: %w", field, err)
+		}
+	}
+	if _, err := r.uint16(); err != nil {
+		return nil, fmt.Errorf("app id: %w", err)
+	}
+	counts, err := r.bytes(2)
+	if err != nil {
+		return nil, fmt.Errorf("players: %w", err)
+	}
+	info.Players, info.MaxPlayers = int(counts[0]), int(counts[1])
+	// Bots, server type, environment, visibility, VAC.
+	if _, err := r.bytes(5); err != nil {
+		return nil, fmt.Errorf("server flags: %w", err)
+	}
+	if info.Version, err = r.cstring(); err != nil {
+		return nil, fmt.Errorf("version: %w", err)
+	}
+	edf, err := r.byte()
+	if err != nil {
+		// The extra data flag is optional.
+		return info, nil
+	}
+	if edf&edfPort != 0 {
+		port, err := r.uint16()
+		if err != nil {
+			return nil, fmt.Errorf("game port: %w", err)
+		}
+		info.GamePort = int(port)
+	}
+	return info, nil
+}
+
+var errShort = errors.New("unexpected end of data")
+
+// reader reads little-endian protocol values from a byte slice.
+type reader struct {
+	b   []byte
+	off int
+}
+
+func (r *reader) byte() (byte, error) {
+	if r.off >= len(r.b) {
+		return 0, errShort
+	}
+	v := r.b[r.off]
+	r.off++
+	return v, nil
+}
+
+func (r *reader) bytes(n int) ([]byte, error) {
+	if n < 0 || r.off+n > len(r.b) {
+		return nil, errShort
+	}
+	v := r.b[r.off : r.off+n]
+	r.off += n
+	return v, nil
+}
+
+func (r *reader) uint16() (uint16, error) {
+	b, err := r.bytes(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(b), nil
+}
+
+func (r *reader) uint32() (uint32, error) {
+	b, err := r.bytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+func (r *reader) cstring() (string, error) {
+	i := bytes.IndexByte(r.b[r.off:], 0)
+	if i < 0 {
+		return "", errShort
+	}
+	s := string(r.b[r.off : r.off+i])
+	r.off += i + 1
+	return s, nil
+}
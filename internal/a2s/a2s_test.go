@@ -0,0 +1,250 @@
+package a2s
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/model"
+)
+
+// escape is the inverse of unescape.
+func escape(b []byte) []byte {
+	var out []byte
+	for _, c := range b {
+		switch c {
+		case 0x01:
+			out = append(out, 0x01, 0x01)
+		case 0x00:
+			out = append(out, 0x01, 0x02)
+		case 0xFF:
+			out = append(out, 0x01, 0x03)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func dayzModPayload(mods []Mod) []byte {
+	var b bytes.Buffer
+	b.Write([]byte{0x03, 0x00})       // version, overflow
+	b.Write([]byte{0x01, 0x00})       // dlc flags: one DLC
+	b.Write([]byte{0xAA, 0xBB, 0, 0}) // dlc hash
+	b.WriteByte(byte(len(mods)))
+	for _, m := range mods {
+		_ = binary.Write(&b, binary.LittleEndian, m.Hash)
+		b.WriteByte(0x04)
+		_ = binary.Write(&b, binary.LittleEndian, uint32(m.WorkshopID)) // #nosec G115 -- test data
+		b.WriteByte(byte(len(m.Name)))
+		b.WriteString(m.Name)
+	}
+	return escape(b.Bytes())
+}
+
+func rulesPayload(rules [][2]string) []byte {
+	var b bytes.Buffer
+	_ = binary.Write(&b, binary.LittleEndian, uint16(len(rules))) // #nosec G115 -- test data
+	for _, kv := range rules {
+		b.WriteString(kv[0])
+		b.WriteByte(0)
+		b.WriteString(kv[1])
+		b.WriteByte(0)
+	}
+	return b.Bytes()
+}
+
+var testMods = []Mod{
+	{Hash: 1, WorkshopID: 1559212036, Name: "CF"},
+	{Hash: 2, WorkshopID: 1590841260, Name: "Trader"},
+}
+
+func TestDayZMods(t *testing.T) {
+	payload := dayzModPayload(testMods)
+	half := len(payload) / 2
+	rules := map[string]string{
+		"allowedBuild":       "0",
+		string([]byte{1, 2}): string(payload[:half]),
+		string([]byte{2, 2}): string(payload[half:]),
+	}
+	got, err := DayZMods(rules)
+	if err != nil {
+		t.Fatalf("DayZMods() error = %v", err)
+	}
+	if len(got) != len(testMods) {
+		t.Fatalf("DayZMods() = %+v, want %+v", got, testMods)
+	}
+	for i := range testMods {
+		if got[i] != testMods[i] {
+			t.Errorf("DayZMods()[%d] = %+v, want %+v", i, got[i], testMods[i])
+		}
+	}
+
+	delete(rules, string([]byte{2, 2}))
+	if _, err := DayZMods(rules); err == nil {
+		t.Error("DayZMods() expected error for incomplete chunks")
+	}
+}
+
+func TestCompareMods(t *testing.T) {
+	dzsa := []model.Mods{{SteamWorkshopID: 1559212036}, {SteamWorkshopID: 111}}
+	missing, unexpected := CompareMods(dzsa, testMods)
+	if len(missing) != 1 || missing[0] != 1590841260 {
+		t.Errorf("missing = %v, want [1590841260]", missing)
+	}
+	if len(unexpected) != 1 || unexpected[0] != 111 {
+		t.Errorf("unexpected = %v, want [111]", unexpected)
+	}
+}
+
+func TestClient_Rules(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	challenge := []byte{0x11, 0x22, 0x33, 0x44}
+	rules := rulesPayload([][2]string{{"dedicated", "1"}, {"island", "chernarusplus"}})
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := buf[:n]
+			if bytes.Equal(req[5:9], challenge) {
+				_, _ = conn.WriteTo(append([]byte{0xFF, 0xFF, 0xFF, 0xFF, typeRulesResponse}, rules...), addr)
+				continue
+			}
+			_, _ = conn.WriteTo(append([]byte{0xFF, 0xFF, 0xFF, 0xFF, typeChallenge}, challenge...), addr)
+		}
+	}()
+
+	c := &Client{Timeout: 2 * time.Second}
+	got, err := c.Rules(context.Background(), conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Rules() error = %v", err)
+	}
+	if got["island"] != "chernarusplus" || got["dedicated"] != "1" {
+		t.Errorf("Rules() = %v", got)
+	}
+}
+
+func infoPayloadFor(name, mapName string, players, maxPlayers byte, gamePort uint16) []byte {
+	var b bytes.Buffer
+	b.WriteByte(0x11) // protocol
+	for _, s := range []string{name, mapName, "dayz", "DayZ"} {
+		b.WriteString(s)
+		b.WriteByte(0)
+	}
+	_ = binary.Write(&b, binary.LittleEndian, uint16(0))
+	b.Write([]byte{players, maxPlayers, 0, 'd', 'l', 0, 1})
+	b.WriteString("1.26.159040")
+	b.WriteByte(0)
+	b.WriteByte(edfPort)
+	_ = binary.Write(&b, binary.LittleEndian, gamePort)
+	return b.Bytes()
+}
+
+func TestClient_Info(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	challenge := []byte{0x11, 0x22, 0x33, 0x44}
+	info := infoPayloadFor("My Server", "chernarusplus", 12, 60, 2302)
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := buf[:n]
+			if !bytes.Equal(req[5:5+len(infoPayload)], infoPayload) {
+				continue
+			}
+			if bytes.Equal(req[5+len(infoPayload):], challenge) {
+				_, _ = conn.WriteTo(append([]byte{0xFF, 0xFF, 0xFF, 0xFF, typeInfoResponse}, info...), addr)
+				continue
+			}
+			_, _ = conn.WriteTo(append([]byte{0xFF, 0xFF, 0xFF, 0xFF, typeChallenge}, challenge...), addr)
+		}
+	}()
+
+	c := &Client{Timeout: 2 * time.Second}
+	got, err := c.Info(context.Background(), conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Info() error = %v", err)
+	}
+	want := Info{Name: "My Server", Map: "chernarusplus", Players: 12, MaxPlayers: 60, Version: "1.26.159040", GamePort: 2302}
+	if *got != want {
+		t.Errorf("Info() = %+v, want %+v", *got, want)
+	}
+
+	rtt, err := c.Ping(context.Background(), conn.LocalAddr().String())
+	if err != nil || rtt <= 0 || rtt > time.Second {
+		t.Errorf("Ping() = %v, %v", rtt, err)
+	}
+}
+
+func TestClient_PingTimeout(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	c := &Client{Timeout: 100 * time.Millisecond}
+	if _, err := c.Ping(context.Background(), conn.LocalAddr().String()); err == nil {
+		t.Error("Ping() of a silent server succeeded")
+	}
+}
+
+// serveInfo answers A2S_INFO requests with info, without a challenge, and returns the listener's port.
+func serveInfo(t *testing.T, info []byte) int {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			_, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = conn.WriteTo(append([]byte{0xFF, 0xFF, 0xFF, 0xFF, typeInfoResponse}, info...), addr)
+		}
+	}()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestClient_FindQueryPort(t *testing.T) {
+	other := serveInfo(t, infoPayloadFor("Other", "chernarusplus", 0, 60, 2402))
+	main := serveInfo(t, infoPayloadFor("Main", "chernarusplus", 0, 60, 2302))
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+
+	c := &Client{Timeout: 200 * time.Millisecond}
+	candidates := []int{silent.LocalAddr().(*net.UDPAddr).Port, other, main}
+	got, err := c.FindQueryPort(context.Background(), "127.0.0.1", 2302, candidates)
+	if err != nil || got != main {
+		t.Errorf("FindQueryPort() = %d, %v, want %d", got, err, main)
+	}
+	if _, err := c.FindQueryPort(context.Background(), "127.0.0.1", 2502, candidates); !errors.Is(err, ErrQueryPortNotFound) {
+		t.Errorf("FindQueryPort() of an unknown game port error = %v, want ErrQueryPortNotFound", err)
+	}
+}
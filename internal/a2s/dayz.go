@@ -0,0 +1,178 @@
+package a2s
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/bits"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/jsirianni/dzsa-sync/model"
+)
+
+// Mod is a workshop mod advertised in a DayZ server's A2S_RULES.
+type Mod struct {
+	Hash       uint32 `json:"hash"`
+	WorkshopID int    `json:"workshop_id"`
+	Name       string `json:"name"`
+}
+
+// ErrQueryPortNotFound is returned by FindQueryPort when no candidate port answers for the game port.
+var ErrQueryPortNotFound = errors.New("no query port answers for the game port")
+
+// FindQueryPort sends A2S_INFO to host at each candidate port in order and returns the first port whose
+// server reports gamePort as its game port. A port answered by another server on the host is skipped.
+func (c *Client) FindQueryPort(ctx context.Context, host string, gamePort int, candidates []int) (int, error) {
+	for _, port := range candidates {
+		info, err := c.Info(ctx, net.JoinHostPort(host, strconv.Itoa(port)))
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+			continue
+		}
+		if info.GamePort == gamePort {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("game port %d, tried %v: %w", gamePort, candidates, ErrQueryPortNotFound)
+}
+
+// DayZMods decodes the mod list that DayZ servers split across binary A2S_RULES entries.
+// Binary rule keys are two bytes (chunk index starting at 1, chunk count); the joined value is
+// escaped with 0x01 0x01 = 0x01, 0x01 0x02 = 0x00, 0x01 0x03 = 0xFF.
+func DayZMods(rules map[string]string) ([]Mod, error) {
+	payload, err := joinBinaryRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) == 0 {
+		return nil, nil
+	}
+	r := &reader{b: payload}
+	// protocol version, overflow flags
+	if _, err := r.bytes(2); err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	dlcFlags, err := r.uint16()
+	if err != nil {
+		return nil, fmt.Errorf("dlc flags: %w", err)
+	}
+	// One 4 byte hash per DLC flag set.
+	if _, err := r.bytes(4 * bits.OnesCount16(dlcFlags)); err != nil {
+		return nil, fmt.Errorf("dlc hashes: %w", err)
+	}
+	count, err := r.byte()
+	if err != nil {
+		return nil, fmt.Errorf("mod count: %w", err)
+	}
+	mods := make([]Mod, 0, count)
+	for i := 0; i < int(count); i++ {
+		var m Mod
+		if m.Hash, err = r.uint32(); err != nil {
+			return nil, fmt.Errorf("mod %d hash: %w", i, err)
+		}
+		idInfo, err := r.byte()
+		if err != nil {
+			return nil, fmt.Errorf("mod %d id length: %w", i, err)
+		}
+		// Low nibble is the ID length; high bits are flags (e.g. DLC).
+		idBytes, err := r.bytes(int(idInfo & 0x0F))
+		if err != nil {
+			return nil, fmt.Errorf("mod %d id: %w", i, err)
+		}
+		for j := len(idBytes) - 1; j >= 0; j-- {
+			m.WorkshopID = m.WorkshopID<<8 | int(idBytes[j])
+		}
+		nameLen, err := r.byte()
+		if err != nil {
+			return nil, fmt.Errorf("mod %d name length: %w", i, err)
+		}
+		name, err := r.bytes(int(nameLen))
+		if err != nil {
+			return nil, fmt.Errorf("mod %d name: %w", i, err)
+		}
+		m.Name = string(name)
+		mods = append(mods, m)
+	}
+	return mods, nil
+}
+
+func joinBinaryRules(rules map[string]string) ([]byte, error) {
+	chunks := make(map[int]string)
+	total := 0
+	for k, v := range rules {
+		if len(k) != 2 || k[0] == 0 || k[0] > k[1] {
+			continue
+		}
+		chunks[int(k[0])] = v
+		total = int(k[1])
+	}
+	if len(chunks) == 0 {
+		return nil, nil
+	}
+	if len(chunks) != total {
+		return nil, fmt.Errorf("incomplete mod rules: have %d of %d chunks", len(chunks), total)
+	}
+	var buf bytes.Buffer
+	for i := 1; i <= total; i++ {
+		v, ok := chunks[i]
+		if !ok {
+			return nil, fmt.Errorf("missing mod rules chunk %d", i)
+		}
+		buf.WriteString(v)
+	}
+	return unescape(buf.Bytes()), nil
+}
+
+func unescape(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		if b[i] == 0x01 && i+1 < len(b) {
+			switch b[i+1] {
+			case 0x01:
+				out = append(out, 0x01)
+				i++
+				continue
+			case 0x02:
+				out = append(out, 0x00)
+				i++
+				continue
+			case 0x03:
+				out = append(out, 0xFF)
+				i++
+				continue
+			}
+		}
+		out = append(out, b[i])
+	}
+	return out
+}
+
+// CompareMods compares the workshop IDs reported by DZSA against those advertised by the server.
+// missing lists IDs the server advertises that DZSA does not report; unexpected lists IDs DZSA
+// reports that the server does not advertise. Both are sorted.
+func CompareMods(dzsa []model.Mods, local []Mod) (missing, unexpected []int) {
+	remote := make(map[int]bool, len(dzsa))
+	for _, m := range dzsa {
+		remote[m.SteamWorkshopID] = true
+	}
+	seen := make(map[int]bool, len(local))
+	for _, m := range local {
+		seen[m.WorkshopID] = true
+		if !remote[m.WorkshopID] {
+			missing = append(missing, m.WorkshopID)
+		}
+	}
+	for id := range remote {
+		if !seen[id] {
+			unexpected = append(unexpected, id)
+		}
+	}
+	sort.Ints(missing)
+	sort.Ints(unexpected)
+	return missing, unexpected
+}
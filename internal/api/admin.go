@@ -0,0 +1,30 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+)
+
+// validToken reports whether r carries token as "Authorization: Bearer <token>".
+func validToken(r *http.Request, token string) bool {
+	got, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// requireToken rejects requests without the admin token with 401. An empty token accepts every request,
+// e.g. on the unix socket, which file permissions protect.
+func requireToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	if token == "" {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !validToken(r, token) {
+			httpError(w, r, errkind.Validation, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
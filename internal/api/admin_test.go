@@ -0,0 +1,125 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/backup"
+	"github.com/jsirianni/dzsa-sync/internal/configdiff"
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+)
+
+func TestReadOnlyAndAdmin(t *testing.T) {
+	syncer := &fakeSyncer{servers: []config.Server{{Name: "main", Port: 2424}}}
+	hooks := []config.Hook{{Name: "restart", Token: "hook-token", Action: config.HookActionSync}}
+	store := servers.New(nil)
+	opts := Options{MetricsHandler: http.NotFoundHandler(), Store: store, Syncer: syncer, Hooks: hooks, Backup: backup.New(backup.Options{Store: store}), ConfigDiff: fakeConfigDiff{}}
+	opts.Reload = func() (configdiff.Applied, error) { return configdiff.Applied{Added: []int{2425}}, nil }
+	do := func(srv *http.Server, method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	public := opts
+	public.Routes = RoutesReadOnly
+	srv := NewServer(public)
+	for _, path := range []string{"/api/v1/sync", "/api/v1/sync/2424", "/api/v1/hooks/restart", "/api/v1/backup", "/api/v1/restore", "/api/v1/reload"} {
+		if rec := do(srv, http.MethodPost, path, "hook-token"); rec.Code != http.StatusNotFound && rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("read-only POST %s = %d, want 404 or 405", path, rec.Code)
+		}
+	}
+	if rec := do(srv, http.MethodGet, "/api/v1/config/diff", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("read-only GET /api/v1/config/diff = %d, want 404", rec.Code)
+	}
+	if rec := do(srv, http.MethodGet, "/api/v1/status", ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"read_only":true`) {
+		t.Errorf("read-only GET /api/v1/status = %d %s", rec.Code, rec.Body.String())
+	}
+
+	admin := opts
+	admin.AdminToken = "admin-token"
+	srv = NewServer(admin)
+	tests := []struct {
+		path  string
+		token string
+		want  int
+	}{
+		{"/api/v1/sync", "", http.StatusUnauthorized},
+		{"/api/v1/sync/2424", "wrong", http.StatusUnauthorized},
+		{"/api/v1/sync/2424", "admin-token", http.StatusAccepted},
+		// Hooks keep their own tokens.
+		{"/api/v1/hooks/restart", "hook-token", http.StatusAccepted},
+		{"/api/v1/backup", "", http.StatusUnauthorized},
+		{"/api/v1/backup", "admin-token", http.StatusOK},
+		// An empty body is not an archive.
+		{"/api/v1/restore", "admin-token", http.StatusBadRequest},
+		{"/api/v1/reload", "", http.StatusUnauthorized},
+		{"/api/v1/reload", "admin-token", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rec := do(srv, http.MethodPost, tt.path, tt.token); rec.Code != tt.want {
+			t.Errorf("admin POST %s with %q = %d, want %d", tt.path, tt.token, rec.Code, tt.want)
+		}
+	}
+	if syncer.all != 1 || len(syncer.triggered) != 1 {
+		t.Errorf("TriggerAll calls = %d, triggered = %v", syncer.all, syncer.triggered)
+	}
+	// The diff shows the running and on-disk config, so it needs the admin token too.
+	if rec := do(srv, http.MethodGet, "/api/v1/config/diff", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("admin GET /api/v1/config/diff without a token = %d, want 401", rec.Code)
+	}
+	if rec := do(srv, http.MethodGet, "/api/v1/config/diff", "admin-token"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"path":"servers[0].port"`) {
+		t.Errorf("admin GET /api/v1/config/diff = %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+type fakeConfigDiff struct{}
+
+func (fakeConfigDiff) Diff() configdiff.Result {
+	return configdiff.Result{Path: "/etc/dzsa-sync/config.yaml", Pending: true, Changes: []config.Change{{Path: "servers[0].port", Old: 2424, New: 2425}}}
+}
+
+func TestRoutes(t *testing.T) {
+	syncer := &fakeSyncer{servers: []config.Server{{Name: "main", Port: 2424}}}
+	store := servers.New(nil)
+	opts := Options{MetricsHandler: http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), Store: store, Syncer: syncer, Backup: backup.New(backup.Options{Store: store}), UI: true}
+	tests := []struct {
+		routes Routes
+		method string
+		path   string
+		want   int
+	}{
+		{RoutesMetrics, http.MethodGet, "/metrics", http.StatusOK},
+		{RoutesMetrics, http.MethodGet, "/healthz", http.StatusOK},
+		{RoutesMetrics, http.MethodGet, "/api/v1/servers", http.StatusNotFound},
+		{RoutesMetrics, http.MethodGet, "/api/v1/status", http.StatusNotFound},
+		{RoutesMetrics, http.MethodPost, "/api/v1/sync", http.StatusNotFound},
+		{RoutesMetrics, http.MethodGet, "/ui/", http.StatusNotFound},
+		{RoutesAdmin, http.MethodGet, "/metrics", http.StatusNotFound},
+		{RoutesAdmin, http.MethodGet, "/healthz", http.StatusOK},
+		{RoutesAdmin, http.MethodGet, "/api/v1/servers", http.StatusNotFound},
+		{RoutesAdmin, http.MethodGet, "/api/v1/status", http.StatusOK},
+		{RoutesAdmin, http.MethodPost, "/api/v1/sync", http.StatusAccepted},
+		{RoutesAdmin, http.MethodPost, "/api/v1/backup", http.StatusOK},
+		{RoutesAdmin, http.MethodGet, "/ui/", http.StatusNotFound},
+		{"", http.MethodGet, "/metrics", http.StatusOK},
+		{"", http.MethodGet, "/api/v1/servers", http.StatusOK},
+		{"", http.MethodPost, "/api/v1/sync", http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		o := opts
+		o.Routes = tt.routes
+		rec := httptest.NewRecorder()
+		NewServer(o).Handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("routes %q: %s %s = %d, want %d", tt.routes, tt.method, tt.path, rec.Code, tt.want)
+		}
+	}
+}
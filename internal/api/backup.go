@@ -0,0 +1,63 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/backup"
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+)
+
+// maxRestoreBody caps the size of an uploaded backup archive.
+const maxRestoreBody = 1 << 30
+
+// backupTimeout replaces the server's read and write timeouts for backup and restore requests, which
+// move a whole history database.
+const backupTimeout = 5 * time.Minute
+
+// Backuper writes and restores archives of the instance's state.
+type Backuper interface {
+	Backup(ctx context.Context, w io.Writer) error
+	Restore(ctx context.Context, r io.Reader) (backup.Result, error)
+}
+
+// backupHandler serves POST /api/v1/backup. The archive is built in memory first, so a failure is reported
+// with an error status instead of a truncated download.
+func backupHandler(b Backuper) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(backupTimeout))
+		var buf bytes.Buffer
+		if err := b.Backup(r.Context(), &buf); err != nil {
+			httpError(w, r, errkind.Of(err), err.Error(), http.StatusInternalServerError)
+			return
+		}
+		name := "dzsa-sync-" + time.Now().UTC().Format("20060102T150405Z") + ".tar.gz"
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+		_, _ = buf.WriteTo(w)
+	}
+}
+
+// restoreHandler serves POST /api/v1/restore with an archive from POST /api/v1/backup as the body.
+func restoreHandler(b Backuper) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		_ = rc.SetReadDeadline(time.Now().Add(backupTimeout))
+		_ = rc.SetWriteDeadline(time.Now().Add(backupTimeout))
+		res, err := b.Restore(r.Context(), http.MaxBytesReader(w, r.Body, maxRestoreBody))
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errkind.Of(err) == errkind.Validation {
+				status = http.StatusBadRequest
+			}
+			httpError(w, r, errkind.Of(err), err.Error(), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(res)
+	}
+}
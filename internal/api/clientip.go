@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Headers reverse proxies set to the address of the client they forward for.
+const (
+	ForwardedForHeader = "X-Forwarded-For"
+	RealIPHeader       = "X-Real-IP"
+)
+
+type clientIPKey struct{}
+
+// ClientIP returns the address of the client of the request ctx belongs to, or the zero Addr outside an API
+// request or on a unix socket. Access logs, rate limits, and allowlists use it instead of the peer address.
+func ClientIP(ctx context.Context) netip.Addr {
+	addr, _ := ctx.Value(clientIPKey{}).(netip.Addr)
+	return addr
+}
+
+// clientIP returns the address of the client of r. Forwarding headers are only read when the peer is a
+// trusted proxy: X-Forwarded-For is walked from the right, skipping the trusted proxies in the chain, so a
+// client cannot pose as another by sending the header itself; X-Real-IP is used when X-Forwarded-For is
+// missing. Otherwise it is the peer address.
+func clientIP(trusted []netip.Prefix, r *http.Request) netip.Addr {
+	peer, ok := peerAddr(r.RemoteAddr)
+	if !ok || !contains(trusted, peer) {
+		return peer
+	}
+	if values := r.Header.Values(ForwardedForHeader); len(values) > 0 {
+		hops := strings.Split(strings.Join(values, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				// A malformed hop cannot be trusted, nor anything left of it.
+				return peer
+			}
+			addr = addr.Unmap()
+			if !contains(trusted, addr) || i == 0 {
+				return addr
+			}
+		}
+	}
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get(RealIPHeader))); err == nil {
+		return addr.Unmap()
+	}
+	return peer
+}
+
+// peerAddr parses the address of a connection's peer, which is empty on a unix socket.
+func peerAddr(remoteAddr string) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/jsirianni/dzsa-sync/internal/configdiff"
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+)
+
+// ConfigDiffer compares the config file on disk with the applied config.
+type ConfigDiffer interface {
+	Diff() configdiff.Result
+}
+
+// configDiffHandler serves GET /api/v1/config/diff.
+func configDiffHandler(d ConfigDiffer) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(d.Diff())
+	}
+}
+
+// reloadHandler serves POST /api/v1/reload. A config file that fails to load is answered with 422 and leaves
+// the running servers as they are.
+func reloadHandler(reload func() (configdiff.Applied, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		applied, err := reload()
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errkind.Of(err) == errkind.Config {
+				status = http.StatusUnprocessableEntity
+			}
+			httpError(w, r, errkind.Of(err), err.Error(), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(applied)
+	}
+}
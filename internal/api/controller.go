@@ -0,0 +1,166 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/buildinfo"
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+	"go.uber.org/zap"
+)
+
+// ErrNotFound is returned by Fleet.Sync for an unknown agent, or a port the agent does not manage.
+var ErrNotFound = errors.New("not found")
+
+// Fleet is the combined state of the agents a controller polls.
+type Fleet interface {
+	// Agents returns every configured agent with the outcome of its last poll.
+	Agents() []AgentStatus
+	// Servers returns the servers of every agent, from each agent's last successful poll.
+	Servers() []FleetServer
+	// Statuses returns the status of every agent's managed servers.
+	Statuses() []FleetServerStatus
+	// Sync asks the agent to sync port, or every server when port is 0. An empty agent asks every agent.
+	Sync(ctx context.Context, agent string, port int) error
+}
+
+// AgentStatus is an agent in GET /api/v1/agents and the controller's GET /api/v1/status.
+type AgentStatus struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Up is true when the last poll succeeded.
+	Up bool `json:"up"`
+	// LastSeen is the time of the last successful poll.
+	LastSeen time.Time `json:"last_seen,omitzero"`
+	// Error is the reason the last poll failed.
+	Error string `json:"error,omitempty"`
+	// InstanceName, Version, ExternalIP, and Role are reported by the agent.
+	InstanceName string `json:"instance_name,omitempty"`
+	Version      string `json:"version,omitempty"`
+	ExternalIP   string `json:"external_ip,omitempty"`
+	Role         string `json:"role,omitempty"`
+	// Servers is the number of servers the agent manages.
+	Servers int `json:"servers"`
+}
+
+// FleetServer is a server in the controller's GET /api/v1/servers: the agent's v1 server with the agent's name.
+type FleetServer struct {
+	Agent string `json:"agent"`
+	ServerV1
+}
+
+// FleetServerStatus is a server in the controller's GET /api/v1/status.
+type FleetServerStatus struct {
+	Agent string `json:"agent"`
+	ServerStatus
+}
+
+// FleetList is the body of the controller's GET /api/v1/servers.
+type FleetList struct {
+	InstanceName string        `json:"instance_name,omitempty"`
+	Servers      []FleetServer `json:"servers"`
+}
+
+// FleetStatusResponse is the body of the controller's GET /api/v1/status.
+type FleetStatusResponse struct {
+	InstanceName string        `json:"instance_name,omitempty"`
+	Version      string        `json:"version"`
+	Agents       []AgentStatus `json:"agents"`
+	// ReadOnly is true on a listener that does not accept sync requests.
+	ReadOnly bool                `json:"read_only,omitempty"`
+	Servers  []FleetServerStatus `json:"servers"`
+}
+
+// ControllerOptions configures the controller's API server.
+type ControllerOptions struct {
+	// Addr is the listen address (host:port).
+	Addr string
+	// MetricsHandler serves MetricsPath.
+	MetricsHandler http.Handler
+	// Fleet is the combined state of the agents.
+	Fleet Fleet
+	// InstanceName is included in list and status responses when set.
+	InstanceName string
+	// Redact rewrites every JSON response body when set, e.g. to hide IP addresses. Metrics are not rewritten.
+	Redact func(string) string
+	// Logger logs every request with its X-Request-ID when set.
+	Logger *zap.Logger
+	// TrustedProxies are the peers whose X-Request-ID is kept and whose forwarding headers give the client IP.
+	TrustedProxies []netip.Prefix
+	// UI serves the embedded web UI at UIPath, and redirects / to it, when true.
+	UI bool
+	// ReadOnly leaves out the sync endpoints, e.g. for a public listener.
+	ReadOnly bool
+	// AdminToken must be sent as "Authorization: Bearer <token>" to the sync endpoints when set.
+	AdminToken string
+}
+
+// NewControllerServer returns the API server of a controller: metrics, /healthz and /readyz, /api/v1/version,
+// the combined /api/v1/agents, /api/v1/servers, and /api/v1/status of every agent, POST /api/v1/sync and
+// POST /api/v1/agents/{agent}/sync[/{port}], which are forwarded to the agents unless opts.ReadOnly is set,
+// and the web UI when opts.UI is set.
+func NewControllerServer(opts ControllerOptions) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, opts.MetricsHandler)
+	mux.HandleFunc("GET /healthz", healthzHandler)
+	mux.HandleFunc("GET /readyz", readyzHandler(nil, false, nil, nil, nil))
+	mux.HandleFunc("GET /api/v1/version", versionHandler)
+	mux.HandleFunc("GET /api/v1/agents", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(opts.Fleet.Agents())
+	})
+	mux.HandleFunc("GET /api/v1/servers", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(FleetList{InstanceName: opts.InstanceName, Servers: opts.Fleet.Servers()})
+	})
+	mux.HandleFunc("GET /api/v1/status", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(FleetStatusResponse{
+			InstanceName: opts.InstanceName,
+			Version:      buildinfo.Get().Version,
+			Agents:       opts.Fleet.Agents(),
+			ReadOnly:     opts.ReadOnly,
+			Servers:      opts.Fleet.Statuses(),
+		})
+	})
+	if !opts.ReadOnly {
+		sync := requireToken(opts.AdminToken, fleetSyncHandler(opts.Fleet))
+		mux.HandleFunc("POST /api/v1/sync", sync)
+		mux.HandleFunc("POST /api/v1/agents/{agent}/sync", sync)
+		mux.HandleFunc("POST /api/v1/agents/{agent}/sync/{port}", sync)
+	}
+	if opts.UI {
+		mux.Handle("GET "+UIPath, uiHandler())
+		mux.Handle("GET /{$}", http.RedirectHandler(UIPath, http.StatusFound))
+	}
+	return newHTTPServer(opts.Addr, mux, opts.Redact, opts.TrustedProxies, opts.Logger)
+}
+
+// fleetSyncHandler forwards a sync request to the agent in the path, or to every agent when there is none.
+func fleetSyncHandler(fleet Fleet) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var port int
+		if v := r.PathValue("port"); v != "" {
+			p, err := strconv.Atoi(v)
+			if err != nil {
+				httpError(w, r, errkind.Validation, "invalid port", http.StatusBadRequest)
+				return
+			}
+			port = p
+		}
+		if err := fleet.Sync(r.Context(), r.PathValue("agent"), port); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				httpError(w, r, errkind.Validation, err.Error(), http.StatusNotFound)
+				return
+			}
+			httpError(w, r, errkind.Upstream, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeFleet struct {
+	synced []string
+}
+
+func (f *fakeFleet) Agents() []AgentStatus {
+	return []AgentStatus{{Name: "box-1", URL: "http://box-1:8888", Up: true, Servers: 1}, {Name: "box-2", URL: "http://box-2:8888", Error: "do request: refused"}}
+}
+
+func (f *fakeFleet) Servers() []FleetServer {
+	return []FleetServer{{Agent: "box-1", ServerV1: ServerV1{Port: 2424, Fingerprint: "abc"}}}
+}
+
+func (f *fakeFleet) Statuses() []FleetServerStatus {
+	return []FleetServerStatus{{Agent: "box-1", ServerStatus: ServerStatus{Name: "main", Port: 2424}}}
+}
+
+func (f *fakeFleet) Sync(_ context.Context, agent string, port int) error {
+	switch {
+	case agent == "box-9":
+		return fmt.Errorf("agent %s: %w", agent, ErrNotFound)
+	case agent == "box-2":
+		return errors.New("agent box-2: do request: refused")
+	}
+	f.synced = append(f.synced, fmt.Sprintf("%s/%d", agent, port))
+	return nil
+}
+
+func TestControllerServer(t *testing.T) {
+	fleet := &fakeFleet{}
+	srv := NewControllerServer(ControllerOptions{MetricsHandler: http.NotFoundHandler(), Fleet: fleet, InstanceName: "fleet", UI: true})
+	do := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/api/v1/servers")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/v1/servers = %d", rec.Code)
+	}
+	// The agent's v1 server is flattened next to the agent name.
+	if want := `{"instance_name":"fleet","servers":[{"agent":"box-1","port":2424,"result":null,"fingerprint":"abc"}]}`; strings.TrimSpace(rec.Body.String()) != want {
+		t.Errorf("GET /api/v1/servers = %s, want %s", rec.Body.String(), want)
+	}
+
+	var status FleetStatusResponse
+	rec = do(http.MethodGet, "/api/v1/status")
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if status.InstanceName != "fleet" || len(status.Agents) != 2 || len(status.Servers) != 1 || status.Servers[0].Agent != "box-1" {
+		t.Errorf("GET /api/v1/status = %+v", status)
+	}
+	if rec := do(http.MethodGet, "/api/v1/agents"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"error":"do request: refused"`) {
+		t.Errorf("GET /api/v1/agents = %d %s", rec.Code, rec.Body.String())
+	}
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/api/v1/sync", http.StatusAccepted},
+		{"/api/v1/agents/box-1/sync", http.StatusAccepted},
+		{"/api/v1/agents/box-1/sync/2424", http.StatusAccepted},
+		{"/api/v1/agents/box-1/sync/abc", http.StatusBadRequest},
+		{"/api/v1/agents/box-9/sync", http.StatusNotFound},
+		{"/api/v1/agents/box-2/sync", http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		if rec := do(http.MethodPost, tt.path); rec.Code != tt.want {
+			t.Errorf("POST %s = %d, want %d", tt.path, rec.Code, tt.want)
+		}
+	}
+	if want := []string{"/0", "box-1/0", "box-1/2424"}; fmt.Sprint(fleet.synced) != fmt.Sprint(want) {
+		t.Errorf("synced %v, want %v", fleet.synced, want)
+	}
+
+	if rec := do(http.MethodGet, UIPath); rec.Code != http.StatusOK {
+		t.Errorf("GET %s = %d", UIPath, rec.Code)
+	}
+}
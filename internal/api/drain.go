@@ -0,0 +1,22 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+)
+
+// unlessDraining rejects requests that change state with 503 while the daemon shuts down, since no sync would
+// run for them. A nil draining accepts every request.
+func unlessDraining(draining func() bool, next http.HandlerFunc) http.HandlerFunc {
+	if draining == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if draining() {
+			httpError(w, r, errkind.Internal, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+)
+
+// ReadyResponse is the body of GET /readyz.
+type ReadyResponse struct {
+	Ready bool `json:"ready"`
+	// Reason explains why the daemon is not ready.
+	Reason string `json:"reason,omitempty"`
+}
+
+// healthzHandler reports that the process is up and serving HTTP.
+func healthzHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// readyzHandler reports whether the daemon can register servers: it is ready once the external IP
+// is known. Sync failures do not affect readiness, since they are usually on the DZSA side and
+// restarting the daemon would not fix them. With requireSync, readiness also waits until every server
+// managed by syncer synced successfully once, except on an HA follower, which does not sync.
+func readyzHandler(address func() string, requireSync bool, store *servers.Store, syncer Syncer, elector Elector) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		resp := ReadyResponse{Ready: true}
+		if address != nil && address() == "" {
+			resp = ReadyResponse{Reason: "external IP not detected yet"}
+		} else if requireSync && syncer != nil && (elector == nil || elector.IsLeader()) {
+			if name := firstUnsynced(store, syncer); name != "" {
+				resp = ReadyResponse{Reason: fmt.Sprintf("server %s has not synced successfully yet", name)}
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !resp.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// firstUnsynced returns the name of a managed server that never synced successfully, or "" when every server has.
+func firstUnsynced(store *servers.Store, syncer Syncer) string {
+	for _, srv := range syncer.Servers() {
+		if st, ok := store.GetSyncState(srv.Port); !ok || st.LastSuccess.IsZero() {
+			return srv.Name
+		}
+	}
+	return ""
+}
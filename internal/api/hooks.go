@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+)
+
+// Syncer triggers syncs for managed servers. *worker.Manager implements it.
+type Syncer interface {
+	// Trigger requests an immediate sync for the port and returns false if it is not managed.
+	Trigger(port int) bool
+	// TriggerAll requests an immediate sync for every server.
+	TriggerAll()
+	// Servers returns the managed servers.
+	Servers() []config.Server
+}
+
+type hookResponse struct {
+	InstanceName string `json:"instance_name,omitempty"`
+	Hook         string `json:"hook"`
+	Action       string `json:"action"`
+	Ports        []int  `json:"ports"`
+	// Until is the end of the maintenance window for maintenance hooks.
+	Until *time.Time `json:"until,omitempty"`
+}
+
+// hooksHandler serves POST /api/v1/hooks/{name}. The request must carry the hook's token as a bearer token.
+// Maintenance hooks accept an optional duration query parameter (e.g. ?duration=30m).
+func hooksHandler(hooks []config.Hook, store *servers.Store, syncer Syncer, instanceName string) http.HandlerFunc {
+	byName := make(map[string]config.Hook, len(hooks))
+	for _, h := range hooks {
+		byName[h.Name] = h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		hook, ok := byName[r.PathValue("name")]
+		if !ok {
+			httpError(w, r, errkind.Validation, "hook not found", http.StatusNotFound)
+			return
+		}
+		if !validToken(r, hook.Token) {
+			httpError(w, r, errkind.Validation, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var ports []int
+		if hook.Port != 0 {
+			ports = []int{hook.Port}
+		} else {
+			for _, srv := range syncer.Servers() {
+				ports = append(ports, srv.Port)
+			}
+		}
+		resp := hookResponse{InstanceName: instanceName, Hook: hook.Name, Action: hook.Action, Ports: ports}
+
+		switch hook.Action {
+		case config.HookActionSync:
+			for _, port := range ports {
+				store.ClearMaintenance(port)
+			}
+			if hook.Port != 0 {
+				if !syncer.Trigger(hook.Port) {
+					httpError(w, r, errkind.Validation, "server not found", http.StatusNotFound)
+					return
+				}
+			} else {
+				syncer.TriggerAll()
+			}
+		case config.HookActionMaintenance:
+			d := hook.Duration
+			if d <= 0 {
+				d = config.DefaultHookMaintenance
+			}
+			if v := r.URL.Query().Get("duration"); v != "" {
+				parsed, err := time.ParseDuration(v)
+				if err != nil || parsed <= 0 {
+					httpError(w, r, errkind.Validation, errInvalidParam("duration").Error(), http.StatusBadRequest)
+					return
+				}
+				d = parsed
+			}
+			until := time.Now().Add(d).UTC()
+			for _, port := range ports {
+				store.SetMaintenance(port, servers.Maintenance{Until: until, Reason: "hook:" + hook.Name})
+			}
+			resp.Until = &until
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
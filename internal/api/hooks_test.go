@@ -0,0 +1,89 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+)
+
+type fakeSyncer struct {
+	servers   []config.Server
+	triggered []int
+	all       int
+}
+
+func (f *fakeSyncer) Trigger(port int) bool {
+	for _, s := range f.servers {
+		if s.Port == port {
+			f.triggered = append(f.triggered, port)
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeSyncer) TriggerAll() { f.all++ }
+
+func (f *fakeSyncer) Servers() []config.Server { return f.servers }
+
+func TestHooksHandler(t *testing.T) {
+	hooks := []config.Hook{
+		{Name: "restart-main", Token: "secret", Action: config.HookActionMaintenance, Port: 2424, Duration: time.Minute},
+		{Name: "started-main", Token: "secret", Action: config.HookActionSync, Port: 2424},
+		{Name: "sync-all", Token: "other", Action: config.HookActionSync},
+	}
+	store := servers.New([]int{2424, 2324})
+	syncer := &fakeSyncer{servers: []config.Server{{Name: "modded", Port: 2324}, {Name: "main", Port: 2424}}}
+	srv := NewServer(Options{MetricsHandler: http.NotFoundHandler(), Store: store, Hooks: hooks, Syncer: syncer})
+
+	do := func(path, token string) int {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := do("/api/v1/hooks/restart-main", ""); code != http.StatusUnauthorized {
+		t.Errorf("missing token status = %d, want 401", code)
+	}
+	if code := do("/api/v1/hooks/restart-main", "other"); code != http.StatusUnauthorized {
+		t.Errorf("wrong token status = %d, want 401", code)
+	}
+	if code := do("/api/v1/hooks/unknown", "secret"); code != http.StatusNotFound {
+		t.Errorf("unknown hook status = %d, want 404", code)
+	}
+	if code := do("/api/v1/hooks/restart-main?duration=bad", "secret"); code != http.StatusBadRequest {
+		t.Errorf("bad duration status = %d, want 400", code)
+	}
+
+	if code := do("/api/v1/hooks/restart-main", "secret"); code != http.StatusAccepted {
+		t.Fatalf("maintenance status = %d, want 202", code)
+	}
+	if !store.InMaintenance(2424, time.Now()) || store.InMaintenance(2324, time.Now()) {
+		t.Error("expected maintenance window on 2424 only")
+	}
+
+	if code := do("/api/v1/hooks/started-main", "secret"); code != http.StatusAccepted {
+		t.Fatalf("sync status = %d, want 202", code)
+	}
+	if store.InMaintenance(2424, time.Now()) {
+		t.Error("sync hook should clear the maintenance window")
+	}
+	if len(syncer.triggered) != 1 || syncer.triggered[0] != 2424 {
+		t.Errorf("triggered = %v, want [2424]", syncer.triggered)
+	}
+
+	if code := do("/api/v1/hooks/sync-all", "other"); code != http.StatusAccepted {
+		t.Fatalf("sync-all status = %d, want 202", code)
+	}
+	if syncer.all != 1 {
+		t.Errorf("TriggerAll calls = %d, want 1", syncer.all)
+	}
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+)
+
+// HA roles reported in StatusResponse.Role.
+const (
+	RoleLeader   = "leader"
+	RoleFollower = "follower"
+)
+
+// Elector reports whether this instance is the HA leader.
+type Elector interface {
+	IsLeader() bool
+	// Leader returns the ID of the current leader, or "" when unknown.
+	Leader() string
+}
+
+// leaderOnly rejects requests that change sync state with 503 on a follower, since a follower does not sync.
+// Clients should retry against the leader named in the response.
+func leaderOnly(elector Elector, next http.HandlerFunc) http.HandlerFunc {
+	if elector == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !elector.IsLeader() {
+			msg := "not the leader"
+			if l := elector.Leader(); l != "" {
+				msg += "; leader is " + l
+			}
+			httpError(w, r, errkind.Internal, msg, http.StatusServiceUnavailable)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// redactResponses buffers each response of h and writes it through redact. Metrics pass through unchanged, and
+// the server stream, which cannot be buffered, redacts each of its events.
+func redactResponses(redact func(string) string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == MetricsPath || r.URL.Path == StreamPath {
+			h.ServeHTTP(w, r)
+			return
+		}
+		rw := &bufferedWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rw, r)
+		w.Header().Del("Content-Length")
+		w.WriteHeader(rw.status)
+		_, _ = io.WriteString(w, redact(rw.body.String()))
+	})
+}
+
+// bufferedWriter holds the status and body of a response until the handler returns.
+type bufferedWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(status int) { w.status = status }
+
+func (w *bufferedWriter) Write(b []byte) (int, error) { return w.body.Write(b) }
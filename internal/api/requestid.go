@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader carries the ID of an API request. It is set on every response and logged with the request.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds IDs accepted from trusted proxies.
+const maxRequestIDLen = 128
+
+type requestIDKey struct{}
+
+// RequestID returns the ID of the request ctx belongs to, or "" outside an API request.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// ParseTrustedProxies parses IP addresses and CIDR prefixes, e.g. from api.trusted_proxies.
+func ParseTrustedProxies(proxies []string) ([]netip.Prefix, error) {
+	out := make([]netip.Prefix, 0, len(proxies))
+	for _, p := range proxies {
+		if prefix, err := netip.ParsePrefix(p); err == nil {
+			out = append(out, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", p)
+		}
+		out = append(out, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return out, nil
+}
+
+// requestIDs gives every request an ID, taken from X-Request-ID when the peer is a trusted proxy and the
+// ID is well formed, and generated otherwise. The ID is echoed in the response header, added to error
+// responses, and logged with the method, path, status, duration, and client IP when logger is set. The client
+// IP is resolved once here, see clientIP.
+func requestIDs(trusted []netip.Prefix, logger *zap.Logger, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) || !isTrusted(trusted, r.RemoteAddr) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		client := clientIP(trusted, r)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		ctx = context.WithValue(ctx, clientIPKey{}, client)
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sw, r.WithContext(ctx))
+		if logger == nil {
+			return
+		}
+		clientField := zap.Skip()
+		if client.IsValid() {
+			clientField = zap.Stringer("client_ip", client)
+		}
+		log := logger.Debug
+		if sw.status >= http.StatusBadRequest {
+			log = logger.Info
+		}
+		log("api request",
+			zap.String("request_id", id),
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.Int("status", sw.status),
+			zap.Duration("duration", time.Since(start)),
+			zap.String("remote_addr", r.RemoteAddr),
+			clientField)
+	})
+}
+
+// httpError is http.Error with the error kind prefixed, so API clients can tell bad input from a server-side
+// failure, and the request ID appended, so they can quote it when reporting a failure.
+func httpError(w http.ResponseWriter, r *http.Request, kind errkind.Kind, msg string, status int) {
+	msg = string(kind) + ": " + msg
+	if id := RequestID(r.Context()); id != "" {
+		msg += " (request_id " + id + ")"
+	}
+	http.Error(w, msg, status)
+}
+
+func isTrusted(trusted []netip.Prefix, remoteAddr string) bool {
+	addr, ok := peerAddr(remoteAddr)
+	return ok && contains(trusted, addr)
+}
+
+// validRequestID accepts printable IDs without spaces, so a forwarded ID cannot break log lines or headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	return !strings.ContainsFunc(id, func(c rune) bool { return c <= ' ' || c > '~' })
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// statusWriter records the status code written by a handler.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the connection, e.g. to flush a stream.
+func (w *statusWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
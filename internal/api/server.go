@@ -2,69 +2,411 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"net/netip"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/buildinfo"
+	"github.com/jsirianni/dzsa-sync/internal/configdiff"
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+	"github.com/jsirianni/dzsa-sync/internal/history"
 	"github.com/jsirianni/dzsa-sync/internal/servers"
+	"go.uber.org/zap"
 )
 
 // MetricsPath is the path for the Prometheus metrics handler.
 const MetricsPath = "/metrics"
 
-// NewServer returns an HTTP server that serves metrics at MetricsPath and JSON API at /api/v1/servers and /api/v1/servers/<port>.
-func NewServer(addr string, metricsHandler http.Handler, store *servers.Store) *http.Server {
+// Routes is a set of endpoints a listener serves. Health checks are part of every set.
+type Routes string
+
+// Route sets, named like the routes of config.ListenerConfig.
+const (
+	// RoutesFull serves every endpoint.
+	RoutesFull Routes = "full"
+	// RoutesReadOnly leaves out the endpoints that change state (sync, hooks, backup, restore, and reload) and the
+	// config diff, e.g. for a public listener.
+	RoutesReadOnly Routes = "read_only"
+	// RoutesMetrics serves only the metrics and health checks, e.g. for a scraper on another network.
+	RoutesMetrics Routes = "metrics"
+	// RoutesAdmin serves only the endpoints RoutesReadOnly leaves out, the version, and the status.
+	RoutesAdmin Routes = "admin"
+)
+
+// Options configures the API server.
+type Options struct {
+	// Addr is the listen address (host:port).
+	Addr string
+	// MetricsHandler serves MetricsPath.
+	MetricsHandler http.Handler
+	// Store holds the latest sync result per port.
+	Store *servers.Store
+	// History serves /api/v1/history when set.
+	History history.Reader
+	// Hooks are served at POST /api/v1/hooks/{name} when set. Syncer is required with hooks.
+	Hooks []config.Hook
+	// Syncer serves POST /api/v1/sync and GET /api/v1/status, and triggers syncs for hooks.
+	Syncer Syncer
+	// Servers serves POST /api/v1/servers and DELETE /api/v1/servers/{port} when set.
+	Servers ServerEditor
+	// Address returns the current external IP for GET /api/v1/status. /readyz fails while it returns "".
+	Address func() string
+	// Address6 returns the current external IPv6 address for GET /api/v1/status when set.
+	Address6 func() string
+	// ReadyRequiresSync also fails /readyz until every server of Syncer synced successfully once.
+	ReadyRequiresSync bool
+	// AddressFlapping reports whether the external IP is flapping for GET /api/v1/status when set.
+	AddressFlapping func() bool
+	// InstanceName is included in list, status, sync, and hook responses when set.
+	InstanceName string
+	// Started is when the daemon started, reported with its uptime in GET /api/v1/status. Zero uses the time
+	// NewServer is called.
+	Started time.Time
+	// SyncTarget is reported in GET /api/v1/status when syncs do not go to the live DZSA listing: a staging
+	// URL, or "dry_run".
+	SyncTarget string
+	// Elector reports HA leadership when set. Followers reject sync and hook requests.
+	Elector Elector
+	// Redact rewrites every JSON response body when set, e.g. to hide IP addresses. Metrics are not rewritten.
+	Redact func(string) string
+	// Logger logs every request with its X-Request-ID when set: failed requests at info, others at debug.
+	Logger *zap.Logger
+	// TrustedProxies are the peers whose X-Request-ID is kept and whose forwarding headers give the client IP.
+	// Requests from other peers get a new ID, and their peer address is the client IP.
+	TrustedProxies []netip.Prefix
+	// UI serves the embedded web UI at UIPath, and redirects / to it, when true.
+	UI bool
+	// Backup serves POST /api/v1/backup and POST /api/v1/restore when set.
+	Backup Backuper
+	// ConfigDiff serves GET /api/v1/config/diff when set.
+	ConfigDiff ConfigDiffer
+	// Reload serves POST /api/v1/reload when set: it applies the server list of the config file.
+	Reload func() (configdiff.Applied, error)
+	// Draining reports whether the daemon is shutting down when set. Sync, hook, and restore requests are then
+	// rejected with 503.
+	Draining func() bool
+	// Routes selects the endpoints served. Empty serves all of them.
+	Routes Routes
+	// AdminToken must be sent as "Authorization: Bearer <token>" to POST /api/v1/sync, backup, restore, and
+	// reload when set. Hooks keep their own tokens.
+	AdminToken string
+}
+
+// NewServer returns an HTTP server that serves metrics at MetricsPath, /healthz and /readyz, JSON API at /api/v1/version, /api/v1/servers, /api/v1/servers/<port>, and /api/v1/servers/<port>/history, and the server stream at StreamPath.
+// When opts.History is set, /api/v1/history, /api/v1/history/hourly, and /api/v1/history/daily are also served, when opts.Syncer is set, POST /api/v1/sync[/{port}] and GET /api/v1/status, when opts.Hooks is set, POST /api/v1/hooks/{name}, and when opts.UI is set, the web UI.
+// When opts.Backup is set, POST /api/v1/backup and POST /api/v1/restore are served, when opts.ConfigDiff
+// is set, GET /api/v1/config/diff, when opts.Reload is set, POST /api/v1/reload, and when opts.Servers is set,
+// POST /api/v1/servers and DELETE /api/v1/servers/{port}. opts.Routes narrows these down to a route set.
+// Every response carries an X-Request-ID header.
+func NewServer(opts Options) *http.Server {
+	routes := opts.Routes
+	if routes == "" {
+		routes = RoutesFull
+	}
+	read := routes == RoutesFull || routes == RoutesReadOnly
+	write := routes == RoutesFull || routes == RoutesAdmin
+	started := opts.Started
+	if started.IsZero() {
+		started = time.Now()
+	}
+
+	// streams is cancelled on Shutdown, so open streams end instead of holding it up.
+	streams, closeStreams := context.WithCancel(context.Background())
 	mux := http.NewServeMux()
-	mux.Handle(MetricsPath, metricsHandler)
-	mux.HandleFunc("GET /api/v1/servers", listHandler(store))
-	mux.HandleFunc("GET /api/v1/servers/", singleHandler(store))
+	mux.HandleFunc("GET /healthz", healthzHandler)
+	mux.HandleFunc("GET /readyz", readyzHandler(opts.Address, opts.ReadyRequiresSync, opts.Store, opts.Syncer, opts.Elector))
+	if read || routes == RoutesMetrics {
+		mux.Handle(MetricsPath, opts.MetricsHandler)
+	}
+	if routes != RoutesMetrics {
+		mux.HandleFunc("GET /api/v1/version", versionHandler)
+	}
+	if read {
+		mux.HandleFunc("GET /api/v1/servers", listHandler(opts.Store, opts.InstanceName))
+		mux.HandleFunc("GET /api/v1/servers/", singleHandler(opts.Store))
+		mux.HandleFunc("GET /api/v1/servers/{port}/history", serverHistoryHandler(opts.Store))
+		mux.HandleFunc("GET "+StreamPath, streamHandler(streams, opts.Store, opts.InstanceName, opts.Redact))
+	}
+	if opts.History != nil && read {
+		mux.HandleFunc("GET /api/v1/history", historyHandler(opts.History))
+		mux.HandleFunc("GET /api/v1/history/hourly", aggregateHandler(opts.History, time.Hour, 24*time.Hour))
+		mux.HandleFunc("GET /api/v1/history/daily", aggregateHandler(opts.History, 24*time.Hour, 30*24*time.Hour))
+	}
+	if opts.Syncer != nil {
+		if write {
+			sync := requireToken(opts.AdminToken, unlessDraining(opts.Draining, leaderOnly(opts.Elector, syncHandler(opts.Syncer, opts.InstanceName))))
+			mux.HandleFunc("POST /api/v1/sync", sync)
+			mux.HandleFunc("POST /api/v1/sync/{port}", sync)
+		}
+		if routes != RoutesMetrics {
+			mux.HandleFunc("GET /api/v1/status", statusHandler(opts.Store, opts.Syncer, opts.Address, opts.Address6, opts.AddressFlapping, opts.InstanceName, opts.SyncTarget, opts.Elector, started, !write))
+		}
+	}
+	if opts.Backup != nil && write {
+		mux.HandleFunc("POST /api/v1/backup", requireToken(opts.AdminToken, backupHandler(opts.Backup)))
+		mux.HandleFunc("POST /api/v1/restore", requireToken(opts.AdminToken, unlessDraining(opts.Draining, restoreHandler(opts.Backup))))
+	}
+	if opts.ConfigDiff != nil && write {
+		mux.HandleFunc("GET /api/v1/config/diff", requireToken(opts.AdminToken, configDiffHandler(opts.ConfigDiff)))
+	}
+	if opts.Servers != nil && write {
+		mux.HandleFunc("POST /api/v1/servers", requireToken(opts.AdminToken, unlessDraining(opts.Draining, addServerHandler(opts.Servers, opts.InstanceName))))
+		mux.HandleFunc("DELETE /api/v1/servers/{port}", requireToken(opts.AdminToken, unlessDraining(opts.Draining, removeServerHandler(opts.Servers, opts.InstanceName))))
+	}
+	if opts.Reload != nil && write {
+		mux.HandleFunc("POST /api/v1/reload", requireToken(opts.AdminToken, unlessDraining(opts.Draining, reloadHandler(opts.Reload))))
+	}
+	if len(opts.Hooks) > 0 && write {
+		mux.HandleFunc("POST /api/v1/hooks/{name}", unlessDraining(opts.Draining, leaderOnly(opts.Elector, hooksHandler(opts.Hooks, opts.Store, opts.Syncer, opts.InstanceName))))
+	}
+	if opts.UI && read {
+		mux.Handle("GET "+UIPath, uiHandler())
+		mux.Handle("GET /{$}", http.RedirectHandler(UIPath, http.StatusFound))
+	}
+
+	srv := newHTTPServer(opts.Addr, mux, opts.Redact, opts.TrustedProxies, opts.Logger)
+	srv.RegisterOnShutdown(closeStreams)
+	return srv
+}
 
+// newHTTPServer wraps mux with response redaction and request IDs and returns a server with the API's timeouts.
+func newHTTPServer(addr string, mux *http.ServeMux, redact func(string) string, trusted []netip.Prefix, logger *zap.Logger) *http.Server {
+	var handler http.Handler = mux
+	if redact != nil {
+		handler = redactResponses(redact, mux)
+	}
+	handler = requestIDs(trusted, logger, handler)
 	return &http.Server{
 		Addr:              addr,
-		Handler:            mux,
-		ReadHeaderTimeout:  10 * time.Second,
-		ReadTimeout:        10 * time.Second,
-		WriteTimeout:       10 * time.Second,
-		IdleTimeout:        60 * time.Second,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		IdleTimeout:       60 * time.Second,
 	}
 }
 
-func listHandler(store *servers.Store) http.HandlerFunc {
+// listHandler serves the server list. The full list is encoded once per store version and tagged with it,
+// so status pages that poll can send If-None-Match, or ask for ?since=<version> to get only what changed.
+func listHandler(store *servers.Store, instanceName string) http.HandlerFunc {
+	var (
+		mu      sync.Mutex
+		version uint64
+		body    []byte
+	)
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
-			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			httpError(w, r, errkind.Validation, "Method Not Allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		entries := store.GetAll()
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]any{"servers": entries})
+		if v := r.URL.Query().Get("since"); v != "" {
+			since, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				httpError(w, r, errkind.Validation, "invalid since", http.StatusBadRequest)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(v1Delta(instanceName, store.Changes(since)))
+			return
+		}
+
+		all, current := store.GetAllWithVersion()
+		etag := `"` + strconv.FormatUint(current, 10) + `"`
+		w.Header().Set("ETag", etag)
+		if strings.Contains(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		mu.Lock()
+		if body == nil || version != current {
+			b, err := json.Marshal(v1Delta(instanceName, servers.Delta{Version: current, Servers: all}))
+			if err != nil {
+				mu.Unlock()
+				httpError(w, r, errkind.Internal, "encode servers", http.StatusInternalServerError)
+				return
+			}
+			body, version = append(b, '\n'), current
+		}
+		b := body
+		mu.Unlock()
+		_, _ = w.Write(b)
 	}
 }
 
 func singleHandler(store *servers.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
-			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			httpError(w, r, errkind.Validation, "Method Not Allowed", http.StatusMethodNotAllowed)
 			return
 		}
 		suffix := strings.TrimPrefix(r.URL.Path, "/api/v1/servers/")
 		if suffix == "" || strings.Contains(suffix, "/") {
-			http.NotFound(w, r)
+			httpError(w, r, errkind.Validation, "server not found", http.StatusNotFound)
 			return
 		}
 		port, err := strconv.Atoi(suffix)
 		if err != nil {
-			http.Error(w, "invalid port", http.StatusBadRequest)
+			httpError(w, r, errkind.Validation, "invalid port", http.StatusBadRequest)
 			return
 		}
 		result, ok := store.Get(port)
 		if !ok {
-			http.NotFound(w, r)
+			httpError(w, r, errkind.Validation, "server not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v1Result(result))
+	}
+}
+
+// serverHistoryHandler serves the recent results the store kept for the port in the path.
+func serverHistoryHandler(store *servers.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		port, err := strconv.Atoi(r.PathValue("port"))
+		if err != nil {
+			httpError(w, r, errkind.Validation, "invalid port", http.StatusBadRequest)
+			return
+		}
+		h, ok := store.History(port)
+		if !ok {
+			httpError(w, r, errkind.Validation, "server not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v1History(port, h))
+	}
+}
+
+// versionHandler serves the build metadata of the running binary.
+func versionHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(buildinfo.Get())
+}
+
+// syncResponse is the body of POST /api/v1/sync.
+type syncResponse struct {
+	InstanceName string `json:"instance_name,omitempty"`
+	// Ports are the query ports of the servers whose sync was requested.
+	Ports []int `json:"ports"`
+}
+
+// syncHandler triggers an immediate sync for the port in the path, or for every server when there is none,
+// and answers 202 with the triggered ports. The syncs run in the background; GET /api/v1/status shows them.
+func syncHandler(syncer Syncer, instanceName string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := syncResponse{InstanceName: instanceName, Ports: []int{}}
+		if v := r.PathValue("port"); v == "" {
+			syncer.TriggerAll()
+			for _, srv := range syncer.Servers() {
+				resp.Ports = append(resp.Ports, srv.Port)
+			}
+		} else {
+			port, err := strconv.Atoi(v)
+			if err != nil {
+				httpError(w, r, errkind.Validation, "invalid port", http.StatusBadRequest)
+				return
+			}
+			if !syncer.Trigger(port) {
+				httpError(w, r, errkind.Validation, "server not found", http.StatusNotFound)
+				return
+			}
+			resp.Ports = append(resp.Ports, port)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// historyHandler serves records in [from, to] (RFC 3339, default the last 24 hours), optionally filtered by port.
+func historyHandler(reader history.Reader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseHistoryQuery(r, 24*time.Hour)
+		if err != nil {
+			httpError(w, r, errkind.Validation, err.Error(), http.StatusBadRequest)
+			return
+		}
+		records, err := reader.Query(r.Context(), q)
+		if err != nil {
+			httpError(w, r, errkind.Internal, "history query failed", http.StatusInternalServerError)
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(result)
+		_ = json.NewEncoder(w).Encode(map[string]any{"history": records})
 	}
 }
+
+// aggregateQueryLimit caps the records reduced for one aggregate request, e.g. a month of syncs every minute.
+const aggregateQueryLimit = 100000
+
+// aggregateHandler serves the records in [from, to] (RFC 3339, default the last span), optionally filtered by
+// port, reduced to buckets of step. Truncated is set when the records exceeded aggregateQueryLimit, so the
+// buckets after the last record read are missing.
+func aggregateHandler(reader history.Reader, step, span time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseHistoryQuery(r, span)
+		if err != nil {
+			httpError(w, r, errkind.Validation, err.Error(), http.StatusBadRequest)
+			return
+		}
+		q.Limit = aggregateQueryLimit
+		records, err := reader.Query(r.Context(), q)
+		if err != nil {
+			httpError(w, r, errkind.Internal, "history query failed", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			Buckets   []history.Bucket `json:"buckets"`
+			Truncated bool             `json:"truncated,omitempty"`
+		}{history.Aggregate(records, step), len(records) >= aggregateQueryLimit})
+	}
+}
+
+// parseHistoryQuery reads from, to, port, and limit. from and to default to the last span.
+func parseHistoryQuery(r *http.Request, span time.Duration) (history.Query, error) {
+	now := time.Now()
+	q := history.Query{From: now.Add(-span), To: now}
+	values := r.URL.Query()
+	if v := values.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return q, errInvalidParam("from")
+		}
+		q.From = t
+	}
+	if v := values.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return q, errInvalidParam("to")
+		}
+		q.To = t
+	}
+	if v := values.Get("port"); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return q, errInvalidParam("port")
+		}
+		q.Port = port
+	}
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return q, errInvalidParam("limit")
+		}
+		q.Limit = limit
+	}
+	return q, nil
+}
+
+type errInvalidParam string
+
+func (e errInvalidParam) Error() string {
+	return "invalid " + string(e)
+}
@@ -0,0 +1,666 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+	"github.com/jsirianni/dzsa-sync/internal/history"
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+	"github.com/jsirianni/dzsa-sync/model"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSyncHandler(t *testing.T) {
+	syncer := &fakeSyncer{servers: []config.Server{{Name: "main", Port: 2424}}}
+	srv := NewServer(Options{MetricsHandler: http.NotFoundHandler(), Store: servers.New(nil), Syncer: syncer})
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/api/v1/sync", http.StatusAccepted},
+		{"/api/v1/sync/2424", http.StatusAccepted},
+		{"/api/v1/sync/9999", http.StatusNotFound},
+		{"/api/v1/sync/abc", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("POST %s status = %d, want %d", tt.path, rec.Code, tt.want)
+		}
+	}
+	if syncer.all != 1 || len(syncer.triggered) != 1 {
+		t.Errorf("TriggerAll calls = %d, triggered = %v", syncer.all, syncer.triggered)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/sync", nil))
+	if body := strings.TrimSpace(rec.Body.String()); body != `{"ports":[2424]}` {
+		t.Errorf("POST /api/v1/sync body = %s", body)
+	}
+}
+
+func TestStatusHandler(t *testing.T) {
+	store := servers.New([]int{2424, 2324})
+	store.Set(2424, &model.Result{Players: 12, MaxPlayers: 60})
+	now := time.Now().UTC()
+	store.RecordSync(2424, now, nil)
+	store.RecordSync(2324, now, errors.New("status 404"))
+	store.RecordSync(2324, now, errors.New("status 404"))
+	syncer := &fakeSyncer{servers: []config.Server{{Name: "modded", Port: 2324, AddressFamily: config.AddressFamilyIPv6}, {Name: "main", Port: 2424}}}
+	srv := NewServer(Options{
+		MetricsHandler: http.NotFoundHandler(),
+		Store:          store,
+		Syncer:         syncer,
+		Address:        func() string { return "203.0.113.10" },
+		Address6:       func() string { return "2001:db8::10" },
+		InstanceName:   "eu-1",
+		Started:        now.Add(-90 * time.Second),
+	})
+
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/status", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var got StatusResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ExternalIP != "203.0.113.10" || got.InstanceName != "eu-1" || len(got.Servers) != 2 {
+		t.Fatalf("status = %+v", got)
+	}
+	if got.ExternalIPv6 != "2001:db8::10" || got.Servers[0].AddressFamily != config.AddressFamilyIPv6 {
+		t.Errorf("external_ipv6 = %q, address_family = %q", got.ExternalIPv6, got.Servers[0].AddressFamily)
+	}
+	if !got.StartedAt.Equal(now.Add(-90*time.Second)) || got.UptimeSeconds < 90 {
+		t.Errorf("started_at = %v, uptime_seconds = %d", got.StartedAt, got.UptimeSeconds)
+	}
+	modded, main := got.Servers[0], got.Servers[1]
+	if modded.Sync == nil || modded.Sync.ConsecutiveFailures != 2 || modded.Sync.LastError != "status 404" || !modded.Sync.LastSuccess.IsZero() {
+		t.Errorf("modded = %+v", modded.Sync)
+	}
+	if main.Players != 12 || main.Sync == nil || main.Sync.LastError != "" || main.Sync.LastSuccess.IsZero() {
+		t.Errorf("main = %+v", main)
+	}
+}
+
+func TestListHandlerInstanceName(t *testing.T) {
+	for _, instance := range []string{"", "eu-1"} {
+		srv := NewServer(Options{MetricsHandler: http.NotFoundHandler(), Store: servers.New(nil), InstanceName: instance})
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/servers", nil))
+		var got map[string]any
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		name, ok := got["instance_name"]
+		if (instance == "" && ok) || (instance != "" && name != instance) {
+			t.Errorf("instance %q: response = %v", instance, got)
+		}
+	}
+}
+
+func TestServerHistoryHandler(t *testing.T) {
+	store := servers.New([]int{2424, 2425})
+	store.Set(2424, &model.Result{Name: "main", Players: 3, MaxPlayers: 60, Version: "1.25"})
+	store.Set(2424, &model.Result{Name: "main", Players: 12, MaxPlayers: 60, Version: "1.26"})
+	srv := NewServer(Options{MetricsHandler: http.NotFoundHandler(), Store: store})
+
+	for _, tt := range []struct {
+		path        string
+		wantStatus  int
+		wantPlayers []int
+	}{
+		{"/api/v1/servers/2424/history", http.StatusOK, []int{3, 12}},
+		{"/api/v1/servers/2425/history", http.StatusOK, []int{}},
+		{"/api/v1/servers/2302/history", http.StatusNotFound, nil},
+		{"/api/v1/servers/main/history", http.StatusBadRequest, nil},
+	} {
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("GET %s = %d, want %d", tt.path, rec.Code, tt.wantStatus)
+			continue
+		}
+		if tt.wantPlayers == nil {
+			continue
+		}
+		var got HistoryV1
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		players := []int{}
+		for _, e := range got.Entries {
+			if e.At.IsZero() {
+				t.Errorf("GET %s: entry without a time", tt.path)
+			}
+			players = append(players, e.Result.Players)
+		}
+		if !slices.Equal(players, tt.wantPlayers) {
+			t.Errorf("GET %s: players = %v, want %v", tt.path, players, tt.wantPlayers)
+		}
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	ip := ""
+	srv := NewServer(Options{MetricsHandler: http.NotFoundHandler(), Store: servers.New(nil), Address: func() string { return ip }})
+
+	for _, tt := range []struct {
+		ip   string
+		want int
+	}{
+		{"", http.StatusServiceUnavailable},
+		{"203.0.113.10", http.StatusOK},
+	} {
+		ip = tt.ip
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		if rec.Code != tt.want {
+			t.Errorf("ip %q: status = %d, want %d", tt.ip, rec.Code, tt.want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("healthz status = %d, want 200", rec.Code)
+	}
+}
+
+func TestReadyz_RequireSync(t *testing.T) {
+	store := servers.New([]int{2424})
+	syncer := &fakeSyncer{servers: []config.Server{{Name: "main", Port: 2424}}}
+	elector := &fakeElector{leader: true}
+	srv := NewServer(Options{
+		MetricsHandler:    http.NotFoundHandler(),
+		Store:             store,
+		Syncer:            syncer,
+		Address:           func() string { return "203.0.113.10" },
+		Elector:           elector,
+		ReadyRequiresSync: true,
+	})
+	readyz := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return rec
+	}
+
+	if rec := readyz(); rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "server main") {
+		t.Errorf("before the first sync: %d %s", rec.Code, rec.Body.String())
+	}
+	store.RecordSync(2424, time.Now(), errors.New("status 404"))
+	if rec := readyz(); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("after a failed sync: %d, want 503", rec.Code)
+	}
+	// A follower does not sync, so it does not wait for syncs.
+	elector.leader = false
+	if rec := readyz(); rec.Code != http.StatusOK {
+		t.Errorf("follower: %d, want 200", rec.Code)
+	}
+	elector.leader = true
+	store.RecordSync(2424, time.Now(), nil)
+	store.RecordSync(2424, time.Now(), errors.New("status 404"))
+	// Later failures do not affect readiness.
+	if rec := readyz(); rec.Code != http.StatusOK {
+		t.Errorf("after a successful sync: %d, want 200", rec.Code)
+	}
+}
+
+type fakeElector struct {
+	leader bool
+	holder string
+}
+
+func (e *fakeElector) IsLeader() bool { return e.leader }
+func (e *fakeElector) Leader() string { return e.holder }
+
+func TestFollowerRejectsSync(t *testing.T) {
+	syncer := &fakeSyncer{servers: []config.Server{{Name: "main", Port: 2424}}}
+	elector := &fakeElector{holder: "node-b"}
+	srv := NewServer(Options{MetricsHandler: http.NotFoundHandler(), Store: servers.New(nil), Syncer: syncer, Elector: elector})
+
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/sync", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "leader is node-b") {
+		t.Errorf("follower POST /api/v1/sync = %d %q, want 503 naming the leader", rec.Code, rec.Body.String())
+	}
+	if syncer.all != 0 {
+		t.Error("follower triggered a sync")
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/status", nil))
+	var got StatusResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Role != RoleFollower || got.Leader != "node-b" {
+		t.Errorf("follower status role = %q, leader = %q", got.Role, got.Leader)
+	}
+
+	elector.leader, elector.holder = true, "node-a"
+	rec = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/sync", nil))
+	if rec.Code != http.StatusAccepted || syncer.all != 1 {
+		t.Errorf("leader POST /api/v1/sync = %d, TriggerAll calls = %d", rec.Code, syncer.all)
+	}
+}
+
+func TestDrainingRejectsSync(t *testing.T) {
+	syncer := &fakeSyncer{servers: []config.Server{{Name: "main", Port: 2424}}}
+	draining := false
+	srv := NewServer(Options{MetricsHandler: http.NotFoundHandler(), Store: servers.New(nil), Syncer: syncer, Draining: func() bool { return draining }})
+
+	draining = true
+	for _, path := range []string{"/api/v1/sync", "/api/v1/sync/2424"} {
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("POST %s while draining = %d, want 503", path, rec.Code)
+		}
+	}
+	if syncer.all != 0 || len(syncer.triggered) != 0 {
+		t.Error("a sync was triggered while draining")
+	}
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/servers", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /api/v1/servers while draining = %d, want 200", rec.Code)
+	}
+
+	draining = false
+	rec = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/sync", nil))
+	if rec.Code != http.StatusAccepted || syncer.all != 1 {
+		t.Errorf("POST /api/v1/sync = %d, TriggerAll calls = %d", rec.Code, syncer.all)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	store := servers.New([]int{2424})
+	store.Set(2424, &model.Result{Name: "main", Endpoint: model.Endpoint{IP: "203.0.113.10", Port: 2424}})
+	metrics := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write([]byte("ip 203.0.113.10")) })
+	srv := NewServer(Options{
+		MetricsHandler: metrics,
+		Store:          store,
+		Redact:         func(s string) string { return strings.ReplaceAll(s, "203.0.113.10", "ip-redacted") },
+	})
+
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/servers/2424", nil))
+	var got model.Result
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || got.Endpoint.IP != "ip-redacted" {
+		t.Errorf("GET /api/v1/servers/2424 = %d, endpoint %+v", rec.Code, got.Endpoint)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/servers/2325", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown port status = %d, want 404", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/servers/2424/history", nil))
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "203.0.113.10") {
+		t.Errorf("GET /api/v1/servers/2424/history = %d, %s, want the IP redacted", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, MetricsPath, nil))
+	if rec.Body.String() != "ip 203.0.113.10" {
+		t.Errorf("metrics were rewritten: %q", rec.Body.String())
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	srv := NewServer(Options{
+		MetricsHandler: http.NotFoundHandler(),
+		Store:          servers.New(nil),
+		Logger:         zap.New(core),
+		// httptest requests come from 192.0.2.1.
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")},
+	})
+	get := func(path, id, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if id != "" {
+			req.Header.Set(RequestIDHeader, id)
+		}
+		if remote != "" {
+			req.RemoteAddr = remote
+		}
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/api/v1/servers/abc", "panel-42", "")
+	if got := rec.Header().Get(RequestIDHeader); got != "panel-42" {
+		t.Errorf("trusted proxy ID = %q, want panel-42", got)
+	}
+	if !strings.HasPrefix(rec.Body.String(), "validation: invalid port") || !strings.Contains(rec.Body.String(), "request_id panel-42") {
+		t.Errorf("error body %q does not include the error kind and request ID", rec.Body.String())
+	}
+	if rec := get("/api/v1/version", "panel-42", "198.51.100.7:4000"); rec.Header().Get(RequestIDHeader) == "panel-42" {
+		t.Error("kept the ID of an untrusted peer")
+	}
+	if rec := get("/api/v1/version", "bad id\n", ""); len(rec.Header().Get(RequestIDHeader)) != 32 {
+		t.Errorf("malformed ID not replaced: %q", rec.Header().Get(RequestIDHeader))
+	}
+
+	failed := logs.FilterField(zap.String("request_id", "panel-42")).All()
+	if len(failed) != 1 || failed[0].Level != zapcore.InfoLevel || failed[0].ContextMap()["status"] != int64(http.StatusBadRequest) {
+		t.Errorf("access log for the failed request = %+v", failed)
+	}
+	if logs.Len() != 3 {
+		t.Errorf("logged %d requests, want 3", logs.Len())
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("127.0.0.1/32")}
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{"untrusted peer", "198.51.100.7:4000", map[string]string{ForwardedForHeader: "203.0.113.5"}, "198.51.100.7"},
+		{"trusted peer", "127.0.0.1:4000", map[string]string{ForwardedForHeader: "203.0.113.5"}, "203.0.113.5"},
+		{"spoofed hop left of the client", "127.0.0.1:4000", map[string]string{ForwardedForHeader: "192.0.2.9, 203.0.113.5, 10.1.2.3"}, "203.0.113.5"},
+		{"only trusted hops", "127.0.0.1:4000", map[string]string{ForwardedForHeader: "10.1.2.3"}, "10.1.2.3"},
+		{"malformed hop", "127.0.0.1:4000", map[string]string{ForwardedForHeader: "203.0.113.5, bogus"}, "127.0.0.1"},
+		{"real ip", "127.0.0.1:4000", map[string]string{RealIPHeader: "203.0.113.6"}, "203.0.113.6"},
+		{"forwarded for wins over real ip", "127.0.0.1:4000", map[string]string{ForwardedForHeader: "203.0.113.5", RealIPHeader: "203.0.113.6"}, "203.0.113.5"},
+		{"mapped ipv4", "127.0.0.1:4000", map[string]string{ForwardedForHeader: "::ffff:203.0.113.5"}, "203.0.113.5"},
+		{"no header", "127.0.0.1:4000", nil, "127.0.0.1"},
+		{"unix socket", "@", map[string]string{ForwardedForHeader: "203.0.113.5"}, "invalid IP"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if got := clientIP(trusted, req).String(); got != tt.want {
+				t.Errorf("clientIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	var got netip.Addr
+	srv := NewServer(Options{
+		MetricsHandler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) { got = ClientIP(r.Context()) }),
+		Store:          servers.New(nil),
+		TrustedProxies: trusted,
+	})
+	req := httptest.NewRequest(http.MethodGet, MetricsPath, nil)
+	req.RemoteAddr = "10.0.0.2:4000"
+	req.Header.Set(RealIPHeader, "203.0.113.6")
+	srv.Handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got.String() != "203.0.113.6" {
+		t.Errorf("ClientIP() in a handler = %s, want 203.0.113.6", got)
+	}
+}
+
+func TestListHandlerVersions(t *testing.T) {
+	store := servers.New([]int{2424, 2425})
+	store.Set(2424, &model.Result{Name: "main"})
+	srv := NewServer(Options{MetricsHandler: http.NotFoundHandler(), Store: store})
+	get := func(path, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/api/v1/servers", "")
+	var full servers.Delta
+	if err := json.NewDecoder(rec.Body).Decode(&full); err != nil {
+		t.Fatal(err)
+	}
+	etag := rec.Header().Get("ETag")
+	if len(full.Servers) != 1 || full.Version == 0 || etag == "" {
+		t.Fatalf("full list = %+v, ETag %q", full, etag)
+	}
+	if rec := get("/api/v1/servers", etag); rec.Code != http.StatusNotModified {
+		t.Errorf("unchanged list with If-None-Match = %d, want 304", rec.Code)
+	}
+
+	store.Set(2425, &model.Result{Name: "modded"})
+	if rec := get("/api/v1/servers", etag); rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Errorf("changed list with If-None-Match = %d, ETag %q", rec.Code, rec.Header().Get("ETag"))
+	}
+	rec = get("/api/v1/servers?since="+strconv.FormatUint(full.Version, 10), "")
+	var delta servers.Delta
+	if err := json.NewDecoder(rec.Body).Decode(&delta); err != nil {
+		t.Fatal(err)
+	}
+	if len(delta.Servers) != 1 || delta.Servers[0].Port != 2425 || delta.Full || delta.Version <= full.Version {
+		t.Errorf("delta = %+v", delta)
+	}
+	if rec := get("/api/v1/servers?since=abc", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid since = %d, want 400", rec.Code)
+	}
+}
+
+func BenchmarkListHandler(b *testing.B) {
+	store := servers.New(nil)
+	for i := range 500 {
+		store.AddPort(2300 + i)
+		store.Set(2300+i, &model.Result{Name: "server", Players: i % 60, MaxPlayers: 60, Mods: make([]model.Mods, 20)})
+	}
+	srv := NewServer(Options{MetricsHandler: http.NotFoundHandler(), Store: store})
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/servers", nil)
+	b.ReportAllocs()
+	for b.Loop() {
+		srv.Handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
+func TestV1Result(t *testing.T) {
+	store := servers.New([]int{2424})
+	store.Set(2424, &model.Result{Name: "main", Endpoint: model.Endpoint{IP: "203.0.113.10", Port: 2424}, Players: 12, MaxPlayers: 60, Mods: []model.Mods{}})
+	srv := NewServer(Options{MetricsHandler: http.NotFoundHandler(), Store: store})
+
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/servers/2424", nil))
+	// The field order and names are the API contract.
+	want := `{"battlEye":false,"endpoint":{"ip":"203.0.113.10","port":2424},"environment":"","firstPersonOnly":false,` +
+		`"folder":"","game":"","gamePort":0,"map":"","maxPlayers":60,"mission":"","name":"main","nameOverride":false,` +
+		`"password":false,"players":12,"profile":false,"shard":"","sponsor":false,"time":"","timeAcceleration":0,` +
+		`"vac":false,"version":"","fillPercent":20}` + "\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("GET /api/v1/servers/2424 =\n%s\nwant\n%s", got, want)
+	}
+
+	// The list carries the config name next to the port.
+	store.SetName(2424, "eu-main")
+	rec = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/servers", nil))
+	if !strings.Contains(rec.Body.String(), `{"port":2424,"name":"eu-main","result":{`) {
+		t.Errorf("GET /api/v1/servers = %s, want the config name", rec.Body.String())
+	}
+
+	for _, tt := range []struct {
+		players, maxPlayers int
+		want                float64
+	}{{1, 3, 33.3}, {0, 0, 0}} {
+		if got := v1Result(&model.Result{Players: tt.players, MaxPlayers: tt.maxPlayers}).FillPercent; got != tt.want {
+			t.Errorf("FillPercent for %d/%d = %v, want %v", tt.players, tt.maxPlayers, got, tt.want)
+		}
+	}
+}
+
+func TestUI(t *testing.T) {
+	get := func(srv *http.Server, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	store := servers.New(nil)
+
+	srv := NewServer(Options{MetricsHandler: http.NotFoundHandler(), Store: store, UI: true})
+	if rec := get(srv, "/"); rec.Code != http.StatusFound || rec.Header().Get("Location") != UIPath {
+		t.Errorf("GET / = %d, Location %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if rec := get(srv, UIPath); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `<script src="app.js">`) {
+		t.Errorf("GET %s = %d", UIPath, rec.Code)
+	}
+	for _, path := range []string{"app.js", "style.css"} {
+		if rec := get(srv, UIPath+path); rec.Code != http.StatusOK {
+			t.Errorf("GET %s = %d", UIPath+path, rec.Code)
+		}
+	}
+	if rec := get(srv, "/api/v1/servers"); rec.Code != http.StatusOK {
+		t.Errorf("GET /api/v1/servers with the UI = %d", rec.Code)
+	}
+
+	srv = NewServer(Options{MetricsHandler: http.NotFoundHandler(), Store: store})
+	if rec := get(srv, UIPath); rec.Code != http.StatusNotFound {
+		t.Errorf("GET %s without the UI = %d, want 404", UIPath, rec.Code)
+	}
+}
+
+type fakeHistory struct {
+	records []history.Record
+	query   history.Query
+}
+
+func (f *fakeHistory) Query(_ context.Context, q history.Query) ([]history.Record, error) {
+	f.query = q
+	return f.records, nil
+}
+
+func TestAggregateHandler(t *testing.T) {
+	hour := time.Date(2024, 1, 10, 20, 0, 0, 0, time.UTC)
+	reader := &fakeHistory{records: []history.Record{
+		{Time: hour.Add(5 * time.Minute), Server: "main", Port: 2424, Online: true, Players: 40},
+		{Time: hour.Add(10 * time.Minute), Server: "main", Port: 2424, Online: true, Players: 20},
+		{Time: hour.Add(70 * time.Minute), Server: "main", Port: 2424, Error: "timeout"},
+	}}
+	srv := NewServer(Options{MetricsHandler: http.NotFoundHandler(), Store: servers.New(nil), History: reader})
+
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/history/hourly?port=2424", nil))
+	var body struct {
+		Buckets []history.Bucket `json:"buckets"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("GET hourly = %d %s", rec.Code, rec.Body.String())
+	}
+	if len(body.Buckets) != 2 || body.Buckets[0].AvgPlayers != 30 || body.Buckets[0].PeakPlayers != 40 || body.Buckets[1].UptimePercent != 0 {
+		t.Errorf("hourly buckets = %+v", body.Buckets)
+	}
+	if reader.query.Port != 2424 || reader.query.Limit != aggregateQueryLimit || reader.query.To.Sub(reader.query.From) != 24*time.Hour {
+		t.Errorf("hourly query = %+v", reader.query)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/history/daily", nil))
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || len(body.Buckets) != 1 || body.Buckets[0].Syncs != 3 {
+		t.Errorf("GET daily = %d %s", rec.Code, rec.Body.String())
+	}
+	if reader.query.To.Sub(reader.query.From) != 30*24*time.Hour {
+		t.Errorf("daily query = %+v", reader.query)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/history/daily?from=yesterday", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("GET daily with invalid from = %d, want 400", rec.Code)
+	}
+}
+
+type fakeEditor struct {
+	servers map[int]config.Server
+}
+
+func (f *fakeEditor) AddServer(srv config.Server) (config.Server, error) {
+	if _, ok := f.servers[srv.Port]; ok {
+		return config.Server{}, fmt.Errorf("%w: port %d", ErrServerConflict, srv.Port)
+	}
+	if srv.Name == "" {
+		return config.Server{}, errkind.Errorf(errkind.Config, "name is required")
+	}
+	f.servers[srv.Port] = srv
+	return srv, nil
+}
+
+func (f *fakeEditor) RemoveServer(port int) (config.Server, error) {
+	srv, ok := f.servers[port]
+	if !ok {
+		return config.Server{}, ErrServerNotFound
+	}
+	delete(f.servers, port)
+	return srv, nil
+}
+
+func (f *fakeEditor) Persistent() bool { return false }
+
+func TestServerEditHandlers(t *testing.T) {
+	editor := &fakeEditor{servers: map[int]config.Server{2424: {Name: "main", Port: 2424}}}
+	srv := NewServer(Options{MetricsHandler: http.NotFoundHandler(), Store: servers.New(nil), Servers: editor, AdminToken: "secret"})
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/api/v1/servers", `{"name":"modded","port":2324,"sync_interval":"15m"}`)
+	if rec.Code != http.StatusCreated || strings.TrimSpace(rec.Body.String()) != `{"name":"modded","port":2324,"persisted":false}` {
+		t.Fatalf("POST = %d %s", rec.Code, rec.Body)
+	}
+	if got := editor.servers[2324]; got.SyncInterval != 15*time.Minute {
+		t.Errorf("added server = %+v", got)
+	}
+	for _, tc := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPost, "/api/v1/servers", `{"name":"dup","port":2424}`, http.StatusConflict},
+		{http.MethodPost, "/api/v1/servers", `{"port":2524}`, http.StatusUnprocessableEntity},
+		{http.MethodPost, "/api/v1/servers", `{"name":"x","port":2524,"unknown":true}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/servers", `{"name":"x","port":2524,"sync_interval":"soon"}`, http.StatusBadRequest},
+		{http.MethodDelete, "/api/v1/servers/2424", "", http.StatusOK},
+		{http.MethodDelete, "/api/v1/servers/2424", "", http.StatusNotFound},
+		{http.MethodDelete, "/api/v1/servers/main", "", http.StatusBadRequest},
+	} {
+		if rec := do(tc.method, tc.path, tc.body); rec.Code != tc.want {
+			t.Errorf("%s %s %s = %d, want %d", tc.method, tc.path, tc.body, rec.Code, tc.want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/servers/2324", nil)
+	rec = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("DELETE without a token = %d, want 401", rec.Code)
+	}
+}
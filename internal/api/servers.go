@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+)
+
+// maxServerBody caps the size of a POST /api/v1/servers body.
+const maxServerBody = 64 << 10
+
+// Errors a ServerEditor wraps to choose the response status.
+var (
+	// ErrServerConflict is returned for a port that is already managed, or managed by a source that cannot
+	// be edited, such as discovery.
+	ErrServerConflict = errors.New("server conflict")
+	// ErrServerNotFound is returned for a port that is not managed.
+	ErrServerNotFound = errors.New("server not found")
+)
+
+// ServerEditor adds and removes servers of a running instance.
+type ServerEditor interface {
+	// AddServer starts syncing srv and returns it as it is synced, with the port derived for query_port: auto.
+	AddServer(srv config.Server) (config.Server, error)
+	// RemoveServer stops syncing the server with the query port and drops its data.
+	RemoveServer(port int) (config.Server, error)
+	// Persistent reports whether edits are written to the config file.
+	Persistent() bool
+}
+
+type serverEditResponse struct {
+	InstanceName string `json:"instance_name,omitempty"`
+	Name         string `json:"name"`
+	Port         int    `json:"port"`
+	// Persisted is true when the change was written to the config file, and false when it lasts until the
+	// next restart.
+	Persisted bool `json:"persisted"`
+}
+
+// addServerHandler serves POST /api/v1/servers with an AddServerV1 body.
+func addServerHandler(editor ServerEditor, instanceName string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req AddServerV1
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxServerBody))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			httpError(w, r, errkind.Validation, "invalid body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		srv, err := req.server()
+		if err != nil {
+			httpError(w, r, errkind.Validation, err.Error(), http.StatusBadRequest)
+			return
+		}
+		added, err := editor.AddServer(srv)
+		if err != nil {
+			serverEditError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(serverEditResponse{InstanceName: instanceName, Name: added.Name, Port: added.Port, Persisted: editor.Persistent()})
+	}
+}
+
+// removeServerHandler serves DELETE /api/v1/servers/{port}.
+func removeServerHandler(editor ServerEditor, instanceName string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		port, err := strconv.Atoi(r.PathValue("port"))
+		if err != nil {
+			httpError(w, r, errkind.Validation, "invalid port", http.StatusBadRequest)
+			return
+		}
+		removed, err := editor.RemoveServer(port)
+		if err != nil {
+			serverEditError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(serverEditResponse{InstanceName: instanceName, Name: removed.Name, Port: removed.Port, Persisted: editor.Persistent()})
+	}
+}
+
+// serverEditError writes err of a ServerEditor with the status of its sentinel or kind.
+func serverEditError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrServerConflict):
+		status = http.StatusConflict
+	case errors.Is(err, ErrServerNotFound):
+		status = http.StatusNotFound
+	case errkind.Of(err) == errkind.Config || errkind.Of(err) == errkind.Validation:
+		status = http.StatusUnprocessableEntity
+	}
+	httpError(w, r, errkind.Of(err), err.Error(), status)
+}
+
+// server converts the request to a config entry.
+func (req AddServerV1) server() (config.Server, error) {
+	srv := config.Server{
+		Name:          req.Name,
+		Port:          req.Port,
+		GamePort:      req.GamePort,
+		QueryPort:     req.QueryPort,
+		MonitorOnly:   req.MonitorOnly,
+		IP:            req.IP,
+		AdvertiseIP:   req.AdvertiseIP,
+		AdvertisePort: req.AdvertisePort,
+		AddressFamily: req.AddressFamily,
+	}
+	if req.SyncInterval != "" {
+		d, err := time.ParseDuration(req.SyncInterval)
+		if err != nil {
+			return config.Server{}, fmt.Errorf("invalid sync_interval: %w", err)
+		}
+		srv.SyncInterval = d
+	}
+	return srv, nil
+}
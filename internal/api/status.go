@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/buildinfo"
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+)
+
+// StatusResponse is the body of GET /api/v1/status.
+type StatusResponse struct {
+	// InstanceName is the configured instance_name, if any.
+	InstanceName string `json:"instance_name,omitempty"`
+	// Version is the daemon's build version.
+	Version string `json:"version"`
+	// ExternalIP is the IP servers are registered with; empty until detected.
+	ExternalIP string `json:"external_ip"`
+	// ExternalIPv6 is the IPv6 address of servers with address_family: ipv6, when configured.
+	ExternalIPv6 string `json:"external_ipv6,omitempty"`
+	// StartedAt is when the daemon started, and UptimeSeconds the whole seconds since.
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+	// SyncTarget is set when syncs do not go to the live DZSA listing: the staging URL, or "dry_run".
+	SyncTarget string `json:"sync_target,omitempty"`
+	// ExternalIPFlapping is true while the external IP flaps and changes do not trigger resyncs.
+	ExternalIPFlapping bool `json:"external_ip_flapping,omitempty"`
+	// Role is "leader" or "follower" in HA mode, and empty otherwise.
+	Role string `json:"role,omitempty"`
+	// Leader is the ID of the current HA leader, when known.
+	Leader string `json:"leader,omitempty"`
+	// ReadOnly is true on a listener that does not accept sync requests, e.g. the public side of api.admin.
+	ReadOnly bool           `json:"read_only,omitempty"`
+	Servers  []ServerStatus `json:"servers"`
+}
+
+// ServerStatus summarizes one managed server, including servers that have never synced successfully.
+type ServerStatus struct {
+	Name string `json:"name"`
+	Port int    `json:"port"`
+	// Host is the hosts entry the server belongs to; empty for servers on the daemon's machine.
+	Host string `json:"host,omitempty"`
+	// MonitorOnly is true for a server this instance only watches, queried at its own IP.
+	MonitorOnly bool `json:"monitor_only,omitempty"`
+	Players     int  `json:"players"`
+	MaxPlayers  int  `json:"max_players"`
+	// Map is empty until the first successful sync.
+	Map string `json:"map,omitempty"`
+	// Sync is nil until the first sync attempt.
+	Sync        *servers.SyncState   `json:"sync,omitempty"`
+	Maintenance *servers.Maintenance `json:"maintenance,omitempty"`
+	// NextSyncAt is when the server is synced next, after any retry backoff or maintenance window. It is
+	// zero until the worker is scheduled.
+	NextSyncAt time.Time `json:"next_sync_at,omitzero"`
+	// AdvertiseIP and AdvertisePort are registered with DZSA instead of the external IP and Port when set.
+	AdvertiseIP   string `json:"advertise_ip,omitempty"`
+	AdvertisePort int    `json:"advertise_port,omitempty"`
+	// AddressFamily is "ipv6" for a server registered with the instance's IPv6 address.
+	AddressFamily string `json:"address_family,omitempty"`
+}
+
+// statusHandler serves a summary of every managed server with its latest sync outcome.
+func statusHandler(store *servers.Store, syncer Syncer, address, address6 func() string, flapping func() bool, instanceName, syncTarget string, elector Elector, started time.Time, readOnly bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		now := time.Now()
+		resp := StatusResponse{
+			InstanceName:  instanceName,
+			Version:       buildinfo.Get().Version,
+			StartedAt:     started.UTC(),
+			UptimeSeconds: int64(now.Sub(started) / time.Second),
+			SyncTarget:    syncTarget,
+			ReadOnly:      readOnly,
+			Servers:       []ServerStatus{},
+		}
+		if address != nil {
+			resp.ExternalIP = address()
+		}
+		if address6 != nil {
+			resp.ExternalIPv6 = address6()
+		}
+		if flapping != nil {
+			resp.ExternalIPFlapping = flapping()
+		}
+		if elector != nil {
+			resp.Role, resp.Leader = RoleFollower, elector.Leader()
+			if elector.IsLeader() {
+				resp.Role = RoleLeader
+			}
+		}
+		for _, srv := range syncer.Servers() {
+			st := ServerStatus{Name: srv.Name, Port: srv.Port, Host: srv.Host, MonitorOnly: srv.MonitorOnly, AdvertiseIP: srv.AdvertiseIP, AdvertisePort: srv.AdvertisePort, AddressFamily: srv.AddressFamily}
+			if r, ok := store.Get(srv.Port); ok {
+				st.Players = r.Players
+				st.MaxPlayers = r.MaxPlayers
+				st.Map = r.Map
+			}
+			if s, ok := store.GetSyncState(srv.Port); ok {
+				st.Sync = &s
+			}
+			if m, ok := store.GetMaintenance(srv.Port, now); ok {
+				st.Maintenance = &m
+			}
+			if at, ok := store.NextSync(srv.Port, now); ok {
+				st.NextSyncAt = at
+			}
+			resp.Servers = append(resp.Servers, st)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
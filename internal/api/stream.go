@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+)
+
+// StreamPath serves the server list as Server-Sent Events.
+const StreamPath = "/api/v1/servers/stream"
+
+// streamKeepAlive is the time between comments sent on an idle stream, so proxies do not close it.
+const streamKeepAlive = 30 * time.Second
+
+// streamHandler serves StreamPath: a "servers" event with the full list, then one with the changes after each
+// store update, such as a sync result or the resyncs after an IP change. Each event has the DeltaV1 body of
+// GET /api/v1/servers and the store version as its ID, so a client that reconnects with Last-Event-ID only
+// gets what changed meanwhile, like ?since=<version>. Events are passed through redact when it is set, since
+// the stream bypasses the buffered response redaction. Streams end when done is cancelled.
+func streamHandler(done context.Context, store *servers.Store, instanceName string, redact func(string) string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		// The stream outlives the server's write timeout.
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			httpError(w, r, errkind.Internal, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+		var (
+			delta servers.Delta
+			since uint64
+		)
+		if v := r.Header.Get("Last-Event-ID"); v != "" {
+			var err error
+			if since, err = strconv.ParseUint(v, 10, 64); err != nil {
+				httpError(w, r, errkind.Validation, "invalid Last-Event-ID", http.StatusBadRequest)
+				return
+			}
+			delta = store.Changes(since)
+		} else {
+			all, version := store.GetAllWithVersion()
+			delta = servers.Delta{Version: version, Servers: all}
+		}
+		// Subscribe before the first event, so no update after it is missed.
+		changes, unsubscribe := store.Subscribe()
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		// Keep nginx from buffering the stream.
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		keepAlive := time.NewTicker(streamKeepAlive)
+		defer keepAlive.Stop()
+		for first := true; ; first = false {
+			// Updates that do not change what the list shows, e.g. a new next sync time, are not sent.
+			if first || delta.Full || len(delta.Servers) > 0 || len(delta.Removed) > 0 {
+				if err := writeServersEvent(w, v1Delta(instanceName, delta), redact); err != nil {
+					return
+				}
+			}
+			since = delta.Version
+			if err := rc.Flush(); err != nil {
+				return
+			}
+			select {
+			case <-r.Context().Done():
+				return
+			case <-done.Done():
+				return
+			case <-keepAlive.C:
+				if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				delta = servers.Delta{Version: since}
+			case <-changes:
+				delta = store.Changes(since)
+			}
+		}
+	}
+}
+
+// writeServersEvent writes d as a "servers" event with its version as the ID.
+func writeServersEvent(w io.Writer, d DeltaV1, redact func(string) string) error {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	data := string(b)
+	if redact != nil {
+		data = redact(data)
+	}
+	_, err = fmt.Fprintf(w, "event: servers\nid: %d\ndata: %s\n\n", d.Version, data)
+	return err
+}
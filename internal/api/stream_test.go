@@ -0,0 +1,106 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+	"github.com/jsirianni/dzsa-sync/model"
+)
+
+// sseEvent is an event read from a server stream.
+type sseEvent struct {
+	name, id string
+	delta    DeltaV1
+}
+
+func readEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+	var ev sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && ev.name != "":
+			return ev
+		case strings.HasPrefix(line, "event: "):
+			ev.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.delta); err != nil {
+				t.Fatalf("decode event data: %v", err)
+			}
+		}
+	}
+}
+
+func TestStreamHandler(t *testing.T) {
+	store := servers.New([]int{2424, 2425})
+	store.Set(2424, &model.Result{Name: "main", Endpoint: model.Endpoint{IP: "203.0.113.10", Port: 2424}})
+	srv := NewServer(Options{
+		MetricsHandler: http.NotFoundHandler(),
+		Store:          store,
+		InstanceName:   "eu-1",
+		Redact:         func(s string) string { return strings.ReplaceAll(s, "203.0.113.10", "[redacted]") },
+	})
+	ts := httptest.NewServer(srv.Handler)
+	defer ts.Close()
+
+	open := func(lastEventID string) (*http.Response, *bufio.Reader) {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+StreamPath, nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+			t.Fatalf("status = %d, content type = %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		return resp, bufio.NewReader(resp.Body)
+	}
+
+	resp, r := open("")
+	first := readEvent(t, r)
+	if first.name != "servers" || first.id != strconv.FormatUint(first.delta.Version, 10) || first.delta.InstanceName != "eu-1" {
+		t.Fatalf("first event = %+v", first)
+	}
+	if len(first.delta.Servers) != 1 || first.delta.Servers[0].Result.Endpoint.IP != "[redacted]" {
+		t.Fatalf("first event servers = %+v, want main with its IP redacted", first.delta.Servers)
+	}
+
+	store.Set(2425, &model.Result{Name: "modded"})
+	update := readEvent(t, r)
+	if len(update.delta.Servers) != 1 || update.delta.Servers[0].Port != 2425 || update.delta.Version <= first.delta.Version {
+		t.Errorf("update event = %+v, want only port 2425", update.delta)
+	}
+	resp.Body.Close()
+
+	// A reconnect only gets what changed after the last event it saw.
+	resp, r = open(first.id)
+	defer resp.Body.Close()
+	resumed := readEvent(t, r)
+	if len(resumed.delta.Servers) != 1 || resumed.delta.Servers[0].Port != 2425 {
+		t.Errorf("resumed event = %+v, want only port 2425", resumed.delta)
+	}
+
+	// Shutdown ends open streams instead of waiting for them.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_ = srv.Shutdown(ctx)
+	if _, err := r.ReadString('\n'); err == nil {
+		t.Error("stream still open after Shutdown")
+	}
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// UIPath is where the web UI is served when Options.UI is set.
+const UIPath = "/ui/"
+
+//go:embed ui
+var uiFiles embed.FS
+
+// uiHandler serves the embedded web UI. The UI is static and reads everything from the JSON API.
+func uiHandler() http.Handler {
+	files, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		panic(err) // the directory is embedded at build time
+	}
+	return http.StripPrefix(UIPath, http.FileServerFS(files))
+}
@@ -0,0 +1,166 @@
+// dzsa-sync web UI. Everything comes from the JSON API; paths are relative so the UI works behind a
+// reverse proxy that serves the API under a prefix.
+"use strict";
+
+const api = "../api/v1/";
+const refreshMs = 15000;
+const historyMs = 5 * 60 * 1000;
+
+let playerHistory = {};
+let historyLoaded = 0;
+
+async function get(path) {
+  // no-cache revalidates with the ETag, so an unchanged server list costs a 304.
+  const res = await fetch(api + path, { cache: "no-cache" });
+  if (res.status === 404) return null;
+  if (!res.ok) throw new Error(await res.text());
+  return res.json();
+}
+
+function showMessage(text) {
+  const el = document.getElementById("message");
+  el.textContent = text;
+  el.hidden = !text;
+}
+
+function ago(iso) {
+  if (!iso) return "never";
+  const s = Math.round((Date.now() - new Date(iso)) / 1000);
+  if (s < 60) return s + "s ago";
+  if (s < 3600) return Math.round(s / 60) + "m ago";
+  return Math.round(s / 3600) + "h ago";
+}
+
+// sync syncs one server, or every server without one. On a controller, a server is synced through its agent.
+async function sync(s) {
+  let path = "sync";
+  if (s) path = (s.agent ? "agents/" + encodeURIComponent(s.agent) + "/" : "") + "sync/" + s.port;
+  const res = await fetch(api + path, { method: "POST" });
+  if (!res.ok) {
+    showMessage("Sync failed: " + (await res.text()));
+    return;
+  }
+  showMessage("");
+  setTimeout(refresh, 2000);
+}
+
+// loadHistory loads the hourly player averages of the last day for every server in one request.
+async function loadHistory() {
+  if (Date.now() - historyLoaded < historyMs) return;
+  historyLoaded = Date.now();
+  const body = await get("history/hourly");
+  if (body === null) {
+    playerHistory = null; // history is not enabled
+    return;
+  }
+  const next = {};
+  for (const b of body.buckets) (next[b.port] ||= []).push(b);
+  playerHistory = next;
+}
+
+function drawGraph(svg, buckets, maxPlayers) {
+  const points = buckets.filter((b) => b.syncs > b.failed);
+  if (points.length < 2) {
+    svg.hidden = true;
+    return;
+  }
+  const start = new Date(points[0].time).getTime();
+  const span = new Date(points[points.length - 1].time).getTime() - start || 1;
+  const top = Math.max(maxPlayers, ...points.map((b) => b.avg_players), 1);
+  svg.querySelector("polyline").setAttribute("points", points
+    .map((b) => ((new Date(b.time).getTime() - start) / span) * 300 + "," + (60 - (b.avg_players / top) * 58))
+    .join(" "));
+  svg.hidden = false;
+}
+
+// key identifies a server: its port, and on a controller also its agent.
+function key(s) {
+  return (s.agent || "") + ":" + s.port;
+}
+
+function render(status, list) {
+  const byKey = new Map();
+  for (const s of status ? status.servers : []) byKey.set(key(s), { agent: s.agent, port: s.port, name: s.name, sync: s.sync, maintenance: s.maintenance });
+  for (const e of list ? list.servers : []) byKey.set(key(e), { ...byKey.get(key(e)), ...e });
+  // agents is set when the API is a controller's.
+  const agents = (status && status.agents) || [];
+  const down = new Set(agents.filter((a) => !a.up).map((a) => a.name));
+
+  const canSync = status !== null && status.role !== "follower" && !status.read_only;
+  const title = (status && status.instance_name) || (list && list.instance_name) || "dzsa-sync";
+  document.getElementById("title").textContent = title;
+  document.title = title;
+  const summary = [];
+  if (status) {
+    summary.push("version " + status.version);
+    if (status.agents) {
+      summary.push(agents.length - down.size + "/" + agents.length + " agents up");
+      if (down.size) summary.push("down: " + [...down].join(", "));
+    } else {
+      summary.push("IP " + (status.external_ip || "not detected"));
+    }
+    if (status.role) summary.push(status.role);
+  }
+  document.getElementById("summary").textContent = summary.join(" · ");
+  const syncAll = document.getElementById("sync-all");
+  syncAll.hidden = !canSync;
+  syncAll.onclick = () => sync();
+
+  const main = document.getElementById("servers");
+  const template = document.getElementById("card");
+  main.replaceChildren();
+  const servers = [...byKey.values()].sort((a, b) => (a.agent || "").localeCompare(b.agent || "") || a.port - b.port);
+  for (const s of servers) {
+    const card = template.content.firstElementChild.cloneNode(true);
+    const r = s.result;
+    card.querySelector(".name").textContent = (r && r.name) || s.name || "port " + s.port;
+    const meta = ["port " + s.port];
+    if (r && s.name && s.name !== r.name) meta.unshift(s.name);
+    if (s.agent) meta.unshift(s.agent);
+    if (r) meta.push(r.map, r.version);
+    if (s.daylight) meta.push(s.daylight.time + (s.daylight.night ? " night" : " day"));
+    if (s.latency && !s.latency.error) meta.push(s.latency.rtt_ms.toFixed(0) + " ms");
+    card.querySelector(".meta").textContent = meta.filter(Boolean).join(" · ");
+    if (r) {
+      card.querySelector(".count").textContent = r.players + " / " + r.maxPlayers;
+      card.querySelector(".bar span").style.width = (r.fillPercent || 0) + "%";
+    } else {
+      card.querySelector(".players").hidden = true;
+    }
+    if (playerHistory && playerHistory[s.port]) drawGraph(card.querySelector(".graph"), playerHistory[s.port], r ? r.maxPlayers : 0);
+
+    const line = card.querySelector(".sync");
+    if (s.maintenance) {
+      line.textContent = "maintenance until " + new Date(s.maintenance.until).toLocaleTimeString();
+    } else if (s.sync) {
+      line.textContent = "synced " + ago(s.sync.last_success);
+      if (s.sync.last_error) {
+        line.textContent += " · failed " + s.sync.consecutive_failures + "x: " + s.sync.last_error;
+        line.classList.add("failed");
+        card.classList.toggle("offline", !s.sync.last_success);
+      }
+    }
+    if (down.has(s.agent)) {
+      line.textContent = "agent unreachable";
+      line.classList.add("failed");
+    }
+    const button = card.querySelector(".sync-button");
+    button.hidden = !canSync;
+    button.onclick = () => sync(s);
+    main.append(card);
+  }
+}
+
+async function refresh() {
+  try {
+    const [status, list] = await Promise.all([get("status"), get("servers")]);
+    if (playerHistory !== null) await loadHistory();
+    render(status, list);
+    if (document.getElementById("message").textContent.startsWith("Cannot reach")) showMessage("");
+  } catch (err) {
+    showMessage("Cannot reach the API: " + err.message);
+  }
+}
+
+refresh();
+setInterval(refresh, refreshMs);
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"math"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+	"github.com/jsirianni/dzsa-sync/model"
+)
+
+// The types below are the JSON contract of /api/v1/servers. They are converted from the store and the
+// DZSA model rather than encoding those directly, so a change to DZSA's schema or to the store does not
+// change the API: new fields are added here on purpose, and a breaking change gets a new version.
+// Field order is the declaration order and is stable.
+
+// ServerV1 is a server in the v1 API.
+type ServerV1 struct {
+	Port int `json:"port"`
+	// Name is the server's name from the config; Result.Name is the name DZSA reports.
+	Name        string                 `json:"name,omitempty"`
+	Result      *ResultV1              `json:"result"`
+	Fingerprint string                 `json:"fingerprint,omitempty"`
+	Daylight    *model.Daylight        `json:"daylight,omitempty"`
+	ModCheck    *servers.ModCheck      `json:"mod_check,omitempty"`
+	Upstream    *servers.Upstream      `json:"upstream,omitempty"`
+	Workshop    *servers.WorkshopCheck `json:"workshop,omitempty"`
+	Latency     *servers.Latency       `json:"latency,omitempty"`
+	Maintenance *servers.Maintenance   `json:"maintenance,omitempty"`
+}
+
+// AddServerV1 is the body of POST /api/v1/servers. Fields match a servers entry of the config file.
+type AddServerV1 struct {
+	Name          string `json:"name"`
+	Port          int    `json:"port,omitempty"`
+	GamePort      int    `json:"game_port,omitempty"`
+	QueryPort     string `json:"query_port,omitempty"`
+	MonitorOnly   bool   `json:"monitor_only,omitempty"`
+	IP            string `json:"ip,omitempty"`
+	AdvertiseIP   string `json:"advertise_ip,omitempty"`
+	AdvertisePort int    `json:"advertise_port,omitempty"`
+	// SyncInterval is a duration such as "15m".
+	SyncInterval  string `json:"sync_interval,omitempty"`
+	AddressFamily string `json:"address_family,omitempty"`
+}
+
+// ResultV1 is a DZSA result in the v1 API. Field names match DZSA's, so the v1 JSON decodes into a
+// model.Result.
+type ResultV1 struct {
+	BattlEye         bool           `json:"battlEye"`
+	Endpoint         model.Endpoint `json:"endpoint"`
+	Environment      string         `json:"environment"`
+	FirstPersonOnly  bool           `json:"firstPersonOnly"`
+	Folder           string         `json:"folder"`
+	Game             string         `json:"game"`
+	GamePort         int            `json:"gamePort"`
+	Map              string         `json:"map"`
+	MaxPlayers       int            `json:"maxPlayers"`
+	Mission          string         `json:"mission"`
+	Mods             []model.Mods   `json:"mods,omitempty"`
+	Name             string         `json:"name"`
+	NameOverride     bool           `json:"nameOverride"`
+	Password         bool           `json:"password"`
+	Players          int            `json:"players"`
+	Profile          bool           `json:"profile"`
+	Shard            string         `json:"shard"`
+	Sponsor          bool           `json:"sponsor"`
+	Time             string         `json:"time"`
+	TimeAcceleration int            `json:"timeAcceleration"`
+	Vac              bool           `json:"vac"`
+	Version          string         `json:"version"`
+	// FillPercent is Players as a percentage of MaxPlayers, rounded to one decimal; 0 without slots.
+	FillPercent float64 `json:"fillPercent"`
+}
+
+// DeltaV1 is the body of GET /api/v1/servers: the full list, or with ?since=<version> only the servers
+// that changed after that version.
+type DeltaV1 struct {
+	InstanceName string `json:"instance_name,omitempty"`
+	// Version is the store version the list brings the reader to.
+	Version uint64 `json:"version"`
+	// Full is set when the requested version predates this store, e.g. it came from before a restart.
+	Full    bool       `json:"full,omitempty"`
+	Servers []ServerV1 `json:"servers"`
+	Removed []int      `json:"removed,omitempty"`
+}
+
+// HistoryV1 is the body of GET /api/v1/servers/{port}/history: the recent results stored for the port,
+// oldest first.
+type HistoryV1 struct {
+	Port    int              `json:"port"`
+	Entries []HistoryEntryV1 `json:"entries"`
+}
+
+// HistoryEntryV1 is a result in the v1 history and when it was stored.
+type HistoryEntryV1 struct {
+	At     time.Time `json:"at"`
+	Result *ResultV1 `json:"result"`
+}
+
+func v1History(port int, h []servers.HistoryEntry) HistoryV1 {
+	out := HistoryV1{Port: port, Entries: make([]HistoryEntryV1, len(h))}
+	for i, e := range h {
+		out.Entries[i] = HistoryEntryV1{At: e.At, Result: v1Result(e.Result)}
+	}
+	return out
+}
+
+func v1Delta(instanceName string, d servers.Delta) DeltaV1 {
+	out := DeltaV1{InstanceName: instanceName, Version: d.Version, Full: d.Full, Removed: d.Removed, Servers: make([]ServerV1, len(d.Servers))}
+	for i, e := range d.Servers {
+		out.Servers[i] = v1Server(e)
+	}
+	return out
+}
+
+func v1Server(e servers.ServerEntry) ServerV1 {
+	return ServerV1{
+		Port:        e.Port,
+		Name:        e.Name,
+		Result:      v1Result(e.Result),
+		Fingerprint: e.Fingerprint,
+		Daylight:    e.Daylight,
+		ModCheck:    e.ModCheck,
+		Upstream:    e.Upstream,
+		Workshop:    e.Workshop,
+		Latency:     e.Latency,
+		Maintenance: e.Maintenance,
+	}
+}
+
+func v1Result(r *model.Result) *ResultV1 {
+	if r == nil {
+		return nil
+	}
+	out := &ResultV1{
+		BattlEye:         r.BattlEye,
+		Endpoint:         r.Endpoint,
+		Environment:      r.Environment,
+		FirstPersonOnly:  r.FirstPersonOnly,
+		Folder:           r.Folder,
+		Game:             r.Game,
+		GamePort:         r.GamePort,
+		Map:              r.Map,
+		MaxPlayers:       r.MaxPlayers,
+		Mission:          r.Mission,
+		Mods:             r.Mods,
+		Name:             r.Name,
+		NameOverride:     r.NameOverride,
+		Password:         r.Password,
+		Players:          r.Players,
+		Profile:          r.Profile,
+		Shard:            r.Shard,
+		Sponsor:          r.Sponsor,
+		Time:             r.Time,
+		TimeAcceleration: r.TimeAcceleration,
+		Vac:              r.Vac,
+		Version:          r.Version,
+	}
+	if r.MaxPlayers > 0 {
+		out.FillPercent = math.Round(float64(r.Players)*1000/float64(r.MaxPlayers)) / 10
+	}
+	return out
+}
@@ -0,0 +1,299 @@
+// Package backup writes and restores portable archives of an instance's state: the server store, the
+// external IP, and the SQLite history database. Archives are gzipped tar files with a manifest that is
+// checked before anything is restored, so a backup from a newer, incompatible build is rejected whole.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/buildinfo"
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+	"github.com/jsirianni/dzsa-sync/internal/history"
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+)
+
+// FormatVersion is the archive layout this build writes and reads.
+const FormatVersion = 1
+
+// Archive entries.
+const (
+	manifestFile = "manifest.json"
+	storeFile    = "store.json"
+	ipFile       = "ip.json"
+	historyFile  = "history.db"
+)
+
+// maxJSONEntry caps the size of the JSON entries of an archive.
+const maxJSONEntry = 64 << 20
+
+// Manifest describes an archive. It is the first entry.
+type Manifest struct {
+	// Format is the archive layout version.
+	Format    int       `json:"format"`
+	CreatedAt time.Time `json:"created_at"`
+	// Version is the dzsa-sync build that wrote the archive.
+	Version      string `json:"version"`
+	InstanceName string `json:"instance_name,omitempty"`
+	// HistorySchema is the SQLite schema version of history.db; 0 when the archive has no history.
+	HistorySchema int `json:"history_schema,omitempty"`
+}
+
+// ipState is the body of ip.json.
+type ipState struct {
+	ExternalIP string `json:"external_ip"`
+}
+
+// Result reports what Restore applied.
+type Result struct {
+	Manifest Manifest `json:"manifest"`
+	// Servers is the number of servers with a result in the archive.
+	Servers int `json:"servers"`
+	// HistoryRecords is the number of history records imported.
+	HistoryRecords int64 `json:"history_records"`
+	// Warnings are parts of the archive that were not applied, and why.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Options configures a Service.
+type Options struct {
+	// Store is backed up and restored.
+	Store *servers.Store
+	// InstanceName is recorded in the manifest.
+	InstanceName string
+	// Address returns the current external IP. It is recorded, and compared on restore.
+	Address func() string
+	// History is backed up and imported into when set.
+	History *history.SQLite
+	// TempDir holds the history copy while an archive is written or read. Empty uses os.TempDir.
+	TempDir string
+}
+
+// Service writes and restores archives of a running instance.
+type Service struct {
+	opts Options
+}
+
+// New returns a backup service.
+func New(opts Options) *Service {
+	return &Service{opts: opts}
+}
+
+// Backup writes an archive of the current state to w.
+func (s *Service) Backup(ctx context.Context, w io.Writer) error {
+	m := Manifest{
+		Format:       FormatVersion,
+		CreatedAt:    time.Now().UTC(),
+		Version:      buildinfo.Get().Version,
+		InstanceName: s.opts.InstanceName,
+	}
+	var historyPath string
+	if s.opts.History != nil {
+		dir, err := os.MkdirTemp(s.opts.TempDir, "dzsa-sync-backup-")
+		if err != nil {
+			return errkind.Errorf(errkind.Internal, "temp dir: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		historyPath = filepath.Join(dir, historyFile)
+		if err := s.opts.History.Backup(ctx, historyPath); err != nil {
+			return errkind.Errorf(errkind.Internal, "history: %w", err)
+		}
+		m.HistorySchema = history.SQLiteSchemaVersion()
+	}
+	var ip ipState
+	if s.opts.Address != nil {
+		ip.ExternalIP = s.opts.Address()
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, e := range []struct {
+		name string
+		v    any
+	}{{manifestFile, m}, {storeFile, s.opts.Store.Snapshot()}, {ipFile, ip}} {
+		if err := writeJSON(tw, e.name, m.CreatedAt, e.v); err != nil {
+			return err
+		}
+	}
+	if historyPath != "" {
+		if err := writeFile(tw, historyFile, m.CreatedAt, historyPath); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("close archive: %w", err)
+	}
+	return gz.Close()
+}
+
+func writeJSON(tw *tar.Writer, name string, modTime time.Time, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errkind.Errorf(errkind.Internal, "encode %s: %w", name, err)
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(b)), ModTime: modTime}); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	if _, err := tw.Write(b); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+func writeFile(tw *tar.Writer, name string, modTime time.Time, path string) error {
+	f, err := os.Open(path) // #nosec G304 -- path is a temp file created by Backup
+	if err != nil {
+		return fmt.Errorf("open %s: %w", name, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", name, err)
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: info.Size(), ModTime: modTime}); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+// Restore reads the archive from r, checks that this build can read it, and applies it: the store
+// snapshot is restored, and history records older than every stored record are imported. The external IP
+// is not applied, since servers must register with the IP of the host they run on; a difference is reported
+// as a warning. Errors about the archive itself are of kind errkind.Validation.
+func (s *Service) Restore(ctx context.Context, r io.Reader) (Result, error) {
+	dir, err := os.MkdirTemp(s.opts.TempDir, "dzsa-sync-restore-")
+	if err != nil {
+		return Result{}, errkind.Errorf(errkind.Internal, "temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	a, err := read(r, dir)
+	if err != nil {
+		return Result{}, err
+	}
+
+	res := Result{Manifest: a.manifest, Servers: len(a.snapshot.Results)}
+	s.opts.Store.Restore(a.snapshot)
+	if s.opts.Address != nil {
+		if current := s.opts.Address(); a.ip.ExternalIP != "" && current != "" && a.ip.ExternalIP != current {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("backup was taken with external IP %s; servers keep registering with %s", a.ip.ExternalIP, current))
+		}
+	}
+	switch {
+	case a.historyPath == "":
+	case s.opts.History == nil:
+		res.Warnings = append(res.Warnings, "backup contains history, but history.sqlite is not enabled")
+	default:
+		n, err := s.opts.History.Import(ctx, a.historyPath)
+		if err != nil {
+			return res, errkind.Errorf(errkind.Internal, "import history: %w", err)
+		}
+		res.HistoryRecords = n
+	}
+	return res, nil
+}
+
+// archive is a read and validated archive. history.db is extracted to historyPath.
+type archive struct {
+	manifest    Manifest
+	snapshot    servers.Snapshot
+	ip          ipState
+	historyPath string
+}
+
+// read reads and validates an archive, extracting history.db into dir.
+func read(r io.Reader, dir string) (*archive, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errkind.Errorf(errkind.Validation, "not a backup archive: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	a := &archive{}
+	seenManifest, seenStore := false, false
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errkind.Errorf(errkind.Validation, "read archive: %w", err)
+		}
+		if !seenManifest && h.Name != manifestFile {
+			return nil, errkind.Errorf(errkind.Validation, "not a backup archive: first entry is %q, want %s", h.Name, manifestFile)
+		}
+		switch h.Name {
+		case manifestFile:
+			if err := readJSON(tr, h.Name, &a.manifest); err != nil {
+				return nil, err
+			}
+			if err := checkManifest(a.manifest); err != nil {
+				return nil, err
+			}
+			seenManifest = true
+		case storeFile:
+			if err := readJSON(tr, h.Name, &a.snapshot); err != nil {
+				return nil, err
+			}
+			seenStore = true
+		case ipFile:
+			if err := readJSON(tr, h.Name, &a.ip); err != nil {
+				return nil, err
+			}
+		case historyFile:
+			a.historyPath = filepath.Join(dir, historyFile)
+			if err := extract(tr, a.historyPath); err != nil {
+				return nil, err
+			}
+		}
+		// Unknown entries are skipped, so a compatible newer build can add optional ones.
+	}
+	if !seenManifest || !seenStore {
+		return nil, errkind.Errorf(errkind.Validation, "incomplete backup archive: %s and %s are required", manifestFile, storeFile)
+	}
+	if a.historyPath != "" && a.manifest.HistorySchema == 0 {
+		return nil, errkind.Errorf(errkind.Validation, "backup archive has %s without a history schema version", historyFile)
+	}
+	return a, nil
+}
+
+// checkManifest rejects archives this build cannot read.
+func checkManifest(m Manifest) error {
+	if m.Format != FormatVersion {
+		return errkind.Errorf(errkind.Validation, "backup format %d is not supported (this build reads format %d)", m.Format, FormatVersion)
+	}
+	if m.HistorySchema > history.SQLiteSchemaVersion() {
+		return errkind.Errorf(errkind.Validation, "backup history schema %d is newer than this build's %d; restore with dzsa-sync %s or later",
+			m.HistorySchema, history.SQLiteSchemaVersion(), m.Version)
+	}
+	return nil
+}
+
+func readJSON(r io.Reader, name string, v any) error {
+	if err := json.NewDecoder(io.LimitReader(r, maxJSONEntry)).Decode(v); err != nil {
+		return errkind.Errorf(errkind.Validation, "decode %s: %w", name, err)
+	}
+	return nil
+}
+
+func extract(r io.Reader, path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600) // #nosec G304 -- path is in a temp dir
+	if err != nil {
+		return errkind.Errorf(errkind.Internal, "create %s: %w", path, err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return errkind.Errorf(errkind.Validation, "extract %s: %w", filepath.Base(path), err)
+	}
+	return f.Close()
+}
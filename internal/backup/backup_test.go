@@ -0,0 +1,120 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+	"github.com/jsirianni/dzsa-sync/internal/history"
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+	"github.com/jsirianni/dzsa-sync/model"
+)
+
+func newSQLite(t *testing.T, name string) *history.SQLite {
+	t.Helper()
+	db, err := history.NewSQLite(context.Background(), filepath.Join(t.TempDir(), name))
+	if err != nil {
+		t.Fatalf("NewSQLite() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestBackupRestore(t *testing.T) {
+	ctx := context.Background()
+	srcStore := servers.New([]int{2424})
+	srcStore.Set(2424, &model.Result{Name: "main", Players: 5, MaxPlayers: 60, Endpoint: model.Endpoint{IP: "203.0.113.10", Port: 2424}})
+	srcHistory := newSQLite(t, "src.db")
+	if err := srcHistory.Write(ctx, history.Record{Time: time.Now().Add(-time.Hour), Server: "main", Port: 2424, Online: true}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	src := New(Options{Store: srcStore, InstanceName: "box-1", Address: func() string { return "203.0.113.10" }, History: srcHistory, TempDir: t.TempDir()})
+	var buf bytes.Buffer
+	if err := src.Backup(ctx, &buf); err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+
+	dstStore := servers.New([]int{2424})
+	dst := New(Options{Store: dstStore, Address: func() string { return "198.51.100.7" }, History: newSQLite(t, "dst.db"), TempDir: t.TempDir()})
+	res, err := dst.Restore(ctx, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if res.Manifest.InstanceName != "box-1" || res.Manifest.HistorySchema != history.SQLiteSchemaVersion() || res.Servers != 1 || res.HistoryRecords != 1 {
+		t.Errorf("Restore() = %+v", res)
+	}
+	if len(res.Warnings) != 1 || !strings.Contains(res.Warnings[0], "203.0.113.10") {
+		t.Errorf("Restore() warnings = %q, want the external IP difference", res.Warnings)
+	}
+	if r, ok := dstStore.Get(2424); !ok || r.Players != 5 {
+		t.Errorf("Get(2424) after restore = %+v, %v", r, ok)
+	}
+
+	// Without SQLite history the store is still restored, and the history is reported as skipped.
+	res, err = New(Options{Store: servers.New([]int{2424})}).Restore(ctx, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Restore() without history error = %v", err)
+	}
+	if res.HistoryRecords != 0 || len(res.Warnings) != 1 || !strings.Contains(res.Warnings[0], "history.sqlite") {
+		t.Errorf("Restore() without history = %+v", res)
+	}
+}
+
+// archiveOf writes a gzipped tar with the given entries in order.
+func archiveOf(t *testing.T, entries ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		if err := tw.WriteHeader(&tar.Header{Name: e[0], Mode: 0o600, Size: int64(len(e[1]))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRestore_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive []byte
+		want    string
+	}{
+		{"not gzip", []byte("hello"), "not a backup archive"},
+		{"manifest not first", archiveOf(t, [2]string{storeFile, `{}`}, [2]string{manifestFile, `{"format":1}`}), "first entry"},
+		{"newer format", archiveOf(t, [2]string{manifestFile, `{"format":2}`}, [2]string{storeFile, `{}`}), "format 2 is not supported"},
+		{"newer history schema", archiveOf(t, [2]string{manifestFile, `{"format":1,"history_schema":99}`}, [2]string{storeFile, `{}`}), "newer than"},
+		{"no store", archiveOf(t, [2]string{manifestFile, `{"format":1}`}), "incomplete"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := servers.New([]int{2424})
+			store.Set(2424, &model.Result{Name: "main"})
+			_, err := New(Options{Store: store}).Restore(context.Background(), bytes.NewReader(tt.archive))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Restore() error = %v, want %q", err, tt.want)
+			}
+			if errkind.Of(err) != errkind.Validation {
+				t.Errorf("errkind.Of() = %s, want %s", errkind.Of(err), errkind.Validation)
+			}
+			if _, ok := store.Get(2424); !ok {
+				t.Error("a rejected archive changed the store")
+			}
+		})
+	}
+}
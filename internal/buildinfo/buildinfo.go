@@ -0,0 +1,84 @@
+// Package buildinfo holds version metadata injected at build time, for the CLI, logs, and API.
+//
+// Set the values with -ldflags, e.g.
+//
+//	-X github.com/jsirianni/dzsa-sync/internal/buildinfo.Version=v1.2.3
+//	-X github.com/jsirianni/dzsa-sync/internal/buildinfo.Commit=abc1234
+//	-X github.com/jsirianni/dzsa-sync/internal/buildinfo.Date=2025-01-01T00:00:00Z
+//
+// When Commit or Date are not injected, they fall back to the VCS stamp Go embeds in the binary.
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// Injected with -ldflags -X.
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+// Info is the build metadata of the running binary.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+var (
+	once sync.Once
+	info Info
+)
+
+// Get returns the build metadata, resolving VCS fallbacks on first use.
+func Get() Info {
+	once.Do(func() {
+		info = Info{
+			Version:   Version,
+			Commit:    Commit,
+			Date:      Date,
+			GoVersion: runtime.Version(),
+			Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		}
+		bi, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = s.Value
+				}
+			}
+		}
+	})
+	return info
+}
+
+// String returns a one-line summary, e.g. "v1.2.3 (commit abc1234, built 2025-01-01T00:00:00Z, go1.24.0 linux/amd64)".
+func (i Info) String() string {
+	commit := i.Commit
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	if commit == "" {
+		commit = "unknown"
+	}
+	date := i.Date
+	if date == "" {
+		date = "unknown"
+	}
+	return fmt.Sprintf("%s (commit %s, built %s, %s %s)", i.Version, commit, date, i.GoVersion, i.Platform)
+}
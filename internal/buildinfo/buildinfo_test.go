@@ -0,0 +1,17 @@
+package buildinfo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInfo_String(t *testing.T) {
+	i := Info{Version: "v1.2.3", Commit: "0123456789abcdef", Date: "2025-01-01T00:00:00Z", GoVersion: "go1.24.0", Platform: "linux/amd64"}
+	want := "v1.2.3 (commit 0123456789ab, built 2025-01-01T00:00:00Z, go1.24.0 linux/amd64)"
+	if got := i.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got := (Info{Version: "dev"}).String(); !strings.Contains(got, "commit unknown, built unknown") {
+		t.Errorf("String() = %q, want unknown commit and date", got)
+	}
+}
@@ -0,0 +1,215 @@
+// Package configdiff compares the config file on disk with the config the daemon applied, so an operator can
+// see whether an edit is pending a reload and why a reload was rejected, and applies the server list of the
+// file on a hot reload.
+package configdiff
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+)
+
+// Result is the comparison served by GET /api/v1/config/diff.
+type Result struct {
+	// Path is the config file.
+	Path string `json:"path"`
+	// AppliedAt is when the running process loaded the applied config.
+	AppliedAt time.Time `json:"applied_at"`
+	// Pending is true when the file differs from the applied config.
+	Pending bool `json:"pending"`
+	// Changes are the settings that differ, from the applied config to the file.
+	Changes []config.Change `json:"changes"`
+	// Error is why the file cannot be applied as it is: it cannot be read or parsed, or fails validation.
+	Error string `json:"error,omitempty"`
+	// LastReload is the last reload of this process that failed, if any.
+	LastReload *Reload `json:"last_reload,omitempty"`
+}
+
+// Reload is a rejected reload: a hot reload (SIGHUP, or POST /api/v1/reload), or a graceful restart (SIGUSR2,
+// or systemctl reload).
+type Reload struct {
+	At time.Time `json:"at"`
+	// Error is why the reload failed.
+	Error string `json:"error"`
+	// ConfigError is why the file failed to load at the time, the usual cause, if it did.
+	ConfigError string `json:"config_error,omitempty"`
+}
+
+// Applied is the outcome of a hot reload, served by POST /api/v1/reload.
+type Applied struct {
+	// Added, Removed, and Changed are the query ports of the servers the reload started, stopped, and
+	// restarted with new settings.
+	Added   []int `json:"added"`
+	Removed []int `json:"removed"`
+	Changed []int `json:"changed"`
+	// RestartRequired are the settings outside servers and hosts that changed, which a hot reload does not
+	// apply. A graceful restart does.
+	RestartRequired []string `json:"restart_required"`
+}
+
+// Tracker holds the applied config and the last rejected reload. Safe for concurrent use.
+type Tracker struct {
+	path string
+
+	// reloadMu serializes hot reloads.
+	reloadMu sync.Mutex
+
+	mu         sync.Mutex
+	applied    *config.Config
+	appliedAt  time.Time
+	lastReload *Reload
+}
+
+// New returns a tracker for applied, loaded from path now.
+func New(path string, applied *config.Config) *Tracker {
+	return &Tracker{path: path, applied: applied, appliedAt: time.Now().UTC()}
+}
+
+// Diff reads the config file and compares it with the applied config. A file that fails validation is still
+// compared, as parsed.
+func (t *Tracker) Diff() Result {
+	t.mu.Lock()
+	r := Result{Path: t.path, AppliedAt: t.appliedAt, Changes: []config.Change{}}
+	applied := t.applied
+	if t.lastReload != nil {
+		cp := *t.lastReload
+		r.LastReload = &cp
+	}
+	t.mu.Unlock()
+
+	onDisk, err := config.NewFromFile(t.path)
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if onDisk == nil {
+		return r
+	}
+	changes, err := config.Diff(applied, onDisk)
+	if err != nil {
+		if r.Error == "" {
+			r.Error = err.Error()
+		}
+		return r
+	}
+	if changes != nil {
+		r.Changes = changes
+	}
+	r.Pending = len(changes) > 0
+	return r
+}
+
+// RecordReload records a failed reload with err, and the config file's error at the time.
+func (t *Tracker) RecordReload(err error) {
+	reload := &Reload{At: time.Now().UTC(), Error: err.Error()}
+	if _, cerr := config.NewFromFile(t.path); cerr != nil {
+		reload.ConfigError = cerr.Error()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastReload = reload
+}
+
+// Reload reads the config file and passes its servers, including those of hosts, to apply, which reconciles
+// the running workers with them. The applied config then takes the file's servers and hosts; other changed
+// settings are listed in Applied.RestartRequired. A file that fails to load is rejected and recorded.
+func (t *Tracker) Reload(apply func([]config.Server)) (Applied, error) {
+	t.reloadMu.Lock()
+	defer t.reloadMu.Unlock()
+	return t.reload(apply)
+}
+
+// EditServers rewrites the config file with edit, e.g. a call of config.AppendServer, and then reloads it like
+// Reload, which also applies any other pending server edits of the file. The file is only written when the
+// edited contents load, so a rejected edit leaves it untouched.
+func (t *Tracker) EditServers(edit func([]byte) ([]byte, error), apply func([]config.Server)) (Applied, error) {
+	t.reloadMu.Lock()
+	defer t.reloadMu.Unlock()
+
+	info, err := os.Stat(t.path)
+	if err != nil {
+		return Applied{}, fmt.Errorf("stat config: %w", err)
+	}
+	b, err := os.ReadFile(t.path)
+	if err != nil {
+		return Applied{}, fmt.Errorf("read config: %w", err)
+	}
+	edited, err := edit(b)
+	if err != nil {
+		return Applied{}, errkind.Wrap(errkind.Config, err)
+	}
+	if _, err := config.Parse(edited); err != nil {
+		return Applied{}, err
+	}
+	if err := os.WriteFile(t.path, edited, info.Mode().Perm()); err != nil {
+		return Applied{}, fmt.Errorf("write config: %w", err)
+	}
+	return t.reload(apply)
+}
+
+// reload is Reload without reloadMu, which the caller holds.
+func (t *Tracker) reload(apply func([]config.Server)) (Applied, error) {
+	onDisk, err := config.NewFromFile(t.path)
+	if err != nil {
+		err = fmt.Errorf("reload: %w", err)
+		t.mu.Lock()
+		t.lastReload = &Reload{At: time.Now().UTC(), Error: err.Error()}
+		t.mu.Unlock()
+		return Applied{}, err
+	}
+	t.mu.Lock()
+	applied := *t.applied
+	t.mu.Unlock()
+
+	out := Applied{Added: []int{}, Removed: []int{}, Changed: []int{}, RestartRequired: []string{}}
+	old := make(map[int]config.Server)
+	for _, s := range applied.AllServers() {
+		old[s.Port] = s
+	}
+	srvs := onDisk.AllServers()
+	for _, s := range srvs {
+		prev, ok := old[s.Port]
+		switch {
+		case !ok:
+			out.Added = append(out.Added, s.Port)
+		case prev != s:
+			out.Changed = append(out.Changed, s.Port)
+		}
+		delete(old, s.Port)
+	}
+	for port := range old {
+		out.Removed = append(out.Removed, port)
+	}
+	slices.Sort(out.Added)
+	slices.Sort(out.Removed)
+	slices.Sort(out.Changed)
+
+	changes, err := config.Diff(&applied, onDisk)
+	if err != nil {
+		return Applied{}, fmt.Errorf("reload: %w", err)
+	}
+	for _, c := range changes {
+		if strings.HasPrefix(c.Path, "servers") || strings.HasPrefix(c.Path, "hosts") {
+			continue
+		}
+		// Report each changed section once, e.g. api rather than api.port and api.host.
+		section, _, _ := strings.Cut(c.Path, ".")
+		section, _, _ = strings.Cut(section, "[")
+		if !slices.Contains(out.RestartRequired, section) {
+			out.RestartRequired = append(out.RestartRequired, section)
+		}
+	}
+
+	apply(srvs)
+	applied.Servers, applied.Hosts = onDisk.Servers, onDisk.Hosts
+	t.mu.Lock()
+	t.applied = &applied
+	t.appliedAt = time.Now().UTC()
+	t.mu.Unlock()
+	return out, nil
+}
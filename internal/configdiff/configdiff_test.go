@@ -0,0 +1,153 @@
+package configdiff
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jsirianni/dzsa-sync/config"
+)
+
+const appliedYAML = `log_path: stdout
+external_ip: 203.0.113.10
+servers:
+  - name: main
+    port: 2424
+`
+
+func TestTracker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(s string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(s), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(appliedYAML)
+	applied, err := config.NewFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := New(path, applied)
+
+	if r := tr.Diff(); r.Pending || len(r.Changes) != 0 || r.Error != "" || r.Path != path {
+		t.Errorf("Diff() of the unchanged file = %+v", r)
+	}
+
+	write(strings.Replace(appliedYAML, "2424", "2425", 1))
+	r := tr.Diff()
+	if !r.Pending || len(r.Changes) != 1 || r.Changes[0].Path != "servers[0].port" || r.Error != "" {
+		t.Errorf("Diff() of the edited file = %+v", r)
+	}
+
+	// A file that fails validation is compared as parsed, with the validation error.
+	write(strings.Replace(appliedYAML, "log_path: stdout\n", "", 1))
+	r = tr.Diff()
+	if !r.Pending || !strings.Contains(r.Error, "log_path is required") {
+		t.Errorf("Diff() of an invalid file = %+v", r)
+	}
+	tr.RecordReload(errors.New("new process exited before it was ready"))
+	r = tr.Diff()
+	if r.LastReload == nil || r.LastReload.Error != "new process exited before it was ready" ||
+		!strings.Contains(r.LastReload.ConfigError, "log_path is required") {
+		t.Errorf("LastReload = %+v", r.LastReload)
+	}
+
+	write("servers: [")
+	if r := tr.Diff(); r.Error == "" || r.Pending {
+		t.Errorf("Diff() of an unparsable file = %+v", r)
+	}
+}
+
+func TestTracker_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(s string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(s), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(appliedYAML + "  - name: old\n    port: 2524\n")
+	applied, err := config.NewFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := New(path, applied)
+
+	var got []config.Server
+	apply := func(srvs []config.Server) { got = srvs }
+	write(`log_path: stdout
+external_ip: 203.0.113.10
+shutdown_timeout: 1m
+servers:
+  - name: renamed
+    port: 2424
+  - name: new
+    port: 2624
+`)
+	a, err := tr.Reload(apply)
+	if err != nil {
+		t.Fatalf("Reload() = %v", err)
+	}
+	if len(got) != 2 || got[0].Name != "renamed" || got[1].Port != 2624 {
+		t.Errorf("applied servers = %+v", got)
+	}
+	if !slices.Equal(a.Added, []int{2624}) || !slices.Equal(a.Removed, []int{2524}) || !slices.Equal(a.Changed, []int{2424}) ||
+		!slices.Equal(a.RestartRequired, []string{"shutdown_timeout"}) {
+		t.Errorf("Reload() = %+v", a)
+	}
+	// Only the setting that needs a restart is still pending.
+	if r := tr.Diff(); len(r.Changes) != 1 || r.Changes[0].Path != "shutdown_timeout" {
+		t.Errorf("Diff() after Reload = %+v", r)
+	}
+
+	got = nil
+	write("servers: [")
+	if _, err := tr.Reload(apply); err == nil || got != nil {
+		t.Errorf("Reload() of an unparsable file = %v, applied %+v", err, got)
+	}
+	if r := tr.Diff(); r.LastReload == nil || !strings.Contains(r.LastReload.Error, "reload:") {
+		t.Errorf("LastReload = %+v", r.LastReload)
+	}
+}
+
+func TestTracker_EditServers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(appliedYAML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	applied, err := config.NewFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := New(path, applied)
+
+	var got []config.Server
+	apply := func(srvs []config.Server) { got = srvs }
+	a, err := tr.EditServers(func(b []byte) ([]byte, error) {
+		return config.AppendServer(b, config.Server{Name: "modded", Port: 2324})
+	}, apply)
+	if err != nil {
+		t.Fatalf("EditServers() = %v", err)
+	}
+	if len(got) != 2 || got[1].Name != "modded" || !slices.Equal(a.Added, []int{2324}) {
+		t.Errorf("EditServers() = %+v, applied %+v", a, got)
+	}
+	if r := tr.Diff(); r.Pending {
+		t.Errorf("Diff() after EditServers = %+v", r)
+	}
+
+	// A duplicate port fails validation and leaves the file as it was.
+	before, _ := os.ReadFile(path)
+	if _, err := tr.EditServers(func(b []byte) ([]byte, error) {
+		return config.AppendServer(b, config.Server{Name: "dup", Port: 2424})
+	}, apply); err == nil {
+		t.Error("EditServers() with a duplicate port: error = nil")
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(before) {
+		t.Errorf("config after a rejected edit:\n%s", after)
+	}
+}
@@ -0,0 +1,278 @@
+// Package controller polls the API of dzsa-sync agents, the instances that sync the servers on each game
+// host, and combines their state so one controller serves the servers of a whole fleet.
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/api"
+	"github.com/jsirianni/dzsa-sync/internal/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultInterval = 30 * time.Second
+	// maxBody caps the size of an agent response.
+	maxBody = 8 << 20
+)
+
+// Options configures a Controller.
+type Options struct {
+	// Agents are the instances to poll.
+	Agents []config.Agent
+	// Interval is the time between polls of each agent. Zero uses 30s.
+	Interval time.Duration
+	// HTTPClient is used for every agent request. Nil uses a client with a 30s timeout.
+	HTTPClient *http.Client
+	// Recorder records agent_up when set.
+	Recorder metrics.AgentRecorder
+	// Logger logs agents going down and coming back. Nil disables logging.
+	Logger *zap.Logger
+}
+
+// Controller holds the last known state of every agent. It implements api.Fleet.
+type Controller struct {
+	client   *http.Client
+	interval time.Duration
+	recorder metrics.AgentRecorder
+	logger   *zap.Logger
+	agents   []*agent
+}
+
+// agent is the state of one agent, updated by its poll loop.
+type agent struct {
+	cfg config.Agent
+
+	mu     sync.Mutex
+	status api.AgentStatus
+	// version is the agent's store version at the last poll, sent as ?since= so only changes are fetched.
+	version  uint64
+	servers  map[int]api.ServerV1
+	statuses []api.ServerStatus
+}
+
+// New returns a controller for opts.Agents. Call Run to start polling.
+func New(opts Options) *Controller {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultInterval
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+	c := &Controller{
+		client:   opts.HTTPClient,
+		interval: opts.Interval,
+		recorder: opts.Recorder,
+		logger:   opts.Logger,
+	}
+	for _, a := range opts.Agents {
+		c.agents = append(c.agents, &agent{
+			cfg:    a,
+			status: api.AgentStatus{Name: a.Name, URL: a.URL, Error: "not polled yet"},
+		})
+	}
+	return c
+}
+
+// Run polls every agent immediately and then every interval until ctx is done.
+func (c *Controller) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, a := range c.agents {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(c.interval)
+			defer ticker.Stop()
+			for {
+				c.poll(ctx, a)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// poll fetches the agent's status and the servers that changed since the last poll. When the agent cannot
+// be reached, its last known servers are kept and it is reported as down.
+func (c *Controller) poll(ctx context.Context, a *agent) {
+	var status api.StatusResponse
+	err := c.get(ctx, a.cfg, "/api/v1/status", &status)
+	var delta api.DeltaV1
+	if err == nil {
+		a.mu.Lock()
+		since := a.version
+		a.mu.Unlock()
+		err = c.get(ctx, a.cfg, "/api/v1/servers?since="+strconv.FormatUint(since, 10), &delta)
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	a.mu.Lock()
+	wasUp := a.status.Up
+	if err != nil {
+		a.status.Up = false
+		a.status.Error = err.Error()
+	} else {
+		a.status.Up = true
+		a.status.Error = ""
+		a.status.LastSeen = time.Now()
+		a.status.InstanceName = status.InstanceName
+		a.status.Version = status.Version
+		a.status.ExternalIP = status.ExternalIP
+		a.status.Role = status.Role
+		a.status.Servers = len(status.Servers)
+		a.statuses = status.Servers
+		if delta.Full || a.servers == nil {
+			a.servers = make(map[int]api.ServerV1, len(delta.Servers))
+		}
+		for _, s := range delta.Servers {
+			a.servers[s.Port] = s
+		}
+		for _, port := range delta.Removed {
+			delete(a.servers, port)
+		}
+		a.version = delta.Version
+	}
+	a.mu.Unlock()
+
+	if c.recorder != nil {
+		c.recorder.RecordAgentUp(ctx, a.cfg.Name, err == nil)
+	}
+	switch {
+	case err != nil && wasUp:
+		c.logger.Warn("agent down", zap.String("agent", a.cfg.Name), zap.Error(err))
+	case err != nil:
+		c.logger.Debug("agent poll failed", zap.String("agent", a.cfg.Name), zap.Error(err))
+	case !wasUp:
+		c.logger.Info("agent up", zap.String("agent", a.cfg.Name), zap.Int("servers", len(status.Servers)))
+	}
+}
+
+// get decodes the JSON response of the agent's API at path into v.
+func (c *Controller) get(ctx context.Context, a config.Agent, path string, v any) error {
+	resp, err := c.do(ctx, a, http.MethodGet, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status code: %d", path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxBody)).Decode(v); err != nil {
+		return fmt.Errorf("%s: decode response: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Controller) do(ctx context.Context, a config.Agent, method, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(a.URL, "/")+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "dzsa-sync/1.0")
+	req.Header.Set("Accept", "application/json")
+	for k, v := range a.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	return resp, nil
+}
+
+// Agents returns every agent in config order.
+func (c *Controller) Agents() []api.AgentStatus {
+	out := make([]api.AgentStatus, 0, len(c.agents))
+	for _, a := range c.agents {
+		a.mu.Lock()
+		out = append(out, a.status)
+		a.mu.Unlock()
+	}
+	return out
+}
+
+// Servers returns the servers of every agent in config order, sorted by port within an agent.
+func (c *Controller) Servers() []api.FleetServer {
+	out := []api.FleetServer{}
+	for _, a := range c.agents {
+		a.mu.Lock()
+		start := len(out)
+		for _, s := range a.servers {
+			out = append(out, api.FleetServer{Agent: a.cfg.Name, ServerV1: s})
+		}
+		a.mu.Unlock()
+		slices.SortFunc(out[start:], func(x, y api.FleetServer) int { return x.Port - y.Port })
+	}
+	return out
+}
+
+// Statuses returns the managed servers of every agent in config order.
+func (c *Controller) Statuses() []api.FleetServerStatus {
+	out := []api.FleetServerStatus{}
+	for _, a := range c.agents {
+		a.mu.Lock()
+		for _, s := range a.statuses {
+			out = append(out, api.FleetServerStatus{Agent: a.cfg.Name, ServerStatus: s})
+		}
+		a.mu.Unlock()
+	}
+	return out
+}
+
+// Sync forwards a sync request to the named agent, or to every agent when name is empty. Port 0 syncs
+// every server of the agent.
+func (c *Controller) Sync(ctx context.Context, name string, port int) error {
+	if name == "" {
+		var errs []error
+		for _, a := range c.agents {
+			errs = append(errs, c.sync(ctx, a.cfg, port))
+		}
+		return errors.Join(errs...)
+	}
+	for _, a := range c.agents {
+		if a.cfg.Name == name {
+			return c.sync(ctx, a.cfg, port)
+		}
+	}
+	return fmt.Errorf("agent %s: %w", name, api.ErrNotFound)
+}
+
+func (c *Controller) sync(ctx context.Context, a config.Agent, port int) error {
+	path := "/api/v1/sync"
+	if port != 0 {
+		path += "/" + strconv.Itoa(port)
+	}
+	resp, err := c.do(ctx, a, http.MethodPost, path)
+	if err != nil {
+		return fmt.Errorf("agent %s: %w", a.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusAccepted {
+		return nil
+	}
+	if resp.StatusCode == http.StatusNotFound && port != 0 {
+		return fmt.Errorf("agent %s: server %d: %w", a.Name, port, api.ErrNotFound)
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("agent %s: status %d: %s", a.Name, resp.StatusCode, strings.TrimSpace(string(b)))
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/api"
+)
+
+// fakeAgent serves the agent API: a full list for ?since=0 and a delta that removes 2324 afterwards.
+func fakeAgent(t *testing.T, synced *[]string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/status", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(api.StatusResponse{
+			InstanceName: "box-1",
+			Version:      "1.2.3",
+			ExternalIP:   "203.0.113.10",
+			Servers:      []api.ServerStatus{{Name: "main", Port: 2424, Players: 5}},
+		})
+	})
+	mux.HandleFunc("GET /api/v1/servers", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		d := api.DeltaV1{Version: 2, Servers: []api.ServerV1{{Port: 2424}, {Port: 2324}}}
+		if r.URL.Query().Get("since") == "2" {
+			d = api.DeltaV1{Version: 3, Servers: []api.ServerV1{{Port: 2424, Fingerprint: "new"}}, Removed: []int{2324}}
+		}
+		_ = json.NewEncoder(w).Encode(d)
+	})
+	mux.HandleFunc("POST /api/v1/sync/{port}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("port") != "2424" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		*synced = append(*synced, r.URL.Path)
+		w.WriteHeader(http.StatusAccepted)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestPoll(t *testing.T) {
+	var synced []string
+	agentSrv := fakeAgent(t, &synced)
+	down := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(down.Close)
+
+	c := New(Options{Agents: []config.Agent{
+		{Name: "box-1", URL: agentSrv.URL + "/", Headers: map[string]string{"Authorization": "Bearer secret"}},
+		{Name: "box-2", URL: down.URL},
+	}})
+	ctx := context.Background()
+	for _, a := range c.agents {
+		c.poll(ctx, a)
+	}
+
+	agents := c.Agents()
+	if len(agents) != 2 {
+		t.Fatalf("Agents() = %+v", agents)
+	}
+	if a := agents[0]; !a.Up || a.InstanceName != "box-1" || a.ExternalIP != "203.0.113.10" || a.Servers != 1 || a.LastSeen.IsZero() {
+		t.Errorf("Agents()[0] = %+v", a)
+	}
+	if a := agents[1]; a.Up || a.Error == "" {
+		t.Errorf("Agents()[1] = %+v, want down with an error", a)
+	}
+	if got := c.Servers(); len(got) != 2 || got[0].Port != 2324 || got[1].Port != 2424 || got[0].Agent != "box-1" {
+		t.Errorf("Servers() = %+v, want 2324 and 2424 of box-1", got)
+	}
+	if got := c.Statuses(); len(got) != 1 || got[0].Agent != "box-1" || got[0].Players != 5 {
+		t.Errorf("Statuses() = %+v", got)
+	}
+
+	// The second poll asks for changes since version 2 and applies them.
+	c.poll(ctx, c.agents[0])
+	if got := c.Servers(); len(got) != 1 || got[0].Port != 2424 || got[0].Fingerprint != "new" {
+		t.Errorf("Servers() after delta = %+v, want only the updated 2424", got)
+	}
+
+	// An agent that goes down keeps its last known servers.
+	agentSrv.Close()
+	c.poll(ctx, c.agents[0])
+	if a := c.Agents()[0]; a.Up || a.Error == "" {
+		t.Errorf("Agents()[0] after close = %+v, want down", a)
+	}
+	if got := c.Servers(); len(got) != 1 {
+		t.Errorf("Servers() after close = %+v, want the last known server", got)
+	}
+}
+
+func TestSync(t *testing.T) {
+	var synced []string
+	agentSrv := fakeAgent(t, &synced)
+	c := New(Options{Agents: []config.Agent{{Name: "box-1", URL: agentSrv.URL}}})
+	ctx := context.Background()
+
+	if err := c.Sync(ctx, "box-1", 2424); err != nil {
+		t.Fatalf("Sync(box-1, 2424) = %v", err)
+	}
+	if len(synced) != 1 || synced[0] != "/api/v1/sync/2424" {
+		t.Errorf("agent received %v, want /api/v1/sync/2424", synced)
+	}
+	if err := c.Sync(ctx, "box-1", 9999); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("Sync(box-1, 9999) = %v, want ErrNotFound", err)
+	}
+	if err := c.Sync(ctx, "box-9", 2424); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("Sync(box-9, 2424) = %v, want ErrNotFound", err)
+	}
+	// The fake agent has no POST /api/v1/sync, so syncing everything fails with its status.
+	if err := c.Sync(ctx, "", 0); err == nil || errors.Is(err, api.ErrNotFound) {
+		t.Errorf("Sync(all) = %v, want an agent error", err)
+	}
+}
@@ -0,0 +1,1164 @@
+// Package daemon runs the sync daemon: a worker per server, the store, history, checks, notifications, and
+// the API, until its context is cancelled. The binary adds logging, signals, and graceful restarts around
+// it, and package dzsasync exposes it to programs that embed the daemon.
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/client"
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/a2s"
+	"github.com/jsirianni/dzsa-sync/internal/api"
+	"github.com/jsirianni/dzsa-sync/internal/backup"
+	"github.com/jsirianni/dzsa-sync/internal/configdiff"
+	"github.com/jsirianni/dzsa-sync/internal/ddns"
+	"github.com/jsirianni/dzsa-sync/internal/discovery"
+	"github.com/jsirianni/dzsa-sync/internal/dnscache"
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+	"github.com/jsirianni/dzsa-sync/internal/exechook"
+	"github.com/jsirianni/dzsa-sync/internal/feed"
+	"github.com/jsirianni/dzsa-sync/internal/history"
+	"github.com/jsirianni/dzsa-sync/internal/httpclient"
+	"github.com/jsirianni/dzsa-sync/internal/ifconfig"
+	"github.com/jsirianni/dzsa-sync/internal/leader"
+	"github.com/jsirianni/dzsa-sync/internal/metrics"
+	"github.com/jsirianni/dzsa-sync/internal/notify"
+	"github.com/jsirianni/dzsa-sync/internal/redact"
+	"github.com/jsirianni/dzsa-sync/internal/remotewrite"
+	"github.com/jsirianni/dzsa-sync/internal/retry"
+	"github.com/jsirianni/dzsa-sync/internal/serverlog"
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+	"github.com/jsirianni/dzsa-sync/internal/statefile"
+	"github.com/jsirianni/dzsa-sync/internal/steam"
+	"github.com/jsirianni/dzsa-sync/internal/worker"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// DefaultAPIPort is the API port when api.port is not set.
+	DefaultAPIPort     = 8888
+	syncJitterMax      = 20 * time.Second
+	defaultHistoryPath = "/var/lib/dzsa-sync/history.db"
+)
+
+// Names of the API listeners, under which they are passed to Options.Handoff and looked up with
+// Options.Listener. The listeners of api.listeners are named ListenerExtra followed by their index.
+const (
+	ListenerAPI    = "api"
+	ListenerSocket = "socket"
+	ListenerAdmin  = "admin"
+	ListenerExtra  = "listener"
+)
+
+// Listener is an API listener and its name.
+type Listener struct {
+	Name     string
+	Listener net.Listener
+}
+
+// Options configures Run. Every field is optional.
+type Options struct {
+	// Logger receives the daemon's logs. Nil discards them.
+	Logger *zap.Logger
+	// Redactor hides IP addresses in API responses, history, notifications, and server logs. Nil leaves them.
+	// The Logger is expected to be wrapped with its Core already.
+	Redactor *redact.Redactor
+	// ConfigPath is the file the config was loaded from, compared against the running config by
+	// GET /api/v1/config/diff. Empty leaves the endpoint out.
+	ConfigPath string
+	// HTTPClient sends every outbound request. Nil builds one from the http config section.
+	HTTPClient *http.Client
+	// Client queries DZSA. Nil builds one from the HTTP client and the staging config section.
+	Client client.Client
+	// History receives every sync record in addition to the stores of the history config section.
+	History history.Sink
+	// HistoryReader serves the history API, rules, and reports instead of the stores of the history
+	// config section.
+	HistoryReader history.Reader
+	// Notifiers can be named by rules and reports like the notifiers config section, which they take
+	// precedence over.
+	Notifiers map[string]notify.Notifier
+	// DisableAPI leaves out the API listeners, e.g. when the embedding program serves its own.
+	DisableAPI bool
+	// Listener returns a listener to serve instead of listening anew, e.g. one inherited from the previous
+	// process. Nil always listens.
+	Listener func(name string) (net.Listener, bool)
+	// State is restored into the store before the first sync.
+	State *servers.Snapshot
+	// Ready is called once the daemon serves and syncs.
+	Ready func()
+	// Reload receives hot reload requests, which apply the server list of the file at ConfigPath like
+	// POST /api/v1/reload.
+	Reload <-chan os.Signal
+	// Restart receives graceful restart requests, which call Handoff. Once it succeeds, the background loops
+	// (discovery, the state file, DNS updates, IP checks) stop at once, and Run returns without flushing the
+	// feed, the state file, and remote_write, since the new process owns them.
+	Restart <-chan os.Signal
+	// Handoff passes the listeners and the store to a new process and returns its PID once it is ready.
+	Handoff func(listeners []Listener, state servers.Snapshot) (int, error)
+}
+
+// Run runs the daemon for cfg, which must be valid, until ctx is cancelled, then waits for the syncs in flight
+// within cfg.ShutdownTimeout and returns. Startup failures are returned. Metrics are registered globally, so
+// Run can be called once per process.
+func Run(ctx context.Context, cfg *config.Config, opts Options) error {
+	started := time.Now()
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	redactor := opts.Redactor
+	// stopCtx is cancelled when ctx is or an API listener fails. The workers outlive it, so the syncs in
+	// flight can finish while they are drained.
+	stopCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	workCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+
+	metricsProvider, err := metrics.NewProvider(cfg.InstanceName, OTLPOptions(cfg.Metrics))
+	if err != nil {
+		return fmt.Errorf("metrics provider: %w", err)
+	}
+	defer func() {
+		_ = metricsProvider.Shutdown(context.Background())
+	}()
+
+	recorder, err := metrics.NewHTTPRecorder()
+	if err != nil {
+		return fmt.Errorf("metrics recorder: %w", err)
+	}
+	playerCountRecorder, err := metrics.NewPlayerCountRecorder()
+	if err != nil {
+		return fmt.Errorf("player count recorder: %w", err)
+	}
+
+	modCheckRecorder, err := metrics.NewModCheckRecorder()
+	if err != nil {
+		return fmt.Errorf("mod check recorder: %w", err)
+	}
+
+	upstreamRecorder, err := metrics.NewUpstreamRecorder()
+	if err != nil {
+		return fmt.Errorf("upstream recorder: %w", err)
+	}
+	workshopRecorder, err := metrics.NewWorkshopRecorder()
+	if err != nil {
+		return fmt.Errorf("workshop recorder: %w", err)
+	}
+
+	nightRecorder, err := metrics.NewNightRecorder()
+	if err != nil {
+		return fmt.Errorf("night recorder: %w", err)
+	}
+
+	latencyRecorder, err := metrics.NewLatencyRecorder()
+	if err != nil {
+		return fmt.Errorf("latency recorder: %w", err)
+	}
+
+	dnsRecorder, err := metrics.NewDNSRecorder()
+	if err != nil {
+		return fmt.Errorf("dns recorder: %w", err)
+	}
+
+	retryRecorder, err := metrics.NewRetryRecorder()
+	if err != nil {
+		return fmt.Errorf("retry recorder: %w", err)
+	}
+
+	syncErrorRecorder, err := metrics.NewSyncErrorRecorder()
+	if err != nil {
+		return fmt.Errorf("sync error recorder: %w", err)
+	}
+
+	nextSyncRecorder, err := metrics.NewNextSyncRecorder()
+	if err != nil {
+		return fmt.Errorf("next sync recorder: %w", err)
+	}
+
+	notificationRecorder, err := metrics.NewNotificationRecorder()
+	if err != nil {
+		return fmt.Errorf("notification recorder: %w", err)
+	}
+
+	ipFlapRecorder, err := metrics.NewIPFlapRecorder()
+	if err != nil {
+		return fmt.Errorf("ip flap recorder: %w", err)
+	}
+
+	var httpOpts httpclient.Options
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		var err error
+		httpOpts, err = HTTPOptions(cfg.HTTP, dnsRecorder)
+		if err != nil {
+			return fmt.Errorf("http client: %w", err)
+		}
+		httpClient = httpclient.New(httpOpts)
+	}
+
+	dzsaOpts := client.Options{
+		HTTPClient: httpClient,
+		Recorder:   recorder,
+	}
+	// syncTarget is reported in the status API when syncs do not go to the live DZSA listing.
+	syncTarget := ""
+	if s := cfg.Staging; s != nil {
+		dzsaOpts.BaseURL = s.URL
+		syncTarget = s.URL
+		if s.DryRun {
+			syncTarget = "dry_run"
+		}
+		logger.Warn("staging mode: syncs are not sent to the live DZSA listing", zap.String("sync_target", syncTarget))
+	}
+	dzsaClient := opts.Client
+	if dzsaClient == nil {
+		dzsaClient = client.New(dzsaOpts)
+	}
+
+	// With detect_ipv6, each address family is detected over a connection of that family, since ifconfig.net
+	// reports the address the request came from.
+	ifconfigHTTP, ifconfigHTTP6 := httpClient, httpClient
+	if cfg.DetectIPv6 && opts.HTTPClient == nil {
+		familyOpts := httpOpts
+		familyOpts.Network = "tcp4"
+		ifconfigHTTP = httpclient.New(familyOpts)
+		familyOpts.Network = "tcp6"
+		ifconfigHTTP6 = httpclient.New(familyOpts)
+	}
+	ifconfigClient := ifconfig.New(
+		logger.With(zap.String("module", "ifconfig")),
+		ifconfigHTTP,
+		recorder,
+	)
+	ifconfig6Client := ifconfig.New(
+		logger.With(zap.String("module", "ifconfig"), zap.String("family", ifconfig.FamilyIPv6)),
+		ifconfigHTTP6,
+		recorder,
+	)
+	ifconfig6Client.Family = ifconfig.FamilyIPv6
+	if cfg.DetectIPv6 {
+		ifconfigClient.Family = ifconfig.FamilyIPv4
+	} else if cfg.ExternalIPv6 != "" {
+		ifconfig6Client.SetAddress(cfg.ExternalIPv6)
+	}
+
+	redactor.SetServerAddress(ifconfigClient.GetAddress)
+
+	// Without detect_ip or external_ip, every server belongs to a host with its own IP.
+	instanceIP := cfg.DetectIP || cfg.ExternalIP != ""
+	if !cfg.DetectIP && cfg.ExternalIP != "" {
+		ifconfigClient.SetAddress(cfg.ExternalIP)
+	}
+
+	var (
+		historySinks  []history.Sink
+		historyReader history.Reader
+		historyDB     *history.SQLite
+	)
+	if h := cfg.History; h != nil && h.Postgres != nil && h.Postgres.Enabled {
+		pgCtx, pgCancel := context.WithTimeout(stopCtx, 30*time.Second)
+		pg, err := history.NewPostgres(pgCtx, h.Postgres.DSN, h.Postgres.Timescale)
+		pgCancel()
+		if err != nil {
+			return fmt.Errorf("postgres history: %w", err)
+		}
+		defer pg.Close()
+		go history.RunRetention(stopCtx, logger.With(zap.String("module", "history")), pg, historyRetention(h.Postgres.Retention, 0, h.Postgres.HourlyRetention))
+		historySinks = append(historySinks, pg)
+		historyReader = pg
+	}
+	if h := cfg.History; h != nil && h.SQLite != nil && h.SQLite.Enabled {
+		path := h.SQLite.Path
+		if path == "" {
+			path = defaultHistoryPath
+		}
+		db, err := history.NewSQLite(stopCtx, path)
+		if err != nil {
+			return fmt.Errorf("sqlite history: %w", err)
+		}
+		defer db.Close()
+		go history.RunRetention(stopCtx, logger.With(zap.String("module", "history")), db, historyRetention(h.SQLite.Retention, history.DefaultRetention, h.SQLite.HourlyRetention))
+		historySinks = append(historySinks, db)
+		// Prefer the local database for API reads.
+		historyReader = db
+		historyDB = db
+	}
+	if opts.History != nil {
+		historySinks = append(historySinks, opts.History)
+	}
+	if opts.HistoryReader != nil {
+		historyReader = opts.HistoryReader
+	}
+	var historySink history.Sink
+	if len(historySinks) > 0 {
+		historySink = history.Multi(historySinks...)
+		if redactor != nil {
+			historySink = history.Redact(historySink, redactor.String)
+		}
+	}
+
+	// remoteWriter, feedWriter, and stateWriter are flushed once the workers are drained on shutdown.
+	var (
+		remoteWriter *remotewrite.Writer
+		feedWriter   *feed.Writer
+		stateWriter  *statefile.Writer
+	)
+	if rw := cfg.RemoteWrite; rw != nil && rw.Enabled {
+		remoteWriter = remotewrite.New(remotewrite.Options{
+			Logger:   logger.With(zap.String("module", "remotewrite")),
+			Client:   httpClient,
+			URL:      rw.URL,
+			Headers:  rw.Headers,
+			Username: rw.Username,
+			Password: rw.Password,
+			Labels:   rw.Labels,
+			Interval: rw.Interval,
+		})
+		go remoteWriter.Run(stopCtx)
+	}
+
+	store := servers.New(nil)
+	if opts.State != nil {
+		store.Restore(*opts.State)
+		logger.Info("restored state from the previous process")
+	} else if st := cfg.State; st != nil && st.Path != "" {
+		// A state file that cannot be read only costs the results until the first sync.
+		snap, err := statefile.Load(st.Path)
+		switch {
+		case err != nil:
+			logger.Warn("ignoring state file", zap.String("path", st.Path), zap.Error(err))
+		case snap != nil:
+			store.Restore(*snap)
+			logger.Info("restored state from the state file", zap.String("path", st.Path))
+		}
+	}
+	// stateWriterDone is closed once the state writer stopped, so a handoff can wait for its last write.
+	stateWriterDone := make(chan struct{})
+	if st := cfg.State; st != nil && st.Path != "" {
+		stateWriter = statefile.New(statefile.Options{
+			Logger: logger.With(zap.String("module", "statefile")),
+			Store:  store,
+			Path:   st.Path,
+		})
+		go func() {
+			defer close(stateWriterDone)
+			stateWriter.Run(stopCtx)
+		}()
+	} else {
+		close(stateWriterDone)
+	}
+	if f := cfg.Feed; f != nil && f.Path != "" {
+		feedOpts := feed.Options{
+			Logger: logger.With(zap.String("module", "feed")),
+			Store:  store,
+			Path:   f.Path,
+
+			InstanceName: cfg.InstanceName,
+		}
+		if f.Template != "" {
+			tmpl, err := feed.ParseTemplate(f.Template)
+			if err != nil {
+				return fmt.Errorf("feed template: %w", err)
+			}
+			feedOpts.Template = tmpl
+		}
+		feedWriter = feed.New(feedOpts)
+		go feedWriter.Run(stopCtx)
+	}
+	a2sHost := ""
+	modCheck, latency, requireUp := false, false, false
+	a2sClient := &a2s.Client{}
+	if cfg.A2S != nil {
+		a2sHost = cfg.A2S.Host
+		modCheck = cfg.A2S.ModCheck
+		latency = cfg.A2S.Latency
+		requireUp = cfg.A2S.RequireUp
+		a2sClient.Timeout = cfg.A2S.Timeout
+	}
+
+	var (
+		manager *worker.Manager
+		elector *leader.Elector
+		active  func() bool
+	)
+	if ha := cfg.HA; ha != nil && ha.Enabled {
+		id := ha.ID
+		if id == "" {
+			if id, err = os.Hostname(); err != nil {
+				return fmt.Errorf("ha.id is empty and the hostname is unavailable: %w", err)
+			}
+		}
+		if err := leader.CheckShared(ha.LeaseFile); err != nil {
+			return fmt.Errorf("ha.lease_file %s: %w", ha.LeaseFile, err)
+		}
+		elector = leader.New(leader.Options{
+			Logger: logger.With(zap.String("module", "leader")),
+			Lease:  leader.NewFileLease(ha.LeaseFile),
+			ID:     id,
+			TTL:    ha.LeaseTTL,
+			OnChange: func(isLeader bool) {
+				if isLeader {
+					manager.TriggerAll()
+				}
+			},
+		})
+		active = elector.IsLeader
+	}
+
+	var execHooks *exechook.Runner
+	if len(cfg.ExecHooks) > 0 || len(cfg.Webhooks) > 0 {
+		var redactFn func(string) string
+		if redactor != nil {
+			redactFn = redactor.String
+		}
+		var err error
+		execHooks, err = exechook.New(exechook.Options{
+			Logger:       logger.With(zap.String("module", "exechook")),
+			Hooks:        cfg.ExecHooks,
+			Webhooks:     cfg.Webhooks,
+			Client:       httpClient,
+			Redact:       redactFn,
+			InstanceName: cfg.InstanceName,
+			Active:       active,
+		})
+		if err != nil {
+			return fmt.Errorf("exec hooks: %w", err)
+		}
+	}
+
+	workerOpts := worker.Options{
+		Logger:          logger,
+		Client:          dzsaClient,
+		IFConfig:        ifconfigClient,
+		ExternalIP:      cfg.ExternalIP,
+		IFConfig6:       ifconfig6Client,
+		ExternalIPv6:    cfg.ExternalIPv6,
+		Hosts:           cfg.Hosts,
+		Resolver:        httpOpts.Resolver,
+		Store:           store,
+		PlayerCount:     playerCountRecorder,
+		Night:           nightRecorder,
+		History:         historySink,
+		A2S:             a2sClient,
+		A2SHost:         a2sHost,
+		ModCheck:        modCheck,
+		Latency:         latency,
+		RequireUp:       requireUp,
+		LatencyRecorder: latencyRecorder,
+		ModMismatch:     modCheckRecorder,
+		Interval:        cfg.SyncInterval,
+		JitterMax:       syncJitterMax,
+		Active:          active,
+		Retry:           retryRecorder,
+		SyncErrors:      syncErrorRecorder,
+		NextSync:        nextSyncRecorder,
+		DryRun:          cfg.Staging != nil && cfg.Staging.DryRun,
+		Exec:            execHooks,
+	}
+	if r := cfg.Retry; r != nil && r.Attempts > 0 {
+		workerOpts.Retries = r.Attempts
+		workerOpts.RetryBackoff = r.Backoff
+		workerOpts.RetryMaxBackoff = r.MaxBackoff
+		workerOpts.RetryBudget = retry.NewBudget(r.Budget)
+	}
+	if r := cfg.Retry; r != nil {
+		workerOpts.FailureSchedule = r.FailureSchedule
+	}
+	if l := cfg.ServerLogs; l != nil {
+		workerOpts.ServerLogs = serverlog.New(serverlog.Options{
+			Path:       l.Path,
+			MaxSizeMB:  l.MaxSizeMB,
+			MaxBackups: l.MaxBackups,
+			MaxAgeDays: l.MaxAgeDays,
+			Encoder:    LogEncoder(cfg.LogFormat),
+			Level:      LogLevel(cfg.LogLevel),
+			Wrap:       redactor.Core,
+		})
+	}
+	manager = worker.NewManager(workCtx, workerOpts)
+	// electorDone is closed once the lease is released on shutdown, so a follower can take over at once.
+	electorDone := make(chan struct{})
+	if elector != nil {
+		go func() {
+			defer close(electorDone)
+			elector.Run(stopCtx)
+		}()
+	} else {
+		close(electorDone)
+	}
+
+	apiOpts := api.Options{
+		Addr:           APIAddr(cfg.API),
+		MetricsHandler: metricsProvider.Handler(MetricsHandlerOptions(cfg.Metrics)),
+		Store:          store,
+		History:        historyReader,
+		Hooks:          cfg.Hooks,
+		Syncer:         manager,
+		InstanceName:   cfg.InstanceName,
+		SyncTarget:     syncTarget,
+		Started:        started,
+		Logger:         logger,
+		Draining:       manager.Draining,
+	}
+	if instanceIP {
+		apiOpts.Address = ifconfigClient.GetAddress
+	}
+	if cfg.DetectIPv6 || cfg.ExternalIPv6 != "" {
+		apiOpts.Address6 = ifconfig6Client.GetAddress
+	}
+	resyncAll := func(oldIP, newIP string) {
+		logger.Info("external IP changed, triggering sync for all servers",
+			zap.String("old_ip", oldIP),
+			zap.String("new_ip", newIP))
+		manager.TriggerAll()
+		execHooks.Fire(exechook.Event{Event: config.ExecEventIPChange, OldIP: oldIP, NewIP: newIP})
+	}
+	var damper *ifconfig.Damper
+	if f := cfg.IPFlap; cfg.DetectIP && (f == nil || !f.Disabled) {
+		damperOpts := ifconfig.DamperOptions{
+			Logger:   logger.With(zap.String("module", "ifconfig")),
+			OnChange: resyncAll,
+			Recorder: ipFlapRecorder,
+		}
+		if f != nil {
+			damperOpts.Window, damperOpts.Changes, damperOpts.HoldDown = f.Window, f.Changes, f.HoldDown
+		}
+		damper = ifconfig.NewDamper(damperOpts)
+		defer damper.Stop()
+		apiOpts.AddressFlapping = damper.Flapping
+	}
+	apiOpts.Backup = backup.New(backup.Options{Store: store, InstanceName: cfg.InstanceName, Address: apiOpts.Address, History: historyDB})
+	var configTracker *configdiff.Tracker
+	verifyServers := func(srvs []config.Server) {
+		verifyQueryPorts(stopCtx, logger, a2sClient, a2sHost, srvs)
+	}
+	applyConfigServers := func(srvs []config.Server) {
+		verifyServers(srvs)
+		manager.Reconcile(worker.SourceConfig, srvs)
+	}
+	// reload applies the server list of the config file; the other settings need a restart.
+	var reload func() (configdiff.Applied, error)
+	if opts.ConfigPath != "" {
+		configTracker = configdiff.New(opts.ConfigPath, cfg)
+		apiOpts.ConfigDiff = configTracker
+		reload = func() (configdiff.Applied, error) {
+			applied, err := configTracker.Reload(applyConfigServers)
+			if err != nil {
+				logger.Error("config reload rejected, keeping the running servers", zap.Error(err), errkind.Field(err))
+				return applied, err
+			}
+			logger.Info("config reloaded",
+				zap.Ints("added", applied.Added),
+				zap.Ints("removed", applied.Removed),
+				zap.Ints("changed", applied.Changed),
+				zap.Strings("restart_required", applied.RestartRequired))
+			return applied, nil
+		}
+		apiOpts.Reload = reload
+	}
+	persistServers := cfg.API != nil && cfg.API.PersistServers
+	if persistServers && configTracker == nil {
+		logger.Warn("api.persist_servers is set, but the config was not loaded from a file; servers added through the API last until restart")
+	}
+	apiOpts.Servers = &serverEditor{
+		manager: manager,
+		cfg:     cfg,
+		tracker: configTracker,
+		persist: persistServers,
+		verify:  verifyServers,
+		apply:   applyConfigServers,
+	}
+	if cfg.API != nil {
+		if apiOpts.TrustedProxies, err = api.ParseTrustedProxies(cfg.API.TrustedProxies); err != nil {
+			return fmt.Errorf("API server: %w", err)
+		}
+		apiOpts.UI = cfg.API.UI
+		apiOpts.ReadyRequiresSync = cfg.API.ReadyRequiresSync
+	}
+	if elector != nil {
+		apiOpts.Elector = elector
+	}
+	if redactor != nil {
+		apiOpts.Redact = redactor.String
+	}
+	// apiServers are shut down on return, and listeners are handed to the new process on a graceful restart.
+	var (
+		apiServers []*http.Server
+		listeners  []Listener
+	)
+	defer func() {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer shutdownCancel()
+		for _, srv := range apiServers {
+			_ = srv.Shutdown(shutdownCtx)
+		}
+	}()
+	// serve serves srv on the listener named name by opts.Listener, or on a new one from listen.
+	serve := func(name, desc string, srv *http.Server, listen func() (net.Listener, error), fields ...zap.Field) error {
+		var (
+			ln net.Listener
+			ok bool
+		)
+		if opts.Listener != nil {
+			ln, ok = opts.Listener(name)
+		}
+		if !ok {
+			var err error
+			if ln, err = listen(); err != nil {
+				return fmt.Errorf("%s: %w", desc, err)
+			}
+		}
+		listeners = append(listeners, Listener{Name: name, Listener: ln})
+		apiServers = append(apiServers, srv)
+		go func() {
+			logger.Info(desc+" listening", fields...)
+			if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+				logger.Error(desc, append(fields, zap.Error(err))...)
+				stop()
+			}
+		}()
+		return nil
+	}
+	listenTCP := func(addr string) func() (net.Listener, error) {
+		return func() (net.Listener, error) { return net.Listen("tcp", addr) }
+	}
+	listenSocket := func(path string) func() (net.Listener, error) {
+		return func() (net.Listener, error) { return listenUnix(path) }
+	}
+	if !opts.DisableAPI {
+		// The socket always serves the full API: file permissions protect it.
+		fullServer := api.NewServer(apiOpts)
+		apiServer := fullServer
+		if cfg.API != nil && cfg.API.Admin != nil {
+			publicOpts := apiOpts
+			publicOpts.Routes = api.RoutesReadOnly
+			apiServer = api.NewServer(publicOpts)
+			adminOpts := apiOpts
+			adminOpts.Addr = net.JoinHostPort(cfg.API.Admin.Host, strconv.Itoa(cfg.API.Admin.Port))
+			adminOpts.AdminToken = cfg.API.Admin.Token
+			adminServer := api.NewServer(adminOpts)
+			if err := serve(ListenerAdmin, "admin API server", adminServer, listenTCP(adminServer.Addr), zap.String("addr", adminServer.Addr)); err != nil {
+				return err
+			}
+		}
+		if err := serve(ListenerAPI, "API server", apiServer, listenTCP(apiServer.Addr), zap.String("addr", apiServer.Addr), zap.String("metrics", api.MetricsPath)); err != nil {
+			return err
+		}
+		if cfg.API != nil && cfg.API.Socket != "" {
+			if err := serve(ListenerSocket, "API socket", fullServer, listenSocket(cfg.API.Socket), zap.String("socket", cfg.API.Socket)); err != nil {
+				return err
+			}
+		}
+		var extra []config.ListenerConfig
+		if cfg.API != nil {
+			extra = cfg.API.Listeners
+		}
+		for i, l := range extra {
+			routes := l.Routes
+			if routes == "" {
+				routes = config.RoutesFull
+			}
+			listenerOpts := apiOpts
+			listenerOpts.Routes = api.Routes(routes)
+			listenerOpts.AdminToken = l.Token
+			srv := api.NewServer(listenerOpts)
+			listen, addr := listenSocket(l.Socket), l.Socket
+			if l.Socket == "" {
+				srv.Addr = net.JoinHostPort(l.Host, strconv.Itoa(l.Port))
+				listen, addr = listenTCP(srv.Addr), srv.Addr
+			}
+			name := fmt.Sprintf("%s%d", ListenerExtra, i)
+			if err := serve(name, "API listener", srv, listen, zap.Int("listener", i), zap.String("addr", addr), zap.String("routes", routes)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// dnsUpdater keeps dns.cloudflare pointed at the external IP; DNS updates are not damped.
+	var dnsUpdater *ddns.Updater
+	if d := cfg.DNS; d != nil && d.Cloudflare != nil && instanceIP {
+		cf := d.Cloudflare
+		dnsUpdater = ddns.New(ddns.Options{
+			Provider: ddns.NewCloudflare(httpClient, recorder, ddns.CloudflareOptions{
+				ZoneID: cf.ZoneID,
+				Record: cf.Record,
+				Token:  cf.APIToken,
+				TTL:    cf.TTL,
+			}),
+			Logger:  logger.With(zap.String("module", "ddns"), zap.String("record", cf.Record)),
+			Address: ifconfigClient.GetAddress,
+		})
+		go dnsUpdater.Run(stopCtx)
+	}
+
+	// IP changes are kept in memory for reports; history records no IPs.
+	ipLog := notify.NewIPLog()
+	onIPChanged := func(oldIP, newIP string) {
+		ipLog.Record(time.Now(), oldIP, newIP)
+		if dnsUpdater != nil {
+			dnsUpdater.Set(newIP)
+		}
+		if damper == nil {
+			resyncAll(oldIP, newIP)
+			return
+		}
+		damper.Changed(oldIP, newIP)
+	}
+
+	// IPv6 changes are not damped; they resync only the servers registered with the IPv6 address.
+	onIPv6Changed := func(oldIP, newIP string) {
+		ipLog.Record(time.Now(), oldIP, newIP)
+		logger.Info("external IPv6 address changed, triggering sync for ipv6 servers",
+			zap.String("old_ip", oldIP),
+			zap.String("new_ip", newIP))
+		for _, srv := range manager.Servers() {
+			if srv.IPv6() {
+				manager.Trigger(srv.Port)
+			}
+		}
+		execHooks.Fire(exechook.Event{Event: config.ExecEventIPChange, OldIP: oldIP, NewIP: newIP})
+	}
+
+	if cfg.DetectIP {
+		go ifconfigClient.Run(stopCtx, onIPChanged)
+	}
+	if cfg.DetectIPv6 {
+		go ifconfig6Client.Run(stopCtx, onIPv6Changed)
+	}
+	if cfg.DetectIP || cfg.DetectIPv6 {
+		// Give ifconfig one chance to populate IP before starting port workers
+		time.Sleep(2 * time.Second)
+	}
+
+	configServers := cfg.AllServers()
+	verifyQueryPorts(stopCtx, logger, a2sClient, a2sHost, configServers)
+	logger.Info("servers from config, starting sync workers",
+		zap.Int("count", len(configServers)),
+		zap.Int("hosts", len(cfg.Hosts)))
+	manager.Reconcile(worker.SourceConfig, configServers)
+
+	if d := cfg.Discovery; d != nil && d.Docker != nil && d.Docker.Enabled {
+		docker, err := discovery.NewDocker(d.Docker.Host)
+		if err != nil {
+			return fmt.Errorf("docker discovery: %w", err)
+		}
+		go discovery.Run(stopCtx, logger.With(zap.String("module", "discovery")), docker, d.Docker.Interval, manager.Reconcile)
+	}
+	if d := cfg.Discovery; d != nil && d.Systemd != nil && d.Systemd.Enabled {
+		systemd := discovery.NewSystemd(d.Systemd.Pattern)
+		go discovery.Run(stopCtx, logger.With(zap.String("module", "discovery")), systemd, d.Systemd.Interval, manager.Reconcile)
+	}
+	if d := cfg.Discovery; d != nil && d.ServerDZ != nil && d.ServerDZ.Enabled {
+		serverDZ := discovery.NewServerDZ(d.ServerDZ.Paths, d.ServerDZ.Processes)
+		go discovery.Run(stopCtx, logger.With(zap.String("module", "discovery")), serverDZ, d.ServerDZ.Interval, manager.Reconcile)
+	}
+	if d := cfg.Discovery; d != nil && d.Remote != nil && d.Remote.Enabled {
+		remote := discovery.NewRemote(d.Remote.URL, d.Remote.Headers, httpClient)
+		go discovery.Run(stopCtx, logger.With(zap.String("module", "discovery")), remote, d.Remote.Interval, manager.Reconcile)
+	}
+
+	if mc := cfg.MasterCheck; mc != nil && mc.Enabled {
+		checker := &steam.Checker{
+			Client:   steam.New(httpClient, recorder),
+			Logger:   logger.With(zap.String("module", "steam")),
+			Store:    store,
+			Recorder: upstreamRecorder,
+			Interval: mc.Interval,
+			Address:  manager.Address,
+			Servers:  manager.Servers,
+		}
+		go checker.Run(stopCtx)
+	}
+	if wc := cfg.WorkshopCheck; wc != nil && wc.Enabled {
+		checker := &steam.WorkshopChecker{
+			Client:   steam.New(httpClient, recorder),
+			Logger:   logger.With(zap.String("module", "steam")),
+			Store:    store,
+			Recorder: workshopRecorder,
+			Interval: wc.Interval,
+			Servers:  manager.Servers,
+		}
+		go checker.Run(stopCtx)
+	}
+	notifiers := make(map[string]notify.Notifier)
+	for _, n := range cfg.Notifiers {
+		notifiers[n.Name] = newNotifier(n, httpClient, redactor)
+	}
+	maps.Copy(notifiers, opts.Notifiers)
+	if len(cfg.Rules) > 0 {
+		notifyOpts := notify.Options{
+			Logger:       logger.With(zap.String("module", "notify")),
+			Store:        store,
+			Servers:      notifyServers(manager),
+			Notifiers:    notifiers,
+			InstanceName: cfg.InstanceName,
+			Active:       active,
+			Recorder:     notificationRecorder,
+			History:      historyReader,
+		}
+		for _, r := range cfg.Rules {
+			rule, err := notifyRule(r)
+			if err != nil {
+				return fmt.Errorf("rule %s: %w", r.Name, err)
+			}
+			notifyOpts.Rules = append(notifyOpts.Rules, rule)
+		}
+		go notify.New(notifyOpts).Run(stopCtx)
+	}
+	for _, r := range cfg.Reports {
+		schedule, err := notify.ParseSchedule(r.Schedule, r.At, r.Day, r.Timezone)
+		if err != nil {
+			return fmt.Errorf("report %s: %w", r.Name, err)
+		}
+		reportOpts := notify.ReportOptions{
+			Logger:       logger.With(zap.String("module", "report")),
+			Name:         r.Name,
+			Schedule:     schedule,
+			History:      historyReader,
+			Servers:      notifyServers(manager),
+			Filter:       r.Servers,
+			IPLog:        ipLog,
+			Notifiers:    make(map[string]notify.Notifier),
+			InstanceName: cfg.InstanceName,
+			Active:       active,
+			Recorder:     notificationRecorder,
+		}
+		for _, name := range r.Notify {
+			reportOpts.Notifiers[name] = notifiers[name]
+		}
+		go notify.NewReportRunner(reportOpts).Run(stopCtx)
+	}
+
+	if opts.Ready != nil {
+		opts.Ready()
+	}
+
+	handedOff := false
+wait:
+	for {
+		select {
+		case <-stopCtx.Done():
+			logger.Info("shutdown signal received, stopping workers")
+			break wait
+		case <-opts.Reload:
+			if reload == nil {
+				logger.Warn("config reload requested, but the config was not loaded from a file; restart to change it")
+				continue
+			}
+			_, _ = reload()
+		case <-opts.Restart:
+			if opts.Handoff == nil {
+				continue
+			}
+			logger.Info("graceful restart requested, starting new process")
+			manager.Pause()
+			pid, err := opts.Handoff(listeners, store.Snapshot())
+			if err != nil {
+				logger.Error("graceful restart failed, continuing with this process", zap.Error(err))
+				if configTracker != nil {
+					configTracker.RecordReload(err)
+				}
+				manager.Resume()
+				continue
+			}
+			if elector != nil {
+				elector.KeepLease()
+			}
+			// The new process owns the state file, the DNS records, and discovery from now on, so the loops
+			// that write them stop now instead of once the API connections drained.
+			stop()
+			<-stateWriterDone
+			for _, l := range listeners {
+				if ul, ok := l.Listener.(*net.UnixListener); ok {
+					// The socket file now belongs to the new process.
+					ul.SetUnlinkOnClose(false)
+				}
+			}
+			logger.Info("new process is ready, stopping workers", zap.Int("pid", pid))
+			handedOff = true
+			break wait
+		}
+	}
+	shutdownTimeout := cfg.ShutdownTimeout
+	if shutdownTimeout == 0 {
+		shutdownTimeout = config.DefaultShutdownTimeout
+	}
+	if !manager.Drain(shutdownTimeout) {
+		logger.Warn("syncs still running at the shutdown timeout were cancelled", zap.Duration("shutdown_timeout", shutdownTimeout))
+	}
+	// Hooks of the last syncs run to completion, bounded by their own timeouts.
+	execHooks.Wait()
+	// The new process owns the feed and the metrics after a handoff.
+	if !handedOff {
+		flush(logger, feedWriter, stateWriter, remoteWriter)
+	}
+	cancel()
+	<-electorDone
+	logger.Info("shutdown complete")
+	return nil
+}
+
+// flush writes the results of the syncs drained on shutdown to the feed, the state file, and the remote_write
+// endpoint, whose loops already stopped. Any may be nil.
+func flush(logger *zap.Logger, feedWriter *feed.Writer, stateWriter *statefile.Writer, remoteWriter *remotewrite.Writer) {
+	if feedWriter != nil {
+		if err := feedWriter.Write(); err != nil {
+			logger.Error("write feed on shutdown", zap.Error(err))
+		}
+	}
+	if stateWriter != nil {
+		if err := stateWriter.Write(); err != nil {
+			logger.Error("write state file on shutdown", zap.Error(err))
+		}
+	}
+	if remoteWriter != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := remoteWriter.Push(ctx); err != nil {
+			logger.Error("remote write on shutdown", zap.Error(err))
+		}
+	}
+}
+
+// MetricsHandlerOptions returns the /metrics exposition options from the metrics config section, which may be nil.
+func MetricsHandlerOptions(m *config.MetricsConfig) metrics.HandlerOptions {
+	if m == nil {
+		return metrics.HandlerOptions{}
+	}
+	return metrics.HandlerOptions{OpenMetrics: m.OpenMetrics, CreatedSamples: m.CreatedTimestamps}
+}
+
+// OTLPOptions returns the OTLP exporter options from the metrics config section, which may be nil, or nil when
+// the exporter is not enabled.
+func OTLPOptions(m *config.MetricsConfig) *metrics.OTLPOptions {
+	if m == nil || m.OTLP == nil || !m.OTLP.Enabled {
+		return nil
+	}
+	return &metrics.OTLPOptions{
+		Protocol: m.OTLP.Protocol,
+		Endpoint: m.OTLP.Endpoint,
+		Headers:  m.OTLP.Headers,
+		Interval: m.OTLP.Interval,
+	}
+}
+
+// APIAddr returns the API listen address from the api config section, which may be nil.
+func APIAddr(a *config.APIConfig) string {
+	host, port := "", DefaultAPIPort
+	if a != nil {
+		host = a.Host
+		if a.Port != 0 {
+			port = a.Port
+		}
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// listenUnix listens on a unix socket at path, replacing a stale socket left by an unclean exit.
+// The socket is group-accessible so operators in the service group can use the CLI.
+func listenUnix(path string) (net.Listener, error) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("remove stale socket: %w", err)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listen %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o660); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("chmod %s: %w", path, err)
+	}
+	return ln, nil
+}
+
+// historyRetention returns the retention of a history store, with defaultRaw for a zero raw retention.
+func historyRetention(raw, defaultRaw, hourly time.Duration) history.Retention {
+	if raw == 0 {
+		raw = defaultRaw
+	}
+	if hourly == 0 {
+		hourly = history.DefaultHourlyRetention
+	}
+	return history.Retention{Raw: raw, Hourly: hourly}
+}
+
+// verifyQueryPorts checks that each server on this machine with query_port: auto answers A2S at its
+// derived port. When another candidate answers for the game port instead, the server uses that port. Servers
+// under hosts and monitor-only servers are not checked, since their query ports may not be reachable from here.
+func verifyQueryPorts(ctx context.Context, logger *zap.Logger, c *a2s.Client, host string, srvs []config.Server) {
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	for i, s := range srvs {
+		if s.QueryPort != config.QueryPortAuto || s.Host != "" || s.MonitorOnly {
+			continue
+		}
+		logger := logger.With(zap.String("server", s.Name), zap.Int("game_port", s.GamePort), zap.Int("port", s.Port))
+		port, err := c.FindQueryPort(ctx, host, s.GamePort, config.QueryPortCandidates(s.GamePort))
+		switch {
+		case err != nil:
+			logger.Warn("could not verify the derived query port over A2S; using it anyway", zap.Error(err))
+		case port == s.Port:
+			logger.Info("verified derived query port")
+		case slices.ContainsFunc(srvs, func(o config.Server) bool { return o.Port == port }):
+			logger.Warn("server answers on a query port another server uses; using the derived port", zap.Int("answered", port))
+		default:
+			logger.Warn("server answers on a query port other than the derived one; using it", zap.Int("answered", port))
+			srvs[i].Port = port
+		}
+	}
+}
+
+// newNotifier returns the notifier of a notifiers entry, which config.Validate has checked.
+func newNotifier(n config.Notifier, client *http.Client, redactor *redact.Redactor) notify.Notifier {
+	var redactFn func(string) string
+	if redactor != nil {
+		redactFn = redactor.String
+	}
+	if n.Type == notify.TypeEmail {
+		port := n.SMTP.Port
+		if port == 0 {
+			port = config.DefaultSMTPPort
+		}
+		return notify.NewEmail(notify.EmailOptions{
+			Host:     n.SMTP.Host,
+			Port:     port,
+			Username: n.SMTP.Username,
+			Password: n.SMTP.Password,
+			From:     n.SMTP.From,
+			To:       n.SMTP.To,
+			Redact:   redactFn,
+		})
+	}
+	return notify.NewHTTP(notify.HTTPOptions{Client: client, Type: n.Type, URL: n.URL, Headers: n.Headers, Redact: redactFn})
+}
+
+// notifyRule parses a rules entry, which config.Validate has checked.
+func notifyRule(r config.Rule) (notify.Rule, error) {
+	cond, err := notify.ParseCondition(r.When)
+	if err != nil {
+		return notify.Rule{}, err
+	}
+	window, err := notify.ParseWindow(r.During, r.Days, r.Timezone)
+	if err != nil {
+		return notify.Rule{}, err
+	}
+	return notify.Rule{
+		Name:      r.Name,
+		Condition: cond,
+		For:       r.For,
+		Servers:   r.Servers,
+		Window:    window,
+		Notify:    r.Notify,
+		Cooldown:  r.Cooldown,
+	}, nil
+}
+
+// notifyServers returns the managed servers of m as the rules engine sees them.
+func notifyServers(m *worker.Manager) func() []notify.Server {
+	return func() []notify.Server {
+		var out []notify.Server
+		for _, s := range m.Servers() {
+			out = append(out, notify.Server{Name: s.Name, Port: s.Port})
+		}
+		return out
+	}
+}
+
+// NewRedactor returns the IP redactor for the privacy config section, or nil when redaction is off.
+func NewRedactor(p *config.PrivacyConfig) (*redact.Redactor, error) {
+	if p == nil || p.RedactIPs == "" {
+		return nil, nil
+	}
+	return redact.New(redact.Options{Mode: p.RedactIPs, Key: p.HashKey, RedactServerIP: p.RedactServerIP})
+}
+
+// HTTPOptions converts the http config section to client options. A nil section uses the defaults,
+// including the DNS cache.
+func HTTPOptions(h *config.HTTPConfig, recorder metrics.DNSRecorder) (httpclient.Options, error) {
+	if h == nil {
+		return httpclient.Options{Resolver: dnscache.New(dnscache.Options{Recorder: recorder})}, nil
+	}
+	opts := httpclient.Options{
+		Timeout:             h.Timeout,
+		DialTimeout:         h.DialTimeout,
+		MaxIdleConns:        h.MaxIdleConns,
+		MaxIdleConnsPerHost: h.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     h.MaxConnsPerHost,
+		IdleConnTimeout:     h.IdleConnTimeout,
+		TLSSessionCacheSize: h.TLSSessionCacheSize,
+		DisableHTTP2:        h.DisableHTTP2,
+	}
+	if h.CAFile != "" {
+		pool, err := httpclient.LoadRootCAs(h.CAFile)
+		if err != nil {
+			return httpclient.Options{}, err
+		}
+		opts.RootCAs = pool
+	}
+	if !h.DisableDNSCache {
+		resolverOpts := dnscache.Options{
+			MinTTL:      h.DNSMinTTL,
+			MaxTTL:      h.DNSMaxTTL,
+			NegativeTTL: h.DNSNegativeTTL,
+			Recorder:    recorder,
+		}
+		if len(h.DNSServers) > 0 {
+			servers, err := h.Nameservers()
+			if err != nil {
+				return httpclient.Options{}, err
+			}
+			resolverOpts.Servers = servers
+		}
+		if h.DNSOverHTTPS != "" {
+			// The endpoint itself is resolved with the system resolver.
+			resolverOpts.DoH = h.DNSOverHTTPS
+			resolverOpts.DoHClient = httpclient.New(httpclient.Options{RootCAs: opts.RootCAs})
+		}
+		opts.Resolver = dnscache.New(resolverOpts)
+	}
+	if len(h.DZSAPins) > 0 {
+		opts.Pins = map[string][]string{client.Host: h.DZSAPins}
+	}
+	return opts, nil
+}
+
+// LogEncoder returns the encoder of the daemon's log lines for log_format, shared by the main log and server
+// logs. Empty is JSON.
+func LogEncoder(format string) zapcore.Encoder {
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.CallerKey = ""
+	encoderConfig.StacktraceKey = ""
+	encoderConfig.TimeKey = "timestamp"
+	encoderConfig.MessageKey = "message"
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	if format == config.LogFormatConsole {
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		return zapcore.NewConsoleEncoder(encoderConfig)
+	}
+	return zapcore.NewJSONEncoder(encoderConfig)
+}
+
+// LogLevel returns the minimum level of log_level, which config validation checked. Empty is debug.
+func LogLevel(level string) zapcore.Level {
+	if level == "" {
+		return zapcore.DebugLevel
+	}
+	l, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return zapcore.DebugLevel
+	}
+	return l
+}
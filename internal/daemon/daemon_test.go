@@ -0,0 +1,120 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+	"github.com/jsirianni/dzsa-sync/model"
+)
+
+type fakeClient struct{}
+
+func (fakeClient) Query(context.Context, string, int) (*model.QueryResponse, error) {
+	return &model.QueryResponse{}, nil
+}
+
+// TestRun_HandoffStopsLoops checks that once a graceful restart handed off, the old process stops writing the
+// state file, although its API still drains a connection and discovery would change its servers.
+func TestRun_HandoffStopsLoops(t *testing.T) {
+	var handedOff atomic.Bool
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		// The server goes away after the handoff, which would remove it from the old process's store.
+		if handedOff.Load() {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"name":"main","port":2424}]`))
+	}))
+	defer remote.Close()
+
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	cfg := &config.Config{
+		ExternalIP: "203.0.113.10",
+		State:      &config.StateConfig{Path: statePath},
+		Discovery: &config.DiscoveryConfig{
+			Remote: &config.RemoteDiscoveryConfig{Enabled: true, URL: remote.URL, Interval: 20 * time.Millisecond},
+		},
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ready := make(chan struct{})
+	restart := make(chan os.Signal, 1)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- Run(context.Background(), cfg, Options{
+			Client:   fakeClient{},
+			Listener: func(name string) (net.Listener, bool) { return ln, name == ListenerAPI },
+			Ready:    func() { close(ready) },
+			Restart:  restart,
+			Handoff: func([]Listener, servers.Snapshot) (int, error) {
+				// The new process takes over the state file.
+				if err := os.Remove(statePath); err != nil {
+					return 0, err
+				}
+				handedOff.Store(true)
+				return 4242, nil
+			},
+		})
+	}()
+	<-ready
+
+	// Discovery added the server, which the state writer wrote.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(statePath); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("state file was not written")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A request that has started keeps the API server, and so Run, shutting down until it is closed.
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("GET /healthz HTTP/1.1\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	restart <- os.Interrupt
+	// Give discovery many polls to see the server gone, were it still running.
+	time.Sleep(300 * time.Millisecond)
+	select {
+	case err := <-errc:
+		t.Fatalf("Run returned before the API drained: %v", err)
+	default:
+	}
+	if _, err := os.Stat(statePath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the old process wrote the state file after the handoff: %v", err)
+	}
+
+	conn.Close()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not return after the handoff")
+	}
+	if _, err := os.Stat(statePath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the old process wrote the state file on shutdown: %v", err)
+	}
+}
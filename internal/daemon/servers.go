@@ -0,0 +1,101 @@
+package daemon
+
+import (
+	"fmt"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/api"
+	"github.com/jsirianni/dzsa-sync/internal/configdiff"
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+	"github.com/jsirianni/dzsa-sync/internal/worker"
+)
+
+// serverEditor serves POST and DELETE /api/v1/servers. Without persist, added servers are owned by
+// worker.SourceAPI and last until the next restart. With persist, edits are written to the config file and
+// applied by reloading it, so the servers are owned by worker.SourceConfig like the ones listed there.
+type serverEditor struct {
+	manager *worker.Manager
+	cfg     *config.Config
+	// tracker is nil when the config was not loaded from a file.
+	tracker *configdiff.Tracker
+	persist bool
+	// verify checks the query port of servers with query_port: auto, see verifyQueryPorts.
+	verify func([]config.Server)
+	// apply applies the server list of the config file, like a reload.
+	apply func([]config.Server)
+}
+
+var _ api.ServerEditor = (*serverEditor)(nil)
+
+// AddServer implements api.ServerEditor.
+func (e *serverEditor) AddServer(srv config.Server) (config.Server, error) {
+	// Validate srv as the only server of the running config, which also derives its port.
+	candidate := *e.cfg
+	candidate.Servers = []config.Server{srv}
+	candidate.Hosts = nil
+	if err := candidate.Validate(); err != nil {
+		return config.Server{}, errkind.Wrap(errkind.Validation, err)
+	}
+	added := candidate.Servers[0]
+	if source, ok := e.manager.Source(added.Port); ok {
+		return config.Server{}, fmt.Errorf("%w: port %d already managed by %s", api.ErrServerConflict, added.Port, source)
+	}
+
+	if e.Persistent() {
+		_, err := e.tracker.EditServers(func(b []byte) ([]byte, error) {
+			return config.AppendServer(b, srv)
+		}, e.apply)
+		if err != nil {
+			return config.Server{}, err
+		}
+		return added, nil
+	}
+	srvs := []config.Server{added}
+	e.verify(srvs)
+	if err := e.manager.Add(worker.SourceAPI, srvs[0]); err != nil {
+		return config.Server{}, fmt.Errorf("%w: %w", api.ErrServerConflict, err)
+	}
+	return srvs[0], nil
+}
+
+// RemoveServer implements api.ServerEditor. Servers of the config file are removed from it with persist, and
+// otherwise only until the next reload or restart. Discovered servers cannot be removed.
+func (e *serverEditor) RemoveServer(port int) (config.Server, error) {
+	source, ok := e.manager.Source(port)
+	if !ok {
+		return config.Server{}, fmt.Errorf("%w: port %d", api.ErrServerNotFound, port)
+	}
+	var removed config.Server
+	for _, s := range e.manager.Servers() {
+		if s.Port == port {
+			removed = s
+		}
+	}
+
+	switch {
+	case source == worker.SourceAPI, source == worker.SourceConfig && !e.Persistent():
+		if !e.manager.Remove(port) {
+			return config.Server{}, fmt.Errorf("%w: port %d", api.ErrServerNotFound, port)
+		}
+		return removed, nil
+	case source == worker.SourceConfig:
+		_, err := e.tracker.EditServers(func(b []byte) ([]byte, error) {
+			out, found, err := config.DeleteServer(b, port)
+			if err == nil && !found {
+				err = fmt.Errorf("%w: port %d is not listed in the config file", api.ErrServerConflict, port)
+			}
+			return out, err
+		}, e.apply)
+		if err != nil {
+			return config.Server{}, err
+		}
+		return removed, nil
+	default:
+		return config.Server{}, fmt.Errorf("%w: port %d is managed by %s discovery", api.ErrServerConflict, port, source)
+	}
+}
+
+// Persistent implements api.ServerEditor.
+func (e *serverEditor) Persistent() bool {
+	return e.persist && e.tracker != nil
+}
@@ -0,0 +1,161 @@
+package ddns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+	"github.com/jsirianni/dzsa-sync/internal/metrics"
+)
+
+// CloudflareURL is the Cloudflare API v4 base URL.
+const CloudflareURL = "https://api.cloudflare.com/client/v4"
+
+// cloudflareHost is the host label of Cloudflare requests in the request metrics.
+const cloudflareHost = "cloudflare"
+
+// CloudflareOptions configures a Cloudflare record.
+type CloudflareOptions struct {
+	// ZoneID is the ID of the zone the record is in.
+	ZoneID string
+	// Record is the record's fully qualified name, e.g. play.example.com.
+	Record string
+	// Token is an API token with DNS edit permission for the zone.
+	Token string
+	// TTL is set when the record is created or updated: 1 is automatic. Zero is 1.
+	TTL int
+	// BaseURL overrides CloudflareURL when set (e.g. for tests).
+	BaseURL string
+}
+
+// Cloudflare keeps an A record of a Cloudflare zone pointed at the IP. The record is not proxied, since
+// Cloudflare does not proxy game traffic.
+type Cloudflare struct {
+	client   *http.Client
+	recorder metrics.HTTPRecorder
+	opts     CloudflareOptions
+}
+
+var _ Provider = (*Cloudflare)(nil)
+
+// NewCloudflare returns a Cloudflare provider. httpClient may be nil to use a default client.
+func NewCloudflare(httpClient *http.Client, recorder metrics.HTTPRecorder, opts CloudflareOptions) *Cloudflare {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	if opts.BaseURL == "" {
+		opts.BaseURL = CloudflareURL
+	}
+	if opts.TTL == 0 {
+		opts.TTL = 1
+	}
+	return &Cloudflare{client: httpClient, recorder: recorder, opts: opts}
+}
+
+// Name implements Provider.
+func (c *Cloudflare) Name() string { return "cloudflare" }
+
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+	Proxied bool   `json:"proxied"`
+}
+
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+// Update implements Provider: it creates the record when the zone has none, and otherwise updates it unless
+// it already points at ip.
+func (c *Cloudflare) Update(ctx context.Context, ip string) (bool, error) {
+	q := url.Values{"type": {"A"}, "name": {c.opts.Record}}
+	var existing []cloudflareRecord
+	if err := c.do(ctx, http.MethodGet, "/dns_records?"+q.Encode(), nil, &existing); err != nil {
+		return false, fmt.Errorf("look up record %s: %w", c.opts.Record, err)
+	}
+	want := cloudflareRecord{Type: "A", Name: c.opts.Record, Content: ip, TTL: c.opts.TTL}
+	if len(existing) == 0 {
+		if err := c.do(ctx, http.MethodPost, "/dns_records", want, nil); err != nil {
+			return false, fmt.Errorf("create record %s: %w", c.opts.Record, err)
+		}
+		return true, nil
+	}
+	if existing[0].Content == ip {
+		return false, nil
+	}
+	if err := c.do(ctx, http.MethodPatch, "/dns_records/"+url.PathEscape(existing[0].ID), want, nil); err != nil {
+		return false, fmt.Errorf("update record %s: %w", c.opts.Record, err)
+	}
+	return true, nil
+}
+
+// do sends a request to the zone's path with body as JSON, and decodes the result into out when set.
+func (c *Cloudflare) do(ctx context.Context, method, path string, body, out any) error {
+	start := time.Now()
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	endpoint := strings.TrimSuffix(c.opts.BaseURL, "/") + "/zones/" + url.PathEscape(c.opts.ZoneID) + path
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.opts.Token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.record(ctx, 0, metrics.ClassifyError(err, 0), start)
+		return fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+	var cr cloudflareResponse
+	if err := json.NewDecoder(resp.Body).Decode(&cr); err != nil {
+		c.record(ctx, resp.StatusCode, metrics.ErrorDecode, start)
+		return errkind.Errorf(errkind.Upstream, "decode response (status %d): %w", resp.StatusCode, err)
+	}
+	c.record(ctx, resp.StatusCode, metrics.ClassifyError(nil, resp.StatusCode), start)
+	if !cr.Success || resp.StatusCode >= 300 {
+		msgs := make([]string, 0, len(cr.Errors))
+		for _, e := range cr.Errors {
+			msgs = append(msgs, fmt.Sprintf("%d %s", e.Code, e.Message))
+		}
+		return errkind.Errorf(errkind.Upstream, "cloudflare api error (status %d): %s", resp.StatusCode, strings.Join(msgs, "; "))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(cr.Result, out); err != nil {
+		return fmt.Errorf("decode result: %w", err)
+	}
+	return nil
+}
+
+func (c *Cloudflare) record(ctx context.Context, statusCode int, errType string, start time.Time) {
+	if c.recorder != nil {
+		c.recorder.RecordRequest(ctx, cloudflareHost, statusCode, errType, time.Since(start))
+	}
+}
@@ -0,0 +1,137 @@
+// Package ddns keeps a DNS record pointed at the external IP, so players who connect by hostname reach the
+// servers after the IP changes.
+package ddns
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+	"go.uber.org/zap"
+)
+
+// Defaults used when the matching option is zero.
+const (
+	DefaultRetryInterval = time.Minute
+	DefaultTimeout       = 30 * time.Second
+)
+
+// Provider points a DNS record at an IP.
+type Provider interface {
+	// Name identifies the provider in logs.
+	Name() string
+	// Update points the record at ip and reports whether it had to change.
+	Update(ctx context.Context, ip string) (bool, error)
+}
+
+// Options configures an Updater.
+type Options struct {
+	Provider Provider
+	// Logger logs updates. Nil disables logging.
+	Logger *zap.Logger
+	// Address returns the current external IP when set. It is read on start and every RetryInterval, so an IP
+	// first detected after start is applied without a call of Set.
+	Address func() string
+	// RetryInterval is the time before a failed update is tried again. Zero uses DefaultRetryInterval.
+	RetryInterval time.Duration
+	// Timeout bounds each update. Zero uses DefaultTimeout.
+	Timeout time.Duration
+}
+
+// Updater applies the latest IP passed to Set, or read from Options.Address, to the provider's record from its
+// own goroutine, so a slow or failing DNS API does not hold up IP detection. A failed update is retried until
+// it succeeds or a newer IP replaces it. Safe for concurrent use.
+type Updater struct {
+	opts Options
+
+	mu sync.Mutex
+	// requested is the IP the record should point at, and applied the last one it was pointed at.
+	requested, applied string
+	changed            chan struct{}
+}
+
+// New returns an updater. Run applies the requested IPs.
+func New(opts Options) *Updater {
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = DefaultRetryInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	return &Updater{opts: opts, changed: make(chan struct{}, 1)}
+}
+
+// Set requests the record to point at ip. An empty ip is ignored.
+func (u *Updater) Set(ip string) {
+	if u.want(ip) {
+		select {
+		case u.changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// want records ip as the requested IP and reports whether it differs from the one requested before.
+func (u *Updater) want(ip string) bool {
+	if ip == "" {
+		return false
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	changed := u.requested != ip
+	u.requested = ip
+	return changed
+}
+
+// Run applies requested IPs until ctx is cancelled.
+func (u *Updater) Run(ctx context.Context) {
+	ticker := time.NewTicker(u.opts.RetryInterval)
+	defer ticker.Stop()
+	for {
+		if u.opts.Address != nil {
+			u.want(u.opts.Address())
+		}
+		if err := u.apply(ctx); err != nil && ctx.Err() == nil {
+			u.opts.Logger.Error("dns record update failed, retrying",
+				zap.String("provider", u.opts.Provider.Name()),
+				zap.Duration("retry_in", u.opts.RetryInterval),
+				zap.Error(err),
+				errkind.Field(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-u.changed:
+		case <-ticker.C:
+		}
+	}
+}
+
+// apply points the record at the requested IP unless it was already pointed there.
+func (u *Updater) apply(ctx context.Context) error {
+	u.mu.Lock()
+	ip, applied := u.requested, u.applied
+	u.mu.Unlock()
+	if ip == "" || ip == applied {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, u.opts.Timeout)
+	defer cancel()
+	changed, err := u.opts.Provider.Update(ctx, ip)
+	if err != nil {
+		return err
+	}
+	u.mu.Lock()
+	u.applied = ip
+	u.mu.Unlock()
+	if changed {
+		u.opts.Logger.Info("dns record updated", zap.String("provider", u.opts.Provider.Name()), zap.String("ip", ip))
+	} else {
+		u.opts.Logger.Debug("dns record already up to date", zap.String("provider", u.opts.Provider.Name()), zap.String("ip", ip))
+	}
+	return nil
+}
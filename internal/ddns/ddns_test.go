@@ -0,0 +1,172 @@
+package ddns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeZone is a Cloudflare zone with at most one A record.
+type fakeZone struct {
+	mu       sync.Mutex
+	record   *cloudflareRecord
+	requests []string
+	fail     bool
+}
+
+func (z *fakeZone) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.requests = append(z.requests, r.Method)
+	if r.Header.Get("Authorization") != "Bearer secret" || z.fail {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"success":false,"errors":[{"code":10000,"message":"Authentication error"}]}`))
+		return
+	}
+	var result any
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/zones/zone1/dns_records":
+		records := []cloudflareRecord{}
+		if z.record != nil && r.URL.Query().Get("name") == z.record.Name {
+			records = append(records, *z.record)
+		}
+		result = records
+	case r.Method == http.MethodPost && r.URL.Path == "/zones/zone1/dns_records",
+		r.Method == http.MethodPatch && z.record != nil && r.URL.Path == "/zones/zone1/dns_records/"+z.record.ID:
+		var rec cloudflareRecord
+		_ = json.NewDecoder(r.Body).Decode(&rec)
+		rec.ID = "rec1"
+		z.record = &rec
+		result = rec
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"success": true, "result": result})
+}
+
+func TestCloudflare_Update(t *testing.T) {
+	zone := &fakeZone{}
+	ts := httptest.NewServer(zone)
+	defer ts.Close()
+	cf := NewCloudflare(ts.Client(), nil, CloudflareOptions{ZoneID: "zone1", Record: "play.example.com", Token: "secret", BaseURL: ts.URL})
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		ip          string
+		wantChanged bool
+	}{
+		{"203.0.113.10", true},  // created
+		{"203.0.113.10", false}, // already pointed there
+		{"203.0.113.20", true},  // updated
+	} {
+		changed, err := cf.Update(ctx, tt.ip)
+		if err != nil {
+			t.Fatalf("Update(%s) error = %v", tt.ip, err)
+		}
+		if changed != tt.wantChanged {
+			t.Errorf("Update(%s) changed = %v, want %v", tt.ip, changed, tt.wantChanged)
+		}
+		if zone.record == nil || zone.record.Content != tt.ip || zone.record.Type != "A" || zone.record.Proxied || zone.record.TTL != 1 {
+			t.Errorf("record after Update(%s) = %+v", tt.ip, zone.record)
+		}
+	}
+
+	zone.fail = true
+	if _, err := cf.Update(ctx, "203.0.113.30"); err == nil {
+		t.Error("Update() with a rejected token: error = nil")
+	}
+}
+
+// fakeProvider fails the first failures updates.
+type fakeProvider struct {
+	mu       sync.Mutex
+	failures int
+	ips      []string
+}
+
+func (p *fakeProvider) Name() string { return "fake" }
+
+func (p *fakeProvider) Update(_ context.Context, ip string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ips = append(p.ips, ip)
+	if p.failures > 0 {
+		p.failures--
+		return false, errors.New("unavailable")
+	}
+	return true, nil
+}
+
+func (p *fakeProvider) calls() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.ips...)
+}
+
+func TestUpdater(t *testing.T) {
+	p := &fakeProvider{failures: 1}
+	u := New(Options{Provider: p, RetryInterval: 10 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go u.Run(ctx)
+
+	// The first update fails and is retried.
+	u.Set("203.0.113.10")
+	waitFor(t, func() bool { return len(p.calls()) == 2 })
+	// An IP that was already applied is not sent again.
+	u.Set("203.0.113.10")
+	u.Set("203.0.113.20")
+	waitFor(t, func() bool { return len(p.calls()) == 3 })
+	time.Sleep(50 * time.Millisecond)
+	if got := p.calls(); len(got) != 3 || got[2] != "203.0.113.20" {
+		t.Errorf("updates = %v, want the retry and then 203.0.113.20 once", got)
+	}
+}
+
+func TestUpdater_Address(t *testing.T) {
+	p := &fakeProvider{}
+	var (
+		mu sync.Mutex
+		ip string
+	)
+	u := New(Options{
+		Provider:      p,
+		RetryInterval: 10 * time.Millisecond,
+		Address: func() string {
+			mu.Lock()
+			defer mu.Unlock()
+			return ip
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go u.Run(ctx)
+
+	// An IP detected after start is applied without Set.
+	time.Sleep(30 * time.Millisecond)
+	mu.Lock()
+	ip = "203.0.113.10"
+	mu.Unlock()
+	waitFor(t, func() bool { return len(p.calls()) == 1 })
+	time.Sleep(30 * time.Millisecond)
+	if got := p.calls(); len(got) != 1 || got[0] != "203.0.113.10" {
+		t.Errorf("updates = %v, want 203.0.113.10 once", got)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
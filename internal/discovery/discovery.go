@@ -0,0 +1,56 @@
+// Package discovery finds DayZ servers from external systems so workers can be managed without editing the config.
+package discovery
+
+import (
+	"context"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"go.uber.org/zap"
+)
+
+// DefaultInterval is the default time between discovery polls.
+const DefaultInterval = 30 * time.Second
+
+// Source discovers servers from an external system.
+type Source interface {
+	// Name returns the source name used to own discovered workers (e.g. "docker").
+	Name() string
+	// Discover returns the servers currently present in the external system.
+	Discover(ctx context.Context) ([]config.Server, error)
+}
+
+// ApplyFunc receives the full set of servers discovered by a source.
+type ApplyFunc func(source string, servers []config.Server)
+
+// Run polls src every interval and passes each successful result to apply. Failed polls are logged and
+// leave the previous result in place. Run blocks until ctx is cancelled.
+func Run(ctx context.Context, logger *zap.Logger, src Source, interval time.Duration, apply ApplyFunc) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	logger = logger.With(zap.String("source", src.Name()))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	poll := func() {
+		srvs, err := src.Discover(ctx)
+		if err != nil {
+			logger.Error("server discovery failed", zap.Error(err))
+			return
+		}
+		logger.Debug("server discovery completed", zap.Int("count", len(srvs)))
+		apply(src.Name(), srvs)
+	}
+
+	poll()
+	for {
+		select {
+		case <-ticker.C:
+			poll()
+		case <-ctx.Done():
+			logger.Info("discovery loop shutting down")
+			return
+		}
+	}
+}
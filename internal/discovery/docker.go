@@ -0,0 +1,129 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/config"
+)
+
+const (
+	// DefaultDockerHost is the default Docker Engine API address.
+	DefaultDockerHost = "unix:///var/run/docker.sock"
+	// LabelPort is the container label holding the server query port.
+	LabelPort = "dzsa-sync.port"
+	// LabelName is the optional container label holding the server name. Defaults to the container name.
+	LabelName = "dzsa-sync.name"
+)
+
+// Docker discovers running containers labeled with LabelPort via the Docker Engine API.
+type Docker struct {
+	client  *http.Client
+	baseURL string
+}
+
+type dockerContainer struct {
+	Names  []string          `json:"Names"`
+	Labels map[string]string `json:"Labels"`
+}
+
+// NewDocker returns a Docker source for host, which is either unix:///path/to/docker.sock or tcp://host:port.
+// An empty host uses DefaultDockerHost.
+func NewDocker(host string) (*Docker, error) {
+	if host == "" {
+		host = DefaultDockerHost
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("parse docker host %s: %w", host, err)
+	}
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		dialer := &net.Dialer{Timeout: 10 * time.Second}
+		return &Docker{
+			client: &http.Client{
+				Timeout: 30 * time.Second,
+				Transport: &http.Transport{
+					DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+						return dialer.DialContext(ctx, "unix", socket)
+					},
+				},
+			},
+			// Host is ignored when dialing the socket but must be a valid URL.
+			baseURL: "http://docker",
+		}, nil
+	case "tcp", "http":
+		return &Docker{
+			client:  &http.Client{Timeout: 30 * time.Second},
+			baseURL: "http://" + u.Host,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported docker host scheme: %q", u.Scheme)
+	}
+}
+
+// Name returns "docker".
+func (d *Docker) Name() string {
+	return "docker"
+}
+
+// Discover lists running containers with LabelPort and returns one server per container.
+// Containers with an invalid port label are skipped.
+func (d *Docker) Discover(ctx context.Context) ([]config.Server, error) {
+	filters, err := json.Marshal(map[string][]string{
+		"label":  {LabelPort},
+		"status": {"running"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal filters: %w", err)
+	}
+	endpoint := d.baseURL + "/containers/json?filters=" + url.QueryEscape(string(filters))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("list containers: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	var containers []dockerContainer
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return nil, fmt.Errorf("decode containers: %w", err)
+	}
+	return serversFromContainers(containers), nil
+}
+
+func serversFromContainers(containers []dockerContainer) []config.Server {
+	seen := make(map[int]bool)
+	var out []config.Server
+	for _, c := range containers {
+		port, err := strconv.Atoi(c.Labels[LabelPort])
+		if err != nil || port < 1 || port > 65535 || seen[port] {
+			continue
+		}
+		name := c.Labels[LabelName]
+		if name == "" && len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		if name == "" {
+			name = strconv.Itoa(port)
+		}
+		seen[port] = true
+		out = append(out, config.Server{Name: name, Port: port})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Port < out[j].Port })
+	return out
+}
@@ -0,0 +1,57 @@
+package discovery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jsirianni/dzsa-sync/config"
+)
+
+func TestDocker_Discover(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if !strings.Contains(r.URL.Query().Get("filters"), LabelPort) {
+			t.Errorf("filters = %q, want label %s", r.URL.Query().Get("filters"), LabelPort)
+		}
+		_, _ = w.Write([]byte(`[
+			{"Names":["/dayz-modded"],"Labels":{"dzsa-sync.port":"2324"}},
+			{"Names":["/dayz-main"],"Labels":{"dzsa-sync.port":"2424","dzsa-sync.name":"main"}},
+			{"Names":["/bad"],"Labels":{"dzsa-sync.port":"not-a-port"}},
+			{"Names":["/dup"],"Labels":{"dzsa-sync.port":"2424"}}
+		]`))
+	}))
+	defer server.Close()
+
+	d, err := NewDocker("tcp://" + strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("NewDocker() error = %v", err)
+	}
+	got, err := d.Discover(context.Background())
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	want := []config.Server{
+		{Name: "dayz-modded", Port: 2324},
+		{Name: "main", Port: 2424},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Discover() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Discover()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestNewDocker_InvalidScheme(t *testing.T) {
+	if _, err := NewDocker("ftp://example.com"); err == nil {
+		t.Error("NewDocker() expected error for unsupported scheme")
+	}
+}
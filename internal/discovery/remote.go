@@ -0,0 +1,104 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"gopkg.in/yaml.v3"
+)
+
+// maxRemoteBody caps the size of a remote server list.
+const maxRemoteBody = 1 << 20
+
+// Remote fetches the server list from a URL, so a central control plane can manage which servers an
+// instance syncs. The response is YAML or JSON: either a list of servers or an object with a
+// servers key, using the same fields as the local config.
+type Remote struct {
+	client  *http.Client
+	url     string
+	headers map[string]string
+}
+
+// NewRemote returns a remote source for url. headers (e.g. Authorization) are sent with every request.
+// httpClient may be nil to use a default client.
+func NewRemote(url string, headers map[string]string, httpClient *http.Client) *Remote {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Remote{
+		client:  httpClient,
+		url:     url,
+		headers: headers,
+	}
+}
+
+// Name returns "remote".
+func (r *Remote) Name() string {
+	return "remote"
+}
+
+// Discover fetches and validates the remote server list. An invalid list is rejected as a whole so a
+// bad publish does not remove running workers.
+func (r *Remote) Discover(ctx context.Context) ([]config.Server, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "dzsa-sync/1.0")
+	req.Header.Set("Accept", "application/json, application/yaml")
+	for k, v := range r.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteBody))
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	return parseRemote(b)
+}
+
+func parseRemote(b []byte) ([]config.Server, error) {
+	var srvs []config.Server
+	b = bytes.TrimSpace(b)
+	if bytes.HasPrefix(b, []byte("-")) || bytes.HasPrefix(b, []byte("[")) {
+		if err := yaml.Unmarshal(b, &srvs); err != nil {
+			return nil, fmt.Errorf("decode server list: %w", err)
+		}
+	} else {
+		var doc struct {
+			Servers []config.Server `yaml:"servers"`
+		}
+		if err := yaml.Unmarshal(b, &doc); err != nil {
+			return nil, fmt.Errorf("decode server list: %w", err)
+		}
+		srvs = doc.Servers
+	}
+
+	seen := make(map[int]bool, len(srvs))
+	for i, s := range srvs {
+		if s.Name == "" {
+			return nil, fmt.Errorf("servers[%d]: name is required", i)
+		}
+		if s.Port < 1 || s.Port > 65535 {
+			return nil, fmt.Errorf("servers[%d]: port must be 1-65535, got %d", i, s.Port)
+		}
+		if seen[s.Port] {
+			return nil, fmt.Errorf("duplicate port: %d", s.Port)
+		}
+		seen[s.Port] = true
+	}
+	return srvs, nil
+}
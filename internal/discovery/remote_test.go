@@ -0,0 +1,84 @@
+package discovery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jsirianni/dzsa-sync/config"
+)
+
+func TestParseRemote(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    []config.Server
+		wantErr bool
+	}{
+		{
+			name: "json list",
+			body: `[{"name":"main","port":2424}]`,
+			want: []config.Server{{Name: "main", Port: 2424}},
+		},
+		{
+			name: "yaml object",
+			body: "servers:\n  - name: main\n    port: 2424\n  - name: modded\n    port: 2324\n",
+			want: []config.Server{{Name: "main", Port: 2424}, {Name: "modded", Port: 2324}},
+		},
+		{
+			name: "empty object",
+			body: `{"servers":[]}`,
+			want: []config.Server{},
+		},
+		{
+			name:    "missing name",
+			body:    `[{"port":2424}]`,
+			wantErr: true,
+		},
+		{
+			name:    "duplicate port",
+			body:    `[{"name":"a","port":2424},{"name":"b","port":2424}]`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRemote([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRemote() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseRemote() = %+v, want %+v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("parseRemote()[%d] = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRemote_Discover(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"servers":[{"name":"main","port":2424}]}`))
+	}))
+	defer server.Close()
+
+	got, err := NewRemote(server.URL, map[string]string{"Authorization": "Bearer secret"}, server.Client()).Discover(context.Background())
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if len(got) != 1 || got[0] != (config.Server{Name: "main", Port: 2424}) {
+		t.Errorf("Discover() = %+v", got)
+	}
+
+	if _, err := NewRemote(server.URL, nil, server.Client()).Discover(context.Background()); err == nil {
+		t.Error("Discover() expected error without auth header")
+	}
+}
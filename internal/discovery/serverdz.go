@@ -0,0 +1,169 @@
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jsirianni/dzsa-sync/config"
+)
+
+// DefaultSteamQueryPort is the query port DayZ uses when serverDZ.cfg does not set steamQueryPort.
+const DefaultSteamQueryPort = 27016
+
+// ServerDZ discovers servers by parsing serverDZ.cfg files matched by glob patterns and, optionally,
+// the -config argument of running DayZServer processes. The server name is the cfg hostname and the
+// port is steamQueryPort.
+type ServerDZ struct {
+	patterns  []string
+	processes bool
+	procRoot  string
+}
+
+// NewServerDZ returns a serverDZ.cfg source. patterns are file globs (e.g. /home/dayz/*/serverDZ.cfg);
+// when processes is true, configs referenced by running DayZServer processes are included as well.
+func NewServerDZ(patterns []string, processes bool) *ServerDZ {
+	return &ServerDZ{
+		patterns:  patterns,
+		processes: processes,
+		procRoot:  "/proc",
+	}
+}
+
+// Name returns "serverdz".
+func (s *ServerDZ) Name() string {
+	return "serverdz"
+}
+
+// Discover parses every matched serverDZ.cfg and returns one server per distinct query port.
+// Files that cannot be read or parsed are skipped.
+func (s *ServerDZ) Discover(_ context.Context) ([]config.Server, error) {
+	var paths []string
+	for _, pattern := range s.patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("glob %s: %w", pattern, err)
+		}
+		paths = append(paths, matches...)
+	}
+	if s.processes {
+		paths = append(paths, s.processConfigs()...)
+	}
+
+	seen := make(map[int]bool)
+	var srvs []config.Server
+	for _, path := range paths {
+		srv, err := parseServerDZFile(path)
+		if err != nil || seen[srv.Port] {
+			continue
+		}
+		seen[srv.Port] = true
+		srvs = append(srvs, srv)
+	}
+	sort.Slice(srvs, func(i, j int) bool { return srvs[i].Port < srvs[j].Port })
+	return srvs, nil
+}
+
+// processConfigs returns the serverDZ.cfg paths of running DayZServer processes, resolved against each process's working directory.
+func (s *ServerDZ) processConfigs() []string {
+	entries, err := os.ReadDir(s.procRoot)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, e := range entries {
+		if _, err := strconv.Atoi(e.Name()); err != nil {
+			continue
+		}
+		dir := filepath.Join(s.procRoot, e.Name())
+		cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")) // #nosec G304 -- procfs path
+		if err != nil {
+			continue
+		}
+		args := strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+		if len(args) == 0 || !strings.Contains(strings.ToLower(filepath.Base(args[0])), "dayzserver") {
+			continue
+		}
+		cfgPath := "serverDZ.cfg"
+		for _, a := range args[1:] {
+			if v, ok := cutPrefixFold(a, "-config="); ok {
+				cfgPath = strings.Trim(v, `"`)
+			}
+		}
+		if !filepath.IsAbs(cfgPath) {
+			cwd, err := os.Readlink(filepath.Join(dir, "cwd"))
+			if err != nil {
+				continue
+			}
+			cfgPath = filepath.Join(cwd, cfgPath)
+		}
+		paths = append(paths, cfgPath)
+	}
+	return paths
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return "", false
+	}
+	return s[len(prefix):], true
+}
+
+func parseServerDZFile(path string) (config.Server, error) {
+	b, err := os.ReadFile(path) // #nosec G304 -- path is user-configured or from a running DayZ process
+	if err != nil {
+		return config.Server{}, fmt.Errorf("read %s: %w", path, err)
+	}
+	srv := parseServerDZ(b)
+	if srv.Name == "" {
+		srv.Name = filepath.Base(filepath.Dir(path))
+	}
+	if srv.Port < 1 || srv.Port > 65535 {
+		return config.Server{}, fmt.Errorf("%s: invalid steamQueryPort %d", path, srv.Port)
+	}
+	return srv, nil
+}
+
+// parseServerDZ reads hostname and steamQueryPort from serverDZ.cfg content (key = value; lines with // comments).
+func parseServerDZ(b []byte) config.Server {
+	srv := config.Server{Port: DefaultSteamQueryPort}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := stripComment(scanner.Text())
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.Trim(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), ";")), `"`)
+		switch strings.ToLower(key) {
+		case "hostname":
+			srv.Name = value
+		case "steamqueryport":
+			if port, err := strconv.Atoi(value); err == nil {
+				srv.Port = port
+			}
+		}
+	}
+	return srv
+}
+
+// stripComment removes a trailing // comment that is not inside a quoted string.
+func stripComment(line string) string {
+	inQuote := false
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '"':
+			inQuote = !inQuote
+		case !inQuote && strings.HasPrefix(line[i:], "//"):
+			return line[:i]
+		}
+	}
+	return line
+}
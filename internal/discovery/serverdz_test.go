@@ -0,0 +1,72 @@
+package discovery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jsirianni/dzsa-sync/config"
+)
+
+func TestParseServerDZ(t *testing.T) {
+	cfg := []byte(`hostname = "My // Server";   // name shown in launcher
+password = "";
+steamQueryPort = 2425; // query port
+maxPlayers = 60;
+`)
+	got := parseServerDZ(cfg)
+	want := config.Server{Name: "My // Server", Port: 2425}
+	if got != want {
+		t.Errorf("parseServerDZ() = %+v, want %+v", got, want)
+	}
+
+	if got := parseServerDZ([]byte(`hostname = "x";`)); got.Port != DefaultSteamQueryPort {
+		t.Errorf("parseServerDZ() port = %d, want default %d", got.Port, DefaultSteamQueryPort)
+	}
+}
+
+func TestServerDZ_Discover(t *testing.T) {
+	dir := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(dir, "main", "serverDZ.cfg"), "hostname = \"main\";\nsteamQueryPort = 2424;\n")
+	write(filepath.Join(dir, "modded", "serverDZ.cfg"), "hostname = \"modded\";\nsteamQueryPort = 2324;\n")
+
+	// Fake procfs with one DayZServer process using a relative -config path.
+	procRoot := filepath.Join(dir, "proc")
+	serverDir := filepath.Join(dir, "proc-server")
+	write(filepath.Join(serverDir, "custom.cfg"), "hostname = \"proc\";\nsteamQueryPort = 2524;\n")
+	write(filepath.Join(procRoot, "1234", "cmdline"), "./DayZServer\x00-config=custom.cfg\x00-port=2502\x00")
+	write(filepath.Join(procRoot, "99", "cmdline"), "/usr/bin/bash\x00")
+	if err := os.Symlink(serverDir, filepath.Join(procRoot, "1234", "cwd")); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServerDZ([]string{filepath.Join(dir, "*", "serverDZ.cfg")}, true)
+	s.procRoot = procRoot
+	got, err := s.Discover(context.Background())
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	want := []config.Server{
+		{Name: "modded", Port: 2324},
+		{Name: "main", Port: 2424},
+		{Name: "proc", Port: 2524},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Discover() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Discover()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
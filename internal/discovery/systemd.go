@@ -0,0 +1,165 @@
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/jsirianni/dzsa-sync/config"
+)
+
+const (
+	// DefaultSystemdPattern is the default unit pattern matched by systemd discovery.
+	DefaultSystemdPattern = "dayz*.service"
+	// EnvPort is the unit environment variable holding the server query port.
+	EnvPort = "DZSA_SYNC_PORT"
+	// EnvName is the optional unit environment variable holding the server name. Defaults to the unit name.
+	EnvName = "DZSA_SYNC_NAME"
+)
+
+// runFunc runs a command and returns its standard output.
+type runFunc func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+// Systemd discovers running systemd units matching a pattern whose environment sets EnvPort.
+// The variable may be set in the unit itself or in a drop-in (e.g. Environment=DZSA_SYNC_PORT=2424).
+type Systemd struct {
+	pattern string
+	run     runFunc
+}
+
+// NewSystemd returns a systemd source for units matching pattern. An empty pattern uses DefaultSystemdPattern.
+func NewSystemd(pattern string) *Systemd {
+	if pattern == "" {
+		pattern = DefaultSystemdPattern
+	}
+	return &Systemd{
+		pattern: pattern,
+		run: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+			return exec.CommandContext(ctx, name, args...).Output() // #nosec G204 -- fixed binary, pattern is user-configured
+		},
+	}
+}
+
+// Name returns "systemd".
+func (s *Systemd) Name() string {
+	return "systemd"
+}
+
+// Discover lists running units matching the pattern and returns one server per unit with a valid EnvPort.
+func (s *Systemd) Discover(ctx context.Context) ([]config.Server, error) {
+	out, err := s.run(ctx, "systemctl", "list-units", "--type=service", "--state=running",
+		"--plain", "--no-legend", "--no-pager", s.pattern)
+	if err != nil {
+		return nil, fmt.Errorf("list units: %w", err)
+	}
+	seen := make(map[int]bool)
+	var srvs []config.Server
+	for _, unit := range parseUnits(out) {
+		envOut, err := s.run(ctx, "systemctl", "show", "--property=Environment", "--value", unit)
+		if err != nil {
+			return nil, fmt.Errorf("show unit %s: %w", unit, err)
+		}
+		env := parseEnvironment(envOut)
+		port, err := strconv.Atoi(env[EnvPort])
+		if err != nil || port < 1 || port > 65535 || seen[port] {
+			continue
+		}
+		name := env[EnvName]
+		if name == "" {
+			name = strings.TrimSuffix(unit, ".service")
+		}
+		seen[port] = true
+		srvs = append(srvs, config.Server{Name: name, Port: port})
+	}
+	sort.Slice(srvs, func(i, j int) bool { return srvs[i].Port < srvs[j].Port })
+	return srvs, nil
+}
+
+// parseUnits returns the unit names from `systemctl list-units --plain --no-legend` output.
+func parseUnits(out []byte) []string {
+	var units []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 {
+			units = append(units, fields[0])
+		}
+	}
+	return units
+}
+
+// parseEnvironment parses the KEY=VALUE assignments printed by `systemctl show --property=Environment --value`.
+// systemd quotes an assignment that contains whitespace or quotes, e.g. "DZSA_SYNC_NAME=My Server", so the
+// output is split on unquoted whitespace and unquoted as a shell would (see splitQuoted).
+func parseEnvironment(out []byte) map[string]string {
+	env := make(map[string]string)
+	for _, kv := range splitQuoted(string(out)) {
+		k, v, ok := strings.Cut(kv, "=")
+		if ok {
+			env[k] = v
+		}
+	}
+	return env
+}
+
+// splitQuoted splits s into words on whitespace outside quotes. Double quotes group a word and allow
+// backslash escapes (\n, \t, or any escaped character), single quotes group a word literally, and a
+// backslash outside quotes escapes the next character. Quotes may start anywhere in a word, so KEY="a b" and
+// "KEY=a b" are the same word.
+func splitQuoted(s string) []string {
+	var (
+		words  []string
+		word   strings.Builder
+		inWord bool
+		quote  rune
+		escape bool
+	)
+	for _, c := range s {
+		switch {
+		case escape:
+			escape = false
+			switch c {
+			case 'n':
+				c = '\n'
+			case 't':
+				c = '\t'
+			}
+			word.WriteRune(c)
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\\':
+			escape, inWord = true, true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			quote, inWord = c, true
+		case unicode.IsSpace(c):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
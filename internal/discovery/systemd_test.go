@@ -0,0 +1,77 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jsirianni/dzsa-sync/config"
+)
+
+func TestSystemd_Discover(t *testing.T) {
+	env := map[string]string{
+		"dayz-main.service":   "DZSA_SYNC_PORT=2424 DZSA_SYNC_NAME=main",
+		"dayz-modded.service": "FOO=bar DZSA_SYNC_PORT=2324",
+		"dayz-nope.service":   "FOO=bar",
+		"dayz-eu.service":     `"DZSA_SYNC_NAME=EU Server #1" DZSA_SYNC_PORT=2524`,
+	}
+	s := NewSystemd("")
+	s.run = func(_ context.Context, _ string, args ...string) ([]byte, error) {
+		switch args[0] {
+		case "list-units":
+			if got := args[len(args)-1]; got != DefaultSystemdPattern {
+				t.Errorf("pattern = %q, want %q", got, DefaultSystemdPattern)
+			}
+			return []byte("dayz-main.service loaded active running DayZ main\n" +
+				"dayz-modded.service loaded active running DayZ modded\n" +
+				"dayz-nope.service loaded active running DayZ nope\n" +
+				"dayz-eu.service loaded active running DayZ EU\n"), nil
+		case "show":
+			return []byte(env[args[len(args)-1]] + "\n"), nil
+		}
+		return nil, fmt.Errorf("unexpected args %v", args)
+	}
+
+	got, err := s.Discover(context.Background())
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	want := []config.Server{
+		{Name: "dayz-modded", Port: 2324},
+		{Name: "main", Port: 2424},
+		{Name: "EU Server #1", Port: 2524},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Discover() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Discover()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseEnvironment(t *testing.T) {
+	for _, tt := range []struct {
+		out  string
+		want map[string]string
+	}{
+		{"A=1 B=2\n", map[string]string{"A": "1", "B": "2"}},
+		// systemd quotes whole assignments with whitespace; a quoted value works too.
+		{`"DZSA_SYNC_NAME=My Server" DZSA_SYNC_PORT=2424`, map[string]string{"DZSA_SYNC_NAME": "My Server", "DZSA_SYNC_PORT": "2424"}},
+		{`DZSA_SYNC_NAME="My Server" DZSA_SYNC_PORT=2424`, map[string]string{"DZSA_SYNC_NAME": "My Server", "DZSA_SYNC_PORT": "2424"}},
+		{`"NAME=say \"hi\"" 'RAW=a\b c' ESC=a\ b`, map[string]string{"NAME": `say "hi"`, "RAW": `a\b c`, "ESC": "a b"}},
+		{"EMPTY= NOVALUE", map[string]string{"EMPTY": ""}},
+	} {
+		got := parseEnvironment([]byte(tt.out))
+		if len(got) != len(tt.want) {
+			t.Errorf("parseEnvironment(%q) = %q, want %q", tt.out, got, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("parseEnvironment(%q)[%s] = %q, want %q", tt.out, k, got[k], v)
+			}
+		}
+	}
+}
@@ -0,0 +1,395 @@
+// Package dnscache is an in-process caching resolver for outbound connections. A sync of every server
+// resolves the same few hostnames hundreds of times within a second; the cache answers those from memory
+// for as long as the records' TTL allows, caches failed lookups briefly, and keeps serving the last good
+// answer when the local resolver is flaky.
+package dnscache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/metrics"
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/sync/singleflight"
+)
+
+// Defaults used when an Options field is zero.
+const (
+	DefaultMinTTL      = 5 * time.Second
+	DefaultMaxTTL      = 10 * time.Minute
+	DefaultNegativeTTL = 30 * time.Second
+	DefaultMaxStale    = time.Hour
+	DefaultTimeout     = 2 * time.Second
+	// DefaultFallbackTTL caches answers from the system resolver, which does not report a TTL.
+	DefaultFallbackTTL = 30 * time.Second
+
+	resolvConf = "/etc/resolv.conf"
+	// maxResponseSize is large enough for any UDP response; truncated answers fall back to the system resolver.
+	maxResponseSize = 4096
+	// dohMaxResponseSize is the largest DNS message, which DoH responses are not truncated below.
+	dohMaxResponseSize = 65535
+	dohContentType     = "application/dns-message"
+)
+
+// Lookup results recorded by the dns_lookup_count metric.
+const (
+	ResultHit      = "hit"
+	ResultMiss     = "miss"
+	ResultNegative = "negative"
+	ResultStale    = "stale"
+	ResultError    = "error"
+)
+
+var (
+	errNotFound  = errors.New("no such host")
+	errNoRecords = errors.New("no A records")
+)
+
+// Options configures a Resolver.
+type Options struct {
+	// Servers are nameservers (host:port) queried directly so record TTLs are known. Nil reads /etc/resolv.conf.
+	Servers []string
+	// DoH is a DNS-over-HTTPS endpoint (RFC 8484) queried instead of Servers when set, e.g.
+	// https://1.1.1.1/dns-query.
+	DoH string
+	// DoHClient sends the DoH queries. Nil uses a client that resolves the endpoint with the system resolver,
+	// so an endpoint with an IP address avoids depending on it.
+	DoHClient *http.Client
+	// Fallback resolves names the nameservers cannot answer (hosts file entries, single-label names,
+	// IPv6-only hosts). Nil uses net.DefaultResolver.
+	Fallback func(ctx context.Context, host string) ([]string, error)
+	// MinTTL and MaxTTL clamp record TTLs.
+	MinTTL time.Duration
+	MaxTTL time.Duration
+	// NegativeTTL is how long a name that does not exist is cached.
+	NegativeTTL time.Duration
+	// MaxStale is how long past expiry an answer is served while lookups fail.
+	MaxStale time.Duration
+	// Timeout bounds each nameserver query.
+	Timeout time.Duration
+	// Recorder records the result of every lookup. Optional.
+	Recorder metrics.DNSRecorder
+}
+
+// Resolver resolves hostnames to IPv4 addresses with caching. It is safe for concurrent use.
+type Resolver struct {
+	opts  Options
+	group singleflight.Group
+	now   func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]entry
+}
+
+type entry struct {
+	addrs   []string
+	expires time.Time
+	// notFound marks a negative entry.
+	notFound bool
+}
+
+// New returns a Resolver.
+func New(opts Options) *Resolver {
+	if opts.Servers == nil {
+		opts.Servers = readNameservers(resolvConf)
+	}
+	if opts.Fallback == nil {
+		opts.Fallback = net.DefaultResolver.LookupHost
+	}
+	if opts.DoH != "" && opts.DoHClient == nil {
+		opts.DoHClient = &http.Client{}
+	}
+	opts.MinTTL = orDefault(opts.MinTTL, DefaultMinTTL)
+	opts.MaxTTL = orDefault(opts.MaxTTL, DefaultMaxTTL)
+	opts.NegativeTTL = orDefault(opts.NegativeTTL, DefaultNegativeTTL)
+	opts.MaxStale = orDefault(opts.MaxStale, DefaultMaxStale)
+	opts.Timeout = orDefault(opts.Timeout, DefaultTimeout)
+	return &Resolver{opts: opts, now: time.Now, cache: make(map[string]entry)}
+}
+
+// Dial returns a DialContext function that resolves hostnames through the cache and dials each address
+// in turn with d until one connects.
+func (r *Resolver) Dial(d *net.Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil || net.ParseIP(host) != nil {
+			return d.DialContext(ctx, network, address)
+		}
+		addrs, err := r.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var dialErr error
+		for _, addr := range addrs {
+			conn, err := d.DialContext(ctx, network, net.JoinHostPort(addr, port))
+			if err == nil {
+				return conn, nil
+			}
+			dialErr = err
+		}
+		return nil, dialErr
+	}
+}
+
+// LookupHost returns the addresses of host. Concurrent lookups of the same uncached name share one query.
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	r.mu.Lock()
+	e, ok := r.cache[host]
+	r.mu.Unlock()
+	if ok && r.now().Before(e.expires) {
+		if e.notFound {
+			r.record(ctx, ResultNegative)
+			return nil, notFoundError(host)
+		}
+		r.record(ctx, ResultHit)
+		return e.addrs, nil
+	}
+
+	v, err, _ := r.group.Do(host, func() (any, error) {
+		return r.resolve(ctx, host)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]string), nil
+}
+
+// resolve looks host up, updates the cache, and falls back to a stale answer when the lookup fails.
+func (r *Resolver) resolve(ctx context.Context, host string) ([]string, error) {
+	addrs, ttl, err := r.lookup(ctx, host)
+	now := r.now()
+	switch {
+	case err == nil:
+		r.store(host, entry{addrs: addrs, expires: now.Add(min(max(ttl, r.opts.MinTTL), r.opts.MaxTTL))})
+		r.record(ctx, ResultMiss)
+		return addrs, nil
+	case isNotFound(err):
+		r.store(host, entry{notFound: true, expires: now.Add(r.opts.NegativeTTL)})
+		r.record(ctx, ResultNegative)
+		return nil, notFoundError(host)
+	}
+
+	r.mu.Lock()
+	e, ok := r.cache[host]
+	r.mu.Unlock()
+	if ok && !e.notFound && now.Before(e.expires.Add(r.opts.MaxStale)) {
+		r.record(ctx, ResultStale)
+		return e.addrs, nil
+	}
+	r.record(ctx, ResultError)
+	return nil, err
+}
+
+// lookup queries the nameservers, or the DoH endpoint, for A records and, when they have no answer, the
+// fallback resolver. Single-label names go straight to the fallback, which applies search domains.
+func (r *Resolver) lookup(ctx context.Context, host string) ([]string, time.Duration, error) {
+	var queryErr error
+	if strings.Contains(host, ".") && (len(r.opts.Servers) > 0 || r.opts.DoH != "") {
+		addrs, ttl, err := r.query(ctx, host)
+		if err == nil {
+			return addrs, ttl, nil
+		}
+		queryErr = err
+	}
+	addrs, err := r.opts.Fallback(ctx, host)
+	if err != nil {
+		// When no nameserver answered, a "not found" from the fallback is not trusted over a cached answer.
+		if queryErr != nil && !errors.Is(queryErr, errNotFound) && !errors.Is(queryErr, errNoRecords) {
+			return nil, 0, fmt.Errorf("lookup %s: %w", host, queryErr)
+		}
+		return nil, 0, err
+	}
+	return addrs, DefaultFallbackTTL, nil
+}
+
+// query sends an A query to the DoH endpoint, or to each nameserver until one answers, and returns the
+// addresses with the lowest TTL in the answer chain.
+func (r *Resolver) query(ctx context.Context, host string) ([]string, time.Duration, error) {
+	name, err := dnsmessage.NewName(host + ".")
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid name %q: %w", host, err)
+	}
+	id := uint16(rand.N(1 << 16)) // #nosec G115 G404 -- DNS message ID, bounded to uint16
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}},
+	}
+	req, err := msg.Pack()
+	if err != nil {
+		return nil, 0, fmt.Errorf("pack query: %w", err)
+	}
+
+	if r.opts.DoH != "" {
+		return r.exchangeDoH(ctx, req)
+	}
+	var queryErr error
+	for _, server := range r.opts.Servers {
+		addrs, ttl, err := r.exchange(ctx, server, id, req)
+		if err == nil || errors.Is(err, errNotFound) || errors.Is(err, errNoRecords) {
+			return addrs, ttl, err
+		}
+		queryErr = err
+	}
+	return nil, 0, queryErr
+}
+
+func (r *Resolver) exchange(ctx context.Context, server string, id uint16, req []byte) ([]string, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.opts.Timeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, 0, fmt.Errorf("dial %s: %w", server, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write(req); err != nil {
+		return nil, 0, fmt.Errorf("write %s: %w", server, err)
+	}
+
+	buf := make([]byte, maxResponseSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, 0, fmt.Errorf("read %s: %w", server, err)
+		}
+		var p dnsmessage.Parser
+		h, err := p.Start(buf[:n])
+		if err != nil || h.ID != id || !h.Response {
+			// Ignore stray or malformed packets until the deadline.
+			continue
+		}
+		return parseAnswer(&p, h)
+	}
+}
+
+// exchangeDoH posts req to the DoH endpoint. The response ID is not checked, since HTTP pairs the response
+// with its query.
+func (r *Resolver) exchangeDoH(ctx context.Context, req []byte) ([]string, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.opts.Timeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, r.opts.DoH, bytes.NewReader(req))
+	if err != nil {
+		return nil, 0, fmt.Errorf("doh request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", dohContentType)
+	httpReq.Header.Set("Accept", dohContentType)
+	resp, err := r.opts.DoHClient.Do(httpReq)
+	if err != nil {
+		return nil, 0, fmt.Errorf("doh: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("doh: status %d", resp.StatusCode)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, dohMaxResponseSize))
+	if err != nil {
+		return nil, 0, fmt.Errorf("doh: read response: %w", err)
+	}
+	var p dnsmessage.Parser
+	h, err := p.Start(b)
+	if err != nil || !h.Response {
+		return nil, 0, fmt.Errorf("doh: malformed response")
+	}
+	return parseAnswer(&p, h)
+}
+
+// parseAnswer returns the A records of a response and the lowest TTL among all answers.
+func parseAnswer(p *dnsmessage.Parser, h dnsmessage.Header) ([]string, time.Duration, error) {
+	switch {
+	case h.RCode == dnsmessage.RCodeNameError:
+		return nil, 0, errNotFound
+	case h.RCode != dnsmessage.RCodeSuccess:
+		return nil, 0, fmt.Errorf("server answered %s", h.RCode)
+	case h.Truncated:
+		return nil, 0, fmt.Errorf("truncated response")
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, 0, fmt.Errorf("parse questions: %w", err)
+	}
+	answers, err := p.AllAnswers()
+	if err != nil {
+		return nil, 0, fmt.Errorf("parse answers: %w", err)
+	}
+	var (
+		addrs []string
+		ttl   time.Duration
+	)
+	for i, a := range answers {
+		if d := time.Duration(a.Header.TTL) * time.Second; i == 0 || d < ttl {
+			ttl = d
+		}
+		if body, ok := a.Body.(*dnsmessage.AResource); ok {
+			addrs = append(addrs, net.IP(body.A[:]).String())
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, 0, errNoRecords
+	}
+	return addrs, ttl, nil
+}
+
+func (r *Resolver) store(host string, e entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// A failed lookup must not replace a good answer that can still be served stale.
+	if old, ok := r.cache[host]; e.notFound && ok && !old.notFound && r.now().Before(old.expires) {
+		return
+	}
+	r.cache[host] = e
+}
+
+func (r *Resolver) record(ctx context.Context, result string) {
+	if r.opts.Recorder != nil {
+		r.opts.Recorder.RecordDNSLookup(ctx, result)
+	}
+}
+
+func notFoundError(host string) error {
+	return &net.DNSError{Err: errNotFound.Error(), Name: host, IsNotFound: true}
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.Is(err, errNotFound) || (errors.As(err, &dnsErr) && dnsErr.IsNotFound)
+}
+
+// readNameservers returns the nameserver entries of a resolv.conf file as host:port, or nil when the file
+// cannot be read.
+func readNameservers(path string) []string {
+	f, err := os.Open(path) // #nosec G304 -- fixed system path
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var servers []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" && net.ParseIP(fields[1]) != nil {
+			servers = append(servers, net.JoinHostPort(fields[1], "53"))
+		}
+	}
+	return servers
+}
+
+func orDefault(v, def time.Duration) time.Duration {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,283 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeServer is a UDP nameserver that answers A queries from records and NXDOMAIN otherwise.
+type fakeServer struct {
+	conn    net.PacketConn
+	queries atomic.Int32
+	down    atomic.Bool
+	records map[string][4]byte
+	ttl     uint32
+}
+
+func newFakeServer(t *testing.T, records map[string][4]byte, ttl uint32) *fakeServer {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{conn: conn, records: records, ttl: ttl}
+	t.Cleanup(func() { conn.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *fakeServer) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		s.queries.Add(1)
+		if s.down.Load() {
+			continue
+		}
+		if b := s.answer(buf[:n]); b != nil {
+			_, _ = s.conn.WriteTo(b, addr)
+		}
+	}
+}
+
+// answer returns the response to the query in b, or nil when it is malformed.
+func (s *fakeServer) answer(b []byte) []byte {
+	var req dnsmessage.Message
+	if err := req.Unpack(b); err != nil || len(req.Questions) != 1 {
+		return nil
+	}
+	q := req.Questions[0]
+	resp := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: req.ID, Response: true, RecursionAvailable: true},
+		Questions: req.Questions,
+	}
+	if a, ok := s.records[q.Name.String()]; ok {
+		resp.Answers = []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: s.ttl},
+			Body:   &dnsmessage.AResource{A: a},
+		}}
+	} else {
+		resp.RCode = dnsmessage.RCodeNameError
+	}
+	out, _ := resp.Pack()
+	return out
+}
+
+type fakeRecorder struct {
+	mu      sync.Mutex
+	results []string
+}
+
+func (r *fakeRecorder) RecordDNSLookup(_ context.Context, result string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, result)
+}
+
+func TestLookupHostCaches(t *testing.T) {
+	srv := newFakeServer(t, map[string][4]byte{"dayzsalauncher.com.": {203, 0, 113, 10}}, 60)
+	rec := &fakeRecorder{}
+	r := New(Options{
+		Servers: []string{srv.conn.LocalAddr().String()},
+		Fallback: func(_ context.Context, host string) ([]string, error) {
+			return nil, &net.DNSError{Name: host, IsNotFound: true}
+		},
+		Timeout:  200 * time.Millisecond,
+		Recorder: rec,
+	})
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// A burst of concurrent lookups issues a single query.
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			addrs, err := r.LookupHost(ctx, "dayzsalauncher.com")
+			if err != nil || !slices.Equal(addrs, []string{"203.0.113.10"}) {
+				t.Errorf("LookupHost() = %v, %v", addrs, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err := r.LookupHost(ctx, "dayzsalauncher.com."); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.queries.Load(); n != 1 {
+		t.Errorf("nameserver got %d queries, want 1", n)
+	}
+
+	// The record expires after its TTL.
+	now = now.Add(61 * time.Second)
+	if _, err := r.LookupHost(ctx, "dayzsalauncher.com"); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.queries.Load(); n != 2 {
+		t.Errorf("nameserver got %d queries after expiry, want 2", n)
+	}
+
+	// Names that do not exist are cached for NegativeTTL.
+	for range 3 {
+		_, err := r.LookupHost(ctx, "missing.example.com")
+		var dnsErr *net.DNSError
+		if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+			t.Fatalf("LookupHost(missing) error = %v, want not found", err)
+		}
+	}
+	if n := srv.queries.Load(); n != 3 {
+		t.Errorf("nameserver got %d queries for a missing name, want 3", n)
+	}
+
+	// A failing nameserver serves the expired answer.
+	srv.down.Store(true)
+	now = now.Add(2 * time.Minute)
+	addrs, err := r.LookupHost(ctx, "dayzsalauncher.com")
+	if err != nil || !slices.Equal(addrs, []string{"203.0.113.10"}) {
+		t.Errorf("LookupHost() while down = %v, %v, want stale answer", addrs, err)
+	}
+	now = now.Add(DefaultMaxStale)
+	if _, err := r.LookupHost(ctx, "dayzsalauncher.com"); err == nil {
+		t.Error("LookupHost() served an answer older than MaxStale")
+	}
+
+	// Callers that joined the burst's in-flight query record nothing and later ones record hits,
+	// so runs of the same result are compacted before comparing.
+	want := []string{ResultMiss, ResultHit, ResultMiss, ResultNegative, ResultStale, ResultError}
+	rec.mu.Lock()
+	got := slices.Compact(slices.Clone(rec.results))
+	rec.mu.Unlock()
+	if !slices.Equal(got, want) {
+		t.Errorf("recorded %v, want %v", got, want)
+	}
+}
+
+func TestLookupHostTTLClamp(t *testing.T) {
+	srv := newFakeServer(t, map[string][4]byte{"steam.example.com.": {198, 51, 100, 1}}, 0)
+	r := New(Options{Servers: []string{srv.conn.LocalAddr().String()}, MinTTL: 10 * time.Second})
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	for range 2 {
+		if _, err := r.LookupHost(context.Background(), "steam.example.com"); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(5 * time.Second)
+	}
+	if n := srv.queries.Load(); n != 1 {
+		t.Errorf("nameserver got %d queries within MinTTL, want 1", n)
+	}
+}
+
+func TestLookupHostFallback(t *testing.T) {
+	var calls atomic.Int32
+	r := New(Options{
+		Servers: []string{},
+		Fallback: func(_ context.Context, host string) ([]string, error) {
+			calls.Add(1)
+			if host != "localhost" {
+				return nil, &net.DNSError{Name: host, IsNotFound: true}
+			}
+			return []string{"127.0.0.1"}, nil
+		},
+	})
+	for range 2 {
+		addrs, err := r.LookupHost(context.Background(), "localhost")
+		if err != nil || !slices.Equal(addrs, []string{"127.0.0.1"}) {
+			t.Fatalf("LookupHost(localhost) = %v, %v", addrs, err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("fallback called %d times, want 1", n)
+	}
+}
+
+func TestLookupHostDoH(t *testing.T) {
+	records := &fakeServer{records: map[string][4]byte{"dayzsalauncher.com.": {203, 0, 113, 10}}, ttl: 60}
+	var contentType string
+	doh := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/dns-message")
+		_, _ = w.Write(records.answer(b))
+	}))
+	defer doh.Close()
+	r := New(Options{
+		// The nameservers are not queried with DoH.
+		Servers:   []string{"192.0.2.1:53"},
+		DoH:       doh.URL + "/dns-query",
+		DoHClient: doh.Client(),
+		Fallback: func(_ context.Context, host string) ([]string, error) {
+			return nil, &net.DNSError{Name: host, IsNotFound: true}
+		},
+		Timeout: time.Second,
+	})
+
+	addrs, err := r.LookupHost(context.Background(), "dayzsalauncher.com")
+	if err != nil || !slices.Equal(addrs, []string{"203.0.113.10"}) {
+		t.Fatalf("LookupHost = %v, %v", addrs, err)
+	}
+	if contentType != "application/dns-message" {
+		t.Errorf("Content-Type = %q", contentType)
+	}
+	if _, err := r.LookupHost(context.Background(), "missing.example.com"); !isNotFound(err) {
+		t.Errorf("LookupHost(missing) error = %v, want not found", err)
+	}
+}
+
+func TestDial(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	r := New(Options{
+		Servers:  []string{},
+		Fallback: func(context.Context, string) ([]string, error) { return []string{"127.0.0.1"}, nil },
+	})
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	conn, err := r.Dial(&net.Dialer{})(context.Background(), "tcp", net.JoinHostPort("api.example.com", port))
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	conn.Close()
+}
+
+func TestReadNameservers(t *testing.T) {
+	path := t.TempDir() + "/resolv.conf"
+	if err := os.WriteFile(path, []byte("# comment\nnameserver 127.0.0.53\nsearch example.com\nnameserver ::1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got := readNameservers(path)
+	if want := []string{"127.0.0.53:53", "[::1]:53"}; !slices.Equal(got, want) {
+		t.Errorf("readNameservers() = %v, want %v", got, want)
+	}
+	if got := readNameservers(path + ".missing"); got != nil {
+		t.Errorf("readNameservers(missing) = %v, want nil", got)
+	}
+}
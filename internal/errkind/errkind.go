@@ -0,0 +1,74 @@
+// Package errkind categorizes errors so that logs, metrics, and API responses can say what went wrong
+// (bad config, network, DZSA or another upstream API, invalid input, or a bug) without parsing messages.
+package errkind
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"go.uber.org/zap"
+)
+
+// Kind is the category of an error.
+type Kind string
+
+// Error kinds.
+const (
+	// Config is a config file that cannot be read, parsed, or is invalid.
+	Config Kind = "config"
+	// Network is a failure to reach a host: DNS, connect, TLS, or timeout.
+	Network Kind = "network"
+	// Upstream is an unexpected answer from DZSA or another API: an error status or an unreadable body.
+	Upstream Kind = "upstream_api"
+	// Validation is invalid input, e.g. an API request parameter.
+	Validation Kind = "validation"
+	// Internal is anything else.
+	Internal Kind = "internal"
+)
+
+// Error is an error with a Kind. It unwraps to the underlying error.
+type Error struct {
+	Kind Kind
+	Err  error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Wrap returns err with the given kind, or nil when err is nil.
+func Wrap(kind Kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+// Errorf formats an error like fmt.Errorf, %w included, and gives it the kind.
+func Errorf(kind Kind, format string, args ...any) error {
+	return &Error{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// Of returns the kind of err: the outermost Kind it was wrapped with, otherwise Network for network
+// errors and timeouts, and Internal for the rest. Of(nil) is "".
+func Of(err error) Kind {
+	if err == nil {
+		return ""
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return Network
+	}
+	return Internal
+}
+
+// Field returns the error_kind log field for err, to be logged next to zap.Error(err).
+func Field(err error) zap.Field {
+	return zap.String("error_kind", string(Of(err)))
+}
@@ -0,0 +1,41 @@
+package errkind
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestOf(t *testing.T) {
+	base := errors.New("boom")
+	tests := []struct {
+		name string
+		err  error
+		want Kind
+	}{
+		{"nil", nil, ""},
+		{"plain", base, Internal},
+		{"wrapped", Wrap(Upstream, base), Upstream},
+		{"wrapped further", fmt.Errorf("sync: %w", Wrap(Validation, base)), Validation},
+		{"outermost wins", Wrap(Config, Wrap(Network, base)), Config},
+		{"net error", fmt.Errorf("do request: %w", &net.OpError{Op: "dial", Err: base}), Network},
+		{"deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), Network},
+		{"errorf", Errorf(Upstream, "decode: %w", base), Upstream},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Of(tt.err); got != tt.want {
+				t.Errorf("Of() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if Wrap(Network, nil) != nil {
+		t.Error("Wrap(nil) is not nil")
+	}
+	if err := Errorf(Upstream, "decode: %w", base); !errors.Is(err, base) || err.Error() != "decode: boom" {
+		t.Errorf("Errorf() = %v, does not wrap its cause", err)
+	}
+}
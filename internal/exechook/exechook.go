@@ -0,0 +1,305 @@
+// Package exechook runs operator-configured shell commands and webhooks on lifecycle events (a sync succeeded
+// or failed, a server went offline or its data changed, the external IP changed), so integrations that are not
+// built in can be scripted. Commands get the event in DZSA_SYNC_* environment variables and as JSON on stdin;
+// webhooks send it as JSON or in the body their template renders.
+package exechook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/notify"
+	"github.com/jsirianni/dzsa-sync/model"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultTimeout bounds a run when the hook's timeout is unset.
+	DefaultTimeout = 30 * time.Second
+	// DefaultMaxConcurrent is how many runs of a hook may run at once when unset.
+	DefaultMaxConcurrent = 1
+	// maxOutput is how much of a command's output is logged.
+	maxOutput = 4096
+	// waitDelay is how long a killed command's children may hold its output open.
+	waitDelay = time.Second
+)
+
+// Event is what a hook runs on. It is written to the command's stdin as JSON, and is the data of webhook body
+// templates.
+type Event struct {
+	// Event is one of config.ExecEvents.
+	Event        string    `json:"event"`
+	Time         time.Time `json:"time"`
+	InstanceName string    `json:"instance_name,omitempty"`
+	// Server and Port are set for every event but ip_change.
+	Server string `json:"server,omitempty"`
+	Port   int    `json:"port,omitempty"`
+	// Result is the DZSA result of a successful sync.
+	Result *model.Result `json:"result,omitempty"`
+	// Error, ErrorKind, and Failures describe a failed sync.
+	Error     string `json:"error,omitempty"`
+	ErrorKind string `json:"error_kind,omitempty"`
+	Failures  int    `json:"consecutive_failures,omitempty"`
+	// OldIP and NewIP are set for ip_change.
+	OldIP string `json:"old_ip,omitempty"`
+	NewIP string `json:"new_ip,omitempty"`
+	// Changes are the fields that changed for server_change, from the stored result to Result.
+	Changes []model.Change `json:"changes,omitempty"`
+}
+
+// env returns the event as DZSA_SYNC_* environment variables.
+func (e Event) env() []string {
+	vars := []string{"DZSA_SYNC_EVENT=" + e.Event, "DZSA_SYNC_TIME=" + e.Time.UTC().Format(time.RFC3339)}
+	add := func(name, value string) {
+		if value != "" {
+			vars = append(vars, "DZSA_SYNC_"+name+"="+value)
+		}
+	}
+	add("INSTANCE_NAME", e.InstanceName)
+	add("SERVER", e.Server)
+	if e.Port != 0 {
+		add("PORT", strconv.Itoa(e.Port))
+	}
+	if r := e.Result; r != nil {
+		add("NAME", r.Name)
+		add("PLAYERS", strconv.Itoa(r.Players))
+		add("MAX_PLAYERS", strconv.Itoa(r.MaxPlayers))
+		add("VERSION", r.Version)
+		add("MAP", r.Map)
+	}
+	add("ERROR", e.Error)
+	add("ERROR_KIND", e.ErrorKind)
+	if e.Failures != 0 {
+		add("FAILURES", strconv.Itoa(e.Failures))
+	}
+	add("OLD_IP", e.OldIP)
+	add("NEW_IP", e.NewIP)
+	if len(e.Changes) > 0 {
+		fields := make([]string, len(e.Changes))
+		for i, c := range e.Changes {
+			fields[i] = c.Field
+		}
+		add("CHANGED", strings.Join(fields, ","))
+	}
+	return vars
+}
+
+// Options configures a Runner.
+type Options struct {
+	// Logger logs every run. Nil disables logging.
+	Logger *zap.Logger
+	Hooks  []config.ExecHook
+	// Webhooks are sent like Hooks are run. Their config must have been validated.
+	Webhooks []config.Webhook
+	// Client sends webhook requests. Nil uses a default client.
+	Client *http.Client
+	// Redact rewrites webhook bodies when set, e.g. to hide IP addresses in sync errors. Command input is not
+	// redacted, since it stays on the host.
+	Redact func(string) string
+	// InstanceName is set on every event when set.
+	InstanceName string
+	// Active reports whether this instance runs hooks, e.g. while it is the HA leader. Nil is always.
+	Active func() bool
+}
+
+// Runner runs the hooks and sends the webhooks of events. Safe for concurrent use.
+type Runner struct {
+	opts  Options
+	hooks []*hook
+	wg    sync.WaitGroup
+}
+
+// hook is an exec hook or a webhook.
+type hook struct {
+	// kind is "exec hook" or "webhook", for logs.
+	kind    string
+	name    string
+	events  []string
+	servers []string
+	timeout time.Duration
+	// slots holds a value per run in progress, up to the hook's max_concurrent.
+	slots chan struct{}
+	// run runs the hook for an event, logging the outcome.
+	run func(ctx context.Context, logger *zap.Logger, e Event)
+}
+
+// New returns a runner, or an error when a webhook body template does not parse.
+func New(opts Options) (*Runner, error) {
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+	r := &Runner{opts: opts}
+	for _, h := range opts.Hooks {
+		if h.Timeout <= 0 {
+			h.Timeout = DefaultTimeout
+		}
+		r.hooks = append(r.hooks, newHook("exec hook", h.Name, h.Events, h.Servers, h.Timeout, h.MaxConcurrent, func(ctx context.Context, logger *zap.Logger, e Event) {
+			runCommand(ctx, logger, h, e)
+		}))
+	}
+	for _, w := range opts.Webhooks {
+		var body *template.Template
+		if w.Body != "" {
+			var err error
+			if body, err = notify.ParseBody(w.Body); err != nil {
+				return nil, fmt.Errorf("webhook %s: body: %w", w.Name, err)
+			}
+		}
+		wh := notify.NewHTTP(notify.HTTPOptions{Client: opts.Client, Type: notify.TypeWebhook, URL: w.URL, Method: w.Method, Headers: w.Headers, Body: body, Redact: opts.Redact})
+		r.hooks = append(r.hooks, newHook("webhook", w.Name, w.Events, w.Servers, w.Timeout, w.MaxConcurrent, func(ctx context.Context, logger *zap.Logger, e Event) {
+			start := time.Now()
+			if err := wh.Send(ctx, e); err != nil {
+				logger.Warn("webhook failed", zap.Duration("duration", time.Since(start)), zap.Error(err))
+				return
+			}
+			logger.Info("webhook sent", zap.Duration("duration", time.Since(start)))
+		}))
+	}
+	return r, nil
+}
+
+func newHook(kind, name string, events, servers []string, timeout time.Duration, maxConcurrent int, run func(context.Context, *zap.Logger, Event)) *hook {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	if maxConcurrent <= 0 {
+		maxConcurrent = DefaultMaxConcurrent
+	}
+	return &hook{kind: kind, name: name, events: events, servers: servers, timeout: timeout, slots: make(chan struct{}, maxConcurrent), run: run}
+}
+
+// Fire starts the hooks of e in the background and returns at once. A hook already running MaxConcurrent
+// times skips e, so a slow command cannot pile up runs. A nil runner does nothing.
+func (r *Runner) Fire(e Event) {
+	if r == nil || (r.opts.Active != nil && !r.opts.Active()) {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.InstanceName = r.opts.InstanceName
+	for _, h := range r.hooks {
+		if !h.matches(e) {
+			continue
+		}
+		select {
+		case h.slots <- struct{}{}:
+		default:
+			r.opts.Logger.Warn(h.kind+" still running, skipping event",
+				zap.String("hook", h.name),
+				zap.String("event", e.Event),
+				zap.Int("max_concurrent", cap(h.slots)))
+			continue
+		}
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			defer func() { <-h.slots }()
+			r.run(h, e)
+		}()
+	}
+}
+
+// Wait returns once every run in progress has finished, which their timeouts bound. A nil runner returns at
+// once.
+func (r *Runner) Wait() {
+	if r != nil {
+		r.wg.Wait()
+	}
+}
+
+func (h *hook) matches(e Event) bool {
+	if !slices.Contains(h.events, e.Event) {
+		return false
+	}
+	return e.Server == "" || len(h.servers) == 0 || slices.Contains(h.servers, e.Server) || slices.Contains(h.servers, strconv.Itoa(e.Port))
+}
+
+func (r *Runner) run(h *hook, e Event) {
+	logger := r.opts.Logger.With(zap.String("hook", h.name), zap.String("event", e.Event))
+	if e.Server != "" {
+		logger = logger.With(zap.String("server", e.Server), zap.Int("port", e.Port))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+	h.run(ctx, logger, e)
+}
+
+// runCommand runs the command of h for e until ctx is done.
+func runCommand(ctx context.Context, logger *zap.Logger, h config.ExecHook, e Event) {
+	stdin, err := json.Marshal(e)
+	if err != nil {
+		logger.Error("exec hook event", zap.Error(err))
+		return
+	}
+	cmd := command(ctx, h.Command)
+	cmd.Env = append(os.Environ(), e.env()...)
+	cmd.Env = append(cmd.Env, "DZSA_SYNC_HOOK="+h.Name)
+	cmd.Stdin = bytes.NewReader(stdin)
+	out := &limitedBuffer{max: maxOutput}
+	cmd.Stdout, cmd.Stderr = out, out
+	cmd.WaitDelay = waitDelay
+
+	start := time.Now()
+	err = cmd.Run()
+	fields := []zap.Field{zap.Duration("duration", time.Since(start)), zap.String("output", out.String())}
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		logger.Warn("exec hook timed out and was killed", append(fields, zap.Duration("timeout", h.Timeout))...)
+	case err != nil:
+		logger.Warn("exec hook failed", append(fields, zap.Error(err))...)
+	default:
+		logger.Info("exec hook ran", fields...)
+	}
+}
+
+// command returns the command running script with the platform's shell.
+func command(ctx context.Context, script string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", script) // #nosec G204 -- operator-configured command
+	}
+	return exec.CommandContext(ctx, "/bin/sh", "-c", script) // #nosec G204 -- operator-configured command
+}
+
+// limitedBuffer keeps the first max bytes written to it. Safe for concurrent use, since stdout and stderr
+// share it.
+type limitedBuffer struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if room := b.max - b.buf.Len(); room < len(p) {
+		b.buf.Write(p[:max(room, 0)])
+		b.truncated = true
+	} else {
+		b.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.truncated {
+		return fmt.Sprintf("%s... (truncated)", b.buf.String())
+	}
+	return b.buf.String()
+}
@@ -0,0 +1,158 @@
+package exechook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/model"
+)
+
+func TestRunner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks in this test are sh scripts")
+	}
+	dir := t.TempDir()
+	envFile, stdinFile, runsFile := filepath.Join(dir, "env"), filepath.Join(dir, "stdin"), filepath.Join(dir, "runs")
+	r, err := New(Options{
+		InstanceName: "eu-1",
+		Hooks: []config.ExecHook{
+			{
+				Name:    "record",
+				Events:  []string{config.ExecEventSyncSuccess},
+				Command: `env > ` + envFile + `; cat > ` + stdinFile,
+				Servers: []string{"main"},
+			},
+			{
+				Name:    "slow",
+				Events:  []string{config.ExecEventIPChange},
+				Command: "echo $DZSA_SYNC_NEW_IP >> " + runsFile + "; sleep 5",
+				Timeout: 100 * time.Millisecond,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another server's event does not run the hook.
+	r.Fire(Event{Event: config.ExecEventSyncSuccess, Server: "other", Port: 2402})
+	r.Fire(Event{Event: config.ExecEventSyncSuccess, Server: "main", Port: 2302, Result: &model.Result{Name: "Main", Players: 7, MaxPlayers: 60}})
+	r.Wait()
+
+	env, err := os.ReadFile(envFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"DZSA_SYNC_EVENT=post_sync_success", "DZSA_SYNC_SERVER=main", "DZSA_SYNC_PORT=2302", "DZSA_SYNC_PLAYERS=7", "DZSA_SYNC_INSTANCE_NAME=eu-1", "DZSA_SYNC_HOOK=record"} {
+		if !strings.Contains(string(env), want+"\n") {
+			t.Errorf("environment does not contain %s", want)
+		}
+	}
+	var got Event
+	b, err := os.ReadFile(stdinFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("stdin is not an event: %v", err)
+	}
+	if got.Server != "main" || got.Result == nil || got.Result.Players != 7 || got.InstanceName != "eu-1" {
+		t.Errorf("stdin event = %+v", got)
+	}
+
+	// The second event finds the only slot taken and is skipped; the run is killed at the timeout.
+	start := time.Now()
+	r.Fire(Event{Event: config.ExecEventIPChange, OldIP: "203.0.113.1", NewIP: "203.0.113.2"})
+	r.Fire(Event{Event: config.ExecEventIPChange, OldIP: "203.0.113.2", NewIP: "203.0.113.3"})
+	r.Wait()
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("timed out hook ran for %v", elapsed)
+	}
+	if runs, _ := os.ReadFile(runsFile); string(runs) != "203.0.113.2\n" {
+		t.Errorf("runs of the slow hook = %q, want only the first event", runs)
+	}
+}
+
+func TestRunnerWebhook(t *testing.T) {
+	bodies := make(chan string, 4)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies <- r.Method + " " + string(b)
+	}))
+	defer ts.Close()
+	r, err := New(Options{
+		Client:       ts.Client(),
+		InstanceName: "eu-1",
+		Webhooks: []config.Webhook{{
+			Name:    "status",
+			Events:  []string{config.ExecEventServerChange, config.ExecEventIPChange},
+			URL:     ts.URL,
+			Method:  http.MethodPatch,
+			Body:    `{{.Event}} {{.InstanceName}} {{.Server}}:{{.Port}}{{range .Changes}} {{.Field}}={{.New}}{{end}}{{if .NewIP}}{{.OldIP}}->{{.NewIP}}{{end}}`,
+			Servers: []string{"main"},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Fire(Event{Event: config.ExecEventSyncSuccess, Server: "main", Port: 2302})
+	r.Fire(Event{Event: config.ExecEventServerChange, Server: "other", Port: 2402})
+	r.Fire(Event{Event: config.ExecEventServerChange, Server: "main", Port: 2302, Changes: []model.Change{{Field: "version", Old: "1.25", New: "1.26"}}})
+	r.Wait()
+	r.Fire(Event{Event: config.ExecEventIPChange, OldIP: "203.0.113.1", NewIP: "203.0.113.2"})
+	r.Wait()
+	close(bodies)
+
+	var got []string
+	for b := range bodies {
+		got = append(got, b)
+	}
+	want := []string{"PATCH server_change eu-1 main:2302 version=1.26", "PATCH ip_change eu-1 :0203.0.113.1->203.0.113.2"}
+	if !slices.Equal(got, want) {
+		t.Errorf("webhook requests = %q, want %q", got, want)
+	}
+
+	if _, err := New(Options{Webhooks: []config.Webhook{{Name: "bad", Events: []string{config.ExecEventIPChange}, URL: ts.URL, Body: "{{.Server"}}}); err == nil {
+		t.Error("New() with a body that does not parse: error = nil")
+	}
+}
+
+func TestRunnerInactive(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New(Options{
+		Hooks:  []config.ExecHook{{Name: "touch", Events: []string{config.ExecEventServerOffline}, Command: "touch " + filepath.Join(dir, "ran")}},
+		Active: func() bool { return false },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Fire(Event{Event: config.ExecEventServerOffline, Server: "main", Port: 2302})
+	r.Wait()
+	if _, err := os.Stat(filepath.Join(dir, "ran")); err == nil {
+		t.Error("hook ran on an inactive instance")
+	}
+
+	var nilRunner *Runner
+	nilRunner.Fire(Event{Event: config.ExecEventServerOffline})
+	nilRunner.Wait()
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	b := &limitedBuffer{max: 4}
+	_, _ = b.Write([]byte("ab"))
+	_, _ = b.Write([]byte("cdef"))
+	if got := b.String(); got != "abcd... (truncated)" {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -0,0 +1,152 @@
+// Package feed writes the current server store snapshot to a file whenever it changes, for static
+// websites and game panels that read from disk instead of calling the HTTP API.
+package feed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+	"go.uber.org/zap"
+)
+
+// Snapshot is the data passed to the feed template. Without a template it is written as indented JSON.
+type Snapshot struct {
+	// InstanceName is the configured instance_name, if any.
+	InstanceName string                `json:"instance_name,omitempty"`
+	GeneratedAt  time.Time             `json:"generated_at"`
+	Servers      []servers.ServerEntry `json:"servers"`
+}
+
+// Options configures a Writer.
+type Options struct {
+	Logger *zap.Logger
+	Store  *servers.Store
+	// Path is the output file. It is replaced atomically on every write.
+	Path string
+	// Template renders the Snapshot. Nil writes the Snapshot as JSON.
+	Template *template.Template
+	// InstanceName is copied into every Snapshot.
+	InstanceName string
+}
+
+// Writer keeps the feed file in sync with the store.
+type Writer struct {
+	logger *zap.Logger
+	store  *servers.Store
+	path   string
+	tmpl   *template.Template
+	// instance is the configured instance_name.
+	instance string
+}
+
+// New returns a feed Writer.
+func New(opts Options) *Writer {
+	return &Writer{
+		logger: opts.Logger,
+		store:  opts.Store,
+		path:   opts.Path,
+		tmpl:   opts.Template,
+
+		instance: opts.InstanceName,
+	}
+}
+
+// ParseTemplate parses a text/template file for use as a feed template. The template may call the
+// json function to embed a value as JSON (e.g. {{ json .Servers }}).
+func ParseTemplate(path string) (*template.Template, error) {
+	tmpl, err := template.New(filepath.Base(path)).Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).ParseFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("parse feed template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Run writes the feed once and then after every store change until ctx is cancelled.
+func (w *Writer) Run(ctx context.Context) {
+	changes, unsubscribe := w.store.Subscribe()
+	defer unsubscribe()
+
+	w.writeLogged()
+	for {
+		select {
+		case <-changes:
+			w.writeLogged()
+		case <-ctx.Done():
+			w.logger.Info("feed writer shutting down")
+			return
+		}
+	}
+}
+
+func (w *Writer) writeLogged() {
+	if err := w.Write(); err != nil {
+		w.logger.Error("write feed", zap.String("path", w.path), zap.Error(err))
+	}
+}
+
+// Write renders the current snapshot and atomically replaces the feed file.
+func (w *Writer) Write() error {
+	snapshot := Snapshot{
+		InstanceName: w.instance,
+		GeneratedAt:  time.Now().UTC(),
+		Servers:      w.store.GetAll(),
+	}
+	if snapshot.Servers == nil {
+		snapshot.Servers = []servers.ServerEntry{}
+	}
+
+	var buf bytes.Buffer
+	if w.tmpl != nil {
+		if err := w.tmpl.Execute(&buf, snapshot); err != nil {
+			return fmt.Errorf("execute template: %w", err)
+		}
+	} else {
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(snapshot); err != nil {
+			return fmt.Errorf("encode snapshot: %w", err)
+		}
+	}
+	return writeAtomic(w.path, buf.Bytes())
+}
+
+// writeAtomic writes data to a temporary file in the same directory and renames it over path, so
+// readers never observe a partially written file.
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write temp file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil { // #nosec G302 -- feed is meant to be world readable
+		return fmt.Errorf("chmod temp file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("rename temp file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+	"github.com/jsirianni/dzsa-sync/model"
+	"go.uber.org/zap"
+)
+
+func TestWriter_Write(t *testing.T) {
+	store := servers.New([]int{2424})
+	store.Set(2424, &model.Result{Name: "main", Players: 12})
+	path := filepath.Join(t.TempDir(), "servers.json")
+
+	w := New(Options{Logger: zap.NewNop(), Store: store, Path: path})
+	if err := w.Write(); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Snapshot
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unmarshal feed: %v", err)
+	}
+	if len(got.Servers) != 1 || got.Servers[0].Result.Players != 12 {
+		t.Errorf("feed = %+v", got)
+	}
+}
+
+func TestWriter_Template(t *testing.T) {
+	dir := t.TempDir()
+	tmplPath := filepath.Join(dir, "feed.tmpl")
+	if err := os.WriteFile(tmplPath, []byte(`{{ range .Servers }}{{ .Result.Name }}={{ .Result.Players }};{{ end }}{{ json (len .Servers) }}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := ParseTemplate(tmplPath)
+	if err != nil {
+		t.Fatalf("ParseTemplate() error = %v", err)
+	}
+	store := servers.New([]int{2424, 2324})
+	store.Set(2424, &model.Result{Name: "main", Players: 12})
+	store.Set(2324, &model.Result{Name: "modded", Players: 3})
+	path := filepath.Join(dir, "servers.txt")
+
+	w := New(Options{Logger: zap.NewNop(), Store: store, Path: path, Template: tmpl})
+	if err := w.Write(); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "modded=3;main=12;2"; string(b) != want {
+		t.Errorf("feed = %q, want %q", b, want)
+	}
+}
+
+func TestWriter_Run(t *testing.T) {
+	store := servers.New([]int{2424})
+	path := filepath.Join(t.TempDir(), "servers.json")
+	w := New(Options{Logger: zap.NewNop(), Store: store, Path: path})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Wait for the initial write so the subscription is in place before the change.
+	waitFor(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	})
+	store.Set(2424, &model.Result{Name: "main", Players: 7})
+	waitFor(t, func() bool {
+		b, _ := os.ReadFile(path)
+		return strings.Contains(string(b), `"players": 7`)
+	})
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("condition not met before deadline")
+}
@@ -0,0 +1,71 @@
+package history
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// Bucket summarizes one server's syncs in one hour or day.
+type Bucket struct {
+	// Time is the start of the bucket in UTC.
+	Time   time.Time `json:"time"`
+	Server string    `json:"server"`
+	Port   int       `json:"port"`
+	// Syncs is the number of sync attempts in the bucket, and Failed those that failed.
+	Syncs  int `json:"syncs"`
+	Failed int `json:"failed"`
+	// UptimePercent is the share of syncs that succeeded.
+	UptimePercent float64 `json:"uptime_percent"`
+	// AvgPlayers is the average player count of the successful syncs, and PeakPlayers the highest.
+	AvgPlayers  float64 `json:"avg_players"`
+	PeakPlayers int     `json:"peak_players"`
+}
+
+// Aggregate groups records into buckets of step (time.Hour or 24*time.Hour) aligned to UTC, per port, weighting
+// hourly aggregates by the syncs they stand for. The buckets are ordered by port, then time.
+func Aggregate(records []Record, step time.Duration) []Bucket {
+	type key struct {
+		port int
+		t    time.Time
+	}
+	type sums struct {
+		bucket  Bucket
+		online  int
+		players int
+	}
+	byKey := make(map[key]*sums)
+	for _, r := range records {
+		k := key{port: r.Port, t: r.Time.UTC().Truncate(step)}
+		s, ok := byKey[k]
+		if !ok {
+			s = &sums{bucket: Bucket{Time: k.t, Port: r.Port}}
+			byKey[k] = s
+		}
+		// The latest record names the server, which may have been renamed.
+		s.bucket.Server = r.Server
+		n := r.OnlineSyncs()
+		s.bucket.Syncs += r.Syncs()
+		s.bucket.Failed += r.Syncs() - n
+		if n == 0 {
+			continue
+		}
+		s.online += n
+		s.players += r.Players * n
+		s.bucket.PeakPlayers = max(s.bucket.PeakPlayers, r.Peak())
+	}
+
+	out := make([]Bucket, 0, len(byKey))
+	for _, s := range byKey {
+		b := s.bucket
+		b.UptimePercent = float64(s.online) * 100 / float64(b.Syncs)
+		if s.online > 0 {
+			b.AvgPlayers = float64(s.players) / float64(s.online)
+		}
+		out = append(out, b)
+	}
+	slices.SortFunc(out, func(a, b Bucket) int {
+		return cmp.Or(cmp.Compare(a.Port, b.Port), a.Time.Compare(b.Time))
+	})
+	return out
+}
@@ -0,0 +1,245 @@
+// Package history records every sync result to optional long-term sinks (PostgreSQL, embedded SQLite).
+// Stores keep raw records for a retention period, then compact them into hourly aggregates, which are
+// kept for a longer one.
+package history
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/model"
+	"go.uber.org/zap"
+)
+
+// Record is a single sync observation for one server, or an hourly aggregate of several.
+type Record struct {
+	Time       time.Time `json:"time"`
+	Server     string    `json:"server"`
+	Port       int       `json:"port"`
+	Online     bool      `json:"online"`
+	Players    int       `json:"players"`
+	MaxPlayers int       `json:"max_players"`
+	Version    string    `json:"version,omitempty"`
+	Map        string    `json:"map,omitempty"`
+	ModsHash   string    `json:"mods_hash,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	// Samples is set on an hourly aggregate, whose Time is the start of the hour, to the number of syncs it
+	// stands for. Players is then their average while online, Online is true when any succeeded, and the
+	// other fields are those of the last successful one, or of the last one when none succeeded.
+	Samples int `json:"samples,omitempty"`
+	// Failed is the number of the aggregated syncs that failed.
+	Failed int `json:"failed,omitempty"`
+	// PeakPlayers is the highest player count of the aggregated syncs.
+	PeakPlayers int `json:"peak_players,omitempty"`
+}
+
+// Syncs returns the number of syncs r stands for: Samples for an aggregate, else 1.
+func (r Record) Syncs() int {
+	if r.Samples > 0 {
+		return r.Samples
+	}
+	return 1
+}
+
+// OnlineSyncs returns the number of successful syncs r stands for.
+func (r Record) OnlineSyncs() int {
+	if r.Samples > 0 {
+		return r.Samples - r.Failed
+	}
+	if r.Online {
+		return 1
+	}
+	return 0
+}
+
+// Peak returns the highest player count of the syncs r stands for.
+func (r Record) Peak() int {
+	if r.Samples > 0 {
+		return r.PeakPlayers
+	}
+	return r.Players
+}
+
+// Sink stores sync records. Implementations must be safe for concurrent use.
+type Sink interface {
+	Write(ctx context.Context, r Record) error
+	Close() error
+}
+
+// DefaultQueryLimit is the maximum number of records returned by a query when Query.Limit is zero.
+const DefaultQueryLimit = 10000
+
+// Query selects records in [From, To], optionally for a single port.
+type Query struct {
+	// Port limits results to one server port. Zero means all ports.
+	Port int
+	From time.Time
+	To   time.Time
+	// Limit caps the number of records returned. Zero uses DefaultQueryLimit.
+	Limit int
+}
+
+func (q Query) limit() int {
+	if q.Limit <= 0 {
+		return DefaultQueryLimit
+	}
+	return q.Limit
+}
+
+// Reader queries stored records.
+type Reader interface {
+	Query(ctx context.Context, q Query) ([]Record, error)
+}
+
+// Retention is how long a store keeps records.
+type Retention struct {
+	// Raw is how long raw records are kept. Zero keeps them forever.
+	Raw time.Duration
+	// Hourly is how long hourly aggregates are kept. When it is not longer than Raw, raw records are deleted
+	// instead of compacted.
+	Hourly time.Duration
+}
+
+// CompactResult reports what a compaction changed.
+type CompactResult struct {
+	// Compacted is the number of raw records folded into aggregates, and Aggregates the number of aggregates
+	// written.
+	Compacted, Aggregates int64
+	// Deleted is the number of raw records and aggregates deleted.
+	Deleted int64
+}
+
+// Compactor is a store that compacts and prunes its records.
+type Compactor interface {
+	// Compact folds raw records older than rawBefore into hourly aggregates when compact is true, or
+	// deletes them otherwise, and deletes aggregates older than hourlyBefore. Only whole hours are
+	// compacted.
+	Compact(ctx context.Context, rawBefore, hourlyBefore time.Time, compact bool) (CompactResult, error)
+}
+
+// RunRetention applies r to c at once and then every hour until ctx is cancelled. It does nothing when
+// r.Raw is zero.
+func RunRetention(ctx context.Context, logger *zap.Logger, c Compactor, r Retention) {
+	if r.Raw <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	run := func() {
+		now := time.Now()
+		compact := r.Hourly > r.Raw
+		res, err := c.Compact(ctx, now.Add(-r.Raw), now.Add(-max(r.Hourly, r.Raw)), compact)
+		if err != nil {
+			logger.Error("compact sync history", zap.Error(err))
+			return
+		}
+		if res != (CompactResult{}) {
+			logger.Info("compacted sync history",
+				zap.Int64("compacted", res.Compacted),
+				zap.Int64("aggregates", res.Aggregates),
+				zap.Int64("deleted", res.Deleted))
+		}
+	}
+	run()
+	for {
+		select {
+		case <-ticker.C:
+			run()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Multi returns a Sink that writes each record to all sinks and closes all of them on Close.
+func Multi(sinks ...Sink) Sink {
+	if len(sinks) == 1 {
+		return sinks[0]
+	}
+	return multiSink(sinks)
+}
+
+type multiSink []Sink
+
+func (m multiSink) Write(ctx context.Context, r Record) error {
+	var errs []error
+	for _, s := range m {
+		if err := s.Write(ctx, r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m multiSink) Close() error {
+	var errs []error
+	for _, s := range m {
+		if err := s.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Redact returns a Sink that passes each record's error through redact before writing it to s, e.g. to
+// remove IP addresses.
+func Redact(s Sink, redact func(string) string) Sink {
+	return redactSink{Sink: s, redact: redact}
+}
+
+type redactSink struct {
+	Sink
+	redact func(string) string
+}
+
+func (s redactSink) Write(ctx context.Context, r Record) error {
+	r.Error = s.redact(r.Error)
+	return s.Sink.Write(ctx, r)
+}
+
+// NewRecord returns a record for a sync of server/port at t. A nil result with a non-nil err
+// is recorded as offline.
+func NewRecord(t time.Time, server string, port int, result *model.Result, err error) Record {
+	r := Record{
+		Time:   t.UTC(),
+		Server: server,
+		Port:   port,
+	}
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	if result != nil {
+		r.Online = true
+		r.Players = result.Players
+		r.MaxPlayers = result.MaxPlayers
+		r.Version = result.Version
+		r.Map = result.Map
+		r.ModsHash = ModsHash(result.Mods)
+	}
+	return r
+}
+
+// ModsHash returns a hex SHA-256 over the workshop IDs and names of mods, independent of order.
+// Returns an empty string when mods is empty.
+func ModsHash(mods []model.Mods) string {
+	if len(mods) == 0 {
+		return ""
+	}
+	keys := make([]string, len(mods))
+	for i, m := range mods {
+		keys[i] = strconv.Itoa(m.SteamWorkshopID) + ":" + m.Name
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
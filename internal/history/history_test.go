@@ -0,0 +1,73 @@
+package history
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/model"
+)
+
+func TestModsHash(t *testing.T) {
+	a := []model.Mods{{Name: "CF", SteamWorkshopID: 1559212036}, {Name: "Trader", SteamWorkshopID: 1590841260}}
+	b := []model.Mods{{Name: "Trader", SteamWorkshopID: 1590841260}, {Name: "CF", SteamWorkshopID: 1559212036}}
+	c := []model.Mods{{Name: "CF", SteamWorkshopID: 1559212036}}
+
+	if ModsHash(nil) != "" {
+		t.Error("ModsHash(nil) should be empty")
+	}
+	if ModsHash(a) != ModsHash(b) {
+		t.Error("ModsHash() should not depend on order")
+	}
+	if ModsHash(a) == ModsHash(c) {
+		t.Error("ModsHash() should differ for different mod lists")
+	}
+}
+
+func TestNewRecord(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("online", func(t *testing.T) {
+		r := NewRecord(now, "main", 2424, &model.Result{Players: 10, MaxPlayers: 60, Version: "1.25", Map: "chernarusplus"}, nil)
+		if !r.Online || r.Players != 10 || r.MaxPlayers != 60 || r.Version != "1.25" || r.Error != "" {
+			t.Errorf("NewRecord() = %+v", r)
+		}
+	})
+
+	t.Run("offline", func(t *testing.T) {
+		r := NewRecord(now, "main", 2424, nil, errors.New("timeout"))
+		if r.Online || r.Error != "timeout" {
+			t.Errorf("NewRecord() = %+v", r)
+		}
+	})
+}
+
+func TestAggregate(t *testing.T) {
+	hour := time.Date(2024, 1, 10, 20, 0, 0, 0, time.UTC)
+	records := []Record{
+		{Time: hour.Add(-2 * time.Hour), Server: "main", Port: 2424, Samples: 60, Failed: 10, Players: 12, PeakPlayers: 30, Online: true},
+		{Time: hour.Add(5 * time.Minute), Server: "main", Port: 2424, Online: true, Players: 40},
+		{Time: hour.Add(10 * time.Minute), Server: "modded", Port: 2524, Error: "timeout"},
+		{Time: hour.Add(15 * time.Minute), Server: "main", Port: 2424, Error: "timeout"},
+		{Time: hour.Add(20 * time.Minute), Server: "main", Port: 2424, Online: true, Players: 20},
+	}
+
+	hourly := Aggregate(records, time.Hour)
+	want := []Bucket{
+		{Time: hour.Add(-2 * time.Hour), Server: "main", Port: 2424, Syncs: 60, Failed: 10, UptimePercent: 50 * 100.0 / 60, AvgPlayers: 12, PeakPlayers: 30},
+		{Time: hour, Server: "main", Port: 2424, Syncs: 3, Failed: 1, UptimePercent: 2 * 100.0 / 3, AvgPlayers: 30, PeakPlayers: 40},
+		{Time: hour, Server: "modded", Port: 2524, Syncs: 1, Failed: 1},
+	}
+	if !slices.Equal(hourly, want) {
+		t.Errorf("Aggregate(hour) =\n%+v\nwant\n%+v", hourly, want)
+	}
+
+	daily := Aggregate(records, 24*time.Hour)
+	if len(daily) != 2 {
+		t.Fatalf("Aggregate(day) = %+v, want 2 buckets", daily)
+	}
+	if d := daily[0]; d.Time != hour.Truncate(24*time.Hour) || d.Syncs != 63 || d.Failed != 11 || d.PeakPlayers != 40 {
+		t.Errorf("Aggregate(day)[0] = %+v", d)
+	}
+}
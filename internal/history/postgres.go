@@ -0,0 +1,222 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	// Registers the "pgx" database/sql driver.
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// migration is a schema change applied once, in order, and recorded in schema_migrations.
+type migration struct {
+	version int
+	stmt    string
+}
+
+var postgresMigrations = []migration{
+	{
+		version: 1,
+		stmt: `CREATE TABLE IF NOT EXISTS sync_history (
+	time        TIMESTAMPTZ NOT NULL,
+	server      TEXT        NOT NULL,
+	port        INTEGER     NOT NULL,
+	online      BOOLEAN     NOT NULL,
+	players     INTEGER     NOT NULL,
+	max_players INTEGER     NOT NULL,
+	version     TEXT        NOT NULL,
+	map         TEXT        NOT NULL,
+	mods_hash   TEXT        NOT NULL,
+	error       TEXT        NOT NULL
+)`,
+	},
+	{
+		version: 2,
+		stmt:    `CREATE INDEX IF NOT EXISTS sync_history_port_time_idx ON sync_history (port, time DESC)`,
+	},
+	{
+		version: 3,
+		stmt: `ALTER TABLE sync_history
+	ADD COLUMN IF NOT EXISTS samples INTEGER NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS failed INTEGER NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS peak_players INTEGER NOT NULL DEFAULT 0`,
+	},
+}
+
+// postgresCompact is sqliteCompact for PostgreSQL. Hours are whole UTC hours, whatever the session time zone.
+const postgresCompact = `INSERT INTO sync_history (time, server, port, online, players, max_players, version, map, mods_hash, error, samples, failed, peak_players)
+SELECT hour, MAX(CASE WHEN rn = 1 THEN server END), port, bool_or(online),
+	ROUND(COALESCE(AVG(CASE WHEN online THEN players END), 0))::integer,
+	MAX(CASE WHEN rn = 1 THEN max_players END), MAX(CASE WHEN rn = 1 THEN version END),
+	MAX(CASE WHEN rn = 1 THEN map END), MAX(CASE WHEN rn = 1 THEN mods_hash END), MAX(CASE WHEN rn = 1 THEN error END),
+	COUNT(*), SUM(CASE WHEN online THEN 0 ELSE 1 END), MAX(CASE WHEN online THEN players ELSE 0 END)
+FROM (
+	SELECT *, to_timestamp(floor(extract(epoch FROM time) / 3600) * 3600) AS hour,
+		ROW_NUMBER() OVER (PARTITION BY port, floor(extract(epoch FROM time) / 3600) ORDER BY online DESC, time DESC) AS rn
+	FROM sync_history WHERE samples = 0 AND time < $1
+) AS raw GROUP BY port, hour`
+
+// Postgres writes records to a PostgreSQL (optionally TimescaleDB) table named sync_history.
+type Postgres struct {
+	db *sql.DB
+}
+
+// NewPostgres connects to dsn and applies pending schema migrations. When timescale is true,
+// sync_history is converted to a TimescaleDB hypertable (the extension must be available).
+func NewPostgres(ctx context.Context, dsn string, timescale bool) (*Postgres, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("ping: %w", err)
+	}
+	if err := migrate(ctx, db, postgresMigrations); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	if timescale {
+		if _, err := db.ExecContext(ctx, `CREATE EXTENSION IF NOT EXISTS timescaledb`); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("timescaledb extension: %w", err)
+		}
+		if _, err := db.ExecContext(ctx, `SELECT create_hypertable('sync_history', 'time', if_not_exists => TRUE, migrate_data => TRUE)`); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("create hypertable: %w", err)
+		}
+	}
+	return &Postgres{db: db}, nil
+}
+
+// Write inserts r into sync_history.
+func (p *Postgres) Write(ctx context.Context, r Record) error {
+	_, err := p.db.ExecContext(ctx,
+		`INSERT INTO sync_history (time, server, port, online, players, max_players, version, map, mods_hash, error)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		r.Time, r.Server, r.Port, r.Online, r.Players, r.MaxPlayers, r.Version, r.Map, r.ModsHash, r.Error)
+	if err != nil {
+		return fmt.Errorf("insert: %w", err)
+	}
+	return nil
+}
+
+// Query returns records matching q ordered by time.
+func (p *Postgres) Query(ctx context.Context, q Query) ([]Record, error) {
+	stmt := `SELECT time, server, port, online, players, max_players, version, map, mods_hash, error, samples, failed, peak_players
+FROM sync_history WHERE time >= $1 AND time <= $2`
+	args := []any{q.From, q.To}
+	if q.Port != 0 {
+		stmt += ` AND port = $3`
+		args = append(args, q.Port)
+	}
+	stmt += ` ORDER BY time, port LIMIT ` + strconv.Itoa(q.limit())
+
+	rows, err := p.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	defer rows.Close()
+	records := []Record{}
+	for rows.Next() {
+		var r Record
+		if err := rows.Scan(&r.Time, &r.Server, &r.Port, &r.Online, &r.Players, &r.MaxPlayers, &r.Version, &r.Map, &r.ModsHash, &r.Error,
+			&r.Samples, &r.Failed, &r.PeakPlayers); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		r.Time = r.Time.UTC()
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// Compact implements Compactor in one transaction.
+func (p *Postgres) Compact(ctx context.Context, rawBefore, hourlyBefore time.Time, compact bool) (CompactResult, error) {
+	return compactTx(ctx, p.db, postgresCompact, rawBefore.Truncate(time.Hour), hourlyBefore, compact, "$1")
+}
+
+// Close closes the database connection pool.
+func (p *Postgres) Close() error {
+	return p.db.Close()
+}
+
+// compactTx runs a compaction with the store's aggregate statement, which takes the raw cutoff as its only
+// argument, and placeholder for that argument in the delete statements.
+func compactTx(ctx context.Context, db *sql.DB, aggregate string, rawBefore, hourlyBefore any, compact bool, placeholder string) (CompactResult, error) {
+	var res CompactResult
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return res, fmt.Errorf("begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	if compact {
+		r, err := tx.ExecContext(ctx, aggregate, rawBefore)
+		if err != nil {
+			return res, fmt.Errorf("aggregate: %w", err)
+		}
+		if res.Aggregates, err = r.RowsAffected(); err != nil {
+			return res, fmt.Errorf("aggregate: %w", err)
+		}
+	}
+	r, err := tx.ExecContext(ctx, `DELETE FROM sync_history WHERE samples = 0 AND time < `+placeholder, rawBefore)
+	if err != nil {
+		return res, fmt.Errorf("delete raw: %w", err)
+	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		return res, fmt.Errorf("delete raw: %w", err)
+	}
+	if compact {
+		res.Compacted = n
+	} else {
+		res.Deleted = n
+	}
+	r, err = tx.ExecContext(ctx, `DELETE FROM sync_history WHERE samples > 0 AND time < `+placeholder, hourlyBefore)
+	if err != nil {
+		return res, fmt.Errorf("delete aggregates: %w", err)
+	}
+	if n, err = r.RowsAffected(); err != nil {
+		return res, fmt.Errorf("delete aggregates: %w", err)
+	}
+	res.Deleted += n
+	if err := tx.Commit(); err != nil {
+		return CompactResult{}, fmt.Errorf("commit: %w", err)
+	}
+	return res, nil
+}
+
+// migrate applies migrations newer than the highest version recorded in schema_migrations.
+func migrate(ctx context.Context, db *sql.DB, migrations []migration) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	var current int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("begin migration %d: %w", m.version, err)
+		}
+		if _, err := tx.ExecContext(ctx, m.stmt); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("apply migration %d: %w", m.version, err)
+		}
+		// version is a constant; formatting it avoids driver-specific placeholder syntax.
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES (`+strconv.Itoa(m.version)+`)`); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("record migration %d: %w", m.version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit migration %d: %w", m.version, err)
+		}
+	}
+	return nil
+}
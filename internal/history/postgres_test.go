@@ -0,0 +1,80 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPostgresDSN names the variable holding the DSN of a PostgreSQL database the tests may create schemas in.
+// The tests are skipped when it is unset.
+const testPostgresDSN = "DZSA_SYNC_TEST_POSTGRES_DSN"
+
+// postgresTestDSN creates an empty schema for the test, dropped when it ends, and returns a DSN whose
+// search_path is that schema.
+func postgresTestDSN(t *testing.T) string {
+	t.Helper()
+	dsn := os.Getenv(testPostgresDSN)
+	if dsn == "" {
+		t.Skipf("%s is not set", testPostgresDSN)
+	}
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	schema := fmt.Sprintf("dzsa_sync_test_%d", time.Now().UnixNano())
+	if _, err := db.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := db.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+			t.Errorf("drop schema: %v", err)
+		}
+	})
+
+	if !strings.Contains(dsn, "://") {
+		return dsn + " search_path=" + schema
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("parse %s: %v", testPostgresDSN, err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func newTestPostgres(t *testing.T) *Postgres {
+	t.Helper()
+	db, err := NewPostgres(context.Background(), postgresTestDSN(t), false)
+	if err != nil {
+		t.Fatalf("NewPostgres() error = %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestPostgres_WriteQuery(t *testing.T) {
+	testWriteQuery(t, newTestPostgres(t))
+}
+
+func TestPostgres_Migrate(t *testing.T) {
+	db, err := sql.Open("pgx", postgresTestDSN(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	testMigrate(t, db, postgresMigrations, &Postgres{db: db})
+}
+
+func TestPostgres_Compact(t *testing.T) {
+	testCompact(t, newTestPostgres(t))
+}
@@ -0,0 +1,206 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	// Registers the pure-Go "sqlite" database/sql driver.
+	_ "modernc.org/sqlite"
+)
+
+// Default SQLite retention: raw records for 7 days, hourly aggregates for 90.
+const (
+	DefaultRetention       = 7 * 24 * time.Hour
+	DefaultHourlyRetention = 90 * 24 * time.Hour
+)
+
+var sqliteMigrations = []migration{
+	{
+		version: 1,
+		stmt: `CREATE TABLE IF NOT EXISTS sync_history (
+	time        INTEGER NOT NULL,
+	server      TEXT    NOT NULL,
+	port        INTEGER NOT NULL,
+	online      INTEGER NOT NULL,
+	players     INTEGER NOT NULL,
+	max_players INTEGER NOT NULL,
+	version     TEXT    NOT NULL,
+	map         TEXT    NOT NULL,
+	mods_hash   TEXT    NOT NULL,
+	error       TEXT    NOT NULL
+)`,
+	},
+	{
+		version: 2,
+		stmt:    `CREATE INDEX IF NOT EXISTS sync_history_port_time_idx ON sync_history (port, time)`,
+	},
+	{
+		version: 3,
+		stmt: `ALTER TABLE sync_history ADD COLUMN samples INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE sync_history ADD COLUMN failed INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE sync_history ADD COLUMN peak_players INTEGER NOT NULL DEFAULT 0`,
+	},
+}
+
+// sqliteCompact inserts one aggregate per port and hour of the raw records before a time. The row numbered
+// 1 of each hour is its last successful sync, or its last sync when none succeeded.
+const sqliteCompact = `INSERT INTO sync_history (time, server, port, online, players, max_players, version, map, mods_hash, error, samples, failed, peak_players)
+SELECT hour, MAX(CASE WHEN rn = 1 THEN server END), port, MAX(online),
+	CAST(ROUND(COALESCE(AVG(CASE WHEN online THEN players END), 0)) AS INTEGER),
+	MAX(CASE WHEN rn = 1 THEN max_players END), MAX(CASE WHEN rn = 1 THEN version END),
+	MAX(CASE WHEN rn = 1 THEN map END), MAX(CASE WHEN rn = 1 THEN mods_hash END), MAX(CASE WHEN rn = 1 THEN error END),
+	COUNT(*), SUM(CASE WHEN online THEN 0 ELSE 1 END), MAX(CASE WHEN online THEN players ELSE 0 END)
+FROM (
+	SELECT *, time / 3600000 * 3600000 AS hour,
+		ROW_NUMBER() OVER (PARTITION BY port, time / 3600000 ORDER BY online DESC, time DESC) AS rn
+	FROM sync_history WHERE samples = 0 AND time < ?
+) GROUP BY port, hour`
+
+// SQLite stores records in an embedded SQLite database file. Times are stored as Unix milliseconds.
+type SQLite struct {
+	db *sql.DB
+}
+
+// NewSQLite opens (or creates) the database at path and applies pending schema migrations.
+func NewSQLite(ctx context.Context, path string) (*SQLite, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	// SQLite allows a single writer; serialize access through one connection.
+	db.SetMaxOpenConns(1)
+	if _, err := db.ExecContext(ctx, `PRAGMA journal_mode=WAL`); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("enable wal: %w", err)
+	}
+	if err := migrate(ctx, db, sqliteMigrations); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	return &SQLite{db: db}, nil
+}
+
+// Write inserts r into sync_history.
+func (s *SQLite) Write(ctx context.Context, r Record) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO sync_history (time, server, port, online, players, max_players, version, map, mods_hash, error)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.Time.UnixMilli(), r.Server, r.Port, r.Online, r.Players, r.MaxPlayers, r.Version, r.Map, r.ModsHash, r.Error)
+	if err != nil {
+		return fmt.Errorf("insert: %w", err)
+	}
+	return nil
+}
+
+// Query returns records matching q ordered by time.
+func (s *SQLite) Query(ctx context.Context, q Query) ([]Record, error) {
+	stmt := `SELECT time, server, port, online, players, max_players, version, map, mods_hash, error, samples, failed, peak_players
+FROM sync_history WHERE time >= ? AND time <= ?`
+	args := []any{q.From.UnixMilli(), q.To.UnixMilli()}
+	if q.Port != 0 {
+		stmt += ` AND port = ?`
+		args = append(args, q.Port)
+	}
+	stmt += ` ORDER BY time, port LIMIT ?`
+	args = append(args, q.limit())
+
+	rows, err := s.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	defer rows.Close()
+	records := []Record{}
+	for rows.Next() {
+		var r Record
+		var ms int64
+		if err := rows.Scan(&ms, &r.Server, &r.Port, &r.Online, &r.Players, &r.MaxPlayers, &r.Version, &r.Map, &r.ModsHash, &r.Error,
+			&r.Samples, &r.Failed, &r.PeakPlayers); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		r.Time = time.UnixMilli(ms).UTC()
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// Prune deletes records older than before and returns the number of deleted rows.
+func (s *SQLite) Prune(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM sync_history WHERE time < ?`, before.UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("delete: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// Compact implements Compactor in one transaction.
+func (s *SQLite) Compact(ctx context.Context, rawBefore, hourlyBefore time.Time, compact bool) (CompactResult, error) {
+	return compactTx(ctx, s.db, sqliteCompact, rawBefore.Truncate(time.Hour).UnixMilli(), hourlyBefore.UnixMilli(), compact, "?")
+}
+
+// SQLiteSchemaVersion is the schema version of the SQLite databases this build writes and can import.
+func SQLiteSchemaVersion() int {
+	return sqliteMigrations[len(sqliteMigrations)-1].version
+}
+
+// Backup writes a consistent copy of the database to path, which must not exist.
+func (s *SQLite) Backup(ctx context.Context, path string) error {
+	if _, err := s.db.ExecContext(ctx, `VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("vacuum into %s: %w", path, err)
+	}
+	return nil
+}
+
+// Import copies the records of the SQLite database at path that are older than every record already
+// stored, so importing the same backup twice adds nothing. It fails when the database was written by a
+// newer schema than SQLiteSchemaVersion.
+func (s *SQLite) Import(ctx context.Context, path string) (int64, error) {
+	// ATTACH is per connection, so keep every statement on one.
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("connection: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `ATTACH DATABASE ? AS src`, path); err != nil {
+		return 0, fmt.Errorf("attach %s: %w", path, err)
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.Background(), `DETACH DATABASE src`)
+	}()
+
+	var version int
+	if err := conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM src.schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	if version > SQLiteSchemaVersion() {
+		return 0, fmt.Errorf("history schema version %d is newer than this build's %d", version, SQLiteSchemaVersion())
+	}
+	var oldest sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT MIN(time) FROM main.sync_history`).Scan(&oldest); err != nil {
+		return 0, fmt.Errorf("read oldest record: %w", err)
+	}
+	cutoff := int64(math.MaxInt64)
+	if oldest.Valid {
+		cutoff = oldest.Int64
+	}
+	// Databases from before schema version 3 have no aggregates.
+	aggregate := "samples, failed, peak_players"
+	if version < 3 {
+		aggregate = "0, 0, 0"
+	}
+	res, err := conn.ExecContext(ctx,
+		`INSERT INTO main.sync_history (time, server, port, online, players, max_players, version, map, mods_hash, error, samples, failed, peak_players)
+SELECT time, server, port, online, players, max_players, version, map, mods_hash, error, `+aggregate+` FROM src.sync_history WHERE time < ?`,
+		cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("copy records: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// Close closes the database.
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
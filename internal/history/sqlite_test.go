@@ -0,0 +1,111 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLite_WriteQueryPrune(t *testing.T) {
+	ctx := context.Background()
+	db, err := NewSQLite(ctx, filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite() error = %v", err)
+	}
+	defer db.Close()
+	testWriteQuery(t, db)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	n, err := db.Prune(ctx, base.Add(30*time.Minute))
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Prune() deleted %d, want 1", n)
+	}
+	got, err := db.Query(ctx, Query{From: base, To: base.Add(4 * time.Hour)})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(got) != 3 {
+		t.Errorf("Query() after prune returned %d records, want 3", len(got))
+	}
+}
+
+func TestSQLite_Migrate(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	testMigrate(t, db, sqliteMigrations, &SQLite{db: db})
+}
+
+func TestSQLite_BackupImport(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	src, err := NewSQLite(ctx, filepath.Join(dir, "src.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite() error = %v", err)
+	}
+	defer src.Close()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 3 {
+		if err := src.Write(ctx, Record{Time: base.Add(time.Duration(i) * time.Hour), Server: "main", Port: 2424, Online: true, Players: i}); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	backup := filepath.Join(dir, "backup.db")
+	if err := src.Backup(ctx, backup); err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+
+	// The target already has a record from the last hour of the backup, so only older ones are imported.
+	dst, err := NewSQLite(ctx, filepath.Join(dir, "dst.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite() error = %v", err)
+	}
+	defer dst.Close()
+	if err := dst.Write(ctx, Record{Time: base.Add(2 * time.Hour), Server: "main", Port: 2424, Online: true, Players: 9}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	for i, want := range []int64{2, 0} {
+		n, err := dst.Import(ctx, backup)
+		if err != nil {
+			t.Fatalf("Import() #%d error = %v", i, err)
+		}
+		if n != want {
+			t.Errorf("Import() #%d = %d records, want %d", i, n, want)
+		}
+	}
+	got, err := dst.Query(ctx, Query{From: base, To: base.Add(3 * time.Hour)})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(got) != 3 || got[0].Players != 0 || got[2].Players != 9 {
+		t.Errorf("Query() after import = %+v", got)
+	}
+
+	if _, err := src.db.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES (99)`); err != nil {
+		t.Fatal(err)
+	}
+	newer := filepath.Join(dir, "newer.db")
+	if err := src.Backup(ctx, newer); err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	if _, err := dst.Import(ctx, newer); err == nil {
+		t.Error("Import() of a newer schema succeeded, want error")
+	}
+}
+
+func TestSQLite_Compact(t *testing.T) {
+	db, err := NewSQLite(context.Background(), filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite() error = %v", err)
+	}
+	defer db.Close()
+	testCompact(t, db)
+}
@@ -0,0 +1,159 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+// The tests below run against every store, so SQLite and PostgreSQL keep the same schema, range query, and
+// compaction semantics.
+
+// testStore is a history store under test.
+type testStore interface {
+	Sink
+	Reader
+	Compactor
+}
+
+// testWriteQuery writes records of two servers and checks range queries by port and time.
+func testWriteQuery(t *testing.T, s testStore) {
+	t.Helper()
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	records := []Record{
+		{Time: base, Server: "main", Port: 2424, Online: true, Players: 5, MaxPlayers: 60},
+		{Time: base.Add(time.Hour), Server: "main", Port: 2424, Online: false, Error: "timeout"},
+		{Time: base.Add(time.Hour), Server: "modded", Port: 2324, Online: true, Players: 12, MaxPlayers: 40},
+		// Outside the queried range.
+		{Time: base.Add(3 * time.Hour), Server: "main", Port: 2424, Online: true, Players: 7, MaxPlayers: 60},
+	}
+	for _, r := range records {
+		if err := s.Write(ctx, r); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	got, err := s.Query(ctx, Query{Port: 2424, From: base, To: base.Add(2 * time.Hour)})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Query() returned %d records, want 2", len(got))
+	}
+	if !got[0].Time.Equal(base) || got[0].Players != 5 || !got[0].Online {
+		t.Errorf("Query()[0] = %+v", got[0])
+	}
+	if got[1].Online || got[1].Error != "timeout" {
+		t.Errorf("Query()[1] = %+v", got[1])
+	}
+
+	got, err = s.Query(ctx, Query{From: base.Add(time.Hour), To: base.Add(2 * time.Hour)})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(got) != 2 || got[0].Port != 2324 || got[1].Port != 2424 {
+		t.Errorf("Query() of every port = %+v, want both servers' records at the same time ordered by port", got)
+	}
+	got, err = s.Query(ctx, Query{From: base, To: base.Add(4 * time.Hour), Limit: 1})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(got) != 1 || !got[0].Time.Equal(base) {
+		t.Errorf("Query() with limit 1 = %+v, want the oldest record", got)
+	}
+}
+
+// testCompact writes raw records and checks that compaction folds whole hours into aggregates, and later
+// deletes them.
+func testCompact(t *testing.T, s testStore) {
+	t.Helper()
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	records := []Record{
+		{Time: base.Add(5 * time.Minute), Server: "main", Port: 2424, Online: true, Players: 10, MaxPlayers: 60, Version: "1.25", Map: "chernarusplus"},
+		{Time: base.Add(15 * time.Minute), Server: "main", Port: 2424, Online: true, Players: 31, MaxPlayers: 60, Version: "1.26", Map: "chernarusplus"},
+		{Time: base.Add(25 * time.Minute), Server: "main", Port: 2424, Error: "timeout"},
+		{Time: base.Add(35 * time.Minute), Server: "modded", Port: 2324, Error: "refused"},
+		{Time: base.Add(45 * time.Minute), Server: "modded", Port: 2324, Error: "timeout"},
+		// In the hour of the cutoff, so kept raw.
+		{Time: base.Add(70 * time.Minute), Server: "main", Port: 2424, Online: true, Players: 20, MaxPlayers: 60},
+	}
+	for _, r := range records {
+		if err := s.Write(ctx, r); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	res, err := s.Compact(ctx, base.Add(90*time.Minute), base.Add(-24*time.Hour), true)
+	if err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+	if want := (CompactResult{Compacted: 5, Aggregates: 2}); res != want {
+		t.Errorf("Compact() = %+v, want %+v", res, want)
+	}
+	got, err := s.Query(ctx, Query{From: base, To: base.Add(2 * time.Hour)})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("Query() after compaction = %+v, want 2 aggregates and 1 raw record", got)
+	}
+	wantMain := Record{Time: base, Server: "main", Port: 2424, Online: true, Players: 21, MaxPlayers: 60, Version: "1.26", Map: "chernarusplus",
+		Samples: 3, Failed: 1, PeakPlayers: 31}
+	wantModded := Record{Time: base, Server: "modded", Port: 2324, Error: "timeout", Samples: 2, Failed: 2}
+	if got[0] != wantModded || got[1] != wantMain {
+		t.Errorf("aggregates = %+v, want %+v and %+v", got[:2], wantModded, wantMain)
+	}
+	if a := got[1]; a.Syncs() != 3 || a.OnlineSyncs() != 2 || a.Peak() != 31 {
+		t.Errorf("aggregate Syncs, OnlineSyncs, Peak = %d, %d, %d", a.Syncs(), a.OnlineSyncs(), a.Peak())
+	}
+	if r := got[2]; r.Samples != 0 || r.Syncs() != 1 || r.OnlineSyncs() != 1 || r.Peak() != 20 {
+		t.Errorf("raw record = %+v", r)
+	}
+
+	// Aggregates are deleted after their own retention, and raw records are deleted when not compacted.
+	res, err = s.Compact(ctx, base.Add(3*time.Hour), base.Add(30*time.Minute), false)
+	if err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+	if want := (CompactResult{Deleted: 3}); res != want {
+		t.Errorf("Compact() = %+v, want %+v", res, want)
+	}
+}
+
+// testMigrate migrates the empty database db to the first version, writes a record through s, which must
+// use db, and checks that the remaining migrations keep it as a raw record and that each version is recorded
+// once, also when migrating again.
+func testMigrate(t *testing.T, db *sql.DB, migrations []migration, s testStore) {
+	t.Helper()
+	ctx := context.Background()
+	if err := migrate(ctx, db, migrations[:1]); err != nil {
+		t.Fatalf("migrate() to version 1 error = %v", err)
+	}
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := s.Write(ctx, Record{Time: at, Server: "main", Port: 2424, Online: true, Players: 5, MaxPlayers: 60}); err != nil {
+		t.Fatalf("Write() at version 1 error = %v", err)
+	}
+	for range 2 {
+		if err := migrate(ctx, db, migrations); err != nil {
+			t.Fatalf("migrate() error = %v", err)
+		}
+	}
+
+	var n, latest int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*), MAX(version) FROM schema_migrations`).Scan(&n, &latest); err != nil {
+		t.Fatal(err)
+	}
+	if want := migrations[len(migrations)-1].version; n != len(migrations) || latest != want {
+		t.Errorf("schema_migrations has %d versions up to %d, want %d up to %d", n, latest, len(migrations), want)
+	}
+	got, err := s.Query(ctx, Query{From: at, To: at})
+	if err != nil {
+		t.Fatalf("Query() after migrating error = %v", err)
+	}
+	if len(got) != 1 || got[0].Players != 5 || got[0].Samples != 0 || got[0].Syncs() != 1 {
+		t.Errorf("record written before migrating = %+v, want a raw record", got)
+	}
+}
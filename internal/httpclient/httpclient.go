@@ -0,0 +1,200 @@
+// Package httpclient builds the HTTP client shared by every outbound integration (DZSA, ifconfig.net,
+// Steam, remote discovery, remote_write), so a sync-all burst reuses pooled connections instead of
+// dialing and handshaking once per server.
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/dnscache"
+)
+
+// Defaults used when an Options field is zero.
+const (
+	DefaultTimeout             = 60 * time.Second
+	DefaultDialTimeout         = 10 * time.Second
+	DefaultKeepAlive           = 30 * time.Second
+	DefaultMaxIdleConns        = 100
+	DefaultMaxIdleConnsPerHost = 32
+	DefaultIdleConnTimeout     = 90 * time.Second
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+	DefaultTLSSessionCacheSize = 64
+)
+
+// PinPrefix starts every public key pin: the base64 SHA-256 of a certificate's SubjectPublicKeyInfo,
+// as printed by "openssl x509 -pubkey | openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64".
+const PinPrefix = "sha256/"
+
+// Options tunes the shared client. Zero values use the defaults above.
+type Options struct {
+	// Timeout bounds each request, including reading the body.
+	Timeout time.Duration
+	// DialTimeout bounds establishing a TCP connection.
+	DialTimeout time.Duration
+	// MaxIdleConns limits idle connections across all hosts.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost limits idle connections kept per host. The standard library default of 2 forces
+	// most requests of a burst to the same host to open a new connection.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits connections per host, including active ones. Zero means no limit.
+	MaxConnsPerHost int
+	// IdleConnTimeout is how long an idle connection is kept.
+	IdleConnTimeout time.Duration
+	// TLSSessionCacheSize is the number of TLS sessions cached for resumption.
+	TLSSessionCacheSize int
+	// DisableHTTP2 keeps connections on HTTP/1.1.
+	DisableHTTP2 bool
+	// Resolver resolves hostnames for new connections. Nil uses the system resolver on every dial.
+	Resolver *dnscache.Resolver
+	// Network restricts connections to one address family, "tcp4" or "tcp6", e.g. to detect the host's IPv4
+	// and IPv6 addresses separately. Empty dials either.
+	Network string
+	// RootCAs verifies server certificates. Nil uses the system roots.
+	RootCAs *x509.CertPool
+	// Pins maps a hostname to public key pins (see PinPrefix). A connection to a pinned host fails unless
+	// a certificate in its verified chain has one of the pinned keys.
+	Pins map[string][]string
+}
+
+// New returns a client with a pooled, tuned Transport.
+func New(opts Options) *http.Client {
+	return &http.Client{
+		Timeout:   orDefault(opts.Timeout, DefaultTimeout),
+		Transport: NewTransport(opts),
+	}
+}
+
+// NewTransport returns the Transport used by New, for callers that set their own client timeout.
+func NewTransport(opts Options) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   orDefault(opts.DialTimeout, DefaultDialTimeout),
+		KeepAlive: DefaultKeepAlive,
+	}
+	dial := dialer.DialContext
+	if opts.Resolver != nil {
+		dial = opts.Resolver.Dial(dialer)
+	}
+	if opts.Network != "" {
+		inner := dial
+		dial = func(ctx context.Context, _, address string) (net.Conn, error) {
+			return inner(ctx, opts.Network, address)
+		}
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		RootCAs:            opts.RootCAs,
+		ClientSessionCache: tls.NewLRUClientSessionCache(orDefault(opts.TLSSessionCacheSize, DefaultTLSSessionCacheSize)),
+	}
+	if len(opts.Pins) > 0 {
+		tlsConfig.VerifyConnection = verifyPins(opts.Pins)
+	}
+	t := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dial,
+		MaxIdleConns:        orDefault(opts.MaxIdleConns, DefaultMaxIdleConns),
+		MaxIdleConnsPerHost: orDefault(opts.MaxIdleConnsPerHost, DefaultMaxIdleConnsPerHost),
+		MaxConnsPerHost:     opts.MaxConnsPerHost,
+		IdleConnTimeout:     orDefault(opts.IdleConnTimeout, DefaultIdleConnTimeout),
+		TLSHandshakeTimeout: DefaultTLSHandshakeTimeout,
+		TLSClientConfig:     tlsConfig,
+		// A custom DialContext or TLSClientConfig disables HTTP/2 unless it is requested explicitly.
+		ForceAttemptHTTP2:     !opts.DisableHTTP2,
+		ExpectContinueTimeout: time.Second,
+	}
+	if opts.DisableHTTP2 {
+		// A non-nil, empty TLSNextProto map is how the standard library turns HTTP/2 off.
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return t
+}
+
+// LoadRootCAs returns the system roots plus the PEM certificates in path, e.g. the root of a
+// TLS-intercepting proxy.
+func LoadRootCAs(path string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(path) // #nosec G304 -- operator-configured CA bundle
+	if err != nil {
+		return nil, fmt.Errorf("read CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("CA bundle %s contains no PEM certificates", path)
+	}
+	return pool, nil
+}
+
+// ParsePin decodes a public key pin and returns the SHA-256 digest it carries.
+func ParsePin(pin string) ([]byte, error) {
+	enc, ok := strings.CutPrefix(pin, PinPrefix)
+	if !ok {
+		return nil, fmt.Errorf("pin %q must start with %q", pin, PinPrefix)
+	}
+	digest, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil || len(digest) != sha256.Size {
+		return nil, fmt.Errorf("pin %q is not a base64 SHA-256 digest", pin)
+	}
+	return digest, nil
+}
+
+// Pin returns the public key pin of cert.
+func Pin(cert *x509.Certificate) string {
+	digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return PinPrefix + base64.StdEncoding.EncodeToString(digest[:])
+}
+
+var errPinMismatch = errors.New("no certificate matches the pinned public keys")
+
+// verifyPins returns a tls.Config.VerifyConnection function that checks the verified chain of pinned hosts.
+// Invalid pins never match.
+func verifyPins(pins map[string][]string) func(tls.ConnectionState) error {
+	digests := make(map[string][][]byte, len(pins))
+	for host, hostPins := range pins {
+		key := strings.ToLower(host)
+		list := digests[key]
+		for _, pin := range hostPins {
+			if d, err := ParsePin(pin); err == nil {
+				list = append(list, d)
+			}
+		}
+		// Stored even when empty, so a host whose pins are all invalid fails closed.
+		digests[key] = list
+	}
+	return func(cs tls.ConnectionState) error {
+		want, ok := digests[strings.ToLower(cs.ServerName)]
+		if !ok {
+			return nil
+		}
+		for _, chain := range cs.VerifiedChains {
+			for _, cert := range chain {
+				got := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+				for _, d := range want {
+					if bytes.Equal(got[:], d) {
+						return nil
+					}
+				}
+			}
+		}
+		return fmt.Errorf("%s: %w", cs.ServerName, errPinMismatch)
+	}
+}
+
+func orDefault[T int | time.Duration](v, def T) T {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,166 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/dnscache"
+)
+
+func TestNewDefaults(t *testing.T) {
+	c := New(Options{})
+	if c.Timeout != DefaultTimeout {
+		t.Errorf("Timeout = %v, want %v", c.Timeout, DefaultTimeout)
+	}
+	tr := c.Transport.(*http.Transport)
+	if tr.MaxIdleConnsPerHost != DefaultMaxIdleConnsPerHost || tr.MaxIdleConns != DefaultMaxIdleConns {
+		t.Errorf("idle pool = %d/%d, want %d/%d", tr.MaxIdleConnsPerHost, tr.MaxIdleConns, DefaultMaxIdleConnsPerHost, DefaultMaxIdleConns)
+	}
+	if !tr.ForceAttemptHTTP2 || tr.TLSNextProto != nil {
+		t.Error("HTTP/2 is not enabled by default")
+	}
+	if tr.TLSClientConfig.ClientSessionCache == nil {
+		t.Error("TLS session cache is not set")
+	}
+
+	tr = NewTransport(Options{MaxIdleConnsPerHost: 4, MaxConnsPerHost: 8, DisableHTTP2: true})
+	if tr.MaxIdleConnsPerHost != 4 || tr.MaxConnsPerHost != 8 {
+		t.Errorf("pool = %d/%d, want 4/8", tr.MaxIdleConnsPerHost, tr.MaxConnsPerHost)
+	}
+	if tr.ForceAttemptHTTP2 || tr.TLSNextProto == nil {
+		t.Error("DisableHTTP2 did not disable HTTP/2")
+	}
+}
+
+func TestConnectionReuse(t *testing.T) {
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		fmt.Fprint(w, "ok")
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	c := New(Options{MaxConnsPerHost: 8})
+	// Two bursts: the second must reuse the pooled connections from the first.
+	for range 2 {
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := c.Get(srv.URL)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				_, _ = io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}()
+		}
+		wg.Wait()
+	}
+	if n := conns.Load(); n > 8 {
+		t.Errorf("opened %d connections for 16 requests, want at most 8", n)
+	}
+}
+
+func TestNetwork(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer srv.Close()
+
+	resp, err := New(Options{Network: "tcp4"}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("tcp4 Get() error = %v", err)
+	}
+	resp.Body.Close()
+	// The test server listens on 127.0.0.1, which a tcp6 client must not reach.
+	if resp, err := New(Options{Network: "tcp6"}).Get(srv.URL); err == nil {
+		resp.Body.Close()
+		t.Error("tcp6 Get() of an IPv4 address: error = nil")
+	}
+}
+
+func TestRootCAsAndPins(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, pemBytes, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	roots, err := LoadRootCAs(caFile)
+	if err != nil {
+		t.Fatalf("LoadRootCAs() error = %v", err)
+	}
+	if _, err := New(Options{}).Get(srv.URL); err == nil {
+		t.Error("request succeeded without the CA bundle")
+	}
+
+	// The test certificate is valid for example.com, which resolves to the server.
+	host := "example.com"
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	url := "https://" + net.JoinHostPort(host, port)
+	resolver := dnscache.New(dnscache.Options{
+		Servers:  []string{},
+		Fallback: func(context.Context, string) ([]string, error) { return []string{"127.0.0.1"}, nil },
+	})
+	cases := []struct {
+		name    string
+		pins    map[string][]string
+		wantErr bool
+	}{
+		{name: "no pins"},
+		{name: "matching pin", pins: map[string][]string{host: {"sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=", Pin(srv.Certificate())}}},
+		{name: "other host pinned", pins: map[string][]string{"dayzsalauncher.com": {"sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}}},
+		{name: "mismatched pin", pins: map[string][]string{host: {"sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}}, wantErr: true},
+		{name: "invalid pins fail closed", pins: map[string][]string{host: {"not-a-pin"}}, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := New(Options{RootCAs: roots, Pins: tc.pins, Resolver: resolver}).Get(url)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("Get() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr && !errors.Is(err, errPinMismatch) {
+				t.Errorf("Get() error = %v, want a pin mismatch", err)
+			}
+		})
+	}
+}
+
+func TestParsePin(t *testing.T) {
+	if _, err := ParsePin("sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="); err != nil {
+		t.Errorf("ParsePin(valid) error = %v", err)
+	}
+	for _, pin := range []string{"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=", "sha256/AAAA", "sha1/AAAAAAAAAAAAAAAAAAAAAAAAAAA="} {
+		if _, err := ParsePin(pin); err == nil {
+			t.Errorf("ParsePin(%q) succeeded", pin)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package ifconfig
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// Flap damping defaults.
+const (
+	DefaultFlapWindow   = time.Hour
+	DefaultFlapChanges  = 3
+	DefaultFlapHoldDown = 30 * time.Minute
+)
+
+// DamperOptions configures a Damper.
+type DamperOptions struct {
+	// Logger logs flaps. Nil disables logging.
+	Logger *zap.Logger
+	// Window is how far back changes are counted. Zero uses DefaultFlapWindow.
+	Window time.Duration
+	// Changes is the number of changes within Window that counts as flapping. Zero uses DefaultFlapChanges.
+	Changes int
+	// HoldDown is how long the IP must stay unchanged before a flap ends. Zero uses DefaultFlapHoldDown.
+	HoldDown time.Duration
+	// OnChange is called for a change that is not held down, and once when a flap ends with a different IP
+	// than before it.
+	OnChange func(oldIP, newIP string)
+	// Recorder records external_ip_flapping when set.
+	Recorder metrics.IPFlapRecorder
+}
+
+// Damper passes external IP changes on, unless the IP flaps between values, e.g. with broken load-balanced
+// egress or dual-WAN failover. The IP flaps when it changes Changes times within Window, or changes back to
+// an IP it left within Window. While it flaps, changes are held down, so a flap costs one fleet resync
+// instead of one per change; the flap ends once the IP stays unchanged for HoldDown.
+type Damper struct {
+	opts DamperOptions
+
+	mu      sync.Mutex
+	changes []ipChange
+	// holding is true during a flap; stable is the IP before it and current the latest.
+	holding         bool
+	stable, current string
+	timer           *time.Timer
+	// gen invalidates the timers of earlier changes.
+	gen int
+}
+
+type ipChange struct {
+	at       time.Time
+	old, new string
+}
+
+// NewDamper returns a damper.
+func NewDamper(opts DamperOptions) *Damper {
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+	if opts.Window <= 0 {
+		opts.Window = DefaultFlapWindow
+	}
+	if opts.Changes <= 0 {
+		opts.Changes = DefaultFlapChanges
+	}
+	if opts.HoldDown <= 0 {
+		opts.HoldDown = DefaultFlapHoldDown
+	}
+	return &Damper{opts: opts}
+}
+
+// Changed handles a change of the external IP. It is meant as the onChanged callback of Client.Run.
+func (d *Damper) Changed(oldIP, newIP string) {
+	if d.changed(time.Now(), oldIP, newIP) && d.opts.OnChange != nil {
+		d.opts.OnChange(oldIP, newIP)
+	}
+}
+
+// changed records a change at now, starts or extends a flap, and returns true when the change is passed on.
+func (d *Damper) changed(now time.Time, oldIP, newIP string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.changes = slices.DeleteFunc(d.changes, func(c ipChange) bool { return now.Sub(c.at) > d.opts.Window })
+	returned := slices.ContainsFunc(d.changes, func(c ipChange) bool { return c.old == newIP })
+	d.changes = append(d.changes, ipChange{at: now, old: oldIP, new: newIP})
+	d.current = newIP
+	if !d.holding && !returned && len(d.changes) < d.opts.Changes {
+		return true
+	}
+	if !d.holding {
+		d.holding, d.stable = true, oldIP
+		d.opts.Logger.Error("external IP is flapping; holding down resyncs until it is stable",
+			zap.Int("changes", len(d.changes)),
+			zap.Duration("window", d.opts.Window),
+			zap.Strings("ips", d.ips()),
+			zap.Duration("hold_down", d.opts.HoldDown))
+		if d.opts.Recorder != nil {
+			d.opts.Recorder.RecordIPFlapping(context.Background(), true)
+		}
+	} else {
+		d.opts.Logger.Warn("external IP changed while flapping; resync held down",
+			zap.String("old_ip", oldIP), zap.String("new_ip", newIP))
+	}
+	d.gen++
+	gen := d.gen
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.timer = time.AfterFunc(d.opts.HoldDown, func() { d.release(gen) })
+	return false
+}
+
+// ips returns the distinct IPs of the changes in the window.
+func (d *Damper) ips() []string {
+	var out []string
+	for _, c := range d.changes {
+		for _, ip := range []string{c.old, c.new} {
+			if !slices.Contains(out, ip) {
+				out = append(out, ip)
+			}
+		}
+	}
+	return out
+}
+
+// release ends the flap started or extended by the change of generation gen, unless a later change
+// extended it again, and passes on the net change.
+func (d *Damper) release(gen int) {
+	d.mu.Lock()
+	if !d.holding || gen != d.gen {
+		d.mu.Unlock()
+		return
+	}
+	d.holding, d.timer = false, nil
+	stable, current := d.stable, d.current
+	d.mu.Unlock()
+
+	if d.opts.Recorder != nil {
+		d.opts.Recorder.RecordIPFlapping(context.Background(), false)
+	}
+	d.opts.Logger.Info("external IP is stable again", zap.String("ip", current), zap.Duration("hold_down", d.opts.HoldDown))
+	if stable != current && d.opts.OnChange != nil {
+		d.opts.OnChange(stable, current)
+	}
+}
+
+// Flapping returns true while changes are held down.
+func (d *Damper) Flapping() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.holding
+}
+
+// Stop cancels a pending end of a flap.
+func (d *Damper) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+}
@@ -0,0 +1,91 @@
+package ifconfig
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+type fakeFlapRecorder struct {
+	values []bool
+}
+
+func (r *fakeFlapRecorder) RecordIPFlapping(_ context.Context, flapping bool) {
+	r.values = append(r.values, flapping)
+}
+
+func TestDamper(t *testing.T) {
+	t0 := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	var resyncs [][2]string
+	rec := &fakeFlapRecorder{}
+	d := NewDamper(DamperOptions{
+		HoldDown: time.Hour,
+		Recorder: rec,
+		OnChange: func(oldIP, newIP string) { resyncs = append(resyncs, [2]string{oldIP, newIP}) },
+	})
+	defer d.Stop()
+
+	// A single change, and another to a new IP well after, are passed on.
+	if !d.changed(t0, "203.0.113.1", "203.0.113.2") {
+		t.Fatal("first change held down, want passed on")
+	}
+	if !d.changed(t0.Add(2*time.Hour), "203.0.113.2", "203.0.113.3") {
+		t.Fatal("change outside the window held down, want passed on")
+	}
+
+	// Changing back to an IP left within the window is a flap.
+	if d.changed(t0.Add(2*time.Hour+10*time.Minute), "203.0.113.3", "203.0.113.2") {
+		t.Fatal("change back to a recent IP passed on, want held down")
+	}
+	if !d.Flapping() || !slices.Equal(rec.values, []bool{true}) {
+		t.Fatalf("Flapping() = %v, recorded %v, want a flap", d.Flapping(), rec.values)
+	}
+	if d.changed(t0.Add(2*time.Hour+20*time.Minute), "203.0.113.2", "203.0.113.4") {
+		t.Fatal("change during a flap passed on, want held down")
+	}
+
+	// A release by an earlier change's timer is ignored.
+	d.release(d.gen - 1)
+	if !d.Flapping() {
+		t.Fatal("stale release ended the flap")
+	}
+	d.release(d.gen)
+	if d.Flapping() || !slices.Equal(rec.values, []bool{true, false}) {
+		t.Fatalf("Flapping() = %v, recorded %v, want the flap ended", d.Flapping(), rec.values)
+	}
+	// The release resyncs once, from the IP before the flap to the latest.
+	if len(resyncs) != 1 || resyncs[0] != [2]string{"203.0.113.3", "203.0.113.4"} {
+		t.Fatalf("resyncs = %v, want one for the whole flap", resyncs)
+	}
+}
+
+func TestDamper_Changes(t *testing.T) {
+	t0 := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	var resyncs [][2]string
+	d := NewDamper(DamperOptions{
+		Changes:  3,
+		HoldDown: time.Hour,
+		OnChange: func(oldIP, newIP string) { resyncs = append(resyncs, [2]string{oldIP, newIP}) },
+	})
+	defer d.Stop()
+
+	d.changed(t0, "203.0.113.1", "203.0.113.2")
+	d.changed(t0.Add(10*time.Minute), "203.0.113.2", "203.0.113.3")
+	if d.changed(t0.Add(20*time.Minute), "203.0.113.3", "203.0.113.4") {
+		t.Fatal("third change within the window passed on, want held down")
+	}
+	d.release(d.gen)
+	if len(resyncs) != 1 || resyncs[0] != [2]string{"203.0.113.3", "203.0.113.4"} {
+		t.Fatalf("resyncs after the release = %v, want one from before the flap to the latest IP", resyncs)
+	}
+
+	// A flap that ends on the IP it started from needs no resync.
+	resyncs = nil
+	d.changed(t0.Add(30*time.Minute), "203.0.113.4", "203.0.113.5")
+	d.changed(t0.Add(40*time.Minute), "203.0.113.5", "203.0.113.4")
+	d.release(d.gen)
+	if len(resyncs) != 0 {
+		t.Fatalf("resyncs = %v, want none", resyncs)
+	}
+}
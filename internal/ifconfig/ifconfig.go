@@ -4,17 +4,28 @@ package ifconfig
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
+	"net/netip"
 	"sync"
 	"time"
 
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
 	"github.com/jsirianni/dzsa-sync/internal/metrics"
+	"github.com/jsirianni/dzsa-sync/internal/schedule"
 	"go.uber.org/zap"
 )
 
 const (
 	endpoint = "https://ifconfig.net/json"
+
+	// checkInterval is the time between IP checks of the Run loop.
+	checkInterval = 10 * time.Minute
+)
+
+// Address families a Client can be restricted to with Family.
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
 )
 
 // Response is the response from the ifconfig.net service.
@@ -47,6 +58,9 @@ type Client struct {
 	mu       sync.Mutex
 	// BaseURL overrides the default endpoint when set (e.g. for tests).
 	BaseURL string
+	// Family, when FamilyIPv4 or FamilyIPv6, rejects detected IPs of the other family. It pairs with an HTTP
+	// client that dials only that family, so a dual-stack host runs one Client per family.
+	Family string
 }
 
 // New creates a new ifconfig client. httpClient may be nil to use a default client.
@@ -65,6 +79,9 @@ func New(logger *zap.Logger, httpClient *http.Client, recorder metrics.HTTPRecor
 func (c *Client) Get(ctx context.Context) (*Response, error) {
 	start := time.Now()
 	host := "ifconfig"
+	if c.Family == FamilyIPv6 {
+		host = "ifconfig6"
+	}
 	var statusCode int
 
 	url := endpoint
@@ -76,7 +93,7 @@ func (c *Client) Get(ctx context.Context) (*Response, error) {
 		if c.recorder != nil {
 			c.recorder.RecordRequest(ctx, host, 0, metrics.ClassifyError(err, 0), time.Since(start))
 		}
-		return nil, err
+		return nil, errkind.Wrap(errkind.Internal, err)
 	}
 	req.Header.Set("User-Agent", "dzsa-sync/1.0")
 	req.Header.Set("Accept", "application/json")
@@ -86,7 +103,7 @@ func (c *Client) Get(ctx context.Context) (*Response, error) {
 		if c.recorder != nil {
 			c.recorder.RecordRequest(ctx, host, 0, metrics.ClassifyError(err, 0), time.Since(start))
 		}
-		return nil, err
+		return nil, errkind.Wrap(errkind.Network, err)
 	}
 	defer resp.Body.Close()
 	statusCode = resp.StatusCode
@@ -95,7 +112,7 @@ func (c *Client) Get(ctx context.Context) (*Response, error) {
 		if c.recorder != nil {
 			c.recorder.RecordRequest(ctx, host, statusCode, metrics.ClassifyError(nil, statusCode), time.Since(start))
 		}
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, errkind.Errorf(errkind.Upstream, "unexpected status code: %d", resp.StatusCode)
 	}
 
 	var r Response
@@ -103,14 +120,33 @@ func (c *Client) Get(ctx context.Context) (*Response, error) {
 		if c.recorder != nil {
 			c.recorder.RecordRequest(ctx, host, statusCode, metrics.ErrorDecode, time.Since(start))
 		}
-		return nil, err
+		return nil, errkind.Wrap(errkind.Upstream, err)
 	}
 	if c.recorder != nil {
 		c.recorder.RecordRequest(ctx, host, statusCode, metrics.ErrorNone, time.Since(start))
 	}
+	if r.IP != "" && !c.inFamily(r.IP) {
+		return nil, errkind.Errorf(errkind.Upstream, "detected IP %s is not an %s address", r.IP, c.Family)
+	}
 	return &r, nil
 }
 
+// inFamily reports whether ip belongs to c.Family, or any family when it is unset.
+func (c *Client) inFamily(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return c.Family == ""
+	}
+	switch c.Family {
+	case FamilyIPv4:
+		return addr.Unmap().Is4()
+	case FamilyIPv6:
+		return addr.Is6() && !addr.Is4In6()
+	default:
+		return true
+	}
+}
+
 // GetAddress returns the last successfully detected IP (updated by Run loop).
 func (c *Client) GetAddress() string {
 	c.mu.Lock()
@@ -125,16 +161,19 @@ func (c *Client) SetAddress(ip string) {
 	c.address = ip
 }
 
-// Run runs the IP detection loop every 10 minutes. When the IP changes, onChanged is called.
-// Run blocks until ctx is cancelled.
+// Run runs the IP detection loop every 10 minutes, and at once when the host resumes from suspend, since a
+// resumed host often has a new IP. When the IP changes, onChanged is called. Run blocks until ctx is cancelled.
 func (c *Client) Run(ctx context.Context, onChanged func(oldIP, newIP string)) {
-	ticker := time.NewTicker(10 * time.Minute)
-	defer ticker.Stop()
+	now := time.Now()
+	due := schedule.In(now, checkInterval)
+	watch := schedule.NewWatch(now)
+	timer := time.NewTimer(due.Wait(now))
+	defer timer.Stop()
 
 	// Initial fetch
 	resp, err := c.Get(ctx)
 	if err != nil {
-		c.logger.Error("ifconfig initial get failed", zap.Error(err))
+		c.logger.Error("ifconfig initial get failed", zap.Error(err), errkind.Field(err))
 	} else if resp.IP != "" {
 		c.mu.Lock()
 		c.address = resp.IP
@@ -144,27 +183,42 @@ func (c *Client) Run(ctx context.Context, onChanged func(oldIP, newIP string)) {
 
 	for {
 		select {
-		case <-ticker.C:
-			resp, err := c.Get(ctx)
-			if err != nil {
-				c.logger.Error("ifconfig get failed", zap.Error(err))
-				continue
-			}
-			if resp.IP == "" {
-				c.logger.Warn("ifconfig returned empty IP")
-				continue
-			}
-			c.logger.Info("ifconfig sync completed", zap.String("detected_ip", resp.IP))
-			c.mu.Lock()
-			old := c.address
-			c.address = resp.IP
-			c.mu.Unlock()
-			if old != "" && old != resp.IP && onChanged != nil {
-				onChanged(old, resp.IP)
+		case <-timer.C:
+			now := time.Now()
+			if gap, ok := watch.Resumed(now); ok {
+				c.logger.Info("host resumed from suspend or its clock jumped, checking the IP now", zap.Duration("gap", gap))
+				c.Check(ctx, onChanged)
+				due = schedule.In(time.Now(), checkInterval)
+			} else if due.Passed(now) {
+				c.Check(ctx, onChanged)
+				due = due.Next(time.Now(), checkInterval)
 			}
+			timer.Reset(due.Wait(time.Now()))
 		case <-ctx.Done():
 			c.logger.Info("ifconfig loop shutting down")
 			return
 		}
 	}
 }
+
+// Check runs one detection of the Run loop: it fetches the IP and calls onChanged when it differs from a
+// previously detected one.
+func (c *Client) Check(ctx context.Context, onChanged func(oldIP, newIP string)) {
+	resp, err := c.Get(ctx)
+	if err != nil {
+		c.logger.Error("ifconfig get failed", zap.Error(err), errkind.Field(err))
+		return
+	}
+	if resp.IP == "" {
+		c.logger.Warn("ifconfig returned empty IP")
+		return
+	}
+	c.logger.Info("ifconfig sync completed", zap.String("detected_ip", resp.IP))
+	c.mu.Lock()
+	old := c.address
+	c.address = resp.IP
+	c.mu.Unlock()
+	if old != "" && old != resp.IP && onChanged != nil {
+		onChanged(old, resp.IP)
+	}
+}
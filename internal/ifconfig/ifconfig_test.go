@@ -85,6 +85,29 @@ func TestClient_Get(t *testing.T) {
 	})
 }
 
+func TestClient_Family(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"ip":"2001:db8::42"}`))
+	}))
+	defer server.Close()
+
+	client := New(zap.NewNop(), server.Client(), nil)
+	client.BaseURL = server.URL
+	client.Family = FamilyIPv6
+	resp, err := client.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if resp.IP != "2001:db8::42" {
+		t.Errorf("Get() IP = %q, want 2001:db8::42", resp.IP)
+	}
+
+	client.Family = FamilyIPv4
+	if _, err := client.Get(context.Background()); err == nil {
+		t.Error("Get() of an IPv6 address with FamilyIPv4: error = nil")
+	}
+}
+
 func TestClient_GetAddress_SetAddress(t *testing.T) {
 	logger := zap.NewNop()
 	client := New(logger, nil, nil)
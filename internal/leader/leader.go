@@ -0,0 +1,242 @@
+// Package leader elects one active instance among dzsa-sync instances that manage the same servers.
+// The leader holds a lease that it renews periodically; followers take over once it expires.
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultTTL is the default lease duration. A failed leader is replaced within one TTL.
+const DefaultTTL = 30 * time.Second
+
+// Lease is a shared lock with an expiry.
+type Lease interface {
+	// Acquire takes the lease for id until now+ttl when it is free, expired, or already held by id, and
+	// returns the current holder.
+	Acquire(ctx context.Context, id string, ttl time.Duration) (string, error)
+	// Release gives up the lease if id holds it.
+	Release(ctx context.Context, id string) error
+}
+
+// FileLease is a Lease stored in a file on storage shared by every instance, e.g. NFS.
+// Two instances that take an expired lease at the same moment may both lead until the next renewal,
+// which is harmless because a sync is idempotent.
+type FileLease struct {
+	Path string
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+type leaseRecord struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+// NewFileLease returns a lease stored at path.
+func NewFileLease(path string) *FileLease {
+	return &FileLease{Path: path, now: time.Now}
+}
+
+// Acquire implements Lease.
+func (l *FileLease) Acquire(_ context.Context, id string, ttl time.Duration) (string, error) {
+	rec, err := l.read()
+	if err != nil {
+		return "", err
+	}
+	now := l.now()
+	if rec.Holder != "" && rec.Holder != id && now.Before(rec.Expires) {
+		return rec.Holder, nil
+	}
+	if err := l.write(leaseRecord{Holder: id, Expires: now.Add(ttl)}); err != nil {
+		return "", err
+	}
+	// Another instance may have replaced the file between the read and the rename; the file decides.
+	rec, err = l.read()
+	if err != nil {
+		return "", err
+	}
+	return rec.Holder, nil
+}
+
+// Release implements Lease.
+func (l *FileLease) Release(_ context.Context, id string) error {
+	rec, err := l.read()
+	if err != nil || rec.Holder != id {
+		return err
+	}
+	return l.write(leaseRecord{})
+}
+
+func (l *FileLease) read() (leaseRecord, error) {
+	var rec leaseRecord
+	b, err := os.ReadFile(l.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return rec, nil
+	}
+	if err != nil {
+		return rec, fmt.Errorf("read lease: %w", err)
+	}
+	if len(b) == 0 {
+		return rec, nil
+	}
+	if err := json.Unmarshal(b, &rec); err != nil {
+		// A corrupt lease is treated as free so the instances can recover.
+		return leaseRecord{}, nil
+	}
+	return rec, nil
+}
+
+func (l *FileLease) write(rec leaseRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encode lease: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(l.Path), filepath.Base(l.Path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("write lease: %w", err)
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write lease: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write lease: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), l.Path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write lease: %w", err)
+	}
+	return nil
+}
+
+// Options configures an Elector.
+type Options struct {
+	Logger *zap.Logger
+	Lease  Lease
+	// ID identifies this instance in the lease, e.g. the hostname.
+	ID string
+	// TTL is the lease duration. The lease is renewed every TTL/3. Zero uses DefaultTTL.
+	TTL time.Duration
+	// OnChange is called when this instance becomes leader or steps down. Optional.
+	OnChange func(leader bool)
+}
+
+// Elector campaigns for the lease and reports whether this instance leads. Safe for concurrent use.
+type Elector struct {
+	opts Options
+
+	mu        sync.RWMutex
+	leader    bool
+	holder    string
+	lastRenew time.Time
+	keep      bool
+}
+
+// New returns an Elector. Call Run to start campaigning; until then it is a follower.
+func New(opts Options) *Elector {
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTTL
+	}
+	return &Elector{opts: opts}
+}
+
+// IsLeader reports whether this instance holds the lease.
+func (e *Elector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leader
+}
+
+// Leader returns the current lease holder, or "" when unknown.
+func (e *Elector) Leader() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.holder
+}
+
+// KeepLease stops Run from releasing the lease on exit. It is used when a new process with the same ID
+// takes over during a graceful restart, so a follower does not grab the lease in between.
+func (e *Elector) KeepLease() {
+	e.mu.Lock()
+	e.keep = true
+	e.mu.Unlock()
+}
+
+// Run campaigns until ctx is cancelled, then releases the lease if held so a follower takes over at once.
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.opts.TTL / 3)
+	defer ticker.Stop()
+	for {
+		e.campaign(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			e.mu.RLock()
+			release := e.leader && !e.keep
+			e.mu.RUnlock()
+			if release {
+				releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				if err := e.opts.Lease.Release(releaseCtx, e.opts.ID); err != nil {
+					e.opts.Logger.Warn("release leader lease", zap.Error(err))
+				}
+				cancel()
+				e.set(false, "")
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// campaign acquires or renews the lease once. When the lease cannot be reached, a leader keeps leading
+// until its last renewal expires, and then steps down.
+func (e *Elector) campaign(ctx context.Context, now time.Time) {
+	holder, err := e.opts.Lease.Acquire(ctx, e.opts.ID, e.opts.TTL)
+	if err != nil {
+		e.opts.Logger.Warn("leader lease unavailable", zap.Error(err))
+		e.mu.RLock()
+		expired := e.leader && now.Sub(e.lastRenew) >= e.opts.TTL
+		e.mu.RUnlock()
+		if expired {
+			e.set(false, "")
+		}
+		return
+	}
+	if holder == e.opts.ID {
+		e.mu.Lock()
+		e.lastRenew = now
+		e.mu.Unlock()
+	}
+	e.set(holder == e.opts.ID, holder)
+}
+
+func (e *Elector) set(leader bool, holder string) {
+	e.mu.Lock()
+	changed := e.leader != leader
+	e.leader, e.holder = leader, holder
+	e.mu.Unlock()
+	if !changed {
+		return
+	}
+	if leader {
+		e.opts.Logger.Info("elected leader, starting syncs", zap.String("id", e.opts.ID))
+	} else {
+		e.opts.Logger.Info("not the leader, pausing syncs", zap.String("id", e.opts.ID), zap.String("leader", holder))
+	}
+	if e.opts.OnChange != nil {
+		e.opts.OnChange(leader)
+	}
+}
@@ -0,0 +1,112 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileLease(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "dzsa-sync.lease")
+	now := time.Now()
+	a, b := NewFileLease(path), NewFileLease(path)
+	a.now = func() time.Time { return now }
+	b.now = a.now
+
+	acquire := func(l *FileLease, id string) string {
+		t.Helper()
+		holder, err := l.Acquire(ctx, id, 30*time.Second)
+		if err != nil {
+			t.Fatalf("Acquire(%s) error = %v", id, err)
+		}
+		return holder
+	}
+
+	if h := acquire(a, "node-a"); h != "node-a" {
+		t.Errorf("free lease holder = %q, want node-a", h)
+	}
+	if h := acquire(b, "node-b"); h != "node-a" {
+		t.Errorf("held lease holder = %q, want node-a", h)
+	}
+	now = now.Add(20 * time.Second)
+	if h := acquire(a, "node-a"); h != "node-a" {
+		t.Errorf("renewed lease holder = %q, want node-a", h)
+	}
+	// The renewal extended the lease past the original expiry.
+	now = now.Add(20 * time.Second)
+	if h := acquire(b, "node-b"); h != "node-a" {
+		t.Errorf("renewed lease holder = %q, want node-a", h)
+	}
+	now = now.Add(31 * time.Second)
+	if h := acquire(b, "node-b"); h != "node-b" {
+		t.Errorf("expired lease holder = %q, want node-b", h)
+	}
+
+	if err := a.Release(ctx, "node-a"); err != nil {
+		t.Fatal(err)
+	}
+	if h := acquire(a, "node-a"); h != "node-b" {
+		t.Errorf("release by a non-holder freed the lease: holder = %q", h)
+	}
+	if err := b.Release(ctx, "node-b"); err != nil {
+		t.Fatal(err)
+	}
+	if h := acquire(a, "node-a"); h != "node-a" {
+		t.Errorf("released lease holder = %q, want node-a", h)
+	}
+}
+
+// fakeLease returns holder, or err when set.
+type fakeLease struct {
+	holder string
+	err    error
+}
+
+func (l *fakeLease) Acquire(_ context.Context, id string, _ time.Duration) (string, error) {
+	if l.err != nil {
+		return "", l.err
+	}
+	if l.holder == "" {
+		l.holder = id
+	}
+	return l.holder, nil
+}
+
+func (l *fakeLease) Release(context.Context, string) error { return nil }
+
+func TestElectorCampaign(t *testing.T) {
+	lease := &fakeLease{holder: "node-b"}
+	var changes []bool
+	e := New(Options{Lease: lease, ID: "node-a", TTL: 30 * time.Second, OnChange: func(l bool) { changes = append(changes, l) }})
+	ctx := context.Background()
+	now := time.Now()
+
+	e.campaign(ctx, now)
+	if e.IsLeader() || e.Leader() != "node-b" {
+		t.Errorf("follower: IsLeader() = %v, Leader() = %q", e.IsLeader(), e.Leader())
+	}
+
+	lease.holder = ""
+	e.campaign(ctx, now)
+	if !e.IsLeader() {
+		t.Error("not leader after acquiring a free lease")
+	}
+
+	// A leader keeps leading through backend errors until its lease would have expired.
+	lease.err = errors.New("stale NFS file handle")
+	e.campaign(ctx, now.Add(10*time.Second))
+	if !e.IsLeader() {
+		t.Error("stepped down before the lease expired")
+	}
+	e.campaign(ctx, now.Add(30*time.Second))
+	if e.IsLeader() {
+		t.Error("still leader after the lease expired")
+	}
+
+	if want := []bool{true, false}; len(changes) != 2 || changes[0] != want[0] || changes[1] != want[1] {
+		t.Errorf("OnChange calls = %v, want %v", changes, want)
+	}
+}
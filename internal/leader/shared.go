@@ -0,0 +1,24 @@
+package leader
+
+import "errors"
+
+// ErrLocalLease is returned by CheckShared for a lease path on storage only one host can reach.
+var ErrLocalLease = errors.New("lease file is on local storage; instances on other hosts cannot see it")
+
+// localFilesystems names the filesystem types, by statfs magic number, that are local to one host.
+var localFilesystems = map[uint32]string{
+	0xEF53:     "ext4",
+	0x58465342: "xfs",
+	0x9123683E: "btrfs",
+	0x2FC12FC1: "zfs",
+	0xF2F52010: "f2fs",
+	0xCA451A4E: "bcachefs",
+	0x52654973: "reiserfs",
+	0x3153464A: "jfs",
+	0x4D44:     "vfat",
+	0x2011BAB0: "exfat",
+	0x5346544E: "ntfs",
+	0x01021994: "tmpfs",
+	0x858458F6: "ramfs",
+	0x794C7630: "overlayfs",
+}
@@ -0,0 +1,27 @@
+package leader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// CheckShared returns ErrLocalLease when the directory of the lease file at path is on a filesystem known to
+// be local to this host, such as ext4 or tmpfs. Network and cluster filesystems (NFS, SMB, CephFS), and any
+// other it does not know, pass, as does a directory that does not exist yet.
+func CheckShared(path string) error {
+	var st syscall.Statfs_t
+	err := syscall.Statfs(filepath.Dir(path), &st)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("statfs %s: %w", filepath.Dir(path), err)
+	}
+	if name, ok := localFilesystems[uint32(st.Type)]; ok {
+		return fmt.Errorf("%w (%s)", ErrLocalLease, name)
+	}
+	return nil
+}
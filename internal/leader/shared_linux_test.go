@@ -0,0 +1,25 @@
+package leader
+
+import (
+	"errors"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestCheckShared(t *testing.T) {
+	dir := t.TempDir()
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := localFilesystems[uint32(st.Type)]; !ok {
+		t.Skipf("the temp dir is on filesystem %#x, which is not known to be local", st.Type)
+	}
+	if err := CheckShared(filepath.Join(dir, "dzsa-sync.lease")); !errors.Is(err, ErrLocalLease) {
+		t.Errorf("CheckShared() on local storage error = %v, want ErrLocalLease", err)
+	}
+	if err := CheckShared(filepath.Join(dir, "missing", "dzsa-sync.lease")); err != nil {
+		t.Errorf("CheckShared() in a missing directory error = %v, want nil", err)
+	}
+}
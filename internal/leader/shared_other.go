@@ -0,0 +1,8 @@
+//go:build !linux
+
+package leader
+
+// CheckShared cannot tell local from shared storage on this platform and accepts every path.
+func CheckShared(string) error {
+	return nil
+}
@@ -16,6 +16,7 @@ const (
 	ErrorStatus4xx        = "status_4xx"
 	ErrorStatus5xx        = "status_5xx"
 	ErrorDecode           = "decode_error"
+	ErrorInvalid          = "invalid_result"
 	ErrorUnknown          = "unknown"
 )
 
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// OTLP protocols.
+const (
+	OTLPProtocolGRPC = "grpc"
+	OTLPProtocolHTTP = "http"
+)
+
+// DefaultOTLPInterval is the time between OTLP pushes when OTLPOptions.Interval is zero.
+const DefaultOTLPInterval = 30 * time.Second
+
+// otlpHTTPPath is the path metrics are posted to when the HTTP endpoint has none.
+const otlpHTTPPath = "/v1/metrics"
+
+// OTLPOptions configures pushing metrics to an OpenTelemetry collector or a hosted OTLP backend, next to the
+// Prometheus exposition.
+type OTLPOptions struct {
+	// Protocol is OTLPProtocolHTTP (protobuf over HTTP) or OTLPProtocolGRPC. Empty is OTLPProtocolHTTP.
+	Protocol string
+	// Endpoint is the receiver's URL, e.g. http://otel-collector:4318 or https://otlp.example.com. An http
+	// scheme sends in plain text. An HTTP endpoint without a path posts to /v1/metrics.
+	Endpoint string
+	// Headers are sent with every push, e.g. an API key.
+	Headers map[string]string
+	// Interval is the time between pushes. Zero uses DefaultOTLPInterval.
+	Interval time.Duration
+}
+
+// newOTLPReader returns a reader that pushes the provider's metrics to opts.Endpoint every opts.Interval.
+func newOTLPReader(ctx context.Context, opts OTLPOptions) (sdkmetric.Reader, error) {
+	u, err := url.Parse(opts.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse endpoint: %w", err)
+	}
+	var exporter sdkmetric.Exporter
+	switch opts.Protocol {
+	case OTLPProtocolGRPC:
+		exporter, err = otlpmetricgrpc.New(ctx,
+			otlpmetricgrpc.WithEndpointURL(opts.Endpoint),
+			otlpmetricgrpc.WithHeaders(opts.Headers),
+		)
+	case "", OTLPProtocolHTTP:
+		if u.Path == "" || u.Path == "/" {
+			u.Path = otlpHTTPPath
+		}
+		exporter, err = otlpmetrichttp.New(ctx,
+			otlpmetrichttp.WithEndpointURL(u.String()),
+			otlpmetrichttp.WithHeaders(opts.Headers),
+		)
+	default:
+		return nil, fmt.Errorf("unknown protocol %q", opts.Protocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+	interval := opts.Interval
+	if interval == 0 {
+		interval = DefaultOTLPInterval
+	}
+	return sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(interval)), nil
+}
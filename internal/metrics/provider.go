@@ -7,7 +7,9 @@ import (
 	"os"
 	"time"
 
+	promclient "github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/prometheus"
@@ -15,6 +17,7 @@ import (
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 const (
@@ -23,34 +26,68 @@ const (
 	requestCount       = "request_count"
 	requestLatency     = "request_latency_seconds"
 	serverPlayerCount  = "server_player_count"
+	serverMaxPlayers   = "server_max_players"
+	serverOnline       = "server_online"
+	serverModsMismatch = "server_mods_mismatch"
+	serverListed       = "server_listed_upstream"
+	workshopInvalid    = "server_workshop_mods_invalid"
+	serverNight        = "server_night"
+	serverLatency      = "server_query_latency_seconds"
+	dnsLookupCount     = "dns_lookup_count"
+	retryCount         = "retry_count"
+	syncErrorCount     = "sync_error_count"
+	agentUp            = "agent_up"
+	notificationCount  = "notification_count"
+	ipFlapping         = "external_ip_flapping"
+	serverNextSync     = "server_next_sync_timestamp_seconds"
+
+	// instanceNameKey labels every series with the configured instance_name.
+	instanceNameKey = attribute.Key("instance_name")
 )
 
 // Provider sets up OpenTelemetry metrics and Prometheus exposition.
 type Provider struct {
 	provider *sdkmetric.MeterProvider
+	// started is when the cumulative series began, the created timestamp of every counter and histogram.
+	started time.Time
 }
 
 // NewProvider creates a new metrics provider. Call Start before using the returned HTTPRecorder.
-func NewProvider() (*Provider, error) {
+// When instanceName is set, it is added to the resource and as an instance_name label on every series.
+// When otlp is set, metrics are also pushed over OTLP; Shutdown pushes them a last time.
+func NewProvider(instanceName string, otlp *OTLPOptions) (*Provider, error) {
 	hostname, err := os.Hostname()
 	if err != nil {
 		return nil, fmt.Errorf("hostname: %w", err)
 	}
-	r := resource.NewWithAttributes(
-		semconv.SchemaURL,
+	attrs := []attribute.KeyValue{
 		semconv.ServiceNameKey.String(serviceName),
 		semconv.HostNameKey.String(hostname),
-	)
-	exporter, err := prometheus.New(prometheus.WithNamespace(serviceName))
+	}
+	opts := []prometheus.Option{prometheus.WithNamespace(serviceName)}
+	if instanceName != "" {
+		attrs = append(attrs, instanceNameKey.String(instanceName))
+		opts = append(opts, prometheus.WithResourceAsConstantLabels(attribute.NewAllowKeysFilter(instanceNameKey)))
+	}
+	r := resource.NewWithAttributes(semconv.SchemaURL, attrs...)
+	exporter, err := prometheus.New(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("prometheus exporter: %w", err)
 	}
-	provider := sdkmetric.NewMeterProvider(
+	providerOpts := []sdkmetric.Option{
 		sdkmetric.WithReader(exporter),
 		sdkmetric.WithResource(r),
-	)
+	}
+	if otlp != nil {
+		reader, err := newOTLPReader(context.Background(), *otlp)
+		if err != nil {
+			return nil, fmt.Errorf("otlp exporter: %w", err)
+		}
+		providerOpts = append(providerOpts, sdkmetric.WithReader(reader))
+	}
+	provider := sdkmetric.NewMeterProvider(providerOpts...)
 	otel.SetMeterProvider(provider)
-	return &Provider{provider: provider}, nil
+	return &Provider{provider: provider, started: time.Now()}, nil
 }
 
 // Start is a no-op; initialization is done in NewProvider.
@@ -66,9 +103,49 @@ func (p *Provider) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// HandlerOptions configures the /metrics exposition.
+type HandlerOptions struct {
+	// OpenMetrics serves the OpenMetrics format, which carries exemplars, to scrapers that ask for it in their
+	// Accept header. Others get the classic Prometheus text format. OpenMetrics formats histogram bucket
+	// bounds with a trailing ".0", so enabling it changes the identity of those series on a Prometheus server.
+	OpenMetrics bool
+	// CreatedSamples adds a _created series to every counter and histogram in the OpenMetrics format, for
+	// reset detection. The series are cumulative since the provider was created, which is their created time.
+	CreatedSamples bool
+}
+
 // Handler returns an http.Handler that serves Prometheus metrics at /metrics.
-func (p *Provider) Handler() http.Handler {
-	return promhttp.Handler()
+func (p *Provider) Handler(opts HandlerOptions) http.Handler {
+	var gatherer promclient.Gatherer = promclient.DefaultGatherer
+	created := opts.OpenMetrics && opts.CreatedSamples
+	if created {
+		gatherer = createdGatherer{Gatherer: gatherer, created: timestamppb.New(p.started)}
+	}
+	return promhttp.InstrumentMetricHandler(promclient.DefaultRegisterer, promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{
+		EnableOpenMetrics:                   opts.OpenMetrics,
+		EnableOpenMetricsTextCreatedSamples: created,
+	}))
+}
+
+// createdGatherer sets the created timestamp of the counters and histograms the OTel exporter leaves without one.
+type createdGatherer struct {
+	promclient.Gatherer
+	created *timestamppb.Timestamp
+}
+
+func (g createdGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.Gatherer.Gather()
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			if c := m.GetCounter(); c != nil && c.CreatedTimestamp == nil {
+				c.CreatedTimestamp = g.created
+			}
+			if h := m.GetHistogram(); h != nil && h.CreatedTimestamp == nil {
+				h.CreatedTimestamp = g.created
+			}
+		}
+	}
+	return families, err
 }
 
 // NewHTTPRecorder returns an HTTPRecorder that records RequestCount and RequestLatency.
@@ -85,14 +162,144 @@ func NewHTTPRecorder() (HTTPRecorder, error) {
 	return &otelRecorder{counter: counter, histogram: histogram}, nil
 }
 
-// NewPlayerCountRecorder returns a PlayerCountRecorder that records server_player_count (gauge).
+// NewPlayerCountRecorder returns a PlayerCountRecorder that records server_player_count, server_max_players,
+// and server_online (gauges).
 func NewPlayerCountRecorder() (PlayerCountRecorder, error) {
 	meter := otel.Meter(meterName)
 	gauge, err := meter.Int64Gauge(serverPlayerCount)
 	if err != nil {
 		return nil, fmt.Errorf("server_player_count gauge: %w", err)
 	}
-	return &playerCountRecorder{gauge: gauge}, nil
+	maxPlayers, err := meter.Int64Gauge(serverMaxPlayers)
+	if err != nil {
+		return nil, fmt.Errorf("server_max_players gauge: %w", err)
+	}
+	online, err := meter.Int64Gauge(serverOnline)
+	if err != nil {
+		return nil, fmt.Errorf("server_online gauge: %w", err)
+	}
+	return &playerCountRecorder{gauge: gauge, maxPlayers: maxPlayers, online: online}, nil
+}
+
+// NewModCheckRecorder returns a ModCheckRecorder that records server_mods_mismatch (gauge).
+func NewModCheckRecorder() (ModCheckRecorder, error) {
+	meter := otel.Meter(meterName)
+	gauge, err := meter.Int64Gauge(serverModsMismatch)
+	if err != nil {
+		return nil, fmt.Errorf("server_mods_mismatch gauge: %w", err)
+	}
+	return &modCheckRecorder{gauge: gauge}, nil
+}
+
+// NewUpstreamRecorder returns an UpstreamRecorder that records server_listed_upstream (gauge).
+func NewUpstreamRecorder() (UpstreamRecorder, error) {
+	meter := otel.Meter(meterName)
+	gauge, err := meter.Int64Gauge(serverListed)
+	if err != nil {
+		return nil, fmt.Errorf("server_listed_upstream gauge: %w", err)
+	}
+	return &upstreamRecorder{gauge: gauge}, nil
+}
+
+// NewWorkshopRecorder returns a WorkshopRecorder that records server_workshop_mods_invalid (gauge).
+func NewWorkshopRecorder() (WorkshopRecorder, error) {
+	meter := otel.Meter(meterName)
+	gauge, err := meter.Int64Gauge(workshopInvalid)
+	if err != nil {
+		return nil, fmt.Errorf("server_workshop_mods_invalid gauge: %w", err)
+	}
+	return &workshopRecorder{gauge: gauge}, nil
+}
+
+// NewLatencyRecorder returns a LatencyRecorder that records server_query_latency_seconds (histogram).
+func NewLatencyRecorder() (LatencyRecorder, error) {
+	meter := otel.Meter(meterName)
+	histogram, err := meter.Float64Histogram(serverLatency,
+		metric.WithExplicitBucketBoundaries(0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5))
+	if err != nil {
+		return nil, fmt.Errorf("server_query_latency_seconds histogram: %w", err)
+	}
+	return &latencyRecorder{histogram: histogram}, nil
+}
+
+// NewNightRecorder returns a NightRecorder that records server_night (gauge).
+func NewNightRecorder() (NightRecorder, error) {
+	meter := otel.Meter(meterName)
+	gauge, err := meter.Int64Gauge(serverNight)
+	if err != nil {
+		return nil, fmt.Errorf("server_night gauge: %w", err)
+	}
+	return &nightRecorder{gauge: gauge}, nil
+}
+
+// NewDNSRecorder returns a DNSRecorder that records dns_lookup_count (counter).
+func NewDNSRecorder() (DNSRecorder, error) {
+	meter := otel.Meter(meterName)
+	counter, err := meter.Int64Counter(dnsLookupCount)
+	if err != nil {
+		return nil, fmt.Errorf("dns_lookup_count counter: %w", err)
+	}
+	return &dnsRecorder{counter: counter}, nil
+}
+
+// NewRetryRecorder returns a RetryRecorder that records retry_count (counter).
+func NewRetryRecorder() (RetryRecorder, error) {
+	meter := otel.Meter(meterName)
+	counter, err := meter.Int64Counter(retryCount)
+	if err != nil {
+		return nil, fmt.Errorf("retry_count counter: %w", err)
+	}
+	return &retryRecorder{counter: counter}, nil
+}
+
+// NewSyncErrorRecorder returns a SyncErrorRecorder that records sync_error_count (counter).
+func NewSyncErrorRecorder() (SyncErrorRecorder, error) {
+	meter := otel.Meter(meterName)
+	counter, err := meter.Int64Counter(syncErrorCount)
+	if err != nil {
+		return nil, fmt.Errorf("sync_error_count counter: %w", err)
+	}
+	return &syncErrorRecorder{counter: counter}, nil
+}
+
+// NewAgentRecorder returns an AgentRecorder that records agent_up (gauge).
+func NewAgentRecorder() (AgentRecorder, error) {
+	meter := otel.Meter(meterName)
+	gauge, err := meter.Int64Gauge(agentUp)
+	if err != nil {
+		return nil, fmt.Errorf("agent_up gauge: %w", err)
+	}
+	return &agentRecorder{gauge: gauge}, nil
+}
+
+// NewNotificationRecorder returns a NotificationRecorder that records notification_count (counter).
+func NewNotificationRecorder() (NotificationRecorder, error) {
+	meter := otel.Meter(meterName)
+	counter, err := meter.Int64Counter(notificationCount)
+	if err != nil {
+		return nil, fmt.Errorf("notification_count counter: %w", err)
+	}
+	return &notificationRecorder{counter: counter}, nil
+}
+
+// NewIPFlapRecorder returns an IPFlapRecorder that records external_ip_flapping (gauge).
+func NewIPFlapRecorder() (IPFlapRecorder, error) {
+	meter := otel.Meter(meterName)
+	gauge, err := meter.Int64Gauge(ipFlapping)
+	if err != nil {
+		return nil, fmt.Errorf("external_ip_flapping gauge: %w", err)
+	}
+	return &ipFlapRecorder{gauge: gauge}, nil
+}
+
+// NewNextSyncRecorder returns a NextSyncRecorder that records server_next_sync_timestamp_seconds (gauge).
+func NewNextSyncRecorder() (NextSyncRecorder, error) {
+	meter := otel.Meter(meterName)
+	gauge, err := meter.Int64Gauge(serverNextSync)
+	if err != nil {
+		return nil, fmt.Errorf("server_next_sync_timestamp_seconds gauge: %w", err)
+	}
+	return &nextSyncRecorder{gauge: gauge}, nil
 }
 
 type otelRecorder struct {
@@ -115,10 +322,153 @@ func (r *otelRecorder) RecordRequest(ctx context.Context, host string, statusCod
 }
 
 type playerCountRecorder struct {
-	gauge metric.Int64Gauge
+	gauge      metric.Int64Gauge
+	maxPlayers metric.Int64Gauge
+	online     metric.Int64Gauge
 }
 
 func (r *playerCountRecorder) RecordServerPlayerCount(ctx context.Context, serverName string, count int64) {
 	attrs := attribute.NewSet(attribute.String("server", serverName))
 	r.gauge.Record(ctx, count, metric.WithAttributeSet(attrs))
 }
+
+func (r *playerCountRecorder) RecordServerMaxPlayers(ctx context.Context, serverName string, count int64) {
+	attrs := attribute.NewSet(attribute.String("server", serverName))
+	r.maxPlayers.Record(ctx, count, metric.WithAttributeSet(attrs))
+}
+
+func (r *playerCountRecorder) RecordServerOnline(ctx context.Context, serverName string, online bool) {
+	var v int64
+	if online {
+		v = 1
+	}
+	attrs := attribute.NewSet(attribute.String("server", serverName))
+	r.online.Record(ctx, v, metric.WithAttributeSet(attrs))
+}
+
+type modCheckRecorder struct {
+	gauge metric.Int64Gauge
+}
+
+func (r *modCheckRecorder) RecordModMismatch(ctx context.Context, serverName string, mismatch bool) {
+	var v int64
+	if mismatch {
+		v = 1
+	}
+	attrs := attribute.NewSet(attribute.String("server", serverName))
+	r.gauge.Record(ctx, v, metric.WithAttributeSet(attrs))
+}
+
+type upstreamRecorder struct {
+	gauge metric.Int64Gauge
+}
+
+func (r *upstreamRecorder) RecordListedUpstream(ctx context.Context, serverName string, listed bool) {
+	var v int64
+	if listed {
+		v = 1
+	}
+	attrs := attribute.NewSet(attribute.String("server", serverName))
+	r.gauge.Record(ctx, v, metric.WithAttributeSet(attrs))
+}
+
+type workshopRecorder struct {
+	gauge metric.Int64Gauge
+}
+
+func (r *workshopRecorder) RecordInvalidMods(ctx context.Context, serverName string, count int) {
+	attrs := attribute.NewSet(attribute.String("server", serverName))
+	r.gauge.Record(ctx, int64(count), metric.WithAttributeSet(attrs))
+}
+
+type latencyRecorder struct {
+	histogram metric.Float64Histogram
+}
+
+func (r *latencyRecorder) RecordLatency(ctx context.Context, serverName string, rtt time.Duration) {
+	attrs := attribute.NewSet(attribute.String("server", serverName))
+	r.histogram.Record(ctx, rtt.Seconds(), metric.WithAttributeSet(attrs))
+}
+
+type nightRecorder struct {
+	gauge metric.Int64Gauge
+}
+
+func (r *nightRecorder) RecordNight(ctx context.Context, serverName string, night bool) {
+	var v int64
+	if night {
+		v = 1
+	}
+	attrs := attribute.NewSet(attribute.String("server", serverName))
+	r.gauge.Record(ctx, v, metric.WithAttributeSet(attrs))
+}
+
+type dnsRecorder struct {
+	counter metric.Int64Counter
+}
+
+func (r *dnsRecorder) RecordDNSLookup(ctx context.Context, result string) {
+	attrs := attribute.NewSet(attribute.String("result", result))
+	r.counter.Add(ctx, 1, metric.WithAttributeSet(attrs))
+}
+
+type retryRecorder struct {
+	counter metric.Int64Counter
+}
+
+func (r *retryRecorder) RecordRetry(ctx context.Context, result string) {
+	attrs := attribute.NewSet(attribute.String("result", result))
+	r.counter.Add(ctx, 1, metric.WithAttributeSet(attrs))
+}
+
+type syncErrorRecorder struct {
+	counter metric.Int64Counter
+}
+
+func (r *syncErrorRecorder) RecordSyncError(ctx context.Context, kind string) {
+	attrs := attribute.NewSet(attribute.String("kind", kind))
+	r.counter.Add(ctx, 1, metric.WithAttributeSet(attrs))
+}
+
+type agentRecorder struct {
+	gauge metric.Int64Gauge
+}
+
+func (r *agentRecorder) RecordAgentUp(ctx context.Context, agent string, up bool) {
+	var v int64
+	if up {
+		v = 1
+	}
+	attrs := attribute.NewSet(attribute.String("agent", agent))
+	r.gauge.Record(ctx, v, metric.WithAttributeSet(attrs))
+}
+
+type notificationRecorder struct {
+	counter metric.Int64Counter
+}
+
+func (r *notificationRecorder) RecordNotification(ctx context.Context, notifier, result string) {
+	attrs := attribute.NewSet(attribute.String("notifier", notifier), attribute.String("result", result))
+	r.counter.Add(ctx, 1, metric.WithAttributeSet(attrs))
+}
+
+type ipFlapRecorder struct {
+	gauge metric.Int64Gauge
+}
+
+func (r *ipFlapRecorder) RecordIPFlapping(ctx context.Context, flapping bool) {
+	var v int64
+	if flapping {
+		v = 1
+	}
+	r.gauge.Record(ctx, v)
+}
+
+type nextSyncRecorder struct {
+	gauge metric.Int64Gauge
+}
+
+func (r *nextSyncRecorder) RecordNextSync(ctx context.Context, serverName string, at time.Time) {
+	attrs := attribute.NewSet(attribute.String("server", serverName))
+	r.gauge.Record(ctx, at.Unix(), metric.WithAttributeSet(attrs))
+}
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandlerNegotiation(t *testing.T) {
+	p, err := NewProvider("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Shutdown(context.Background())
+	recorder, err := NewHTTPRecorder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.RecordRequest(context.Background(), "dayzsalauncher.com", http.StatusOK, "", time.Second)
+
+	const openMetrics = "application/openmetrics-text; version=1.0.0"
+	scrape := func(opts HandlerOptions, accept string) (string, string) {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		p.Handler(opts).ServeHTTP(rec, req)
+		return rec.Header().Get("Content-Type"), rec.Body.String()
+	}
+
+	tests := []struct {
+		name        string
+		opts        HandlerOptions
+		accept      string
+		openMetrics bool
+		created     bool
+	}{
+		{"classic scraper", HandlerOptions{OpenMetrics: true}, "text/plain", false, false},
+		{"openmetrics disabled", HandlerOptions{}, openMetrics, false, false},
+		{"openmetrics negotiated", HandlerOptions{OpenMetrics: true}, openMetrics, true, false},
+		{"created samples", HandlerOptions{OpenMetrics: true, CreatedSamples: true}, openMetrics, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contentType, body := scrape(tt.opts, tt.accept)
+			if got := strings.HasPrefix(contentType, "application/openmetrics-text"); got != tt.openMetrics {
+				t.Errorf("Content-Type = %q, want OpenMetrics %v", contentType, tt.openMetrics)
+			}
+			if got := strings.HasSuffix(body, "# EOF\n"); got != tt.openMetrics {
+				t.Errorf("body ends with # EOF = %v, want %v", got, tt.openMetrics)
+			}
+			if got := strings.Contains(body, "dzsa_sync_request_count_total_created") || strings.Contains(body, "dzsa_sync_request_count_created"); got != tt.created {
+				t.Errorf("_created series present = %v, want %v", got, tt.created)
+			}
+		})
+	}
+}
+
+func TestProviderOTLP(t *testing.T) {
+	pushes := make(chan *http.Request, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case pushes <- r:
+		default:
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	p, err := NewProvider("", &OTLPOptions{
+		Endpoint: srv.URL,
+		Headers:  map[string]string{"X-Api-Key": "secret"},
+		Interval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder, err := NewPlayerCountRecorder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.RecordServerPlayerCount(context.Background(), "main", 12)
+	// Shutdown pushes once more, well before the interval.
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	select {
+	case r := <-pushes:
+		if r.URL.Path != "/v1/metrics" || r.Header.Get("X-Api-Key") != "secret" {
+			t.Errorf("push to %s with X-Api-Key %q", r.URL.Path, r.Header.Get("X-Api-Key"))
+		}
+	default:
+		t.Fatal("no OTLP push received")
+	}
+
+	if _, err := NewProvider("", &OTLPOptions{Protocol: "udp", Endpoint: srv.URL}); err == nil {
+		t.Error("NewProvider() with an unknown protocol: error = nil")
+	}
+}
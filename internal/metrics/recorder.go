@@ -12,7 +12,72 @@ type HTTPRecorder interface {
 	RecordRequest(ctx context.Context, host string, statusCode int, errType string, duration time.Duration)
 }
 
-// PlayerCountRecorder records the server_player_count gauge (number of players per server).
+// PlayerCountRecorder records the server_player_count and server_max_players gauges (players and slots per
+// server) and the server_online gauge (1 when the last DZSA query of the server succeeded, else 0).
 type PlayerCountRecorder interface {
 	RecordServerPlayerCount(ctx context.Context, serverName string, count int64)
+	RecordServerMaxPlayers(ctx context.Context, serverName string, count int64)
+	RecordServerOnline(ctx context.Context, serverName string, online bool)
+}
+
+// ModCheckRecorder records the server_mods_mismatch gauge (1 when the DZSA and A2S mod lists differ, else 0).
+type ModCheckRecorder interface {
+	RecordModMismatch(ctx context.Context, serverName string, mismatch bool)
+}
+
+// UpstreamRecorder records the server_listed_upstream gauge (1 when the Valve master server lists the server, else 0).
+type UpstreamRecorder interface {
+	RecordListedUpstream(ctx context.Context, serverName string, listed bool)
+}
+
+// WorkshopRecorder records the server_workshop_mods_invalid gauge (number of mods that failed workshop validation).
+type WorkshopRecorder interface {
+	RecordInvalidMods(ctx context.Context, serverName string, count int)
+}
+
+// LatencyRecorder records the server_query_latency_seconds histogram (A2S round trip time per server).
+type LatencyRecorder interface {
+	RecordLatency(ctx context.Context, serverName string, rtt time.Duration)
+}
+
+// NightRecorder records the server_night gauge (1 when the server's in-game time is night, else 0).
+type NightRecorder interface {
+	RecordNight(ctx context.Context, serverName string, night bool)
+}
+
+// DNSRecorder records the dns_lookup_count counter (lookups by result: hit, miss, negative, stale, error).
+type DNSRecorder interface {
+	RecordDNSLookup(ctx context.Context, result string)
+}
+
+// SyncErrorRecorder records the sync_error_count counter (failed syncs by errkind category).
+type SyncErrorRecorder interface {
+	RecordSyncError(ctx context.Context, kind string)
+}
+
+// AgentRecorder records the agent_up gauge (1 when a controller's last poll of an agent succeeded, else 0).
+type AgentRecorder interface {
+	RecordAgentUp(ctx context.Context, agent string, up bool)
+}
+
+// NotificationRecorder records the notification_count counter (notifications sent by rules, by notifier and
+// result: sent, failed).
+type NotificationRecorder interface {
+	RecordNotification(ctx context.Context, notifier, result string)
+}
+
+// RetryRecorder records the retry_count counter (DZSA query retries by result: allowed, exhausted).
+type RetryRecorder interface {
+	RecordRetry(ctx context.Context, result string)
+}
+
+// IPFlapRecorder records the external_ip_flapping gauge (1 while external IP changes are held down, else 0).
+type IPFlapRecorder interface {
+	RecordIPFlapping(ctx context.Context, flapping bool)
+}
+
+// NextSyncRecorder records the server_next_sync_timestamp_seconds gauge (Unix time of each server's next
+// scheduled sync).
+type NextSyncRecorder interface {
+	RecordNextSync(ctx context.Context, serverName string, at time.Time)
 }
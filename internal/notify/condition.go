@@ -0,0 +1,387 @@
+package notify
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// State is what a rule condition sees of a server at one evaluation.
+type State struct {
+	Name string `json:"name"`
+	// Online is true when the last sync succeeded.
+	Online      bool    `json:"online"`
+	Players     int     `json:"players"`
+	MaxPlayers  int     `json:"max_players"`
+	FillPercent float64 `json:"fill_percent"`
+	// Failures is the number of consecutive failed syncs.
+	Failures int    `json:"failures"`
+	Night    bool   `json:"night"`
+	Version  string `json:"version,omitempty"`
+	Map      string `json:"map,omitempty"`
+	// Mods is a hash of the mod list; see history.ModsHash.
+	Mods string `json:"mods,omitempty"`
+	// Error is the error of the last sync, empty when it succeeded.
+	Error string `json:"error,omitempty"`
+	// LastWeekPlayers is the average player count in the same hour a week ago, from history. Nil when
+	// history has no online record in that hour, or no rule uses it.
+	LastWeekPlayers *float64 `json:"last_week_players,omitempty"`
+	// LastWeekChange is the percent change of Players versus LastWeekPlayers, e.g. -60 for a drop from
+	// 50 to 20. Nil when LastWeekPlayers is nil or zero.
+	LastWeekChange *float64 `json:"last_week_change,omitempty"`
+}
+
+// Field kinds.
+const (
+	kindNumber = iota
+	kindBool
+	kindString
+)
+
+// fields are the names a condition may compare, with their kind.
+var fields = map[string]int{
+	"players":      kindNumber,
+	"max_players":  kindNumber,
+	"fill_percent": kindNumber,
+	"failures":     kindNumber,
+	"online":       kindBool,
+	"night":        kindBool,
+	"name":         kindString,
+	"version":      kindString,
+	"map":          kindString,
+	"mods":         kindString,
+
+	"last_week_players": kindNumber,
+	"last_week_change":  kindNumber,
+}
+
+// historyFields are the fields read from the history store.
+var historyFields = []string{"last_week_players", "last_week_change"}
+
+func (s State) field(name string) any {
+	switch name {
+	case "players":
+		return float64(s.Players)
+	case "max_players":
+		return float64(s.MaxPlayers)
+	case "fill_percent":
+		return s.FillPercent
+	case "failures":
+		return float64(s.Failures)
+	case "online":
+		return s.Online
+	case "night":
+		return s.Night
+	case "name":
+		return s.Name
+	case "version":
+		return s.Version
+	case "map":
+		return s.Map
+	case "last_week_players":
+		return floatOrNil(s.LastWeekPlayers)
+	case "last_week_change":
+		return floatOrNil(s.LastWeekChange)
+	default:
+		return s.Mods
+	}
+}
+
+// floatOrNil returns *f, or an untyped nil for a value that is not known.
+func floatOrNil(f *float64) any {
+	if f == nil {
+		return nil
+	}
+	return *f
+}
+
+// opChanged is the operator of a term that holds when the field differs from the previous evaluation.
+const opChanged = "changed"
+
+type term struct {
+	field string
+	op    string
+	value any
+}
+
+// Condition is a parsed rule condition: comparisons such as "players == 0" or "version changed", joined by "and".
+type Condition struct {
+	text  string
+	terms []term
+}
+
+// ParseCondition parses a rule condition.
+func ParseCondition(s string) (*Condition, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("condition is empty")
+	}
+	c := &Condition{text: strings.TrimSpace(s)}
+	for len(tokens) > 0 {
+		name := tokens[0]
+		kind, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		if len(tokens) < 2 {
+			return nil, fmt.Errorf("%s: expected an operator or %q", name, opChanged)
+		}
+		t := term{field: name, op: strings.ToLower(tokens[1])}
+		tokens = tokens[2:]
+		if t.op == opChanged && slices.Contains(historyFields, name) {
+			return nil, fmt.Errorf("%s cannot be %s", name, opChanged)
+		}
+		if t.op != opChanged {
+			if len(tokens) == 0 {
+				return nil, fmt.Errorf("%s %s: expected a value", name, t.op)
+			}
+			if t.value, err = parseValue(kind, t.op, tokens[0]); err != nil {
+				return nil, fmt.Errorf("%s %s %s: %w", name, t.op, tokens[0], err)
+			}
+			tokens = tokens[1:]
+		}
+		c.terms = append(c.terms, t)
+		if len(tokens) == 0 {
+			break
+		}
+		if !strings.EqualFold(tokens[0], "and") || len(tokens) == 1 {
+			return nil, fmt.Errorf("expected \"and\" and another comparison after %s %s", t.field, t.op)
+		}
+		tokens = tokens[1:]
+	}
+	return c, nil
+}
+
+func parseValue(kind int, op, v string) (any, error) {
+	switch op {
+	case "==", "!=":
+	case "<", "<=", ">", ">=":
+		if kind != kindNumber {
+			return nil, fmt.Errorf("%s only compares numbers", op)
+		}
+	default:
+		return nil, fmt.Errorf("unknown operator %q", op)
+	}
+	switch kind {
+	case kindNumber:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("not a number")
+		}
+		return f, nil
+	case kindBool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("not true or false")
+		}
+		return b, nil
+	default:
+		return v, nil
+	}
+}
+
+// tokenize splits s into words, operators, and double-quoted strings.
+func tokenize(s string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"':
+			end := strings.IndexByte(s[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, s[i+1:i+1+end])
+			i += end + 2
+		case strings.IndexByte("=!<>", c) >= 0:
+			j := i + 1
+			if j < len(s) && s[j] == '=' {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		default:
+			j := i
+			for j < len(s) && strings.IndexByte(" \t\"=!<>", s[j]) < 0 {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// String returns the condition as written.
+func (c *Condition) String() string {
+	return c.text
+}
+
+// HasChange returns true when the condition has a "changed" term, which holds for one evaluation only.
+func (c *Condition) HasChange() bool {
+	for _, t := range c.terms {
+		if t.op == opChanged {
+			return true
+		}
+	}
+	return false
+}
+
+// UsesHistory returns true when the condition compares a field read from the history store.
+func (c *Condition) UsesHistory() bool {
+	for _, t := range c.terms {
+		if slices.Contains(historyFields, t.field) {
+			return true
+		}
+	}
+	return false
+}
+
+// Eval returns true when every term holds for cur. A "changed" term compares with prev, and never holds
+// when prev is nil. No term holds for a field that is not known, such as a missing history baseline.
+func (c *Condition) Eval(prev *State, cur State) bool {
+	for _, t := range c.terms {
+		v := cur.field(t.field)
+		var ok bool
+		switch {
+		case v == nil:
+		case t.op == opChanged:
+			ok = prev != nil && prev.field(t.field) != v
+		default:
+			ok = compare(t.op, v, t.value)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func compare(op string, v, value any) bool {
+	switch op {
+	case "==":
+		return v == value
+	case "!=":
+		return v != value
+	case "<":
+		return v.(float64) < value.(float64)
+	case "<=":
+		return v.(float64) <= value.(float64)
+	case ">":
+		return v.(float64) > value.(float64)
+	default:
+		return v.(float64) >= value.(float64)
+	}
+}
+
+// changes describes the fields of the "changed" terms, e.g. "version 1.25 -> 1.26".
+func (c *Condition) changes(prev *State, cur State) []string {
+	if prev == nil {
+		return nil
+	}
+	var out []string
+	for _, t := range c.terms {
+		if t.op == opChanged {
+			out = append(out, fmt.Sprintf("%s %v -> %v", t.field, prev.field(t.field), cur.field(t.field)))
+		}
+	}
+	return out
+}
+
+// Window is a recurring time range, e.g. prime time on weekends.
+type Window struct {
+	// start and end are minutes after midnight; a window with end <= start wraps past midnight.
+	start, end int
+	// days are the weekdays the window starts on.
+	days [7]bool
+	loc  *time.Location
+}
+
+// ParseWindow parses a daily range "HH:MM-HH:MM" on the given weekdays (mon-sun, or full names) in the IANA
+// timezone. An empty range is the whole day, no days is every day, and an empty timezone is the local zone.
+// It returns nil, a window that always contains, when during and days are both empty.
+func ParseWindow(during string, days []string, timezone string) (*Window, error) {
+	if during == "" && len(days) == 0 {
+		return nil, nil
+	}
+	w := &Window{loc: time.Local}
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("timezone: %w", err)
+		}
+		w.loc = loc
+	}
+	if during != "" {
+		from, to, ok := strings.Cut(during, "-")
+		start, err1 := parseClock(from)
+		end, err2 := parseClock(to)
+		if !ok || err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("during must be HH:MM-HH:MM, got %q", during)
+		}
+		if start == end {
+			return nil, fmt.Errorf("during %q is empty; omit it for the whole day", during)
+		}
+		w.start, w.end = start, end
+	}
+	if len(days) == 0 {
+		w.days = [7]bool{true, true, true, true, true, true, true}
+	}
+	for _, d := range days {
+		day, ok := parseDay(d)
+		if !ok {
+			return nil, fmt.Errorf("unknown day %q", d)
+		}
+		w.days[day] = true
+	}
+	return w, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func parseDay(s string) (time.Weekday, bool) {
+	s = strings.ToLower(s)
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || s == name[:3] {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// Contains returns true when t is in the window. The part of a window that wraps past midnight belongs to
+// the day it started on. A nil window contains every time.
+func (w *Window) Contains(t time.Time) bool {
+	if w == nil {
+		return true
+	}
+	t = t.In(w.loc)
+	m := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	switch {
+	case w.start == w.end:
+		// The whole day.
+	case w.start < w.end:
+		if m < w.start || m >= w.end {
+			return false
+		}
+	case m < w.end:
+		day = (day + 6) % 7
+	case m < w.start:
+		return false
+	}
+	return w.days[day]
+}
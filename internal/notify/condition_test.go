@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCondition(t *testing.T) {
+	prev := &State{Online: true, Players: 10, MaxPlayers: 60, Version: "1.25", Map: "chernarusplus"}
+	cur := State{Online: true, Players: 0, MaxPlayers: 60, Version: "1.26", Map: "chernarusplus"}
+	tests := []struct {
+		when    string
+		want    bool
+		wantErr bool
+	}{
+		{when: "players == 0", want: true},
+		{when: "players==0", want: true},
+		{when: "players > 0", want: false},
+		{when: "max_players >= 60 and players < 1", want: true},
+		{when: "online == false", want: false},
+		{when: `map == "chernarusplus"`, want: true},
+		{when: "map != chernarusplus", want: false},
+		{when: "version changed", want: true},
+		{when: "map changed", want: false},
+		{when: "version changed AND players == 0", want: true},
+		{when: "", wantErr: true},
+		{when: "player == 0", wantErr: true},
+		{when: "players", wantErr: true},
+		{when: "players ==", wantErr: true},
+		{when: "players == none", wantErr: true},
+		{when: "map > 3", wantErr: true},
+		{when: "online == maybe", wantErr: true},
+		{when: "players == 0 or online == false", wantErr: true},
+		{when: "players == 0 and", wantErr: true},
+		{when: `map == "chernarus`, wantErr: true},
+		// The baseline is unknown in cur, so no comparison on it holds.
+		{when: "last_week_players >= 0", want: false},
+		{when: "last_week_change != 0", want: false},
+		{when: "last_week_players changed", wantErr: true},
+	}
+	for _, tt := range tests {
+		c, err := ParseCondition(tt.when)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCondition(%q) error = %v, wantErr %v", tt.when, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got := c.Eval(prev, cur); got != tt.want {
+			t.Errorf("%q.Eval() = %v, want %v", tt.when, got, tt.want)
+		}
+	}
+
+	c, _ := ParseCondition("version changed")
+	if c.Eval(nil, cur) {
+		t.Error("a change holds without a previous state")
+	}
+	if got := c.changes(prev, cur); len(got) != 1 || got[0] != "version 1.25 -> 1.26" {
+		t.Errorf("changes() = %q", got)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	w, err := ParseWindow("22:00-02:00", []string{"fri", "Saturday"}, "UTC")
+	if err != nil {
+		t.Fatalf("ParseWindow() error = %v", err)
+	}
+	// 2024-01-05 is a Friday.
+	tests := []struct {
+		at   string
+		want bool
+	}{
+		{"2024-01-05T21:59:00Z", false},
+		{"2024-01-05T22:00:00Z", true},
+		{"2024-01-06T01:59:00Z", true}, // Friday's window, after midnight
+		{"2024-01-06T02:00:00Z", false},
+		{"2024-01-06T23:00:00Z", true},
+		{"2024-01-07T01:00:00Z", true}, // Saturday's window
+		{"2024-01-07T23:00:00Z", false},
+		{"2024-01-08T01:00:00Z", false},
+	}
+	for _, tt := range tests {
+		at, _ := time.Parse(time.RFC3339, tt.at)
+		if got := w.Contains(at); got != tt.want {
+			t.Errorf("Contains(%s) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+
+	if w, err := ParseWindow("", nil, ""); err != nil || w != nil || !w.Contains(time.Now()) {
+		t.Errorf("ParseWindow() without a range or days = %v, %v; want nil, which always contains", w, err)
+	}
+	for _, bad := range []struct {
+		during, tz string
+		days       []string
+	}{
+		{during: "18:00"},
+		{during: "18:00-18:00"},
+		{during: "25:00-02:00"},
+		{during: "18:00-23:00", tz: "Mars/Olympus"},
+		{days: []string{"someday"}},
+	} {
+		if _, err := ParseWindow(bad.during, bad.days, bad.tz); err == nil {
+			t.Errorf("ParseWindow(%q, %q, %q) succeeded, want error", bad.during, bad.days, bad.tz)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EmailOptions configures an email notifier.
+type EmailOptions struct {
+	// Host and Port are the SMTP server. STARTTLS is used when the server offers it.
+	Host string
+	Port int
+	// Username and Password authenticate with PLAIN auth when Username is set; net/smtp only sends them over
+	// TLS or to localhost.
+	Username string
+	Password string
+	From     string
+	To       []string
+	// Redact rewrites the subject and body when set, e.g. to hide IP addresses in sync errors.
+	Redact func(string) string
+}
+
+// EmailNotifier sends events and reports as plain text email.
+type EmailNotifier struct {
+	opts EmailOptions
+}
+
+// NewEmail returns an email notifier.
+func NewEmail(opts EmailOptions) *EmailNotifier {
+	return &EmailNotifier{opts: opts}
+}
+
+// Notify mails the event's message.
+func (n *EmailNotifier) Notify(ctx context.Context, e Event) error {
+	subject := fmt.Sprintf("%s: %s (%d)", e.Rule, e.Server, e.Port)
+	return n.send(ctx, withInstance(e.InstanceName, subject), e.Message)
+}
+
+// SendReport mails the report's text.
+func (n *EmailNotifier) SendReport(ctx context.Context, r Report) error {
+	return n.send(ctx, withInstance(r.InstanceName, r.Name+" report"), r.Text)
+}
+
+func withInstance(instance, subject string) string {
+	if instance == "" {
+		return "dzsa-sync " + subject
+	}
+	return "[" + instance + "] " + subject
+}
+
+// send delivers one message. net/smtp has no context support, so ctx only bounds the wait: a send that is
+// still running when ctx is done finishes in the background.
+func (n *EmailNotifier) send(ctx context.Context, subject, body string) error {
+	if n.opts.Redact != nil {
+		subject, body = n.opts.Redact(subject), n.opts.Redact(body)
+	}
+	msg := emailMessage(n.opts.From, n.opts.To, subject, body, time.Now())
+	var auth smtp.Auth
+	if n.opts.Username != "" {
+		auth = smtp.PlainAuth("", n.opts.Username, n.opts.Password, n.opts.Host)
+	}
+	addr := net.JoinHostPort(n.opts.Host, strconv.Itoa(n.opts.Port))
+	done := make(chan error, 1)
+	go func() { done <- smtp.SendMail(addr, auth, n.opts.From, n.opts.To, msg) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("send mail: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("send mail: %w", ctx.Err())
+	}
+}
+
+// emailMessage returns an RFC 5322 plain text message.
+func emailMessage(from string, to []string, subject, body string, date time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
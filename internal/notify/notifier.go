@@ -0,0 +1,179 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// Notifier types.
+const (
+	// TypeWebhook posts the Event as JSON.
+	TypeWebhook = "webhook"
+	// TypeDiscord posts the message to a Discord webhook.
+	TypeDiscord = "discord"
+	// TypeSlack posts the message to a Slack incoming webhook.
+	TypeSlack = "slack"
+	// TypeEmail sends the message by SMTP.
+	TypeEmail = "email"
+)
+
+// Types are the supported notifier types.
+var Types = []string{TypeWebhook, TypeDiscord, TypeSlack, TypeEmail}
+
+// Methods are the HTTP methods a webhook may use. The first is the default.
+var Methods = []string{http.MethodPost, http.MethodPut, http.MethodPatch}
+
+// bodyFuncs are the functions available to body templates.
+var bodyFuncs = template.FuncMap{
+	// json encodes a value as JSON, e.g. {{json .Error}} for a quoted and escaped string.
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// ParseBody parses a webhook body template. Besides the built-in functions, templates can use json.
+func ParseBody(text string) (*template.Template, error) {
+	return template.New("body").Funcs(bodyFuncs).Option("missingkey=error").Parse(text)
+}
+
+// discordMaxContent is the longest message Discord accepts.
+const discordMaxContent = 2000
+
+// Event is a notification sent when a rule fires for a server.
+type Event struct {
+	Rule         string `json:"rule"`
+	InstanceName string `json:"instance_name,omitempty"`
+	Server       string `json:"server"`
+	Port         int    `json:"port"`
+	Condition    string `json:"condition"`
+	// Since is when the condition started holding.
+	Since time.Time `json:"since"`
+	Time  time.Time `json:"time"`
+	// Message is a one-line summary, as sent to chat notifiers.
+	Message string `json:"message"`
+	State   State  `json:"state"`
+	// Previous is the state at the evaluation before, when there was one.
+	Previous *State `json:"previous,omitempty"`
+}
+
+// Notifier sends events and reports somewhere. Implementations must be safe for concurrent use.
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+	SendReport(ctx context.Context, r Report) error
+}
+
+// HTTPOptions configures an HTTP notifier.
+type HTTPOptions struct {
+	// Client sends the requests. Nil uses a client with a 10s timeout.
+	Client *http.Client
+	// Type is one of Types.
+	Type string
+	URL  string
+	// Method is one of Methods. Empty is POST.
+	Method string
+	// Headers are sent with every request, e.g. Authorization. Content-Type defaults to application/json.
+	Headers map[string]string
+	// Body renders the body of webhook requests from the data sent, e.g. the Event. Nil sends the data as JSON.
+	Body *template.Template
+	// Redact rewrites the request body when set, e.g. to hide IP addresses in sync errors.
+	Redact func(string) string
+}
+
+// HTTPNotifier posts events to a webhook URL in the format of its type.
+type HTTPNotifier struct {
+	opts HTTPOptions
+}
+
+// NewHTTP returns a notifier that posts to opts.URL.
+func NewHTTP(opts HTTPOptions) *HTTPNotifier {
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if opts.Method == "" {
+		opts.Method = Methods[0]
+	}
+	return &HTTPNotifier{opts: opts}
+}
+
+// Notify posts e and returns an error unless the endpoint answers 2xx.
+func (n *HTTPNotifier) Notify(ctx context.Context, e Event) error {
+	return n.post(ctx, e, e.Message)
+}
+
+// SendReport posts r and returns an error unless the endpoint answers 2xx. Discord receives the text
+// shortened to its message limit.
+func (n *HTTPNotifier) SendReport(ctx context.Context, r Report) error {
+	return n.post(ctx, r, r.Text)
+}
+
+// Send sends data to a webhook in the body its template renders, or as JSON without one, and returns an error
+// unless the endpoint answers 2xx.
+func (n *HTTPNotifier) Send(ctx context.Context, data any) error {
+	if n.opts.Body == nil {
+		return n.post(ctx, data, "")
+	}
+	var body bytes.Buffer
+	if err := n.opts.Body.Execute(&body, data); err != nil {
+		return fmt.Errorf("render body: %w", err)
+	}
+	return n.do(ctx, body.Bytes())
+}
+
+// post sends v as JSON to a webhook, or text to a chat notifier.
+func (n *HTTPNotifier) post(ctx context.Context, v any, text string) error {
+	body := v
+	switch n.opts.Type {
+	case TypeDiscord:
+		if len(text) > discordMaxContent {
+			cut := discordMaxContent - len("\n…")
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			text = text[:cut] + "\n…"
+		}
+		body = map[string]string{"content": text}
+	case TypeSlack:
+		body = map[string]string{"text": text}
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("encode body: %w", err)
+	}
+	return n.do(ctx, b)
+}
+
+// do sends b, redacted, and returns an error unless the endpoint answers 2xx.
+func (n *HTTPNotifier) do(ctx context.Context, b []byte) error {
+	if n.opts.Redact != nil {
+		b = []byte(n.opts.Redact(string(b)))
+	}
+	req, err := http.NewRequestWithContext(ctx, n.opts.Method, n.opts.URL, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "dzsa-sync/1.0")
+	for k, v := range n.opts.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := n.opts.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status code: %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
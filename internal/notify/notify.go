@@ -0,0 +1,350 @@
+// Package notify evaluates notification rules against the state of every managed server and sends an
+// event to the rule's notifiers when a rule fires. Rules are conditions such as "players == 0" or
+// "version changed", optionally held for a duration, limited to servers and a time window, and rate
+// limited per server by a cooldown. A ReportRunner sends scheduled summaries of the history to the same
+// notifiers.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/history"
+	"github.com/jsirianni/dzsa-sync/internal/metrics"
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultCooldown is the minimum time between notifications of a rule for one server when unset.
+	DefaultCooldown = time.Hour
+	// defaultInterval is how often rules are evaluated in addition to every store change, so durations and
+	// windows are noticed when nothing changes.
+	defaultInterval = time.Minute
+	// sendTimeout bounds each notifier call.
+	sendTimeout = 10 * time.Second
+	// baselineAge is how far back the history baseline of the last_week_* fields is.
+	baselineAge = 7 * 24 * time.Hour
+)
+
+// Rule is a parsed rules entry.
+type Rule struct {
+	Name      string
+	Condition *Condition
+	// For is how long the condition must hold before the rule fires.
+	For time.Duration
+	// Servers are the server names or ports the rule applies to. Empty is every server.
+	Servers []string
+	// Window limits when the rule fires. Nil is always.
+	Window *Window
+	// Notify names the notifiers the event is sent to.
+	Notify []string
+	// Cooldown is the minimum time between notifications for one server. Zero uses DefaultCooldown.
+	Cooldown time.Duration
+}
+
+// matches returns true when filter, a list of server names and ports, is empty or names srv.
+func matches(filter []string, srv Server) bool {
+	return len(filter) == 0 || slices.Contains(filter, srv.Name) || slices.Contains(filter, strconv.Itoa(srv.Port))
+}
+
+// Server is a managed server rules are evaluated for.
+type Server struct {
+	Name string
+	Port int
+}
+
+// Options configures an Engine.
+type Options struct {
+	// Logger logs notifications and notifier errors. Nil disables logging.
+	Logger *zap.Logger
+	// Store is read for each server's latest result and sync state, and its changes trigger an evaluation.
+	Store *servers.Store
+	// Servers returns the managed servers.
+	Servers func() []Server
+	Rules   []Rule
+	// Notifiers are the notifiers rules refer to, by name.
+	Notifiers map[string]Notifier
+	// InstanceName is included in every event and message when set.
+	InstanceName string
+	// Active reports whether this instance sends notifications, e.g. while it is the HA leader. Nil is always.
+	Active func() bool
+	// Recorder records notification_count when set.
+	Recorder metrics.NotificationRecorder
+	// Interval is the time between evaluations when the store does not change. Zero uses 1m.
+	Interval time.Duration
+	// History is read for the last_week_* fields. Nil leaves them unknown, so conditions on them never hold.
+	History history.Reader
+}
+
+// Engine evaluates rules and sends notifications.
+type Engine struct {
+	opts Options
+	// prev is each server's state at the last evaluation, for "changed" terms.
+	prev map[int]State
+	// rules holds each rule's state per server, keyed by rule index and port.
+	rules map[ruleKey]*ruleState
+	// baselines caches each server's history baseline for the current hour.
+	baselines map[int]baseline
+	// useHistory is true when a rule reads history.
+	useHistory bool
+}
+
+// baseline is a server's average player count in one hour a week ago.
+type baseline struct {
+	// hour is the start of the hour a week ago.
+	hour    time.Time
+	players float64
+	ok      bool
+}
+
+type ruleKey struct {
+	rule, port int
+}
+
+type ruleState struct {
+	// since is when the condition started holding; zero while it does not.
+	since time.Time
+	// sent is true once the rule fired during the current stretch of the condition holding.
+	sent bool
+	// last is when the rule last fired.
+	last time.Time
+}
+
+// New returns an engine. Call Run to start evaluating.
+func New(opts Options) *Engine {
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultInterval
+	}
+	e := &Engine{opts: opts, prev: make(map[int]State), rules: make(map[ruleKey]*ruleState), baselines: make(map[int]baseline)}
+	for _, r := range opts.Rules {
+		e.useHistory = e.useHistory || (opts.History != nil && r.Condition.UsesHistory())
+	}
+	return e
+}
+
+// Run evaluates the rules on every store change and every interval until ctx is done.
+func (e *Engine) Run(ctx context.Context) {
+	changes, unsubscribe := e.opts.Store.Subscribe()
+	defer unsubscribe()
+	ticker := time.NewTicker(e.opts.Interval)
+	defer ticker.Stop()
+	for {
+		e.Evaluate(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-changes:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Evaluate checks every rule against every managed server as of now and sends the notifications of rules
+// that fire. A rule fires when its condition has held for its For duration, once per stretch of the
+// condition holding, and not within its cooldown of the last notification for the same server.
+func (e *Engine) Evaluate(ctx context.Context, now time.Time) {
+	if e.opts.Active != nil && !e.opts.Active() {
+		// Start over when this instance takes over, rather than acting on state from before.
+		clear(e.prev)
+		clear(e.rules)
+		return
+	}
+	entries := make(map[int]servers.ServerEntry)
+	for _, entry := range e.opts.Store.GetAll() {
+		entries[entry.Port] = entry
+	}
+	managed := make(map[int]bool)
+	for _, srv := range e.opts.Servers() {
+		managed[srv.Port] = true
+		cur, ok := e.state(ctx, srv, entries[srv.Port], now)
+		if !ok {
+			// Not synced yet, or in maintenance: hold every rule and keep the previous state, so a change
+			// made during maintenance is reported once it ends.
+			for key, st := range e.rules {
+				if key.port == srv.Port {
+					st.since, st.sent = time.Time{}, false
+				}
+			}
+			continue
+		}
+		var prev *State
+		if p, ok := e.prev[srv.Port]; ok {
+			prev = &p
+		}
+		for i := range e.opts.Rules {
+			r := &e.opts.Rules[i]
+			if !matches(r.Servers, srv) {
+				continue
+			}
+			key := ruleKey{i, srv.Port}
+			st := e.rules[key]
+			if st == nil {
+				st = &ruleState{}
+				e.rules[key] = st
+			}
+			if !r.Window.Contains(now) || !r.Condition.Eval(prev, cur) {
+				st.since, st.sent = time.Time{}, false
+				continue
+			}
+			if st.since.IsZero() {
+				st.since = now
+			}
+			cooldown := r.Cooldown
+			if cooldown <= 0 {
+				cooldown = DefaultCooldown
+			}
+			if st.sent || now.Sub(st.since) < r.For || (!st.last.IsZero() && now.Sub(st.last) < cooldown) {
+				continue
+			}
+			st.sent, st.last = true, now
+			e.send(ctx, r, srv, prev, cur, st.since, now)
+		}
+		e.prev[srv.Port] = cur
+	}
+	for port := range e.prev {
+		if !managed[port] {
+			delete(e.prev, port)
+		}
+	}
+	for key := range e.rules {
+		if !managed[key.port] {
+			delete(e.rules, key)
+		}
+	}
+	for port := range e.baselines {
+		if !managed[port] {
+			delete(e.baselines, port)
+		}
+	}
+}
+
+// state returns what conditions see of srv, whose store entry is entry, and false before its first sync
+// attempt or during maintenance.
+func (e *Engine) state(ctx context.Context, srv Server, entry servers.ServerEntry, now time.Time) (State, bool) {
+	sync, ok := e.opts.Store.GetSyncState(srv.Port)
+	if !ok || e.opts.Store.InMaintenance(srv.Port, now) {
+		return State{}, false
+	}
+	s := State{Name: srv.Name, Online: sync.LastError == "", Failures: sync.ConsecutiveFailures, Error: sync.LastError}
+	if r := entry.Result; r != nil {
+		s.Players, s.MaxPlayers = r.Players, r.MaxPlayers
+		if r.MaxPlayers > 0 {
+			s.FillPercent = float64(r.Players) * 100 / float64(r.MaxPlayers)
+		}
+		s.Version, s.Map, s.Mods = r.Version, r.Map, history.ModsHash(r.Mods)
+	}
+	if d := entry.Daylight; d != nil {
+		// Night flips at the estimated phase change; later changes wait for the next sync.
+		s.Night = d.Night != (!d.PhaseChangeAt.IsZero() && !now.Before(d.PhaseChangeAt))
+	}
+	if !s.Online {
+		// The last result is stale; an offline server has no players.
+		s.Players, s.FillPercent = 0, 0
+	}
+	if e.useHistory {
+		if b := e.baseline(ctx, srv.Port, now); b.ok {
+			s.LastWeekPlayers = &b.players
+			if b.players > 0 {
+				change := (float64(s.Players) - b.players) * 100 / b.players
+				s.LastWeekChange = &change
+			}
+		}
+	}
+	return s, true
+}
+
+// baseline returns the average player count of port's successful syncs in the same hour a week before now, from
+// raw records or an hourly aggregate. It is queried once per hour; a failed query is not retried within the hour.
+func (e *Engine) baseline(ctx context.Context, port int, now time.Time) baseline {
+	hour := now.Add(-baselineAge).Truncate(time.Hour)
+	if b, ok := e.baselines[port]; ok && b.hour.Equal(hour) {
+		return b
+	}
+	b := baseline{hour: hour}
+	records, err := e.opts.History.Query(ctx, history.Query{Port: port, From: hour, To: hour.Add(time.Hour - time.Millisecond)})
+	if err != nil {
+		e.opts.Logger.Warn("query history baseline", zap.Int("port", port), zap.Error(err))
+	}
+	var sum, n int
+	for _, r := range records {
+		sum += r.Players * r.OnlineSyncs()
+		n += r.OnlineSyncs()
+	}
+	if n > 0 {
+		b.players, b.ok = float64(sum)/float64(n), true
+	}
+	e.baselines[port] = b
+	return b
+}
+
+func (e *Engine) send(ctx context.Context, r *Rule, srv Server, prev *State, cur State, since, now time.Time) {
+	ev := Event{
+		Rule:         r.Name,
+		InstanceName: e.opts.InstanceName,
+		Server:       srv.Name,
+		Port:         srv.Port,
+		Condition:    r.Condition.String(),
+		Since:        since.UTC(),
+		Time:         now.UTC(),
+		State:        cur,
+		Previous:     prev,
+	}
+	ev.Message = message(r, ev, r.Condition.changes(prev, cur))
+	logger := e.opts.Logger.With(zap.String("rule", r.Name), zap.String("server", srv.Name), zap.Int("port", srv.Port))
+	logger.Info("rule fired", zap.String("condition", ev.Condition))
+	for _, name := range r.Notify {
+		n, ok := e.opts.Notifiers[name]
+		if !ok {
+			continue
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err := n.Notify(sendCtx, ev)
+		cancel()
+		result := "sent"
+		if err != nil {
+			result = "failed"
+			logger.Error("send notification", zap.String("notifier", name), zap.Error(err))
+		}
+		if e.opts.Recorder != nil {
+			e.opts.Recorder.RecordNotification(ctx, name, result)
+		}
+	}
+}
+
+// message returns the one-line summary of an event, e.g.
+// "[box-1] empty: main (2424): players == 0 for 2h0m0s (players 0/60, chernarusplus)".
+func message(r *Rule, ev Event, changes []string) string {
+	var b strings.Builder
+	if ev.InstanceName != "" {
+		fmt.Fprintf(&b, "[%s] ", ev.InstanceName)
+	}
+	fmt.Fprintf(&b, "%s: %s (%d): %s", r.Name, ev.Server, ev.Port, ev.Condition)
+	if r.For > 0 {
+		fmt.Fprintf(&b, " for %s", r.For)
+	}
+	details := changes
+	switch s := ev.State; {
+	case !s.Online && s.Error != "":
+		details = append(details, "offline: "+s.Error)
+	case !s.Online:
+		details = append(details, "offline")
+	default:
+		details = append(details, fmt.Sprintf("players %d/%d", s.Players, s.MaxPlayers))
+		if s.Map != "" {
+			details = append(details, s.Map)
+		}
+	}
+	if s := ev.State; s.LastWeekPlayers != nil && r.Condition.UsesHistory() {
+		details = append(details, fmt.Sprintf("last week %.0f", *s.LastWeekPlayers))
+	}
+	fmt.Fprintf(&b, " (%s)", strings.Join(details, ", "))
+	return b.String()
+}
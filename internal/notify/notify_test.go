@@ -0,0 +1,285 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/history"
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+	"github.com/jsirianni/dzsa-sync/model"
+)
+
+type fakeNotifier struct {
+	events  []Event
+	reports []Report
+}
+
+func (n *fakeNotifier) Notify(_ context.Context, e Event) error {
+	n.events = append(n.events, e)
+	return nil
+}
+
+func (n *fakeNotifier) SendReport(_ context.Context, r Report) error {
+	n.reports = append(n.reports, r)
+	return nil
+}
+
+func mustRule(t *testing.T, r Rule, when string) Rule {
+	t.Helper()
+	c, err := ParseCondition(when)
+	if err != nil {
+		t.Fatalf("ParseCondition(%q) error = %v", when, err)
+	}
+	r.Condition = c
+	return r
+}
+
+func TestEngine(t *testing.T) {
+	store := servers.New([]int{2424, 2324})
+	set := func(port, players int, version string) {
+		store.Set(port, &model.Result{Name: "main", Players: players, MaxPlayers: 60, Version: version, Map: "chernarusplus"})
+		store.RecordSync(port, time.Now(), nil)
+	}
+	set(2424, 0, "1.25")
+	set(2324, 0, "1.25")
+
+	ops := &fakeNotifier{}
+	active := true
+	e := New(Options{
+		Store:        store,
+		Servers:      func() []Server { return []Server{{Name: "main", Port: 2424}, {Name: "modded", Port: 2324}} },
+		Notifiers:    map[string]Notifier{"ops": ops},
+		InstanceName: "box-1",
+		Active:       func() bool { return active },
+		Rules: []Rule{
+			mustRule(t, Rule{Name: "empty", For: 2 * time.Hour, Servers: []string{"main"}, Notify: []string{"ops"}}, "players == 0"),
+			mustRule(t, Rule{Name: "update", Servers: []string{"2424"}, Notify: []string{"ops"}}, "version changed"),
+		},
+	})
+	ctx := context.Background()
+	t0 := time.Date(2024, 1, 5, 12, 0, 0, 0, time.UTC)
+	fired := func(want ...string) {
+		t.Helper()
+		var got []string
+		for _, ev := range ops.events {
+			got = append(got, ev.Rule)
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Fatalf("fired %v, want %v", got, want)
+		}
+	}
+
+	e.Evaluate(ctx, t0)
+	e.Evaluate(ctx, t0.Add(time.Hour))
+	fired()
+	e.Evaluate(ctx, t0.Add(2*time.Hour))
+	fired("empty")
+	if ev := ops.events[0]; ev.Server != "main" || ev.Port != 2424 || !ev.Since.Equal(t0) ||
+		ev.Message != "[box-1] empty: main (2424): players == 0 for 2h0m0s (players 0/60, chernarusplus)" {
+		t.Errorf("event = %+v", ev)
+	}
+	// Once per stretch of the condition holding.
+	e.Evaluate(ctx, t0.Add(3*time.Hour))
+	fired("empty")
+
+	// Players join and leave: a new stretch starts and fires after For, outside the cooldown.
+	set(2424, 5, "1.25")
+	e.Evaluate(ctx, t0.Add(3*time.Hour))
+	set(2424, 0, "1.25")
+	e.Evaluate(ctx, t0.Add(3*time.Hour+time.Minute))
+	e.Evaluate(ctx, t0.Add(5*time.Hour+time.Minute))
+	fired("empty", "empty")
+
+	set(2424, 0, "1.26")
+	e.Evaluate(ctx, t0.Add(5*time.Hour+2*time.Minute))
+	fired("empty", "empty", "update")
+	if ev := ops.events[2]; !strings.Contains(ev.Message, "version 1.25 -> 1.26") || ev.Previous == nil || ev.Previous.Version != "1.25" {
+		t.Errorf("change event = %+v", ev)
+	}
+	// A change fires once, and another within the cooldown is not sent.
+	e.Evaluate(ctx, t0.Add(5*time.Hour+3*time.Minute))
+	set(2424, 0, "1.27")
+	e.Evaluate(ctx, t0.Add(5*time.Hour+4*time.Minute))
+	fired("empty", "empty", "update")
+
+	// A failed sync makes the server offline with no players; an inactive instance sends nothing.
+	store.RecordSync(2424, time.Now(), errors.New("do request: timeout"))
+	active = false
+	e.Evaluate(ctx, t0.Add(9*time.Hour))
+	fired("empty", "empty", "update")
+	active = true
+	e.Evaluate(ctx, t0.Add(9*time.Hour))
+	e.Evaluate(ctx, t0.Add(11*time.Hour))
+	fired("empty", "empty", "update", "empty")
+	if ev := ops.events[3]; ev.State.Online || !strings.Contains(ev.Message, "offline: do request: timeout") {
+		t.Errorf("offline event = %+v", ev)
+	}
+}
+
+type fakeHistory struct {
+	records []history.Record
+	queries int
+}
+
+func (h *fakeHistory) Query(_ context.Context, q history.Query) ([]history.Record, error) {
+	h.queries++
+	var out []history.Record
+	for _, r := range h.records {
+		if r.Port == q.Port && !r.Time.Before(q.From) && !r.Time.After(q.To) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func TestEngine_LastWeek(t *testing.T) {
+	t0 := time.Date(2024, 1, 12, 20, 15, 0, 0, time.UTC)
+	weekAgo := t0.Add(-7 * 24 * time.Hour)
+	hist := &fakeHistory{records: []history.Record{
+		{Time: weekAgo.Add(-20 * time.Minute), Port: 2424, Online: true, Players: 10},
+		{Time: weekAgo.Add(-10 * time.Minute), Port: 2424, Online: true, Players: 40},
+		{Time: weekAgo.Add(10 * time.Minute), Port: 2424, Online: true, Players: 50},
+		{Time: weekAgo.Add(20 * time.Minute), Port: 2424},
+	}}
+	store := servers.New([]int{2424})
+	store.Set(2424, &model.Result{Name: "main", Players: 20, MaxPlayers: 60})
+	store.RecordSync(2424, t0, nil)
+	ops := &fakeNotifier{}
+	e := New(Options{
+		Store:     store,
+		Servers:   func() []Server { return []Server{{Name: "main", Port: 2424}} },
+		Notifiers: map[string]Notifier{"ops": ops},
+		History:   hist,
+		Rules:     []Rule{mustRule(t, Rule{Name: "drop", Notify: []string{"ops"}}, "last_week_change <= -50 and last_week_players >= 10")},
+	})
+
+	// 20:00-21:00 a week ago averaged 45 online players, so 20 is a drop of 55%.
+	e.Evaluate(context.Background(), t0)
+	e.Evaluate(context.Background(), t0.Add(time.Minute))
+	if len(ops.events) != 1 {
+		t.Fatalf("fired %d times, want 1", len(ops.events))
+	}
+	s := ops.events[0].State
+	if s.LastWeekPlayers == nil || *s.LastWeekPlayers != 45 || s.LastWeekChange == nil || int(*s.LastWeekChange) != -55 {
+		t.Errorf("state = %+v", s)
+	}
+	if !strings.HasSuffix(ops.events[0].Message, "(players 20/60, last week 45)") {
+		t.Errorf("message = %q", ops.events[0].Message)
+	}
+	if hist.queries != 1 {
+		t.Errorf("history queried %d times in one hour, want 1", hist.queries)
+	}
+
+	// A week ago at 21:00 there is no record, so the baseline is unknown and the rule does not hold.
+	e.Evaluate(context.Background(), t0.Add(time.Hour))
+	if hist.queries != 2 || len(ops.events) != 1 {
+		t.Errorf("queries = %d, events = %d after an hour without a baseline", hist.queries, len(ops.events))
+	}
+}
+
+func TestHTTPNotifier(t *testing.T) {
+	var got map[string]any
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		if strings.HasSuffix(r.URL.Path, "/fail") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	ev := Event{Rule: "offline", Server: "main", Port: 2424, Message: "main is offline: dial 203.0.113.10:2424"}
+	redact := func(s string) string { return strings.ReplaceAll(s, "203.0.113.10", "ip-redacted") }
+	ctx := context.Background()
+
+	tests := []struct {
+		typ, key, want string
+	}{
+		{TypeDiscord, "content", "main is offline: dial ip-redacted:2424"},
+		{TypeSlack, "text", "main is offline: dial ip-redacted:2424"},
+		{TypeWebhook, "rule", "offline"},
+	}
+	for _, tt := range tests {
+		n := NewHTTP(HTTPOptions{Type: tt.typ, URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer x"}, Redact: redact})
+		if err := n.Notify(ctx, ev); err != nil {
+			t.Fatalf("%s Notify() error = %v", tt.typ, err)
+		}
+		if got[tt.key] != tt.want || auth != "Bearer x" {
+			t.Errorf("%s body = %v, authorization %q", tt.typ, got, auth)
+		}
+	}
+	if err := NewHTTP(HTTPOptions{Type: TypeWebhook, URL: srv.URL + "/fail"}).Notify(ctx, ev); err == nil {
+		t.Error("Notify() to a failing endpoint succeeded, want error")
+	}
+}
+
+func TestHTTPNotifierSend(t *testing.T) {
+	var method, contentType, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		method, contentType, body = r.Method, r.Header.Get("Content-Type"), string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	data := struct {
+		Server string
+		Error  string
+	}{"main", `dial 203.0.113.10: "refused"`}
+	redact := func(s string) string { return strings.ReplaceAll(s, "203.0.113.10", "ip-redacted") }
+	ctx := context.Background()
+
+	tmpl, err := ParseBody(`{"text": "{{.Server}} failed", "error": {{json .Error}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := NewHTTP(HTTPOptions{Type: TypeWebhook, URL: srv.URL, Method: http.MethodPut, Body: tmpl, Redact: redact})
+	if err := n.Send(ctx, data); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	want := `{"text": "main failed", "error": "dial ip-redacted: \"refused\""}`
+	if method != http.MethodPut || contentType != "application/json" || body != want {
+		t.Errorf("request = %s %q %s, want PUT with JSON %s", method, contentType, body, want)
+	}
+
+	// Without a template the data is sent as JSON.
+	if err := NewHTTP(HTTPOptions{Type: TypeWebhook, URL: srv.URL}).Send(ctx, data); err != nil {
+		t.Fatalf("Send() without a template error = %v", err)
+	}
+	if method != http.MethodPost || !strings.Contains(body, `"Server":"main"`) {
+		t.Errorf("request = %s %s, want POST with the data as JSON", method, body)
+	}
+
+	missing, _ := ParseBody(`{{.Port}}`)
+	if err := NewHTTP(HTTPOptions{Type: TypeWebhook, URL: srv.URL, Body: missing}).Send(ctx, data); err == nil {
+		t.Error("Send() with a template of an unknown field: error = nil")
+	}
+}
+
+func TestEmailMessage(t *testing.T) {
+	date := time.Date(2024, 1, 10, 8, 0, 0, 0, time.UTC)
+	msg := string(emailMessage("dzsa@example.com", []string{"a@example.com", "b@example.com"}, "[box-1] daily report", "line 1\nline 2", date))
+	for _, want := range []string{
+		"From: dzsa@example.com\r\n",
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: [box-1] daily report\r\n",
+		"Date: Wed, 10 Jan 2024 08:00:00 +0000\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"\r\n\r\nline 1\r\nline 2\r\n",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message = %q, want %q", msg, want)
+		}
+	}
+	if subject := withInstance("", "daily report"); subject != "dzsa-sync daily report" {
+		t.Errorf("withInstance() = %q", subject)
+	}
+}
@@ -0,0 +1,323 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/history"
+	"github.com/jsirianni/dzsa-sync/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// Report schedules.
+const (
+	ScheduleDaily  = "daily"
+	ScheduleWeekly = "weekly"
+)
+
+// reportQueryLimit caps the records read per server for one report, e.g. a week of syncs every minute.
+const reportQueryLimit = 100000
+
+// Report is a summary of every server's sync history over a period, sent by a ReportRunner.
+type Report struct {
+	Name         string          `json:"report"`
+	InstanceName string          `json:"instance_name,omitempty"`
+	From         time.Time       `json:"from"`
+	To           time.Time       `json:"to"`
+	Servers      []ServerSummary `json:"servers"`
+	IPChanges    []IPChange      `json:"ip_changes"`
+	// IPChangesSince is set when IP changes were only recorded from after From, i.e. since the process started.
+	IPChangesSince time.Time `json:"ip_changes_since,omitzero"`
+	// Text is the report rendered for chat and email.
+	Text string `json:"text"`
+}
+
+// ServerSummary is one server's part of a Report.
+type ServerSummary struct {
+	Server string `json:"server"`
+	Port   int    `json:"port"`
+	// Syncs is the number of recorded sync attempts, and Failures those that failed.
+	Syncs    int `json:"syncs"`
+	Failures int `json:"failures"`
+	// UptimePercent is the share of syncs that succeeded.
+	UptimePercent float64   `json:"uptime_percent"`
+	PeakPlayers   int       `json:"peak_players"`
+	PeakAt        time.Time `json:"peak_at,omitzero"`
+	AvgPlayers    float64   `json:"avg_players"`
+}
+
+// IPChange is a change of the instance's external IP.
+type IPChange struct {
+	Time time.Time `json:"time"`
+	Old  string    `json:"old"`
+	New  string    `json:"new"`
+}
+
+// IPLog records external IP changes in memory for reports.
+type IPLog struct {
+	mu      sync.Mutex
+	started time.Time
+	changes []IPChange
+}
+
+// ipLogRetention is how long IP changes are kept: the longest report period and a margin.
+const ipLogRetention = 8 * 24 * time.Hour
+
+// NewIPLog returns an empty log that starts recording now.
+func NewIPLog() *IPLog {
+	return &IPLog{started: time.Now()}
+}
+
+// Record adds a change at t and drops changes too old for any report.
+func (l *IPLog) Record(t time.Time, oldIP, newIP string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.changes = slices.DeleteFunc(l.changes, func(c IPChange) bool { return t.Sub(c.Time) > ipLogRetention })
+	l.changes = append(l.changes, IPChange{Time: t.UTC(), Old: oldIP, New: newIP})
+}
+
+// Between returns the changes in [from, to), and when the log started when that is after from.
+func (l *IPLog) Between(from, to time.Time) ([]IPChange, time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := []IPChange{}
+	for _, c := range l.changes {
+		if !c.Time.Before(from) && c.Time.Before(to) {
+			out = append(out, c)
+		}
+	}
+	var since time.Time
+	if l.started.After(from) {
+		since = l.started.UTC()
+	}
+	return out, since
+}
+
+// Schedule is when a report is sent: every day, or every week on Weekday, at Hour:Minute in Location.
+type Schedule struct {
+	Weekly  bool
+	Weekday time.Weekday
+	// Hour and Minute are the time of day.
+	Hour, Minute int
+	Location     *time.Location
+}
+
+// ParseSchedule parses a report schedule: "daily" or "weekly", a time of day "HH:MM" (empty is 00:00), a
+// weekday for weekly reports (mon-sun, empty is Monday), and an IANA timezone (empty is the local zone).
+func ParseSchedule(schedule, at, day, timezone string) (Schedule, error) {
+	s := Schedule{Location: time.Local, Weekday: time.Monday}
+	switch schedule {
+	case ScheduleDaily:
+	case ScheduleWeekly:
+		s.Weekly = true
+	default:
+		return Schedule{}, fmt.Errorf("schedule must be %q or %q, got %q", ScheduleDaily, ScheduleWeekly, schedule)
+	}
+	if at != "" {
+		m, err := parseClock(at)
+		if err != nil {
+			return Schedule{}, fmt.Errorf("at must be HH:MM, got %q", at)
+		}
+		s.Hour, s.Minute = m/60, m%60
+	}
+	if day != "" {
+		d, ok := parseDay(day)
+		if !ok {
+			return Schedule{}, fmt.Errorf("unknown day %q", day)
+		}
+		if !s.Weekly {
+			return Schedule{}, fmt.Errorf("day is only used by %s reports", ScheduleWeekly)
+		}
+		s.Weekday = d
+	}
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return Schedule{}, fmt.Errorf("timezone: %w", err)
+		}
+		s.Location = loc
+	}
+	return s, nil
+}
+
+// Period is the time a report covers, ending when it is sent.
+func (s Schedule) Period() time.Duration {
+	if s.Weekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// Next returns the first time after t the report is due.
+func (s Schedule) Next(t time.Time) time.Time {
+	t = t.In(s.Location)
+	next := time.Date(t.Year(), t.Month(), t.Day(), s.Hour, s.Minute, 0, 0, s.Location)
+	if s.Weekly {
+		next = next.AddDate(0, 0, (int(s.Weekday)-int(next.Weekday())+7)%7)
+	}
+	for !next.After(t) {
+		next = next.AddDate(0, 0, int(s.Period()/(24*time.Hour)))
+	}
+	return next
+}
+
+// ReportOptions configures a ReportRunner.
+type ReportOptions struct {
+	// Logger logs sent reports and errors. Nil disables logging.
+	Logger *zap.Logger
+	// Name identifies the report.
+	Name     string
+	Schedule Schedule
+	// History is read for each server's syncs in the period.
+	History history.Reader
+	// Servers returns the managed servers; Filter limits the report to some of them by name or port.
+	Servers func() []Server
+	Filter  []string
+	// IPLog is read for external IP changes when set.
+	IPLog *IPLog
+	// Notifiers are the notifiers the report is sent to.
+	Notifiers map[string]Notifier
+	// InstanceName is included in the report when set.
+	InstanceName string
+	// Active reports whether this instance sends reports, e.g. while it is the HA leader. Nil is always.
+	Active func() bool
+	// Recorder records notification_count when set.
+	Recorder metrics.NotificationRecorder
+}
+
+// ReportRunner builds and sends a report on its schedule.
+type ReportRunner struct {
+	opts ReportOptions
+}
+
+// NewReportRunner returns a runner. Call Run to start sending.
+func NewReportRunner(opts ReportOptions) *ReportRunner {
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+	return &ReportRunner{opts: opts}
+}
+
+// Run sends the report whenever it is due until ctx is done. Reports due while the process was not running
+// are not sent.
+func (r *ReportRunner) Run(ctx context.Context) {
+	for {
+		next := r.opts.Schedule.Next(time.Now())
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if r.opts.Active != nil && !r.opts.Active() {
+			continue
+		}
+		report, err := r.Build(ctx, next.Add(-r.opts.Schedule.Period()), next)
+		if err != nil {
+			r.opts.Logger.Error("build report", zap.String("report", r.opts.Name), zap.Error(err))
+			continue
+		}
+		r.send(ctx, report)
+	}
+}
+
+// Build summarizes every matching server's history in [from, to).
+func (r *ReportRunner) Build(ctx context.Context, from, to time.Time) (Report, error) {
+	rep := Report{Name: r.opts.Name, InstanceName: r.opts.InstanceName, From: from.UTC(), To: to.UTC(), Servers: []ServerSummary{}, IPChanges: []IPChange{}}
+	for _, srv := range r.opts.Servers() {
+		if !matches(r.opts.Filter, srv) {
+			continue
+		}
+		records, err := r.opts.History.Query(ctx, history.Query{Port: srv.Port, From: from, To: to.Add(-time.Millisecond), Limit: reportQueryLimit})
+		if err != nil {
+			return Report{}, fmt.Errorf("query history for %s: %w", srv.Name, err)
+		}
+		rep.Servers = append(rep.Servers, summarize(srv, records))
+	}
+	if r.opts.IPLog != nil {
+		rep.IPChanges, rep.IPChangesSince = r.opts.IPLog.Between(from, to)
+	}
+	rep.Text = reportText(rep, r.opts.Schedule.Location)
+	return rep, nil
+}
+
+// summarize counts the syncs of records, weighting hourly aggregates by the syncs they stand for.
+func summarize(srv Server, records []history.Record) ServerSummary {
+	s := ServerSummary{Server: srv.Name, Port: srv.Port}
+	var online, players int
+	for _, rec := range records {
+		n := rec.OnlineSyncs()
+		s.Syncs += rec.Syncs()
+		s.Failures += rec.Syncs() - n
+		if n == 0 {
+			continue
+		}
+		online += n
+		players += rec.Players * n
+		if rec.Peak() > s.PeakPlayers || s.PeakAt.IsZero() {
+			s.PeakPlayers, s.PeakAt = rec.Peak(), rec.Time.UTC()
+		}
+	}
+	if s.Syncs > 0 {
+		s.UptimePercent = float64(online) * 100 / float64(s.Syncs)
+	}
+	if online > 0 {
+		s.AvgPlayers = float64(players) / float64(online)
+	}
+	return s
+}
+
+// reportText renders a report as plain text lines, with times in loc.
+func reportText(rep Report, loc *time.Location) string {
+	const layout = "2006-01-02 15:04"
+	var b strings.Builder
+	if rep.InstanceName != "" {
+		fmt.Fprintf(&b, "[%s] ", rep.InstanceName)
+	}
+	fmt.Fprintf(&b, "%s report, %s to %s %s\n", rep.Name, rep.From.In(loc).Format(layout), rep.To.In(loc).Format(layout), loc)
+	for _, s := range rep.Servers {
+		fmt.Fprintf(&b, "%s (%d): ", s.Server, s.Port)
+		if s.Syncs == 0 {
+			b.WriteString("no syncs recorded\n")
+			continue
+		}
+		if s.PeakAt.IsZero() {
+			fmt.Fprintf(&b, "offline, %d of %d syncs failed\n", s.Failures, s.Syncs)
+			continue
+		}
+		fmt.Fprintf(&b, "peak %d at %s, avg %.1f, uptime %.1f%%, %d of %d syncs failed\n",
+			s.PeakPlayers, s.PeakAt.In(loc).Format(layout), s.AvgPlayers, s.UptimePercent, s.Failures, s.Syncs)
+	}
+	fmt.Fprintf(&b, "External IP changes: %d", len(rep.IPChanges))
+	if !rep.IPChangesSince.IsZero() {
+		fmt.Fprintf(&b, " (since %s)", rep.IPChangesSince.In(loc).Format(layout))
+	}
+	for _, c := range rep.IPChanges {
+		fmt.Fprintf(&b, "\n%s: %s -> %s", c.Time.In(loc).Format(layout), c.Old, c.New)
+	}
+	return b.String()
+}
+
+func (r *ReportRunner) send(ctx context.Context, rep Report) {
+	logger := r.opts.Logger.With(zap.String("report", r.opts.Name))
+	for name, n := range r.opts.Notifiers {
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err := n.SendReport(sendCtx, rep)
+		cancel()
+		result := "sent"
+		if err != nil {
+			result = "failed"
+			logger.Error("send report", zap.String("notifier", name), zap.Error(err))
+		} else {
+			logger.Info("report sent", zap.String("notifier", name), zap.Int("servers", len(rep.Servers)))
+		}
+		if r.opts.Recorder != nil {
+			r.opts.Recorder.RecordNotification(ctx, name, result)
+		}
+	}
+}
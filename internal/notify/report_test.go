@@ -0,0 +1,148 @@
+package notify
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/history"
+)
+
+func TestParseSchedule(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	cases := []struct {
+		name                  string
+		schedule, at, day, tz string
+		from                  time.Time
+		want                  time.Time
+		wantErr               string
+	}{
+		{name: "daily later today", schedule: "daily", at: "08:30", tz: "UTC",
+			from: time.Date(2024, 1, 10, 6, 0, 0, 0, time.UTC), want: time.Date(2024, 1, 10, 8, 30, 0, 0, time.UTC)},
+		{name: "daily tomorrow", schedule: "daily", at: "08:30", tz: "UTC",
+			from: time.Date(2024, 1, 10, 8, 30, 0, 0, time.UTC), want: time.Date(2024, 1, 11, 8, 30, 0, 0, time.UTC)},
+		{name: "weekly default monday midnight", schedule: "weekly", tz: "UTC",
+			from: time.Date(2024, 1, 10, 6, 0, 0, 0, time.UTC), want: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{name: "weekly same day later", schedule: "weekly", at: "18:00", day: "wed", tz: "UTC",
+			from: time.Date(2024, 1, 10, 6, 0, 0, 0, time.UTC), want: time.Date(2024, 1, 10, 18, 0, 0, 0, time.UTC)},
+		{name: "weekly same day passed", schedule: "weekly", at: "18:00", day: "Wednesday", tz: "UTC",
+			from: time.Date(2024, 1, 10, 19, 0, 0, 0, time.UTC), want: time.Date(2024, 1, 17, 18, 0, 0, 0, time.UTC)},
+		{name: "timezone", schedule: "daily", at: "09:00", tz: "Europe/Berlin",
+			from: time.Date(2024, 1, 10, 9, 0, 0, 0, time.UTC), want: time.Date(2024, 1, 11, 9, 0, 0, 0, berlin)},
+		{name: "unknown schedule", schedule: "hourly", wantErr: "schedule must be"},
+		{name: "bad time", schedule: "daily", at: "25:00", wantErr: "at must be HH:MM"},
+		{name: "day on daily", schedule: "daily", day: "mon", wantErr: "only used by weekly"},
+		{name: "unknown day", schedule: "weekly", day: "someday", wantErr: "unknown day"},
+		{name: "unknown timezone", schedule: "daily", tz: "Nowhere/City", wantErr: "timezone"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := ParseSchedule(tc.schedule, tc.at, tc.day, tc.tz)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("ParseSchedule() error = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSchedule() error = %v", err)
+			}
+			if got := s.Next(tc.from); !got.Equal(tc.want) {
+				t.Errorf("Next(%s) = %s, want %s", tc.from, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReportRunner_Build(t *testing.T) {
+	from := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	hist := &fakeHistory{records: []history.Record{
+		{Time: from.Add(-time.Minute), Port: 2424, Online: true, Players: 60},
+		{Time: from.Add(1 * time.Hour), Port: 2424, Online: true, Players: 10},
+		{Time: from.Add(20 * time.Hour), Port: 2424, Online: true, Players: 42},
+		{Time: from.Add(21 * time.Hour), Port: 2424, Online: true, Players: 20},
+		{Time: from.Add(22 * time.Hour), Port: 2424, Error: "timeout"},
+		{Time: from.Add(2 * time.Hour), Port: 2324, Error: "refused"},
+		{Time: to, Port: 2424, Online: true, Players: 60},
+	}}
+	ipLog := &IPLog{started: from.Add(-time.Hour)}
+	ipLog.Record(from.Add(-time.Hour), "203.0.113.1", "203.0.113.2")
+	ipLog.Record(from.Add(5*time.Hour), "203.0.113.2", "203.0.113.3")
+	ops := &fakeNotifier{}
+	r := NewReportRunner(ReportOptions{
+		Name:     "daily",
+		Schedule: Schedule{Location: time.UTC},
+		History:  hist,
+		Servers: func() []Server {
+			return []Server{{Name: "main", Port: 2424}, {Name: "modded", Port: 2324}, {Name: "test", Port: 2524}}
+		},
+		Filter:       []string{"main", "2324", "2524"},
+		IPLog:        ipLog,
+		Notifiers:    map[string]Notifier{"ops": ops},
+		InstanceName: "box-1",
+	})
+
+	rep, err := r.Build(context.Background(), from, to)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if len(rep.Servers) != 3 {
+		t.Fatalf("Build() servers = %+v, want 3", rep.Servers)
+	}
+	main := rep.Servers[0]
+	if main.Syncs != 4 || main.Failures != 1 || main.UptimePercent != 75 || main.PeakPlayers != 42 ||
+		!main.PeakAt.Equal(from.Add(20*time.Hour)) || main.AvgPlayers != 24 {
+		t.Errorf("main summary = %+v", main)
+	}
+	if modded := rep.Servers[1]; modded.Syncs != 1 || modded.Failures != 1 || modded.UptimePercent != 0 || !modded.PeakAt.IsZero() {
+		t.Errorf("modded summary = %+v", modded)
+	}
+	if len(rep.IPChanges) != 1 || rep.IPChanges[0].New != "203.0.113.3" || !rep.IPChangesSince.IsZero() {
+		t.Errorf("IP changes = %+v since %s, want the one in the period", rep.IPChanges, rep.IPChangesSince)
+	}
+	want := strings.Join([]string{
+		"[box-1] daily report, 2024-01-10 00:00 to 2024-01-11 00:00 UTC",
+		"main (2424): peak 42 at 2024-01-10 20:00, avg 24.0, uptime 75.0%, 1 of 4 syncs failed",
+		"modded (2324): offline, 1 of 1 syncs failed",
+		"test (2524): no syncs recorded",
+		"External IP changes: 1",
+		"2024-01-10 05:00: 203.0.113.2 -> 203.0.113.3",
+	}, "\n")
+	if rep.Text != want {
+		t.Errorf("Text =\n%s\nwant\n%s", rep.Text, want)
+	}
+
+	r.send(context.Background(), rep)
+	if len(ops.reports) != 1 || ops.reports[0].Name != "daily" {
+		t.Errorf("sent reports = %+v, want the daily report", ops.reports)
+	}
+
+	// A log started during the period says since when changes are known.
+	ipLog.started = from.Add(3 * time.Hour)
+	rep, err = r.Build(context.Background(), from, to)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if !strings.Contains(rep.Text, "External IP changes: 1 (since 2024-01-10 03:00)") {
+		t.Errorf("Text = %s, want the log start", rep.Text)
+	}
+}
+
+func TestSummarize_Aggregates(t *testing.T) {
+	from := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	got := summarize(Server{Name: "main", Port: 2424}, []history.Record{
+		{Time: from, Port: 2424, Online: true, Players: 20, Samples: 60, Failed: 20, PeakPlayers: 35},
+		{Time: from.Add(time.Hour), Port: 2424, Samples: 60, Failed: 60},
+		{Time: from.Add(2 * time.Hour), Port: 2424, Online: true, Players: 30},
+	})
+	want := ServerSummary{Server: "main", Port: 2424, Syncs: 121, Failures: 80, UptimePercent: 41 * 100.0 / 121,
+		PeakPlayers: 35, PeakAt: from, AvgPlayers: (20*40 + 30) / 41.0}
+	if got != want {
+		t.Errorf("summarize() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,121 @@
+// Package redact hides IP addresses in logs, API responses, and stored history for operators whose log
+// handling must not retain them. Addresses are either replaced by a keyed hash, which still lets equal
+// addresses be correlated, or truncated to their network.
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"sync"
+)
+
+// Redaction modes.
+const (
+	ModeHash     = "hash"
+	ModeTruncate = "truncate"
+)
+
+// Truncation prefixes: the network of an IPv4 address and the site of an IPv6 address.
+const (
+	truncateBits4 = 24
+	truncateBits6 = 48
+)
+
+// ipPattern matches IPv4 and IPv6 literals. Matches are parsed before being replaced, so version strings
+// and timestamps that only look alike are left alone.
+var ipPattern = regexp.MustCompile(`(?i)(?:[0-9a-f]{0,4}:){2,7}[0-9a-f.]*|\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+
+// Options configures a Redactor.
+type Options struct {
+	// Mode is ModeHash or ModeTruncate.
+	Mode string
+	// Key keys the hash. Empty uses a random key, so hashes differ after a restart.
+	Key string
+	// RedactServerIP also redacts the address servers are registered with. By default it is kept, since
+	// it is published on the server browser anyway.
+	RedactServerIP bool
+}
+
+// Redactor replaces IP addresses. A nil Redactor returns its input unchanged. Safe for concurrent use.
+type Redactor struct {
+	opts Options
+	key  []byte
+
+	mu     sync.RWMutex
+	server func() string
+}
+
+// New returns a Redactor, or an error for an unknown mode.
+func New(opts Options) (*Redactor, error) {
+	if opts.Mode != ModeHash && opts.Mode != ModeTruncate {
+		return nil, fmt.Errorf("unknown redaction mode %q", opts.Mode)
+	}
+	key := []byte(opts.Key)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("generate hash key: %w", err)
+		}
+	}
+	return &Redactor{opts: opts, key: key}, nil
+}
+
+// SetServerAddress sets the function returning the server's own external IP, which is kept unless
+// Options.RedactServerIP is set. Until it is called, every address is redacted.
+func (r *Redactor) SetServerAddress(address func() string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.server = address
+	r.mu.Unlock()
+}
+
+// IP returns the redacted form of ip. Loopback and unspecified addresses, and strings that are not an
+// IP address, are returned unchanged.
+func (r *Redactor) IP(ip string) string {
+	if r == nil {
+		return ip
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || addr.IsLoopback() || addr.IsUnspecified() || r.isServer(addr) {
+		return ip
+	}
+	if r.opts.Mode == ModeTruncate {
+		bits := truncateBits6
+		if addr.Unmap().Is4() {
+			addr, bits = addr.Unmap(), truncateBits4
+		}
+		return netip.PrefixFrom(addr, bits).Masked().String()
+	}
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(addr.Unmap().String()))
+	return "ip-" + hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
+// String redacts every IP address in s, e.g. an error message.
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	return ipPattern.ReplaceAllStringFunc(s, r.IP)
+}
+
+func (r *Redactor) isServer(addr netip.Addr) bool {
+	if r.opts.RedactServerIP {
+		return false
+	}
+	r.mu.RLock()
+	server := r.server
+	r.mu.RUnlock()
+	if server == nil {
+		return false
+	}
+	s, err := netip.ParseAddr(server())
+	return err == nil && s.Unmap() == addr.Unmap()
+}
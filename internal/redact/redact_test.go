@@ -0,0 +1,88 @@
+package redact
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestIP(t *testing.T) {
+	trunc, err := New(Options{Mode: ModeTruncate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := New(Options{Mode: ModeHash, Key: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		r    *Redactor
+		in   string
+		want string
+	}{
+		{trunc, "203.0.113.57", "203.0.113.0/24"},
+		{trunc, "2001:db8:1:2::5", "2001:db8:1::/48"},
+		{trunc, "::ffff:198.51.100.7", "198.51.100.0/24"},
+		{trunc, "127.0.0.1", "127.0.0.1"},
+		{trunc, "not an ip", "not an ip"},
+		{nil, "203.0.113.57", "203.0.113.57"},
+	}
+	for _, tc := range cases {
+		if got := tc.r.IP(tc.in); got != tc.want {
+			t.Errorf("IP(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+
+	h := hash.IP("203.0.113.57")
+	if !strings.HasPrefix(h, "ip-") || len(h) != 15 || h != hash.IP("::ffff:203.0.113.57") {
+		t.Errorf("IP() hash = %q, want a stable ip-<12 hex>", h)
+	}
+	other, _ := New(Options{Mode: ModeHash, Key: "other"})
+	if other.IP("203.0.113.57") == h {
+		t.Error("hashes do not depend on the key")
+	}
+	if _, err := New(Options{Mode: "mask"}); err == nil {
+		t.Error("New() accepted an unknown mode")
+	}
+}
+
+func TestString(t *testing.T) {
+	r, _ := New(Options{Mode: ModeTruncate})
+	r.SetServerAddress(func() string { return "198.51.100.7" })
+	in := `Get "https://dayzsalauncher.com/api/v1/query/198.51.100.7:2424": dial tcp 203.0.113.57:443 via [2001:db8::1]:53 at 01:46:33.611, version 1.25.158593`
+	want := `Get "https://dayzsalauncher.com/api/v1/query/198.51.100.7:2424": dial tcp 203.0.113.0/24:443 via [2001:db8::/48]:53 at 01:46:33.611, version 1.25.158593`
+	if got := r.String(in); got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+
+	all, _ := New(Options{Mode: ModeTruncate, RedactServerIP: true})
+	all.SetServerAddress(func() string { return "198.51.100.7" })
+	if got := all.String("endpoint 198.51.100.7:2424"); got != "endpoint 198.51.100.0/24:2424" {
+		t.Errorf("String() with RedactServerIP = %q", got)
+	}
+}
+
+func TestCore(t *testing.T) {
+	r, _ := New(Options{Mode: ModeTruncate})
+	obs, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(r.Core(obs)).With(zap.String("peer", "203.0.113.57"))
+	logger.Error("lookup from 203.0.113.58 failed",
+		zap.Error(errors.New("dial 203.0.113.59:443: refused")),
+		zap.Int("port", 2424))
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("logged %d entries, want 1", len(entries))
+	}
+	if got := entries[0].Message; got != "lookup from 203.0.113.0/24 failed" {
+		t.Errorf("message = %q", got)
+	}
+	fields := entries[0].ContextMap()
+	if fields["peer"] != "203.0.113.0/24" || fields["error"] != "dial 203.0.113.0/24:443: refused" || fields["port"] != int64(2424) {
+		t.Errorf("fields = %v", fields)
+	}
+}
@@ -0,0 +1,58 @@
+package redact
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Core returns a zapcore.Core that redacts the message and the string, error, and Stringer fields of
+// every entry before passing it to c. A nil Redactor returns c.
+func (r *Redactor) Core(c zapcore.Core) zapcore.Core {
+	if r == nil {
+		return c
+	}
+	return &core{Core: c, r: r}
+}
+
+type core struct {
+	zapcore.Core
+	r *Redactor
+}
+
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	return &core{Core: c.Core.With(c.r.fields(fields)), r: c.r}
+}
+
+func (c *core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ent.Message = c.r.String(ent.Message)
+	return c.Core.Write(ent, c.r.fields(fields))
+}
+
+func (r *Redactor) fields(fields []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		switch f.Type {
+		case zapcore.StringType:
+			f.String = r.String(f.String)
+		case zapcore.ErrorType:
+			if err, ok := f.Interface.(error); ok && err != nil {
+				f = zap.String(f.Key, r.String(err.Error()))
+			}
+		case zapcore.StringerType:
+			if s, ok := f.Interface.(fmt.Stringer); ok && s != nil {
+				f = zap.String(f.Key, r.String(s.String()))
+			}
+		}
+		out[i] = f
+	}
+	return out
+}
@@ -0,0 +1,230 @@
+// Package remotewrite pushes metrics to a Prometheus remote_write endpoint (Mimir, VictoriaMetrics,
+// Grafana Cloud) for hosts where running a scraping Prometheus is not feasible.
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// DefaultInterval is the default time between pushes.
+	DefaultInterval = 30 * time.Second
+	// MetricPrefix limits pushed series to dzsa-sync's own metrics (not Go runtime or process metrics).
+	MetricPrefix = "dzsa_sync_"
+)
+
+// Options configures a Writer.
+type Options struct {
+	Logger *zap.Logger
+	// Client is used for pushes. Nil uses a default client.
+	Client *http.Client
+	// Gatherer is the metric source. Nil uses prometheus.DefaultGatherer, which the OTel exporter registers with.
+	Gatherer prometheus.Gatherer
+	// URL is the remote_write endpoint.
+	URL string
+	// Headers are sent with every push, e.g. X-Scope-OrgID.
+	Headers map[string]string
+	// Username and Password enable basic auth when Username is set.
+	Username string
+	Password string
+	// Labels are added to every series, e.g. instance.
+	Labels map[string]string
+	// Interval is the time between pushes. Zero uses DefaultInterval.
+	Interval time.Duration
+}
+
+// Writer periodically gathers metrics and pushes them to a remote_write endpoint.
+type Writer struct {
+	opts Options
+}
+
+// New returns a remote_write Writer.
+func New(opts Options) *Writer {
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	if opts.Gatherer == nil {
+		opts.Gatherer = prometheus.DefaultGatherer
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	return &Writer{opts: opts}
+}
+
+// Run pushes every Interval until ctx is cancelled. Failed pushes are logged and not retried; the next
+// push carries current values.
+func (w *Writer) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.Push(ctx); err != nil {
+				w.opts.Logger.Error("remote write failed", zap.String("url", w.opts.URL), zap.Error(err))
+			}
+		case <-ctx.Done():
+			w.opts.Logger.Info("remote write loop shutting down")
+			return
+		}
+	}
+}
+
+// Push gathers the current metrics and sends them in one remote_write request.
+func (w *Writer) Push(ctx context.Context) error {
+	families, err := w.opts.Gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("gather metrics: %w", err)
+	}
+	series := convert(families, w.opts.Labels, time.Now())
+	if len(series) == 0 {
+		return nil
+	}
+	body := snappy.Encode(nil, encodeWriteRequest(series))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "dzsa-sync/1.0")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	for k, v := range w.opts.Headers {
+		req.Header.Set(k, v)
+	}
+	if w.opts.Username != "" {
+		req.SetBasicAuth(w.opts.Username, w.opts.Password)
+	}
+
+	resp, err := w.opts.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status code: %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+type label struct {
+	name, value string
+}
+
+type timeSeries struct {
+	labels    []label
+	value     float64
+	timestamp int64
+}
+
+// convert flattens metric families into remote_write series. Histograms and summaries are expanded
+// into their _bucket/_sum/_count (and quantile) series, as Prometheus does when scraping.
+func convert(families []*dto.MetricFamily, extra map[string]string, now time.Time) []timeSeries {
+	ts := now.UnixMilli()
+	var out []timeSeries
+	add := func(name string, m *dto.Metric, value float64, extraLabel *label) {
+		labels := make([]label, 0, len(m.GetLabel())+len(extra)+2)
+		labels = append(labels, label{"__name__", name})
+		for _, lp := range m.GetLabel() {
+			labels = append(labels, label{lp.GetName(), lp.GetValue()})
+		}
+		if extraLabel != nil {
+			labels = append(labels, *extraLabel)
+		}
+		for k, v := range extra {
+			labels = append(labels, label{k, v})
+		}
+		sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+		out = append(out, timeSeries{labels: labels, value: value, timestamp: ts})
+	}
+
+	for _, mf := range families {
+		name := mf.GetName()
+		if !strings.HasPrefix(name, MetricPrefix) {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, m, m.GetCounter().GetValue(), nil)
+			case dto.MetricType_GAUGE:
+				add(name, m, m.GetGauge().GetValue(), nil)
+			case dto.MetricType_UNTYPED:
+				add(name, m, m.GetUntyped().GetValue(), nil)
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					add(name+"_bucket", m, float64(b.GetCumulativeCount()), &label{"le", formatFloat(b.GetUpperBound())})
+				}
+				add(name+"_bucket", m, float64(h.GetSampleCount()), &label{"le", "+Inf"})
+				add(name+"_sum", m, h.GetSampleSum(), nil)
+				add(name+"_count", m, float64(h.GetSampleCount()), nil)
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add(name, m, q.GetValue(), &label{"quantile", formatFloat(q.GetQuantile())})
+				}
+				add(name+"_sum", m, s.GetSampleSum(), nil)
+				add(name+"_count", m, float64(s.GetSampleCount()), nil)
+			}
+		}
+	}
+	return out
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// encodeWriteRequest encodes series as a prometheus.WriteRequest protobuf message:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(series []timeSeries) []byte {
+	var out []byte
+	for _, s := range series {
+		var tsBuf []byte
+		for _, l := range s.labels {
+			var lb []byte
+			lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.name)
+			lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.value)
+			tsBuf = protowire.AppendTag(tsBuf, 1, protowire.BytesType)
+			tsBuf = protowire.AppendBytes(tsBuf, lb)
+		}
+		var sb []byte
+		sb = protowire.AppendTag(sb, 1, protowire.Fixed64Type)
+		sb = protowire.AppendFixed64(sb, math.Float64bits(s.value))
+		sb = protowire.AppendTag(sb, 2, protowire.VarintType)
+		sb = protowire.AppendVarint(sb, uint64(s.timestamp)) // #nosec G115 -- protobuf int64 is encoded as its two's complement varint
+		tsBuf = protowire.AppendTag(tsBuf, 2, protowire.BytesType)
+		tsBuf = protowire.AppendBytes(tsBuf, sb)
+
+		out = protowire.AppendTag(out, 1, protowire.BytesType)
+		out = protowire.AppendBytes(out, tsBuf)
+	}
+	return out
+}
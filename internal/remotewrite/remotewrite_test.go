@@ -0,0 +1,128 @@
+package remotewrite
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodeWriteRequest is the inverse of encodeWriteRequest, returning each series as a label map plus value.
+func decodeWriteRequest(t *testing.T, b []byte) []map[string]string {
+	t.Helper()
+	var out []map[string]string
+	for len(b) > 0 {
+		_, _, n := protowire.ConsumeTag(b)
+		b = b[n:]
+		tsBuf, n := protowire.ConsumeBytes(b)
+		b = b[n:]
+		series := map[string]string{}
+		for len(tsBuf) > 0 {
+			num, _, n := protowire.ConsumeTag(tsBuf)
+			tsBuf = tsBuf[n:]
+			msg, n := protowire.ConsumeBytes(tsBuf)
+			tsBuf = tsBuf[n:]
+			switch num {
+			case 1:
+				_, _, n := protowire.ConsumeTag(msg)
+				name, m := protowire.ConsumeString(msg[n:])
+				msg = msg[n+m:]
+				_, _, n = protowire.ConsumeTag(msg)
+				value, _ := protowire.ConsumeString(msg[n:])
+				series[name] = value
+			case 2:
+				_, _, n := protowire.ConsumeTag(msg)
+				v, _ := protowire.ConsumeFixed64(msg[n:])
+				series["_value"] = formatFloat(math.Float64frombits(v))
+			}
+		}
+		out = append(out, series)
+	}
+	return out
+}
+
+func TestWriter_Push(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	players := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "dzsa_sync_server_player_count"}, []string{"server"})
+	players.WithLabelValues("main").Set(12)
+	latency := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "dzsa_sync_request_latency_seconds", Buckets: []float64{0.5}})
+	latency.Observe(0.2)
+	other := prometheus.NewGauge(prometheus.GaugeOpts{Name: "go_goroutines_test"})
+	reg.MustRegister(players, latency, other)
+
+	var got []map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("X-Scope-OrgID") != "edge" {
+			t.Errorf("headers = %v", r.Header)
+		}
+		if u, p, ok := r.BasicAuth(); !ok || u != "user" || p != "pass" {
+			t.Errorf("basic auth = %s, %s, %v", u, p, ok)
+		}
+		compressed, _ := io.ReadAll(r.Body)
+		b, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Fatalf("snappy decode: %v", err)
+		}
+		got = decodeWriteRequest(t, b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	w := New(Options{
+		Logger:   zap.NewNop(),
+		Client:   server.Client(),
+		Gatherer: reg,
+		URL:      server.URL,
+		Headers:  map[string]string{"X-Scope-OrgID": "edge"},
+		Username: "user",
+		Password: "pass",
+		Labels:   map[string]string{"instance": "edge-1"},
+	})
+	if err := w.Push(context.Background()); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+
+	// gauge + 2 buckets + sum + count; go_goroutines_test is filtered out.
+	if len(got) != 5 {
+		t.Fatalf("got %d series, want 5: %v", len(got), got)
+	}
+	find := func(name, le string) map[string]string {
+		for _, s := range got {
+			if s["__name__"] == name && s["le"] == le {
+				return s
+			}
+		}
+		return nil
+	}
+	if s := find("dzsa_sync_server_player_count", ""); s == nil || s["server"] != "main" || s["instance"] != "edge-1" || s["_value"] != "12" {
+		t.Errorf("player count series = %v", s)
+	}
+	if s := find("dzsa_sync_request_latency_seconds_bucket", "0.5"); s == nil || s["_value"] != "1" {
+		t.Errorf("bucket series = %v", s)
+	}
+	if s := find("dzsa_sync_request_latency_seconds_bucket", "+Inf"); s == nil || s["_value"] != "1" {
+		t.Errorf("+Inf bucket series = %v", s)
+	}
+}
+
+func TestWriter_PushError(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	g := prometheus.NewGauge(prometheus.GaugeOpts{Name: "dzsa_sync_test"})
+	reg.MustRegister(g)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "out of order sample", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	w := New(Options{Logger: zap.NewNop(), Client: server.Client(), Gatherer: reg, URL: server.URL})
+	if err := w.Push(context.Background()); err == nil {
+		t.Error("Push() expected error for 400 response")
+	}
+}
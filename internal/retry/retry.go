@@ -0,0 +1,85 @@
+// Package retry provides the retry budget shared by all sync workers, the backoff between retries, and the
+// schedule of syncs after failed ones.
+package retry
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Defaults used when the matching config field is zero.
+const (
+	DefaultBackoff    = 5 * time.Second
+	DefaultMaxBackoff = time.Minute
+	DefaultBudget     = 10
+)
+
+// DefaultFailureSchedule is the delay before the sync following the first, second, and any further
+// consecutive failed sync.
+var DefaultFailureSchedule = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+// Results recorded by metrics.RetryRecorder.
+const (
+	ResultAllowed   = "allowed"
+	ResultExhausted = "exhausted"
+)
+
+// Budget is a token bucket of retries refilled at a fixed rate per minute. Workers take a token before
+// each retry and give up when none is left, so the extra load on a degraded upstream stays bounded no
+// matter how many servers fail at once. A nil Budget allows every retry. Safe for concurrent use.
+type Budget struct {
+	mu     sync.Mutex
+	max    float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewBudget returns a full budget of perMinute retries. perMinute <= 0 uses DefaultBudget.
+func NewBudget(perMinute int) *Budget {
+	if perMinute <= 0 {
+		perMinute = DefaultBudget
+	}
+	b := &Budget{max: float64(perMinute), tokens: float64(perMinute), now: time.Now}
+	b.last = b.now()
+	return b
+}
+
+// Allow takes a retry from the budget and reports whether one was available.
+func (b *Budget) Allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	b.tokens = min(b.max, b.tokens+now.Sub(b.last).Minutes()*b.max)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Backoff returns the delay before retry attempt (starting at 0): base doubled per attempt, capped at
+// maxDelay, with the upper half randomized so workers that failed together do not retry together.
+func Backoff(base, maxDelay time.Duration, attempt int) time.Duration {
+	d := maxDelay
+	if attempt < 32 && base<<attempt > 0 && base<<attempt < maxDelay {
+		d = base << attempt
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1)) // #nosec G404 -- jitter only, not security-sensitive
+}
+
+// FailureDelay returns the delay before the next sync after failures (at least 1) consecutive failed syncs:
+// the matching schedule entry, the last one once they run out, capped at interval. An empty schedule
+// returns interval.
+func FailureDelay(schedule []time.Duration, failures int, interval time.Duration) time.Duration {
+	if len(schedule) == 0 || failures < 1 {
+		return interval
+	}
+	return min(schedule[min(failures, len(schedule))-1], interval)
+}
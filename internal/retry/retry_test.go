@@ -0,0 +1,110 @@
+package retry
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBudget(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	b := NewBudget(3)
+	b.now = func() time.Time { return now }
+	b.last = now
+
+	for i := 0; i < 3; i++ {
+		if !b.Allow() {
+			t.Fatalf("retry %d denied with budget left", i)
+		}
+	}
+	if b.Allow() {
+		t.Fatal("retry allowed with the budget spent")
+	}
+
+	// 3 per minute refills one every 20s.
+	now = now.Add(20 * time.Second)
+	if !b.Allow() || b.Allow() {
+		t.Error("expected exactly one retry after 20s")
+	}
+	now = now.Add(time.Hour)
+	n := 0
+	for b.Allow() {
+		n++
+	}
+	if n != 3 {
+		t.Errorf("refilled to %d retries after an hour, want the cap of 3", n)
+	}
+
+	var nilBudget *Budget
+	if !nilBudget.Allow() {
+		t.Error("nil budget denied a retry")
+	}
+}
+
+func TestBudgetShared(t *testing.T) {
+	b := NewBudget(50)
+	var (
+		mu      sync.Mutex
+		allowed int
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.Allow() {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	// A few tokens may refill while the goroutines run.
+	if allowed < 50 || allowed > 51 {
+		t.Errorf("%d of 200 concurrent retries allowed, want 50", allowed)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{0, 2500 * time.Millisecond, 5 * time.Second},
+		{1, 5 * time.Second, 10 * time.Second},
+		{3, 20 * time.Second, 40 * time.Second},
+		{4, 30 * time.Second, time.Minute},
+		{100, 30 * time.Second, time.Minute},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 50; i++ {
+			if d := Backoff(DefaultBackoff, DefaultMaxBackoff, tt.attempt); d < tt.min || d > tt.max {
+				t.Fatalf("Backoff(attempt %d) = %v, want %v-%v", tt.attempt, d, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestFailureDelay(t *testing.T) {
+	tests := []struct {
+		schedule []time.Duration
+		failures int
+		interval time.Duration
+		want     time.Duration
+	}{
+		{DefaultFailureSchedule, 1, time.Hour, time.Minute},
+		{DefaultFailureSchedule, 2, time.Hour, 5 * time.Minute},
+		{DefaultFailureSchedule, 3, time.Hour, 15 * time.Minute},
+		{DefaultFailureSchedule, 10, time.Hour, 15 * time.Minute},
+		// A step longer than the interval would delay the sync past its normal time.
+		{DefaultFailureSchedule, 3, 10 * time.Minute, 10 * time.Minute},
+		{nil, 1, time.Hour, time.Hour},
+		{[]time.Duration{}, 2, time.Hour, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := FailureDelay(tt.schedule, tt.failures, tt.interval); got != tt.want {
+			t.Errorf("FailureDelay(%v, %d, %v) = %v, want %v", tt.schedule, tt.failures, tt.interval, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Package schedule provides due times that hold across host suspend and clock drift. Go timers run on the
+// monotonic clock, which stops while the host is suspended (common on low-end VPSes and Windows hosts that
+// sleep), so a timer set for an hour fires an hour of uptime later, however long the host slept. Loops built
+// on this package sleep at most CheckInterval at a time and check both clocks on every wake.
+package schedule
+
+import "time"
+
+const (
+	// CheckInterval is the longest a loop sleeps before it checks whether its due time passed.
+	CheckInterval = 30 * time.Second
+	// ResumeGap is how far the wall clock must run ahead of the monotonic clock between two wakes for a
+	// loop to treat it as a resume from suspend, or a forward clock jump.
+	ResumeGap = time.Minute
+)
+
+// Due is an absolute due time. It is due when either clock reaches it: the wall clock after the host was
+// suspended, and the monotonic clock after the wall clock was set back.
+type Due struct {
+	at time.Time
+}
+
+// In returns the time d after now. now should come from time.Now, so it holds a monotonic reading.
+func In(now time.Time, d time.Duration) Due {
+	return Due{at: now.Add(d)}
+}
+
+// Time returns the due time.
+func (d Due) Time() time.Time {
+	return d.at
+}
+
+// Passed reports whether d is due at now.
+func (d Due) Passed(now time.Time) bool {
+	return !now.Before(d.at) || !now.Round(0).Before(d.at.Round(0))
+}
+
+// Wait returns how long to sleep at now before checking d again: the time left on the earlier clock, at most
+// CheckInterval.
+func (d Due) Wait(now time.Time) time.Duration {
+	return max(min(d.at.Sub(now), d.at.Round(0).Sub(now.Round(0)), CheckInterval), 0)
+}
+
+// Next returns the due time interval after d, so a fixed interval holds however long the work at d took. When
+// that has passed too, e.g. after a long suspend, it returns the time interval after now instead of catching up.
+func (d Due) Next(now time.Time, interval time.Duration) Due {
+	next := Due{at: d.at.Add(interval)}
+	if next.Passed(now) {
+		return In(now, interval)
+	}
+	return next
+}
+
+// Watch detects resumes from suspend between wakes of a loop.
+type Watch struct {
+	last time.Time
+}
+
+// NewWatch returns a watch whose first wake is at now.
+func NewWatch(now time.Time) *Watch {
+	return &Watch{last: now}
+}
+
+// Resumed records a wake at now and returns how far the wall clock ran ahead of the monotonic clock since the
+// previous one, and whether that is more than ResumeGap.
+func (w *Watch) Resumed(now time.Time) (time.Duration, bool) {
+	gap := Gap(w.last, now)
+	w.last = now
+	return gap, gap > ResumeGap
+}
+
+// Gap returns how much longer the wall clock says passed from last to now than the monotonic clock: the time
+// the host was suspended, or the size of a clock jump. Without monotonic readings it is zero.
+func Gap(last, now time.Time) time.Duration {
+	return now.Round(0).Sub(last.Round(0)) - now.Sub(last)
+}
@@ -0,0 +1,49 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDue(t *testing.T) {
+	now := time.Now()
+	d := In(now, time.Hour)
+
+	if d.Passed(now) || !d.Passed(now.Add(time.Hour)) {
+		t.Errorf("Passed() before and at the due time = %v, %v", d.Passed(now), d.Passed(now.Add(time.Hour)))
+	}
+	// A time read after a suspend: the wall clock is past the due time, the monotonic clock is not.
+	// Without a monotonic reading, only the wall clock is compared.
+	if !d.Passed(now.Add(2 * time.Hour).Round(0)) {
+		t.Error("Passed() is false once the wall clock is past the due time")
+	}
+	if got := d.Wait(now); got != CheckInterval {
+		t.Errorf("Wait() an hour ahead = %v, want %v", got, CheckInterval)
+	}
+	if got := d.Wait(now.Add(time.Hour - time.Second)); got != time.Second {
+		t.Errorf("Wait() a second ahead = %v, want 1s", got)
+	}
+	if got := d.Wait(now.Add(2 * time.Hour)); got != 0 {
+		t.Errorf("Wait() past the due time = %v, want 0", got)
+	}
+
+	// Next keeps the cadence when the work took a while, and restarts it after a long gap.
+	if got := d.Next(now.Add(time.Hour+10*time.Second), time.Hour).Time(); !got.Equal(now.Add(2 * time.Hour)) {
+		t.Errorf("Next() = %v, want %v", got, now.Add(2*time.Hour))
+	}
+	late := now.Add(5 * time.Hour)
+	if got := d.Next(late, time.Hour).Time(); !got.Equal(late.Add(time.Hour)) {
+		t.Errorf("Next() after a long gap = %v, want %v", got, late.Add(time.Hour))
+	}
+}
+
+func TestWatch(t *testing.T) {
+	now := time.Now()
+	w := NewWatch(now)
+	if gap, ok := w.Resumed(now.Add(CheckInterval)); ok || gap != 0 {
+		t.Errorf("Resumed() without a suspend = %v, %v", gap, ok)
+	}
+	if gap := Gap(now.Round(0), now.Add(time.Hour).Round(0)); gap != 0 {
+		t.Errorf("Gap() without monotonic readings = %v, want 0", gap)
+	}
+}
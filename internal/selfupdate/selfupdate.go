@@ -0,0 +1,320 @@
+// Package selfupdate finds the latest GitHub release and replaces the running binary with it,
+// after verifying the release checksums and their signature with the public key built in.
+package selfupdate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBaseURL = "https://api.github.com"
+	// DefaultRepo is the GitHub repository releases are fetched from.
+	DefaultRepo = "jsirianni/dzsa-sync"
+
+	checksumsSuffix = "checksums.txt"
+	signatureSuffix = ".sig"
+	// maxDownload bounds the size of a downloaded asset.
+	maxDownload = 256 << 20
+)
+
+// PublicKey is the base64 Ed25519 key release checksums are signed with. Injected with -ldflags -X;
+// every update requires a valid checksums.txt.sig, so a build without a key cannot update itself.
+var PublicKey = ""
+
+// ErrNoPublicKey is returned by Install when no public key is configured or built in.
+var ErrNoPublicKey = errors.New("no release public key built in; update with the package manager or a release build")
+
+// ErrNoAsset is returned when a release has no binary for the requested platform.
+var ErrNoAsset = errors.New("release has no binary for this platform")
+
+// Release is a published GitHub release.
+type Release struct {
+	Tag    string  `json:"tag_name"`
+	URL    string  `json:"html_url"`
+	Assets []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release.
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Options configures an Updater.
+type Options struct {
+	// HTTPClient is used for all requests. Nil uses a client with a 5 minute timeout.
+	HTTPClient *http.Client
+	// BaseURL overrides the GitHub API URL when set (e.g. for tests).
+	BaseURL string
+	// Repo is "owner/name". Empty uses DefaultRepo.
+	Repo string
+	// PublicKey verifies checksums.txt.sig. Nil uses the built-in PublicKey.
+	PublicKey ed25519.PublicKey
+}
+
+// Updater checks for and installs releases.
+type Updater struct {
+	client    *http.Client
+	baseURL   string
+	repo      string
+	publicKey ed25519.PublicKey
+}
+
+// New returns an Updater. When opts.PublicKey is nil and PublicKey was injected at build time, it is used.
+func New(opts Options) (*Updater, error) {
+	u := &Updater{
+		client:    opts.HTTPClient,
+		baseURL:   strings.TrimRight(opts.BaseURL, "/"),
+		repo:      opts.Repo,
+		publicKey: opts.PublicKey,
+	}
+	if u.client == nil {
+		u.client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	if u.baseURL == "" {
+		u.baseURL = defaultBaseURL
+	}
+	if u.repo == "" {
+		u.repo = DefaultRepo
+	}
+	if u.publicKey == nil && PublicKey != "" {
+		key, err := base64.StdEncoding.DecodeString(PublicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid built-in public key")
+		}
+		u.publicKey = key
+	}
+	return u, nil
+}
+
+// Latest returns the latest published release.
+func (u *Updater) Latest(ctx context.Context) (*Release, error) {
+	url := fmt.Sprintf("%s/repos/%s/releases/latest", u.baseURL, u.repo)
+	body, err := u.get(ctx, url, "application/vnd.github+json")
+	if err != nil {
+		return nil, fmt.Errorf("fetch latest release: %w", err)
+	}
+	var r Release
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, fmt.Errorf("decode release: %w", err)
+	}
+	if r.Tag == "" {
+		return nil, fmt.Errorf("decode release: missing tag_name")
+	}
+	return &r, nil
+}
+
+// Binary returns the release's raw binary for goos/goarch, e.g. dzsa-sync_1.2.3_linux_amd64.
+// Packages (.deb, .rpm) and archives are ignored.
+func (r *Release) Binary(goos, goarch string) (Asset, error) {
+	suffix := "_" + goos + "_" + goarch
+	for _, a := range r.Assets {
+		if strings.HasSuffix(a.Name, suffix) {
+			return a, nil
+		}
+	}
+	return Asset{}, fmt.Errorf("%w (%s/%s)", ErrNoAsset, goos, goarch)
+}
+
+func (r *Release) asset(suffix string) (Asset, bool) {
+	for _, a := range r.Assets {
+		if strings.HasSuffix(a.Name, suffix) {
+			return a, true
+		}
+	}
+	return Asset{}, false
+}
+
+// Install downloads the release binary for goos/goarch, verifies it against the release
+// checksums and their signature, and atomically replaces the file at exe. exe keeps its
+// permissions. Without a public key it returns ErrNoPublicKey and downloads nothing.
+func (u *Updater) Install(ctx context.Context, r *Release, goos, goarch, exe string) error {
+	if u.publicKey == nil {
+		return ErrNoPublicKey
+	}
+	bin, err := r.Binary(goos, goarch)
+	if err != nil {
+		return err
+	}
+	sums, ok := r.asset(checksumsSuffix)
+	if !ok {
+		return fmt.Errorf("release %s has no %s", r.Tag, checksumsSuffix)
+	}
+	sumsData, err := u.get(ctx, sums.URL, "")
+	if err != nil {
+		return fmt.Errorf("download %s: %w", sums.Name, err)
+	}
+	sig, ok := r.asset(checksumsSuffix + signatureSuffix)
+	if !ok {
+		return fmt.Errorf("release %s is not signed", r.Tag)
+	}
+	sigData, err := u.get(ctx, sig.URL, "")
+	if err != nil {
+		return fmt.Errorf("download %s: %w", sig.Name, err)
+	}
+	if err := verifySignature(u.publicKey, sumsData, sigData); err != nil {
+		return err
+	}
+	want, err := checksumFor(sumsData, bin.Name)
+	if err != nil {
+		return err
+	}
+
+	data, err := u.get(ctx, bin.URL, "")
+	if err != nil {
+		return fmt.Errorf("download %s: %w", bin.Name, err)
+	}
+	got := sha256.Sum256(data)
+	if hex.EncodeToString(got[:]) != want {
+		return fmt.Errorf("checksum mismatch for %s", bin.Name)
+	}
+	return replace(exe, data)
+}
+
+// verifySignature checks sig (raw or base64) is an Ed25519 signature of data.
+func verifySignature(key ed25519.PublicKey, data, sig []byte) error {
+	if len(sig) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+		if err != nil {
+			return fmt.Errorf("invalid signature encoding: %w", err)
+		}
+		sig = decoded
+	}
+	if !ed25519.Verify(key, data, sig) {
+		return fmt.Errorf("signature verification failed")
+	}
+	return nil
+}
+
+// checksumFor returns the SHA-256 listed for name in a sha256sum-format checksums file.
+func checksumFor(sums []byte, name string) (string, error) {
+	sc := bufio.NewScanner(bytes.NewReader(sums))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum for %s", name)
+}
+
+// replace writes data next to exe and renames it over exe, so a failed update leaves the old binary in place.
+func replace(exe string, data []byte) error {
+	info, err := os.Stat(exe)
+	if err != nil {
+		return fmt.Errorf("stat executable: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(exe), "."+filepath.Base(exe)+".update-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write temp file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("chmod temp file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		return fmt.Errorf("replace executable: %w", err)
+	}
+	return nil
+}
+
+func (u *Updater) get(ctx context.Context, url, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "dzsa-sync/1.0")
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDownload+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDownload {
+		return nil, fmt.Errorf("response exceeds %d bytes", maxDownload)
+	}
+	return data, nil
+}
+
+// Newer reports whether version a is newer than b. Versions are compared as dotted numbers,
+// ignoring a leading "v" and any pre-release or build suffix; a non-numeric version such as
+// "dev" is older than every release.
+func Newer(a, b string) bool {
+	pa, oka := parseVersion(a)
+	pb, okb := parseVersion(b)
+	switch {
+	case !oka:
+		return false
+	case !okb:
+		return true
+	}
+	for i := range max(len(pa), len(pb)) {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			return x > y
+		}
+	}
+	return false
+}
+
+func parseVersion(v string) ([]int, bool) {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	if v == "" {
+		return nil, false
+	}
+	var parts []int
+	for _, p := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+	return parts, true
+}
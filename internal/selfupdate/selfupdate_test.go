@@ -0,0 +1,144 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// releaseServer serves a latest release with a linux/amd64 binary, checksums, and optionally a signature.
+func releaseServer(t *testing.T, binary, sums, sig []byte) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+	mux.HandleFunc("GET /repos/jsirianni/dzsa-sync/releases/latest", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(w, `{"tag_name":"v1.3.0","assets":[
+			{"name":"dzsa-sync-amd64.deb","browser_download_url":"%[1]s/dl/deb"},
+			{"name":"dzsa-sync_1.3.0_linux_amd64","browser_download_url":"%[1]s/dl/bin"},
+			{"name":"dzsa-sync_1.3.0_checksums.txt","browser_download_url":"%[1]s/dl/sums"},
+			{"name":"dzsa-sync_1.3.0_checksums.txt.sig","browser_download_url":"%[1]s/dl/sig"}]}`, srv.URL)
+	})
+	mux.HandleFunc("GET /dl/bin", func(w http.ResponseWriter, _ *http.Request) { w.Write(binary) })
+	mux.HandleFunc("GET /dl/sums", func(w http.ResponseWriter, _ *http.Request) { w.Write(sums) })
+	mux.HandleFunc("GET /dl/sig", func(w http.ResponseWriter, r *http.Request) {
+		if sig == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(sig)
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestInstall(t *testing.T) {
+	binary := []byte("new binary")
+	sum := sha256.Sum256(binary)
+	sums := []byte(hex.EncodeToString(sum[:]) + "  dzsa-sync_1.3.0_linux_amd64\n" +
+		"0000000000000000000000000000000000000000000000000000000000000000  dzsa-sync-amd64.deb\n")
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPriv, _ := ed25519.GenerateKey(nil)
+	badSums := []byte("0000000000000000000000000000000000000000000000000000000000000000  dzsa-sync_1.3.0_linux_amd64\n")
+
+	tests := []struct {
+		name    string
+		sums    []byte
+		sig     []byte
+		key     ed25519.PublicKey
+		wantErr bool
+	}{
+		{name: "no public key", sums: sums, sig: ed25519.Sign(priv, sums), wantErr: true},
+		{name: "valid raw signature", sums: sums, sig: ed25519.Sign(priv, sums), key: pub},
+		{name: "valid base64 signature", sums: sums, sig: []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, sums)) + "\n"), key: pub},
+		{name: "wrong signer", sums: sums, sig: ed25519.Sign(otherPriv, sums), key: pub, wantErr: true},
+		{name: "missing signature", sums: sums, key: pub, wantErr: true},
+		{name: "checksum mismatch", sums: badSums, sig: ed25519.Sign(priv, badSums), key: pub, wantErr: true},
+		{name: "binary not in checksums", sums: []byte("abc  other\n"), sig: ed25519.Sign(priv, []byte("abc  other\n")), key: pub, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := releaseServer(t, binary, tt.sums, tt.sig)
+			exe := filepath.Join(t.TempDir(), "dzsa-sync")
+			if err := os.WriteFile(exe, []byte("old binary"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			u, err := New(Options{BaseURL: srv.URL, PublicKey: tt.key})
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := u.Latest(context.Background())
+			if err != nil {
+				t.Fatalf("Latest() error = %v", err)
+			}
+			err = u.Install(context.Background(), r, "linux", "amd64", exe)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Install() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.key == nil && !errors.Is(err, ErrNoPublicKey) {
+				t.Errorf("Install() without a key error = %v, want ErrNoPublicKey", err)
+			}
+
+			want := "new binary"
+			if tt.wantErr {
+				want = "old binary"
+			}
+			got, err := os.ReadFile(exe)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want {
+				t.Errorf("executable = %q, want %q", got, want)
+			}
+			info, _ := os.Stat(exe)
+			if info.Mode().Perm() != 0o755 {
+				t.Errorf("mode = %v, want 0755", info.Mode().Perm())
+			}
+			entries, _ := os.ReadDir(filepath.Dir(exe))
+			if len(entries) != 1 {
+				t.Errorf("temp files left behind: %v", entries)
+			}
+		})
+	}
+}
+
+func TestBinaryNoAsset(t *testing.T) {
+	r := &Release{Tag: "v1.3.0", Assets: []Asset{{Name: "dzsa-sync_1.3.0_linux_amd64"}}}
+	if _, err := r.Binary("linux", "arm64"); err == nil {
+		t.Error("Binary() found an asset for linux/arm64")
+	}
+}
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"v1.3.0", "1.2.9", true},
+		{"v1.10.0", "v1.9.0", true},
+		{"v1.2.0", "v1.2.0", false},
+		{"v1.2", "v1.2.0", false},
+		{"v1.2.1", "v1.2.1-rc1", false},
+		{"v1.2.0", "dev", true},
+		{"dev", "v1.2.0", false},
+		{"v1.1.0", "v1.2.0", false},
+	}
+	for _, tt := range tests {
+		if got := Newer(tt.a, tt.b); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
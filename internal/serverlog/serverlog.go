@@ -0,0 +1,125 @@
+// Package serverlog writes each server's sync log lines to a rotated file of its own, in addition to the main
+// log, so the log of one server can be handed to its owner.
+package serverlog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Rotation defaults used when the matching config field is zero.
+const (
+	DefaultMaxSizeMB  = 100
+	DefaultMaxBackups = 3
+	DefaultMaxAgeDays = 28
+)
+
+// Options configures a Router.
+type Options struct {
+	// Path is the path template, see config.ServerLogsConfig.
+	Path string
+	// MaxSizeMB, MaxBackups, and MaxAgeDays configure rotation. Zero uses the defaults.
+	MaxSizeMB  int
+	MaxBackups int
+	MaxAgeDays int
+	// Encoder encodes the lines of every file. It is cloned per file.
+	Encoder zapcore.Encoder
+	// Level is the minimum level written to the files. Nil writes every level.
+	Level zapcore.LevelEnabler
+	// Wrap wraps the core of every file when set, e.g. to redact IP addresses as in the main log.
+	Wrap func(zapcore.Core) zapcore.Core
+}
+
+// Router hands out loggers that also write to the file of a server. Safe for concurrent use.
+type Router struct {
+	opts Options
+
+	mu    sync.Mutex
+	files map[string]*file
+}
+
+// file is an open log file shared by the loggers of one path.
+type file struct {
+	w    *lumberjack.Logger
+	refs int
+}
+
+// New returns a router.
+func New(opts Options) *Router {
+	if opts.MaxSizeMB <= 0 {
+		opts.MaxSizeMB = DefaultMaxSizeMB
+	}
+	if opts.MaxBackups <= 0 {
+		opts.MaxBackups = DefaultMaxBackups
+	}
+	if opts.MaxAgeDays <= 0 {
+		opts.MaxAgeDays = DefaultMaxAgeDays
+	}
+	if opts.Level == nil {
+		opts.Level = zap.DebugLevel
+	}
+	return &Router{opts: opts, files: make(map[string]*file)}
+}
+
+// Logger returns logger with every line also written to the file of srv, and a func that closes the file
+// once every logger of it is released.
+func (r *Router) Logger(logger *zap.Logger, srv config.Server) (*zap.Logger, func(), error) {
+	path, err := config.ServerLogPath(r.opts.Path, srv)
+	if err != nil {
+		return nil, nil, fmt.Errorf("server log path: %w", err)
+	}
+	w := r.open(path)
+	var core zapcore.Core = zapcore.NewCore(r.opts.Encoder.Clone(), zapcore.AddSync(w), r.opts.Level)
+	if r.opts.Wrap != nil {
+		core = r.opts.Wrap(core)
+	}
+	teed := logger.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(c, core)
+	}))
+	var once sync.Once
+	return teed, func() { once.Do(func() { r.release(path) }) }, nil
+}
+
+func (r *Router) open(path string) *lumberjack.Logger {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.files[path]
+	if !ok {
+		// As for the main log: lumberjack creates a missing file without O_APPEND, which would let the two
+		// processes of a graceful restart overwrite each other's lines.
+		_ = os.MkdirAll(filepath.Dir(path), 0o750)
+		if fd, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600); err == nil { // #nosec G304 -- operator-configured log path
+			fd.Close()
+		}
+		f = &file{w: &lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    r.opts.MaxSizeMB,
+			MaxBackups: r.opts.MaxBackups,
+			MaxAge:     r.opts.MaxAgeDays,
+			Compress:   true,
+		}}
+		r.files[path] = f
+	}
+	f.refs++
+	return f.w
+}
+
+func (r *Router) release(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.files[path]
+	if !ok {
+		return
+	}
+	if f.refs--; f.refs == 0 {
+		_ = f.w.Close()
+		delete(r.files, path)
+	}
+}
@@ -0,0 +1,63 @@
+package serverlog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRouter(t *testing.T) {
+	dir := t.TempDir()
+	var main bytes.Buffer
+	enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	base := zap.New(zapcore.NewCore(enc, zapcore.AddSync(&main), zap.DebugLevel))
+	r := New(Options{Path: filepath.Join(dir, "servers", "{{ .Name }}.log"), Encoder: enc})
+
+	mainLog, releaseMain, err := r.Logger(base, config.Server{Name: "main", Port: 2424})
+	if err != nil {
+		t.Fatalf("Logger() error = %v", err)
+	}
+	testLog, releaseTest, err := r.Logger(base, config.Server{Name: "test", Port: 2524})
+	if err != nil {
+		t.Fatalf("Logger() error = %v", err)
+	}
+	mainLog.With(zap.Int("port", 2424)).Info("server synced with dzsa launcher")
+	testLog.Info("server sync failed")
+	base.Info("ifconfig sync completed")
+	releaseMain()
+	releaseMain()
+	releaseTest()
+
+	read := func(name string) string {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(dir, "servers", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	if got := read("main.log"); !strings.Contains(got, "server synced") || !strings.Contains(got, `"port":2424`) ||
+		strings.Contains(got, "sync failed") || strings.Contains(got, "ifconfig") {
+		t.Errorf("main.log = %s", got)
+	}
+	if got := read("test.log"); !strings.Contains(got, "server sync failed") || strings.Contains(got, "synced") {
+		t.Errorf("test.log = %s", got)
+	}
+	// Every line still reaches the main log.
+	if n := strings.Count(main.String(), "\n"); n != 3 {
+		t.Errorf("main log has %d lines, want 3:\n%s", n, main.String())
+	}
+	if len(r.files) != 0 {
+		t.Errorf("%d files still open after every logger was released", len(r.files))
+	}
+
+	if _, _, err := New(Options{Path: "{{ .Owner }}", Encoder: enc}).Logger(base, config.Server{Name: "main"}); err == nil {
+		t.Error("Logger() accepted a path template with an unknown field")
+	}
+}
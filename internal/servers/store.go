@@ -2,79 +2,699 @@
 package servers
 
 import (
-	"sort"
+	"slices"
 	"sync"
+	"time"
 
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
 	"github.com/jsirianni/dzsa-sync/model"
 )
 
 // Store holds the latest DZSA query result per config port. Safe for concurrent use.
+//
+// Stored values are never modified in place: every write replaces them, so reads share them without
+// copying. Values returned by Get, GetAll, and Changes must therefore be treated as read-only.
+// Every change is numbered by a store version, which lets readers fetch only what changed (Changes)
+// and lets GetAll reuse its sorted list until the next change.
 type Store struct {
-	mu     sync.RWMutex
-	byPort map[int]*model.Result
-	ports  map[int]bool
+	mu    sync.RWMutex
+	ports map[int]*portState
+	// base is the first version; versions start at the creation time in milliseconds so they keep
+	// increasing across restarts and stay exact as JSON numbers.
+	base    uint64
+	version uint64
+	// removed holds the version at which each removed port was dropped, for Changes.
+	removed map[int]uint64
+
+	// all caches the GetAll result for allVersion until the earliest active maintenance window ends.
+	all        []ServerEntry
+	allVersion uint64
+	allExpires time.Time
+
+	subMu sync.Mutex
+	subs  map[chan struct{}]struct{}
+}
+
+// portState is everything stored for one port. Data for a port that is not valid (restored, or not yet
+// added) is kept but not served.
+type portState struct {
+	valid   bool
+	version uint64
+	// name is the config name of the server, set by SetName.
+	name   string
+	result *model.Result
+	// fingerprint is result.Fingerprint(), computed once per stored result.
+	fingerprint string
+	daylight    *model.Daylight
+	modCheck    *ModCheck
+	upstream    *Upstream
+	workshop    *WorkshopCheck
+	latency     *Latency
+	maint       *Maintenance
+	sync        *SyncState
+	// nextSync is when the worker's timer fires next, and interval the time between its syncs. Neither is
+	// versioned: they change with every sync and are only reported by status.
+	nextSync time.Time
+	interval time.Duration
+	// history holds the last HistorySize stored results, oldest first once it wraps at historyNext.
+	history     []HistoryEntry
+	historyNext int
+}
+
+// HistorySize is the number of recent results kept per port for History.
+const HistorySize = 100
+
+// HistoryEntry is a result stored for a port and when it was stored.
+type HistoryEntry struct {
+	At     time.Time     `json:"at"`
+	Result *model.Result `json:"result"`
+}
+
+// Upstream is the result of checking whether a server is listed on the Valve master server, which DZSA ingests from.
+type Upstream struct {
+	CheckedAt time.Time `json:"checked_at"`
+	// Listed is true when the master server lists the server's query port at the external IP.
+	Listed bool `json:"listed"`
+	// Error is set when the check could not be performed; Listed is false in that case.
+	Error string `json:"error,omitempty"`
+}
+
+// SyncState records the outcome of recent sync attempts for a server.
+type SyncState struct {
+	LastAttempt time.Time `json:"last_attempt"`
+	// LastSuccess is zero until the first successful sync.
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	// LastError is the error of the most recent attempt, empty when it succeeded.
+	LastError string `json:"last_error,omitempty"`
+	// LastErrorKind is the errkind category of LastError, e.g. "network" or "upstream_api".
+	LastErrorKind       string `json:"last_error_kind,omitempty"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+}
+
+// Maintenance is a window during which scheduled syncs for a server are skipped (e.g. while it restarts or wipes).
+type Maintenance struct {
+	Until time.Time `json:"until"`
+	// Reason names what started the window, e.g. the webhook name.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Latency is the round trip time of an A2S query to a server's query port, measured after a sync.
+type Latency struct {
+	MeasuredAt time.Time `json:"measured_at"`
+	// RTTMillis is the round trip time in milliseconds; zero when Error is set.
+	RTTMillis float64 `json:"rtt_ms"`
+	// Error is set when the server did not answer.
+	Error string `json:"error,omitempty"`
+}
+
+// WorkshopCheck is the result of validating a server's workshop mods against the Steam API.
+type WorkshopCheck struct {
+	CheckedAt time.Time `json:"checked_at"`
+	// Valid is true when every mod exists, is publicly accessible, and matches its workshop title.
+	Valid bool `json:"valid"`
+	// Problems lists each mod that failed validation.
+	Problems []WorkshopProblem `json:"problems,omitempty"`
+	// Error is set when the check could not be performed; Valid is false in that case.
+	Error string `json:"error,omitempty"`
+}
+
+// WorkshopProblem describes a single mod that failed workshop validation.
+type WorkshopProblem struct {
+	WorkshopID int `json:"workshop_id"`
+	// Name is the mod name DZSA reports.
+	Name string `json:"name"`
+	// Problem is one of "deleted", "private", "banned", or "renamed".
+	Problem string `json:"problem"`
+	// Title is the current workshop title, when known.
+	Title string `json:"title,omitempty"`
+}
+
+// ModCheck is the result of comparing the mod list DZSA reports against the server's own A2S_RULES mod list.
+type ModCheck struct {
+	CheckedAt time.Time `json:"checked_at"`
+	// Match is true when both lists contain the same workshop IDs.
+	Match bool `json:"match"`
+	// MissingFromDZSA lists workshop IDs the server advertises that DZSA does not report (e.g. DZSA cache lag).
+	MissingFromDZSA []int `json:"missing_from_dzsa,omitempty"`
+	// UnexpectedInDZSA lists workshop IDs DZSA reports that the server does not advertise.
+	UnexpectedInDZSA []int `json:"unexpected_in_dzsa,omitempty"`
+	// Error is set when the server could not be queried; Match is false in that case.
+	Error string `json:"error,omitempty"`
 }
 
 // New returns a store that only accepts and returns data for the given config ports.
 func New(ports []int) *Store {
-	valid := make(map[int]bool, len(ports))
+	base := uint64(time.Now().UnixMilli()) // #nosec G115 -- current time is positive
+	s := &Store{
+		ports:   make(map[int]*portState, len(ports)),
+		base:    base,
+		version: base,
+		removed: make(map[int]uint64),
+		subs:    make(map[chan struct{}]struct{}),
+	}
 	for _, p := range ports {
-		valid[p] = true
+		s.ports[p] = &portState{valid: true}
 	}
-	return &Store{
-		byPort: make(map[int]*model.Result),
-		ports:  valid,
+	return s
+}
+
+// Subscribe returns a channel that receives a value after the store changes, and a function that
+// unsubscribes. Notifications are coalesced: a slow reader sees at most one pending notification.
+func (s *Store) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	s.subMu.Lock()
+	s.subs[ch] = struct{}{}
+	s.subMu.Unlock()
+	return ch, func() {
+		s.subMu.Lock()
+		delete(s.subs, ch)
+		s.subMu.Unlock()
+	}
+}
+
+// notify signals every subscriber without blocking. It does not take s.mu, so callers may hold it.
+func (s *Store) notify() {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	for ch := range s.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// update applies fn to the state of a valid port, records the change, and notifies subscribers.
+// It is a no-op for ports that are not valid.
+func (s *Store) update(port int, fn func(*portState)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ps, ok := s.ports[port]
+	if !ok || !ps.valid {
+		return
 	}
+	fn(ps)
+	s.touch(port, ps)
+	s.notify()
+}
+
+// touch gives ps a new version. s.mu must be held for writing.
+func (s *Store) touch(port int, ps *portState) {
+	s.version++
+	ps.version = s.version
+	delete(s.removed, port)
+}
+
+// valid returns the state of a valid port. s.mu must be held.
+func (s *Store) valid(port int) (*portState, bool) {
+	ps, ok := s.ports[port]
+	return ps, ok && ps.valid
 }
 
 // Set stores the result for the given port. Port must be in the set passed to New; otherwise Set is a no-op.
+// A result equal to the stored one changes nothing: the version stays and subscribers are not notified.
 func (s *Store) Set(port int, result *model.Result) {
 	if result == nil {
 		return
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if s.ports[port] {
-		// Copy so callers cannot mutate after Set
-		cp := *result
-		s.byPort[port] = &cp
+	ps, ok := s.valid(port)
+	if !ok || (ps.result != nil && ps.result.Equal(*result)) {
+		return
+	}
+	// Copy so callers cannot mutate after Set
+	cp := *result
+	now := time.Now()
+	ps.result = &cp
+	ps.fingerprint = cp.Fingerprint()
+	ps.daylight = nil
+	if d, ok := cp.Daylight(now); ok {
+		ps.daylight = &d
+	}
+	ps.record(HistoryEntry{At: now, Result: &cp})
+	s.touch(port, ps)
+	s.notify()
+}
+
+// AddPort adds port to the set of valid ports. Adding an existing port is a no-op.
+func (s *Store) AddPort(port int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ps, ok := s.ports[port]
+	if !ok {
+		s.ports[port] = &portState{valid: true}
+		return
+	}
+	if !ps.valid {
+		// Restored data becomes visible.
+		ps.valid = true
+		s.touch(port, ps)
+		s.notify()
+	}
+}
+
+// SetName stores the config name of the port. Port must be valid; otherwise SetName is a no-op. Setting the
+// stored name changes nothing.
+func (s *Store) SetName(port int, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ps, ok := s.valid(port)
+	if !ok || ps.name == name {
+		return
+	}
+	ps.name = name
+	s.touch(port, ps)
+	s.notify()
+}
+
+// RemovePort removes port from the set of valid ports and drops any stored result for it.
+func (s *Store) RemovePort(port int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ps, ok := s.ports[port]
+	delete(s.ports, port)
+	if ok && ps.valid {
+		s.version++
+		s.removed[port] = s.version
+	}
+	s.notify()
+}
+
+// SetModCheck stores the latest mod check for the port. Port must be valid; otherwise SetModCheck is a no-op.
+func (s *Store) SetModCheck(port int, check ModCheck) {
+	s.update(port, func(ps *portState) { ps.modCheck = &check })
+}
+
+// SetUpstream stores the latest master server listing check for the port. Port must be valid; otherwise SetUpstream is a no-op.
+func (s *Store) SetUpstream(port int, u Upstream) {
+	s.update(port, func(ps *portState) { ps.upstream = &u })
+}
+
+// GetUpstream returns the latest master server listing check for the port and true if one exists.
+func (s *Store) GetUpstream(port int) (Upstream, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ps, ok := s.valid(port)
+	if !ok || ps.upstream == nil {
+		return Upstream{}, false
+	}
+	return *ps.upstream, true
+}
+
+// SetLatency stores the latest latency measurement for the port. Port must be valid; otherwise SetLatency is a no-op.
+func (s *Store) SetLatency(port int, l Latency) {
+	s.update(port, func(ps *portState) { ps.latency = &l })
+}
+
+// SetWorkshop stores the latest workshop validation for the port. Port must be valid; otherwise SetWorkshop is a no-op.
+func (s *Store) SetWorkshop(port int, check WorkshopCheck) {
+	s.update(port, func(ps *portState) { ps.workshop = &check })
+}
+
+// RecordSync records a sync attempt at t for the port; err is nil on success. Port must be valid; otherwise RecordSync is a no-op.
+func (s *Store) RecordSync(port int, t time.Time, err error) {
+	s.update(port, func(ps *portState) {
+		st := SyncState{LastAttempt: t}
+		if ps.sync != nil {
+			st = *ps.sync
+			st.LastAttempt = t
+		}
+		if err != nil {
+			st.LastError = err.Error()
+			st.LastErrorKind = string(errkind.Of(err))
+			st.ConsecutiveFailures++
+		} else {
+			st.LastSuccess = t
+			st.LastError = ""
+			st.LastErrorKind = ""
+			st.ConsecutiveFailures = 0
+		}
+		ps.sync = &st
+	})
+}
+
+// GetSyncState returns the sync state for the port and true if at least one sync was attempted.
+func (s *Store) GetSyncState(port int) (SyncState, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ps, ok := s.valid(port)
+	if !ok || ps.sync == nil {
+		return SyncState{}, false
 	}
+	return *ps.sync, true
+}
+
+// GetMaintenance returns the active maintenance window for the port, if any.
+func (s *Store) GetMaintenance(port int, now time.Time) (Maintenance, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ps, ok := s.ports[port]
+	if !ok || ps.maint == nil || !ps.maint.Until.After(now) {
+		return Maintenance{}, false
+	}
+	return *ps.maint, true
+}
+
+// SetMaintenance starts or replaces the maintenance window for the port. Port must be valid; otherwise SetMaintenance is a no-op.
+func (s *Store) SetMaintenance(port int, m Maintenance) {
+	s.update(port, func(ps *portState) { ps.maint = &m })
+}
+
+// ClearMaintenance ends the maintenance window for the port, if any.
+func (s *Store) ClearMaintenance(port int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ps, ok := s.ports[port]; ok && ps.maint != nil {
+		ps.maint = nil
+		s.touch(port, ps)
+		s.notify()
+	}
+}
+
+// SetNextSync records that the port's next sync is due at, and that it syncs every interval after that.
+// Port must be valid; otherwise SetNextSync is a no-op. It does not change the store version.
+func (s *Store) SetNextSync(port int, at time.Time, interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ps, ok := s.valid(port); ok {
+		ps.nextSync, ps.interval = at, interval
+	}
+}
+
+// NextSync returns when the port is next synced and true if a sync is scheduled. Syncs due during a
+// maintenance window that is active at now are skipped, so the result is the first due time at or after
+// the window's end.
+func (s *Store) NextSync(port int, now time.Time) (time.Time, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ps, ok := s.valid(port)
+	if !ok || ps.nextSync.IsZero() {
+		return time.Time{}, false
+	}
+	at := ps.nextSync
+	if m := ps.maint; m != nil && m.Until.After(now) && m.Until.After(at) && ps.interval > 0 {
+		at = at.Add((m.Until.Sub(at) + ps.interval - 1) / ps.interval * ps.interval)
+	}
+	return at, true
+}
+
+// InMaintenance returns true when the port has a maintenance window that ends after now.
+func (s *Store) InMaintenance(port int, now time.Time) bool {
+	_, ok := s.GetMaintenance(port, now)
+	return ok
 }
 
 // Get returns the stored result for the port and true if found. Returns (nil, false) if port is not a valid config port or no data yet.
+// The result is shared and must not be modified.
 func (s *Store) Get(port int) (*model.Result, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	if !s.ports[port] {
+	ps, ok := s.valid(port)
+	if !ok || ps.result == nil {
 		return nil, false
 	}
-	r, ok := s.byPort[port]
-	if !ok || r == nil {
+	return ps.result, true
+}
+
+// Fingerprint returns the fingerprint of the stored result for port (see model.Result.Fingerprint), and
+// false when there is none.
+func (s *Store) Fingerprint(port int) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ps, ok := s.valid(port)
+	if !ok || ps.result == nil {
+		return "", false
+	}
+	return ps.fingerprint, true
+}
+
+// record adds e to the history, dropping the oldest entry once it holds HistorySize.
+func (ps *portState) record(e HistoryEntry) {
+	if len(ps.history) < HistorySize {
+		ps.history = append(ps.history, e)
+		return
+	}
+	ps.history[ps.historyNext] = e
+	ps.historyNext = (ps.historyNext + 1) % HistorySize
+}
+
+// History returns the last HistorySize results stored for port by Set, oldest first, and true if port is
+// valid. Only results that changed are stored, so consecutive entries differ (in-game time included). History
+// is kept in memory only: it is not part of a Snapshot and is dropped with the port.
+// The results are shared and must not be modified.
+func (s *Store) History(port int) ([]HistoryEntry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ps, ok := s.valid(port)
+	if !ok {
 		return nil, false
 	}
-	cp := *r
-	return &cp, true
+	out := make([]HistoryEntry, 0, len(ps.history))
+	out = append(out, ps.history[ps.historyNext:]...)
+	return append(out, ps.history[:ps.historyNext]...), true
 }
 
-// ServerEntry is a single server in the list response (port + result).
+// ServerEntry is a single server in the list response (port + result, plus the latest mod, listing, and workshop checks when enabled, and any active maintenance window).
 type ServerEntry struct {
-	Port   int           `json:"port"`
+	Port int `json:"port"`
+	// Name is the server's name from the config, as in logs, metrics, and GET /api/v1/status. Result.Name is
+	// the name DZSA reports.
+	Name   string        `json:"name,omitempty"`
 	Result *model.Result `json:"result"`
+	// Fingerprint identifies the server's name, map, version, and mods; see model.Result.Fingerprint.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Daylight is the day/night state derived from Result.Time when the result was stored.
+	Daylight *model.Daylight `json:"daylight,omitempty"`
+	ModCheck *ModCheck       `json:"mod_check,omitempty"`
+	Upstream *Upstream       `json:"upstream,omitempty"`
+	Workshop *WorkshopCheck  `json:"workshop,omitempty"`
+	Latency  *Latency        `json:"latency,omitempty"`
+	// Maintenance is set while a maintenance window is active.
+	Maintenance *Maintenance `json:"maintenance,omitempty"`
+}
+
+// Version returns the current store version. It increases with every change, including a maintenance
+// window ending.
+func (s *Store) Version() uint64 {
+	s.expire(time.Now())
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.version
 }
 
 // GetAll returns all stored results as a slice of ServerEntry, one per valid port that has data, in stable order (by port).
+// The slice is shared by every caller until the store changes and must not be modified.
 func (s *Store) GetAll() []ServerEntry {
+	all, _ := s.GetAllWithVersion()
+	return all
+}
+
+// GetAllWithVersion returns GetAll and the store version it reflects.
+func (s *Store) GetAllWithVersion() ([]ServerEntry, uint64) {
+	now := time.Now()
+	s.mu.RLock()
+	if s.all != nil && s.allVersion == s.version && now.Before(s.allExpires) {
+		all, version := s.all, s.allVersion
+		s.mu.RUnlock()
+		return all, version
+	}
+	s.mu.RUnlock()
+
+	s.expire(now)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.all == nil || s.allVersion != s.version || !now.Before(s.allExpires) {
+		s.all, s.allExpires = s.entries(0, now), maxTime
+		for _, ps := range s.ports {
+			if ps.maint != nil && ps.maint.Until.After(now) && ps.maint.Until.Before(s.allExpires) {
+				s.allExpires = ps.maint.Until
+			}
+		}
+		s.allVersion = s.version
+	}
+	return s.all, s.allVersion
+}
+
+// maxTime is later than any maintenance window.
+var maxTime = time.Unix(1<<62, 0)
+
+// Delta is the change to the server list since a version.
+type Delta struct {
+	// Version is the store version the delta brings the reader to.
+	Version uint64 `json:"version"`
+	// Full is set when the requested version predates this store, e.g. it came from before a restart.
+	// Servers is then the complete list and replaces what the reader holds.
+	Full bool `json:"full,omitempty"`
+	// Servers are the entries added or changed since the version, by port.
+	Servers []ServerEntry `json:"servers"`
+	// Removed lists ports that were dropped since the version.
+	Removed []int `json:"removed,omitempty"`
+}
+
+// Changes returns the entries that changed after version since. Entries are shared and must not be modified.
+func (s *Store) Changes(since uint64) Delta {
+	now := time.Now()
+	s.expire(now)
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	// Iterate in deterministic order: use sorted ports. We don't have ports as slice here, so collect from byPort keys and the valid set.
-	var entries []ServerEntry
-	for port, r := range s.byPort {
-		if !s.ports[port] || r == nil {
+	if since < s.base || since > s.version {
+		return Delta{Version: s.version, Full: true, Servers: s.entries(0, now)}
+	}
+	d := Delta{Version: s.version, Servers: s.entries(since, now)}
+	for port, v := range s.removed {
+		if v > since {
+			d.Removed = append(d.Removed, port)
+		}
+	}
+	slices.Sort(d.Removed)
+	return d
+}
+
+// entries returns the entries of valid ports with data that changed after since, sorted by port.
+// s.mu must be held.
+func (s *Store) entries(since uint64, now time.Time) []ServerEntry {
+	entries := make([]ServerEntry, 0, len(s.ports))
+	for port, ps := range s.ports {
+		if !ps.valid || ps.result == nil || ps.version <= since {
 			continue
 		}
-		cp := *r
-		entries = append(entries, ServerEntry{Port: port, Result: &cp})
+		entry := ServerEntry{
+			Port:        port,
+			Name:        ps.name,
+			Result:      ps.result,
+			Fingerprint: ps.fingerprint,
+			Daylight:    ps.daylight,
+			ModCheck:    ps.modCheck,
+			Upstream:    ps.upstream,
+			Workshop:    ps.workshop,
+			Latency:     ps.latency,
+		}
+		if ps.maint != nil && ps.maint.Until.After(now) {
+			entry.Maintenance = ps.maint
+		}
+		entries = append(entries, entry)
 	}
-	sort.Slice(entries, func(i, j int) bool { return entries[i].Port < entries[j].Port })
+	slices.SortFunc(entries, func(a, b ServerEntry) int { return a.Port - b.Port })
 	return entries
 }
+
+// expire drops maintenance windows that ended by now, so their end is a change readers see.
+func (s *Store) expire(now time.Time) {
+	s.mu.RLock()
+	expired := false
+	for _, ps := range s.ports {
+		if ps.maint != nil && !ps.maint.Until.After(now) {
+			expired = true
+			break
+		}
+	}
+	s.mu.RUnlock()
+	if !expired {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for port, ps := range s.ports {
+		if ps.maint != nil && !ps.maint.Until.After(now) {
+			ps.maint = nil
+			if ps.valid {
+				s.touch(port, ps)
+			}
+		}
+	}
+	s.notify()
+}
+
+// Snapshot is a copy of the store's per-port state, handed to the new process during a graceful restart.
+type Snapshot struct {
+	Results     map[int]*model.Result   `json:"results,omitempty"`
+	Daylight    map[int]*model.Daylight `json:"daylight,omitempty"`
+	ModChecks   map[int]*ModCheck       `json:"mod_checks,omitempty"`
+	Upstream    map[int]*Upstream       `json:"upstream,omitempty"`
+	Workshop    map[int]*WorkshopCheck  `json:"workshop,omitempty"`
+	Latency     map[int]*Latency        `json:"latency,omitempty"`
+	Maintenance map[int]*Maintenance    `json:"maintenance,omitempty"`
+	Syncs       map[int]*SyncState      `json:"syncs,omitempty"`
+}
+
+// Snapshot returns the state of every valid port. Values are shared and must not be modified.
+func (s *Store) Snapshot() Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snap := Snapshot{
+		Results:     make(map[int]*model.Result),
+		Daylight:    make(map[int]*model.Daylight),
+		ModChecks:   make(map[int]*ModCheck),
+		Upstream:    make(map[int]*Upstream),
+		Workshop:    make(map[int]*WorkshopCheck),
+		Latency:     make(map[int]*Latency),
+		Maintenance: make(map[int]*Maintenance),
+		Syncs:       make(map[int]*SyncState),
+	}
+	for port, ps := range s.ports {
+		if !ps.valid {
+			continue
+		}
+		putNonNil(snap.Results, port, ps.result)
+		putNonNil(snap.Daylight, port, ps.daylight)
+		putNonNil(snap.ModChecks, port, ps.modCheck)
+		putNonNil(snap.Upstream, port, ps.upstream)
+		putNonNil(snap.Workshop, port, ps.workshop)
+		putNonNil(snap.Latency, port, ps.latency)
+		putNonNil(snap.Maintenance, port, ps.maint)
+		putNonNil(snap.Syncs, port, ps.sync)
+	}
+	return snap
+}
+
+// Restore loads a snapshot taken by another process. Restored state stays hidden until its port is added,
+// so ports the new config no longer has are never served.
+func (s *Store) Restore(snap Snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := func(port int) *portState {
+		ps, ok := s.ports[port]
+		if !ok {
+			ps = &portState{}
+			s.ports[port] = ps
+		}
+		s.touch(port, ps)
+		return ps
+	}
+	for port, v := range snap.Results {
+		// A snapshot may come from another version of dzsa-sync; keep only results the client would accept.
+		if v.Validate() == nil {
+			ps := state(port)
+			ps.result, ps.fingerprint = v, v.Fingerprint()
+		}
+	}
+	for port, v := range snap.Daylight {
+		state(port).daylight = v
+	}
+	for port, v := range snap.ModChecks {
+		state(port).modCheck = v
+	}
+	for port, v := range snap.Upstream {
+		state(port).upstream = v
+	}
+	for port, v := range snap.Workshop {
+		state(port).workshop = v
+	}
+	for port, v := range snap.Latency {
+		state(port).latency = v
+	}
+	for port, v := range snap.Maintenance {
+		state(port).maint = v
+	}
+	for port, v := range snap.Syncs {
+		state(port).sync = v
+	}
+	s.notify()
+}
+
+func putNonNil[T any](m map[int]*T, port int, v *T) {
+	if v != nil {
+		m[port] = v
+	}
+}
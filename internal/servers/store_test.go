@@ -0,0 +1,317 @@
+package servers
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+	"github.com/jsirianni/dzsa-sync/model"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	src := New([]int{2424, 2425})
+	src.Set(2424, &model.Result{Name: "main", Players: 12, MaxPlayers: 60, Endpoint: model.Endpoint{IP: "203.0.113.10", Port: 2424}, Time: "21:30"})
+	src.Set(2425, &model.Result{Name: "broken", Players: 12})
+	src.RecordSync(2424, now, nil)
+	src.SetLatency(2424, Latency{MeasuredAt: now, RTTMillis: 1.5})
+	src.SetMaintenance(2425, Maintenance{Until: now.Add(time.Hour), Reason: "wipe"})
+
+	// The snapshot crosses a process boundary as JSON.
+	b, err := json.Marshal(src.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := New(nil)
+	dst.Restore(snap)
+	if _, ok := dst.Get(2424); ok {
+		t.Error("restored result visible before its port was added")
+	}
+	dst.AddPort(2424)
+	dst.AddPort(2425)
+	if r, ok := dst.Get(2424); !ok || r.Name != "main" || r.Players != 12 {
+		t.Errorf("Get(2424) = %+v, %v", r, ok)
+	}
+	if fp, _ := dst.Fingerprint(2424); fp == "" {
+		t.Error("fingerprint not restored")
+	}
+	if st, ok := dst.GetSyncState(2424); !ok || !st.LastSuccess.Equal(now) {
+		t.Errorf("GetSyncState(2424) = %+v, %v", st, ok)
+	}
+	if !dst.InMaintenance(2425, now) {
+		t.Error("maintenance window not restored")
+	}
+	if _, ok := dst.Get(2425); ok {
+		t.Error("invalid result restored")
+	}
+	if all := dst.GetAll(); len(all) != 1 || all[0].Daylight == nil || !all[0].Daylight.Night || all[0].Latency == nil || all[0].Latency.RTTMillis != 1.5 {
+		t.Errorf("daylight or latency not restored: %+v", all)
+	}
+}
+
+func TestRecordSyncErrorKind(t *testing.T) {
+	s := New([]int{2424})
+	now := time.Now()
+	s.RecordSync(2424, now, errkind.Wrap(errkind.Upstream, errors.New("unexpected status code: 503")))
+	s.RecordSync(2424, now, errors.New("boom"))
+	st, _ := s.GetSyncState(2424)
+	if st.LastErrorKind != string(errkind.Internal) || st.ConsecutiveFailures != 2 {
+		t.Errorf("after two failures: %+v", st)
+	}
+	s.RecordSync(2424, now, errkind.Wrap(errkind.Upstream, errors.New("unexpected status code: 503")))
+	if st, _ = s.GetSyncState(2424); st.LastErrorKind != string(errkind.Upstream) {
+		t.Errorf("LastErrorKind = %q, want %q", st.LastErrorKind, errkind.Upstream)
+	}
+	s.RecordSync(2424, now, nil)
+	if st, _ = s.GetSyncState(2424); st.LastErrorKind != "" || st.LastError != "" {
+		t.Errorf("after a success: %+v", st)
+	}
+}
+
+func TestSetUnchanged(t *testing.T) {
+	s := New([]int{2424})
+	s.Set(2424, &model.Result{Name: "main", Players: 3})
+	ch, unsubscribe := s.Subscribe()
+	defer unsubscribe()
+	v := s.Version()
+
+	s.Set(2424, &model.Result{Name: "main", Players: 3})
+	if s.Version() != v || len(ch) != 0 {
+		t.Error("an equal result changed the store")
+	}
+	s.Set(2424, &model.Result{Name: "main", Players: 4})
+	if s.Version() == v || len(ch) != 1 {
+		t.Error("a changed result did not change the store")
+	}
+}
+
+func TestHistory(t *testing.T) {
+	s := New([]int{2424})
+	if h, ok := s.History(2424); !ok || len(h) != 0 {
+		t.Fatalf("History() before a result = %v, %v", h, ok)
+	}
+	if _, ok := s.History(2302); ok {
+		t.Error("History() ok for an unknown port")
+	}
+	start := time.Now()
+	for i := range HistorySize + 5 {
+		s.Set(2424, &model.Result{Name: "main", Players: i})
+		// An unchanged result is not recorded again.
+		s.Set(2424, &model.Result{Name: "main", Players: i})
+	}
+	h, _ := s.History(2424)
+	if len(h) != HistorySize {
+		t.Fatalf("len(History()) = %d, want %d", len(h), HistorySize)
+	}
+	for i, e := range h {
+		if e.Result.Players != i+5 || e.At.Before(start) || (i > 0 && e.At.Before(h[i-1].At)) {
+			t.Fatalf("History()[%d] = %+v, want players %d in order", i, e, i+5)
+		}
+	}
+
+	s.RemovePort(2424)
+	s.AddPort(2424)
+	if h, _ := s.History(2424); len(h) != 0 {
+		t.Errorf("History() after the port was removed and added = %d entries, want none", len(h))
+	}
+}
+
+func TestSetName(t *testing.T) {
+	s := New([]int{2424})
+	s.Set(2424, &model.Result{Name: "DZSA name", Players: 3})
+	s.SetName(2424, "main")
+	s.SetName(2302, "unknown")
+	all := s.GetAll()
+	if len(all) != 1 || all[0].Name != "main" || all[0].Result.Name != "DZSA name" {
+		t.Fatalf("GetAll() = %+v", all)
+	}
+	v := s.Version()
+	s.SetName(2424, "main")
+	if s.Version() != v {
+		t.Error("an equal name changed the store")
+	}
+	s.SetName(2424, "renamed")
+	if d := s.Changes(v); len(d.Servers) != 1 || d.Servers[0].Name != "renamed" {
+		t.Errorf("Changes() after a rename = %+v", d)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	s := New([]int{2424})
+	if _, ok := s.Fingerprint(2424); ok {
+		t.Error("Fingerprint() ok before a result was stored")
+	}
+	s.Set(2424, &model.Result{Name: "main", Map: "chernarusplus", Players: 3})
+	fp, _ := s.Fingerprint(2424)
+	s.Set(2424, &model.Result{Name: "main", Map: "chernarusplus", Players: 4})
+	if got, _ := s.Fingerprint(2424); got != fp || s.GetAll()[0].Fingerprint != fp {
+		t.Error("fingerprint changed with the player count")
+	}
+	s.Set(2424, &model.Result{Name: "main", Map: "enoch", Players: 4})
+	if got, _ := s.Fingerprint(2424); got == fp {
+		t.Error("fingerprint unchanged after a map change")
+	}
+}
+
+func TestChanges(t *testing.T) {
+	s := New([]int{2424, 2425, 2426})
+	s.Set(2424, &model.Result{Name: "main"})
+	s.Set(2425, &model.Result{Name: "modded"})
+	v1 := s.Version()
+
+	if d := s.Changes(v1); d.Full || len(d.Servers) != 0 || d.Version != v1 {
+		t.Errorf("Changes(current) = %+v, want no changes", d)
+	}
+
+	s.Set(2425, &model.Result{Name: "modded", Players: 3})
+	s.Set(2426, &model.Result{Name: "test"})
+	s.RemovePort(2424)
+	d := s.Changes(v1)
+	if got := ports(d.Servers); !slices.Equal(got, []int{2425, 2426}) || !slices.Equal(d.Removed, []int{2424}) || d.Full {
+		t.Errorf("Changes(v1) servers = %v, removed = %v, full = %v", got, d.Removed, d.Full)
+	}
+	if d.Version <= v1 || d.Version != s.Version() {
+		t.Errorf("Changes(v1).Version = %d, want the current version after %d", d.Version, v1)
+	}
+
+	// A version from another store, e.g. before a restart, gets the full list.
+	if d := s.Changes(1); !d.Full || !slices.Equal(ports(d.Servers), []int{2425, 2426}) {
+		t.Errorf("Changes(stale) = %+v, want the full list", d)
+	}
+}
+
+func TestGetAllCache(t *testing.T) {
+	s := New([]int{2424, 2425})
+	s.Set(2424, &model.Result{Name: "main"})
+	s.SetMaintenance(2424, Maintenance{Until: time.Now().Add(50 * time.Millisecond)})
+	a, b := s.GetAll(), s.GetAll()
+	if len(a) != 1 || &a[0] != &b[0] {
+		t.Error("GetAll() rebuilt the list without a change")
+	}
+	if a[0].Maintenance == nil {
+		t.Fatal("active maintenance window not listed")
+	}
+
+	// The end of a maintenance window is a change.
+	v := s.Version()
+	time.Sleep(60 * time.Millisecond)
+	if all := s.GetAll(); all[0].Maintenance != nil {
+		t.Error("ended maintenance window still listed")
+	}
+	if d := s.Changes(v); !slices.Equal(ports(d.Servers), []int{2424}) {
+		t.Errorf("Changes() after the window ended = %v, want [2424]", ports(d.Servers))
+	}
+
+	s.Set(2425, &model.Result{Name: "modded"})
+	if all := s.GetAll(); len(all) != 2 || all[0].Port != 2424 || all[1].Port != 2425 {
+		t.Errorf("GetAll() after Set = %v", ports(all))
+	}
+}
+
+func TestNextSync(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := New([]int{2424})
+	if _, ok := s.NextSync(2424, now); ok {
+		t.Error("NextSync() before SetNextSync = true, want false")
+	}
+	s.SetNextSync(2425, now, time.Minute)
+	if _, ok := s.NextSync(2425, now); ok {
+		t.Error("NextSync() of an unknown port = true, want false")
+	}
+
+	at := now.Add(30 * time.Second)
+	s.SetNextSync(2424, at, 10*time.Minute)
+	v := s.Version()
+	if got, ok := s.NextSync(2424, now); !ok || !got.Equal(at) {
+		t.Errorf("NextSync() = %v, %v, want %v", got, ok, at)
+	}
+	if s.Version() != v {
+		t.Error("SetNextSync() changed the store version")
+	}
+
+	tests := []struct {
+		name  string
+		until time.Time
+		want  time.Time
+	}{
+		{"ends before the sync", now.Add(10 * time.Second), at},
+		{"ends on a due time", at.Add(20 * time.Minute), at.Add(20 * time.Minute)},
+		{"skips due times", now.Add(25 * time.Minute), at.Add(30 * time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.SetMaintenance(2424, Maintenance{Until: tt.until})
+			if got, _ := s.NextSync(2424, now); !got.Equal(tt.want) {
+				t.Errorf("NextSync() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func ports(entries []ServerEntry) []int {
+	out := make([]int, len(entries))
+	for i, e := range entries {
+		out[i] = e.Port
+	}
+	return out
+}
+
+// fleet returns a store of n synced servers, as for a large hosting provider.
+func fleet(n int) *Store {
+	s := New(nil)
+	for i := range n {
+		port := 2300 + i
+		s.AddPort(port)
+		s.Set(port, &model.Result{Name: "server", Players: i % 60, MaxPlayers: 60, Mods: make([]model.Mods, 20)})
+		s.RecordSync(port, time.Now(), nil)
+		s.SetModCheck(port, ModCheck{Match: true})
+	}
+	return s
+}
+
+func BenchmarkGetAll(b *testing.B) {
+	s := fleet(500)
+	b.ReportAllocs()
+	for b.Loop() {
+		_ = s.GetAll()
+	}
+}
+
+func BenchmarkGetAllChanging(b *testing.B) {
+	s := fleet(500)
+	result := &model.Result{Name: "server"}
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		result.Players = i
+		s.Set(2300+i%500, result)
+		_ = s.GetAll()
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	s := fleet(500)
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		_, _ = s.Get(2300 + i%500)
+	}
+}
+
+func BenchmarkChanges(b *testing.B) {
+	s := fleet(500)
+	result := &model.Result{Name: "server"}
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		v := s.Version()
+		result.Players = i
+		s.Set(2300+i%500, result)
+		_ = s.Changes(v)
+	}
+}
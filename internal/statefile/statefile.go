@@ -0,0 +1,113 @@
+// Package statefile keeps a copy of the server store snapshot on disk, so the last sync results are served
+// again after a restart instead of being empty until the first sync completes.
+package statefile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+	"go.uber.org/zap"
+)
+
+// Load reads a state file written by a Writer. A missing file returns nil and no error.
+//
+// Sync states are dropped: the previous process may have registered another external IP, which the file does
+// not record, so every server syncs at startup as without a state file. The results are served until then.
+func Load(path string) (*servers.Snapshot, error) {
+	b, err := os.ReadFile(path) // #nosec G304 -- path is from the operator's config
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read state file: %w", err)
+	}
+	var snap servers.Snapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return nil, fmt.Errorf("decode state file: %w", err)
+	}
+	snap.Syncs = nil
+	return &snap, nil
+}
+
+// Options configures a Writer.
+type Options struct {
+	Logger *zap.Logger
+	Store  *servers.Store
+	// Path is the state file. It is replaced atomically on every write.
+	Path string
+}
+
+// Writer keeps the state file in sync with the store.
+type Writer struct {
+	logger *zap.Logger
+	store  *servers.Store
+	path   string
+}
+
+// New returns a state file Writer.
+func New(opts Options) *Writer {
+	return &Writer{
+		logger: opts.Logger,
+		store:  opts.Store,
+		path:   opts.Path,
+	}
+}
+
+// Run writes the state file after every store change until ctx is cancelled. Unlike the feed, it does not
+// write at startup, so a state file is not replaced by an empty store before the first sync.
+func (w *Writer) Run(ctx context.Context) {
+	changes, unsubscribe := w.store.Subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-changes:
+			if ctx.Err() != nil {
+				// Stopped meanwhile: another process may own the file already.
+				continue
+			}
+			if err := w.Write(); err != nil {
+				w.logger.Error("write state file", zap.String("path", w.path), zap.Error(err))
+			}
+		case <-ctx.Done():
+			w.logger.Info("state file writer shutting down")
+			return
+		}
+	}
+}
+
+// Write atomically replaces the state file with the current snapshot.
+func (w *Writer) Write() error {
+	b, err := json.Marshal(w.store.Snapshot())
+	if err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(w.path), "."+filepath.Base(w.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write temp file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), w.path); err != nil {
+		return fmt.Errorf("rename temp file: %w", err)
+	}
+	return nil
+}
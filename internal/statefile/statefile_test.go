@@ -0,0 +1,74 @@
+package statefile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+	"github.com/jsirianni/dzsa-sync/model"
+	"go.uber.org/zap"
+)
+
+func TestWriter_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if snap, err := Load(path); err != nil || snap != nil {
+		t.Fatalf("Load(missing) = %v, %v, want nil, nil", snap, err)
+	}
+
+	store := servers.New([]int{2424})
+	store.Set(2424, &model.Result{Name: "main", Endpoint: model.Endpoint{IP: "203.0.113.10", Port: 27016}, Players: 12, MaxPlayers: 60})
+	if err := New(Options{Logger: zap.NewNop(), Store: store, Path: path}).Write(); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	snap, err := Load(path)
+	if err != nil || snap == nil {
+		t.Fatalf("Load() = %v, %v", snap, err)
+	}
+	restored := servers.New(nil)
+	restored.Restore(*snap)
+	restored.AddPort(2424)
+	if r, ok := restored.Get(2424); !ok || r.Players != 12 {
+		t.Errorf("restored result = %+v, %v", r, ok)
+	}
+}
+
+func TestWriter_Run(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	store := servers.New([]int{2424})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go New(Options{Logger: zap.NewNop(), Store: store, Path: path}).Run(ctx)
+
+	// Nothing is written before the store changes.
+	time.Sleep(50 * time.Millisecond)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("state file written before a change: %v", err)
+	}
+
+	store.Set(2424, &model.Result{Name: "main", Players: 3})
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		snap, err := Load(path)
+		if err == nil && snap != nil && snap.Results[2424] != nil && snap.Results[2424].Players == 3 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("state file not written: %v, %v", snap, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Load(invalid) error = nil")
+	}
+}
@@ -0,0 +1,230 @@
+package steam
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/metrics"
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+	"github.com/jsirianni/dzsa-sync/model"
+	"go.uber.org/zap"
+)
+
+// DefaultCheckInterval is the default time between master server listing checks.
+const DefaultCheckInterval = 15 * time.Minute
+
+// Checker periodically verifies that each server is listed on the Valve master server, which is
+// the source DZSA ingests from, and stores the outcome per port.
+type Checker struct {
+	Client   *Client
+	Logger   *zap.Logger
+	Store    *servers.Store
+	Recorder metrics.UpstreamRecorder
+	// Interval is the time between checks. Zero uses DefaultCheckInterval.
+	Interval time.Duration
+	// Address returns the external IP a server is registered with. Servers whose address fails are skipped.
+	Address func(ctx context.Context, srv config.Server) (string, error)
+	// Servers returns the servers to check.
+	Servers func() []config.Server
+}
+
+// Run checks once immediately and then every Interval until ctx is cancelled.
+func (c *Checker) Run(ctx context.Context) {
+	interval := c.Interval
+	if interval <= 0 {
+		interval = DefaultCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	c.check(ctx)
+	for {
+		select {
+		case <-ticker.C:
+			c.check(ctx)
+		case <-ctx.Done():
+			c.Logger.Info("master server check loop shutting down")
+			return
+		}
+	}
+}
+
+// check queries the master server once per external IP, so servers on several hosts are each checked
+// against their own IP.
+func (c *Checker) check(ctx context.Context) {
+	byIP := make(map[string][]config.Server)
+	var ips []string
+	for _, srv := range c.Servers() {
+		ip, err := c.Address(ctx, srv)
+		if err != nil {
+			c.Logger.Warn("no external IP available, skipping master server check", zap.String("server", srv.Name), zap.Error(err))
+			continue
+		}
+		if _, ok := byIP[ip]; !ok {
+			ips = append(ips, ip)
+		}
+		byIP[ip] = append(byIP[ip], srv)
+	}
+	for _, ip := range ips {
+		c.checkAddress(ctx, ip, byIP[ip])
+	}
+}
+
+func (c *Checker) checkAddress(ctx context.Context, ip string, srvs []config.Server) {
+	now := time.Now().UTC()
+	listed, err := c.Client.ServersAtAddress(ctx, ip)
+	if err != nil {
+		c.Logger.Error("master server check failed", zap.String("ip", ip), zap.Error(err))
+		for _, srv := range srvs {
+			c.Store.SetUpstream(srv.Port, servers.Upstream{CheckedAt: now, Error: err.Error()})
+		}
+		return
+	}
+	ports := make(map[int]bool, len(listed))
+	for _, l := range listed {
+		if e, err := model.ParseEndpoint(l.Addr); err == nil {
+			ports[e.Port] = true
+		}
+	}
+	for _, srv := range srvs {
+		_, port := srv.Advertised(ip)
+		ok := ports[port]
+		c.Store.SetUpstream(srv.Port, servers.Upstream{CheckedAt: now, Listed: ok})
+		if c.Recorder != nil {
+			c.Recorder.RecordListedUpstream(ctx, srv.Name, ok)
+		}
+		if !ok {
+			c.Logger.Warn("server not listed on steam master server",
+				zap.String("server", srv.Name),
+				zap.Stringer("endpoint", model.Endpoint{IP: ip, Port: port}))
+		}
+	}
+}
+
+// DefaultWorkshopInterval is the default time between workshop mod validations.
+const DefaultWorkshopInterval = time.Hour
+
+// Workshop problem kinds stored in servers.WorkshopProblem.
+const (
+	ProblemDeleted = "deleted"
+	ProblemPrivate = "private"
+	ProblemBanned  = "banned"
+	ProblemRenamed = "renamed"
+)
+
+// WorkshopChecker periodically validates that every workshop mod DZSA reports for a server still
+// exists, is publicly accessible, and matches its workshop title, and stores the outcome per port.
+type WorkshopChecker struct {
+	Client   *Client
+	Logger   *zap.Logger
+	Store    *servers.Store
+	Recorder metrics.WorkshopRecorder
+	// Interval is the time between checks. Zero uses DefaultWorkshopInterval.
+	Interval time.Duration
+	// Servers returns the servers to check.
+	Servers func() []config.Server
+}
+
+// Run checks once immediately and then every Interval until ctx is cancelled.
+func (c *WorkshopChecker) Run(ctx context.Context) {
+	interval := c.Interval
+	if interval <= 0 {
+		interval = DefaultWorkshopInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	c.check(ctx)
+	for {
+		select {
+		case <-ticker.C:
+			c.check(ctx)
+		case <-ctx.Done():
+			c.Logger.Info("workshop check loop shutting down")
+			return
+		}
+	}
+}
+
+func (c *WorkshopChecker) check(ctx context.Context) {
+	type target struct {
+		srv  config.Server
+		mods []model.Mods
+	}
+	var targets []target
+	var ids []int
+	seen := make(map[int]bool)
+	for _, srv := range c.Servers() {
+		result, ok := c.Store.Get(srv.Port)
+		if !ok {
+			continue
+		}
+		targets = append(targets, target{srv: srv, mods: result.Mods})
+		for _, m := range result.Mods {
+			if !seen[m.SteamWorkshopID] {
+				seen[m.SteamWorkshopID] = true
+				ids = append(ids, m.SteamWorkshopID)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	now := time.Now().UTC()
+	details, err := c.Client.PublishedFileDetails(ctx, ids)
+	if err != nil {
+		c.Logger.Error("workshop check failed", zap.Error(err))
+		for _, t := range targets {
+			c.Store.SetWorkshop(t.srv.Port, servers.WorkshopCheck{CheckedAt: now, Error: err.Error()})
+		}
+		return
+	}
+	byID := make(map[int]FileDetails, len(details))
+	for _, d := range details {
+		byID[d.ID] = d
+	}
+
+	for _, t := range targets {
+		problems := ValidateMods(t.mods, byID)
+		c.Store.SetWorkshop(t.srv.Port, servers.WorkshopCheck{CheckedAt: now, Valid: len(problems) == 0, Problems: problems})
+		if c.Recorder != nil {
+			c.Recorder.RecordInvalidMods(ctx, t.srv.Name, len(problems))
+		}
+		for _, p := range problems {
+			c.Logger.Warn("workshop mod failed validation",
+				zap.String("server", t.srv.Name),
+				zap.Int("workshop_id", p.WorkshopID),
+				zap.String("mod", p.Name),
+				zap.String("problem", p.Problem),
+				zap.String("title", p.Title))
+		}
+	}
+}
+
+// ValidateMods returns a problem for each mod that is missing from details, not public, banned, or
+// whose name differs from its workshop title (case-insensitive). Unlisted mods are accepted since
+// clients can still subscribe to them.
+func ValidateMods(mods []model.Mods, details map[int]FileDetails) []servers.WorkshopProblem {
+	var problems []servers.WorkshopProblem
+	for _, m := range mods {
+		d, ok := details[m.SteamWorkshopID]
+		problem := servers.WorkshopProblem{WorkshopID: m.SteamWorkshopID, Name: m.Name, Title: d.Title}
+		switch {
+		case !ok || !d.Exists():
+			problem.Problem = ProblemDeleted
+		case d.Banned:
+			problem.Problem = ProblemBanned
+		case d.Visibility == VisibilityPrivate || d.Visibility == VisibilityFriendsOnly:
+			problem.Problem = ProblemPrivate
+		case !strings.EqualFold(strings.TrimSpace(m.Name), strings.TrimSpace(d.Title)):
+			problem.Problem = ProblemRenamed
+		default:
+			continue
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
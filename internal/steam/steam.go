@@ -0,0 +1,111 @@
+// Package steam queries the Steam Web API to verify servers are listed on the Valve master server
+// and that their workshop mods are still available.
+package steam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/metrics"
+)
+
+const (
+	serversAtAddressURL = "https://api.steampowered.com/ISteamApps/GetServersAtAddress/v1/"
+)
+
+// ServerAddr is a server listed on the master server at a given address.
+type ServerAddr struct {
+	// Addr is ip:queryport.
+	Addr     string `json:"addr"`
+	AppID    int    `json:"appid"`
+	GameDir  string `json:"gamedir"`
+	GamePort int    `json:"gameport"`
+	Secure   bool   `json:"secure"`
+	LAN      bool   `json:"lan"`
+}
+
+type serversAtAddressResponse struct {
+	Response struct {
+		Success bool         `json:"success"`
+		Servers []ServerAddr `json:"servers"`
+		Message string       `json:"message"`
+	} `json:"response"`
+}
+
+// Client queries the Steam Web API.
+type Client struct {
+	client   *http.Client
+	recorder metrics.HTTPRecorder
+	// BaseURL overrides the GetServersAtAddress endpoint when set (e.g. for tests).
+	BaseURL string
+	// WorkshopURL overrides the GetPublishedFileDetails endpoint when set (e.g. for tests).
+	WorkshopURL string
+}
+
+// New creates a new Steam client. httpClient may be nil to use a default client.
+func New(httpClient *http.Client, recorder metrics.HTTPRecorder) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Client{
+		client:   httpClient,
+		recorder: recorder,
+	}
+}
+
+// ServersAtAddress returns the servers the master server lists for ip. No API key is required.
+func (c *Client) ServersAtAddress(ctx context.Context, ip string) ([]ServerAddr, error) {
+	start := time.Now()
+	host := "steam"
+
+	endpoint := serversAtAddressURL
+	if c.BaseURL != "" {
+		endpoint = c.BaseURL
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("addr", ip)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		c.record(ctx, host, 0, metrics.ClassifyError(err, 0), start)
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "dzsa-sync/1.0")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.record(ctx, host, 0, metrics.ClassifyError(err, 0), start)
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		c.record(ctx, host, resp.StatusCode, metrics.ClassifyError(nil, resp.StatusCode), start)
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	var body serversAtAddressResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		c.record(ctx, host, resp.StatusCode, metrics.ErrorDecode, start)
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	c.record(ctx, host, resp.StatusCode, metrics.ErrorNone, start)
+	if !body.Response.Success {
+		return nil, fmt.Errorf("steam api error: %s", body.Response.Message)
+	}
+	return body.Response.Servers, nil
+}
+
+func (c *Client) record(ctx context.Context, host string, statusCode int, errType string, start time.Time) {
+	if c.recorder != nil {
+		c.recorder.RecordRequest(ctx, host, statusCode, errType, time.Since(start))
+	}
+}
@@ -0,0 +1,174 @@
+package steam
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+	"github.com/jsirianni/dzsa-sync/model"
+	"go.uber.org/zap"
+)
+
+func TestClient_ServersAtAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("addr") {
+		case "203.0.113.10":
+			_, _ = w.Write([]byte(`{"response":{"success":true,"servers":[{"addr":"203.0.113.10:2424","appid":221100,"gamedir":"dayz","gameport":2302}]}}`))
+		default:
+			_, _ = w.Write([]byte(`{"response":{"success":false,"message":"Invalid IP"}}`))
+		}
+	}))
+	defer server.Close()
+
+	c := New(server.Client(), nil)
+	c.BaseURL = server.URL
+
+	got, err := c.ServersAtAddress(context.Background(), "203.0.113.10")
+	if err != nil {
+		t.Fatalf("ServersAtAddress() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Addr != "203.0.113.10:2424" || got[0].GamePort != 2302 {
+		t.Errorf("ServersAtAddress() = %+v", got)
+	}
+
+	if _, err := c.ServersAtAddress(context.Background(), "bad"); err == nil {
+		t.Error("ServersAtAddress() expected error when success is false")
+	}
+}
+
+func TestChecker_check(t *testing.T) {
+	var queries int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries++
+		if r.URL.Query().Get("addr") == "203.0.113.20" {
+			_, _ = w.Write([]byte(`{"response":{"success":true,"servers":[{"addr":"203.0.113.20:2525"}]}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"response":{"success":true,"servers":[{"addr":"203.0.113.10:2424"}]}}`))
+	}))
+	defer server.Close()
+
+	c := New(server.Client(), nil)
+	c.BaseURL = server.URL
+	store := servers.New([]int{2424, 2324, 2525})
+	checker := &Checker{
+		Client: c,
+		Logger: zap.NewNop(),
+		Store:  store,
+		Address: func(_ context.Context, srv config.Server) (string, error) {
+			if srv.Host == "remote" {
+				return "203.0.113.20", nil
+			}
+			return "203.0.113.10", nil
+		},
+		Servers: func() []config.Server {
+			return []config.Server{{Name: "main", Port: 2424}, {Name: "modded", Port: 2324}, {Name: "remote", Port: 2525, Host: "remote"}}
+		},
+	}
+	checker.check(context.Background())
+
+	if u, ok := store.GetUpstream(2424); !ok || !u.Listed {
+		t.Errorf("GetUpstream(2424) = %+v, %v, want listed", u, ok)
+	}
+	if u, ok := store.GetUpstream(2324); !ok || u.Listed {
+		t.Errorf("GetUpstream(2324) = %+v, %v, want not listed", u, ok)
+	}
+	if u, ok := store.GetUpstream(2525); !ok || !u.Listed {
+		t.Errorf("GetUpstream(2525) = %+v, %v, want listed at its host's IP", u, ok)
+	}
+	if queries != 2 {
+		t.Errorf("master server queried %d times, want once per IP", queries)
+	}
+}
+
+func TestClient_PublishedFileDetails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if r.Form.Get("itemcount") != "2" || r.Form.Get("publishedfileids[1]") != "1564026768" {
+			t.Errorf("form = %v", r.Form)
+		}
+		_, _ = w.Write([]byte(`{"response":{"result":1,"resultcount":2,"publishedfiledetails":[
+			{"publishedfileid":"1559212036","result":1,"title":"CF","visibility":0,"banned":0,"file_size":"1048576","time_updated":1700000000},
+			{"publishedfileid":"1564026768","result":9}]}}`))
+	}))
+	defer server.Close()
+
+	c := New(server.Client(), nil)
+	c.WorkshopURL = server.URL
+
+	got, err := c.PublishedFileDetails(context.Background(), []int{1559212036, 1564026768})
+	if err != nil {
+		t.Fatalf("PublishedFileDetails() error = %v", err)
+	}
+	if len(got) != 2 || got[0].Title != "CF" || !got[0].Exists() || got[1].Exists() ||
+		got[0].FileSize != 1048576 || !got[0].Updated.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("PublishedFileDetails() = %+v", got)
+	}
+}
+
+func TestValidateMods(t *testing.T) {
+	mods := []model.Mods{
+		{Name: "CF", SteamWorkshopID: 1},
+		{Name: "Gone", SteamWorkshopID: 2},
+		{Name: "Hidden", SteamWorkshopID: 3},
+		{Name: "Old Name", SteamWorkshopID: 4},
+		{Name: "Bad", SteamWorkshopID: 5},
+		{Name: "Unlisted", SteamWorkshopID: 6},
+	}
+	details := map[int]FileDetails{
+		1: {ID: 1, Result: 1, Title: "cf "},
+		2: {ID: 2, Result: 9},
+		3: {ID: 3, Result: 1, Title: "Hidden", Visibility: VisibilityPrivate},
+		4: {ID: 4, Result: 1, Title: "New Name"},
+		5: {ID: 5, Result: 1, Title: "Bad", Banned: true},
+		6: {ID: 6, Result: 1, Title: "Unlisted", Visibility: VisibilityUnlisted},
+	}
+	got := ValidateMods(mods, details)
+	want := map[int]string{2: ProblemDeleted, 3: ProblemPrivate, 4: ProblemRenamed, 5: ProblemBanned}
+	if len(got) != len(want) {
+		t.Fatalf("ValidateMods() = %+v, want %d problems", got, len(want))
+	}
+	for _, p := range got {
+		if want[p.WorkshopID] != p.Problem {
+			t.Errorf("ValidateMods() %d problem = %q, want %q", p.WorkshopID, p.Problem, want[p.WorkshopID])
+		}
+	}
+}
+
+func TestWorkshopChecker_check(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"response":{"result":1,"publishedfiledetails":[
+			{"publishedfileid":"1559212036","result":1,"title":"CF","visibility":0}]}}`))
+	}))
+	defer server.Close()
+
+	c := New(server.Client(), nil)
+	c.WorkshopURL = server.URL
+	store := servers.New([]int{2424})
+	store.Set(2424, &model.Result{Mods: []model.Mods{{Name: "CF", SteamWorkshopID: 1559212036}, {Name: "Gone", SteamWorkshopID: 42}}})
+	checker := &WorkshopChecker{
+		Client:  c,
+		Logger:  zap.NewNop(),
+		Store:   store,
+		Servers: func() []config.Server { return []config.Server{{Name: "main", Port: 2424}} },
+	}
+	checker.check(context.Background())
+
+	entries := store.GetAll()
+	if len(entries) != 1 || entries[0].Workshop == nil {
+		t.Fatalf("GetAll() = %+v, want workshop check", entries)
+	}
+	w := entries[0].Workshop
+	if w.Valid || len(w.Problems) != 1 || w.Problems[0].WorkshopID != 42 || w.Problems[0].Problem != ProblemDeleted {
+		t.Errorf("Workshop = %+v", w)
+	}
+}
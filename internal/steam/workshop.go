@@ -0,0 +1,150 @@
+package steam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/internal/metrics"
+)
+
+const (
+	publishedFileDetailsURL = "https://api.steampowered.com/ISteamRemoteStorage/GetPublishedFileDetails/v1/"
+
+	// resultOK is the EResult value for a workshop item that exists.
+	resultOK = 1
+)
+
+// Workshop visibility values reported by GetPublishedFileDetails.
+const (
+	VisibilityPublic      = 0
+	VisibilityFriendsOnly = 1
+	VisibilityPrivate     = 2
+	VisibilityUnlisted    = 3
+)
+
+// FileDetails is the subset of workshop item details used for mod validation.
+type FileDetails struct {
+	ID int
+	// Result is the Steam EResult for the item; anything other than 1 means it does not exist or is inaccessible.
+	Result     int
+	Title      string
+	Visibility int
+	Banned     bool
+	// FileSize is the size of the mod in bytes.
+	FileSize int64
+	// Updated is when the mod was last updated on the workshop; zero when unknown.
+	Updated time.Time
+}
+
+// Exists returns true when Steam returned details for the item.
+func (d FileDetails) Exists() bool {
+	return d.Result == resultOK
+}
+
+type publishedFileDetailsResponse struct {
+	Response struct {
+		Result  int `json:"result"`
+		Details []struct {
+			ID         string  `json:"publishedfileid"`
+			Result     int     `json:"result"`
+			Title      string  `json:"title"`
+			Visibility int     `json:"visibility"`
+			Banned     intBool `json:"banned"`
+			// FileSize is a string in current responses and a number in older ones.
+			FileSize    json.Number `json:"file_size"`
+			TimeUpdated int64       `json:"time_updated"`
+		} `json:"publishedfiledetails"`
+	} `json:"response"`
+}
+
+// intBool decodes a JSON number (0/1) or boolean.
+type intBool bool
+
+func (b *intBool) UnmarshalJSON(data []byte) error {
+	switch strings.TrimSpace(string(data)) {
+	case "1", "true":
+		*b = true
+	case "0", "false", "null":
+		*b = false
+	default:
+		return fmt.Errorf("invalid boolean %s", data)
+	}
+	return nil
+}
+
+// PublishedFileDetails returns workshop details for ids in a single request. No API key is required.
+func (c *Client) PublishedFileDetails(ctx context.Context, ids []int) ([]FileDetails, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	start := time.Now()
+	host := "steam"
+
+	endpoint := publishedFileDetailsURL
+	if c.WorkshopURL != "" {
+		endpoint = c.WorkshopURL
+	}
+	form := url.Values{}
+	form.Set("itemcount", strconv.Itoa(len(ids)))
+	for i, id := range ids {
+		form.Set(fmt.Sprintf("publishedfileids[%d]", i), strconv.Itoa(id))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		c.record(ctx, host, 0, metrics.ClassifyError(err, 0), start)
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "dzsa-sync/1.0")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.record(ctx, host, 0, metrics.ClassifyError(err, 0), start)
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		c.record(ctx, host, resp.StatusCode, metrics.ClassifyError(nil, resp.StatusCode), start)
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	var body publishedFileDetailsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		c.record(ctx, host, resp.StatusCode, metrics.ErrorDecode, start)
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	c.record(ctx, host, resp.StatusCode, metrics.ErrorNone, start)
+	if body.Response.Result != resultOK {
+		return nil, fmt.Errorf("steam api error: result %d", body.Response.Result)
+	}
+
+	details := make([]FileDetails, 0, len(body.Response.Details))
+	for _, d := range body.Response.Details {
+		id, err := strconv.Atoi(d.ID)
+		if err != nil {
+			continue
+		}
+		fd := FileDetails{
+			ID:         id,
+			Result:     d.Result,
+			Title:      d.Title,
+			Visibility: d.Visibility,
+			Banned:     bool(d.Banned),
+		}
+		if size, err := d.FileSize.Int64(); err == nil {
+			fd.FileSize = size
+		}
+		if d.TimeUpdated > 0 {
+			fd.Updated = time.Unix(d.TimeUpdated, 0).UTC()
+		}
+		details = append(details, fd)
+	}
+	return details, nil
+}
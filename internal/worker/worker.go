@@ -0,0 +1,856 @@
+// Package worker runs one DZSA sync loop per server and manages their lifecycle.
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/client"
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/a2s"
+	"github.com/jsirianni/dzsa-sync/internal/dnscache"
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+	"github.com/jsirianni/dzsa-sync/internal/exechook"
+	"github.com/jsirianni/dzsa-sync/internal/history"
+	"github.com/jsirianni/dzsa-sync/internal/ifconfig"
+	"github.com/jsirianni/dzsa-sync/internal/metrics"
+	"github.com/jsirianni/dzsa-sync/internal/retry"
+	"github.com/jsirianni/dzsa-sync/internal/schedule"
+	"github.com/jsirianni/dzsa-sync/internal/serverlog"
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+	"github.com/jsirianni/dzsa-sync/model"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultInterval is the default time between syncs for a server.
+	DefaultInterval = 1 * time.Hour
+	// DefaultJitterMax is the default upper bound of the random delay before each sync.
+	DefaultJitterMax = 20 * time.Second
+)
+
+// SourceConfig is the source name for servers defined in the config file.
+const SourceConfig = "config"
+
+// SourceAPI is the source name for servers added through the API and not written to the config file.
+const SourceAPI = "api"
+
+var errNoExternalIP = errkind.Wrap(errkind.Network, errors.New("no external IP available"))
+
+// Options configures a Manager.
+type Options struct {
+	Logger      *zap.Logger
+	Client      client.Client
+	IFConfig    *ifconfig.Client
+	ExternalIP  string
+	Store       *servers.Store
+	PlayerCount metrics.PlayerCountRecorder
+	// IFConfig6 and ExternalIPv6 are the IPv6 counterparts of IFConfig and ExternalIP, used by servers with
+	// address_family: ipv6. IFConfig6 may be nil.
+	IFConfig6    *ifconfig.Client
+	ExternalIPv6 string
+	// Hosts are the hosts servers can belong to (config.Server.Host). A host's servers are registered
+	// with its IP instead of the instance's.
+	Hosts []config.Host
+	// Resolver resolves the hostnames of Hosts, so they are cached and use the configured nameservers like
+	// outbound requests. Nil uses the system resolver on every sync.
+	Resolver *dnscache.Resolver
+	// History receives a record for every sync attempt. May be nil.
+	History history.Sink
+	// A2S queries the servers directly. When set with ModCheck, each successful sync compares the
+	// DZSA mod list against the server's A2S_RULES mod list.
+	A2S *a2s.Client
+	// A2SHost is the host used for A2S queries. Empty uses 127.0.0.1.
+	A2SHost string
+	// ModCheck enables the mod list cross-check.
+	ModCheck bool
+	// Latency enables measuring the A2S round trip time to each server after a sync.
+	Latency bool
+	// RequireUp fails a sync without querying DZSA when the server does not answer A2S_INFO. A2S is required.
+	RequireUp bool
+	// LatencyRecorder records the measured round trip times. May be nil.
+	LatencyRecorder metrics.LatencyRecorder
+	// Night records whether each server's in-game time is night after a sync. May be nil.
+	Night metrics.NightRecorder
+	// ModMismatch records the mod check outcome. May be nil.
+	ModMismatch metrics.ModCheckRecorder
+	// Interval is the time between syncs. Zero uses DefaultInterval.
+	Interval time.Duration
+	// JitterMax is the upper bound of the random delay before each sync. Zero uses DefaultJitterMax.
+	JitterMax time.Duration
+	// Active reports whether this instance syncs, e.g. whether it is the HA leader. Nil always syncs.
+	Active func() bool
+	// Retries is the number of times a query that failed with a transient error is retried. Zero disables retries.
+	Retries int
+	// RetryBackoff and RetryMaxBackoff bound the delay between retries. Zero uses the retry package defaults.
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+	// FailureSchedule is the delay before the sync following each consecutive failed sync, the last one
+	// repeating until a sync succeeds, each capped at the server's interval. Nil uses
+	// retry.DefaultFailureSchedule; empty keeps the interval after failures.
+	FailureSchedule []time.Duration
+	// RetryBudget is shared by all workers; a retry is skipped when it is spent. Nil allows every retry.
+	RetryBudget *retry.Budget
+	// Retry records retries taken and skipped. May be nil.
+	Retry metrics.RetryRecorder
+	// SyncErrors records failed syncs by error kind. May be nil.
+	SyncErrors metrics.SyncErrorRecorder
+	// NextSync records when each server syncs next. May be nil.
+	NextSync metrics.NextSyncRecorder
+	// DryRun answers syncs from A2S queries of the servers instead of sending them to DZSA. A2S is required.
+	DryRun bool
+	// ServerLogs also writes each server's log lines to a file of its own when set.
+	ServerLogs *serverlog.Router
+	// Exec runs the exec hooks of every sync outcome. May be nil.
+	Exec *exechook.Runner
+}
+
+// Manager starts and stops sync workers. Safe for concurrent use.
+type Manager struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	opts    Options
+	mu      sync.Mutex
+	workers map[int]*worker
+	wg      sync.WaitGroup
+
+	// pauseMu is held for reading by every sync in flight, so Pause can wait for them to finish.
+	pauseMu sync.RWMutex
+	paused  bool
+
+	hosts map[string]config.Host
+
+	// driftSeen holds the DZSA schema changes already logged, so each is logged once.
+	driftMu   sync.Mutex
+	driftSeen map[string]bool
+
+	// draining is closed by Drain: workers start no more syncs and exit once their sync in flight is done.
+	draining  chan struct{}
+	drainOnce sync.Once
+}
+
+type worker struct {
+	server  config.Server
+	source  string
+	trigger chan struct{}
+	cancel  context.CancelFunc
+}
+
+// NewManager returns a manager whose workers run until ctx is cancelled or they are removed.
+func NewManager(ctx context.Context, opts Options) *Manager {
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.JitterMax <= 0 {
+		opts.JitterMax = DefaultJitterMax
+	}
+	if opts.A2SHost == "" {
+		opts.A2SHost = "127.0.0.1"
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = retry.DefaultBackoff
+	}
+	if opts.RetryMaxBackoff <= 0 {
+		opts.RetryMaxBackoff = retry.DefaultMaxBackoff
+	}
+	if opts.FailureSchedule == nil {
+		opts.FailureSchedule = retry.DefaultFailureSchedule
+	}
+	hosts := make(map[string]config.Host, len(opts.Hosts))
+	for _, h := range opts.Hosts {
+		hosts[h.Name] = h
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &Manager{
+		ctx:       ctx,
+		cancel:    cancel,
+		draining:  make(chan struct{}),
+		opts:      opts,
+		workers:   make(map[int]*worker),
+		hosts:     hosts,
+		driftSeen: make(map[string]bool),
+	}
+}
+
+// Address returns the external IP srv is registered with: its advertise_ip when set, and otherwise the IP it
+// is reachable at (see reachableAddress).
+func (m *Manager) Address(ctx context.Context, srv config.Server) (string, error) {
+	if srv.AdvertiseIP != "" {
+		return srv.AdvertiseIP, nil
+	}
+	return m.reachableAddress(ctx, srv)
+}
+
+// reachableAddress returns the external IP srv is reachable at: its own IP when it is monitor-only, its host's
+// IP when it belongs to a host, and the instance's external IP of srv's address family otherwise. A host's
+// hostname is resolved on every call, through Options.Resolver when set.
+func (m *Manager) reachableAddress(ctx context.Context, srv config.Server) (string, error) {
+	if srv.MonitorOnly {
+		return srv.IP, nil
+	}
+	if srv.Host != "" {
+		h, ok := m.hosts[srv.Host]
+		if !ok {
+			return "", errkind.Errorf(errkind.Config, "unknown host %q", srv.Host)
+		}
+		if h.ExternalIP != "" {
+			return h.ExternalIP, nil
+		}
+		return m.lookupHost(ctx, h.Hostname, srv.IPv6())
+	}
+	detector, static := m.opts.IFConfig, m.opts.ExternalIP
+	if srv.IPv6() {
+		detector, static = m.opts.IFConfig6, m.opts.ExternalIPv6
+	}
+	ip := ""
+	if detector != nil {
+		ip = detector.GetAddress()
+	}
+	if ip == "" {
+		ip = static
+	}
+	if ip == "" {
+		return "", errNoExternalIP
+	}
+	return ip, nil
+}
+
+// lookupHost returns the first address of host in srv's address family: IPv6 when ipv6 is set, and IPv4
+// otherwise.
+func (m *Manager) lookupHost(ctx context.Context, host string, ipv6 bool) (string, error) {
+	lookup := net.DefaultResolver.LookupHost
+	if m.opts.Resolver != nil {
+		lookup = m.opts.Resolver.LookupHost
+	}
+	addrs, err := lookup(ctx, host)
+	if err != nil {
+		return "", errkind.Errorf(errkind.Network, "resolve host %s: %w", host, err)
+	}
+	for _, a := range addrs {
+		if ip, err := netip.ParseAddr(a); err == nil && ip.Unmap().Is6() == ipv6 {
+			return ip.Unmap().String(), nil
+		}
+	}
+	family := config.AddressFamilyIPv4
+	if ipv6 {
+		family = config.AddressFamilyIPv6
+	}
+	return "", errkind.Errorf(errkind.Network, "resolve host %s: no %s address", host, family)
+}
+
+// Add starts a worker for the server. Returns an error if a worker already exists for the port.
+func (m *Manager) Add(source string, srv config.Server) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existing, ok := m.workers[srv.Port]; ok {
+		return fmt.Errorf("port %d already managed by %s (%s)", srv.Port, existing.source, existing.server.Name)
+	}
+	m.start(source, srv)
+	return nil
+}
+
+// Remove stops the worker for the port and drops its data from the store. Returns false if no worker exists.
+func (m *Manager) Remove(port int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stop(port)
+}
+
+// Reconcile makes the workers owned by source match servers: new ports are started, ports no longer
+// listed are stopped, and ports whose name changed are restarted. Ports owned by another source are skipped.
+func (m *Manager) Reconcile(source string, srvs []config.Server) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	want := make(map[int]config.Server, len(srvs))
+	for _, s := range srvs {
+		want[s.Port] = s
+	}
+	for port, w := range m.workers {
+		if w.source != source {
+			continue
+		}
+		s, ok := want[port]
+		if !ok || s != w.server {
+			m.stop(port)
+		}
+	}
+	for _, s := range srvs {
+		if existing, ok := m.workers[s.Port]; ok {
+			if existing.source != source {
+				m.opts.Logger.Warn("port already managed by another source, skipping",
+					zap.String("source", source),
+					zap.String("owner", existing.source),
+					zap.String("server", s.Name),
+					zap.Int("port", s.Port))
+			}
+			continue
+		}
+		m.start(source, s)
+	}
+}
+
+// Source returns the source that manages the port, and false if no worker exists.
+func (m *Manager) Source(port int) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.workers[port]
+	if !ok {
+		return "", false
+	}
+	return w.source, true
+}
+
+// Trigger requests an immediate sync for the port. Returns false if no worker exists.
+func (m *Manager) Trigger(port int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.workers[port]
+	if !ok {
+		return false
+	}
+	notify(w.trigger)
+	return true
+}
+
+// TriggerAll requests an immediate sync for every worker.
+func (m *Manager) TriggerAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, w := range m.workers {
+		notify(w.trigger)
+	}
+}
+
+// Servers returns the managed servers sorted by port.
+func (m *Manager) Servers() []config.Server {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]config.Server, 0, len(m.workers))
+	for _, w := range m.workers {
+		out = append(out, w.server)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Port < out[j].Port })
+	return out
+}
+
+// Pause waits for syncs in flight to finish and skips every sync until Resume. It is used during a graceful
+// restart so the state handed to the new process is final.
+func (m *Manager) Pause() {
+	m.pauseMu.Lock()
+	m.paused = true
+	m.pauseMu.Unlock()
+}
+
+// Resume undoes Pause.
+func (m *Manager) Resume() {
+	m.pauseMu.Lock()
+	m.paused = false
+	m.pauseMu.Unlock()
+}
+
+// Wait blocks until all workers have exited.
+func (m *Manager) Wait() {
+	m.wg.Wait()
+}
+
+// Drain shuts the workers down without losing the syncs in flight: no new sync starts, and retries are given
+// up, while syncs already running get up to timeout to finish and store their result. Workers still running
+// then are cancelled. It returns when every worker has exited, and reports whether all of them finished
+// within timeout. Workers are not started again after Drain.
+func (m *Manager) Drain(timeout time.Duration) bool {
+	m.drainOnce.Do(func() { close(m.draining) })
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	defer m.cancel()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		m.cancel()
+		<-done
+		return false
+	}
+}
+
+// Draining reports whether Drain was called.
+func (m *Manager) Draining() bool {
+	select {
+	case <-m.draining:
+		return true
+	default:
+		return false
+	}
+}
+
+// start must be called with m.mu held.
+func (m *Manager) start(source string, srv config.Server) {
+	if m.Draining() {
+		return
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	w := &worker{
+		server:  srv,
+		source:  source,
+		trigger: make(chan struct{}, 1),
+		cancel:  cancel,
+	}
+	m.workers[srv.Port] = w
+	m.opts.Store.AddPort(srv.Port)
+	m.opts.Store.SetName(srv.Port, srv.Name)
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.run(ctx, w)
+	}()
+}
+
+// stop must be called with m.mu held.
+func (m *Manager) stop(port int) bool {
+	w, ok := m.workers[port]
+	if !ok {
+		return false
+	}
+	w.cancel()
+	delete(m.workers, port)
+	m.opts.Store.RemovePort(port)
+	return true
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+		// already pending trigger
+	}
+}
+
+func (m *Manager) run(ctx context.Context, w *worker) {
+	logger := m.opts.Logger
+	if m.opts.ServerLogs != nil {
+		l, release, err := m.opts.ServerLogs.Logger(logger, w.server)
+		if err != nil {
+			logger.Error("server log unavailable, logging to the main log only",
+				zap.String("server", w.server.Name), zap.Error(err))
+		} else {
+			logger = l
+			defer release()
+		}
+	}
+	logger = logger.With(
+		zap.String("server", w.server.Name),
+		zap.Int("port", w.server.Port),
+		zap.String("source", w.source))
+	if w.server.Host != "" {
+		logger = logger.With(zap.String("host", w.server.Host))
+	}
+	if m.opts.DryRun {
+		logger = logger.With(zap.Bool("dry_run", true))
+	}
+	logger.Info("sync worker started for server")
+	defer logger.Info("sync worker stopped for server")
+
+	// Sync once on startup, unless state restored from a previous process shows a recent successful sync,
+	// in which case its schedule is kept.
+	now := time.Now()
+	interval := m.interval(w.server)
+	due := schedule.In(now, m.firstSync(w.server))
+	m.schedule(ctx, w.server, due.Time())
+	// The timer only wakes the worker to check the due time, so a sync due while the host was suspended runs
+	// on resume instead of after the remaining timer time.
+	timer := time.NewTimer(due.Wait(now))
+	defer timer.Stop()
+	watch := schedule.NewWatch(now)
+	// failures counts consecutive failed syncs; while it is not zero, syncs follow FailureSchedule instead
+	// of the interval.
+	failures := 0
+	next := func(err error, due schedule.Due) schedule.Due {
+		if err == nil {
+			failures = 0
+			return due
+		}
+		failures++
+		delay := retry.FailureDelay(m.opts.FailureSchedule, failures, interval)
+		logger.Info("scheduling the next sync after a failed one",
+			zap.Int("consecutive_failures", failures),
+			zap.Duration("delay", delay))
+		return schedule.In(time.Now(), delay)
+	}
+
+	for {
+		// A drain wins over a due sync or a trigger that arrived at the same time.
+		if m.Draining() {
+			return
+		}
+		select {
+		case <-m.draining:
+			return
+		case <-timer.C:
+			now := time.Now()
+			if gap, ok := watch.Resumed(now); ok {
+				logger.Info("host resumed from suspend or its clock jumped, syncing now", zap.Duration("gap", gap))
+				err := m.syncOnce(ctx, logger, w.server)
+				due = next(err, schedule.In(time.Now(), interval))
+				m.schedule(ctx, w.server, due.Time())
+			} else if due.Passed(now) {
+				err := m.syncOnce(ctx, logger, w.server)
+				due = next(err, due.Next(time.Now(), interval))
+				m.schedule(ctx, w.server, due.Time())
+			}
+		case <-w.trigger:
+			// The trigger syncs now anyway, so a resume since the last wake needs no sync of its own.
+			watch.Resumed(time.Now())
+			err := m.syncOnce(ctx, logger, w.server)
+			due = next(err, schedule.In(time.Now(), interval))
+			m.schedule(ctx, w.server, due.Time())
+		case <-ctx.Done():
+			return
+		}
+		timer.Reset(due.Wait(time.Now()))
+	}
+}
+
+// schedule records that the server syncs next at at.
+func (m *Manager) schedule(ctx context.Context, srv config.Server, at time.Time) {
+	now := time.Now()
+	m.opts.Store.SetNextSync(srv.Port, at.UTC(), m.interval(srv))
+	if m.opts.NextSync == nil {
+		return
+	}
+	if at, ok := m.opts.Store.NextSync(srv.Port, now); ok {
+		m.opts.NextSync.RecordNextSync(ctx, srv.Name, at)
+	}
+}
+
+// firstSync returns the delay before a new worker's first sync.
+func (m *Manager) firstSync(srv config.Server) time.Duration {
+	st, ok := m.opts.Store.GetSyncState(srv.Port)
+	if !ok || st.LastError != "" {
+		return 0
+	}
+	return max(time.Until(st.LastAttempt.Add(m.interval(srv))), 0)
+}
+
+// interval returns the time between syncs of srv: its own sync_interval, or Options.Interval.
+func (m *Manager) interval(srv config.Server) time.Duration {
+	if srv.SyncInterval > 0 {
+		return srv.SyncInterval
+	}
+	return m.opts.Interval
+}
+
+// syncOnce syncs srv and returns the error of a failed sync. A skipped sync returns nil.
+func (m *Manager) syncOnce(ctx context.Context, logger *zap.Logger, srv config.Server) error {
+	if m.opts.Active != nil && !m.opts.Active() {
+		logger.Debug("not the leader, skipping sync")
+		return nil
+	}
+	if m.opts.Store.InMaintenance(srv.Port, time.Now()) {
+		logger.Info("server in maintenance window, skipping sync")
+		return nil
+	}
+	jitter := time.Duration(rand.Int63n(int64(m.opts.JitterMax)/int64(time.Second)+1)) * time.Second // #nosec G404 -- jitter only, not security-sensitive
+	if jitter > 0 {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-m.draining:
+			return nil
+		case <-time.After(jitter):
+		}
+	}
+	m.pauseMu.RLock()
+	defer m.pauseMu.RUnlock()
+	if m.paused {
+		logger.Debug("restart in progress, skipping sync")
+		return nil
+	}
+	ip, err := m.reachableAddress(ctx, srv)
+	if err != nil {
+		logger.Warn("no external IP available, skipping sync", zap.Error(err), errkind.Field(err))
+		m.recordSyncError(ctx, err)
+		m.opts.Store.RecordSync(srv.Port, time.Now().UTC(), err)
+		m.fireExec(srv, nil, nil, err)
+		return err
+	}
+	resp, err := m.query(ctx, srv, ip)
+	for attempt := 0; err != nil && attempt < m.opts.Retries && client.Retryable(err); attempt++ {
+		if !m.opts.RetryBudget.Allow() {
+			logger.Warn("retry budget exhausted, waiting for the next sync", zap.Error(err), errkind.Field(err))
+			m.recordRetry(ctx, retry.ResultExhausted)
+			break
+		}
+		m.recordRetry(ctx, retry.ResultAllowed)
+		delay := retry.Backoff(m.opts.RetryBackoff, m.opts.RetryMaxBackoff, attempt)
+		logger.Warn("server sync failed, retrying",
+			zap.Int("attempt", attempt+1),
+			zap.Duration("delay", delay),
+			zap.Error(err),
+			errkind.Field(err))
+		m.schedule(ctx, srv, time.Now().Add(delay))
+		if !m.sleepUnpaused(ctx, delay) {
+			if m.Draining() && ctx.Err() == nil {
+				// Record the last failure instead of retrying during a shutdown.
+				break
+			}
+			return nil
+		}
+		resp, err = m.query(ctx, srv, ip)
+	}
+	if err != nil {
+		logger.Error("server sync failed",
+			zap.Stringer("endpoint", advertised(srv, ip)),
+			zap.Error(err),
+			errkind.Field(err))
+		m.recordSyncError(ctx, err)
+		if m.opts.PlayerCount != nil {
+			m.opts.PlayerCount.RecordServerOnline(ctx, srv.Name, false)
+		}
+		m.recordHistory(ctx, logger, srv, nil, err)
+		m.opts.Store.RecordSync(srv.Port, time.Now().UTC(), err)
+		m.fireExec(srv, nil, nil, err)
+		return err
+	}
+	m.opts.Store.RecordSync(srv.Port, time.Now().UTC(), nil)
+	m.logDrift(logger, resp.Drift)
+	result := resp.Result
+	m.recordHistory(ctx, logger, srv, &result, nil)
+	prev, _ := m.opts.Store.Get(srv.Port)
+	m.opts.Store.Set(srv.Port, &result)
+	m.fireExec(srv, prev, &result, nil)
+	if m.opts.PlayerCount != nil {
+		m.opts.PlayerCount.RecordServerPlayerCount(ctx, srv.Name, int64(result.Players))
+		m.opts.PlayerCount.RecordServerMaxPlayers(ctx, srv.Name, int64(result.MaxPlayers))
+		m.opts.PlayerCount.RecordServerOnline(ctx, srv.Name, true)
+	}
+	if t, err := model.ParseGameTime(result.Time); err == nil && m.opts.Night != nil {
+		m.opts.Night.RecordNight(ctx, srv.Name, t.Night())
+	}
+	logger.Info("server synced with dzsa launcher",
+		zap.String("endpoint", result.Endpoint.String()),
+		zap.String("name", result.Name),
+		zap.Int("players", result.Players),
+		zap.Int("max_players", result.MaxPlayers),
+		zap.String("version", result.Version),
+		zap.String("map", result.Map),
+	)
+	if m.opts.ModCheck && m.opts.A2S != nil {
+		m.checkMods(ctx, logger, srv, m.a2sAddr(srv, ip), result.Mods)
+	}
+	if m.opts.Latency && m.opts.A2S != nil {
+		m.measureLatency(ctx, logger, srv, m.a2sAddr(srv, ip))
+	}
+	return nil
+}
+
+// a2sAddr returns the A2S address of srv: A2SHost for servers on this machine, and the IP the server is
+// registered with for servers on other hosts and monitor-only servers.
+func (m *Manager) a2sAddr(srv config.Server, ip string) string {
+	if srv.Host != "" || srv.MonitorOnly {
+		return net.JoinHostPort(ip, strconv.Itoa(srv.Port))
+	}
+	return net.JoinHostPort(m.opts.A2SHost, strconv.Itoa(srv.Port))
+}
+
+// measureLatency times an A2S query to the server at addr and stores the result.
+func (m *Manager) measureLatency(ctx context.Context, logger *zap.Logger, srv config.Server, addr string) {
+	l := servers.Latency{MeasuredAt: time.Now().UTC()}
+	rtt, err := m.opts.A2S.Ping(ctx, addr)
+	if err != nil {
+		logger.Warn("latency measurement failed", zap.String("a2s_addr", addr), zap.Error(err), errkind.Field(err))
+		l.Error = err.Error()
+	} else {
+		l.RTTMillis = float64(rtt.Microseconds()) / 1000
+		if m.opts.LatencyRecorder != nil {
+			m.opts.LatencyRecorder.RecordLatency(ctx, srv.Name, rtt)
+		}
+	}
+	m.opts.Store.SetLatency(srv.Port, l)
+}
+
+// logDrift warns about a DZSA response that did not match the model, once per distinct change.
+func (m *Manager) logDrift(logger *zap.Logger, drift *model.SchemaDrift) {
+	if drift == nil {
+		return
+	}
+	key := drift.String()
+	m.driftMu.Lock()
+	seen := m.driftSeen[key]
+	m.driftSeen[key] = true
+	m.driftMu.Unlock()
+	if seen {
+		return
+	}
+	logger.Warn("dzsa response schema changed",
+		zap.Strings("unknown_fields", drift.UnknownFields()),
+		zap.Strings("coerced_fields", drift.Coerced),
+		zap.Strings("invalid_fields", drift.Invalid))
+}
+
+// query runs one DZSA query for the advertised endpoint of srv, reachable at ip, bounded by
+// client.DefaultHTTPTimeout, or with DryRun, answers it from A2S. With RequireUp, a server that does not
+// answer A2S_INFO fails the query without asking DZSA; the error is retryable, e.g. while the server boots.
+func (m *Manager) query(ctx context.Context, srv config.Server, ip string) (*model.QueryResponse, error) {
+	if m.opts.DryRun {
+		return m.dryRun(ctx, srv, ip)
+	}
+	if m.opts.RequireUp {
+		addr := m.a2sAddr(srv, ip)
+		if _, err := m.opts.A2S.Info(ctx, addr); err != nil {
+			return nil, errkind.Errorf(errkind.Network, "server is down: a2s info %s: %w", addr, err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, client.DefaultHTTPTimeout)
+	defer cancel()
+	e := advertised(srv, ip)
+	return m.opts.Client.Query(ctx, e.IP, e.Port)
+}
+
+// advertised returns the endpoint srv, reachable at ip, is registered with.
+func advertised(srv config.Server, ip string) model.Endpoint {
+	ip, port := srv.Advertised(ip)
+	return model.Endpoint{IP: ip, Port: port}
+}
+
+// dryRun builds the response DZSA would likely give for srv at ip from its A2S_INFO, and the mod list from
+// A2S_RULES when the server reports one, so the store is filled without touching the DZSA listing.
+func (m *Manager) dryRun(ctx context.Context, srv config.Server, ip string) (*model.QueryResponse, error) {
+	addr := m.a2sAddr(srv, ip)
+	info, err := m.opts.A2S.Info(ctx, addr)
+	if err != nil {
+		return nil, errkind.Errorf(errkind.Network, "dry run: a2s info %s: %w", addr, err)
+	}
+	result := model.Result{
+		Endpoint:   advertised(srv, ip),
+		Name:       info.Name,
+		Map:        info.Map,
+		Players:    info.Players,
+		MaxPlayers: info.MaxPlayers,
+		Version:    info.Version,
+		GamePort:   info.GamePort,
+	}
+	if rules, err := m.opts.A2S.Rules(ctx, addr); err == nil {
+		if mods, err := a2s.DayZMods(rules); err == nil {
+			for _, mod := range mods {
+				result.Mods = append(result.Mods, model.Mods{Name: mod.Name, SteamWorkshopID: mod.WorkshopID})
+			}
+		}
+	}
+	result.Mods = model.NormalizeMods(result.Mods)
+	return &model.QueryResponse{Result: result, APIVersion: client.APIVersion}, nil
+}
+
+// sleepUnpaused waits d between retries. It must be called with pauseMu held for reading, which it releases
+// while waiting so a restart is not held up by a backoff. It returns false, with pauseMu held again, when ctx
+// is done, the manager is draining or was paused, or this instance stopped being active meanwhile.
+func (m *Manager) sleepUnpaused(ctx context.Context, d time.Duration) bool {
+	m.pauseMu.RUnlock()
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		m.pauseMu.RLock()
+		return false
+	case <-m.draining:
+		m.pauseMu.RLock()
+		return false
+	case <-t.C:
+	}
+	m.pauseMu.RLock()
+	return !m.paused && (m.opts.Active == nil || m.opts.Active())
+}
+
+func (m *Manager) recordSyncError(ctx context.Context, err error) {
+	if m.opts.SyncErrors != nil {
+		m.opts.SyncErrors.RecordSyncError(ctx, string(errkind.Of(err)))
+	}
+}
+
+func (m *Manager) recordRetry(ctx context.Context, result string) {
+	if m.opts.Retry != nil {
+		m.opts.Retry.RecordRetry(ctx, result)
+	}
+}
+
+// checkMods compares the DZSA mod list against the A2S_RULES mod list of the server at addr and stores the outcome.
+func (m *Manager) checkMods(ctx context.Context, logger *zap.Logger, srv config.Server, addr string, dzsaMods []model.Mods) {
+	check := servers.ModCheck{CheckedAt: time.Now().UTC()}
+	rules, err := m.opts.A2S.Rules(ctx, addr)
+	var local []a2s.Mod
+	if err == nil {
+		local, err = a2s.DayZMods(rules)
+	}
+	if err != nil {
+		logger.Warn("mod check failed", zap.String("a2s_addr", addr), zap.Error(err), errkind.Field(err))
+		check.Error = err.Error()
+		m.opts.Store.SetModCheck(srv.Port, check)
+		return
+	}
+	check.MissingFromDZSA, check.UnexpectedInDZSA = a2s.CompareMods(dzsaMods, local)
+	check.Match = len(check.MissingFromDZSA) == 0 && len(check.UnexpectedInDZSA) == 0
+	m.opts.Store.SetModCheck(srv.Port, check)
+	if m.opts.ModMismatch != nil {
+		m.opts.ModMismatch.RecordModMismatch(ctx, srv.Name, !check.Match)
+	}
+	if !check.Match {
+		logger.Warn("dzsa mod list does not match server",
+			zap.Ints("missing_from_dzsa", check.MissingFromDZSA),
+			zap.Ints("unexpected_in_dzsa", check.UnexpectedInDZSA))
+	}
+}
+
+// fireExec runs the exec hooks and webhooks of a sync of srv that returned result or failed with syncErr. The
+// first failure after a success, or after startup, also runs the server_offline hooks, and a result that differs
+// from prev, the stored one, in more than players and in-game time also runs the server_change hooks.
+func (m *Manager) fireExec(srv config.Server, prev, result *model.Result, syncErr error) {
+	if m.opts.Exec == nil {
+		return
+	}
+	e := exechook.Event{Server: srv.Name, Port: srv.Port, Result: result, Time: time.Now()}
+	if syncErr == nil {
+		e.Event = config.ExecEventSyncSuccess
+		m.opts.Exec.Fire(e)
+		if prev != nil {
+			if e.Changes = prev.Diff(*result, model.FieldPlayers, model.FieldTime); len(e.Changes) > 0 {
+				e.Event = config.ExecEventServerChange
+				m.opts.Exec.Fire(e)
+			}
+		}
+		return
+	}
+	e.Event, e.Error, e.ErrorKind = config.ExecEventSyncFailure, syncErr.Error(), string(errkind.Of(syncErr))
+	if state, ok := m.opts.Store.GetSyncState(srv.Port); ok {
+		e.Failures = state.ConsecutiveFailures
+	}
+	m.opts.Exec.Fire(e)
+	if e.Failures == 1 {
+		e.Event = config.ExecEventServerOffline
+		m.opts.Exec.Fire(e)
+	}
+}
+
+func (m *Manager) recordHistory(ctx context.Context, logger *zap.Logger, srv config.Server, result *model.Result, syncErr error) {
+	if m.opts.History == nil {
+		return
+	}
+	r := history.NewRecord(time.Now(), srv.Name, srv.Port, result, syncErr)
+	if err := m.opts.History.Write(ctx, r); err != nil {
+		logger.Error("write sync history", zap.Error(err))
+	}
+}
@@ -0,0 +1,479 @@
+package worker
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/client"
+	"github.com/jsirianni/dzsa-sync/config"
+	"github.com/jsirianni/dzsa-sync/internal/a2s"
+	"github.com/jsirianni/dzsa-sync/internal/dnscache"
+	"github.com/jsirianni/dzsa-sync/internal/exechook"
+	"github.com/jsirianni/dzsa-sync/internal/servers"
+	"github.com/jsirianni/dzsa-sync/internal/statefile"
+	"github.com/jsirianni/dzsa-sync/mockserver"
+	"github.com/jsirianni/dzsa-sync/model"
+	"go.uber.org/zap"
+)
+
+func TestManager_Drain(t *testing.T) {
+	srv := config.Server{Name: "main", Port: 2302}
+	cases := []struct {
+		name    string
+		dzsa    mockserver.Options
+		timeout time.Duration
+		want    bool
+	}{
+		{
+			name:    "sync in flight completes",
+			dzsa:    mockserver.Options{Latency: 300 * time.Millisecond},
+			timeout: 5 * time.Second,
+			want:    true,
+		},
+		{
+			name:    "sync in flight is cancelled at the timeout",
+			dzsa:    mockserver.Options{Faults: []mockserver.Fault{{Kind: mockserver.FaultTimeout, Rate: 1}}},
+			timeout: 100 * time.Millisecond,
+			want:    false,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.dzsa.Default = &model.Result{Name: "main", Map: "chernarusplus", MaxPlayers: 60}
+			dzsa := mockserver.New(tc.dzsa)
+			ts := httptest.NewServer(dzsa)
+			defer ts.Close()
+			defer dzsa.Close()
+
+			store := servers.New(nil)
+			m := NewManager(context.Background(), Options{
+				Logger:     zap.NewNop(),
+				Client:     client.New(client.Options{HTTPClient: ts.Client(), BaseURL: ts.URL + mockserver.QueryPath}),
+				ExternalIP: "203.0.113.10",
+				Store:      store,
+				JitterMax:  time.Nanosecond,
+			})
+			m.Reconcile(SourceConfig, []config.Server{srv})
+			waitFor(t, func() bool { return len(dzsa.Queries()) > 0 })
+
+			start := time.Now()
+			if got := m.Drain(tc.timeout); got != tc.want {
+				t.Errorf("Drain() = %v, want %v", got, tc.want)
+			}
+			if elapsed := time.Since(start); elapsed > tc.timeout+time.Second {
+				t.Errorf("Drain() took %v with a timeout of %v", elapsed, tc.timeout)
+			}
+			if _, ok := store.Get(srv.Port); ok != tc.want {
+				t.Errorf("result stored = %v, want %v", ok, tc.want)
+			}
+			if !m.Draining() {
+				t.Error("Draining() = false after Drain")
+			}
+			m.Reconcile(SourceConfig, []config.Server{srv, {Name: "second", Port: 2402}})
+			time.Sleep(50 * time.Millisecond)
+			if n := dzsa.Queries()["203.0.113.10:2402"]; n != 0 {
+				t.Errorf("server added after Drain was queried %d times", n)
+			}
+		})
+	}
+}
+
+func TestManager_Advertised(t *testing.T) {
+	dzsa := mockserver.New(mockserver.Options{Default: &model.Result{Name: "main", Map: "chernarusplus", MaxPlayers: 60}})
+	ts := httptest.NewServer(dzsa)
+	defer ts.Close()
+	defer dzsa.Close()
+
+	store := servers.New(nil)
+	m := NewManager(context.Background(), Options{
+		Logger:     zap.NewNop(),
+		Client:     client.New(client.Options{HTTPClient: ts.Client(), BaseURL: ts.URL + mockserver.QueryPath}),
+		ExternalIP: "203.0.113.10",
+		Store:      store,
+		JitterMax:  time.Nanosecond,
+	})
+	defer m.Drain(time.Second)
+	relayed := config.Server{Name: "relayed", Port: 2302, AdvertiseIP: "198.51.100.20", AdvertisePort: 40302}
+	translated := config.Server{Name: "translated", Port: 2402, AdvertisePort: 40402}
+	m.Reconcile(SourceConfig, []config.Server{relayed, translated})
+	waitFor(t, func() bool {
+		_, ok1 := store.Get(relayed.Port)
+		_, ok2 := store.Get(translated.Port)
+		return ok1 && ok2
+	})
+
+	queries := dzsa.Queries()
+	for _, endpoint := range []string{"198.51.100.20:40302", "203.0.113.10:40402"} {
+		if queries[endpoint] == 0 {
+			t.Errorf("%s was not queried, got %v", endpoint, queries)
+		}
+	}
+	if ip, err := m.Address(context.Background(), relayed); err != nil || ip != "198.51.100.20" {
+		t.Errorf("Address() = %q, %v, want the advertised IP", ip, err)
+	}
+}
+
+func TestManager_HostResolver(t *testing.T) {
+	var lookups int
+	resolver := dnscache.New(dnscache.Options{
+		Servers: []string{},
+		Fallback: func(_ context.Context, host string) ([]string, error) {
+			lookups++
+			return []string{"2001:db8::5", "198.51.100.7"}, nil
+		},
+	})
+	m := NewManager(context.Background(), Options{
+		Logger:   zap.NewNop(),
+		Store:    servers.New(nil),
+		Hosts:    []config.Host{{Name: "box-b", Hostname: "box-b.example.com"}},
+		Resolver: resolver,
+	})
+	defer m.Drain(time.Second)
+
+	for range 2 {
+		if ip, err := m.Address(context.Background(), config.Server{Name: "b", Port: 2302, Host: "box-b"}); err != nil || ip != "198.51.100.7" {
+			t.Errorf("Address() = %q, %v, want the host's IPv4 address", ip, err)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("resolver lookups = %d, want 1: the hostname is cached", lookups)
+	}
+	v6 := config.Server{Name: "b6", Port: 2402, Host: "box-b", AddressFamily: config.AddressFamilyIPv6}
+	if ip, err := m.Address(context.Background(), v6); err != nil || ip != "2001:db8::5" {
+		t.Errorf("Address() of an ipv6 server = %q, %v, want the host's IPv6 address", ip, err)
+	}
+}
+
+func TestManager_AddressFamily(t *testing.T) {
+	dzsa := mockserver.New(mockserver.Options{Default: &model.Result{Name: "main", Map: "chernarusplus", MaxPlayers: 60}})
+	ts := httptest.NewServer(dzsa)
+	defer ts.Close()
+	defer dzsa.Close()
+
+	store := servers.New(nil)
+	m := NewManager(context.Background(), Options{
+		Logger:       zap.NewNop(),
+		Client:       client.New(client.Options{HTTPClient: ts.Client(), BaseURL: ts.URL + mockserver.QueryPath}),
+		ExternalIP:   "203.0.113.10",
+		ExternalIPv6: "2001:db8::10",
+		Store:        store,
+		JitterMax:    time.Nanosecond,
+	})
+	defer m.Drain(time.Second)
+	v4 := config.Server{Name: "v4", Port: 2302}
+	v6 := config.Server{Name: "v6", Port: 2402, AddressFamily: config.AddressFamilyIPv6}
+	m.Reconcile(SourceConfig, []config.Server{v4, v6})
+	waitFor(t, func() bool {
+		_, ok1 := store.Get(v4.Port)
+		_, ok2 := store.Get(v6.Port)
+		return ok1 && ok2
+	})
+
+	queries := dzsa.Queries()
+	for _, endpoint := range []string{"203.0.113.10:2302", "[2001:db8::10]:2402"} {
+		if queries[endpoint] == 0 {
+			t.Errorf("%s was not queried, got %v", endpoint, queries)
+		}
+	}
+}
+
+func TestManager_SyncInterval(t *testing.T) {
+	dzsa := mockserver.New(mockserver.Options{Default: &model.Result{Name: "main", Map: "chernarusplus", MaxPlayers: 60}})
+	ts := httptest.NewServer(dzsa)
+	defer ts.Close()
+	defer dzsa.Close()
+
+	store := servers.New(nil)
+	m := NewManager(context.Background(), Options{
+		Logger:     zap.NewNop(),
+		Client:     client.New(client.Options{HTTPClient: ts.Client(), BaseURL: ts.URL + mockserver.QueryPath}),
+		ExternalIP: "203.0.113.10",
+		Store:      store,
+		Interval:   30 * time.Minute,
+		JitterMax:  time.Nanosecond,
+	})
+	defer m.Drain(time.Second)
+	m.Reconcile(SourceConfig, []config.Server{{Name: "default", Port: 2302}, {Name: "own", Port: 2402, SyncInterval: 2 * time.Hour}})
+	waitFor(t, func() bool {
+		_, ok1 := store.Get(2302)
+		_, ok2 := store.Get(2402)
+		return ok1 && ok2
+	})
+
+	for port, want := range map[int]time.Duration{2302: 30 * time.Minute, 2402: 2 * time.Hour} {
+		var next time.Time
+		waitFor(t, func() bool {
+			at, ok := store.NextSync(port, time.Now())
+			next = at
+			return ok && time.Until(at) > time.Minute
+		})
+		if d := time.Until(next); d > want || d < want-time.Minute {
+			t.Errorf("port %d syncs next in %v, want %v", port, d, want)
+		}
+	}
+}
+
+func TestManager_FailureSchedule(t *testing.T) {
+	// Without a default result, DZSA answers every query with an error until the server is set.
+	dzsa := mockserver.New(mockserver.Options{})
+	ts := httptest.NewServer(dzsa)
+	defer ts.Close()
+	defer dzsa.Close()
+
+	store := servers.New(nil)
+	m := NewManager(context.Background(), Options{
+		Logger:          zap.NewNop(),
+		Client:          client.New(client.Options{HTTPClient: ts.Client(), BaseURL: ts.URL + mockserver.QueryPath}),
+		ExternalIP:      "203.0.113.10",
+		Store:           store,
+		Interval:        30 * time.Minute,
+		JitterMax:       time.Nanosecond,
+		FailureSchedule: []time.Duration{2 * time.Minute, 10 * time.Minute},
+	})
+	defer m.Drain(time.Second)
+	const port = 2302
+	m.Reconcile(SourceConfig, []config.Server{{Name: "main", Port: port}})
+
+	for i, want := range []time.Duration{2 * time.Minute, 10 * time.Minute, 10 * time.Minute, 30 * time.Minute} {
+		if i == 3 {
+			dzsa.Set("203.0.113.10:2302", model.Result{Name: "main", Map: "chernarusplus", MaxPlayers: 60})
+		}
+		if i > 0 {
+			m.Trigger(port)
+		}
+		waitFor(t, func() bool {
+			at, ok := store.NextSync(port, time.Now())
+			d := time.Until(at)
+			return dzsa.Queries()["203.0.113.10:2302"] == i+1 && ok && d <= want && d > want-time.Minute
+		})
+	}
+	if _, ok := store.Get(port); !ok {
+		t.Error("server not stored after a successful sync")
+	}
+}
+
+func TestManager_ServerChange(t *testing.T) {
+	dzsa := mockserver.New(mockserver.Options{})
+	ts := httptest.NewServer(dzsa)
+	defer ts.Close()
+	defer dzsa.Close()
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	hook := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		events = append(events, string(b))
+		mu.Unlock()
+	}))
+	defer hook.Close()
+	received := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(events)
+	}
+
+	exec, err := exechook.New(exechook.Options{Webhooks: []config.Webhook{{
+		Name:   "changes",
+		Events: []string{config.ExecEventServerChange},
+		URL:    hook.URL,
+		Body:   `{{range .Changes}}{{.Field}}:{{.Old}}->{{.New}} {{end}}`,
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(context.Background(), Options{
+		Logger:     zap.NewNop(),
+		Client:     client.New(client.Options{HTTPClient: ts.Client(), BaseURL: ts.URL + mockserver.QueryPath}),
+		ExternalIP: "203.0.113.10",
+		Store:      servers.New(nil),
+		Interval:   30 * time.Minute,
+		JitterMax:  time.Nanosecond,
+		Exec:       exec,
+	})
+	defer m.Drain(time.Second)
+	const port = 2302
+	result := model.Result{Name: "main", Map: "chernarusplus", MaxPlayers: 60, Version: "1.25"}
+	dzsa.Set("203.0.113.10:2302", result)
+	m.Reconcile(SourceConfig, []config.Server{{Name: "main", Port: port}})
+
+	// Neither the first result nor a new player count is a change; a new version is.
+	for i, players := range []int{0, 5, 5} {
+		result.Players = players
+		if i == 2 {
+			result.Version = "1.26"
+		}
+		dzsa.Set("203.0.113.10:2302", result)
+		if i > 0 {
+			m.Trigger(port)
+		}
+		waitFor(t, func() bool { return dzsa.Queries()["203.0.113.10:2302"] == i+1 })
+	}
+	waitFor(t, func() bool { return len(received()) > 0 })
+	exec.Wait()
+	if got := received(); len(got) != 1 || got[0] != "version:1.25->1.26 " {
+		t.Errorf("server_change webhooks = %q, want one for the version", got)
+	}
+}
+
+func TestManager_StateFileColdStart(t *testing.T) {
+	dzsa := mockserver.New(mockserver.Options{})
+	ts := httptest.NewServer(dzsa)
+	defer ts.Close()
+	defer dzsa.Close()
+	dzsa.Set("203.0.113.20:2302", model.Result{Name: "main", Map: "chernarusplus", MaxPlayers: 60})
+
+	// The previous process synced just now, at another external IP.
+	prev := servers.New([]int{2302})
+	prev.Set(2302, &model.Result{Name: "main", Endpoint: model.Endpoint{IP: "203.0.113.10", Port: 2302}})
+	prev.RecordSync(2302, time.Now().UTC(), nil)
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := statefile.New(statefile.Options{Logger: zap.NewNop(), Store: prev, Path: path}).Write(); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := statefile.Load(path)
+	if err != nil || snap == nil {
+		t.Fatalf("Load() = %v, %v", snap, err)
+	}
+	store := servers.New(nil)
+	store.Restore(*snap)
+
+	m := NewManager(context.Background(), Options{
+		Logger:     zap.NewNop(),
+		Client:     client.New(client.Options{HTTPClient: ts.Client(), BaseURL: ts.URL + mockserver.QueryPath}),
+		ExternalIP: "203.0.113.20",
+		Store:      store,
+		Interval:   30 * time.Minute,
+		JitterMax:  time.Nanosecond,
+	})
+	defer m.Drain(time.Second)
+	store.AddPort(2302)
+	if r, ok := store.Get(2302); !ok || r.Endpoint.IP != "203.0.113.10" {
+		t.Errorf("restored result = %+v, %v, want the previous result until the first sync", r, ok)
+	}
+	m.Reconcile(SourceConfig, []config.Server{{Name: "main", Port: 2302}})
+	// The server syncs at startup instead of an interval after the previous process's sync.
+	waitFor(t, func() bool { return dzsa.Queries()["203.0.113.20:2302"] == 1 })
+}
+
+func TestManager_RequireUp(t *testing.T) {
+	dzsa := mockserver.New(mockserver.Options{Default: &model.Result{Name: "main", Map: "chernarusplus", MaxPlayers: 60}})
+	ts := httptest.NewServer(dzsa)
+	defer ts.Close()
+	defer dzsa.Close()
+	// The query port never answers, as when the server is down.
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	port := silent.LocalAddr().(*net.UDPAddr).Port
+
+	store := servers.New(nil)
+	m := NewManager(context.Background(), Options{
+		Logger:     zap.NewNop(),
+		Client:     client.New(client.Options{HTTPClient: ts.Client(), BaseURL: ts.URL + mockserver.QueryPath}),
+		ExternalIP: "203.0.113.10",
+		Store:      store,
+		A2S:        &a2s.Client{Timeout: 100 * time.Millisecond},
+		RequireUp:  true,
+		JitterMax:  time.Nanosecond,
+	})
+	defer m.Drain(time.Second)
+	m.Reconcile(SourceConfig, []config.Server{{Name: "main", Port: port}})
+
+	var state servers.SyncState
+	waitFor(t, func() bool {
+		s, ok := store.GetSyncState(port)
+		state = s
+		return ok && !s.LastAttempt.IsZero()
+	})
+	if state.LastErrorKind != "network" || !strings.Contains(state.LastError, "server is down") {
+		t.Errorf("sync state = %+v, want a server is down error", state)
+	}
+	if n := len(dzsa.Queries()); n != 0 {
+		t.Errorf("DZSA was queried %d times for a server that is down", n)
+	}
+}
+
+type fakePlayerCount struct {
+	mu         sync.Mutex
+	players    map[string]int64
+	maxPlayers map[string]int64
+	online     map[string]bool
+}
+
+func (f *fakePlayerCount) RecordServerPlayerCount(_ context.Context, name string, count int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.players[name] = count
+}
+
+func (f *fakePlayerCount) RecordServerMaxPlayers(_ context.Context, name string, count int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.maxPlayers[name] = count
+}
+
+func (f *fakePlayerCount) RecordServerOnline(_ context.Context, name string, online bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.online[name] = online
+}
+
+func TestManager_PlayerCount(t *testing.T) {
+	// Only the first server is known to DZSA; the second is answered with a DZSA error.
+	dzsa := mockserver.New(mockserver.Options{Servers: map[string]model.Result{
+		"203.0.113.10:2302": {Name: "main", Map: "chernarusplus", Players: 12, MaxPlayers: 60},
+	}})
+	ts := httptest.NewServer(dzsa)
+	defer ts.Close()
+	defer dzsa.Close()
+
+	rec := &fakePlayerCount{players: map[string]int64{}, maxPlayers: map[string]int64{}, online: map[string]bool{}}
+	m := NewManager(context.Background(), Options{
+		Logger:      zap.NewNop(),
+		Client:      client.New(client.Options{HTTPClient: ts.Client(), BaseURL: ts.URL + mockserver.QueryPath}),
+		ExternalIP:  "203.0.113.10",
+		Store:       servers.New(nil),
+		PlayerCount: rec,
+		JitterMax:   time.Nanosecond,
+	})
+	defer m.Drain(time.Second)
+	m.Reconcile(SourceConfig, []config.Server{{Name: "main", Port: 2302}, {Name: "down", Port: 2402}})
+	waitFor(t, func() bool {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return len(rec.online) == 2
+	})
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if !rec.online["main"] || rec.players["main"] != 12 || rec.maxPlayers["main"] != 60 {
+		t.Errorf("main recorded online %v, players %d, max players %d", rec.online["main"], rec.players["main"], rec.maxPlayers["main"])
+	}
+	if _, ok := rec.players["down"]; rec.online["down"] || ok {
+		t.Errorf("down recorded online %v, players %v", rec.online["down"], ok)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
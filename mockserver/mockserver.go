@@ -0,0 +1,285 @@
+// Package mockserver implements a fake DZSA query API for local development and integration tests, with
+// configurable responses, latency, and fault injection. Point client.Options.BaseURL, or staging.url in the
+// daemon config, at the server's address followed by QueryPath.
+package mockserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/model"
+)
+
+// QueryPath is the path the query API is served at, followed by /<ip>:<port>.
+const QueryPath = "/api/v1/query"
+
+// Fault kinds.
+const (
+	// FaultStatus answers with Fault.Status.
+	FaultStatus = "status"
+	// FaultError answers 200 with a DZSA error body, as DZSA does when it cannot query the server.
+	FaultError = "error"
+	// FaultTimeout does not answer until the client gives up.
+	FaultTimeout = "timeout"
+	// FaultMalformed answers 200 with a body that is not JSON.
+	FaultMalformed = "malformed"
+)
+
+// Fault makes a share of queries fail.
+type Fault struct {
+	// Kind is FaultStatus, FaultError, FaultTimeout, or FaultMalformed.
+	Kind string
+	// Rate is the share of queries that fail with this fault, from 0 to 1.
+	Rate float64
+	// Status is the HTTP status for FaultStatus. Zero uses 503.
+	Status int
+	// Message is the error for FaultError. Empty uses "Timeout has occurred".
+	Message string
+}
+
+// Options configures a Server.
+type Options struct {
+	// Servers are the results returned by endpoint ("ip:port"). The endpoint is filled in from the query.
+	Servers map[string]model.Result
+	// Default is returned for endpoints not in Servers. Nil answers them with a DZSA error.
+	Default *model.Result
+	// Latency delays every response, plus a random share of up to Jitter.
+	Latency time.Duration
+	Jitter  time.Duration
+	// Faults are tried in order for every query; the first one whose Rate hits is applied.
+	Faults []Fault
+}
+
+// Validate checks the fault kinds and rates.
+func (o *Options) Validate() error {
+	for i, f := range o.Faults {
+		switch f.Kind {
+		case FaultStatus, FaultError, FaultTimeout, FaultMalformed:
+		default:
+			return fmt.Errorf("faults[%d]: unknown kind %q", i, f.Kind)
+		}
+		if f.Rate < 0 || f.Rate > 1 {
+			return fmt.Errorf("faults[%d]: rate must be 0-1, got %v", i, f.Rate)
+		}
+		if f.Status != 0 && (f.Status < 100 || f.Status > 599) {
+			return fmt.Errorf("faults[%d]: invalid status %d", i, f.Status)
+		}
+	}
+	if o.Latency < 0 || o.Jitter < 0 {
+		return fmt.Errorf("latency and jitter must not be negative")
+	}
+	return nil
+}
+
+// Server is a fake DZSA query API. Responses can be changed while it serves, through its methods or the
+// /mock/ endpoints. Safe for concurrent use.
+type Server struct {
+	mux *http.ServeMux
+
+	mu      sync.Mutex
+	opts    Options
+	queries map[string]int
+	// done is closed by Close, releasing queries held by FaultTimeout.
+	done chan struct{}
+	once sync.Once
+}
+
+// New returns a server answering with opts, which must be valid.
+func New(opts Options) *Server {
+	servers := make(map[string]model.Result, len(opts.Servers))
+	for endpoint, r := range opts.Servers {
+		servers[endpoint] = r
+	}
+	opts.Servers = servers
+	s := &Server{mux: http.NewServeMux(), opts: opts, queries: make(map[string]int), done: make(chan struct{})}
+	s.mux.HandleFunc("GET "+QueryPath+"/{endpoint}", s.query)
+	s.mux.HandleFunc("PUT /mock/servers/{endpoint}", s.putServer)
+	s.mux.HandleFunc("DELETE /mock/servers/{endpoint}", s.deleteServer)
+	s.mux.HandleFunc("GET /mock/queries", s.getQueries)
+	return s
+}
+
+// ServeHTTP serves the query API and the /mock/ endpoints:
+//
+//	GET    /api/v1/query/<ip>:<port>  the DZSA query
+//	PUT    /mock/servers/<ip>:<port>  set the result for an endpoint (JSON model.Result)
+//	DELETE /mock/servers/<ip>:<port>  remove an endpoint
+//	GET    /mock/queries              queries received, by endpoint
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Close releases queries held by FaultTimeout. Call it before closing the HTTP server.
+func (s *Server) Close() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// Set sets the result returned for endpoint ("ip:port").
+func (s *Server) Set(endpoint string, r model.Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opts.Servers[endpoint] = r
+}
+
+// Remove removes endpoint, which is then answered with Default.
+func (s *Server) Remove(endpoint string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.opts.Servers, endpoint)
+}
+
+// SetFaults replaces the faults.
+func (s *Server) SetFaults(faults []Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opts.Faults = faults
+}
+
+// Queries returns the number of queries received by endpoint.
+func (s *Server) Queries() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]int, len(s.queries))
+	for endpoint, n := range s.queries {
+		out[endpoint] = n
+	}
+	return out
+}
+
+func (s *Server) query(w http.ResponseWriter, r *http.Request) {
+	endpoint := r.PathValue("endpoint")
+	s.mu.Lock()
+	s.queries[endpoint]++
+	result, ok := s.opts.Servers[endpoint]
+	if !ok && s.opts.Default != nil {
+		result, ok = *s.opts.Default, true
+	}
+	fault := s.fault()
+	delay := s.opts.Latency
+	if s.opts.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(s.opts.Jitter))) // #nosec G404 -- simulated latency only
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-time.After(delay):
+	case <-r.Context().Done():
+		return
+	case <-s.done:
+		return
+	}
+
+	switch {
+	case fault == nil:
+	case fault.Kind == FaultStatus:
+		status := fault.Status
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	case fault.Kind == FaultError:
+		msg := fault.Message
+		if msg == "" {
+			msg = "Timeout has occurred"
+		}
+		writeJSON(w, model.QueryError{Status: 1, Error: msg})
+		return
+	case fault.Kind == FaultTimeout:
+		select {
+		case <-r.Context().Done():
+		case <-s.done:
+		}
+		return
+	case fault.Kind == FaultMalformed:
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":0,"result":`))
+		return
+	}
+
+	parsed, err := model.ParseEndpoint(endpoint)
+	if err != nil {
+		writeJSON(w, model.QueryError{Status: 1, Error: "Invalid address"})
+		return
+	}
+	if !ok {
+		writeJSON(w, model.QueryError{Status: 1, Error: "Timeout has occurred"})
+		return
+	}
+	result.Endpoint = parsed
+	writeJSON(w, model.QueryResponse{Result: result})
+}
+
+// fault returns the fault to apply to a query, or nil. s.mu must be held.
+func (s *Server) fault() *Fault {
+	for i := range s.opts.Faults {
+		if rand.Float64() < s.opts.Faults[i].Rate { // #nosec G404 -- fault injection only
+			f := s.opts.Faults[i]
+			return &f
+		}
+	}
+	return nil
+}
+
+func (s *Server) putServer(w http.ResponseWriter, r *http.Request) {
+	endpoint := r.PathValue("endpoint")
+	if _, err := model.ParseEndpoint(endpoint); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var result model.Result
+	if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
+		http.Error(w, "decode result: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.Set(endpoint, result)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) deleteServer(w http.ResponseWriter, r *http.Request) {
+	s.Remove(r.PathValue("endpoint"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getQueries(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, s.Queries())
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// ParseFault parses a fault flag: kind=rate, with an optional :status for FaultStatus, e.g. "status=0.1:429"
+// or "timeout=0.05".
+func ParseFault(s string) (Fault, error) {
+	kind, rest, ok := strings.Cut(s, "=")
+	if !ok {
+		return Fault{}, fmt.Errorf("fault %q: want kind=rate", s)
+	}
+	f := Fault{Kind: kind}
+	rate, status, hasStatus := strings.Cut(rest, ":")
+	var err error
+	if f.Rate, err = strconv.ParseFloat(rate, 64); err != nil {
+		return Fault{}, fmt.Errorf("fault %q: invalid rate %q", s, rate)
+	}
+	if hasStatus {
+		if kind != FaultStatus {
+			return Fault{}, fmt.Errorf("fault %q: a status is only allowed for %s", s, FaultStatus)
+		}
+		if f.Status, err = strconv.Atoi(status); err != nil {
+			return Fault{}, fmt.Errorf("fault %q: invalid status %q", s, status)
+		}
+	}
+	o := Options{Faults: []Fault{f}}
+	if err := o.Validate(); err != nil {
+		return Fault{}, fmt.Errorf("fault %q: %w", s, err)
+	}
+	return f, nil
+}
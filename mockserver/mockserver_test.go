@@ -0,0 +1,116 @@
+package mockserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jsirianni/dzsa-sync/client"
+	"github.com/jsirianni/dzsa-sync/internal/errkind"
+	"github.com/jsirianni/dzsa-sync/model"
+)
+
+func newTestServer(t *testing.T, opts Options) (*Server, client.Client) {
+	t.Helper()
+	mock := New(opts)
+	srv := httptest.NewServer(mock)
+	t.Cleanup(func() {
+		mock.Close()
+		srv.Close()
+	})
+	return mock, client.New(client.Options{HTTPClient: srv.Client(), BaseURL: srv.URL + QueryPath})
+}
+
+func TestServer_Query(t *testing.T) {
+	mock, c := newTestServer(t, Options{
+		Servers: map[string]model.Result{"203.0.113.10:2424": {Name: "main", Players: 12, MaxPlayers: 60, Map: "chernarusplus"}},
+	})
+	ctx := context.Background()
+
+	resp, err := c.Query(ctx, "203.0.113.10", 2424)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if r := resp.Result; r.Name != "main" || r.Players != 12 || r.Endpoint != (model.Endpoint{IP: "203.0.113.10", Port: 2424}) {
+		t.Errorf("Query() = %+v", r)
+	}
+
+	if _, err := c.Query(ctx, "203.0.113.10", 2324); err == nil || errkind.Of(err) != errkind.Upstream {
+		t.Errorf("Query() of an unknown endpoint error = %v, want an upstream_api error", err)
+	}
+	mock.Set("203.0.113.10:2324", model.Result{Name: "modded", MaxPlayers: 60})
+	if resp, err := c.Query(ctx, "203.0.113.10", 2324); err != nil || resp.Result.Name != "modded" {
+		t.Errorf("Query() after Set = %+v, %v", resp, err)
+	}
+	if got := mock.Queries(); got["203.0.113.10:2424"] != 1 || got["203.0.113.10:2324"] != 2 {
+		t.Errorf("Queries() = %v", got)
+	}
+}
+
+func TestServer_Default(t *testing.T) {
+	_, c := newTestServer(t, Options{Default: &model.Result{Name: "any", MaxPlayers: 60}})
+	resp, err := c.Query(context.Background(), "198.51.100.7", 27016)
+	if err != nil || resp.Result.Name != "any" || resp.Result.Endpoint.Port != 27016 {
+		t.Errorf("Query() = %+v, %v, want the default result", resp, err)
+	}
+}
+
+func TestServer_Faults(t *testing.T) {
+	tests := []struct {
+		fault Fault
+		check func(error) bool
+	}{
+		{Fault{Kind: FaultStatus, Rate: 1, Status: http.StatusTooManyRequests}, func(err error) bool {
+			var statusErr *client.StatusError
+			return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusTooManyRequests
+		}},
+		{Fault{Kind: FaultError, Rate: 1, Message: "Failed to query"}, func(err error) bool {
+			return strings.Contains(err.Error(), "Failed to query") && !client.Retryable(err)
+		}},
+		{Fault{Kind: FaultMalformed, Rate: 1}, func(err error) bool { return errkind.Of(err) == errkind.Upstream }},
+		{Fault{Kind: FaultTimeout, Rate: 1}, func(err error) bool { return client.Retryable(err) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fault.Kind, func(t *testing.T) {
+			_, c := newTestServer(t, Options{Default: &model.Result{Name: "any"}, Faults: []Fault{tt.fault}})
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			_, err := c.Query(ctx, "203.0.113.10", 2424)
+			if err == nil || !tt.check(err) {
+				t.Errorf("Query() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestParseFault(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Fault
+		wantErr bool
+	}{
+		{in: "timeout=0.05", want: Fault{Kind: FaultTimeout, Rate: 0.05}},
+		{in: "status=0.1:429", want: Fault{Kind: FaultStatus, Rate: 0.1, Status: 429}},
+		{in: "status=0.1", want: Fault{Kind: FaultStatus, Rate: 0.1}},
+		{in: "timeout", wantErr: true},
+		{in: "slow=0.1", wantErr: true},
+		{in: "error=1.5", wantErr: true},
+		{in: "error=0.1:500", wantErr: true},
+		{in: "status=0.1:42", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseFault(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFault() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseFault() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SchemaDrift describes how a DZSA response differed from the model. Fields are named by JSON path,
+// e.g. "result.mods[].steamWorkshopId".
+type SchemaDrift struct {
+	// Unknown holds fields the model does not have, as received.
+	Unknown map[string]json.RawMessage `json:"unknown,omitempty"`
+	// Coerced lists fields that arrived as another JSON type and were converted, e.g. a numeric string.
+	Coerced []string `json:"coerced,omitempty"`
+	// Invalid lists fields that could not be converted and were left empty.
+	Invalid []string `json:"invalid,omitempty"`
+}
+
+// UnknownFields returns the paths of the unknown fields, sorted.
+func (d *SchemaDrift) UnknownFields() []string {
+	if d == nil {
+		return nil
+	}
+	return slices.Sorted(maps.Keys(d.Unknown))
+}
+
+// String returns a stable summary of the drift, e.g. to log each distinct change once.
+func (d *SchemaDrift) String() string {
+	if d == nil {
+		return ""
+	}
+	return fmt.Sprintf("unknown=%v coerced=%v invalid=%v", d.UnknownFields(), d.Coerced, d.Invalid)
+}
+
+func (d *SchemaDrift) empty() bool {
+	return len(d.Unknown) == 0 && len(d.Coerced) == 0 && len(d.Invalid) == 0
+}
+
+// UnmarshalJSON decodes a DZSA response without failing on schema changes: unknown fields are kept in
+// Drift, values of the wrong type are converted where the meaning is clear (numeric strings, numbers as
+// strings, "true"/"1" as booleans), and anything else is left empty. Drift is nil when the response
+// matched the model. Only a body that is not a JSON object is an error.
+func (q *QueryResponse) UnmarshalJSON(b []byte) error {
+	*q = QueryResponse{}
+	d := &SchemaDrift{Unknown: make(map[string]json.RawMessage)}
+	if err := decodeObject(b, reflect.ValueOf(q).Elem(), "", d); err != nil {
+		return err
+	}
+	if !d.empty() {
+		q.Drift = d
+	}
+	return nil
+}
+
+// decodeObject decodes the JSON object b into the struct v field by field.
+func decodeObject(b []byte, v reflect.Value, path string, d *SchemaDrift) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		if data, ok := raw[name]; ok {
+			decodeValue(data, v.Field(i), path+name, d)
+			delete(raw, name)
+		}
+	}
+	for name, data := range raw {
+		d.Unknown[path+name] = data
+	}
+	return nil
+}
+
+// decodeValue decodes b into v, recording conversions and failures in d.
+func decodeValue(b []byte, v reflect.Value, path string, d *SchemaDrift) {
+	b = bytes.TrimSpace(b)
+	if string(b) == "null" {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if decodeObject(b, v, path+".", d) != nil {
+			d.invalid(path)
+		}
+		return
+	case reflect.Slice:
+		var items []json.RawMessage
+		if json.Unmarshal(b, &items) != nil {
+			d.invalid(path)
+			return
+		}
+		s := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			decodeValue(item, s.Index(i), path+"[]", d)
+		}
+		v.Set(s)
+		return
+	}
+	if json.Unmarshal(b, v.Addr().Interface()) == nil {
+		return
+	}
+	if coerce(b, v) {
+		d.once(&d.Coerced, path)
+	} else {
+		d.invalid(path)
+	}
+}
+
+// coerce converts a scalar of another JSON type into v.
+func coerce(b []byte, v reflect.Value) bool {
+	text := string(b)
+	if s, err := strconv.Unquote(text); err == nil {
+		text = strings.TrimSpace(s)
+	}
+	switch v.Kind() {
+	case reflect.String:
+		if b[0] == '{' || b[0] == '[' {
+			return false
+		}
+		v.SetString(text)
+		return true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil && !v.OverflowInt(n) {
+			v.SetInt(n)
+			return true
+		}
+		if f, err := strconv.ParseFloat(text, 64); err == nil && f == float64(int64(f)) && !v.OverflowInt(int64(f)) {
+			v.SetInt(int64(f))
+			return true
+		}
+	case reflect.Bool:
+		if t, err := strconv.ParseBool(text); err == nil {
+			v.SetBool(t)
+			return true
+		}
+	}
+	return false
+}
+
+func (d *SchemaDrift) invalid(path string) { d.once(&d.Invalid, path) }
+
+// once appends path to list unless it is there already, so a field of every mod is listed once.
+func (d *SchemaDrift) once(list *[]string, path string) {
+	if !slices.Contains(*list, path) {
+		*list = append(*list, path)
+	}
+}
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestQueryResponseUnmarshal(t *testing.T) {
+	t.Run("matching schema", func(t *testing.T) {
+		var q QueryResponse
+		body := `{"status":0,"result":{"name":"main","players":12,"endpoint":{"ip":"203.0.113.10","port":2424},"mods":[{"name":"CF","steamWorkshopId":1559212036}]}}`
+		if err := json.Unmarshal([]byte(body), &q); err != nil {
+			t.Fatal(err)
+		}
+		want := Result{Name: "main", Players: 12, Endpoint: Endpoint{IP: "203.0.113.10", Port: 2424}, Mods: []Mods{{Name: "CF", SteamWorkshopID: 1559212036}}}
+		if !reflect.DeepEqual(q.Result, want) || q.Drift != nil {
+			t.Errorf("got %+v, drift %v", q.Result, q.Drift)
+		}
+	})
+
+	t.Run("drift", func(t *testing.T) {
+		var q QueryResponse
+		body := `{
+			"status": "0",
+			"queue": 3,
+			"result": {
+				"name": "main",
+				"players": "12",
+				"maxPlayers": 60.0,
+				"battlEye": "true",
+				"version": 1.26,
+				"gamePort": "auto",
+				"mods": [{"name": "CF", "steamWorkshopId": "1559212036", "size": 1024}, {"name": "VPP", "steamWorkshopId": 1828439124}],
+				"ping": {"ms": 40}
+			}
+		}`
+		if err := json.Unmarshal([]byte(body), &q); err != nil {
+			t.Fatal(err)
+		}
+		r := q.Result
+		if r.Name != "main" || r.Players != 12 || r.MaxPlayers != 60 || !r.BattlEye || r.Version != "1.26" || r.GamePort != 0 ||
+			len(r.Mods) != 2 || r.Mods[0].SteamWorkshopID != 1559212036 || r.Mods[1].SteamWorkshopID != 1828439124 {
+			t.Errorf("result = %+v", r)
+		}
+		if q.Drift == nil {
+			t.Fatal("no drift reported")
+		}
+		if got, want := q.Drift.UnknownFields(), []string{"queue", "result.mods[].size", "result.ping"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("unknown = %v, want %v", got, want)
+		}
+		if want := []string{"result.battlEye", "result.maxPlayers", "result.mods[].steamWorkshopId", "result.players", "result.version", "status"}; !reflect.DeepEqual(q.Drift.Coerced, want) {
+			t.Errorf("coerced = %v, want %v", q.Drift.Coerced, want)
+		}
+		if want := []string{"result.gamePort"}; !reflect.DeepEqual(q.Drift.Invalid, want) {
+			t.Errorf("invalid = %v, want %v", q.Drift.Invalid, want)
+		}
+		if string(q.Drift.Unknown["result.ping"]) != `{"ms": 40}` {
+			t.Errorf("unknown result.ping = %s", q.Drift.Unknown["result.ping"])
+		}
+	})
+
+	t.Run("not an object", func(t *testing.T) {
+		var q QueryResponse
+		if err := json.Unmarshal([]byte(`[1, 2]`), &q); err == nil {
+			t.Error("decoded an array")
+		}
+	})
+}
+
+func TestDecodeQueryResponse(t *testing.T) {
+	q, err := DecodeQueryResponse(APIVersion1, []byte(`{"status":0,"result":{"name":"main","players":12}}`))
+	if err != nil || q.APIVersion != APIVersion1 || q.Result.Name != "main" || q.Result.Players != 12 {
+		t.Errorf("DecodeQueryResponse(v1) = %+v, %v", q, err)
+	}
+
+	var unsupported *UnsupportedVersionError
+	if _, err := DecodeQueryResponse(99, []byte(`{}`)); !errors.As(err, &unsupported) || unsupported.Version != 99 {
+		t.Errorf("DecodeQueryResponse(99) error = %v, want UnsupportedVersionError", err)
+	}
+	if got := APIVersions(); !reflect.DeepEqual(got, []int{APIVersion1}) {
+		t.Errorf("APIVersions() = %v", got)
+	}
+}
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// Hours of the in-game day. DayZ's sunrise and sunset move with the in-game date and the map's latitude;
+// these are a year-round approximation for classifying a server as day or night.
+const (
+	DayStartHour   = 6
+	NightStartHour = 20
+)
+
+// GameTime is an in-game time of day, as reported in Result.Time.
+type GameTime struct {
+	Hour   int
+	Minute int
+}
+
+// ParseGameTime parses an in-game time in the "HH:MM" form DZSA reports.
+func ParseGameTime(s string) (GameTime, error) {
+	var t GameTime
+	if _, err := fmt.Sscanf(s, "%d:%d", &t.Hour, &t.Minute); err != nil || len(s) < 4 || len(s) > 5 {
+		return GameTime{}, fmt.Errorf("invalid game time %q", s)
+	}
+	if t.Hour < 0 || t.Hour > 23 || t.Minute < 0 || t.Minute > 59 {
+		return GameTime{}, fmt.Errorf("invalid game time %q", s)
+	}
+	return t, nil
+}
+
+// String returns the time as "HH:MM".
+func (t GameTime) String() string {
+	return fmt.Sprintf("%02d:%02d", t.Hour, t.Minute)
+}
+
+// Night reports whether t is between NightStartHour and DayStartHour.
+func (t GameTime) Night() bool {
+	return t.Hour < DayStartHour || t.Hour >= NightStartHour
+}
+
+// UntilPhaseChange returns the real time until night starts, or until day starts at night, on a server
+// running at the given time acceleration (in-game minutes per real minute). An acceleration below 1 is
+// treated as 1. Servers often run nights faster than days with a setting DZSA does not report, so the
+// estimate for a night is an upper bound.
+func (t GameTime) UntilPhaseChange(acceleration int) time.Duration {
+	now := t.Hour*60 + t.Minute
+	next := NightStartHour * 60
+	if t.Night() {
+		next = DayStartHour * 60
+	}
+	gameMinutes := (next - now + 24*60) % (24 * 60)
+	return time.Duration(gameMinutes) * time.Minute / time.Duration(max(acceleration, 1))
+}
+
+// Daylight classifies a server's in-game time as day or night.
+type Daylight struct {
+	// Time is the in-game time as "HH:MM" when the result was observed.
+	Time  string `json:"time"`
+	Night bool   `json:"night"`
+	// PhaseChangeAt estimates when night starts, or day starts at night, from the time acceleration.
+	PhaseChangeAt time.Time `json:"phase_change_at"`
+}
+
+// Daylight returns the day/night state of r, observed at the given time, and false when r.Time is not a
+// valid in-game time.
+func (r Result) Daylight(observed time.Time) (Daylight, bool) {
+	t, err := ParseGameTime(r.Time)
+	if err != nil {
+		return Daylight{}, false
+	}
+	return Daylight{
+		Time:          t.String(),
+		Night:         t.Night(),
+		PhaseChangeAt: observed.Add(t.UntilPhaseChange(r.TimeAcceleration)).UTC().Truncate(time.Second),
+	}, true
+}
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseGameTime(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    GameTime
+		wantErr bool
+	}{
+		{"14:05", GameTime{14, 5}, false},
+		{"7:30", GameTime{7, 30}, false},
+		{"00:00", GameTime{0, 0}, false},
+		{"24:00", GameTime{}, true},
+		{"12:60", GameTime{}, true},
+		{"", GameTime{}, true},
+		{"noon", GameTime{}, true},
+		{"12:00:00", GameTime{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseGameTime(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseGameTime(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestUntilPhaseChange(t *testing.T) {
+	tests := []struct {
+		t     GameTime
+		accel int
+		night bool
+		want  time.Duration
+	}{
+		{GameTime{12, 0}, 1, false, 8 * time.Hour},
+		{GameTime{12, 0}, 4, false, 2 * time.Hour},
+		{GameTime{19, 59}, 1, false, time.Minute},
+		{GameTime{20, 0}, 0, true, 10 * time.Hour},
+		{GameTime{23, 0}, 6, true, time.Hour + 10*time.Minute},
+		{GameTime{5, 30}, 1, true, 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		if tt.t.Night() != tt.night {
+			t.Errorf("%v Night() = %v, want %v", tt.t, !tt.night, tt.night)
+		}
+		if got := tt.t.UntilPhaseChange(tt.accel); got != tt.want {
+			t.Errorf("%v UntilPhaseChange(%d) = %v, want %v", tt.t, tt.accel, got, tt.want)
+		}
+	}
+}
+
+func TestResultDaylight(t *testing.T) {
+	observed := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	d, ok := Result{Time: "21:30", TimeAcceleration: 12}.Daylight(observed)
+	want := Daylight{Time: "21:30", Night: true, PhaseChangeAt: observed.Add(42*time.Minute + 30*time.Second)}
+	if !ok || d != want {
+		t.Errorf("Daylight() = %+v, %v; want %+v", d, ok, want)
+	}
+	if _, ok := (Result{}).Daylight(observed); ok {
+		t.Error("Daylight() ok for a result without a time")
+	}
+}
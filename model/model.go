@@ -2,15 +2,28 @@
 package model
 
 import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"net"
+	"net/netip"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 // QueryResponse is the response from the DZSA API
-// https://dayzsalauncher.com/api/v1/query/:ip:port
+// https://dayzsalauncher.com/api/v1/query/:ip:port, and the form responses of every API version are
+// decoded into; see DecodeQueryResponse.
 type QueryResponse struct {
 	Result Result `json:"result"`
 	Status int    `json:"status"`
+	// APIVersion is the DZSA API version the response was decoded from.
+	APIVersion int `json:"-"`
+	// Drift is set when the response did not match this model: unknown fields, or values of another type.
+	Drift *SchemaDrift `json:"-"`
 }
 
 // Endpoint represents the endpoint of a DayZ server.
@@ -19,17 +32,70 @@ type Endpoint struct {
 	Port int    `json:"port"`
 }
 
+// ParseEndpoint parses "host:port", with an IPv6 address in brackets ("[2001:db8::1]:2424"), and checks
+// the port. The host is not required to be an IP address, so a hostname can be resolved afterwards; call
+// Validate before querying.
+func ParseEndpoint(s string) (Endpoint, error) {
+	host, portStr, err := net.SplitHostPort(s)
+	if err != nil {
+		if strings.Count(s, ":") > 1 && !strings.HasPrefix(s, "[") {
+			return Endpoint{}, fmt.Errorf("invalid endpoint %q: put an IPv6 address in brackets, e.g. [2001:db8::1]:2424", s)
+		}
+		return Endpoint{}, fmt.Errorf("invalid endpoint %q: %w", s, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || !validPort(port) {
+		return Endpoint{}, fmt.Errorf("invalid port in %q", s)
+	}
+	return Endpoint{IP: host, Port: port}, nil
+}
+
+// Validate checks that e has an IPv4 or IPv6 address and a port between 1 and 65535.
+func (e Endpoint) Validate() error {
+	var errs []error
+	if _, err := netip.ParseAddr(e.IP); err != nil {
+		errs = append(errs, fmt.Errorf("endpoint.ip %q is not an IP address", e.IP))
+	}
+	if !validPort(e.Port) {
+		errs = append(errs, fmt.Errorf("endpoint.port %d is out of range", e.Port))
+	}
+	return errors.Join(errs...)
+}
+
 // String returns the string representation of an endpoint.
 func (e Endpoint) String() string {
 	return net.JoinHostPort(e.IP, strconv.Itoa(e.Port))
 }
 
+func validPort(port int) bool {
+	return port >= 1 && port <= 65535
+}
+
 // Mods represents a mod of a DayZ server.
 type Mods struct {
 	Name            string `json:"name"`
 	SteamWorkshopID int    `json:"steamWorkshopId"`
 }
 
+// NormalizeMods returns mods with names trimmed, sorted by workshop ID and then name, and without
+// duplicates: mods with the same workshop ID, or local mods (ID 0) with the same name, are listed once.
+// DZSA returns mods in varying order, so comparing or hashing lists is only stable after normalizing.
+func NormalizeMods(mods []Mods) []Mods {
+	if mods == nil {
+		return nil
+	}
+	out := make([]Mods, len(mods))
+	for i, m := range mods {
+		out[i] = Mods{Name: strings.TrimSpace(m.Name), SteamWorkshopID: m.SteamWorkshopID}
+	}
+	slices.SortStableFunc(out, func(a, b Mods) int {
+		return cmp.Or(cmp.Compare(a.SteamWorkshopID, b.SteamWorkshopID), strings.Compare(a.Name, b.Name))
+	})
+	return slices.CompactFunc(out, func(a, b Mods) bool {
+		return a.SteamWorkshopID == b.SteamWorkshopID && (a.SteamWorkshopID != 0 || a.Name == b.Name)
+	})
+}
+
 // Result represents the result of a DayZ server query.
 type Result struct {
 	BattlEye         bool     `json:"battlEye"`
@@ -61,3 +127,104 @@ type QueryError struct {
 	Status int    `json:"status"`
 	Error  string `json:"error"`
 }
+
+// Result field names, as in JSON, for Change.Field and the fields to ignore in Result.Diff and Result.Equal.
+const (
+	FieldPlayers = "players"
+	// FieldTime is the in-game time, which changes on almost every query.
+	FieldTime = "time"
+)
+
+// Change is a field that differs between two results.
+type Change struct {
+	// Field is the JSON name of the field, e.g. "players" or "mods".
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+// Diff returns the fields that differ from r to other, in the order of Result's fields, skipping the
+// fields named in ignore (e.g. FieldPlayers). Mods are compared as an ordered list; see NormalizeMods.
+func (r Result) Diff(other Result, ignore ...string) []Change {
+	d := differ{ignore: ignore}
+	diffField(&d, "battlEye", r.BattlEye, other.BattlEye)
+	diffField(&d, "endpoint", r.Endpoint, other.Endpoint)
+	diffField(&d, "environment", r.Environment, other.Environment)
+	diffField(&d, "firstPersonOnly", r.FirstPersonOnly, other.FirstPersonOnly)
+	diffField(&d, "folder", r.Folder, other.Folder)
+	diffField(&d, "game", r.Game, other.Game)
+	diffField(&d, "gamePort", r.GamePort, other.GamePort)
+	diffField(&d, "map", r.Map, other.Map)
+	diffField(&d, "maxPlayers", r.MaxPlayers, other.MaxPlayers)
+	diffField(&d, "mission", r.Mission, other.Mission)
+	if !slices.Equal(r.Mods, other.Mods) {
+		d.add("mods", r.Mods, other.Mods)
+	}
+	diffField(&d, "name", r.Name, other.Name)
+	diffField(&d, "nameOverride", r.NameOverride, other.NameOverride)
+	diffField(&d, "password", r.Password, other.Password)
+	diffField(&d, FieldPlayers, r.Players, other.Players)
+	diffField(&d, "profile", r.Profile, other.Profile)
+	diffField(&d, "shard", r.Shard, other.Shard)
+	diffField(&d, "sponsor", r.Sponsor, other.Sponsor)
+	diffField(&d, FieldTime, r.Time, other.Time)
+	diffField(&d, "timeAcceleration", r.TimeAcceleration, other.TimeAcceleration)
+	diffField(&d, "vac", r.Vac, other.Vac)
+	diffField(&d, "version", r.Version, other.Version)
+	return d.changes
+}
+
+// Equal reports whether r and other have no differences outside the fields named in ignore.
+func (r Result) Equal(other Result, ignore ...string) bool {
+	return len(r.Diff(other, ignore...)) == 0
+}
+
+type differ struct {
+	ignore  []string
+	changes []Change
+}
+
+func (d *differ) add(field string, from, to any) {
+	if !slices.Contains(d.ignore, field) {
+		d.changes = append(d.changes, Change{Field: field, Old: from, New: to})
+	}
+}
+
+func diffField[T comparable](d *differ, field string, from, to T) {
+	if from != to {
+		d.add(field, from, to)
+	}
+}
+
+// Fingerprint returns a hex SHA-256 over the fields that identify what a server runs: its name, map,
+// version, and mods (independent of their order). It stays the same while only players, time, or other
+// live state change, so comparing fingerprints answers whether the server itself changed.
+func (r Result) Fingerprint() string {
+	mods := make([]string, len(r.Mods))
+	for i, m := range r.Mods {
+		mods[i] = strconv.Itoa(m.SteamWorkshopID) + ":" + strings.TrimSpace(m.Name)
+	}
+	slices.Sort(mods)
+	h := sha256.New()
+	for _, v := range append([]string{r.Name, r.Map, r.Version}, mods...) {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Validate checks the invariants every result DZSA reports for a live server should hold: a valid
+// endpoint IP and port, player counts that are not negative, and no more players than slots. It returns
+// all violations joined, or nil.
+func (r *Result) Validate() error {
+	errs := []error{r.Endpoint.Validate()}
+	if r.GamePort < 0 || r.GamePort > 65535 {
+		errs = append(errs, fmt.Errorf("gamePort %d is out of range", r.GamePort))
+	}
+	if r.Players < 0 || r.MaxPlayers < 0 {
+		errs = append(errs, fmt.Errorf("players %d and maxPlayers %d must not be negative", r.Players, r.MaxPlayers))
+	} else if r.Players > r.MaxPlayers {
+		errs = append(errs, fmt.Errorf("players %d exceeds maxPlayers %d", r.Players, r.MaxPlayers))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,167 @@
+package model
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResultDiff(t *testing.T) {
+	a := Result{
+		Name:    "main",
+		Map:     "chernarusplus",
+		Players: 10,
+		Time:    "12:00",
+		Mods:    []Mods{{Name: "CF", SteamWorkshopID: 1559212036}},
+	}
+	b := a
+	b.Players = 12
+	b.Time = "12:05"
+	b.Mods = []Mods{{Name: "CF", SteamWorkshopID: 1559212036}, {Name: "VPPAdminTools", SteamWorkshopID: 1828439124}}
+
+	want := []Change{
+		{Field: "mods", Old: a.Mods, New: b.Mods},
+		{Field: FieldPlayers, Old: 10, New: 12},
+		{Field: FieldTime, Old: "12:00", New: "12:05"},
+	}
+	if got := a.Diff(b); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %+v, want %+v", got, want)
+	}
+	if got := a.Diff(b, FieldPlayers, FieldTime); len(got) != 1 || got[0].Field != "mods" {
+		t.Errorf("Diff() ignoring players and time = %+v, want only mods", got)
+	}
+
+	if a.Equal(b) {
+		t.Error("Equal() = true for different results")
+	}
+	b.Mods = []Mods{{Name: "CF", SteamWorkshopID: 1559212036}}
+	if !a.Equal(b, FieldPlayers, FieldTime) {
+		t.Error("Equal() = false with only ignored fields differing")
+	}
+	if !a.Equal(a) || a.Diff(a) != nil {
+		t.Error("a result differs from itself")
+	}
+}
+
+func TestResultValidate(t *testing.T) {
+	valid := Result{Endpoint: Endpoint{IP: "203.0.113.10", Port: 2424}, GamePort: 2302, Players: 12, MaxPlayers: 60}
+	tests := []struct {
+		name   string
+		modify func(*Result)
+		want   []string
+	}{
+		{"valid", func(*Result) {}, nil},
+		{"full server", func(r *Result) { r.Players = 60 }, nil},
+		{"too many players", func(r *Result) { r.Players = 61 }, []string{"players 61 exceeds maxPlayers 60"}},
+		{"negative players", func(r *Result) { r.Players = -1 }, []string{"must not be negative"}},
+		{"no endpoint", func(r *Result) { r.Endpoint = Endpoint{} }, []string{"endpoint.ip", "endpoint.port 0"}},
+		{"bad ports", func(r *Result) { r.Endpoint.Port, r.GamePort = 70000, -1 }, []string{"endpoint.port 70000", "gamePort -1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid
+			tt.modify(&r)
+			err := r.Validate()
+			if (err != nil) != (len(tt.want) > 0) {
+				t.Fatalf("Validate() = %v, want violations %v", err, tt.want)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("Validate() = %v, want it to mention %q", err, w)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeMods(t *testing.T) {
+	in := []Mods{
+		{Name: "VPP ", SteamWorkshopID: 1828439124},
+		{Name: "@Local", SteamWorkshopID: 0},
+		{Name: "CF", SteamWorkshopID: 1559212036},
+		{Name: " VPP", SteamWorkshopID: 1828439124},
+		{Name: "@Local"},
+		{Name: "@Other"},
+	}
+	want := []Mods{
+		{Name: "@Local"},
+		{Name: "@Other"},
+		{Name: "CF", SteamWorkshopID: 1559212036},
+		{Name: "VPP", SteamWorkshopID: 1828439124},
+	}
+	if got := NormalizeMods(in); !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeMods() = %v, want %v", got, want)
+	}
+	if in[0].Name != "VPP " {
+		t.Error("NormalizeMods() modified its argument")
+	}
+	if NormalizeMods(nil) != nil {
+		t.Error("NormalizeMods(nil) != nil")
+	}
+}
+
+func TestParseEndpoint(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Endpoint
+		wantErr bool
+	}{
+		{"203.0.113.10:2424", Endpoint{IP: "203.0.113.10", Port: 2424}, false},
+		{"[2001:db8::1]:2424", Endpoint{IP: "2001:db8::1", Port: 2424}, false},
+		{"dayz.example.com:2424", Endpoint{IP: "dayz.example.com", Port: 2424}, false},
+		{"203.0.113.10", Endpoint{}, true},
+		{"203.0.113.10:0", Endpoint{}, true},
+		{"203.0.113.10:65536", Endpoint{}, true},
+		{"203.0.113.10:abc", Endpoint{}, true},
+		{"2001:db8::1:2424", Endpoint{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseEndpoint(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseEndpoint(%q) = %+v, %v; want %+v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+	if _, err := ParseEndpoint("2001:db8::1:2424"); err == nil || !strings.Contains(err.Error(), "brackets") {
+		t.Errorf("ParseEndpoint() error for an unbracketed IPv6 address = %v", err)
+	}
+}
+
+func TestEndpointValidate(t *testing.T) {
+	for _, e := range []Endpoint{{IP: "203.0.113.10", Port: 2424}, {IP: "2001:db8::1", Port: 1}} {
+		if err := e.Validate(); err != nil {
+			t.Errorf("%v Validate() = %v", e, err)
+		}
+	}
+	for _, e := range []Endpoint{{IP: "dayz.example.com", Port: 2424}, {IP: "", Port: 2424}, {IP: "203.0.113.10", Port: 65536}} {
+		if err := e.Validate(); err == nil {
+			t.Errorf("%v Validate() = nil, want an error", e)
+		}
+	}
+	if got := (Endpoint{IP: "2001:db8::1", Port: 2424}).String(); got != "[2001:db8::1]:2424" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestResultFingerprint(t *testing.T) {
+	a := Result{Name: "main", Map: "chernarusplus", Version: "1.26", Players: 3, Mods: []Mods{{"CF", 1559212036}, {"VPP", 1828439124}}}
+	b := a
+	b.Players, b.Time = 40, "12:00"
+	b.Mods = []Mods{{"VPP", 1828439124}, {"CF", 1559212036}}
+	if a.Fingerprint() != b.Fingerprint() {
+		t.Error("Fingerprint() changed with players, time, or mod order")
+	}
+	for _, modify := range []func(*Result){
+		func(r *Result) { r.Name = "other" },
+		func(r *Result) { r.Map = "enoch" },
+		func(r *Result) { r.Version = "1.27" },
+		func(r *Result) { r.Mods = r.Mods[:1] },
+		// Fields are separated, so moving text between them changes the fingerprint.
+		func(r *Result) { r.Name, r.Map = "mainchernarus", "plus" },
+	} {
+		c := a
+		modify(&c)
+		if c.Fingerprint() == a.Fingerprint() {
+			t.Errorf("Fingerprint() unchanged for %+v", c)
+		}
+	}
+}
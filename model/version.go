@@ -0,0 +1,60 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// DZSA API versions. Each has its own wire format and decodes into QueryResponse, so the rest of
+// dzsa-sync does not depend on the version in use.
+const (
+	APIVersion1 = 1
+)
+
+// Decoder decodes a DZSA query response body of one API version.
+type Decoder func(body []byte) (*QueryResponse, error)
+
+// decoders holds the decoder of every supported API version. When DZSA publishes a new schema, add its
+// wire types and a decoder that converts them to QueryResponse, then switch the client's version.
+var decoders = map[int]Decoder{
+	APIVersion1: decodeV1,
+}
+
+// UnsupportedVersionError is returned for an API version without a decoder.
+type UnsupportedVersionError struct {
+	Version int
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("unsupported DZSA API version %d (supported: %v)", e.Version, APIVersions())
+}
+
+// APIVersions returns the supported DZSA API versions, in ascending order.
+func APIVersions() []int {
+	return slices.Sorted(maps.Keys(decoders))
+}
+
+// DecodeQueryResponse decodes body as a response of the given DZSA API version.
+func DecodeQueryResponse(version int, body []byte) (*QueryResponse, error) {
+	decode, ok := decoders[version]
+	if !ok {
+		return nil, &UnsupportedVersionError{Version: version}
+	}
+	q, err := decode(body)
+	if err != nil {
+		return nil, err
+	}
+	q.APIVersion = version
+	return q, nil
+}
+
+// decodeV1 decodes a v1 response, which has the shape of QueryResponse; see QueryResponse.UnmarshalJSON.
+func decodeV1(body []byte) (*QueryResponse, error) {
+	q := &QueryResponse{}
+	if err := json.Unmarshal(body, q); err != nil {
+		return nil, err
+	}
+	return q, nil
+}